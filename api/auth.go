@@ -44,7 +44,10 @@ func (a *Auth) Login(ctx context.Context, authMethod AuthMethod) (*Secret, error
 // call to `sys/mfa/validate` or by passing it to the method (*Auth).MFAValidate.
 func (a *Auth) MFALogin(ctx context.Context, authMethod AuthMethod, creds ...string) (*Secret, error) {
 	if len(creds) > 0 {
-		a.c.SetMFACreds(creds)
+		// The credentials are only sent with the login request so that
+		// passcodes are not replayed with later requests
+		a.c.setPendingMFACreds(creds)
+		defer a.c.setPendingMFACreds(nil)
 		return a.login(ctx, authMethod)
 	}
 
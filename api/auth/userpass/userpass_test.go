@@ -128,3 +128,64 @@ func TestLogin(t *testing.T) {
 		t.Fatalf("no authentication info returned by login with password from string")
 	}
 }
+
+func TestCreateUsers(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/auth/my-userpass/users-batch" {
+			t.Errorf("unexpected path %q", req.URL.Path)
+		}
+
+		var payload struct {
+			Users []map[string]interface{} `json:"users"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Fatalf("error decoding json: %v", err)
+		}
+
+		secret := &api.Secret{
+			Data: map[string]interface{}{
+				"results": []map[string]interface{}{
+					{"username": payload.Users[0]["username"]},
+					{"username": payload.Users[1]["username"], "error": "missing password"},
+				},
+				"succeeded": 1,
+				"failed":    1,
+			},
+		}
+		if _, ok := payload.Users[1]["password"]; ok {
+			t.Errorf("expected empty password to be omitted")
+		}
+
+		respBytes, err := json.Marshal(secret)
+		if err != nil {
+			t.Fatalf("error marshaling json: %v", err)
+		}
+		w.Write(respBytes)
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatalf("error initializing Vault client: %v", err)
+	}
+
+	resp, err := CreateUsers(context.TODO(), client, "my-userpass", []*User{
+		{Username: "alice", Password: "my-password", TokenPolicies: []string{"dev"}},
+		{Username: "bob"},
+	})
+	if err != nil {
+		t.Fatalf("error creating users: %v", err)
+	}
+
+	if resp.Succeeded != 1 || resp.Failed != 1 || len(resp.Results) != 2 {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+	if resp.Results[0].Username != "alice" || resp.Results[0].Error != "" {
+		t.Fatalf("unexpected result: %#v", resp.Results[0])
+	}
+	if resp.Results[1].Username != "bob" || resp.Results[1].Error != "missing password" {
+		t.Fatalf("unexpected result: %#v", resp.Results[1])
+	}
+}
@@ -0,0 +1,75 @@
+package userpass
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// User is a user definition for the batch user creation endpoint. Exactly one
+// of Password or PasswordHash must be set when the user is created.
+type User struct {
+	Username string `json:"username"`
+	// The password as a plaintext string value.
+	Password string `json:"password,omitempty"`
+	// A bcrypt or argon2id (PHC string format) hash of the password.
+	PasswordHash  string            `json:"password_hash,omitempty"`
+	TokenPolicies []string          `json:"token_policies,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// UserResult is the result of creating or updating a single user. Error is
+// empty if the user was written successfully.
+type UserResult struct {
+	Username string `json:"username"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchUsersResponse is the response of the batch user creation endpoint.
+// Results are in the same order as the users in the request.
+type BatchUsersResponse struct {
+	Results   []UserResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+}
+
+// CreateUsers creates or updates the given users using the batch endpoint of
+// the userpass auth method mounted at mountPath, or at the default mount path
+// if mountPath is empty. Users are written independently, so the response
+// must be checked for per-user errors even if the returned error is nil.
+func CreateUsers(ctx context.Context, client *api.Client, mountPath string, users []*User) (*BatchUsersResponse, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if mountPath == "" {
+		mountPath = defaultMountPath
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no users provided")
+	}
+
+	path := fmt.Sprintf("auth/%s/users-batch", mountPath)
+	resp, err := client.Logical().WriteWithContext(ctx, path, map[string]interface{}{
+		"users": users,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create userpass users: %w", err)
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, fmt.Errorf("empty response from batch user creation")
+	}
+
+	// Round-trip through JSON to decode the response data into its type
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, err
+	}
+	var result BatchUsersResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing batch user creation response: %w", err)
+	}
+
+	return &result, nil
+}
@@ -71,6 +71,10 @@ const (
 	EnvVaultInsecure     = "VAULT_SKIP_VERIFY"
 )
 
+// unixSocketHost is the host of the request URLs of clients whose address is
+// a unix domain socket.
+const unixSocketHost = "unix"
+
 // WrappingLookupFunc is a function that, given an HTTP verb and a path,
 // returns an optional string duration to be used for response wrapping (e.g.
 // "15s", or simply "15"). The path will not begin with "/v1/" or "v1/" or "/",
@@ -93,6 +97,12 @@ type Config struct {
 	// complete URL such as "http://vault.example.com".
 	AgentAddress string
 
+	// UnixSocketTLS enables TLS for unix domain socket addresses such as
+	// "unix:///var/run/vault.sock", which otherwise use plain HTTP. Unless
+	// the TLS server name is configured, the certificate of the server must
+	// be valid for the host "unix".
+	UnixSocketTLS bool
+
 	// HttpClient is the HTTP client to use. Vault sets sane defaults for the
 	// http.Client and its associated http.Transport created in DefaultConfig.
 	// If you must modify Vault's defaults, it is suggested that you start with
@@ -176,6 +186,29 @@ type Config struct {
 	// since there will be a performance penalty paid upon each request.
 	// This feature requires Enterprise server-side.
 	ReadYourWrites bool
+
+	// MaxStateRetries is the maximum number of retries of a request failing
+	// with a 412 response, returned when the node serving it has not yet
+	// caught up with the required replication state. These retries are
+	// counted separately from MaxRetries. If zero, 412 responses are retried
+	// like any other retryable response, within MaxRetries.
+	MaxStateRetries int
+
+	// ProxyURL is the URL of the proxy the client sends its requests
+	// through, overriding the proxy environment variables. The http, https
+	// and socks5 schemes are supported. NewClient configures a copy of the
+	// transport of HttpClient, which must be an *http.Transport, so other
+	// users of the transport are unaffected.
+	ProxyURL string
+
+	// unixSocketTransport is the clone of the transport of HttpClient that
+	// parseAddress configured to dial a unix socket, and unixSocketDial is
+	// the dialer of the transport it was cloned from.
+	unixSocketTransport *http.Transport
+	unixSocketDial      func(context.Context, string, string) (net.Conn, error)
+
+	// proxyTransports is shared by the configs cloned from this one.
+	proxyTransports *proxyTransports
 }
 
 // TLSConfig contains the parameters needed to configure TLS on the HTTP client
@@ -206,6 +239,18 @@ type TLSConfig struct {
 
 	// Insecure enables or disables SSL verification
 	Insecure bool
+
+	// ReloadClientCert enables reloading ClientCert and ClientKey when they
+	// change, so that new connections present the rotated certificate
+	// without the client being recreated. Errors reloading the files are
+	// returned as a *ClientCertError by the requests opening new
+	// connections.
+	ReloadClientCert bool
+
+	// GetClientCertificate, if set, is called to provide the client
+	// certificate of each new connection. It cannot be used with ClientCert
+	// and ClientKey.
+	GetClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
 }
 
 // DefaultConfig returns a default configuration for the client. It is
@@ -264,17 +309,29 @@ func (c *Config) configureTLS(t *TLSConfig) error {
 	}
 	clientTLSConfig := c.HttpClient.Transport.(*http.Transport).TLSClientConfig
 
-	var clientCert tls.Certificate
-	foundClientCert := false
+	var getClientCert func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
 
 	switch {
+	case t.GetClientCertificate != nil && (t.ClientCert != "" || t.ClientKey != ""):
+		return fmt.Errorf("client cert and key cannot be provided along with a client certificate callback")
+	case t.GetClientCertificate != nil:
+		getClientCert = t.GetClientCertificate
+	case t.ClientCert != "" && t.ClientKey != "" && t.ReloadClientCert:
+		reloader, err := newClientCertReloader(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return err
+		}
+		getClientCert = reloader.GetClientCertificate
+		c.curlClientCert = t.ClientCert
+		c.curlClientKey = t.ClientKey
 	case t.ClientCert != "" && t.ClientKey != "":
-		var err error
-		clientCert, err = tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		clientCert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
 		if err != nil {
 			return err
 		}
-		foundClientCert = true
+		getClientCert = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &clientCert, nil
+		}
 		c.curlClientCert = t.ClientCert
 		c.curlClientKey = t.ClientKey
 	case t.ClientCert != "" || t.ClientKey != "":
@@ -298,13 +355,11 @@ func (c *Config) configureTLS(t *TLSConfig) error {
 		clientTLSConfig.InsecureSkipVerify = true
 	}
 
-	if foundClientCert {
+	if getClientCert != nil {
 		// We use this function to ignore the server's preferential list of
 		// CAs, otherwise any CA used for the cert auth backend must be in the
 		// server's CA pool
-		clientTLSConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
-			return &clientCert, nil
-		}
+		clientTLSConfig.GetClientCertificate = getClientCert
 	}
 
 	if t.TLSServerName != "" {
@@ -458,7 +513,7 @@ func (c *Config) ReadEnvironment() error {
 	}
 
 	if envVaultProxy != "" {
-		u, err := url.Parse(envVaultProxy)
+		u, err := parseProxyURL(envVaultProxy)
 		if err != nil {
 			return err
 		}
@@ -492,10 +547,33 @@ type Client struct {
 	headers               http.Header
 	wrappingLookupFunc    WrappingLookupFunc
 	mfaCreds              []string
+	pendingMFACreds       []string
 	policyOverride        bool
 	requestCallbacks      []RequestCallback
 	responseCallbacks     []ResponseCallback
 	replicationStateStore *replicationStateStore
+
+	// rateLimitStats is shared by the clients copied from this one.
+	rateLimitStats *rateLimitStats
+
+	// autoReauth is set when automatic re-authentication is enabled.
+	autoReauth *autoReauth
+
+	// unixSocket is the path of the unix domain socket requests are sent
+	// through, if the address is a unix socket address.
+	unixSocket string
+
+	requestHooks   []RequestHook
+	responseHooks  []ResponseHook
+	redactedFields []string
+
+	// requestTimeout overrides the configured timeout of clients returned by
+	// WithRequestOptions.
+	requestTimeout time.Duration
+
+	// optionsErr is returned by every request of a client returned by
+	// WithRequestOptions with invalid options.
+	optionsErr error
 }
 
 // NewClient returns a new client for the given configuration.
@@ -537,36 +615,33 @@ func NewClient(c *Config) (*Client, error) {
 		c.HttpClient.Transport = def.HttpClient.Transport
 	}
 
+	if c.proxyTransports == nil {
+		c.proxyTransports = &proxyTransports{}
+	}
+	if c.ProxyURL != "" {
+		httpClient, err := c.proxyTransports.withProxy(c.HttpClient, c.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		c.HttpClient = httpClient
+	}
+
 	address := c.Address
 	if c.AgentAddress != "" {
 		address = c.AgentAddress
 	}
 
-	u, err := url.Parse(address)
+	u, socket, err := c.parseAddress(address)
 	if err != nil {
 		return nil, err
 	}
 
-	if strings.HasPrefix(address, "unix://") {
-		socket := strings.TrimPrefix(address, "unix://")
-		transport := c.HttpClient.Transport.(*http.Transport)
-		transport.DialContext = func(context.Context, string, string) (net.Conn, error) {
-			return net.Dial("unix", socket)
-		}
-
-		// Since the address points to a unix domain socket, the scheme in the
-		// *URL would be set to `unix`. The *URL in the client is expected to
-		// be pointing to the protocol used in the application layer and not to
-		// the transport layer. Hence, setting the fields accordingly.
-		u.Scheme = "http"
-		u.Host = socket
-		u.Path = ""
-	}
-
 	client := &Client{
-		addr:    u,
-		config:  c,
-		headers: make(http.Header),
+		addr:           u,
+		unixSocket:     socket,
+		config:         c,
+		headers:        make(http.Header),
+		rateLimitStats: &rateLimitStats{},
 	}
 
 	if c.ReadYourWrites {
@@ -594,6 +669,7 @@ func (c *Client) CloneConfig() *Config {
 	newConfig := DefaultConfig()
 	newConfig.Address = c.config.Address
 	newConfig.AgentAddress = c.config.AgentAddress
+	newConfig.UnixSocketTLS = c.config.UnixSocketTLS
 	newConfig.MinRetryWait = c.config.MinRetryWait
 	newConfig.MaxRetryWait = c.config.MaxRetryWait
 	newConfig.MaxRetries = c.config.MaxRetries
@@ -606,6 +682,11 @@ func (c *Client) CloneConfig() *Config {
 	newConfig.CloneHeaders = c.config.CloneHeaders
 	newConfig.CloneToken = c.config.CloneToken
 	newConfig.ReadYourWrites = c.config.ReadYourWrites
+	newConfig.MaxStateRetries = c.config.MaxStateRetries
+	newConfig.ProxyURL = c.config.ProxyURL
+	newConfig.unixSocketTransport = c.config.unixSocketTransport
+	newConfig.unixSocketDial = c.config.unixSocketDial
+	newConfig.proxyTransports = c.config.proxyTransports
 
 	// we specifically want a _copy_ of the client here, not a pointer to the original one
 	newClient := *c.config.HttpClient
@@ -621,15 +702,17 @@ func (c *Client) SetAddress(addr string) error {
 	c.modifyLock.Lock()
 	defer c.modifyLock.Unlock()
 
-	parsedAddr, err := url.Parse(addr)
+	c.config.modifyLock.Lock()
+	defer c.config.modifyLock.Unlock()
+
+	parsedAddr, socket, err := c.config.parseAddress(addr)
 	if err != nil {
 		return errwrap.Wrapf("failed to set address: {{err}}", err)
 	}
 
-	c.config.modifyLock.Lock()
 	c.config.Address = addr
-	c.config.modifyLock.Unlock()
 	c.addr = parsedAddr
+	c.unixSocket = socket
 	return nil
 }
 
@@ -638,9 +721,101 @@ func (c *Client) Address() string {
 	c.modifyLock.RLock()
 	defer c.modifyLock.RUnlock()
 
+	if c.unixSocket != "" {
+		return "unix://" + c.unixSocket
+	}
 	return c.addr.String()
 }
 
+// parseAddress parses the given address. Addresses of the form
+// unix:///path/to/socket are given a synthesized URL whose host is
+// unixSocketHost, and HttpClient is replaced with a copy whose transport is a
+// clone dialing the socket for that host; the path of the socket is returned
+// along with the URL.
+//
+// The config lock must be held.
+func (c *Config) parseAddress(address string) (*url.URL, string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if u.Scheme != "unix" {
+		return u, "", nil
+	}
+
+	socket := strings.TrimPrefix(address, "unix://")
+	if socket == "" {
+		return nil, "", fmt.Errorf("missing socket path in address %q", address)
+	}
+
+	transport, ok := c.HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return nil, "", fmt.Errorf("unix socket addresses require an *http.Transport, got %T", c.HttpClient.Transport)
+	}
+
+	// The socket is only captured by the dialer of a clone of the
+	// transport. A transport already cloned for a previous socket is cloned
+	// again with the dialer it wrapped, so that dialers aren't nested.
+	dial := transport.DialContext
+	if transport == c.unixSocketTransport {
+		dial = c.unixSocketDial
+	}
+	clone, err := cloneTransport(transport)
+	if err != nil {
+		return nil, "", err
+	}
+	clone.DialContext = unixSocketDialer(socket, dial)
+	c.unixSocketTransport, c.unixSocketDial = clone, dial
+
+	// Other users of the HTTP client, e.g. clones, are left unaffected
+	hc := *c.HttpClient
+	hc.Transport = clone
+	c.HttpClient = &hc
+
+	// Since the address points to a unix domain socket, the scheme in the
+	// *URL would be set to `unix`. The *URL in the client is expected to
+	// be pointing to the protocol used in the application layer and not to
+	// the transport layer. Hence, setting the fields accordingly.
+	scheme := "http"
+	if c.UnixSocketTLS {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: unixSocketHost}, socket, nil
+}
+
+// unixSocketDialer returns a dialer dialing the given socket for requests to
+// unixSocketHost, so that redirects to other hosts are still dialed over the
+// network with the given dialer.
+func unixSocketDialer(socket string, dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil && host == unixSocketHost {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// cloneTransport returns a clone of the transport that doesn't share its
+// connection pool.
+func cloneTransport(transport *http.Transport) (*http.Transport, error) {
+	clone := transport.Clone()
+	if _, ok := transport.TLSNextProto["h2"]; ok {
+		// The HTTP/2 support configured by x/net/http2 is bound to the
+		// connection pool of the original transport, which must not be
+		// shared with the clone
+		clone.TLSNextProto = nil
+		if err := http2.ConfigureTransport(clone); err != nil {
+			return nil, err
+		}
+	}
+	return clone, nil
+}
+
 func (c *Client) SetCheckRedirect(f func(*http.Request, []*http.Request) error) {
 	c.modifyLock.Lock()
 	defer c.modifyLock.Unlock()
@@ -729,6 +904,26 @@ func (c *Client) MaxRetries() int {
 	return c.config.MaxRetries
 }
 
+// SetMaxStateRetries sets the maximum number of retries of requests failing
+// because the node has not caught up with the required replication state.
+func (c *Client) SetMaxStateRetries(retries int) {
+	c.modifyLock.RLock()
+	defer c.modifyLock.RUnlock()
+	c.config.modifyLock.Lock()
+	defer c.config.modifyLock.Unlock()
+
+	c.config.MaxStateRetries = retries
+}
+
+func (c *Client) MaxStateRetries() int {
+	c.modifyLock.RLock()
+	defer c.modifyLock.RUnlock()
+	c.config.modifyLock.RLock()
+	defer c.config.modifyLock.RUnlock()
+
+	return c.config.MaxStateRetries
+}
+
 func (c *Client) SetSRVLookup(srv bool) {
 	c.modifyLock.RLock()
 	defer c.modifyLock.RUnlock()
@@ -845,6 +1040,77 @@ func (c *Client) SetMFACreds(creds []string) {
 	c.mfaCreds = creds
 }
 
+// MFACredential is a credential for a login MFA method, sent in the
+// X-Vault-MFA header.
+type MFACredential struct {
+	// Method is the name or ID of the MFA method.
+	Method string
+
+	// Passcode is the passcode for the method, if it requires one.
+	Passcode string
+}
+
+// String returns the credential formatted as a value of the X-Vault-MFA
+// header, i.e. method[:passcode].
+func (m MFACredential) String() string {
+	if m.Passcode == "" {
+		return m.Method
+	}
+	return m.Method + ":" + m.Passcode
+}
+
+func (m MFACredential) validate() error {
+	switch {
+	case m.Method == "":
+		return fmt.Errorf("missing MFA method name or ID")
+	case strings.Contains(m.Method, ":"):
+		return fmt.Errorf("invalid MFA method %q: must not contain ':'", m.Method)
+	}
+	return nil
+}
+
+// SetMFACredentials sets MFA credentials to be sent with the next request
+// made by the client, in addition to those set with SetMFACreds. They are
+// cleared once that request is created so that passcodes are never sent
+// twice. Credentials for several methods may be given.
+//
+// The credentials only apply to requests made by this client, not to copies
+// made by WithRequestOptions, which should use WithMFA instead.
+func (c *Client) SetMFACredentials(creds ...MFACredential) error {
+	vals := make([]string, 0, len(creds))
+	for _, cred := range creds {
+		if err := cred.validate(); err != nil {
+			return err
+		}
+		vals = append(vals, cred.String())
+	}
+	c.setPendingMFACreds(vals)
+	return nil
+}
+
+func (c *Client) setPendingMFACreds(vals []string) {
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+	c.pendingMFACreds = vals
+}
+
+// takePendingMFACreds returns and clears the credentials set by
+// SetMFACredentials.
+func (c *Client) takePendingMFACreds() []string {
+	c.modifyLock.RLock()
+	pending := c.pendingMFACreds
+	c.modifyLock.RUnlock()
+	if pending == nil {
+		return nil
+	}
+
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+	pending = c.pendingMFACreds
+	c.pendingMFACreds = nil
+	return pending
+}
+
 // SetNamespace sets the namespace supplied either via the environment
 // variable or via the command line.
 func (c *Client) SetNamespace(namespace string) {
@@ -1032,6 +1298,35 @@ func (c *Client) SetReadYourWrites(preventStaleReads bool) {
 	c.config.ReadYourWrites = preventStaleReads
 }
 
+// ReplicationStates returns the cluster replication states recorded by the
+// client when ReadYourWrites is enabled. They can be passed to another
+// client with AddReplicationStates, or required by individual requests with
+// the RequireState request callback, so that the other client reads its own
+// writes as well.
+func (c *Client) ReplicationStates() []string {
+	c.modifyLock.RLock()
+	defer c.modifyLock.RUnlock()
+
+	if c.replicationStateStore == nil {
+		return nil
+	}
+	return c.replicationStateStore.states()
+}
+
+// AddReplicationStates merges the given cluster replication states, e.g.
+// returned by ReplicationStates on another client, into the states required
+// by the requests of the client. It has no effect unless ReadYourWrites is
+// enabled.
+func (c *Client) AddReplicationStates(states ...string) {
+	c.modifyLock.RLock()
+	defer c.modifyLock.RUnlock()
+
+	if c.replicationStateStore == nil {
+		return
+	}
+	c.replicationStateStore.mergeStates(states...)
+}
+
 // ReadYourWrites gets the configured value of ReadYourWrites
 func (c *Client) ReadYourWrites() bool {
 	c.modifyLock.RLock()
@@ -1072,21 +1367,28 @@ func (c *Client) clone(cloneHeaders bool) (*Client, error) {
 	defer config.modifyLock.RUnlock()
 
 	newConfig := &Config{
-		Address:        config.Address,
-		HttpClient:     config.HttpClient,
-		MinRetryWait:   config.MinRetryWait,
-		MaxRetryWait:   config.MaxRetryWait,
-		MaxRetries:     config.MaxRetries,
-		Timeout:        config.Timeout,
-		Backoff:        config.Backoff,
-		CheckRetry:     config.CheckRetry,
-		Logger:         config.Logger,
-		Limiter:        config.Limiter,
-		AgentAddress:   config.AgentAddress,
-		SRVLookup:      config.SRVLookup,
-		CloneHeaders:   config.CloneHeaders,
-		CloneToken:     config.CloneToken,
-		ReadYourWrites: config.ReadYourWrites,
+		Address:         config.Address,
+		HttpClient:      config.HttpClient,
+		MinRetryWait:    config.MinRetryWait,
+		MaxRetryWait:    config.MaxRetryWait,
+		MaxRetries:      config.MaxRetries,
+		Timeout:         config.Timeout,
+		Backoff:         config.Backoff,
+		CheckRetry:      config.CheckRetry,
+		Logger:          config.Logger,
+		Limiter:         config.Limiter,
+		AgentAddress:    config.AgentAddress,
+		UnixSocketTLS:   config.UnixSocketTLS,
+		SRVLookup:       config.SRVLookup,
+		CloneHeaders:    config.CloneHeaders,
+		CloneToken:      config.CloneToken,
+		ReadYourWrites:  config.ReadYourWrites,
+		MaxStateRetries: config.MaxStateRetries,
+		ProxyURL:        config.ProxyURL,
+
+		unixSocketTransport: config.unixSocketTransport,
+		unixSocketDial:      config.unixSocketDial,
+		proxyTransports:     config.proxyTransports,
 	}
 	client, err := NewClient(newConfig)
 	if err != nil {
@@ -1117,7 +1419,8 @@ func (c *Client) SetPolicyOverride(override bool) {
 
 // NewRequest creates a new raw request object to query the Vault server
 // configured for this client. This is an advanced method and generally
-// doesn't need to be called externally.
+// doesn't need to be called externally. The path may contain segments
+// escaped with EscapePathSegment, e.g. when built with PathJoin.
 func (c *Client) NewRequest(method, requestPath string) *Request {
 	c.modifyLock.RLock()
 	addr := c.addr
@@ -1131,7 +1434,7 @@ func (c *Client) NewRequest(method, requestPath string) *Request {
 	// if SRV records exist (see https://tools.ietf.org/html/draft-andrews-http-srv-02), lookup the SRV
 	// record and take the highest match; this is not designed for high-availability, just discovery
 	// Internet Draft specifies that the SRV record is ignored if a port is given
-	if addr.Port() == "" && c.config.SRVLookup {
+	if addr.Port() == "" && addr.Host != unixSocketHost && c.config.SRVLookup {
 		_, addrs, err := net.LookupSRV("http", "tcp", addr.Hostname())
 		if err == nil && len(addrs) > 0 {
 			host = fmt.Sprintf("%s:%d", addrs[0].Target, addrs[0].Port)
@@ -1144,12 +1447,12 @@ func (c *Client) NewRequest(method, requestPath string) *Request {
 			User:   addr.User,
 			Scheme: addr.Scheme,
 			Host:   host,
-			Path:   path.Join(addr.Path, requestPath),
 		},
 		Host:        addr.Host,
 		ClientToken: token,
 		Params:      make(map[string][]string),
 	}
+	setRequestPath(req.URL, path.Join(addr.Path, requestPath))
 
 	var lookupPath string
 	switch {
@@ -1162,6 +1465,9 @@ func (c *Client) NewRequest(method, requestPath string) *Request {
 	}
 
 	req.MFAHeaderVals = mfaCreds
+	if pending := c.takePendingMFACreds(); len(pending) > 0 {
+		req.MFAHeaderVals = append(append([]string(nil), mfaCreds...), pending...)
+	}
 
 	if wrappingLookupFunc != nil {
 		req.WrapTTL = wrappingLookupFunc(method, lookupPath)
@@ -1201,6 +1507,23 @@ func (c *Client) RawRequestWithContext(ctx context.Context, r *Request) (*Respon
 }
 
 func (c *Client) rawRequestWithContext(ctx context.Context, r *Request) (*Response, error) {
+	resp, err := c.sendRequestWithContext(ctx, r)
+
+	c.modifyLock.RLock()
+	reauth := c.autoReauth
+	c.modifyLock.RUnlock()
+	if reauth == nil || err == nil {
+		return resp, err
+	}
+
+	return reauth.retry(ctx, c, r, resp, err)
+}
+
+func (c *Client) sendRequestWithContext(ctx context.Context, r *Request) (*Response, error) {
+	if c.optionsErr != nil {
+		return nil, c.optionsErr
+	}
+
 	c.modifyLock.RLock()
 	token := c.token
 
@@ -1209,6 +1532,7 @@ func (c *Client) rawRequestWithContext(ctx context.Context, r *Request) (*Respon
 	minRetryWait := c.config.MinRetryWait
 	maxRetryWait := c.config.MaxRetryWait
 	maxRetries := c.config.MaxRetries
+	maxStateRetries := c.config.MaxStateRetries
 	checkRetry := c.config.CheckRetry
 	backoff := c.config.Backoff
 	httpClient := c.config.HttpClient
@@ -1238,7 +1562,9 @@ func (c *Client) rawRequestWithContext(ctx context.Context, r *Request) (*Respon
 	}
 
 	if limiter != nil {
-		limiter.Wait(ctx)
+		if err := c.waitLimiter(ctx, limiter, r); err != nil {
+			return nil, err
+		}
 	}
 
 	// check the token before potentially erroring from the API
@@ -1246,6 +1572,25 @@ func (c *Client) rawRequestWithContext(ctx context.Context, r *Request) (*Respon
 		return nil, err
 	}
 
+	// The retry policies are wrapped once, so that a redirect doesn't wrap
+	// them again
+	if backoff == nil {
+		backoff = retryablehttp.LinearJitterBackoff
+	}
+
+	if checkRetry == nil {
+		checkRetry = DefaultRetryPolicy
+	}
+	checkRetry = c.rateLimitStats.countRateLimited(checkRetry)
+	backoff = retryAfterBackoff(backoff)
+
+	if maxStateRetries > 0 {
+		checkRetry = stateRetryPolicy(checkRetry, maxRetries, maxStateRetries)
+		if maxStateRetries > maxRetries {
+			maxRetries = maxStateRetries
+		}
+	}
+
 	redirectCount := 0
 START:
 	req, err := r.toRetryableHTTP()
@@ -1278,16 +1623,8 @@ START:
 
 	req.Request = req.Request.WithContext(ctx)
 
-	if backoff == nil {
-		backoff = retryablehttp.LinearJitterBackoff
-	}
-
-	if checkRetry == nil {
-		checkRetry = DefaultRetryPolicy
-	}
-
 	client := &retryablehttp.Client{
-		HTTPClient:   httpClient,
+		HTTPClient:   c.withHooks(httpClient, r),
 		RetryWaitMin: minRetryWait,
 		RetryWaitMax: maxRetryWait,
 		RetryMax:     maxRetries,
@@ -1356,6 +1693,10 @@ START:
 // or 307) will be followed but all retry and timeout logic is the responsibility of the caller as is
 // closing the Response body.
 func (c *Client) httpRequestWithContext(ctx context.Context, r *Request) (*Response, error) {
+	if c.optionsErr != nil {
+		return nil, c.optionsErr
+	}
+
 	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL.RequestURI(), r.Body)
 	if err != nil {
 		return nil, err
@@ -1418,7 +1759,9 @@ func (c *Client) httpRequestWithContext(ctx context.Context, r *Request) (*Respo
 	}
 
 	if limiter != nil {
-		limiter.Wait(ctx)
+		if err := c.waitLimiter(ctx, limiter, r); err != nil {
+			return nil, err
+		}
 	}
 
 	// check the token before potentially erroring from the API
@@ -1428,7 +1771,7 @@ func (c *Client) httpRequestWithContext(ctx context.Context, r *Request) (*Respo
 
 	var result *Response
 
-	resp, err := httpClient.Do(req)
+	resp, err := c.withHooks(httpClient, r).Do(req)
 
 	if resp != nil {
 		result = &Response{Response: resp}
@@ -1507,7 +1850,12 @@ func (c *Client) WithResponseCallbacks(callbacks ...ResponseCallback) *Client {
 
 // withConfiguredTimeout wraps the context with a timeout from the client configuration.
 func (c *Client) withConfiguredTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	timeout := c.ClientTimeout()
+	c.modifyLock.RLock()
+	timeout := c.requestTimeout
+	c.modifyLock.RUnlock()
+	if timeout == 0 {
+		timeout = c.ClientTimeout()
+	}
 
 	if timeout > 0 {
 		return context.WithTimeout(ctx, timeout)
@@ -1657,7 +2005,7 @@ func ForwardAlways() RequestCallback {
 // DefaultRetryPolicy is the default retry policy used by new Client objects.
 // It is the same as retryablehttp.DefaultRetryPolicy except that it also retries
 // 412 requests, which are returned by Vault when a X-Vault-Index header isn't
-// satisfied.
+// satisfied, and 429 requests with a Retry-After header.
 func DefaultRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
 	retry, err := retryablehttp.DefaultRetryPolicy(ctx, resp, err)
 	if err != nil || retry {
@@ -1666,9 +2014,35 @@ func DefaultRetryPolicy(ctx context.Context, resp *http.Response, err error) (bo
 	if resp != nil && resp.StatusCode == 412 {
 		return true, nil
 	}
+	// Rate limited requests are retried when the server says when to do so,
+	// except for health checks which use 429 for standby nodes
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("Retry-After") != "" &&
+		(resp.Request == nil || resp.Request.URL.Path != "/v1/sys/health") {
+		return true, nil
+	}
 	return false, nil
 }
 
+// stateRetryPolicy wraps the given retry policy of a single request so that
+// 412 responses are retried up to maxStateRetries times, and other
+// retryable responses up to maxRetries times.
+func stateRetryPolicy(checkRetry retryablehttp.CheckRetry, maxRetries, maxStateRetries int) retryablehttp.CheckRetry {
+	var retries, stateRetries int
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		retry, err := checkRetry(ctx, resp, err)
+		if !retry || err != nil {
+			return retry, err
+		}
+
+		if resp != nil && resp.StatusCode == http.StatusPreconditionFailed {
+			stateRetries++
+			return stateRetries <= maxStateRetries, nil
+		}
+		retries++
+		return retries <= maxRetries, nil
+	}
+}
+
 // replicationStateStore is used to track cluster replication states
 // in order to ensure proper read-after-write semantics for a Client.
 type replicationStateStore struct {
@@ -1687,6 +2061,17 @@ func (w *replicationStateStore) recordState(resp *Response) {
 	}
 }
 
+// mergeStates merges the given states into the store's replication states.
+func (w *replicationStateStore) mergeStates(states ...string) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	for _, state := range states {
+		if state != "" {
+			w.store = MergeReplicationStates(w.store, state)
+		}
+	}
+}
+
 // requireState updates the Request with the store's current replication states.
 func (w *replicationStateStore) requireState(req *Request) {
 	w.m.RLock()
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientCertError is returned by the TLS handshakes of a client whose
+// certificate and key files could not be reloaded after changing. The
+// handshakes of later connections retry the reload.
+type ClientCertError struct {
+	CertFile string
+	KeyFile  string
+	Err      error
+}
+
+func (e *ClientCertError) Error() string {
+	return fmt.Sprintf("error reloading client certificate %q and key %q: %v", e.CertFile, e.KeyFile, e.Err)
+}
+
+func (e *ClientCertError) Unwrap() error {
+	return e.Err
+}
+
+// clientCertReloader provides the client certificate of TLS handshakes,
+// re-reading the certificate and key files whenever they change.
+type clientCertReloader struct {
+	certFile string
+	keyFile  string
+
+	l       sync.Mutex
+	cert    *tls.Certificate
+	certMod fileVersion
+	keyMod  fileVersion
+}
+
+// fileVersion identifies the version of a file for change detection.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func newClientCertReloader(certFile, keyFile string) (*clientCertReloader, error) {
+	r := &clientCertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate. Like the
+// certificates loaded once, it ignores the server's list of acceptable CAs.
+func (r *clientCertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if err := r.reload(); err != nil {
+		return nil, &ClientCertError{
+			CertFile: r.certFile,
+			KeyFile:  r.keyFile,
+			Err:      err,
+		}
+	}
+	return r.cert, nil
+}
+
+// reload loads the certificate and key if either file changed since they
+// were last loaded.
+func (r *clientCertReloader) reload() error {
+	certMod, err := statFileVersion(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyMod, err := statFileVersion(r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && certMod == r.certMod && keyMod == r.keyMod {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	return nil
+}
+
+func statFileVersion(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
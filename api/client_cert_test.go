@@ -0,0 +1,167 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestClientCert writes a self-signed client certificate with the given
+// serial number and its key to the given files.
+func writeTestClientCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_ReloadClientCert(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		serial := req.TLS.PeerCertificates[0].SerialNumber.String()
+		w.Write([]byte(`{"data": {"serial": "` + serial + `"}}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault-api-client-cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	writeTestClientCert(t, certFile, keyFile, 1)
+
+	config := DefaultConfig()
+	config.Address = server.URL
+	config.MaxRetries = 0
+	err = config.ConfigureTLS(&TLSConfig{
+		ClientCert:       certFile,
+		ClientKey:        keyFile,
+		ReloadClientCert: true,
+		Insecure:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readSerial := func() (interface{}, error) {
+		// Force a new connection, and so a new handshake
+		config.HttpClient.CloseIdleConnections()
+		secret, err := client.Logical().Read("secret/foo")
+		if err != nil {
+			return nil, err
+		}
+		return secret.Data["serial"], nil
+	}
+
+	serial, err := readSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial != "1" {
+		t.Fatalf("expected serial 1, got %v", serial)
+	}
+
+	// Ensure the modification time changes on coarse filesystems
+	time.Sleep(10 * time.Millisecond)
+	writeTestClientCert(t, certFile, keyFile, 2)
+	serial, err = readSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial != "2" {
+		t.Fatalf("expected rotated serial 2, got %v", serial)
+	}
+
+	if err := ioutil.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = readSerial()
+	var certErr *ClientCertError
+	if !errors.As(err, &certErr) || certErr.KeyFile != keyFile {
+		t.Fatalf("expected client cert error, got %v", err)
+	}
+
+	writeTestClientCert(t, certFile, keyFile, 3)
+	serial, err = readSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial != "3" {
+		t.Fatalf("expected serial 3 after recovering, got %v", serial)
+	}
+}
+
+func TestConfig_GetClientCertificate(t *testing.T) {
+	config := DefaultConfig()
+	err := config.ConfigureTLS(&TLSConfig{
+		ClientCert: "client.crt",
+		ClientKey:  "client.key",
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &tls.Certificate{}, nil
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error combining files and a callback")
+	}
+
+	called := false
+	err = config.ConfigureTLS(&TLSConfig{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			called = true
+			return &tls.Certificate{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig := config.HttpClient.Transport.(*http.Transport).TLSClientConfig
+	if _, err := tlsConfig.GetClientCertificate(nil); err != nil || !called {
+		t.Fatalf("expected callback to be configured, err %v", err)
+	}
+}
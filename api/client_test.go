@@ -5,11 +5,16 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -1284,3 +1289,531 @@ func TestVaultProxy(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_ContextCancellation(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	// The handler sends a partial response body and then hangs, so the
+	// request can only complete if the body read honors cancellation.
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"data": {"userpass/": {`))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-done:
+		case <-req.Context().Done():
+		}
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func(ctx context.Context) error{
+		"ListAuth": func(ctx context.Context) error {
+			_, err := client.Sys().ListAuthWithContext(ctx)
+			return err
+		},
+		"ListMounts": func(ctx context.Context) error {
+			_, err := client.Sys().ListMountsWithContext(ctx)
+			return err
+		},
+		"Read": func(ctx context.Context) error {
+			_, err := client.Logical().ReadWithContext(ctx, "secret/foo")
+			return err
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- tc(ctx)
+			}()
+
+			select {
+			case err := <-errCh:
+				if err == nil {
+					t.Fatal("expected error after cancellation")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("request was not aborted after cancellation")
+			}
+		})
+	}
+}
+
+func TestClient_ContextCancellationLimiter(t *testing.T) {
+	var requests int
+	var l sync.Mutex
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		l.Lock()
+		requests++
+		l.Unlock()
+		w.Write([]byte(`{"data": {}}`))
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Allow a single request every minute
+	client.SetLimiter(1.0/60, 1)
+	if _, err := client.Sys().ListAuthWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.Sys().ListAuthWithContext(ctx); err == nil {
+		t.Fatal("expected error when the context expires while rate limited")
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if requests != 1 {
+		t.Fatalf("expected 1 request to be sent, got %d", requests)
+	}
+}
+
+func TestClient_WithRequestOptions(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/secret/slow" {
+			time.Sleep(time.Second)
+		}
+		fmt.Fprintf(w, `{"data": {"namespace": %q, "token": %q, "extra": %q}}`,
+			req.Header.Get(consts.NamespaceHeaderName), req.Header.Get(consts.AuthHeaderName), req.Header.Get("X-Extra"))
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("shared-token")
+	client.SetNamespace("shared")
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ns := fmt.Sprintf("ns%d", i)
+			token := fmt.Sprintf("token%d", i)
+			secret, err := client.Logical().ReadWithOptions(context.Background(), "secret/foo",
+				WithNamespace(ns), WithToken(token), WithHeader("X-Extra", ns))
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if secret.Data["namespace"] != ns || secret.Data["token"] != token || secret.Data["extra"] != ns {
+				errCh <- fmt.Errorf("unexpected request headers %#v", secret.Data)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+
+	// The shared client must be left untouched
+	if client.Namespace() != "shared" || client.Token() != "shared-token" || client.Headers().Get("X-Extra") != "" {
+		t.Fatalf("shared client was modified: %q %q %v", client.Namespace(), client.Token(), client.Headers())
+	}
+
+	secret, err := client.Logical().ReadWithOptions(context.Background(), "secret/foo", WithNamespace(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["namespace"] != "" || secret.Data["token"] != "shared-token" {
+		t.Fatalf("unexpected request headers %#v", secret.Data)
+	}
+
+	_, err = client.Logical().ReadWithOptions(context.Background(), "secret/slow", WithRequestTimeout(100*time.Millisecond))
+	if err == nil {
+		t.Fatal("expected request timeout error")
+	}
+}
+
+func TestClient_MFACredentials(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"mfa": req.Header["X-Vault-Mfa"]},
+		})
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readMFA := func(c *Client, opts ...RequestOption) interface{} {
+		t.Helper()
+		secret, err := c.Logical().ReadWithOptions(context.Background(), "secret/foo", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret.Data["mfa"]
+	}
+
+	err = client.SetMFACredentials(
+		MFACredential{Method: "my_totp", Passcode: "695452"},
+		MFACredential{Method: "my_duo"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(readMFA(client), []interface{}{"my_totp:695452", "my_duo"}); diff != nil {
+		t.Fatal(diff)
+	}
+	// The credentials must not be replayed
+	if mfa := readMFA(client); mfa != nil {
+		t.Fatalf("expected no MFA header, got %v", mfa)
+	}
+
+	// Passcodes may contain the separator
+	scoped := client.WithRequestOptions(WithMFA("my_totp", "12:34"), WithMFA("my_duo", ""))
+	if diff := deep.Equal(readMFA(scoped), []interface{}{"my_totp:12:34", "my_duo"}); diff != nil {
+		t.Fatal(diff)
+	}
+	if mfa := readMFA(scoped); mfa != nil {
+		t.Fatalf("expected no MFA header, got %v", mfa)
+	}
+	if mfa := readMFA(client); mfa != nil {
+		t.Fatalf("expected no MFA header on the original client, got %v", mfa)
+	}
+
+	// Persistent credentials are sent alongside the one-shot ones
+	client.SetMFACreds([]string{"my_pingid"})
+	if diff := deep.Equal(readMFA(client, WithMFA("my_totp", "1")), []interface{}{"my_pingid", "my_totp:1"}); diff != nil {
+		t.Fatal(diff)
+	}
+	client.SetMFACreds(nil)
+
+	if err := client.SetMFACredentials(MFACredential{Method: "bad:method"}); err == nil {
+		t.Fatal("expected error for invalid method")
+	}
+	if err := client.SetMFACredentials(MFACredential{Passcode: "123"}); err == nil {
+		t.Fatal("expected error for missing method")
+	}
+	if _, err := client.Logical().ReadWithOptions(context.Background(), "secret/foo", WithMFA("", "123")); err == nil {
+		t.Fatal("expected request with invalid MFA option to fail")
+	}
+}
+
+func TestClient_UnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-api-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "vault.sock")
+
+	// Requests through the socket are redirected to a TCP server, which must
+	// be dialed over the network
+	redirectConfig, redirectLn := testHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"data": {"via": "tcp"}}`))
+	}))
+	defer redirectLn.Close()
+
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/secret/redirect" {
+			http.Redirect(w, req, redirectConfig.Address+req.URL.Path, http.StatusTemporaryRedirect)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"via": "unix", "host": %q}}`, req.Host)
+	}))
+
+	oldAddr := os.Getenv(EnvVaultAddress)
+	defer os.Setenv(EnvVaultAddress, oldAddr)
+	os.Setenv(EnvVaultAddress, "unix://"+socket)
+
+	config := DefaultConfig()
+	if config.Error != nil {
+		t.Fatal(config.Error)
+	}
+	config.SRVLookup = true
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Address() != "unix://"+socket {
+		t.Fatalf("unexpected address %q", client.Address())
+	}
+
+	secret, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["via"] != "unix" || secret.Data["host"] != "unix" {
+		t.Fatalf("unexpected response %#v", secret.Data)
+	}
+
+	secret, err = client.Logical().Read("secret/redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["via"] != "tcp" {
+		t.Fatalf("unexpected response %#v", secret.Data)
+	}
+
+	// Switching back to a network address must stop using the socket
+	if err := client.SetAddress(redirectConfig.Address); err != nil {
+		t.Fatal(err)
+	}
+	secret, err = client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["via"] != "tcp" {
+		t.Fatalf("unexpected response %#v", secret.Data)
+	}
+
+	// Switching between sockets must dial the current one, with a transport
+	// of the client's own
+	otherSocket := filepath.Join(dir, "other.sock")
+	otherLn, err := net.Listen("unix", otherSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherLn.Close()
+	go http.Serve(otherLn, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"data": {"via": "other"}}`))
+	}))
+
+	for _, s := range []struct {
+		socket string
+		via    string
+	}{
+		{otherSocket, "other"},
+		{socket, "unix"},
+		{otherSocket, "other"},
+	} {
+		if err := client.SetAddress("unix://" + s.socket); err != nil {
+			t.Fatal(err)
+		}
+		secret, err = client.Logical().Read("secret/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret.Data["via"] != s.via {
+			t.Fatalf("unexpected response %#v", secret.Data)
+		}
+		if client.config.HttpClient.Transport != client.config.unixSocketTransport {
+			t.Fatal("expected the client to use the transport dialing the socket")
+		}
+	}
+}
+
+func TestClient_CloneWithOptions(t *testing.T) {
+	var l sync.Mutex
+	var conns int
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, `{"data": {"namespace": %q, "token": %q}}`,
+				req.Header.Get(consts.NamespaceHeaderName), req.Header.Get(consts.AuthHeaderName))
+		}),
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				l.Lock()
+				conns++
+				l.Unlock()
+			}
+		},
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go server.Serve(ln)
+
+	config := DefaultConfig()
+	config.Address = "http://" + ln.Addr().String()
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("original-token")
+	client.SetNamespace("original")
+	client.SetCloneToken(false)
+	client.SetCloneHeaders(false)
+
+	clone, err := client.CloneWithOptions(WithToken("clone-token"), WithNamespace("clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Everything is copied, regardless of the clone settings
+	plain, err := client.CloneWithOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Token() != "original-token" || plain.Namespace() != "original" {
+		t.Fatalf("unexpected clone settings: %q %q", plain.Token(), plain.Namespace())
+	}
+
+	// Modifying the clone must not affect the original
+	clone.SetMaxRetries(7)
+	clone.AddHeader("X-Extra", "clone")
+	if client.MaxRetries() == 7 || client.Headers().Get("X-Extra") != "" {
+		t.Fatal("original client was modified through its clone")
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			secret, err := client.Logical().Read("secret/foo")
+			if err == nil && (secret.Data["token"] != "original-token" || secret.Data["namespace"] != "original") {
+				err = fmt.Errorf("unexpected original request headers %#v", secret.Data)
+			}
+			errCh <- err
+		}()
+		go func() {
+			defer wg.Done()
+			secret, err := clone.Logical().Read("secret/foo")
+			if err == nil && (secret.Data["token"] != "clone-token" || secret.Data["namespace"] != "clone") {
+				err = fmt.Errorf("unexpected clone request headers %#v", secret.Data)
+			}
+			errCh <- err
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Sequential requests through both clients must reuse the idle
+	// connections of the shared transport
+	l.Lock()
+	before := conns
+	l.Unlock()
+	for i := 0; i < 5; i++ {
+		if _, err := client.Logical().Read("secret/foo"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := clone.Logical().Read("secret/foo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Lock()
+	defer l.Unlock()
+	if conns != before {
+		t.Fatalf("expected connections to be reused, %d new connections were opened", conns-before)
+	}
+}
+
+func TestClient_ReadYourWritesStateRetries(t *testing.T) {
+	var l sync.Mutex
+	var requests int
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		l.Lock()
+		requests++
+		n := requests
+		l.Unlock()
+
+		switch req.Method {
+		case http.MethodPut:
+			w.Header().Set(HeaderIndex, "v1:cid:1:0:")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			// The node catches up with the written state after a few
+			// attempts
+			if req.Header.Get(HeaderIndex) != "v1:cid:1:0:" || n < 5 {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`{"errors": ["required index state not present"]}`))
+				return
+			}
+			w.Write([]byte(`{"data": {"foo": "bar"}}`))
+		}
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+	config.ReadYourWrites = true
+	config.MinRetryWait = time.Millisecond
+	config.MaxRetryWait = time.Millisecond
+	config.MaxStateRetries = 5
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	states := client.ReplicationStates()
+	if len(states) != 1 || states[0] != "v1:cid:1:0:" {
+		t.Fatalf("unexpected recorded states %v", states)
+	}
+
+	// Three 412 responses exceed MaxRetries but not MaxStateRetries
+	secret, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["foo"] != "bar" {
+		t.Fatalf("unexpected response %#v", secret.Data)
+	}
+
+	// States can be handed off to another client
+	other, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetReadYourWrites(true)
+	other.SetMaxStateRetries(1)
+	if len(other.ReplicationStates()) != 1 {
+		t.Fatal("expected the clone to share the recorded states")
+	}
+	fresh, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.AddReplicationStates(states...)
+	if diff := deep.Equal(fresh.ReplicationStates(), states); diff != nil {
+		t.Fatal(diff)
+	}
+
+	l.Lock()
+	requests = 0
+	l.Unlock()
+	fresh.SetMaxStateRetries(1)
+	_, err = fresh.Logical().Read("secret/foo")
+	if !errors.Is(err, ErrMissingRequiredState) {
+		t.Fatalf("expected missing state error once state retries are exhausted, got %v", err)
+	}
+}
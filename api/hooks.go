@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/consts"
+)
+
+// RedactedValue replaces the values of redacted headers and JSON fields in
+// the requests and responses passed to hooks.
+const RedactedValue = "redacted"
+
+// DefaultRedactedFields are the JSON fields redacted from the request and
+// response bodies passed to hooks unless SetRedactedFields is called. They
+// include the tokens, codes and assertions of the OIDC provider endpoints.
+var DefaultRedactedFields = []string{
+	"password",
+	"client_secret",
+	"secret_id",
+	"client_token",
+	"token",
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"device_code",
+	"code",
+	"client_assertion",
+}
+
+// redactedHeaders are the headers redacted from the requests passed to
+// hooks.
+var redactedHeaders = []string{
+	consts.AuthHeaderName,
+	"X-Vault-MFA",
+	"Authorization",
+}
+
+type (
+	// RequestHook is invoked before every attempt of a request, including
+	// retries. The request is a redacted copy; modifying it has no effect.
+	RequestHook func(*Request)
+
+	// ResponseHook is invoked after every attempt of a request, including
+	// retries, with the redacted request, the response if one was received,
+	// the error of the attempt if any, and the duration of the attempt. The
+	// response is a redacted copy whose body may be read freely.
+	ResponseHook func(*Request, *Response, error, time.Duration)
+)
+
+// AddRequestHook registers a hook that will be invoked before every attempt
+// of every request made by the client. Hooks are invoked concurrently for
+// concurrent requests; any locking they need is their responsibility.
+func (c *Client) AddRequestHook(hook RequestHook) {
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+	c.requestHooks = append(c.requestHooks, hook)
+}
+
+// AddResponseHook registers a hook that will be invoked after every attempt
+// of every request made by the client. Hooks are invoked concurrently for
+// concurrent requests; any locking they need is their responsibility.
+func (c *Client) AddResponseHook(hook ResponseHook) {
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+	c.responseHooks = append(c.responseHooks, hook)
+}
+
+// SetRedactedFields sets the JSON fields whose values are redacted from the
+// request and response bodies passed to hooks, at any depth. Passing no
+// fields disables body redaction.
+func (c *Client) SetRedactedFields(fields ...string) {
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+	c.redactedFields = append([]string{}, fields...)
+}
+
+// RedactedFields returns the JSON fields redacted from the bodies passed to
+// hooks.
+func (c *Client) RedactedFields() []string {
+	c.modifyLock.RLock()
+	defer c.modifyLock.RUnlock()
+	if c.redactedFields == nil {
+		return DefaultRedactedFields
+	}
+	return c.redactedFields
+}
+
+// withHooks returns a copy of the given HTTP client invoking the hooks
+// registered on the client for every attempt of the given request, or the
+// HTTP client itself if no hooks are registered.
+func (c *Client) withHooks(httpClient *http.Client, r *Request) *http.Client {
+	c.modifyLock.RLock()
+	requestHooks := c.requestHooks
+	responseHooks := c.responseHooks
+	c.modifyLock.RUnlock()
+
+	if len(requestHooks) == 0 && len(responseHooks) == 0 {
+		return httpClient
+	}
+
+	fields := make(map[string]struct{})
+	for _, field := range c.RedactedFields() {
+		fields[field] = struct{}{}
+	}
+
+	hc := *httpClient
+	hc.Transport = &hookTransport{
+		base:           httpClient.Transport,
+		request:        r,
+		requestHooks:   requestHooks,
+		responseHooks:  responseHooks,
+		redactedFields: fields,
+	}
+	return &hc
+}
+
+// hookTransport is a round tripper invoking hooks around each attempt of a
+// single request.
+type hookTransport struct {
+	base           http.RoundTripper
+	request        *Request
+	requestHooks   []RequestHook
+	responseHooks  []ResponseHook
+	redactedFields map[string]struct{}
+
+	// attempt is only modified by RoundTrip, which is never called
+	// concurrently for the attempts of a single request
+	attempt int
+}
+
+func (t *hookTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	t.attempt++
+	hookReq := t.redactRequest(req)
+	for _, hook := range t.requestHooks {
+		hook(hookReq)
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	duration := time.Since(start)
+
+	if len(t.responseHooks) == 0 {
+		return resp, err
+	}
+
+	var hookResp *Response
+	if resp != nil {
+		var redactErr error
+		hookResp, redactErr = t.redactResponse(resp, req)
+		if redactErr != nil {
+			resp.Body.Close()
+			return nil, redactErr
+		}
+	}
+	for _, hook := range t.responseHooks {
+		hook(hookReq, hookResp, err, duration)
+	}
+
+	return resp, err
+}
+
+// redactRequest returns a copy of the request with its credentials and
+// redacted fields replaced.
+func (t *hookTransport) redactRequest(req *http.Request) *Request {
+	u := *req.URL
+	u.User = nil
+
+	hookReq := &Request{
+		Method:         req.Method,
+		URL:            &u,
+		Host:           req.Host,
+		Params:         req.URL.Query(),
+		Headers:        redactHeaders(req.Header),
+		WrapTTL:        t.request.WrapTTL,
+		PolicyOverride: t.request.PolicyOverride,
+		Attempt:        t.attempt,
+		LimiterWait:    t.request.LimiterWait,
+	}
+	if t.request.ClientToken != "" {
+		hookReq.ClientToken = RedactedValue
+	}
+	for range t.request.MFAHeaderVals {
+		hookReq.MFAHeaderVals = append(hookReq.MFAHeaderVals, RedactedValue)
+	}
+
+	// Streamed bodies are not passed to hooks since they can only be read
+	// once
+	if t.request.BodyBytes != nil {
+		hookReq.BodyBytes = t.redactJSON(t.request.BodyBytes)
+	}
+
+	return hookReq
+}
+
+// redactResponse returns a copy of the response whose JSON body has its
+// redacted fields replaced. The body of the original response is buffered
+// so that it can still be read by the caller; bodies of other content types
+// are not passed to hooks since they may be streamed.
+func (t *hookTransport) redactResponse(resp *http.Response, req *http.Request) (*Response, error) {
+	hookResp := *resp
+	hookResp.Body = ioutil.NopCloser(bytes.NewReader(nil))
+
+	hookReq := req.Clone(req.Context())
+	hookReq.Header = redactHeaders(req.Header)
+	hookResp.Request = hookReq
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return &Response{Response: &hookResp}, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	hookResp.Body = ioutil.NopCloser(bytes.NewReader(t.redactJSON(body)))
+	return &Response{Response: &hookResp}, nil
+}
+
+// redactJSON returns a copy of the given JSON document with the values of
+// the redacted fields replaced at any depth, or nil if it is not valid JSON.
+func (t *hookTransport) redactJSON(body []byte) []byte {
+	if len(t.redactedFields) == 0 {
+		return append([]byte(nil), body...)
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+
+	redacted, err := json.Marshal(t.redactValue(v))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+func (t *hookTransport) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if _, ok := t.redactedFields[k]; ok && val != nil {
+				v[k] = RedactedValue
+				continue
+			}
+			v[k] = t.redactValue(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = t.redactValue(val)
+		}
+	}
+	return v
+}
+
+// redactHeaders returns a copy of the given headers with the values of the
+// credential headers replaced.
+func redactHeaders(headers http.Header) http.Header {
+	ret := headers.Clone()
+	if ret == nil {
+		ret = make(http.Header)
+	}
+	for _, header := range redactedHeaders {
+		vals := ret[http.CanonicalHeaderKey(header)]
+		for i := range vals {
+			vals[i] = RedactedValue
+		}
+	}
+	return ret
+}
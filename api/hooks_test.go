@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_Hooks(t *testing.T) {
+	var l sync.Mutex
+	var requests int
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		l.Lock()
+		requests++
+		first := requests == 1
+		l.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if first {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errors": ["transient"]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"client_id": "test", "client_secret": "test-secret"}}`))
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+	config.MinRetryWait = time.Millisecond
+	config.MaxRetryWait = time.Millisecond
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("test-token")
+
+	var attempts []int
+	client.AddRequestHook(func(r *Request) {
+		if r.ClientToken != RedactedValue || r.Headers.Get("X-Vault-Token") != RedactedValue {
+			t.Errorf("token was not redacted from request: %q %v", r.ClientToken, r.Headers)
+		}
+		if strings.Contains(string(r.BodyBytes), "hunter2") {
+			t.Errorf("password was not redacted from request body: %s", r.BodyBytes)
+		}
+		attempts = append(attempts, r.Attempt)
+	})
+
+	var statuses []int
+	var bodies []map[string]interface{}
+	client.AddResponseHook(func(r *Request, resp *Response, err error, d time.Duration) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if resp.Request.Header.Get("X-Vault-Token") != RedactedValue {
+			t.Errorf("token was not redacted from response request: %v", resp.Request.Header)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		statuses = append(statuses, resp.StatusCode)
+		bodies = append(bodies, body)
+	})
+
+	secret, err := client.Logical().Write("identity/oidc/client/test", map[string]interface{}{
+		"password": "hunter2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller still gets the unredacted response
+	if secret.Data["client_secret"] != "test-secret" {
+		t.Fatalf("unexpected response data: %#v", secret.Data)
+	}
+
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Fatalf("expected hooks for the retry to be flagged, got attempts %v", attempts)
+	}
+	if len(statuses) != 2 || statuses[0] != http.StatusInternalServerError || statuses[1] != http.StatusOK {
+		t.Fatalf("unexpected statuses %v", statuses)
+	}
+	data := bodies[1]["data"].(map[string]interface{})
+	if data["client_secret"] != RedactedValue || data["client_id"] != "test" {
+		t.Fatalf("unexpected redacted response body: %#v", data)
+	}
+}
+
+func TestHookTransport_redactJSON(t *testing.T) {
+	transport := &hookTransport{redactedFields: map[string]struct{}{"password": {}}}
+	body := transport.redactJSON([]byte(`{"users": [{"username": "a", "password": "b", "ttl": 10}]}`))
+	if string(body) != `{"users":[{"password":"redacted","ttl":10,"username":"a"}]}` {
+		t.Fatalf("unexpected redacted body %s", body)
+	}
+
+	// The OIDC provider fields are redacted by default
+	transport = &hookTransport{redactedFields: map[string]struct{}{}}
+	for _, field := range (&Client{}).RedactedFields() {
+		transport.redactedFields[field] = struct{}{}
+	}
+	body = transport.redactJSON([]byte(`{"access_token": "a", "refresh_token": "b", "id_token": "c", "device_code": "d", "code": "e", "client_assertion": "f", "token_type": "Bearer"}`))
+	expected := `{"access_token":"redacted","client_assertion":"redacted","code":"redacted","device_code":"redacted","id_token":"redacted","refresh_token":"redacted","token_type":"Bearer"}`
+	if string(body) != expected {
+		t.Fatalf("unexpected redacted body %s", body)
+	}
+
+	if body := transport.redactJSON([]byte("not json")); body != nil {
+		t.Fatalf("expected no body for invalid JSON, got %s", body)
+	}
+
+	resp, err := (&hookTransport{}).redactResponse(&http.Response{
+		Header:  http.Header{"Content-Type": []string{"application/octet-stream"}},
+		Body:    ioutil.NopCloser(strings.NewReader("snapshot")),
+		Request: &http.Request{Header: http.Header{"X-Vault-Token": []string{"secret"}}},
+	}, &http.Request{Header: http.Header{"X-Vault-Token": []string{"secret"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); len(b) != 0 {
+		t.Fatalf("expected streamed body not to be passed to hooks, got %q", b)
+	}
+}
@@ -0,0 +1,11 @@
+package api
+
+// Identity is used to perform identity-related operations on Vault.
+type Identity struct {
+	c *Client
+}
+
+// Identity is used to return the client for identity-related API calls.
+func (c *Client) Identity() *Identity {
+	return &Identity{c: c}
+}
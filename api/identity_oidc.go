@@ -0,0 +1,464 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// IdentityOIDC is used to manage the resources of the identity OIDC provider.
+type IdentityOIDC struct {
+	c *Client
+}
+
+// OIDC is used to return the client for identity OIDC provider API calls.
+func (c *Identity) OIDC() *IdentityOIDC {
+	return &IdentityOIDC{c: c.c}
+}
+
+// OIDCKey is a named key used to sign ID tokens. Durations are in seconds.
+//
+// The writable fields of the OIDC resources whose zero value can be written
+// are pointers, and are only sent when set. This allows updating some of the
+// fields of a resource, as well as clearing lists and setting numbers to 0.
+type OIDCKey struct {
+	RotationPeriod   *int      `json:"rotation_period,omitempty" mapstructure:"rotation_period"`
+	VerificationTTL  *int      `json:"verification_ttl,omitempty" mapstructure:"verification_ttl"`
+	Algorithm        string    `json:"algorithm,omitempty" mapstructure:"algorithm"`
+	AllowedClientIDs *[]string `json:"allowed_client_ids,omitempty" mapstructure:"allowed_client_ids"`
+}
+
+// OIDCScope is a named scope whose template is used to populate claims.
+type OIDCScope struct {
+	Template    string `json:"template,omitempty" mapstructure:"template"`
+	Description string `json:"description,omitempty" mapstructure:"description"`
+}
+
+// OIDCAssignment is a named set of entities and groups that are allowed to
+// authenticate with a client.
+type OIDCAssignment struct {
+	EntityIDs *[]string `json:"entity_ids,omitempty" mapstructure:"entity_ids"`
+	GroupIDs  *[]string `json:"group_ids,omitempty" mapstructure:"group_ids"`
+}
+
+// OIDCClient is a named relying party of the OIDC provider. Durations are in
+// seconds. ClientID and ClientSecret are generated by Vault and are only
+// populated when reading a client.
+type OIDCClient struct {
+	RedirectURIs   *[]string `json:"redirect_uris,omitempty" mapstructure:"redirect_uris"`
+	Assignments    *[]string `json:"assignments,omitempty" mapstructure:"assignments"`
+	Key            string    `json:"key,omitempty" mapstructure:"key"`
+	IDTokenTTL     *int      `json:"id_token_ttl,omitempty" mapstructure:"id_token_ttl"`
+	AccessTokenTTL *int      `json:"access_token_ttl,omitempty" mapstructure:"access_token_ttl"`
+	ClientType     string    `json:"client_type,omitempty" mapstructure:"client_type"`
+
+	ClientID     string `json:"-" mapstructure:"client_id"`
+	ClientSecret string `json:"-" mapstructure:"client_secret"`
+}
+
+// OIDCClientListOptions are the filters and paging options of a detailed
+// listing of clients. Empty options are not sent.
+type OIDCClientListOptions struct {
+	// Key only lists the clients using the named key.
+	Key string
+
+	// Assignment only lists the clients referencing the named assignment.
+	Assignment string
+
+	// After is the client name after which to start listing.
+	After string
+
+	// Limit is the maximum number of clients to return. All matching
+	// clients are returned if it is zero.
+	Limit int
+}
+
+// OIDCClientList is a detailed listing of clients. The clients in KeyInfo
+// never have their ClientSecret populated.
+type OIDCClientList struct {
+	Keys    []string               `mapstructure:"keys"`
+	KeyInfo map[string]*OIDCClient `mapstructure:"key_info"`
+}
+
+// OIDCProvider is a named OIDC provider. When reading a provider, Issuer is
+// the effective issuer used in the iss claim of ID tokens.
+type OIDCProvider struct {
+	Issuer           *string   `json:"issuer,omitempty" mapstructure:"issuer"`
+	AllowedClientIDs *[]string `json:"allowed_client_ids,omitempty" mapstructure:"allowed_client_ids"`
+	ScopesSupported  *[]string `json:"scopes_supported,omitempty" mapstructure:"scopes_supported"`
+	DefaultScopes    *[]string `json:"default_scopes,omitempty" mapstructure:"default_scopes"`
+}
+
+func (c *IdentityOIDC) ReadKey(name string) (*OIDCKey, error) {
+	return c.ReadKeyWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) ReadKeyWithContext(ctx context.Context, name string) (*OIDCKey, error) {
+	var result *OIDCKey
+	if err := c.read(ctx, "key", name, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *IdentityOIDC) WriteKey(name string, key *OIDCKey) error {
+	return c.WriteKeyWithContext(context.Background(), name, key)
+}
+
+func (c *IdentityOIDC) WriteKeyWithContext(ctx context.Context, name string, key *OIDCKey) error {
+	return c.write(ctx, fmt.Sprintf("/v1/identity/oidc/key/%s", EscapePathSegment(name)), key)
+}
+
+func (c *IdentityOIDC) DeleteKey(name string) error {
+	return c.DeleteKeyWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) DeleteKeyWithContext(ctx context.Context, name string) error {
+	return c.delete(ctx, "key", name)
+}
+
+func (c *IdentityOIDC) ListKeys() ([]string, error) {
+	return c.ListKeysWithContext(context.Background())
+}
+
+func (c *IdentityOIDC) ListKeysWithContext(ctx context.Context) ([]string, error) {
+	return c.list(ctx, "key")
+}
+
+// RotateKey rotates the named key. If verificationTTL is non-zero, it
+// overrides the verification TTL of the key for the rotated key.
+func (c *IdentityOIDC) RotateKey(name string, verificationTTL int) error {
+	return c.RotateKeyWithContext(context.Background(), name, verificationTTL)
+}
+
+func (c *IdentityOIDC) RotateKeyWithContext(ctx context.Context, name string, verificationTTL int) error {
+	body := map[string]interface{}{}
+	if verificationTTL != 0 {
+		body["verification_ttl"] = verificationTTL
+	}
+	return c.write(ctx, fmt.Sprintf("/v1/identity/oidc/key/%s/rotate", EscapePathSegment(name)), body)
+}
+
+func (c *IdentityOIDC) ReadScope(name string) (*OIDCScope, error) {
+	return c.ReadScopeWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) ReadScopeWithContext(ctx context.Context, name string) (*OIDCScope, error) {
+	var result *OIDCScope
+	if err := c.read(ctx, "scope", name, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *IdentityOIDC) WriteScope(name string, scope *OIDCScope) error {
+	return c.WriteScopeWithContext(context.Background(), name, scope)
+}
+
+func (c *IdentityOIDC) WriteScopeWithContext(ctx context.Context, name string, scope *OIDCScope) error {
+	return c.write(ctx, fmt.Sprintf("/v1/identity/oidc/scope/%s", EscapePathSegment(name)), scope)
+}
+
+func (c *IdentityOIDC) DeleteScope(name string) error {
+	return c.DeleteScopeWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) DeleteScopeWithContext(ctx context.Context, name string) error {
+	return c.delete(ctx, "scope", name)
+}
+
+func (c *IdentityOIDC) ListScopes() ([]string, error) {
+	return c.ListScopesWithContext(context.Background())
+}
+
+func (c *IdentityOIDC) ListScopesWithContext(ctx context.Context) ([]string, error) {
+	return c.list(ctx, "scope")
+}
+
+func (c *IdentityOIDC) ReadAssignment(name string) (*OIDCAssignment, error) {
+	return c.ReadAssignmentWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) ReadAssignmentWithContext(ctx context.Context, name string) (*OIDCAssignment, error) {
+	var result *OIDCAssignment
+	if err := c.read(ctx, "assignment", name, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *IdentityOIDC) WriteAssignment(name string, assignment *OIDCAssignment) error {
+	return c.WriteAssignmentWithContext(context.Background(), name, assignment)
+}
+
+func (c *IdentityOIDC) WriteAssignmentWithContext(ctx context.Context, name string, assignment *OIDCAssignment) error {
+	return c.write(ctx, fmt.Sprintf("/v1/identity/oidc/assignment/%s", EscapePathSegment(name)), assignment)
+}
+
+func (c *IdentityOIDC) DeleteAssignment(name string) error {
+	return c.DeleteAssignmentWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) DeleteAssignmentWithContext(ctx context.Context, name string) error {
+	return c.delete(ctx, "assignment", name)
+}
+
+func (c *IdentityOIDC) ListAssignments() ([]string, error) {
+	return c.ListAssignmentsWithContext(context.Background())
+}
+
+func (c *IdentityOIDC) ListAssignmentsWithContext(ctx context.Context) ([]string, error) {
+	return c.list(ctx, "assignment")
+}
+
+func (c *IdentityOIDC) ReadClient(name string) (*OIDCClient, error) {
+	return c.ReadClientWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) ReadClientWithContext(ctx context.Context, name string) (*OIDCClient, error) {
+	var result *OIDCClient
+	if err := c.read(ctx, "client", name, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *IdentityOIDC) WriteClient(name string, client *OIDCClient) error {
+	return c.WriteClientWithContext(context.Background(), name, client)
+}
+
+func (c *IdentityOIDC) WriteClientWithContext(ctx context.Context, name string, client *OIDCClient) error {
+	return c.write(ctx, fmt.Sprintf("/v1/identity/oidc/client/%s", EscapePathSegment(name)), client)
+}
+
+func (c *IdentityOIDC) DeleteClient(name string) error {
+	return c.DeleteClientWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) DeleteClientWithContext(ctx context.Context, name string) error {
+	return c.delete(ctx, "client", name)
+}
+
+func (c *IdentityOIDC) ListClients() ([]string, error) {
+	return c.ListClientsWithContext(context.Background())
+}
+
+func (c *IdentityOIDC) ListClientsWithContext(ctx context.Context) ([]string, error) {
+	return c.list(ctx, "client")
+}
+
+// ListClientsDetailed lists the clients matching the given options along with
+// their configuration.
+func (c *IdentityOIDC) ListClientsDetailed(options *OIDCClientListOptions) (*OIDCClientList, error) {
+	return c.ListClientsDetailedWithContext(context.Background(), options)
+}
+
+func (c *IdentityOIDC) ListClientsDetailedWithContext(ctx context.Context, options *OIDCClientListOptions) (*OIDCClientList, error) {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest("LIST", "/v1/identity/oidc/client")
+	r.Method = http.MethodGet
+	r.Params.Set("list", "true")
+	r.Params.Set("detailed", "true")
+	if options != nil {
+		if options.Key != "" {
+			r.Params.Set("key", options.Key)
+		}
+		if options.Assignment != "" {
+			r.Params.Set("assignment", options.Assignment)
+		}
+		if options.After != "" {
+			r.Params.Set("after", options.After)
+		}
+		if options.Limit > 0 {
+			r.Params.Set("limit", strconv.Itoa(options.Limit))
+		}
+	}
+
+	result := &OIDCClientList{
+		KeyInfo: make(map[string]*OIDCClient),
+	}
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return result, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error setting up decoder for API response: %w", err)
+	}
+	if err := d.Decode(secret.Data); err != nil {
+		return nil, fmt.Errorf("error decoding clients from API response: %w", err)
+	}
+
+	return result, nil
+}
+
+func (c *IdentityOIDC) ReadProvider(name string) (*OIDCProvider, error) {
+	return c.ReadProviderWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) ReadProviderWithContext(ctx context.Context, name string) (*OIDCProvider, error) {
+	var result *OIDCProvider
+	if err := c.read(ctx, "provider", name, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *IdentityOIDC) WriteProvider(name string, provider *OIDCProvider) error {
+	return c.WriteProviderWithContext(context.Background(), name, provider)
+}
+
+func (c *IdentityOIDC) WriteProviderWithContext(ctx context.Context, name string, provider *OIDCProvider) error {
+	return c.write(ctx, fmt.Sprintf("/v1/identity/oidc/provider/%s", EscapePathSegment(name)), provider)
+}
+
+func (c *IdentityOIDC) DeleteProvider(name string) error {
+	return c.DeleteProviderWithContext(context.Background(), name)
+}
+
+func (c *IdentityOIDC) DeleteProviderWithContext(ctx context.Context, name string) error {
+	return c.delete(ctx, "provider", name)
+}
+
+func (c *IdentityOIDC) ListProviders() ([]string, error) {
+	return c.ListProvidersWithContext(context.Background())
+}
+
+func (c *IdentityOIDC) ListProvidersWithContext(ctx context.Context) ([]string, error) {
+	return c.list(ctx, "provider")
+}
+
+// read decodes the named resource of the given kind into out, leaving out
+// unmodified if the resource does not exist. Unknown response fields are
+// ignored.
+func (c *IdentityOIDC) read(ctx context.Context, kind, name string, out interface{}) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodGet, fmt.Sprintf("/v1/identity/oidc/%s/%s", kind, EscapePathSegment(name)))
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.Data == nil {
+		return errors.New("data from server response is empty")
+	}
+
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return fmt.Errorf("error setting up decoder for API response: %w", err)
+	}
+	if err := d.Decode(secret.Data); err != nil {
+		return fmt.Errorf("error decoding %s from API response: %w", kind, err)
+	}
+
+	return nil
+}
+
+func (c *IdentityOIDC) write(ctx context.Context, path string, body interface{}) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodPost, path)
+	if err := r.SetJSONBody(body); err != nil {
+		return err
+	}
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (c *IdentityOIDC) delete(ctx context.Context, kind, name string) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodDelete, fmt.Sprintf("/v1/identity/oidc/%s/%s", kind, EscapePathSegment(name)))
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+func (c *IdentityOIDC) list(ctx context.Context, kind string) ([]string, error) {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest("LIST", fmt.Sprintf("/v1/identity/oidc/%s", kind))
+	// Set this for broader compatibility, but we use LIST above to be able to
+	// handle the wrapping lookup function
+	r.Method = http.MethodGet
+	r.Params.Set("list", "true")
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result []string
+	if err := mapstructure.Decode(secret.Data["keys"], &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestIdentityOIDC_Client(t *testing.T) {
+	var written map[string]interface{}
+	mockVaultServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/identity/oidc/client/test-client" && r.Method == http.MethodPost:
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(body, &written); err != nil {
+				t.Fatal(err)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/v1/identity/oidc/client/test-client" && r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(readOIDCClientResponse))
+		case r.URL.Path == "/v1/identity/oidc/client" && r.URL.Query().Get("list") == "true":
+			_, _ = w.Write([]byte(listOIDCClientsResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockVaultServer.Close()
+
+	cfg := DefaultConfig()
+	cfg.Address = mockVaultServer.URL
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidc := client.Identity().OIDC()
+
+	idTokenTTL := 3600
+	err = oidc.WriteClient("test-client", &OIDCClient{
+		RedirectURIs: &[]string{"https://127.0.0.1:8251/callback"},
+		Assignments:  &[]string{"allow_all"},
+		IDTokenTTL:   &idTokenTTL,
+		ClientID:     "ignored",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the writable fields that were set must be sent
+	expectedWrite := map[string]interface{}{
+		"redirect_uris": []interface{}{"https://127.0.0.1:8251/callback"},
+		"assignments":   []interface{}{"allow_all"},
+		"id_token_ttl":  float64(3600),
+	}
+	if diff := deep.Equal(written, expectedWrite); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// Lists can be cleared and numbers set to 0
+	accessTokenTTL := 0
+	err = oidc.WriteClient("test-client", &OIDCClient{
+		Assignments:    &[]string{},
+		AccessTokenTTL: &accessTokenTTL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedWrite = map[string]interface{}{
+		"assignments":      []interface{}{},
+		"access_token_ttl": float64(0),
+	}
+	if diff := deep.Equal(written, expectedWrite); diff != nil {
+		t.Fatal(diff)
+	}
+
+	resp, err := oidc.ReadClient("test-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessTokenTTL = 86400
+	expectedRead := &OIDCClient{
+		RedirectURIs:   &[]string{"https://127.0.0.1:8251/callback"},
+		Assignments:    &[]string{"allow_all"},
+		Key:            "default",
+		IDTokenTTL:     &idTokenTTL,
+		AccessTokenTTL: &accessTokenTTL,
+		ClientType:     "confidential",
+		ClientID:       "cLWHPcr0vLwPCsSn3FrrxIvjQFxpOl5M",
+		ClientSecret:   "hvo_secret_nkmqzFPABh8A6ESmyNR7btQ0VvGJfRyWL0v0d0EzIRfE0ydfRfe4tqbg2o7BOpBe",
+	}
+	if diff := deep.Equal(resp, expectedRead); diff != nil {
+		t.Fatal(diff)
+	}
+
+	resp, err = oidc.ReadClient("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Fatalf("expected nil client, got %#v", resp)
+	}
+
+	clients, err := oidc.ListClients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(clients, []string{"test-client", "other-client"}); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestIdentityOIDC_ListClientsDetailed(t *testing.T) {
+	var query url.Values
+	mockVaultServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/identity/oidc/client" || r.URL.Query().Get("list") != "true" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.Query()
+		if query.Get("key") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(listOIDCClientsDetailedResponse))
+	}))
+	defer mockVaultServer.Close()
+
+	cfg := DefaultConfig()
+	cfg.Address = mockVaultServer.URL
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidc := client.Identity().OIDC()
+
+	clients, err := oidc.ListClientsDetailed(&OIDCClientListOptions{
+		Key:        "test-key",
+		Assignment: "test-assignment",
+		After:      "client-0",
+		Limit:      10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the options that were set must be sent
+	expectedQuery := url.Values{
+		"list":       {"true"},
+		"detailed":   {"true"},
+		"key":        {"test-key"},
+		"assignment": {"test-assignment"},
+		"after":      {"client-0"},
+		"limit":      {"10"},
+	}
+	if diff := deep.Equal(query, expectedQuery); diff != nil {
+		t.Fatal(diff)
+	}
+
+	idTokenTTL, accessTokenTTL := 3600, 86400
+	expected := &OIDCClientList{
+		Keys: []string{"client-1"},
+		KeyInfo: map[string]*OIDCClient{
+			"client-1": {
+				RedirectURIs:   &[]string{"https://127.0.0.1:8251/callback"},
+				Assignments:    &[]string{"test-assignment"},
+				Key:            "test-key",
+				IDTokenTTL:     &idTokenTTL,
+				AccessTokenTTL: &accessTokenTTL,
+				ClientType:     "confidential",
+				ClientID:       "cLWHPcr0vLwPCsSn3FrrxIvjQFxpOl5M",
+			},
+		},
+	}
+	if diff := deep.Equal(clients, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	_, err = oidc.ListClientsDetailed(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(query, url.Values{"list": {"true"}, "detailed": {"true"}}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// No matching clients is an empty listing
+	clients, err = oidc.ListClientsDetailed(&OIDCClientListOptions{Key: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients.Keys) != 0 || len(clients.KeyInfo) != 0 {
+		t.Fatalf("expected no clients, got %#v", clients)
+	}
+}
+
+const readOIDCClientResponse = `{
+  "request_id": "5b3fd2c0-05d5-bba1-b9c6-6d2d1b6bfde5",
+  "data": {
+    "access_token_ttl": 86400,
+    "assignments": ["allow_all"],
+    "client_id": "cLWHPcr0vLwPCsSn3FrrxIvjQFxpOl5M",
+    "client_secret": "hvo_secret_nkmqzFPABh8A6ESmyNR7btQ0VvGJfRyWL0v0d0EzIRfE0ydfRfe4tqbg2o7BOpBe",
+    "client_type": "confidential",
+    "id_token_ttl": 3600,
+    "key": "default",
+    "redirect_uris": ["https://127.0.0.1:8251/callback"],
+    "unknown_future_field": {"nested": true}
+  }
+}`
+
+const listOIDCClientsResponse = `{
+  "data": {
+    "keys": ["test-client", "other-client"]
+  }
+}`
+
+const listOIDCClientsDetailedResponse = `{
+  "data": {
+    "keys": ["client-1"],
+    "key_info": {
+      "client-1": {
+        "access_token_ttl": 86400,
+        "assignments": ["test-assignment"],
+        "client_id": "cLWHPcr0vLwPCsSn3FrrxIvjQFxpOl5M",
+        "client_type": "confidential",
+        "id_token_ttl": 3600,
+        "key": "test-key",
+        "redirect_uris": ["https://127.0.0.1:8251/callback"]
+      }
+    }
+  }
+}`
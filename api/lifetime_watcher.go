@@ -2,7 +2,9 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
+	"net/http"
 	"sync"
 	"time"
 
@@ -86,6 +88,12 @@ type LifetimeWatcher struct {
 	doneCh        chan error
 	renewCh       chan *RenewOutput
 	renewBehavior RenewBehavior
+	renewJitter   float64
+	eventCallback func(*LifetimeWatcherEvent)
+
+	// renewLatency is the highest observed latency of renewals, added to the
+	// grace period so that slow renewals still complete before expiry.
+	renewLatency time.Duration
 
 	stopped bool
 	stopCh  chan struct{}
@@ -121,6 +129,99 @@ type LifetimeWatcherInput struct {
 	// RenewBehavior controls what happens when a renewal errors or the
 	// passed-in secret is not renewable.
 	RenewBehavior RenewBehavior
+
+	// RenewJitter is the maximum fraction, between 0 and 1, by which the time
+	// to wait before each renewal is randomly shortened, so that watchers of
+	// secrets obtained at the same time don't all renew at once.
+	RenewJitter float64
+
+	// EventCallback, if set, is called with an event for every renewal, failed
+	// renewal, and when the watcher is done. It is called from the goroutine
+	// running the watcher, so it should not block.
+	EventCallback func(*LifetimeWatcherEvent)
+}
+
+// LifetimeWatcherEventType is the type of the events passed to the event
+// callback of a LifetimeWatcher.
+type LifetimeWatcherEventType uint
+
+const (
+	// LifetimeWatcherEventRenewed is emitted after a successful renewal.
+	LifetimeWatcherEventRenewed LifetimeWatcherEventType = iota
+
+	// LifetimeWatcherEventRenewFailed is emitted after a failed renewal. The
+	// error of the event is a *RenewError.
+	LifetimeWatcherEventRenewFailed
+
+	// LifetimeWatcherEventDone is emitted when the watcher is done, with the
+	// same error as the one sent on DoneCh.
+	LifetimeWatcherEventDone
+)
+
+// LifetimeWatcherDoneReason is the reason why a LifetimeWatcher is done.
+type LifetimeWatcherDoneReason uint
+
+const (
+	// LifetimeWatcherDoneExpiring means the remaining lease duration is within
+	// the grace period, and the secret should be re-read.
+	LifetimeWatcherDoneExpiring LifetimeWatcherDoneReason = iota
+
+	// LifetimeWatcherDoneStopped means the watcher was stopped.
+	LifetimeWatcherDoneStopped
+
+	// LifetimeWatcherDoneError means the watcher exited because of an error.
+	LifetimeWatcherDoneError
+)
+
+// LifetimeWatcherEvent is an event passed to the event callback of a
+// LifetimeWatcher.
+type LifetimeWatcherEvent struct {
+	Type LifetimeWatcherEventType
+
+	// Time is the time at which the event took place (UTC).
+	Time time.Time
+
+	// Secret is the renewal data of renewed events.
+	Secret *Secret
+
+	// TTL is the new lease duration of renewed events.
+	TTL time.Duration
+
+	// Err is the error of failed renewal events and done events.
+	Err error
+
+	// Reason is the reason of done events.
+	Reason LifetimeWatcherDoneReason
+}
+
+// RenewError is the error of a failed renewal.
+type RenewError struct {
+	Err error
+
+	// Permanent is true when retrying the renewal can't succeed, e.g. because
+	// the token was revoked or the lease does not exist anymore. The secret
+	// should then be re-read or the client re-authenticated immediately
+	// rather than waiting for the watcher to be done.
+	Permanent bool
+}
+
+func (e *RenewError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RenewError) Unwrap() error {
+	return e.Err
+}
+
+// newRenewError classifies the given renewal error. Permission denied and
+// bad request errors are permanent, as returned for revoked tokens and
+// unknown or non-renewable leases; any other error, e.g. a network error or a
+// 5xx response, is considered transient.
+func newRenewError(err error) *RenewError {
+	var respErr *ResponseError
+	permanent := errors.Is(err, ErrPermissionDenied) ||
+		(errors.As(err, &respErr) && respErr.StatusCode == http.StatusBadRequest)
+	return &RenewError{Err: err, Permanent: permanent}
 }
 
 // RenewOutput is the metadata returned to the client (if it's listening) to
@@ -156,6 +257,10 @@ func (c *Client) NewLifetimeWatcher(i *LifetimeWatcherInput) (*LifetimeWatcher,
 		renewBuffer = DefaultLifetimeWatcherRenewBuffer
 	}
 
+	if i.RenewJitter < 0 || i.RenewJitter > 1 {
+		return nil, fmt.Errorf("renew jitter must be between 0 and 1")
+	}
+
 	return &LifetimeWatcher{
 		client:        c,
 		secret:        secret,
@@ -164,6 +269,8 @@ func (c *Client) NewLifetimeWatcher(i *LifetimeWatcherInput) (*LifetimeWatcher,
 		doneCh:        make(chan error, 1),
 		renewCh:       make(chan *RenewOutput, renewBuffer),
 		renewBehavior: i.RenewBehavior,
+		renewJitter:   i.RenewJitter,
+		eventCallback: i.EventCallback,
 
 		stopped: false,
 		stopCh:  make(chan struct{}),
@@ -224,7 +331,33 @@ func (r *LifetimeWatcher) Stop() {
 // the auth (token); When the secret has a lease, this attempts to renew the
 // lease.
 func (r *LifetimeWatcher) Start() {
-	r.doneCh <- r.doRenew()
+	err := r.doRenew()
+
+	reason := LifetimeWatcherDoneExpiring
+	select {
+	case <-r.stopCh:
+		reason = LifetimeWatcherDoneStopped
+	default:
+	}
+	if err != nil {
+		reason = LifetimeWatcherDoneError
+	}
+	r.emit(&LifetimeWatcherEvent{
+		Type:   LifetimeWatcherEventDone,
+		Err:    err,
+		Reason: reason,
+	})
+
+	r.doneCh <- err
+}
+
+// emit passes the given event to the event callback, if any.
+func (r *LifetimeWatcher) emit(event *LifetimeWatcherEvent) {
+	if r.eventCallback == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	r.eventCallback(event)
 }
 
 // Renew is for compatibility with the legacy api.Renewer. Calling Renew
@@ -283,8 +416,21 @@ func (r *LifetimeWatcher) doRenewWithOptions(tokenMode bool, nonRenewable bool,
 
 		default:
 			// Renew the token
+			renewStart := time.Now()
 			renewal, err = renew(credString, r.increment)
+			if latency := time.Since(renewStart); latency > r.renewLatency {
+				r.renewLatency = latency
+			}
 			if err != nil || renewal == nil || (tokenMode && renewal.Auth == nil) {
+				renewErr := err
+				if renewErr == nil {
+					renewErr = r.errLifetimeWatcherNoSecretData
+				}
+				r.emit(&LifetimeWatcherEvent{
+					Type: LifetimeWatcherEventRenewFailed,
+					Err:  newRenewError(renewErr),
+				})
+
 				if r.renewBehavior == RenewBehaviorErrorOnErrors {
 					if err != nil {
 						return err
@@ -333,6 +479,12 @@ func (r *LifetimeWatcher) doRenewWithOptions(tokenMode bool, nonRenewable bool,
 			}
 
 			remainingLeaseDuration = time.Duration(initLeaseDuration) * time.Second
+
+			r.emit(&LifetimeWatcherEvent{
+				Type:   LifetimeWatcherEventRenewed,
+				Secret: renewal,
+				TTL:    remainingLeaseDuration,
+			})
 		}
 
 		var sleepDuration time.Duration
@@ -354,15 +506,24 @@ func (r *LifetimeWatcher) doRenewWithOptions(tokenMode bool, nonRenewable bool,
 			// The sleep duration is set to 2/3 of the current lease duration plus
 			// 1/3 of the current grace period, which adds jitter.
 			sleepDuration = time.Duration(float64(remainingLeaseDuration.Nanoseconds())*2/3 + float64(r.grace.Nanoseconds())/3)
+
+			// Shorten the sleep by a random fraction of up to the configured
+			// jitter
+			if r.renewJitter > 0 {
+				sleepDuration -= time.Duration(r.random.Float64() * r.renewJitter * float64(sleepDuration))
+			}
 		}
 
+		// Leave enough time before expiry for the slowest observed renewal
+		grace := r.grace + r.renewLatency
+
 		// If we are within grace, return now; or, if the amount of time we
 		// would sleep would land us in the grace period. This helps with short
 		// tokens; for example, you don't want a current lease duration of 4
 		// seconds, a grace period of 3 seconds, and end up sleeping for more
 		// than three of those seconds and having a very small budget of time
 		// to renew.
-		if remainingLeaseDuration <= r.grace || remainingLeaseDuration-sleepDuration <= r.grace {
+		if remainingLeaseDuration <= grace || remainingLeaseDuration-sleepDuration <= grace {
 			return nil
 		}
 
@@ -3,11 +3,15 @@ package api
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/errwrap"
@@ -65,28 +69,12 @@ func (c *Logical) ReadWithDataWithContext(ctx context.Context, path string, data
 	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
 	defer cancelFunc()
 
-	r := c.c.NewRequest(http.MethodGet, "/v1/"+path)
-
-	var values url.Values
-	for k, v := range data {
-		if values == nil {
-			values = make(url.Values)
-		}
-		for _, val := range v {
-			values.Add(k, val)
-		}
-	}
-
-	if values != nil {
-		r.Params = values
-	}
-
-	resp, err := c.c.rawRequestWithContext(ctx, r)
+	resp, err := c.readRawWithDataWithContext(ctx, path, data)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
 	if resp != nil && resp.StatusCode == 404 {
-		secret, parseErr := ParseSecret(resp.Body)
+		secret, parseErr := parseSecretResponse(resp)
 		switch parseErr {
 		case nil:
 		case io.EOF:
@@ -103,7 +91,83 @@ func (c *Logical) ReadWithDataWithContext(ctx context.Context, path string, data
 		return nil, err
 	}
 
-	return ParseSecret(resp.Body)
+	return parseSecretResponse(resp)
+}
+
+// ReadRaw attempts to read the value stored at the given Vault path and
+// returns the raw response, whose body is not assumed to be JSON. The caller
+// is responsible for closing the body.
+func (c *Logical) ReadRaw(path string) (*Response, error) {
+	return c.ReadRawWithDataWithContext(context.Background(), path, nil)
+}
+
+// ReadRawWithContext is the same as ReadRaw but with a context.
+func (c *Logical) ReadRawWithContext(ctx context.Context, path string) (*Response, error) {
+	return c.ReadRawWithDataWithContext(ctx, path, nil)
+}
+
+// ReadRawWithData is the same as ReadRaw but sends the given data as query
+// parameters.
+func (c *Logical) ReadRawWithData(path string, data map[string][]string) (*Response, error) {
+	return c.ReadRawWithDataWithContext(context.Background(), path, data)
+}
+
+// ReadRawWithDataWithContext is the same as ReadRawWithData but with a
+// context. The body of the response is streamed rather than buffered. Like
+// RawRequest, a response with an error status code is returned alongside the
+// error.
+func (c *Logical) ReadRawWithDataWithContext(ctx context.Context, path string, data map[string][]string) (*Response, error) {
+	// See the note in RawRequestWithContext on why cancel is not called here
+	ctx, _ = c.c.withConfiguredTimeout(ctx)
+	return c.readRawWithDataWithContext(ctx, path, data)
+}
+
+// ReadJSONInto reads the given Vault path with the given data as query
+// parameters and decodes the JSON body of the response into v, which is
+// typically a pointer to a struct. The body is not parsed as a secret, so
+// this can be used for endpoints such as the OIDC discovery documents which
+// do not use the standard response format.
+func (c *Logical) ReadJSONInto(path string, data map[string][]string, v interface{}) error {
+	return c.ReadJSONIntoWithContext(context.Background(), path, data, v)
+}
+
+// ReadJSONIntoWithContext is the same as ReadJSONInto but with a context.
+func (c *Logical) ReadJSONIntoWithContext(ctx context.Context, path string, data map[string][]string, v interface{}) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	resp, err := c.readRawWithDataWithContext(ctx, path, data)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := jsonutil.DecodeJSONFromReader(resp.Body, v); err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("error decoding response from %q: {{err}}", path), err)
+	}
+	return nil
+}
+
+func (c *Logical) readRawWithDataWithContext(ctx context.Context, path string, data map[string][]string) (*Response, error) {
+	r := c.c.NewRequest(http.MethodGet, "/v1/"+path)
+
+	var values url.Values
+	for k, v := range data {
+		if values == nil {
+			values = make(url.Values)
+		}
+		for _, val := range v {
+			values.Add(k, val)
+		}
+	}
+
+	if values != nil {
+		r.Params = values
+	}
+
+	return c.c.rawRequestWithContext(ctx, r)
 }
 
 func (c *Logical) List(path string) (*Secret, error) {
@@ -111,6 +175,116 @@ func (c *Logical) List(path string) (*Secret, error) {
 }
 
 func (c *Logical) ListWithContext(ctx context.Context, path string) (*Secret, error) {
+	return c.list(ctx, path, nil)
+}
+
+// ListPageOptions are the options of a paginated list request. Endpoints
+// that don't support pagination ignore them and return all keys.
+type ListPageOptions struct {
+	// After is the key after which to start listing.
+	After string
+
+	// Limit is the maximum number of keys to return. All keys are returned
+	// if it is zero.
+	Limit int
+
+	// Params are additional query parameters for the request, such as
+	// "detailed" for endpoints that return key_info.
+	Params map[string][]string
+}
+
+// ListPage lists at most limit keys at the given path that sort after the
+// given key.
+func (c *Logical) ListPage(path string, after string, limit int) (*Secret, error) {
+	return c.ListPageWithContext(context.Background(), path, after, limit)
+}
+
+func (c *Logical) ListPageWithContext(ctx context.Context, path string, after string, limit int) (*Secret, error) {
+	return c.ListPageWithOptions(ctx, path, &ListPageOptions{
+		After: after,
+		Limit: limit,
+	})
+}
+
+func (c *Logical) ListPageWithOptions(ctx context.Context, path string, options *ListPageOptions) (*Secret, error) {
+	params := make(url.Values)
+	if options != nil {
+		for k, v := range options.Params {
+			for _, val := range v {
+				params.Add(k, val)
+			}
+		}
+		if options.After != "" {
+			params.Set("after", options.After)
+		}
+		if options.Limit > 0 {
+			params.Set("limit", strconv.Itoa(options.Limit))
+		}
+	}
+
+	return c.list(ctx, path, params)
+}
+
+// ListAllResult contains all keys returned by ListAll, along with the
+// key_info of detailed listings.
+type ListAllResult struct {
+	Keys    []string
+	KeyInfo map[string]interface{}
+}
+
+// ListAll lists all keys at the given path, requesting pages of
+// options.Limit keys until all keys have been listed. If options.Limit is
+// zero, a default page size of 100 is used.
+func (c *Logical) ListAll(ctx context.Context, path string, options *ListPageOptions) (*ListAllResult, error) {
+	pageOptions := ListPageOptions{Limit: 100}
+	if options != nil {
+		pageOptions = *options
+		if pageOptions.Limit <= 0 {
+			pageOptions.Limit = 100
+		}
+	}
+
+	result := &ListAllResult{}
+	for {
+		secret, err := c.ListPageWithOptions(ctx, path, &pageOptions)
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil || secret.Data == nil {
+			return result, nil
+		}
+
+		keysRaw, _ := secret.Data["keys"].([]interface{})
+		for _, keyRaw := range keysRaw {
+			key, ok := keyRaw.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected key type %T in list response", keyRaw)
+			}
+			result.Keys = append(result.Keys, key)
+		}
+		if keyInfo, ok := secret.Data["key_info"].(map[string]interface{}); ok {
+			if result.KeyInfo == nil {
+				result.KeyInfo = make(map[string]interface{})
+			}
+			for k, v := range keyInfo {
+				result.KeyInfo[k] = v
+			}
+		}
+
+		if len(keysRaw) < pageOptions.Limit {
+			return result, nil
+		}
+
+		// Guard against endpoints that ignore the pagination parameters
+		last := result.Keys[len(result.Keys)-1]
+		if last <= pageOptions.After {
+			return nil, fmt.Errorf("%q does not support pagination", path)
+		}
+		pageOptions.After = last
+	}
+}
+
+func (c *Logical) list(ctx context.Context, path string, params url.Values) (*Secret, error) {
 	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
 	defer cancelFunc()
 
@@ -118,6 +292,9 @@ func (c *Logical) ListWithContext(ctx context.Context, path string) (*Secret, er
 	// Set this for broader compatibility, but we use LIST above to be able to
 	// handle the wrapping lookup function
 	r.Method = http.MethodGet
+	for k, v := range params {
+		r.Params[k] = v
+	}
 	r.Params.Set("list", "true")
 
 	resp, err := c.c.rawRequestWithContext(ctx, r)
@@ -125,7 +302,7 @@ func (c *Logical) ListWithContext(ctx context.Context, path string) (*Secret, er
 		defer resp.Body.Close()
 	}
 	if resp != nil && resp.StatusCode == 404 {
-		secret, parseErr := ParseSecret(resp.Body)
+		secret, parseErr := parseSecretResponse(resp)
 		switch parseErr {
 		case nil:
 		case io.EOF:
@@ -142,7 +319,7 @@ func (c *Logical) ListWithContext(ctx context.Context, path string) (*Secret, er
 		return nil, err
 	}
 
-	return ParseSecret(resp.Body)
+	return parseSecretResponse(resp)
 }
 
 func (c *Logical) Write(path string, data map[string]interface{}) (*Secret, error) {
@@ -165,7 +342,45 @@ func (c *Logical) JSONMergePatch(ctx context.Context, path string, data map[stri
 		return nil, err
 	}
 
-	return c.write(ctx, path, r)
+	secret, err := c.write(ctx, path, r)
+	var respErr *ResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusMethodNotAllowed {
+		return nil, fmt.Errorf("%q does not support patching: %w", path, err)
+	}
+	return secret, err
+}
+
+// Patch applies data to the resource at path as a JSON merge patch (RFC
+// 7396). Keys set to nil remove the corresponding field where the backend
+// supports it. An error wrapping the server's 405 response is returned if the
+// endpoint does not support patching.
+func (c *Logical) Patch(path string, data map[string]interface{}) (*Secret, error) {
+	return c.PatchWithContext(context.Background(), path, data)
+}
+
+func (c *Logical) PatchWithContext(ctx context.Context, path string, data map[string]interface{}) (*Secret, error) {
+	return c.JSONMergePatch(ctx, path, data)
+}
+
+// JSONMergePatchFromStruct builds a JSON merge patch from v, which must
+// encode to a JSON object. The struct's json tags are honored, so fields
+// tagged with omitempty are left out of the patch when they have their zero
+// value and remain unchanged on the server.
+func JSONMergePatchFromStruct(v interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var patch map[string]interface{}
+	if err := jsonutil.DecodeJSON(encoded, &patch); err != nil {
+		return nil, err
+	}
+	if patch == nil {
+		return nil, errors.New("patch must be a JSON object")
+	}
+
+	return patch, nil
 }
 
 func (c *Logical) WriteBytes(path string, data []byte) (*Secret, error) {
@@ -179,6 +394,57 @@ func (c *Logical) WriteBytesWithContext(ctx context.Context, path string, data [
 	return c.write(ctx, path, r)
 }
 
+// MaxRawWriteBodySize is the maximum size of the bodies of raw writes. Bodies
+// are buffered so that they can be sent again on retries and redirects, so
+// larger bodies, or streams that never end, are refused. It matches the
+// default maximum request size of Vault listeners.
+const MaxRawWriteBodySize = 32 << 20
+
+// WriteRaw writes the body to the given Vault path with the given content
+// type, and returns the raw response, whose body is not assumed to be JSON.
+// The caller is responsible for closing the body of the response.
+func (c *Logical) WriteRaw(path string, contentType string, body io.Reader) (*Response, error) {
+	return c.WriteRawWithContext(context.Background(), path, contentType, body)
+}
+
+// WriteRawWithContext is the same as WriteRaw but with a context. The body is
+// read up to MaxRawWriteBodySize bytes before the request is sent. Like
+// RawRequest, a response with an error status code is returned alongside the
+// error.
+func (c *Logical) WriteRawWithContext(ctx context.Context, path string, contentType string, body io.Reader) (*Response, error) {
+	r := c.c.NewRequest(http.MethodPost, "/v1/"+path)
+	if contentType != "" {
+		r.Headers.Set("Content-Type", contentType)
+	}
+
+	if body != nil {
+		buf, err := ioutil.ReadAll(io.LimitReader(body, MaxRawWriteBodySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("error reading body for %q: %w", path, err)
+		}
+		if len(buf) > MaxRawWriteBodySize {
+			return nil, fmt.Errorf("body for %q is larger than %d bytes", path, MaxRawWriteBodySize)
+		}
+		r.BodyBytes = buf
+	}
+
+	// See the note in RawRequestWithContext on why cancel is not called here
+	ctx, _ = c.c.withConfiguredTimeout(ctx)
+	return c.c.rawRequestWithContext(ctx, r)
+}
+
+// WriteForm writes the form-encoded data to the given Vault path, as expected
+// by endpoints such as the OIDC token endpoint, and returns the raw response.
+// The caller is responsible for closing the body of the response.
+func (c *Logical) WriteForm(path string, data url.Values) (*Response, error) {
+	return c.WriteFormWithContext(context.Background(), path, data)
+}
+
+// WriteFormWithContext is the same as WriteForm but with a context.
+func (c *Logical) WriteFormWithContext(ctx context.Context, path string, data url.Values) (*Response, error) {
+	return c.WriteRawWithContext(ctx, path, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
 func (c *Logical) write(ctx context.Context, path string, request *Request) (*Secret, error) {
 	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
 	defer cancelFunc()
@@ -188,7 +454,7 @@ func (c *Logical) write(ctx context.Context, path string, request *Request) (*Se
 		defer resp.Body.Close()
 	}
 	if resp != nil && resp.StatusCode == 404 {
-		secret, parseErr := ParseSecret(resp.Body)
+		secret, parseErr := parseSecretResponse(resp)
 		switch parseErr {
 		case nil:
 		case io.EOF:
@@ -204,7 +470,7 @@ func (c *Logical) write(ctx context.Context, path string, request *Request) (*Se
 		return nil, err
 	}
 
-	return ParseSecret(resp.Body)
+	return parseSecretResponse(resp)
 }
 
 func (c *Logical) Delete(path string) (*Secret, error) {
@@ -244,7 +510,7 @@ func (c *Logical) DeleteWithDataWithContext(ctx context.Context, path string, da
 		defer resp.Body.Close()
 	}
 	if resp != nil && resp.StatusCode == 404 {
-		secret, parseErr := ParseSecret(resp.Body)
+		secret, parseErr := parseSecretResponse(resp)
 		switch parseErr {
 		case nil:
 		case io.EOF:
@@ -260,7 +526,7 @@ func (c *Logical) DeleteWithDataWithContext(ctx context.Context, path string, da
 		return nil, err
 	}
 
-	return ParseSecret(resp.Body)
+	return parseSecretResponse(resp)
 }
 
 func (c *Logical) Unwrap(wrappingToken string) (*Secret, error) {
@@ -299,11 +565,11 @@ func (c *Logical) UnwrapWithContext(ctx context.Context, wrappingToken string) (
 		if resp == nil {
 			return nil, nil
 		}
-		return ParseSecret(resp.Body)
+		return parseSecretResponse(resp)
 	}
 
 	// In the 404 case this may actually be a wrapped 404 error
-	secret, parseErr := ParseSecret(resp.Body)
+	secret, parseErr := parseSecretResponse(resp)
 	switch parseErr {
 	case nil:
 	case io.EOF:
@@ -345,3 +611,13 @@ func (c *Logical) UnwrapWithContext(ctx context.Context, wrappingToken string) (
 
 	return wrappedSecret, nil
 }
+
+// parseSecretResponse parses the secret in the body of the given response,
+// attaching the response headers to it.
+func parseSecretResponse(resp *Response) (*Secret, error) {
+	secret, err := ParseSecret(resp.Body)
+	if secret != nil {
+		secret.Headers = resp.Header
+	}
+	return secret, err
+}
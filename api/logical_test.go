@@ -0,0 +1,381 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestLogical_Patch(t *testing.T) {
+	var patched map[string]interface{}
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPatch {
+			t.Errorf("expected PATCH request, got %s", req.Method)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != "application/merge-patch+json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+
+		switch req.URL.Path {
+		case "/v1/identity/oidc/client/test-client":
+			if err := json.NewDecoder(req.Body).Decode(&patched); err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(`{"data": {"redirect_uris": ["https://example.com/callback"]}}`))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(`{"errors": ["1 error occurred:\n\t* unsupported operation\n\n"]}`))
+		}
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type clientPatch struct {
+		RedirectURIs []string `json:"redirect_uris,omitempty"`
+		Key          string   `json:"key,omitempty"`
+		Assignments  []string `json:"assignments"`
+	}
+	patch, err := JSONMergePatchFromStruct(&clientPatch{
+		RedirectURIs: []string{"https://example.com/callback"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Zero-valued omitempty fields are left out while others are sent as-is
+	expected := map[string]interface{}{
+		"redirect_uris": []interface{}{"https://example.com/callback"},
+		"assignments":   nil,
+	}
+	if diff := deep.Equal(patch, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	secret, err := client.Logical().Patch("identity/oidc/client/test-client", patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data["redirect_uris"] == nil {
+		t.Fatalf("unexpected response: %#v", secret)
+	}
+	if diff := deep.Equal(patched, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	_, err = client.Logical().Patch("auth/userpass/users/test", patch)
+	if err == nil {
+		t.Fatal("expected error patching an endpoint that does not support it")
+	}
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected wrapped 405 response error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "does not support patching") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := JSONMergePatchFromStruct([]string{"not", "an", "object"}); err == nil {
+		t.Fatal("expected error building a patch from a non-object")
+	}
+}
+
+func TestLogical_ListAll(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e"}
+	var requests []string
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		requests = append(requests, req.URL.RawQuery)
+		if q.Get("list") != "true" || q.Get("detailed") != "true" {
+			t.Errorf("unexpected query %q", req.URL.RawQuery)
+		}
+
+		limit, err := strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page []string
+		for _, key := range keys {
+			if key > q.Get("after") && len(page) < limit {
+				page = append(page, key)
+			}
+		}
+		keyInfo := make(map[string]interface{})
+		for _, key := range page {
+			keyInfo[key] = map[string]interface{}{"name": key}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"keys": page, "key_info": keyInfo},
+		})
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.Logical().ListAll(context.Background(), "auth/userpass/users", &ListPageOptions{
+		Limit:  2,
+		Params: map[string][]string{"detailed": {"true"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(result.Keys, keys); diff != nil {
+		t.Fatal(diff)
+	}
+	if len(result.KeyInfo) != len(keys) {
+		t.Fatalf("unexpected key_info: %#v", result.KeyInfo)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %v", requests)
+	}
+
+	secret, err := client.Logical().ListPage("auth/userpass/users", "a&b", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil {
+		t.Fatal("expected a page")
+	}
+	if last := requests[len(requests)-1]; !strings.Contains(last, "after=a%26b") {
+		t.Fatalf("expected encoded after parameter, got %q", last)
+	}
+}
+
+func TestLogical_ResponseHeaders(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(HeaderIndex, "test-index")
+		if req.URL.Path == "/v1/secret/denied" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"request_id": "test-request-id", "data": {"keys": ["foo"]}}`))
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logical := client.Logical()
+
+	calls := map[string]func() (*Secret, error){
+		"read":   func() (*Secret, error) { return logical.Read("secret/foo") },
+		"list":   func() (*Secret, error) { return logical.List("secret") },
+		"write":  func() (*Secret, error) { return logical.Write("secret/foo", nil) },
+		"delete": func() (*Secret, error) { return logical.Delete("secret/foo") },
+	}
+	for name, call := range calls {
+		secret, err := call()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if secret == nil || secret.Headers.Get(HeaderIndex) != "test-index" {
+			t.Fatalf("%s: expected index header on secret, got %#v", name, secret)
+		}
+	}
+
+	_, err = logical.Read("secret/denied")
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		t.Fatalf("expected response error, got %v", err)
+	}
+	if respErr.Headers.Get(HeaderIndex) != "test-index" {
+		t.Fatalf("expected index header on response error, got %#v", respErr.Headers)
+	}
+}
+
+func TestLogical_ReadRaw(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "" {
+			t.Errorf("unexpected token on unauthenticated request")
+		}
+		switch req.URL.Path {
+		case "/v1/identity/oidc/provider/test/.well-known/openid-configuration":
+			if req.URL.Query().Get("a") != "b&c" {
+				t.Errorf("unexpected query %q", req.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"issuer": "https://example.com/v1/identity/oidc/provider/test"}`))
+		case "/v1/sys/metrics":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("# TYPE vault_core_unsealed gauge\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ClearToken()
+
+	var discovery struct {
+		Issuer string `json:"issuer"`
+	}
+	err = client.Logical().ReadJSONInto("identity/oidc/provider/test/.well-known/openid-configuration",
+		map[string][]string{"a": {"b&c"}}, &discovery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discovery.Issuer != "https://example.com/v1/identity/oidc/provider/test" {
+		t.Fatalf("unexpected issuer %q", discovery.Issuer)
+	}
+
+	// Non-JSON bodies are returned as-is
+	resp, err := client.Logical().ReadRaw("sys/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "# TYPE vault_core_unsealed gauge\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	resp, err = client.Logical().ReadRaw("missing")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 response and error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if err := client.Logical().ReadJSONInto("missing", nil, &discovery); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestLogical_WriteForm(t *testing.T) {
+	var attempts int
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			t.Errorf("expected POST request, got %s", req.Method)
+		}
+
+		switch req.URL.Path {
+		case "/v1/identity/oidc/provider/test/token":
+			// Redirected requests must send the same body
+			http.Redirect(w, req, "/v1/identity/oidc/provider/test/token-redirected", http.StatusTemporaryRedirect)
+		case "/v1/identity/oidc/provider/test/token-redirected":
+			if ct := req.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+			if err := req.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			if req.PostForm.Get("grant_type") != "authorization_code" || req.PostForm.Get("code") != "a&b" {
+				t.Errorf("unexpected form %v", req.PostForm)
+			}
+
+			// Retried requests must send the same body
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token": "token"}`))
+		case "/v1/raw":
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ct := req.Header.Get("Content-Type"); ct != "text/plain" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+			if string(body) != "raw body" {
+				t.Errorf("unexpected body %q", body)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors": ["invalid request"]}`))
+		}
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+	config.MinRetryWait = time.Millisecond
+	config.MaxRetryWait = time.Millisecond
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Logical().WriteForm("identity/oidc/provider/test/token", url.Values{
+		"grant_type": {"authorization_code"},
+		"code":       {"a&b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var tokens map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		t.Fatal(err)
+	}
+	if tokens["access_token"] != "token" || attempts != 2 {
+		t.Fatalf("unexpected response %v after %d attempts", tokens, attempts)
+	}
+
+	resp, err = client.Logical().WriteRaw("raw", "text/plain", strings.NewReader("raw body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status code %d", resp.StatusCode)
+	}
+
+	// Responses with an error status code are returned with the error
+	resp, err = client.Logical().WriteForm("missing", url.Values{})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 response and error, got %v", err)
+	}
+	resp.Body.Close()
+
+	// Bodies that can't be buffered are refused
+	_, err = client.Logical().WriteRaw("raw", "text/plain", zeroReader{})
+	if err == nil || !strings.Contains(err.Error(), "is larger than") {
+		t.Fatalf("expected error for oversized body, got %v", err)
+	}
+}
+
+// zeroReader is an unbounded stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,527 @@
+// Package oidcrp provides a minimal OpenID Connect relying party for
+// completing authorization code flows against a Vault identity OIDC provider.
+// It is intended for tests and tooling rather than as a general purpose OIDC
+// client library.
+package oidcrp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// clockSkewLeeway is the amount of clock skew tolerated when validating the
+// time-based claims of an ID token.
+const clockSkewLeeway = 30 * time.Second
+
+// Config configures a relying party.
+type Config struct {
+	// Issuer is the issuer of the OIDC provider. The discovery document is
+	// fetched from <Issuer>/.well-known/openid-configuration.
+	Issuer string
+
+	// ClientID is the ID of the OIDC client.
+	ClientID string
+
+	// ClientSecret is the secret of a confidential client. It must be empty
+	// for public clients, which are required to use PKCE.
+	ClientSecret string
+
+	// RedirectURI is the redirect URI used in authorization requests.
+	RedirectURI string
+}
+
+// Discovery is the subset of the provider's discovery document used by the
+// relying party.
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	JWKSURI               string   `json:"jwks_uri"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	IDTokenAlgs           []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported       []string `json:"scopes_supported"`
+}
+
+// RelyingParty completes authorization code flows against an OIDC provider.
+// The discovery document and JSON web key set are fetched on first use and
+// cached; the key set is refreshed when an ID token is signed by an unknown
+// key.
+type RelyingParty struct {
+	client     *api.Client
+	httpClient *http.Client
+	config     Config
+
+	l         sync.Mutex
+	discovery *Discovery
+	keys      *jose.JSONWebKeySet
+}
+
+// New returns a relying party for the given configuration. Requests to the
+// provider use the HTTP client of the given Vault client, including its TLS
+// configuration. The Vault client's token is used by Authorize to
+// authenticate the end-user at the authorization endpoint.
+func New(client *api.Client, config Config) (*RelyingParty, error) {
+	if client == nil {
+		return nil, errors.New("client is required")
+	}
+	if config.Issuer == "" {
+		return nil, errors.New("issuer is required")
+	}
+	if config.ClientID == "" {
+		return nil, errors.New("client ID is required")
+	}
+	if config.RedirectURI == "" {
+		return nil, errors.New("redirect URI is required")
+	}
+
+	return &RelyingParty{
+		client:     client,
+		httpClient: client.CloneConfig().HttpClient,
+		config:     config,
+	}, nil
+}
+
+// Discovery returns the provider's discovery document, fetching it if it has
+// not yet been cached.
+func (rp *RelyingParty) Discovery(ctx context.Context) (*Discovery, error) {
+	rp.l.Lock()
+	defer rp.l.Unlock()
+
+	if rp.discovery != nil {
+		return rp.discovery, nil
+	}
+
+	var discovery Discovery
+	issuer := strings.TrimSuffix(rp.config.Issuer, "/")
+	if err := rp.getJSON(ctx, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("error fetching discovery document: %w", err)
+	}
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", discovery.Issuer, issuer)
+	}
+
+	rp.discovery = &discovery
+	return rp.discovery, nil
+}
+
+// keySet returns the provider's JSON web key set, fetching it if it has not
+// yet been cached or if refresh is set.
+func (rp *RelyingParty) keySet(ctx context.Context, refresh bool) (*jose.JSONWebKeySet, error) {
+	discovery, err := rp.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rp.l.Lock()
+	defer rp.l.Unlock()
+
+	if rp.keys != nil && !refresh {
+		return rp.keys, nil
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := rp.getJSON(ctx, discovery.JWKSURI, &keys); err != nil {
+		return nil, fmt.Errorf("error fetching keys: %w", err)
+	}
+
+	rp.keys = &keys
+	return rp.keys, nil
+}
+
+// AuthRequest holds the state of a single authorization request.
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	Scopes       []string
+
+	// MaxAge is the max_age parameter of the request in seconds. It is not
+	// sent if zero.
+	MaxAge int
+}
+
+// NewAuthRequest returns an authorization request with a random state, nonce
+// and PKCE code verifier. The openid scope is always requested.
+func NewAuthRequest(scopes ...string) (*AuthRequest, error) {
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	requested := []string{"openid"}
+	for _, scope := range scopes {
+		if scope != "openid" {
+			requested = append(requested, scope)
+		}
+	}
+
+	return &AuthRequest{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Scopes:       requested,
+	}, nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge of the request.
+func (r *AuthRequest) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(r.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthURL returns the URL of the authorization endpoint for the request.
+func (rp *RelyingParty) AuthURL(ctx context.Context, req *AuthRequest) (string, error) {
+	discovery, err := rp.Discovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("error parsing authorization endpoint: %w", err)
+	}
+	u.RawQuery = rp.authParams(req).Encode()
+
+	return u.String(), nil
+}
+
+func (rp *RelyingParty) authParams(req *AuthRequest) url.Values {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", rp.config.ClientID)
+	params.Set("redirect_uri", rp.config.RedirectURI)
+	params.Set("scope", strings.Join(req.Scopes, " "))
+	params.Set("state", req.State)
+	params.Set("nonce", req.Nonce)
+	if req.CodeVerifier != "" {
+		params.Set("code_challenge", req.CodeChallenge())
+		params.Set("code_challenge_method", "S256")
+	}
+	if req.MaxAge > 0 {
+		params.Set("max_age", strconv.Itoa(req.MaxAge))
+	}
+	return params
+}
+
+// Authorize sends the authorization request to the provider using the token
+// of the Vault client to authenticate the end-user, as a browser would after
+// the user logs in to Vault, and returns the authorization code. The path of
+// the authorization endpoint is mapped from the UI to the Vault API.
+func (rp *RelyingParty) Authorize(ctx context.Context, req *AuthRequest) (string, error) {
+	discovery, err := rp.Discovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("error parsing authorization endpoint: %w", err)
+	}
+
+	r := rp.client.NewRequest(http.MethodGet, strings.Replace(u.Path, "/ui/vault/", "/v1/", 1))
+	r.Params = rp.authParams(req)
+
+	resp, err := rp.client.RawRequestWithContext(ctx, r)
+	if resp == nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var authResp struct {
+		Code             string `json:"code"`
+		State            string `json:"state"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if decodeErr := resp.DecodeJSON(&authResp); decodeErr != nil {
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("error decoding authorization response: %w", decodeErr)
+	}
+	if authResp.Error != "" {
+		return "", fmt.Errorf("authorization failed: %s: %s", authResp.Error, authResp.ErrorDescription)
+	}
+	if err != nil {
+		return "", err
+	}
+	if authResp.State != req.State {
+		return "", errors.New("authorization response state does not match the request")
+	}
+
+	return authResp.Code, nil
+}
+
+// Token is the result of a successful code exchange.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+
+	// Claims are the verified claims of the ID token.
+	Claims map[string]interface{} `json:"-"`
+}
+
+// Exchange exchanges the authorization code for tokens and verifies the ID
+// token against the request's nonce.
+func (rp *RelyingParty) Exchange(ctx context.Context, req *AuthRequest, code string) (*Token, error) {
+	discovery, err := rp.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", rp.config.RedirectURI)
+	if req.CodeVerifier != "" {
+		form.Set("code_verifier", req.CodeVerifier)
+	}
+	if rp.config.ClientSecret == "" {
+		form.Set("client_id", rp.config.ClientID)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if rp.config.ClientSecret != "" {
+		httpReq.SetBasicAuth(rp.config.ClientID, rp.config.ClientSecret)
+	}
+
+	var token Token
+	if err := rp.doJSON(httpReq, &token); err != nil {
+		return nil, fmt.Errorf("error exchanging code: %w", err)
+	}
+	if !strings.EqualFold(token.TokenType, "Bearer") {
+		return nil, fmt.Errorf("unexpected token type %q", token.TokenType)
+	}
+
+	claims, err := rp.VerifyIDToken(ctx, token.IDToken, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	token.Claims = claims
+
+	return &token, nil
+}
+
+// VerifyIDToken verifies the signature of the ID token against the
+// provider's keys and validates its standard claims, returning all of its
+// claims. The nonce claim is only checked if nonce is not empty.
+func (rp *RelyingParty) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (map[string]interface{}, error) {
+	discovery, err := rp.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jws, err := jose.ParseSigned(rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ID token: %w", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("ID token must have exactly one signature")
+	}
+	header := jws.Signatures[0].Header
+	if len(discovery.IDTokenAlgs) > 0 && !contains(discovery.IDTokenAlgs, header.Algorithm) {
+		return nil, fmt.Errorf("ID token signing algorithm %q is not supported by the provider", header.Algorithm)
+	}
+
+	key, err := rp.signingKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := jws.Verify(key)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying ID token signature: %w", err)
+	}
+
+	claims := make(map[string]interface{})
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("error decoding ID token claims: %w", err)
+	}
+
+	if err := rp.validateClaims(claims, discovery.Issuer, nonce); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// signingKey returns the key with the given ID, refreshing the cached key
+// set once if it is not found to allow for key rotation.
+func (rp *RelyingParty) signingKey(ctx context.Context, keyID string) (*jose.JSONWebKey, error) {
+	for _, refresh := range []bool{false, true} {
+		keys, err := rp.keySet(ctx, refresh)
+		if err != nil {
+			return nil, err
+		}
+		if found := keys.Key(keyID); len(found) > 0 {
+			return &found[0], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no key found with ID %q", keyID)
+}
+
+func (rp *RelyingParty) validateClaims(claims map[string]interface{}, issuer, nonce string) error {
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("ID token issuer %q does not match %q", iss, issuer)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []interface{}:
+		for _, v := range aud {
+			if s, ok := v.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !contains(audiences, rp.config.ClientID) {
+		return errors.New("ID token audience does not contain the client ID")
+	}
+	if len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != rp.config.ClientID {
+			return errors.New("ID token authorized party does not match the client ID")
+		}
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return errors.New("ID token is missing the sub claim")
+	}
+
+	now := time.Now()
+	exp, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if now.After(exp.Add(clockSkewLeeway)) {
+		return errors.New("ID token is expired")
+	}
+	iat, err := numericDate(claims, "iat")
+	if err != nil {
+		return err
+	}
+	if iat.After(now.Add(clockSkewLeeway)) {
+		return errors.New("ID token was issued in the future")
+	}
+
+	if nonce != "" {
+		if got, _ := claims["nonce"].(string); got != nonce {
+			return errors.New("ID token nonce does not match the request")
+		}
+	}
+
+	return nil
+}
+
+// UserInfo returns the claims from the provider's userinfo endpoint for the
+// given access token.
+func (rp *RelyingParty) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	discovery, err := rp.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+
+	claims := make(map[string]interface{})
+	if err := rp.doJSON(httpReq, &claims); err != nil {
+		return nil, fmt.Errorf("error requesting userinfo: %w", err)
+	}
+
+	return claims, nil
+}
+
+func (rp *RelyingParty) getJSON(ctx context.Context, url string, out interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return rp.doJSON(httpReq, out)
+}
+
+func (rp *RelyingParty) doJSON(httpReq *http.Request, out interface{}) error {
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := rp.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+func numericDate(claims map[string]interface{}, name string) (time.Time, error) {
+	raw, ok := claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("ID token is missing the %s claim", name)
+	}
+	seconds, err := raw.Int64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ID token %s claim is invalid: %w", name, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
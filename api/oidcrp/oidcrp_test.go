@@ -0,0 +1,222 @@
+package oidcrp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	testClientID     = "test-client-id"
+	testClientSecret = "test-client-secret"
+	testRedirectURI  = "https://127.0.0.1:8251/callback"
+	testCode         = "test-code"
+)
+
+// testProvider is a minimal OIDC provider served over TLS with a self-signed
+// certificate.
+type testProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	issuer string
+
+	nonce         string
+	codeChallenge string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &testProvider{t: t, key: key}
+	p.server = httptest.NewTLSServer(http.HandlerFunc(p.handle))
+	p.issuer = p.server.URL + "/v1/identity/oidc/provider/test"
+	return p
+}
+
+func (p *testProvider) handle(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/identity/oidc/provider/test/.well-known/openid-configuration":
+		p.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.issuer,
+			"jwks_uri":                              p.issuer + "/.well-known/keys",
+			"authorization_endpoint":                p.server.URL + "/ui/vault/identity/oidc/provider/test/authorize",
+			"token_endpoint":                        p.issuer + "/token",
+			"userinfo_endpoint":                     p.issuer + "/userinfo",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	case "/v1/identity/oidc/provider/test/.well-known/keys":
+		p.writeJSON(w, http.StatusOK, jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{Key: &p.key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"}},
+		})
+	case "/v1/identity/oidc/provider/test/authorize":
+		if r.Header.Get("X-Vault-Token") != "end-user-token" {
+			p.writeJSON(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURI ||
+			q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+			p.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error": "invalid_request", "error_description": "bad request", "state": q.Get("state"),
+			})
+			return
+		}
+		p.nonce = q.Get("nonce")
+		p.codeChallenge = q.Get("code_challenge")
+		p.writeJSON(w, http.StatusOK, map[string]interface{}{"code": testCode, "state": q.Get("state")})
+	case "/v1/identity/oidc/provider/test/token":
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != testClientID || secret != testClientSecret {
+			p.writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid_client"})
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			p.t.Fatal(err)
+		}
+		verifier := &AuthRequest{CodeVerifier: r.PostForm.Get("code_verifier")}
+		if r.PostForm.Get("code") != testCode || verifier.CodeChallenge() != p.codeChallenge {
+			p.writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid_grant"})
+			return
+		}
+		p.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     p.idToken(p.nonce),
+		})
+	case "/v1/identity/oidc/provider/test/userinfo":
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			p.writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid_token"})
+			return
+		}
+		p.writeJSON(w, http.StatusOK, map[string]interface{}{"sub": "entity-id", "username": "end-user"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (p *testProvider) idToken(nonce string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "test-key"))
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	payload, err := json.Marshal(map[string]interface{}{
+		"iss":   p.issuer,
+		"aud":   testClientID,
+		"sub":   "entity-id",
+		"iat":   now,
+		"exp":   now + 3600,
+		"nonce": nonce,
+	})
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return token
+}
+
+func (p *testProvider) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func TestRelyingParty_AuthCodeFlow(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.server.Close()
+
+	// Trust the provider's self-signed certificate via the client's TLS config
+	config := api.DefaultConfig()
+	config.Address = provider.server.URL
+	config.HttpClient = provider.server.Client()
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("end-user-token")
+
+	rp, err := New(client, Config{
+		Issuer:       provider.issuer,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURI:  testRedirectURI,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	req, err := NewAuthRequest("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := rp.AuthURL(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(authURL, "/ui/vault/identity/oidc/provider/test/authorize?") ||
+		!strings.Contains(authURL, "scope=openid+user") {
+		t.Fatalf("unexpected auth URL %q", authURL)
+	}
+
+	code, err := rp.Authorize(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := rp.Exchange(ctx, req, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Claims["sub"] != "entity-id" || token.Claims["nonce"] != req.Nonce {
+		t.Fatalf("unexpected claims: %#v", token.Claims)
+	}
+
+	userInfo, err := rp.UserInfo(ctx, token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userInfo["username"] != "end-user" {
+		t.Fatalf("unexpected userinfo: %#v", userInfo)
+	}
+
+	// The nonce must match the one from the request
+	if _, err := rp.VerifyIDToken(ctx, token.IDToken, "other-nonce"); err == nil {
+		t.Fatal("expected nonce mismatch error")
+	}
+
+	// Tokens signed by another key must be rejected
+	other := newTestProvider(t)
+	defer other.server.Close()
+	other.issuer = provider.issuer
+	if _, err := rp.VerifyIDToken(ctx, other.idToken(req.Nonce), req.Nonce); err == nil {
+		t.Fatal("expected signature verification error")
+	}
+}
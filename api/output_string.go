@@ -3,9 +3,12 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 )
 
 const (
@@ -93,3 +96,90 @@ func (d *OutputStringError) buildCurlString() (string, error) {
 
 	return fmt.Sprintf("%s%s", finalCurlString, d.Request.URL.String()), nil
 }
+
+// CurlString returns a curl command equivalent to the request. If redact is
+// true, the token is replaced with a command printing the token of the
+// local Vault CLI, and MFA credentials and authorization headers are
+// replaced with a placeholder. Streamed bodies are rendered as read from
+// stdin, since they can only be read once.
+func (r *Request) CurlString(redact bool) (string, error) {
+	// Render a copy without the streamed body, which would otherwise be
+	// consumed
+	rCopy := *r
+	rCopy.Body = nil
+	req, err := rCopy.toRetryableHTTP()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("curl")
+	if req.Method != http.MethodGet {
+		fmt.Fprintf(&b, " -X %s", req.Method)
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			switch {
+			case redact && k == http.CanonicalHeaderKey(consts.AuthHeaderName):
+				// Double quoted so that the shell runs the command
+				fmt.Fprintf(&b, ` -H "%s: $(vault print token)"`, k)
+				continue
+			case redact && (k == "X-Vault-Mfa" || k == "Authorization"):
+				v = RedactedValue
+			}
+			fmt.Fprintf(&b, " -H %s", shellQuote(k+": "+v))
+		}
+	}
+
+	switch {
+	case r.BodyBytes != nil:
+		fmt.Fprintf(&b, " -d %s", shellQuote(string(r.BodyBytes)))
+	case r.Body != nil:
+		b.WriteString(" --data-binary @-")
+	}
+
+	fmt.Fprintf(&b, " %s", shellQuote(req.URL.String()))
+	return b.String(), nil
+}
+
+// shellQuote quotes the given string with single quotes, escaping the single
+// quotes it contains.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "'\"'\"'", -1) + "'"
+}
+
+// CurlRecorder records the curl command equivalent to the last request sent
+// by the clients whose request hooks include its Hook method, e.g.
+//
+//	recorder := &api.CurlRecorder{}
+//	client.AddRequestHook(recorder.Hook)
+//
+// The commands are rendered from the redacted requests passed to hooks.
+type CurlRecorder struct {
+	l    sync.Mutex
+	last string
+	err  error
+}
+
+// Hook is a RequestHook recording the curl command of the request.
+func (c *CurlRecorder) Hook(r *Request) {
+	curl, err := r.CurlString(true)
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.last, c.err = curl, err
+}
+
+// Last returns the curl command of the last recorded request, or the error
+// rendering it.
+func (c *CurlRecorder) Last() (string, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.last, c.err
+}
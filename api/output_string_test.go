@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRequest_CurlString(t *testing.T) {
+	r := &Request{
+		Method:      http.MethodPost,
+		URL:         &url.URL{Scheme: "https", Host: "vault.example.com:8200", Path: "/v1/identity/oidc/provider/test/token"},
+		Params:      url.Values{"a": []string{"b c"}, "d": []string{"e&f"}},
+		Headers:     http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}},
+		ClientToken: "s.secret",
+		BodyBytes:   []byte("code=abc&redirect_uri=https%3A%2F%2Fexample.com"),
+	}
+
+	curl, err := r.CurlString(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `curl -X POST -H 'Content-Type: application/x-www-form-urlencoded' -H "X-Vault-Token: $(vault print token)" ` +
+		`-d 'code=abc&redirect_uri=https%3A%2F%2Fexample.com' 'https://vault.example.com:8200/v1/identity/oidc/provider/test/token?a=b+c&d=e%26f'`
+	if curl != expected {
+		t.Fatalf("unexpected curl string:\n%s\nexpected:\n%s", curl, expected)
+	}
+
+	curl, err = r.CurlString(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(curl, `-H 'X-Vault-Token: s.secret'`) {
+		t.Fatalf("expected unredacted token, got %s", curl)
+	}
+
+	// Multi-line JSON bodies with single quotes are quoted for the shell
+	r = &Request{
+		Method:    http.MethodPut,
+		URL:       &url.URL{Scheme: "http", Host: "127.0.0.1:8200", Path: "/v1/secret/foo"},
+		Params:    url.Values{},
+		BodyBytes: []byte("{\n  \"value\": \"it's\"\n}"),
+	}
+	curl, err = r.CurlString(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "curl -X PUT -d '{\n  \"value\": \"it'\"'\"'s\"\n}' 'http://127.0.0.1:8200/v1/secret/foo'"
+	if curl != expected {
+		t.Fatalf("unexpected curl string:\n%s\nexpected:\n%s", curl, expected)
+	}
+}
+
+func TestCurlRecorder(t *testing.T) {
+	config, ln := testHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"data": {}}`))
+	}))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("s.secret")
+
+	recorder := &CurlRecorder{}
+	client.AddRequestHook(recorder.Hook)
+
+	if _, err := client.Logical().Write("auth/userpass/users/test", map[string]interface{}{"password": "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	curl, err := recorder.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(curl, "s.secret") || strings.Contains(curl, "hunter2") {
+		t.Fatalf("expected recorded command to be redacted, got %s", curl)
+	}
+	if !strings.HasPrefix(curl, "curl -X PUT") || !strings.HasSuffix(curl, "/v1/auth/userpass/users/test'") {
+		t.Fatalf("unexpected recorded command %s", curl)
+	}
+}
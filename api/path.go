@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+)
+
+// EscapePathSegment percent-encodes a single segment of a Vault path, such
+// as the name of an entity or OIDC client, so that characters like "/", "%",
+// "?" and "#" are sent as part of the segment rather than interpreted by the
+// URL. Requests built by NewRequest decode the segment back on the server.
+func EscapePathSegment(segment string) string {
+	// Dot segments would otherwise be removed when the path is cleaned
+	if segment == "." || segment == ".." {
+		return strings.Replace(segment, ".", "%2E", -1)
+	}
+	return url.PathEscape(segment)
+}
+
+// PathJoin joins the given segments into a Vault path, escaping each of them
+// with EscapePathSegment, e.g.
+//
+//	api.PathJoin("identity", "entity", "name", name)
+//
+// The segments are not split on "/", so a segment containing one is sent as
+// a single path segment.
+func PathJoin(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = EscapePathSegment(segment)
+	}
+	return strings.Join(escaped, "/")
+}
+
+// setRequestPath sets the path of the URL to the given path, which may
+// contain segments escaped by EscapePathSegment. Paths which are not validly
+// escaped are used as-is.
+func setRequestPath(u *url.URL, p string) {
+	u.Path = p
+	if !strings.Contains(p, "%") {
+		return
+	}
+
+	unescaped, err := url.PathUnescape(p)
+	if err != nil {
+		return
+	}
+	u.Path = unescaped
+	u.RawPath = p
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPathJoin(t *testing.T) {
+	cases := map[string][]string{
+		"identity/entity/name/foo":          {"identity", "entity", "name", "foo"},
+		"identity/entity/name/a%2Fb":        {"identity", "entity", "name", "a/b"},
+		"identity/entity/name/what%3F%23":   {"identity", "entity", "name", "what?#"},
+		"identity/entity/name/50%25%20off":  {"identity", "entity", "name", "50% off"},
+		"identity/entity/name/%2E%2E":       {"identity", "entity", "name", ".."},
+		"identity/entity/name/%C3%BCnicode": {"identity", "entity", "name", "ünicode"},
+	}
+	for expected, segments := range cases {
+		if actual := PathJoin(segments...); actual != expected {
+			t.Errorf("PathJoin(%q) = %q, expected %q", segments, actual, expected)
+		}
+	}
+}
+
+func TestPathJoin_RoundTrip(t *testing.T) {
+	var l sync.Mutex
+	stored := make(map[string]json.RawMessage)
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		l.Lock()
+		defer l.Unlock()
+
+		// Store by the decoded path, as Vault does
+		name := strings.TrimPrefix(req.URL.Path, "/v1/identity/entity/name/")
+		switch req.Method {
+		case http.MethodPut:
+			var body json.RawMessage
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			stored[name] = body
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			data, ok := stored[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"data": ` + string(data) + `}`))
+		}
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"plain", "a/b", "a%2Fb", "50% off", "what?#", "..", "ünïcødé name", "trailing/"}
+	for _, name := range names {
+		_, err := client.Logical().Write(PathJoin("identity", "entity", "name", name), map[string]interface{}{"name": name})
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+	}
+	if len(stored) != len(names) {
+		t.Fatalf("expected %d distinct names to be stored, got %v", len(names), stored)
+	}
+
+	for _, name := range names {
+		secret, err := client.Logical().Read(PathJoin("identity", "entity", "name", name))
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if secret == nil || secret.Data["name"] != name {
+			t.Fatalf("%q: read back %#v", name, secret)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// proxyTransports holds the transports created by withProxy for the proxies
+// of a config and the configs cloned from it, including per-request proxies,
+// so that the clients and requests using the same proxy share a transport
+// and its connection pool.
+type proxyTransports struct {
+	l          sync.Mutex
+	transports map[proxyTransportKey]*http.Transport
+}
+
+// proxyTransportKey identifies a transport created by withProxy by the
+// transport it was cloned from and its proxy URL.
+type proxyTransportKey struct {
+	base     *http.Transport
+	proxyURL string
+}
+
+// withProxy returns a copy of the given HTTP client sending requests through
+// the given proxy, with the transport previously created for the same
+// transport and proxy if any. The client itself is returned if its transport
+// was created for the proxy. Nil proxy transports create one for every call.
+func (p *proxyTransports) withProxy(httpClient *http.Client, proxyURL string) (*http.Client, error) {
+	if p == nil {
+		return withProxy(httpClient, proxyURL)
+	}
+
+	base, _ := httpClient.Transport.(*http.Transport)
+	key := proxyTransportKey{base: base, proxyURL: proxyURL}
+
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	for k, transport := range p.transports {
+		if transport == base && k.proxyURL == proxyURL {
+			return httpClient, nil
+		}
+	}
+	if transport, ok := p.transports[key]; ok {
+		hc := *httpClient
+		hc.Transport = transport
+		return &hc, nil
+	}
+
+	hc, err := withProxy(httpClient, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if p.transports == nil {
+		p.transports = make(map[proxyTransportKey]*http.Transport)
+	}
+	p.transports[key] = hc.Transport.(*http.Transport)
+	return hc, nil
+}
+
+// parseProxyURL parses the URL of a proxy. Like the standard proxy
+// environment variables, URLs without a scheme use http.
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	if !strings.Contains(proxyURL, "://") {
+		proxyURL = "http://" + proxyURL
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: unsupported scheme %q, must be one of http, https or socks5", proxyURL, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxyURL)
+	}
+	return u, nil
+}
+
+// withProxy returns a copy of the given HTTP client with a clone of its
+// transport sending requests through the given proxy, or directly if the
+// proxy URL is empty.
+func withProxy(httpClient *http.Client, proxyURL string) (*http.Client, error) {
+	var u *url.URL
+	if proxyURL != "" {
+		var err error
+		u, err = parseProxyURL(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("cannot configure a proxy on a transport of type %T, it must be an *http.Transport", httpClient.Transport)
+	}
+
+	clone, err := cloneTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	clone.Proxy = func(req *http.Request) (*url.URL, error) {
+		// Requests to unix sockets are never proxied
+		if u == nil || req.URL.Hostname() == unixSocketHost {
+			return nil, nil
+		}
+		return u, nil
+	}
+
+	hc := *httpClient
+	hc.Transport = clone
+	return &hc, nil
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestProxy returns a plain HTTP proxy forwarding requests, and a pointer
+// to the number of requests it proxied.
+func newTestProxy(t *testing.T) (*httptest.Server, *int32) {
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+		outReq, err := http.NewRequest(req.Method, req.URL.String(), req.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		outReq.Header = req.Header.Clone()
+		resp, err := http.DefaultTransport.RoundTrip(outReq)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	return proxy, &proxied
+}
+
+func TestClient_ProxyURL(t *testing.T) {
+	config, ln := testHTTPServer(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"data": {}}`))
+	}))
+	defer ln.Close()
+
+	proxy, proxied := newTestProxy(t)
+	defer proxy.Close()
+
+	// The direct and proxied clients share the same custom transport, which
+	// must be left untouched
+	transport := &http.Transport{}
+	config.HttpClient = &http.Client{Transport: transport}
+	direct, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxiedConfig := direct.CloneConfig()
+	proxiedConfig.HttpClient = &http.Client{Transport: transport}
+	proxiedConfig.ProxyURL = proxy.URL
+	viaProxy, err := NewClient(proxiedConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.Proxy != nil {
+		t.Fatal("expected the custom transport to be left unmodified")
+	}
+
+	if _, err := direct.Logical().Read("secret/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(proxied); n != 0 {
+		t.Fatalf("expected no proxied requests, got %d", n)
+	}
+	if _, err := viaProxy.Logical().Read("secret/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(proxied); n != 1 {
+		t.Fatalf("expected 1 proxied request, got %d", n)
+	}
+
+	// Clones share the transport created for the proxy
+	clone, err := viaProxy.CloneWithOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.config.HttpClient.Transport != viaProxy.config.HttpClient.Transport {
+		t.Fatal("expected the clone to share the proxied transport")
+	}
+
+	// Per-request overrides, including going direct
+	if _, err := direct.Logical().ReadWithOptions(context.Background(), "secret/foo", WithProxy(proxy.URL)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := viaProxy.Logical().ReadWithOptions(context.Background(), "secret/foo", WithProxy("")); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(proxied); n != 2 {
+		t.Fatalf("expected 2 proxied requests, got %d", n)
+	}
+
+	// Repeated per-request overrides reuse the transports created for them
+	for i := 0; i < 3; i++ {
+		if _, err := direct.Logical().ReadWithOptions(context.Background(), "secret/foo", WithProxy(proxy.URL)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := viaProxy.Logical().ReadWithOptions(context.Background(), "secret/foo", WithProxy("")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(direct.config.proxyTransports.transports); n != 2 {
+		t.Fatalf("expected 2 proxy transports, got %d", n)
+	}
+}
+
+func TestClient_ProxyURLErrors(t *testing.T) {
+	for _, proxyURL := range []string{"ftp://proxy.example.com", "http://", "http://proxy.example.com:port"} {
+		config := DefaultConfig()
+		config.ProxyURL = proxyURL
+		if _, err := NewClient(config); err == nil || !strings.Contains(err.Error(), "invalid proxy URL") {
+			t.Fatalf("%s: expected invalid proxy URL error, got %v", proxyURL, err)
+		}
+	}
+
+	config := DefaultConfig()
+	config.HttpClient = &http.Client{Transport: http.NewFileTransport(http.Dir("."))}
+	config.ProxyURL = "socks5://127.0.0.1:1080"
+	if _, err := NewClient(config); err == nil {
+		t.Fatal("expected error configuring a proxy on an unsupported transport")
+	}
+
+	for _, proxyURL := range []string{"socks5://127.0.0.1:1080", "https://proxy.example.com", "proxy.example.com:3128"} {
+		config := DefaultConfig()
+		config.ProxyURL = proxyURL
+		if _, err := NewClient(config); err != nil {
+			t.Fatalf("%s: %v", proxyURL, err)
+		}
+	}
+
+	client, err := NewClient(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().ReadWithOptions(context.Background(), "secret/foo", WithProxy("ftp://proxy.example.com")); err == nil {
+		t.Fatal("expected request with invalid proxy option to fail")
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitStats are counters of the client-side rate limiting of a client
+// and of the rate limited responses it received.
+type RateLimitStats struct {
+	// LimitedRequests is the number of requests that had to wait for the
+	// client-side rate limiter.
+	LimitedRequests uint64
+
+	// LimiterWait is the total time requests waited for the client-side rate
+	// limiter.
+	LimiterWait time.Duration
+
+	// RateLimitedResponses is the number of 429 responses received, including
+	// those of attempts that were retried.
+	RateLimitedResponses uint64
+}
+
+type rateLimitStats struct {
+	limitedRequests      uint64
+	limiterWait          int64
+	rateLimitedResponses uint64
+}
+
+// RateLimitStats returns the rate limiting counters of the client. They are
+// shared with the clients returned by WithRequestOptions and
+// CloneWithOptions.
+func (c *Client) RateLimitStats() RateLimitStats {
+	if c.rateLimitStats == nil {
+		return RateLimitStats{}
+	}
+	return RateLimitStats{
+		LimitedRequests:      atomic.LoadUint64(&c.rateLimitStats.limitedRequests),
+		LimiterWait:          time.Duration(atomic.LoadInt64(&c.rateLimitStats.limiterWait)),
+		RateLimitedResponses: atomic.LoadUint64(&c.rateLimitStats.rateLimitedResponses),
+	}
+}
+
+// waitLimiter waits for the limiter to allow the given request, recording
+// the time waited on the request and in the stats of the client.
+func (c *Client) waitLimiter(ctx context.Context, limiter *rate.Limiter, r *Request) error {
+	if limiter.Allow() {
+		return nil
+	}
+
+	start := time.Now()
+	err := limiter.Wait(ctx)
+	r.LimiterWait = time.Since(start)
+	if c.rateLimitStats != nil {
+		atomic.AddUint64(&c.rateLimitStats.limitedRequests, 1)
+		atomic.AddInt64(&c.rateLimitStats.limiterWait, int64(r.LimiterWait))
+	}
+
+	// Don't send the request if the context is done or would expire before
+	// the limiter allows it. Limiters with a burst of zero never allow
+	// requests and have historically been ignored.
+	if err != nil && limiter.Burst() > 0 {
+		return err
+	}
+	return nil
+}
+
+// countRateLimited wraps the given retry policy to count the rate limited
+// responses.
+func (s *rateLimitStats) countRateLimited(checkRetry retryablehttp.CheckRetry) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if s != nil && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			atomic.AddUint64(&s.rateLimitedResponses, 1)
+		}
+		return checkRetry(ctx, resp, err)
+	}
+}
+
+// retryAfterBackoff wraps the given backoff so that rate limited responses
+// are retried after the delay of their Retry-After header, if any.
+func retryAfterBackoff(backoff retryablehttp.Backoff) retryablehttp.Backoff {
+	return func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				return wait
+			}
+		}
+		return backoff(min, max, attemptNum, resp)
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
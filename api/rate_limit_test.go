@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_RateLimiting(t *testing.T) {
+	var l sync.Mutex
+	var requests int
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		l.Lock()
+		requests++
+		n := requests
+		l.Unlock()
+
+		if req.URL.Path == "/v1/secret/throttled" && n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"errors": ["request path \"secret/throttled\": rate limit quota exceeded"]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {}}`))
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hookLock sync.Mutex
+	var retryWait time.Duration
+	var lastSent time.Time
+	client.AddRequestHook(func(r *Request) {
+		hookLock.Lock()
+		defer hookLock.Unlock()
+		if r.Attempt > 1 {
+			retryWait = time.Since(lastSent)
+		}
+		lastSent = time.Now()
+	})
+
+	// The retry of a rate limited request waits for the Retry-After delay
+	if _, err := client.Logical().Read("secret/throttled"); err != nil {
+		t.Fatal(err)
+	}
+	hookLock.Lock()
+	if retryWait < 900*time.Millisecond {
+		t.Fatalf("expected the retry to wait for the Retry-After delay, waited %s", retryWait)
+	}
+	hookLock.Unlock()
+	if stats := client.RateLimitStats(); stats.RateLimitedResponses != 1 {
+		t.Fatalf("unexpected stats %#v", stats)
+	}
+
+	// Concurrent requests through the limiter, including raw requests and
+	// requests of copies of the client, are all gated by it
+	client.SetLimiter(20, 1)
+	var limiterWaits []time.Duration
+	client.AddResponseHook(func(r *Request, _ *Response, _ error, _ time.Duration) {
+		hookLock.Lock()
+		defer hookLock.Unlock()
+		limiterWaits = append(limiterWaits, r.LimiterWait)
+	})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			switch i % 3 {
+			case 0:
+				_, err = client.Logical().Read("secret/foo")
+			case 1:
+				_, err = client.Logical().ReadWithOptions(context.Background(), "secret/foo", WithNamespace("ns1"))
+			default:
+				var resp *Response
+				resp, err = client.RawRequest(client.NewRequest(http.MethodGet, "/v1/secret/foo"))
+				if resp != nil {
+					resp.Body.Close()
+				}
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// At 20 requests per second with a burst of 1, 10 requests take at least
+	// 450ms
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("requests were not rate limited, took %s", elapsed)
+	}
+	stats := client.RateLimitStats()
+	if stats.LimitedRequests < 8 || stats.LimiterWait <= 0 {
+		t.Fatalf("unexpected stats %#v", stats)
+	}
+
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	var waited int
+	for _, wait := range limiterWaits {
+		if wait > 0 {
+			waited++
+		}
+	}
+	if waited < 8 {
+		t.Fatalf("expected limiter waits to be visible to hooks, got %v", limiterWaits)
+	}
+}
+
+// TestClient_RateLimitingAfterRedirect tests that the rate limited responses
+// of a request redirected by a standby are counted once
+func TestClient_RateLimitingAfterRedirect(t *testing.T) {
+	var l sync.Mutex
+	var requests int
+	active := func(w http.ResponseWriter, req *http.Request) {
+		l.Lock()
+		requests++
+		n := requests
+		l.Unlock()
+
+		if n == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"errors": ["rate limit quota exceeded"]}`))
+			return
+		}
+		w.Write([]byte(`{"data": {}}`))
+	}
+	config, ln := testHTTPServer(t, http.HandlerFunc(active))
+	defer ln.Close()
+
+	standby := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Location", config.Address+req.URL.Path)
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	}
+	config2, ln2 := testHTTPServer(t, http.HandlerFunc(standby))
+	defer ln2.Close()
+	config2.MinRetryWait = 10 * time.Millisecond
+	config2.MaxRetryWait = 10 * time.Millisecond
+
+	client, err := NewClient(config2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Logical().Read("secret/throttled"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := client.RateLimitStats(); stats.RateLimitedResponses != 1 {
+		t.Fatalf("unexpected stats %#v", stats)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if wait, ok := parseRetryAfter("3"); !ok || wait != 3*time.Second {
+		t.Fatalf("unexpected result %s %v", wait, ok)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if wait, ok := parseRetryAfter(date); !ok || wait <= 50*time.Second {
+		t.Fatalf("unexpected result %s %v", wait, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Fatal("expected invalid value to be rejected")
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ReauthError is returned by requests that failed because the token of the
+// client was no longer valid, when automatically re-authenticating failed.
+type ReauthError struct {
+	// Err is the error of the login with the auth method.
+	Err error
+
+	// RequestErr is the error of the original request.
+	RequestErr error
+}
+
+func (e *ReauthError) Error() string {
+	return fmt.Sprintf("unable to re-authenticate after permission denied error: %v", e.Err)
+}
+
+func (e *ReauthError) Unwrap() error {
+	return e.Err
+}
+
+// SetAutoReauth enables automatic re-authentication with the given auth
+// method, or disables it if the auth method is nil.
+//
+// When enabled, a request failing with a 403 response because the token of
+// the client has expired or been revoked is retried a single time after
+// logging in again with the auth method and swapping the token of the
+// client. Concurrent requests failing with the same token only cause a single
+// login. Requests sent with a token other than the one of the client, e.g.
+// with WithToken, and requests with a streamed body are never retried. If the
+// login fails, a *ReauthError is returned.
+func (c *Client) SetAutoReauth(authMethod AuthMethod) {
+	c.modifyLock.Lock()
+	defer c.modifyLock.Unlock()
+
+	if authMethod == nil {
+		c.autoReauth = nil
+		return
+	}
+	c.autoReauth = &autoReauth{
+		method: authMethod,
+		client: c,
+	}
+}
+
+type autoReauth struct {
+	method AuthMethod
+
+	// client is the client on which automatic re-authentication was enabled,
+	// whose token is swapped
+	client *Client
+
+	// l is held while re-authenticating so that concurrent requests wait for
+	// a single login
+	l sync.Mutex
+
+	// replacedToken is the token replaced by the last login, so that requests
+	// that failed with it are retried with the new token
+	replacedToken string
+}
+
+// retry re-authenticates and retries the given failed request if it failed
+// because its token is no longer valid, returning the response and error of
+// the retry, or the given response and error otherwise.
+func (a *autoReauth) retry(ctx context.Context, c *Client, r *Request, resp *Response, err error) (*Response, error) {
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusForbidden {
+		return resp, err
+	}
+
+	// Streamed bodies can't be sent again, and requests using another token
+	// than the client's aren't ours to re-authenticate
+	if (r.Body != nil && r.BodyBytes == nil) || r.ClientToken == "" {
+		return resp, err
+	}
+
+	token, reauthErr := a.reauthenticate(ctx, r.ClientToken, respErr)
+	switch {
+	case reauthErr != nil:
+		return resp, &ReauthError{Err: reauthErr, RequestErr: err}
+	case token == "":
+		return resp, err
+	}
+
+	if resp != nil {
+		resp.Body.Close()
+	}
+	r.ClientToken = token
+
+	// The retry is sent without re-authenticating again, however it fails
+	return c.sendRequestWithContext(ctx, r)
+}
+
+// reauthenticate logs in again if the given token is still the token of the
+// client and is no longer valid, and returns the new token. It returns the
+// current token of the client if another request already re-authenticated,
+// or an empty string if the given token is still valid or not the client's.
+func (a *autoReauth) reauthenticate(ctx context.Context, failedToken string, respErr *ResponseError) (string, error) {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	current := a.client.Token()
+	switch {
+	case current == "":
+		return "", nil
+	case current == failedToken:
+	case failedToken == a.replacedToken:
+		return current, nil
+	default:
+		return "", nil
+	}
+
+	// Vault returns permission denied for both invalid tokens and tokens
+	// lacking the required policies; only re-authenticate for the former
+	if !containsInvalidTokenError(respErr.Errors) {
+		lookupClient := a.client.WithRequestOptions(WithToken(failedToken))
+		lookupClient.autoReauth = nil
+		if _, err := lookupClient.Auth().Token().LookupSelfWithContext(ctx); err == nil {
+			return "", nil
+		}
+	}
+
+	loginClient := a.client.WithRequestOptions(WithToken(""))
+	loginClient.autoReauth = nil
+	secret, err := a.method.Login(ctx, loginClient)
+	if err != nil {
+		return "", err
+	}
+	token, err := secret.TokenID()
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("login response did not return a client token")
+	}
+
+	a.client.SetToken(token)
+	a.replacedToken = failedToken
+	return token, nil
+}
+
+func containsInvalidTokenError(errs []string) bool {
+	for _, err := range errs {
+		if strings.Contains(err, "invalid token") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type testAuthMethod struct {
+	err error
+}
+
+func (m *testAuthMethod) Login(ctx context.Context, client *Client) (*Secret, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return client.Logical().WriteWithContext(ctx, "auth/test/login", nil)
+}
+
+func TestClient_AutoReauth(t *testing.T) {
+	var logins int32
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		token := req.Header.Get("X-Vault-Token")
+		switch req.URL.Path {
+		case "/v1/auth/test/login":
+			atomic.AddInt32(&logins, 1)
+			w.Write([]byte(`{"auth": {"client_token": "new-token"}}`))
+			return
+		case "/v1/auth/token/lookup-self":
+			if token == "new-token" {
+				w.Write([]byte(`{"data": {"id": "new-token"}}`))
+				return
+			}
+		case "/v1/secret/foo":
+			if token == "new-token" {
+				w.Write([]byte(`{"data": {"foo": "bar"}}`))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors": ["permission denied"]}`))
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("expired-token")
+
+	// Disabled by default
+	if _, err := client.Logical().Read("secret/foo"); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	client.SetAutoReauth(&testAuthMethod{})
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Logical().Write("secret/foo", map[string]interface{}{"foo": "bar"})
+			errCh <- err
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if atomic.LoadInt32(&logins) != 1 {
+		t.Fatalf("expected a single login, got %d", logins)
+	}
+	if client.Token() != "new-token" {
+		t.Fatalf("expected the token to be swapped, got %q", client.Token())
+	}
+
+	// Permission denied errors for valid tokens are returned as-is
+	if _, err := client.Logical().Read("secret/denied"); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if atomic.LoadInt32(&logins) != 1 {
+		t.Fatalf("expected no login for a valid token, got %d", logins)
+	}
+
+	// Requests with another token are not retried
+	if _, err := client.Logical().ReadWithOptions(context.Background(), "secret/foo", WithToken("other")); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	// Failed logins are surfaced
+	loginErr := errors.New("login failed")
+	client.SetToken("expired-token")
+	client.SetAutoReauth(&testAuthMethod{err: loginErr})
+	_, err = client.Logical().Read("secret/foo")
+	var reauthErr *ReauthError
+	if !errors.As(err, &reauthErr) || !errors.Is(err, loginErr) || !errors.Is(reauthErr.RequestErr, ErrPermissionDenied) {
+		t.Fatalf("expected re-authentication error, got %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -216,3 +218,82 @@ func TestLifetimeWatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestLifetimeWatcher_Events(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := &ResponseError{StatusCode: http.StatusForbidden, Errors: []string{"permission denied"}}
+	renewedSecret := &Secret{LeaseDuration: 5, Renewable: true}
+
+	var l sync.Mutex
+	var events []*LifetimeWatcherEvent
+	v, err := client.NewLifetimeWatcher(&LifetimeWatcherInput{
+		Secret:      &Secret{LeaseDuration: 5},
+		RenewJitter: 0.5,
+		EventCallback: func(e *LifetimeWatcherEvent) {
+			l.Lock()
+			defer l.Unlock()
+			events = append(events, e)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	renew := func(_ string, _ int) (*Secret, error) {
+		calls++
+		switch calls {
+		case 1:
+			return renewedSecret, nil
+		case 2:
+			return nil, &ResponseError{StatusCode: http.StatusBadGateway}
+		default:
+			return nil, revoked
+		}
+	}
+
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- v.doRenewWithOptions(false, false, 5, "myleaseID", renew, 10*time.Millisecond)
+	}()
+	defer v.Stop()
+
+	select {
+	case <-time.After(15 * time.Second):
+		t.Fatal("watcher didn't finish")
+	case err := <-doneCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if len(events) < 3 {
+		t.Fatalf("expected at least 3 events, got %d", len(events))
+	}
+	if events[0].Type != LifetimeWatcherEventRenewed || events[0].Secret != renewedSecret || events[0].TTL != 5*time.Second {
+		t.Fatalf("unexpected renewed event %#v", events[0])
+	}
+
+	var renewErr *RenewError
+	if events[1].Type != LifetimeWatcherEventRenewFailed || !errors.As(events[1].Err, &renewErr) || renewErr.Permanent {
+		t.Fatalf("expected transient renew failure, got %#v", events[1])
+	}
+	if events[2].Type != LifetimeWatcherEventRenewFailed || !errors.As(events[2].Err, &renewErr) || !renewErr.Permanent {
+		t.Fatalf("expected permanent renew failure, got %#v", events[2])
+	}
+	if !errors.Is(events[2].Err, ErrPermissionDenied) {
+		t.Fatalf("expected renew error to wrap the response error, got %v", events[2].Err)
+	}
+
+	if _, err := client.NewLifetimeWatcher(&LifetimeWatcherInput{Secret: &Secret{}, RenewJitter: 2}); err == nil {
+		t.Fatal("expected error for out of range jitter")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/helper/consts"
 
@@ -38,6 +39,15 @@ type Request struct {
 	// EGPs). If set, the override flag will take effect for all policies
 	// evaluated during the request.
 	PolicyOverride bool
+
+	// Attempt is the number of the attempt, starting at 1, of the redacted
+	// copies of the request passed to hooks. Attempts greater than 1 are
+	// retries. It is not used when sending the request.
+	Attempt int
+
+	// LimiterWait is the time the request waited for the client-side rate
+	// limiter before being sent. It is not used when sending the request.
+	LimiterWait time.Duration
 }
 
 // SetJSONBody is used to set a request body that is a JSON-encoded value.
@@ -52,9 +62,10 @@ func (r *Request) SetJSONBody(val interface{}) error {
 	return nil
 }
 
-// ResetJSONBody is used to reset the body for a redirect
+// ResetJSONBody is used to reset the body for a redirect. Bodies that were
+// not set from a JSON-encoded value are sent again as they are.
 func (r *Request) ResetJSONBody() error {
-	if r.BodyBytes == nil {
+	if r.BodyBytes == nil || r.Obj == nil {
 		return nil
 	}
 	return r.SetJSONBody(r.Obj)
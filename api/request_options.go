@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/consts"
+)
+
+// RequestOption configures the calls made through a client returned by
+// WithRequestOptions, or through the WithOptions variants of the Logical and
+// Sys methods. Options never modify the client they are applied to, so a
+// single client may safely be shared by goroutines using different options.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	timeout   time.Duration
+	headers   http.Header
+	namespace *string
+	token     *string
+	mfaCreds  []string
+	proxyURL  *string
+	err       error
+}
+
+// WithRequestTimeout sets the timeout of the requests, overriding the
+// timeout of the client.
+func WithRequestTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithHeader adds the given header to the requests, in addition to the
+// headers of the client.
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) {
+		if o.headers == nil {
+			o.headers = make(http.Header)
+		}
+		o.headers.Add(key, value)
+	}
+}
+
+// WithNamespace sets the namespace of the requests. Passing an empty string
+// sends the requests without a namespace.
+func WithNamespace(namespace string) RequestOption {
+	return func(o *requestOptions) {
+		o.namespace = &namespace
+	}
+}
+
+// WithToken sets the token used to authenticate the requests.
+func WithToken(token string) RequestOption {
+	return func(o *requestOptions) {
+		o.token = &token
+	}
+}
+
+// WithMFA adds a credential for the given login MFA method to the next
+// request, formatting the X-Vault-MFA header as method[:passcode]. The
+// passcode may be empty for methods which do not require one. The option may
+// be given several times to supply credentials for several methods. Like
+// SetMFACredentials, the credentials are only sent with the first request so
+// that passcodes are never replayed.
+//
+// An invalid method name fails the requests made with the option.
+func WithMFA(method, passcode string) RequestOption {
+	return func(o *requestOptions) {
+		cred := MFACredential{Method: method, Passcode: passcode}
+		if err := cred.validate(); err != nil {
+			o.err = err
+			return
+		}
+		o.mfaCreds = append(o.mfaCreds, cred.String())
+	}
+}
+
+// WithProxy sends the requests through the given proxy, overriding the
+// proxy of the client, or directly if the proxy URL is empty. The http,
+// https and socks5 schemes are supported. A new transport is created for the
+// proxy, so clients sending many requests through it should be created with
+// CloneWithOptions rather than passing the option to each call.
+//
+// An invalid proxy URL fails the requests made with the option.
+func WithProxy(proxyURL string) RequestOption {
+	return func(o *requestOptions) {
+		o.proxyURL = &proxyURL
+	}
+}
+
+// WithRequestOptions makes a shallow copy of Client with the given options
+// applied and returns it. The client it is called on is left unmodified.
+func (c *Client) WithRequestOptions(opts ...RequestOption) *Client {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c.modifyLock.RLock()
+	c2 := &Client{
+		addr:                  c.addr,
+		unixSocket:            c.unixSocket,
+		config:                c.config,
+		token:                 c.token,
+		headers:               c.headers.Clone(),
+		wrappingLookupFunc:    c.wrappingLookupFunc,
+		mfaCreds:              c.mfaCreds,
+		policyOverride:        c.policyOverride,
+		requestCallbacks:      c.requestCallbacks,
+		responseCallbacks:     c.responseCallbacks,
+		replicationStateStore: c.replicationStateStore,
+		requestHooks:          c.requestHooks,
+		responseHooks:         c.responseHooks,
+		redactedFields:        c.redactedFields,
+		autoReauth:            c.autoReauth,
+		rateLimitStats:        c.rateLimitStats,
+		requestTimeout:        c.requestTimeout,
+		optionsErr:            c.optionsErr,
+	}
+	c.modifyLock.RUnlock()
+
+	if c2.headers == nil {
+		c2.headers = make(http.Header)
+	}
+	for k, v := range o.headers {
+		c2.headers[k] = append(c2.headers[k], v...)
+	}
+	switch {
+	case o.namespace == nil:
+	case *o.namespace == "":
+		c2.headers.Del(consts.NamespaceHeaderName)
+	default:
+		c2.setNamespace(*o.namespace)
+	}
+	if o.token != nil {
+		c2.token = *o.token
+	}
+	if o.timeout > 0 {
+		c2.requestTimeout = o.timeout
+	}
+	if o.mfaCreds != nil {
+		c2.pendingMFACreds = o.mfaCreds
+	}
+	if o.proxyURL != nil {
+		config := c.CloneConfig()
+		httpClient, err := config.proxyTransports.withProxy(config.HttpClient, *o.proxyURL)
+		if err != nil {
+			c2.optionsErr = err
+		} else {
+			config.HttpClient = httpClient
+			config.ProxyURL = *o.proxyURL
+			c2.config = config
+		}
+	}
+	if o.err != nil {
+		c2.optionsErr = o.err
+	}
+
+	return c2
+}
+
+// CloneWithOptions creates a new client with the same configuration, token,
+// headers and settings as this one, with the given options applied. Unlike
+// Clone, everything is copied regardless of the CloneHeaders and CloneToken
+// settings.
+//
+// The clone shares the HTTP client of this client, and so its transport and
+// connection pool, but is otherwise independent: either client may be
+// modified or used concurrently without affecting the other. Modifying the
+// shared HTTP client, e.g. with SetCheckRedirect, affects both.
+func (c *Client) CloneWithOptions(opts ...RequestOption) (*Client, error) {
+	clone, err := c.clone(true)
+	if err != nil {
+		return nil, err
+	}
+
+	c2 := c.WithRequestOptions(opts...)
+	// The options may have already given the copy its own configuration,
+	// e.g. for a proxy
+	if c2.config == c.config {
+		c2.config = clone.config
+	}
+	if c2.autoReauth != nil {
+		c2.autoReauth = &autoReauth{
+			method: c2.autoReauth.method,
+			client: c2,
+		}
+	}
+	return c2, nil
+}
+
+// WithOptions returns a copy of Logical whose calls use the given options.
+func (c *Logical) WithOptions(opts ...RequestOption) *Logical {
+	return &Logical{c: c.c.WithRequestOptions(opts...)}
+}
+
+func (c *Logical) ReadWithOptions(ctx context.Context, path string, opts ...RequestOption) (*Secret, error) {
+	return c.WithOptions(opts...).ReadWithContext(ctx, path)
+}
+
+func (c *Logical) ListWithOptions(ctx context.Context, path string, opts ...RequestOption) (*Secret, error) {
+	return c.WithOptions(opts...).ListWithContext(ctx, path)
+}
+
+func (c *Logical) WriteWithOptions(ctx context.Context, path string, data map[string]interface{}, opts ...RequestOption) (*Secret, error) {
+	return c.WithOptions(opts...).WriteWithContext(ctx, path, data)
+}
+
+func (c *Logical) DeleteWithOptions(ctx context.Context, path string, opts ...RequestOption) (*Secret, error) {
+	return c.WithOptions(opts...).DeleteWithContext(ctx, path)
+}
+
+// WithOptions returns a copy of Sys whose calls use the given options.
+func (c *Sys) WithOptions(opts ...RequestOption) *Sys {
+	return &Sys{c: c.c.WithRequestOptions(opts...)}
+}
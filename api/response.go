@@ -2,10 +2,12 @@ package api
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
@@ -50,6 +52,7 @@ func (r *Response) Error() error {
 		URL:           r.Request.URL.String(),
 		StatusCode:    r.StatusCode,
 		NamespacePath: ns,
+		Headers:       r.Header,
 	}
 
 	// Decode the error response if we can. Note that we wrap the bodyBuf
@@ -63,6 +66,7 @@ func (r *Response) Error() error {
 	} else {
 		// Store the decoded errors
 		respErr.Errors = resp.Errors
+		respErr.Warnings = resp.Warnings
 	}
 
 	return respErr
@@ -71,9 +75,36 @@ func (r *Response) Error() error {
 // ErrorResponse is the raw structure of errors when they're returned by the
 // HTTP API.
 type ErrorResponse struct {
-	Errors []string
+	Errors   []string
+	Warnings []string
 }
 
+var (
+	// ErrPermissionDenied is matched by response errors with a 403 status
+	// code.
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrNotFound is matched by response errors with a 404 status code.
+	ErrNotFound = errors.New("not found")
+
+	// ErrRateLimited is matched by response errors with a 429 status code,
+	// returned when a rate limit or lease count quota has been reached.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrMissingRequiredState is matched by response errors with a 412 status
+	// code, returned when the node has not yet caught up with the index state
+	// required by the request, e.g. on a performance standby or secondary.
+	ErrMissingRequiredState = errors.New("required index state not present")
+
+	// ErrSealed is matched by response errors returned because Vault is
+	// sealed.
+	ErrSealed = errors.New("Vault is sealed")
+
+	// ErrStandby is matched by response errors returned because the request
+	// reached a standby node and no active node could serve it.
+	ErrStandby = errors.New("Vault is in standby mode")
+)
+
 // ResponseError is the error returned when Vault responds with an error or
 // non-success HTTP status code. If a request to Vault fails because of a
 // network error a different error message will be returned. ResponseError gives
@@ -96,9 +127,16 @@ type ResponseError struct {
 	// Errors are the underlying errors returned by Vault.
 	Errors []string
 
+	// Warnings are the warnings returned by Vault along with the errors, if
+	// any.
+	Warnings []string
+
 	// Namespace path to be reported to the client if it is set to anything other
 	// than root
 	NamespacePath string
+
+	// Headers are the HTTP headers of the response.
+	Headers http.Header
 }
 
 // Error returns a human-readable error string for the response error.
@@ -131,3 +169,31 @@ func (r *ResponseError) Error() string {
 
 	return errBody.String()
 }
+
+// Unwrap returns the sentinel error matching the status code and errors of
+// the response, if any, so that it can be detected with errors.Is.
+func (r *ResponseError) Unwrap() error {
+	switch r.StatusCode {
+	case http.StatusForbidden:
+		return ErrPermissionDenied
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusPreconditionFailed:
+		return ErrMissingRequiredState
+	case http.StatusServiceUnavailable:
+		for _, err := range r.Errors {
+			switch {
+			case strings.Contains(err, consts.ErrSealed.Error()):
+				return ErrSealed
+			case strings.Contains(err, consts.ErrStandby.Error()),
+				strings.Contains(err, "node is not active"),
+				strings.Contains(err, "no active Vault instance found"):
+				return ErrStandby
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestResponseError_Unwrap(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		expected   error
+	}{
+		{"permission denied", http.StatusForbidden, `{"errors":["permission denied"]}`, ErrPermissionDenied},
+		{"not found", http.StatusNotFound, `{"errors":[]}`, ErrNotFound},
+		{"rate limited", http.StatusTooManyRequests, `{"errors":["request path \"kv/foo\": rate limit quota exceeded"]}`, ErrRateLimited},
+		{"missing state", http.StatusPreconditionFailed, `{"errors":["required index state not present"]}`, ErrMissingRequiredState},
+		{"sealed", http.StatusServiceUnavailable, `{"errors":["Vault is sealed"]}`, ErrSealed},
+		{"standby", http.StatusServiceUnavailable, `{"errors":["no active Vault instance found"]}`, ErrStandby},
+		{"unknown unavailable", http.StatusServiceUnavailable, `upstream connect error`, nil},
+		{"internal error", http.StatusInternalServerError, `{"errors":["internal error"],"warnings":["a warning"]}`, nil},
+	}
+
+	sentinels := []error{
+		ErrPermissionDenied, ErrNotFound, ErrRateLimited,
+		ErrMissingRequiredState, ErrSealed, ErrStandby,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}
+
+			config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+			defer ln.Close()
+			config.MaxRetries = 0
+
+			client, err := NewClient(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.Logical().Write("secret/foo", nil)
+			var respErr *ResponseError
+			if !errors.As(err, &respErr) || respErr.StatusCode != tt.statusCode {
+				t.Fatalf("expected response error with status %d, got %v", tt.statusCode, err)
+			}
+
+			for _, sentinel := range sentinels {
+				if errors.Is(err, sentinel) != (sentinel == tt.expected) {
+					t.Fatalf("unexpected errors.Is(err, %q) result for %v", sentinel, err)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/hashicorp/errwrap"
@@ -38,6 +39,11 @@ type Secret struct {
 	// cubbyhole of the given token (which has a TTL of the given number of
 	// seconds)
 	WrapInfo *SecretWrapInfo `json:"wrap_info,omitempty"`
+
+	// Headers are the HTTP headers of the response the secret was parsed
+	// from, e.g. X-Vault-Index or rate limit headers. They are only set for
+	// secrets returned by the Logical helpers.
+	Headers http.Header `json:"-"`
 }
 
 // TokenID returns the standardized token ID (token) for the given secret.
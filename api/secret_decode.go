@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/mitchellh/mapstructure"
+)
+
+// DecodeData decodes the data of the secret into target, which must be a
+// pointer, typically to a struct whose fields are named with mapstructure
+// tags. Values are converted the way Vault returns them:
+//
+//   - numbers, booleans and strings are weakly typed, e.g. "10" decodes into
+//     an int field and 10 into a string field
+//   - time.Duration fields accept seconds and duration strings such as "1h"
+//   - string slice fields accept comma-separated strings
+//   - time.Time fields accept RFC 3339 strings
+//
+// Fields missing from the data are left unmodified and data without a
+// matching field is ignored; use DecodeDataStrict to reject it. Decoding
+// errors name the offending fields.
+func (s *Secret) DecodeData(target interface{}) error {
+	return s.decodeData(target, false)
+}
+
+// DecodeDataStrict is the same as DecodeData but errors if the data
+// contains keys matching no field of the target, e.g. to catch typos in tags.
+func (s *Secret) DecodeDataStrict(target interface{}) error {
+	return s.decodeData(target, true)
+}
+
+func (s *Secret) decodeData(target interface{}, strict bool) error {
+	if s == nil {
+		return errors.New("cannot decode the data of a nil secret")
+	}
+
+	data := s.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			durationDecodeHook,
+			commaSeparatedDecodeHook,
+			mapstructure.StringToTimeHookFunc(time.RFC3339Nano),
+		),
+		ErrorUnused:      strict,
+		WeaklyTypedInput: true,
+		Result:           target,
+	})
+	if err != nil {
+		return fmt.Errorf("error setting up decoder for secret data: %w", err)
+	}
+	if err := d.Decode(data); err != nil {
+		return fmt.Errorf("error decoding secret data: %w", err)
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// durationDecodeHook decodes durations given in seconds or as duration
+// strings.
+func durationDecodeHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != durationType || from == durationType {
+		return data, nil
+	}
+	return parseutil.ParseDurationSecond(data)
+}
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// commaSeparatedDecodeHook decodes comma-separated strings into string
+// slices.
+func commaSeparatedDecodeHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || from == jsonNumberType ||
+		to.Kind() != reflect.Slice || to.Elem().Kind() != reflect.String {
+		return data, nil
+	}
+	return strutil.ParseStringSlice(reflect.ValueOf(data).String(), ","), nil
+}
@@ -0,0 +1,27 @@
+//go:build go1.21
+
+// Toolchains from Go 1.21 onwards allow this file to use type parameters
+// even though the module targets an older Go version; older toolchains skip
+// it.
+
+package api
+
+// DecodeSecretData decodes the data of the secret into a new value of type
+// T, as done by Secret.DecodeData.
+func DecodeSecretData[T any](secret *Secret) (*T, error) {
+	var target T
+	if err := secret.DecodeData(&target); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// DecodeSecretDataStrict decodes the data of the secret into a new value of
+// type T, as done by Secret.DecodeDataStrict.
+func DecodeSecretDataStrict[T any](secret *Secret) (*T, error) {
+	var target T
+	if err := secret.DecodeDataStrict(&target); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
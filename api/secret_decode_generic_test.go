@@ -0,0 +1,25 @@
+//go:build go1.21
+
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeSecretData(t *testing.T) {
+	secret := &Secret{Data: map[string]interface{}{"client_id": "test", "id_token_ttl": "60"}}
+
+	client, err := DecodeSecretData[testDecodedClient](secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.ClientID != "test" || client.IDTokenTTL != time.Minute {
+		t.Fatalf("unexpected client %#v", client)
+	}
+
+	secret.Data["unknown"] = true
+	if _, err := DecodeSecretDataStrict[testDecodedClient](secret); err == nil {
+		t.Fatal("expected error decoding unknown fields in strict mode")
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+type testDecodedClient struct {
+	ClientID     string            `mapstructure:"client_id"`
+	RedirectURIs []string          `mapstructure:"redirect_uris"`
+	Assignments  []string          `mapstructure:"assignments"`
+	IDTokenTTL   time.Duration     `mapstructure:"id_token_ttl"`
+	AccessTTL    time.Duration     `mapstructure:"access_token_ttl"`
+	Count        int               `mapstructure:"count"`
+	Enabled      bool              `mapstructure:"enabled"`
+	CreationTime time.Time         `mapstructure:"creation_time"`
+	Metadata     map[string]string `mapstructure:"metadata"`
+	Nested       struct {
+		Key string `mapstructure:"key"`
+	} `mapstructure:"nested"`
+}
+
+func TestSecret_DecodeData(t *testing.T) {
+	secret, err := ParseSecret(strings.NewReader(`{
+  "data": {
+    "client_id": "cLWHPcr0vLwPCsSn3FrrxIvjQFxpOl5M",
+    "redirect_uris": ["https://127.0.0.1:8251/callback"],
+    "assignments": "allow_all, other",
+    "id_token_ttl": 3600,
+    "access_token_ttl": "24h",
+    "count": "10",
+    "enabled": "true",
+    "creation_time": "2022-03-01T12:00:00.5Z",
+    "metadata": {"team": "security"},
+    "nested": {"key": "default"}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var client testDecodedClient
+	if err := secret.DecodeData(&client); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testDecodedClient{
+		ClientID:     "cLWHPcr0vLwPCsSn3FrrxIvjQFxpOl5M",
+		RedirectURIs: []string{"https://127.0.0.1:8251/callback"},
+		Assignments:  []string{"allow_all", "other"},
+		IDTokenTTL:   time.Hour,
+		AccessTTL:    24 * time.Hour,
+		Count:        10,
+		Enabled:      true,
+		CreationTime: time.Date(2022, 3, 1, 12, 0, 0, 500000000, time.UTC),
+		Metadata:     map[string]string{"team": "security"},
+	}
+	expected.Nested.Key = "default"
+	if diff := deep.Equal(client, expected); diff != nil {
+		t.Fatal(diff)
+	}
+	if !client.CreationTime.Equal(expected.CreationTime) {
+		t.Fatalf("unexpected creation time %v", client.CreationTime)
+	}
+
+	// Unknown fields are only rejected in strict mode
+	secret.Data["clientid"] = "typo"
+	if err := secret.DecodeData(&testDecodedClient{}); err != nil {
+		t.Fatal(err)
+	}
+	err = secret.DecodeDataStrict(&testDecodedClient{})
+	if err == nil || !strings.Contains(err.Error(), "clientid") {
+		t.Fatalf("expected error naming the unknown field, got %v", err)
+	}
+
+	// Errors name the offending field
+	secret.Data = map[string]interface{}{"count": "ten"}
+	err = secret.DecodeData(&testDecodedClient{})
+	if err == nil || !strings.Contains(err.Error(), "count") {
+		t.Fatalf("expected error naming the invalid field, got %v", err)
+	}
+
+	var nilSecret *Secret
+	if err := nilSecret.DecodeData(&testDecodedClient{}); err == nil {
+		t.Fatal("expected error decoding a nil secret")
+	}
+}
@@ -88,7 +88,13 @@ func (c *Sys) ListAuditWithContext(ctx context.Context) (map[string]*Audit, erro
 // DEPRECATED: Use EnableAuditWithOptions instead
 func (c *Sys) EnableAudit(
 	path string, auditType string, desc string, opts map[string]string) error {
-	return c.EnableAuditWithOptions(path, &EnableAuditOptions{
+	return c.EnableAuditWithContext(context.Background(), path, auditType, desc, opts)
+}
+
+// DEPRECATED: Use EnableAuditWithOptionsWithContext instead
+func (c *Sys) EnableAuditWithContext(ctx context.Context,
+	path string, auditType string, desc string, opts map[string]string) error {
+	return c.EnableAuditWithOptionsWithContext(ctx, path, &EnableAuditOptions{
 		Type:        auditType,
 		Description: desc,
 		Options:     opts,
@@ -42,9 +42,27 @@ func (c *Sys) ListAuthWithContext(ctx context.Context) (map[string]*AuthMount, e
 	return mounts, nil
 }
 
+// GetAuthMount returns the auth method mounted at the given path, which is
+// relative to the namespace of the client, with or without leading and
+// trailing slashes, e.g. "userpass". If the server does not support reading
+// a single mount, the mounts are listed instead. A *MountNotFoundError is
+// returned if no auth method is mounted at the path.
+func (c *Sys) GetAuthMount(path string) (*AuthMount, error) {
+	return c.GetAuthMountWithContext(context.Background(), path)
+}
+
+func (c *Sys) GetAuthMountWithContext(ctx context.Context, path string) (*AuthMount, error) {
+	return c.getMount(ctx, "sys/auth", path, true, c.ListAuthWithContext)
+}
+
 // DEPRECATED: Use EnableAuthWithOptions instead
 func (c *Sys) EnableAuth(path, authType, desc string) error {
-	return c.EnableAuthWithOptions(path, &EnableAuthOptions{
+	return c.EnableAuthWithContext(context.Background(), path, authType, desc)
+}
+
+// DEPRECATED: Use EnableAuthWithOptionsWithContext instead
+func (c *Sys) EnableAuthWithContext(ctx context.Context, path, authType, desc string) error {
+	return c.EnableAuthWithOptionsWithContext(ctx, path, &EnableAuthOptions{
 		Type:        authType,
 		Description: desc,
 	})
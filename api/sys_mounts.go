@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -43,6 +44,104 @@ func (c *Sys) ListMountsWithContext(ctx context.Context) (map[string]*MountOutpu
 	return mounts, nil
 }
 
+// MountNotFoundError is returned by GetMount and GetAuthMount when nothing is
+// mounted at the requested path. It matches ErrNotFound.
+type MountNotFoundError struct {
+	// Path is the normalized path of the mount, with a trailing slash.
+	Path string
+
+	// Auth is set for auth method mounts.
+	Auth bool
+}
+
+func (e *MountNotFoundError) Error() string {
+	if e.Auth {
+		return fmt.Sprintf("no auth method mounted at %q", e.Path)
+	}
+	return fmt.Sprintf("no secrets engine mounted at %q", e.Path)
+}
+
+func (e *MountNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// GetMount returns the secrets engine mounted at the given path, which is
+// relative to the namespace of the client, with or without leading and
+// trailing slashes. If the server does not support reading a single mount,
+// the mounts are listed instead. A *MountNotFoundError is returned if no
+// secrets engine is mounted at the path.
+func (c *Sys) GetMount(path string) (*MountOutput, error) {
+	return c.GetMountWithContext(context.Background(), path)
+}
+
+func (c *Sys) GetMountWithContext(ctx context.Context, path string) (*MountOutput, error) {
+	return c.getMount(ctx, "sys/mounts", path, false, c.ListMountsWithContext)
+}
+
+// getMount reads the mount at the given path under the given endpoint,
+// falling back to listing the mounts with the given function.
+func (c *Sys) getMount(ctx context.Context, endpoint, path string, auth bool, list func(context.Context) (map[string]*MountOutput, error)) (*MountOutput, error) {
+	path = strings.Trim(path, "/")
+	notFound := &MountNotFoundError{Path: path + "/", Auth: auth}
+	if path == "" {
+		return nil, notFound
+	}
+
+	readCtx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodGet, fmt.Sprintf("/v1/%s/%s", endpoint, path))
+
+	resp, err := c.c.rawRequestWithContext(readCtx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+
+	var respErr *ResponseError
+	switch {
+	case err == nil:
+	case errors.As(err, &respErr) && respErr.StatusCode == http.StatusBadRequest && isMountNotFound(respErr):
+		return nil, notFound
+	case errors.As(err, &respErr) && (respErr.StatusCode == http.StatusNotFound || respErr.StatusCode == http.StatusMethodNotAllowed):
+		// Older servers do not support reading a single mount
+		mounts, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if mount, ok := mounts[notFound.Path]; ok {
+			return mount, nil
+		}
+		return nil, notFound
+	default:
+		return nil, err
+	}
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result MountOutput
+	if err := mapstructure.Decode(secret.Data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// isMountNotFound returns whether the error is the one returned when reading
+// a missing mount.
+func isMountNotFound(respErr *ResponseError) bool {
+	for _, e := range respErr.Errors {
+		if strings.HasPrefix(e, "No secret engine mount at") || strings.HasPrefix(e, "No auth engine at") {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Sys) Mount(path string, mountInfo *MountInput) error {
 	return c.MountWithContext(context.Background(), path, mountInfo)
 }
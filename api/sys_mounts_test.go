@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSys_GetMount(t *testing.T) {
+	for _, singleRead := range []bool{true, false} {
+		var requests []string
+		mockVaultServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.Path)
+			switch {
+			case r.URL.Path == "/v1/sys/auth":
+				_, _ = w.Write([]byte(listAuthResponse))
+			case !singleRead:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				_, _ = w.Write([]byte(`{"errors": ["1 error occurred:\n\t* unsupported operation\n\n"]}`))
+			case r.URL.Path == "/v1/sys/auth/userpass":
+				_, _ = w.Write([]byte(readAuthResponse))
+			case r.URL.Path == "/v1/sys/mounts/missing":
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors": ["No secret engine mount at missing/"]}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors": ["No auth engine at missing/"]}`))
+			}
+		}))
+
+		cfg := DefaultConfig()
+		cfg.Address = mockVaultServer.URL
+		client, err := NewClient(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, path := range []string{"userpass", "userpass/", "/userpass/"} {
+			mount, err := client.Sys().GetAuthMount(path)
+			if err != nil {
+				t.Fatalf("single read %t, path %q: %v", singleRead, path, err)
+			}
+			if mount.Accessor != "auth_userpass_e2f2d5f3" || mount.Type != "userpass" {
+				t.Fatalf("single read %t, path %q: unexpected mount %#v", singleRead, path, mount)
+			}
+		}
+		// Each lookup falls back to listing the mounts after the failed read
+		expectedRequests := 3
+		if !singleRead {
+			expectedRequests = 6
+		}
+		if len(requests) != expectedRequests {
+			t.Fatalf("single read %t: unexpected requests %v", singleRead, requests)
+		}
+
+		_, err = client.Sys().GetAuthMount("missing")
+		var notFound *MountNotFoundError
+		if !errors.As(err, &notFound) || !notFound.Auth || notFound.Path != "missing/" || !errors.Is(err, ErrNotFound) {
+			t.Fatalf("single read %t: expected mount not found error, got %v", singleRead, err)
+		}
+
+		if singleRead {
+			_, err = client.Sys().GetMount("missing")
+			if !errors.As(err, &notFound) || notFound.Auth {
+				t.Fatalf("expected secrets engine not found error, got %v", err)
+			}
+		}
+
+		mockVaultServer.Close()
+	}
+}
+
+const readAuthResponse = `{
+  "data": {
+    "accessor": "auth_userpass_e2f2d5f3",
+    "config": {"default_lease_ttl": 0, "max_lease_ttl": 0, "force_no_cache": false, "token_type": "default-service"},
+    "description": "",
+    "external_entropy_access": false,
+    "local": false,
+    "options": null,
+    "seal_wrap": false,
+    "type": "userpass",
+    "uuid": "0d9b2bf4-1a6a-b7b1-f5ac-8c4c45fbf3ec"
+  }
+}`
+
+const listAuthResponse = `{
+  "data": {
+    "token/": {
+      "accessor": "auth_token_7d13d7c1",
+      "config": {"default_lease_ttl": 0, "max_lease_ttl": 0},
+      "type": "token"
+    },
+    "userpass/": {
+      "accessor": "auth_userpass_e2f2d5f3",
+      "config": {"default_lease_ttl": 0, "max_lease_ttl": 0},
+      "type": "userpass"
+    }
+  }
+}`
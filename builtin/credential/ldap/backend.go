@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	goldap "github.com/go-ldap/ldap/v3"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/ldaputil"
@@ -210,6 +211,125 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 	return entityAliasAttribute, policies, ldapResponse, allGroups, nil
 }
 
+// ResolveGroups returns the local and LDAP groups of the user without binding
+// as the user. The user is searched for with the configured BindDN, so
+// resolving groups requires either BindDN and BindPassword or DiscoverDN. A
+// user that the search doesn't find has no LDAP groups, while a search that
+// fails or finds several users returns an error response, so that the groups
+// of the user aren't taken as empty.
+func (b *backend) ResolveGroups(ctx context.Context, req *logical.Request, username string) ([]string, *logical.Response, error) {
+	cfg, err := b.Config(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg == nil {
+		return nil, logical.ErrorResponse("ldap backend not configured"), nil
+	}
+	if !cfg.DiscoverDN && (cfg.BindDN == "" || cfg.BindPassword == "") {
+		return nil, logical.ErrorResponse("resolving groups without a login requires binddn and bindpass, or discoverdn"), nil
+	}
+
+	ldapClient := ldaputil.Client{
+		Logger: b.Logger(),
+		LDAP:   ldaputil.NewLDAP(),
+	}
+
+	c, err := ldapClient.DialLDAP(cfg.ConfigEntry)
+	if err != nil {
+		return nil, logical.ErrorResponse(err.Error()), nil
+	}
+	if c == nil {
+		return nil, logical.ErrorResponse("invalid connection returned from LDAP dial"), nil
+	}
+
+	// Clean connection
+	defer c.Close()
+
+	// Bind as the BindDN up front, so that failing to search for the user
+	// means that it doesn't exist rather than that the server can't be used
+	if cfg.BindPassword != "" {
+		err = c.Bind(cfg.BindDN, cfg.BindPassword)
+	} else {
+		err = c.UnauthenticatedBind(cfg.BindDN)
+	}
+	if err != nil {
+		return nil, logical.ErrorResponse("ldap operation failed: failed to bind with the BindDN user"), nil
+	}
+
+	var ldapGroups []string
+	userBindDN, err := searchUserBindDN(&ldapClient, cfg.ConfigEntry, c, username)
+	switch {
+	case err != nil:
+		return nil, logical.ErrorResponse(err.Error()), nil
+	case userBindDN == "":
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("user not found in LDAP, resolving local groups only", "username", username)
+		}
+	default:
+		userDN, err := ldapClient.GetUserDN(cfg.ConfigEntry, c, userBindDN, username)
+		if err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+
+		if cfg.AnonymousGroupSearch {
+			c, err = ldapClient.DialLDAP(cfg.ConfigEntry)
+			if err != nil {
+				return nil, logical.ErrorResponse("ldap operation failed: failed to connect to LDAP server"), nil
+			}
+			defer c.Close()
+		}
+
+		ldapGroups, err = ldapClient.GetLdapGroups(cfg.ConfigEntry, c, userDN, username)
+		if err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	canonicalUsername := username
+	if !*cfg.CaseSensitiveNames {
+		canonicalUsername = strings.ToLower(username)
+	}
+
+	var allGroups []string
+	user, err := b.User(ctx, req.Storage, canonicalUsername)
+	if err == nil && user != nil && user.Groups != nil {
+		allGroups = append(allGroups, user.Groups...)
+	}
+	allGroups = append(allGroups, ldapGroups...)
+
+	return allGroups, nil, nil
+}
+
+// searchUserBindDN searches for the DN of the user as the BindDN that the
+// connection is bound as, as GetUserBindDN does when DiscoverDN is set. An
+// empty DN is returned if the search succeeds without finding the user.
+func searchUserBindDN(ldapClient *ldaputil.Client, cfg *ldaputil.ConfigEntry, c ldaputil.Connection, username string) (string, error) {
+	filter, err := ldapClient.RenderUserSearchFilter(cfg, username)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := c.Search(&goldap.SearchRequest{
+		BaseDN:     cfg.UserDN,
+		Scope:      goldap.ScopeWholeSubtree,
+		Filter:     filter,
+		SizeLimit:  2, // More than 1 result means the user isn't unique
+		Attributes: []string{cfg.UserAttr},
+	})
+	if err != nil {
+		return "", fmt.Errorf("LDAP search for binddn failed: %w", err)
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return "", nil
+	case 1:
+		return result.Entries[0].DN, nil
+	default:
+		return "", fmt.Errorf("LDAP search for binddn not unique")
+	}
+}
+
 const backendHelp = `
 The "ldap" credential provider allows authentication querying
 a LDAP server, checking username and password, and associating groups
@@ -743,6 +743,97 @@ func TestBackend_basic_nogroupdn(t *testing.T) {
 	})
 }
 
+// TestBackend_resolveGroups tests that the groups of users that the search
+// doesn't find are their local groups, and that failed searches are errors
+// rather than users without LDAP groups.
+func TestBackend_resolveGroups(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	cleanup, cfg := ldap.PrepareTestContainer(t, "latest")
+	defer cleanup()
+	ctx := context.Background()
+
+	writeConfig := func(data map[string]interface{}) {
+		t.Helper()
+
+		config := map[string]interface{}{
+			"url":                  cfg.Url,
+			"userattr":             cfg.UserAttr,
+			"userdn":               cfg.UserDN,
+			"groupdn":              cfg.GroupDN,
+			"groupattr":            cfg.GroupAttr,
+			"binddn":               cfg.BindDN,
+			"bindpass":             cfg.BindPassword,
+			"case_sensitive_names": true,
+			"request_timeout":      cfg.RequestTimeout,
+		}
+		for k, v := range data {
+			config[k] = v
+		}
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Data:      config,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+		}
+	}
+	writeConfig(nil)
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "users/nobody",
+		Data: map[string]interface{}{
+			"groups": "local",
+		},
+		Storage: storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+
+	req := &logical.Request{Storage: storage}
+
+	// A user found by the search has its LDAP groups
+	groups, resp, err := b.ResolveGroups(ctx, req, "hermes conrad")
+	if err != nil || resp != nil {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	if len(groups) == 0 {
+		t.Fatal("expected the LDAP groups of the user")
+	}
+
+	// A user that isn't in LDAP only has its local groups
+	groups, resp, err = b.ResolveGroups(ctx, req, "nobody")
+	if err != nil || resp != nil {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	if !reflect.DeepEqual(groups, []string{"local"}) {
+		t.Fatalf("expected the local groups of the user, got %v", groups)
+	}
+
+	// Searches that fail or find several users are errors
+	for name, data := range map[string]map[string]interface{}{
+		"failed search": {
+			"userdn": "ou=missing,dc=planetexpress,dc=com",
+		},
+		"not unique": {
+			"userfilter": "(objectClass=inetOrgPerson)",
+		},
+	} {
+		writeConfig(data)
+		groups, resp, err = b.ResolveGroups(ctx, req, "nobody")
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected an error response, got groups: %v\nresp: %#v\nerr: %v", name, groups, resp, err)
+		}
+		writeConfig(map[string]interface{}{
+			"userdn":     cfg.UserDN,
+			"userfilter": cfg.UserFilter,
+		})
+	}
+}
+
 func TestBackend_groupCrud(t *testing.T) {
 	b := factory(t)
 
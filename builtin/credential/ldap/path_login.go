@@ -26,8 +26,9 @@ func pathLogin(b *backend) *framework.Path {
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation:         b.pathLogin,
-			logical.AliasLookaheadOperation: b.pathLoginAliasLookahead,
+			logical.UpdateOperation:              b.pathLogin,
+			logical.AliasLookaheadOperation:      b.pathLoginAliasLookahead,
+			logical.ResolveGroupAliasesOperation: b.pathLoginResolveGroupAliases,
 		},
 
 		HelpSynopsis:    pathLoginSyn,
@@ -50,6 +51,46 @@ func (b *backend) pathLoginAliasLookahead(ctx context.Context, req *logical.Requ
 	}, nil
 }
 
+// pathLoginResolveGroupAliases resolves the group aliases of an entity alias
+// of the mount, so that the identity store can sync the memberships of
+// external groups without a login.
+func (b *backend) pathLoginResolveGroupAliases(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// The alias name is the username unless the alias is based on another
+	// attribute, in which case logins record the username in its metadata
+	username := d.Get("username").(string)
+	switch metadata := req.Data["alias_metadata"].(type) {
+	case map[string]string:
+		if metadata["name"] != "" {
+			username = metadata["name"]
+		}
+	case map[string]interface{}:
+		if name, ok := metadata["name"].(string); ok && name != "" {
+			username = name
+		}
+	}
+	if username == "" {
+		return nil, fmt.Errorf("missing username")
+	}
+
+	groupNames, resp, err := b.ResolveGroups(ctx, req, username)
+	if err != nil || resp != nil {
+		return resp, err
+	}
+
+	resp = &logical.Response{
+		Auth: &logical.Auth{},
+	}
+	for _, groupName := range groupNames {
+		if groupName == "" {
+			continue
+		}
+		resp.Auth.GroupAliases = append(resp.Auth.GroupAliases, &logical.Alias{
+			Name: groupName,
+		})
+	}
+	return resp, nil
+}
+
 func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	cfg, err := b.Config(ctx, req)
 	if err != nil {
@@ -2,8 +2,10 @@ package userpass
 
 import (
 	"context"
+	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -16,7 +18,10 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 }
 
 func Backend() *backend {
-	var b backend
+	b := backend{
+		passwordAttempts: newPasswordAttempts(),
+		userLocks:        locksutil.CreateLocks(),
+	}
 	b.Backend = &framework.Backend{
 		Help: backendHelp,
 
@@ -29,9 +34,12 @@ func Backend() *backend {
 		Paths: []*framework.Path{
 			pathUsers(&b),
 			pathUsersList(&b),
+			pathUsersImport(&b),
+			pathUsersBatch(&b),
 			pathUserPolicies(&b),
 			pathUserPassword(&b),
 			pathLogin(&b),
+			pathConfig(&b),
 		},
 
 		AuthRenew:   b.pathLoginRenew,
@@ -43,6 +51,18 @@ func Backend() *backend {
 
 type backend struct {
 	*framework.Backend
+
+	// passwordAttempts tracks the failed attempts at the current password
+	// of password updates
+	passwordAttempts *passwordAttempts
+
+	// userLocks serialize the writes to each user entry, so that a write
+	// doesn't overwrite the changes made since its entry was read
+	userLocks []*locksutil.LockEntry
+}
+
+func (b *backend) userLock(username string) *locksutil.LockEntry {
+	return locksutil.LockForKey(b.userLocks, strings.ToLower(username))
 }
 
 const backendHelp = `
@@ -3,18 +3,23 @@ package userpass
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-test/deep"
 	sockaddr "github.com/hashicorp/go-sockaddr"
+	"github.com/hashicorp/vault/helper/namespace"
 	logicaltest "github.com/hashicorp/vault/helper/testhelpers/logical"
 	"github.com/hashicorp/vault/sdk/helper/policyutil"
 	"github.com/hashicorp/vault/sdk/helper/tokenutil"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
 	"github.com/mitchellh/mapstructure"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -412,3 +417,1097 @@ func TestBackend_UserUpgrade(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestBackend_passwordHashAlgorithm(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a user while the default bcrypt algorithm is configured
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "testpassword",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// Weak argon2id parameters must be rejected
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password_hash_algorithm": "argon2id",
+			"argon2_memory":           8192,
+			"argon2_iterations":       1,
+			"argon2_parallelism":      1,
+		},
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected weak argon2id parameters to be rejected: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password_hash_algorithm": "argon2id",
+			"argon2_memory":           19456,
+			"argon2_iterations":       2,
+			"argon2_parallelism":      1,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	user, err := b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(user.PasswordHash), "$2a$") {
+		t.Fatalf("expected bcrypt hash, got %q", user.PasswordHash)
+	}
+
+	// A failed login must not migrate the hash
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "login/testuser",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "wrongpassword",
+		},
+		Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected login failure: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// Nor must a login with the right password that is rejected afterwards,
+	// here for coming from outside the bound CIDR blocks of the user
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"token_bound_cidrs": "127.0.0.1/32",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "login/testuser",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "testpassword",
+		},
+		Connection: &logical.Connection{RemoteAddr: "10.0.0.1"},
+	})
+	if err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+	user, err = b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(user.PasswordHash), "$2a$") {
+		t.Fatalf("expected the rejected login to keep the bcrypt hash, got %q", user.PasswordHash)
+	}
+
+	// A successful login migrates the bcrypt hash to argon2id
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "login/testuser",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "testpassword",
+		},
+		Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+	})
+	if err != nil || resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	user, err = b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(user.PasswordHash), "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Fatalf("expected argon2id hash, got %q", user.PasswordHash)
+	}
+
+	// Logging in with the migrated hash continues to work
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "login/testuser",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "testpassword",
+		},
+		Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+	})
+	if err != nil || resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// A re-hash doesn't undo the writes made to the user while the login
+	// was verifying the password it read
+	stale, err := b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password":       "newpassword",
+			"token_policies": "changed",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// The configured parameters change, so that both passwords need a
+	// re-hash
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"argon2_iterations": 3,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if err := b.rehashPasswordIfNeeded(ctx, &logical.Request{Storage: storage}, "testuser", stale, "testpassword", false); err != nil {
+		t.Fatal(err)
+	}
+	user, err = b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.passwordMatches("newpassword") || user.passwordMatches("testpassword") {
+		t.Fatalf("expected the password reset to be kept, got %q", user.PasswordHash)
+	}
+	if !reflect.DeepEqual(user.TokenPolicies, []string{"changed"}) {
+		t.Fatalf("expected the policy change to be kept, got %v", user.TokenPolicies)
+	}
+
+	// The hash is replaced while it's still the one that was verified
+	if err := b.rehashPasswordIfNeeded(ctx, &logical.Request{Storage: storage}, "testuser", user, "newpassword", false); err != nil {
+		t.Fatal(err)
+	}
+	user, err = b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(user.PasswordHash), "$argon2id$v=19$m=19456,t=3,p=1$") || !user.passwordMatches("newpassword") {
+		t.Fatalf("expected the password to be re-hashed, got %q", user.PasswordHash)
+	}
+	if !reflect.DeepEqual(user.TokenPolicies, []string{"changed"}) {
+		t.Fatalf("expected the policies to be kept, got %v", user.TokenPolicies)
+	}
+}
+
+func TestBackend_userListPagination(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, username := range []string{"carol", "alice", "bob", "alex", "dave"} {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Path:      "users/" + username,
+			Operation: logical.CreateOperation,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password":       "password",
+				"token_policies": []string{"foo"},
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+		}
+	}
+
+	list := func(data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Path:      "users/",
+			Operation: logical.ListOperation,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		expected []string
+	}{
+		{"first page", map[string]interface{}{"limit": 2}, []string{"alex", "alice"}},
+		{"second page", map[string]interface{}{"after": "alice", "limit": 2}, []string{"bob", "carol"}},
+		{"last page", map[string]interface{}{"after": "carol", "limit": 2}, []string{"dave"}},
+		{"past the end", map[string]interface{}{"after": "dave", "limit": 2}, nil},
+		{"filter", map[string]interface{}{"filter": "al"}, []string{"alex", "alice"}},
+		{"filter and after", map[string]interface{}{"filter": "al", "after": "alex"}, []string{"alice"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := list(tc.data)
+			keys, _ := resp.Data["keys"].([]string)
+			if diff := deep.Equal(keys, tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	resp := list(map[string]interface{}{"detailed": true, "filter": "bob"})
+	keyInfo, ok := resp.Data["key_info"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected key_info in response: %#v", resp.Data)
+	}
+	info, ok := keyInfo["bob"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected key_info for bob: %#v", keyInfo)
+	}
+	if diff := deep.Equal(info["token_policies"], []string{"foo"}); diff != nil {
+		t.Fatal(diff)
+	}
+	if _, ok := info["password_last_changed"]; !ok {
+		t.Fatalf("expected password_last_changed in key_info: %#v", info)
+	}
+}
+
+func TestBackend_passwordUpdateScoped(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password":       "testpassword",
+			"token_policies": []string{"foo"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	updatePassword := func(data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Path:      "users/testuser/password",
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	// Attempts to modify other fields must fail
+	resp, err = updatePassword(map[string]interface{}{
+		"password":       "newpassword",
+		"token_policies": []string{"root"},
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unsupported parameters: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"require_current_password": true,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// The current password is now required
+	resp, err = updatePassword(map[string]interface{}{
+		"password": "newpassword",
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for missing current_password: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// Repeatedly failing to provide the current password backs off
+	for n := 0; n < currentPasswordFreeAttempts; n++ {
+		resp, err = updatePassword(map[string]interface{}{
+			"password":         "newpassword",
+			"current_password": "wrongpassword",
+		})
+		if err != logical.ErrPermissionDenied || resp == nil || resp.Error().Error() != errCurrentPassword {
+			t.Fatalf("expected error for incorrect current_password: resp: %#v\nerr: %v\n", resp, err)
+		}
+	}
+
+	// The right password is refused with the same error while backing off
+	resp, err = updatePassword(map[string]interface{}{
+		"password":         "newpassword",
+		"current_password": "testpassword",
+	})
+	if err != logical.ErrPermissionDenied || resp == nil || resp.Error().Error() != errCurrentPassword {
+		t.Fatalf("expected error while backing off: resp: %#v\nerr: %v\n", resp, err)
+	}
+	failures := b.passwordAttempts.failures["testuser"]
+	if failures == nil || failures.count != currentPasswordFreeAttempts+1 {
+		t.Fatalf("expected %d failed attempts, got %#v", currentPasswordFreeAttempts+1, failures)
+	}
+	if backoff := failures.notBefore.Sub(failures.last); backoff != 2*currentPasswordBackoff {
+		t.Fatalf("expected the backoff to double, got %s", backoff)
+	}
+
+	// Once the backoff has elapsed, the right password is accepted
+	failures.notBefore = time.Now().Add(-time.Second)
+	resp, err = updatePassword(map[string]interface{}{
+		"password":         "newpassword",
+		"current_password": "testpassword",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if _, ok := b.passwordAttempts.failures["testuser"]; ok {
+		t.Fatal("expected the failed attempts to be forgotten after a success")
+	}
+
+	user, err := b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.passwordMatches("newpassword") {
+		t.Fatal("expected password to be updated")
+	}
+	if diff := deep.Equal(user.TokenPolicies, []string{"foo"}); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestBackend_userImportPasswordHash(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("importedpassword"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakHash, err := bcrypt.GenerateFromPassword([]byte("importedpassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// password and password_hash are mutually exclusive
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/single",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password":      "testpassword",
+			"password_hash": string(hash),
+		},
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/single",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password_hash": string(hash),
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users-import",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{
+					"username":       "alice",
+					"password_hash":  string(hash),
+					"token_policies": []string{"foo"},
+				},
+				map[string]interface{}{
+					"username":      "bob",
+					"password_hash": string(weakHash),
+				},
+				map[string]interface{}{
+					"username": "carol",
+					"password": "plaintext",
+				},
+				map[string]interface{}{
+					"username":      "dave",
+					"password_hash": "not-a-hash",
+				},
+			},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if resp.Data["succeeded"].(int) != 1 || resp.Data["failed"].(int) != 3 {
+		t.Fatalf("unexpected result counts: %#v", resp.Data)
+	}
+	results := resp.Data["results"].([]map[string]interface{})
+	if _, ok := results[0]["error"]; ok {
+		t.Fatalf("expected alice to be imported: %#v", results[0])
+	}
+	for _, result := range results[1:] {
+		if _, ok := result["error"]; !ok {
+			t.Fatalf("expected error for %q: %#v", result["username"], result)
+		}
+	}
+
+	for _, username := range []string{"single", "alice"} {
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Path:      "login/" + username,
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password": "importedpassword",
+			},
+			Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+		})
+		if err != nil || resp == nil || resp.IsError() || resp.Auth == nil {
+			t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+		}
+
+		// The hash must never be returned
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Path:      "users/" + username,
+			Operation: logical.ReadOperation,
+			Storage:   storage,
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+		}
+		if _, ok := resp.Data["password_hash"]; ok {
+			t.Fatalf("password_hash returned in read response")
+		}
+	}
+}
+
+// Usernames are normalized to lowercase on write and login so that users and
+// their entity aliases are stable regardless of the case used.
+func TestBackend_usernameCaseInsensitive(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/JSmith",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "testpassword",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	keys, err := storage.List(ctx, "user/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(keys, []string{"jsmith"}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	for _, username := range []string{"JSmith", "jsmith", "JSMITH"} {
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Path:      "login/" + username,
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password": "testpassword",
+			},
+			Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+		})
+		if err != nil || resp == nil || resp.IsError() || resp.Auth == nil {
+			t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+		}
+		if resp.Auth.Alias.Name != "jsmith" {
+			t.Fatalf("expected normalized alias name, got %q", resp.Auth.Alias.Name)
+		}
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Path:      "login/" + username,
+			Operation: logical.AliasLookaheadOperation,
+			Storage:   storage,
+		})
+		if err != nil || resp == nil || resp.Auth == nil {
+			t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+		}
+		if resp.Auth.Alias.Name != "jsmith" {
+			t.Fatalf("expected normalized lookahead alias name, got %q", resp.Auth.Alias.Name)
+		}
+	}
+}
+
+// revokingSystemView records the login paths whose tokens are revoked
+type revokingSystemView struct {
+	*logical.StaticSystemView
+	revoked []string
+}
+
+func (s *revokingSystemView) RevokeLoginTokens(_ context.Context, loginPath string) error {
+	s.revoked = append(s.revoked, loginPath)
+	return nil
+}
+
+func TestBackend_userDisable(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	sysView := &revokingSystemView{StaticSystemView: logical.TestSystemView()}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	config.System = sysView
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	writeUser := func(op logical.Operation, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Path:      "users/test",
+			Operation: op,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	login := func(password string) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Path:      "login/test",
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password": password,
+			},
+			Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+		})
+	}
+	renew := func(issueTime time.Time) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Path:      "login",
+			Operation: logical.RenewOperation,
+			Storage:   storage,
+			Auth: &logical.Auth{
+				LeaseOptions: logical.LeaseOptions{
+					IssueTime: issueTime,
+				},
+				Metadata: map[string]string{
+					"username": "test",
+				},
+				TokenPolicies: []string{"default", "foo"},
+			},
+		})
+	}
+
+	resp, err := writeUser(logical.CreateOperation, map[string]interface{}{
+		"password":       "testpassword",
+		"token_policies": "foo",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = writeUser(logical.UpdateOperation, map[string]interface{}{
+		"revoke_tokens": true,
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error when revoking tokens of an enabled user: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if len(sysView.revoked) != 0 {
+		t.Fatalf("expected no tokens to be revoked, got %v", sysView.revoked)
+	}
+
+	issueTime := time.Now().Add(-time.Minute)
+	resp, err = writeUser(logical.UpdateOperation, map[string]interface{}{
+		"enabled":       false,
+		"revoke_tokens": true,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if diff := deep.Equal(sysView.revoked, []string{"login/test"}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	resp, err = writeUser(logical.ReadOperation, nil)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if resp.Data["enabled"].(bool) {
+		t.Fatal("expected user to be disabled")
+	}
+
+	// A wrong password must not reveal that the user is disabled
+	resp, err = login("wrongpassword")
+	if err != nil || resp == nil || resp.Error().Error() != "invalid username or password" {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = login("testpassword")
+	if err != logical.ErrPermissionDenied || resp == nil || resp.Error().Error() != "user is disabled" {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	if _, err := renew(time.Now()); err == nil {
+		t.Fatal("expected renewal of a disabled user's token to fail")
+	}
+
+	// Re-enabling restores login with the existing password and policies
+	resp, err = writeUser(logical.UpdateOperation, map[string]interface{}{
+		"enabled": true,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = login("testpassword")
+	if err != nil || resp == nil || resp.IsError() || resp.Auth == nil {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if diff := deep.Equal(resp.Auth.Policies, []string{"foo"}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// Tokens issued before the user was disabled remain unrenewable
+	if _, err := renew(issueTime); err == nil {
+		t.Fatal("expected renewal of a token issued before revocation to fail")
+	}
+	resp, err = renew(time.Now())
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+}
+
+func TestBackend_passwordHistory(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password_history_depth": maxPasswordHistoryDepth + 1,
+		},
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password_history_depth": 3,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.ReadOperation,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if depth := resp.Data["password_history_depth"]; depth != 3 {
+		t.Fatalf("expected password_history_depth 3, got %v", depth)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/test",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "password1",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	type testCase struct {
+		path     string
+		password string
+		reused   bool
+	}
+
+	// Alternate between the admin and self-service endpoints
+	cases := []testCase{
+		{"users/test/password", "password1", true},
+		{"users/test", "password2", false},
+		{"users/test/password", "password3", false},
+		{"users/test", "password1", true},
+		{"users/test/password", "password2", true},
+		{"users/test", "password4", false},
+		{"users/test/password", "password1", false},
+	}
+
+	for _, tc := range cases {
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Path:      tc.path,
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password": tc.password,
+			},
+		})
+		if tc.reused {
+			if err != logical.ErrInvalidRequest || resp == nil || resp.Error().Error() != "password was used recently" {
+				t.Fatalf("expected %q to be rejected on %s: resp: %#v\nerr: %v\n", tc.password, tc.path, resp, err)
+			}
+			continue
+		}
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: %s: resp: %#v\nerr: %v\n", tc.path, resp, err)
+		}
+	}
+
+	user, err := b.user(ctx, storage, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.PasswordHistory) != 2 {
+		t.Fatalf("expected history to be pruned to 2 entries, got %d", len(user.PasswordHistory))
+	}
+	for _, hash := range user.PasswordHistory {
+		if strings.Contains(string(hash), "password") {
+			t.Fatalf("expected history to contain only hashes")
+		}
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/test",
+		Operation: logical.ReadOperation,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	for k := range resp.Data {
+		if strings.Contains(k, "history") || strings.Contains(k, "hash") {
+			t.Fatalf("unexpected field %q in user read", k)
+		}
+	}
+}
+
+func TestBackend_usersBatch(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+
+	ctx := context.Background()
+
+	b := Backend()
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hashedpassword"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users-batch",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{
+					"username":       "alice",
+					"password":       "alicepassword",
+					"token_policies": []string{"dev"},
+					"metadata":       map[string]interface{}{"team": "eng"},
+				},
+				map[string]interface{}{
+					"username":      "bob",
+					"password_hash": string(hash),
+				},
+				map[string]interface{}{
+					"username": "carol",
+				},
+				map[string]interface{}{
+					"username": "-invalid",
+					"password": "password",
+				},
+				map[string]interface{}{
+					"username": "dave",
+					"password": "davepassword",
+					"unknown":  "value",
+				},
+			},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if resp.Data["succeeded"].(int) != 2 || resp.Data["failed"].(int) != 3 {
+		t.Fatalf("unexpected result counts: %#v", resp.Data)
+	}
+
+	results := resp.Data["results"].([]map[string]interface{})
+	for i, result := range results {
+		_, failed := result["error"]
+		if failed != (i >= 2) {
+			t.Fatalf("unexpected result for %q: %#v", result["username"], result)
+		}
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/alice",
+		Operation: logical.ReadOperation,
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if diff := deep.Equal(resp.Data["metadata"], map[string]string{"team": "eng"}); diff != nil {
+		t.Fatal(diff)
+	}
+	if diff := deep.Equal(resp.Data["token_policies"], []string{"dev"}); diff != nil {
+		t.Fatal(diff)
+	}
+
+	for username, password := range map[string]string{"alice": "alicepassword", "bob": "hashedpassword"} {
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Path:      "login/" + username,
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"password": password,
+			},
+			Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+		})
+		if err != nil || resp == nil || resp.IsError() || resp.Auth == nil {
+			t.Fatalf("bad: %s: resp: %#v\nerr: %v\n", username, resp, err)
+		}
+		if username == "alice" {
+			if diff := deep.Equal(resp.Auth.Alias.Metadata, map[string]string{"team": "eng"}); diff != nil {
+				t.Fatal(diff)
+			}
+		}
+	}
+
+	// The errors of invalid values must not include them
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users-batch",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{
+					"username": "erin",
+					"password": map[string]interface{}{"secret": "value"},
+				},
+			},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	results = resp.Data["results"].([]map[string]interface{})
+	if results[0]["error"] != `invalid value for parameter "password"` {
+		t.Fatalf("unexpected result: %#v", results[0])
+	}
+
+	users := make([]interface{}, maxBatchSize+1)
+	for i := range users {
+		users[i] = map[string]interface{}{"username": fmt.Sprintf("user%d", i)}
+	}
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users-batch",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"users": users,
+		},
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for oversized batch: resp: %#v\nerr: %v\n", resp, err)
+	}
+}
+
+func TestBackend_usersBatchAudit(t *testing.T) {
+	var records *[][]byte
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": Factory,
+		},
+	}
+	vault.AddNoopAudit(coreConfig, &records)
+	core, _, rootToken := vault.TestCoreUnsealedWithConfig(t, coreConfig)
+	ctx := namespace.RootContext(context.Background())
+
+	// The usernames and errors of the results are audited in plaintext once
+	// they are non-HMAC response keys of the mount
+	for _, req := range []struct {
+		path string
+		data map[string]interface{}
+	}{
+		{"sys/audit/noop", map[string]interface{}{"type": "noop"}},
+		{"sys/auth/userpass", map[string]interface{}{
+			"type": "userpass",
+			"config": map[string]interface{}{
+				"audit_non_hmac_response_keys": []string{"username", "error"},
+			},
+		}},
+	} {
+		resp, err := core.HandleRequest(ctx, &logical.Request{
+			Path:        req.path,
+			Operation:   logical.UpdateOperation,
+			ClientToken: rootToken,
+			Data:        req.data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: %s: resp: %#v\nerr: %v\n", req.path, resp, err)
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hashedpassword"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := core.HandleRequest(ctx, &logical.Request{
+		Path:        "auth/userpass/users-batch",
+		Operation:   logical.UpdateOperation,
+		ClientToken: rootToken,
+		Data: map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{
+					"username": "alice",
+					"password": "alicepassword",
+				},
+				map[string]interface{}{
+					"username":      "bob",
+					"password_hash": string(hash),
+				},
+				map[string]interface{}{
+					"username": "carol",
+				},
+			},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// The batch has a single response entry, which is that of the request
+	var entries []map[string]interface{}
+	for _, record := range *records {
+		if strings.Contains(string(record), "alicepassword") || strings.Contains(string(record), string(hash)) {
+			t.Fatalf("audit entry contains passwords: %s", record)
+		}
+		var e map[string]interface{}
+		if err := json.Unmarshal(record, &e); err != nil {
+			t.Fatal(err)
+		}
+		request := e["request"].(map[string]interface{})
+		if e["type"] == "response" && request["path"] == "auth/userpass/users-batch" {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 response entry of the batch, got %d", len(entries))
+	}
+	if request := entries[0]["request"].(map[string]interface{}); request["data"] == nil {
+		t.Fatal("expected the request data in the response entry")
+	}
+
+	data := entries[0]["response"].(map[string]interface{})["data"].(map[string]interface{})
+	expected := []interface{}{
+		map[string]interface{}{"username": "alice"},
+		map[string]interface{}{"username": "bob"},
+		map[string]interface{}{"username": "carol", "error": "missing password"},
+	}
+	if diff := deep.Equal(data["results"], expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
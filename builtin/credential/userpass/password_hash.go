@@ -0,0 +1,269 @@
+package userpass
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	hashAlgorithmBcrypt   = "bcrypt"
+	hashAlgorithmArgon2id = "argon2id"
+
+	defaultArgon2Memory      = 64 * 1024
+	defaultArgon2Iterations  = 3
+	defaultArgon2Parallelism = 4
+
+	minArgon2Memory      = 7 * 1024
+	maxArgon2Memory      = 1024 * 1024
+	maxArgon2Iterations  = 10
+	maxArgon2Parallelism = 16
+	minArgon2Cost        = 19456 * 2
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+
+	// Bounds for imported password hashes. Hashes that are too cheap provide
+	// little protection while hashes that are too expensive allow logins to
+	// exhaust the server's resources.
+	minImportedBcryptCost   = 10
+	maxImportedBcryptCost   = 14
+	minImportedArgon2Length = 16
+
+	// maxPasswordHistoryDepth bounds the number of hashes kept per user, each
+	// of which must be checked when the password is changed.
+	maxPasswordHistoryDepth = 24
+)
+
+// argon2idPrefix is the prefix of argon2id hashes in the PHC string format.
+var argon2idPrefix = []byte("$argon2id$")
+
+// errPasswordMismatch is returned when a password does not match its hash.
+var errPasswordMismatch = errors.New("password does not match")
+
+type argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (c *userpassConfig) argon2Params() argon2Params {
+	return argon2Params{
+		Memory:      uint32(c.Argon2Memory),
+		Iterations:  uint32(c.Argon2Iterations),
+		Parallelism: uint8(c.Argon2Parallelism),
+	}
+}
+
+// hashPassword hashes the given password using the configured algorithm. The
+// returned hash is self-describing, recording the algorithm and parameters
+// used so that verification can select the correct path.
+func (c *userpassConfig) hashPassword(password string) ([]byte, error) {
+	switch c.PasswordHashAlgorithm {
+	case hashAlgorithmArgon2id:
+		return argon2idHash(password, c.argon2Params())
+	case hashAlgorithmBcrypt, "":
+		return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", c.PasswordHashAlgorithm)
+	}
+}
+
+// needsRehash returns true if the given hash was not created using the
+// configured algorithm and parameters.
+func (c *userpassConfig) needsRehash(hash []byte) bool {
+	isArgon2id := bytes.HasPrefix(hash, argon2idPrefix)
+
+	switch c.PasswordHashAlgorithm {
+	case hashAlgorithmArgon2id:
+		if !isArgon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2idHash(hash)
+		if err != nil {
+			return true
+		}
+		return params != c.argon2Params()
+	default:
+		return isArgon2id
+	}
+}
+
+// verifyPasswordHash compares the password against a hash produced by
+// hashPassword, returning errPasswordMismatch if they do not match.
+func verifyPasswordHash(hash []byte, password string) error {
+	if !bytes.HasPrefix(hash, argon2idPrefix) {
+		if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+			return errPasswordMismatch
+		}
+		return nil
+	}
+
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return err
+	}
+
+	derived := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory,
+		params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(derived, key) != 1 {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+// argon2idHash hashes the password with argon2id and encodes the result in
+// the PHC string format, e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func argon2idHash(password string, params argon2Params) ([]byte, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory,
+		params.Parallelism, argon2KeyLength)
+
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))), nil
+}
+
+// decodeArgon2idHash parses an argon2id hash in the PHC string format.
+func decodeArgon2idHash(hash []byte) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	// The leading "$" produces an empty first element
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != hashAlgorithmArgon2id {
+		return params, nil, nil, errors.New("invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d",
+		&params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash key: %w", err)
+	}
+	if len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2id hash key length")
+	}
+
+	return params, salt, key, nil
+}
+
+// passwordMatches returns true if the given password matches the password
+// of the user, supporting both hashed and legacy plaintext passwords.
+func (u *UserEntry) passwordMatches(password string) bool {
+	if u.PasswordHash == nil {
+		return subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+	}
+
+	return verifyPasswordHash(u.PasswordHash, password) == nil
+}
+
+// passwordRecentlyUsed returns true if the password matches the current
+// password of the user or one of the previous passwords within depth.
+func (u *UserEntry) passwordRecentlyUsed(password string, depth int) bool {
+	if depth <= 0 {
+		return false
+	}
+
+	if (u.PasswordHash != nil || u.Password != "") && u.passwordMatches(password) {
+		return true
+	}
+
+	history := u.PasswordHistory
+	if len(history) > depth-1 {
+		history = history[:depth-1]
+	}
+	for _, hash := range history {
+		if verifyPasswordHash(hash, password) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordPasswordHistory adds the current password hash of the user to its
+// password history ahead of the password being changed. The history only
+// holds the previous passwords, so it is pruned to depth-1 entries.
+func (u *UserEntry) recordPasswordHistory(depth int) {
+	if depth <= 1 {
+		u.PasswordHistory = nil
+		return
+	}
+
+	// Legacy plaintext passwords are not recorded as the history must only
+	// ever contain hashes.
+	history := u.PasswordHistory
+	if u.PasswordHash != nil {
+		history = append([][]byte{u.PasswordHash}, history...)
+	}
+	if len(history) > depth-1 {
+		history = history[:depth-1]
+	}
+	u.PasswordHistory = history
+}
+
+// validatePasswordHash validates the format and cost of a pre-hashed password
+// supplied by an operator, such as one exported from another system.
+func validatePasswordHash(hash string) error {
+	if bytes.HasPrefix([]byte(hash), argon2idPrefix) {
+		params, salt, key, err := decodeArgon2idHash([]byte(hash))
+		if err != nil {
+			return err
+		}
+
+		config := &userpassConfig{
+			PasswordHashAlgorithm: hashAlgorithmArgon2id,
+			Argon2Memory:          int(params.Memory),
+			Argon2Iterations:      int(params.Iterations),
+			Argon2Parallelism:     int(params.Parallelism),
+		}
+		if err := config.validate(); err != nil {
+			return err
+		}
+
+		if len(salt) < minImportedArgon2Length || len(key) < minImportedArgon2Length {
+			return fmt.Errorf("argon2id salt and key must be at least %d bytes", minImportedArgon2Length)
+		}
+
+		return nil
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return errors.New("hash must be in bcrypt or argon2id format")
+	}
+	if cost < minImportedBcryptCost || cost > maxImportedBcryptCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", minImportedBcryptCost, maxImportedBcryptCost)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package userpass
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const configPath = "config"
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config",
+		Fields: map[string]*framework.FieldSchema{
+			"password_hash_algorithm": {
+				Type:        framework.TypeString,
+				Description: `The algorithm used to hash new and changed passwords. The following algorithms are supported: 'bcrypt', 'argon2id'. Defaults to 'bcrypt'.`,
+				Default:     hashAlgorithmBcrypt,
+			},
+			"argon2_memory": {
+				Type:        framework.TypeInt,
+				Description: "The amount of memory in KiB used by argon2id. Defaults to 65536 (64 MiB).",
+				Default:     defaultArgon2Memory,
+			},
+			"argon2_iterations": {
+				Type:        framework.TypeInt,
+				Description: "The number of passes over memory made by argon2id. Defaults to 3.",
+				Default:     defaultArgon2Iterations,
+			},
+			"argon2_parallelism": {
+				Type:        framework.TypeInt,
+				Description: "The number of threads used by argon2id. Defaults to 4.",
+				Default:     defaultArgon2Parallelism,
+			},
+			"password_history_depth": {
+				Type:        framework.TypeInt,
+				Description: "The number of most recent passwords, including the current password, that a user cannot reuse when their password is changed. Defaults to 0, which disables the check.",
+			},
+			"require_current_password": {
+				Type:        framework.TypeBool,
+				Description: "If set, changing a password using the users/<username>/password endpoint requires the user's current password.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"password_hash_algorithm":  config.PasswordHashAlgorithm,
+			"argon2_memory":            config.Argon2Memory,
+			"argon2_iterations":        config.Argon2Iterations,
+			"argon2_parallelism":       config.Argon2Parallelism,
+			"password_history_depth":   config.PasswordHistoryDepth,
+			"require_current_password": config.RequireCurrentPassword,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if algorithmRaw, ok := d.GetOk("password_hash_algorithm"); ok {
+		config.PasswordHashAlgorithm = algorithmRaw.(string)
+	}
+	if memoryRaw, ok := d.GetOk("argon2_memory"); ok {
+		config.Argon2Memory = memoryRaw.(int)
+	}
+	if iterationsRaw, ok := d.GetOk("argon2_iterations"); ok {
+		config.Argon2Iterations = iterationsRaw.(int)
+	}
+	if parallelismRaw, ok := d.GetOk("argon2_parallelism"); ok {
+		config.Argon2Parallelism = parallelismRaw.(int)
+	}
+	if depthRaw, ok := d.GetOk("password_history_depth"); ok {
+		config.PasswordHistoryDepth = depthRaw.(int)
+	}
+	if requireCurrentRaw, ok := d.GetOk("require_current_password"); ok {
+		config.RequireCurrentPassword = requireCurrentRaw.(bool)
+	}
+
+	if err := config.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON(configPath, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// config returns the configuration for this backend. A default configuration
+// is returned if one has not been written.
+func (b *backend) config(ctx context.Context, s logical.Storage) (*userpassConfig, error) {
+	result := defaultConfig()
+
+	entry, err := s.Get(ctx, configPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(result); err != nil {
+			return nil, fmt.Errorf("error reading configuration: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+type userpassConfig struct {
+	PasswordHashAlgorithm string `json:"password_hash_algorithm"`
+	Argon2Memory          int    `json:"argon2_memory"`
+	Argon2Iterations      int    `json:"argon2_iterations"`
+	Argon2Parallelism     int    `json:"argon2_parallelism"`
+
+	// RequireCurrentPassword requires the current password to be provided
+	// when changing a password using the users/<username>/password endpoint.
+	RequireCurrentPassword bool `json:"require_current_password"`
+
+	// PasswordHistoryDepth is the number of most recent passwords, including
+	// the current password, that cannot be reused.
+	PasswordHistoryDepth int `json:"password_history_depth"`
+}
+
+func defaultConfig() *userpassConfig {
+	return &userpassConfig{
+		PasswordHashAlgorithm: hashAlgorithmBcrypt,
+		Argon2Memory:          defaultArgon2Memory,
+		Argon2Iterations:      defaultArgon2Iterations,
+		Argon2Parallelism:     defaultArgon2Parallelism,
+	}
+}
+
+// validate rejects hash parameters that are either too weak to meet a
+// reasonable security baseline or so expensive that logins would exhaust
+// the server's memory.
+func (c *userpassConfig) validate() error {
+	if c.PasswordHistoryDepth < 0 || c.PasswordHistoryDepth > maxPasswordHistoryDepth {
+		return fmt.Errorf("password_history_depth must be between 0 and %d", maxPasswordHistoryDepth)
+	}
+
+	switch c.PasswordHashAlgorithm {
+	case hashAlgorithmBcrypt:
+		return nil
+	case hashAlgorithmArgon2id:
+	default:
+		return fmt.Errorf("invalid password_hash_algorithm %q; must be one of %q or %q",
+			c.PasswordHashAlgorithm, hashAlgorithmBcrypt, hashAlgorithmArgon2id)
+	}
+
+	if c.Argon2Parallelism < 1 || c.Argon2Parallelism > maxArgon2Parallelism {
+		return fmt.Errorf("argon2_parallelism must be between 1 and %d", maxArgon2Parallelism)
+	}
+	if c.Argon2Iterations < 1 || c.Argon2Iterations > maxArgon2Iterations {
+		return fmt.Errorf("argon2_iterations must be between 1 and %d", maxArgon2Iterations)
+	}
+	if c.Argon2Memory < minArgon2Memory || c.Argon2Memory > maxArgon2Memory {
+		return fmt.Errorf("argon2_memory must be between %d and %d KiB", minArgon2Memory, maxArgon2Memory)
+	}
+	if c.Argon2Memory < 8*c.Argon2Parallelism {
+		return fmt.Errorf("argon2_memory must be at least 8 KiB per unit of argon2_parallelism")
+	}
+
+	// Lower memory settings must be compensated with more iterations. The
+	// threshold corresponds to the weakest of the OWASP recommended argon2id
+	// configurations (e.g. m=19456 with t=2, or m=47104 with t=1).
+	if c.Argon2Memory*c.Argon2Iterations < minArgon2Cost {
+		return fmt.Errorf("argon2_memory * argon2_iterations must be at least %d; "+
+			"increase argon2_iterations when using less memory (e.g. argon2_memory=19456 with argon2_iterations=2)",
+			minArgon2Cost)
+	}
+
+	return nil
+}
+
+const pathConfigHelpSyn = `
+Configure how user passwords are hashed and changed.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures the algorithm and parameters used to hash new and
+changed passwords. Existing users keep their current password hash until
+they next log in successfully, at which point the password is transparently
+re-hashed with the configured algorithm and parameters.
+`
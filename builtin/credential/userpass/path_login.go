@@ -1,8 +1,10 @@
 package userpass
 
 import (
+	"bytes"
 	"context"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -10,7 +12,6 @@ import (
 	"github.com/hashicorp/vault/sdk/helper/cidrutil"
 	"github.com/hashicorp/vault/sdk/helper/policyutil"
 	"github.com/hashicorp/vault/sdk/logical"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func pathLogin(b *backend) *framework.Path {
@@ -87,7 +88,7 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 	// but handle the older legacy passwords with a constant time comparison.
 	passwordBytes := []byte(password)
 	if !legacyPassword {
-		if err := bcrypt.CompareHashAndPassword(userPassword, passwordBytes); err != nil {
+		if err := verifyPasswordHash(userPassword, password); err != nil {
 			return logical.ErrorResponse("invalid username or password"), nil
 		}
 	} else {
@@ -103,6 +104,13 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		return logical.ErrorResponse("invalid username or password"), nil
 	}
 
+	// The disabled state is only reported once the password has been
+	// verified so that it cannot be used to enumerate users.
+	if user.Disabled {
+		b.Logger().Info("login attempt for disabled user", "username", username)
+		return logical.ErrorResponse("user is disabled"), logical.ErrPermissionDenied
+	}
+
 	// Check for a CIDR match.
 	if len(user.TokenBoundCIDRs) > 0 {
 		if req.Connection == nil {
@@ -114,13 +122,21 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		}
 	}
 
+	// Transparently re-hash the password if it was stored using a legacy
+	// format or an algorithm or parameters other than those configured. This
+	// writes to storage, so it's only done once the login is accepted.
+	if err := b.rehashPasswordIfNeeded(ctx, req, username, user, password, legacyPassword); err != nil {
+		return nil, err
+	}
+
 	auth := &logical.Auth{
 		Metadata: map[string]string{
 			"username": username,
 		},
 		DisplayName: username,
 		Alias: &logical.Alias{
-			Name: username,
+			Name:     username,
+			Metadata: user.aliasMetadata(),
 		},
 	}
 	user.PopulateTokenAuth(auth)
@@ -130,6 +146,53 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 	}, nil
 }
 
+// rehashPasswordIfNeeded updates the stored password hash of the user to use
+// the configured algorithm and parameters after a successful login. The entry
+// is read again under the lock of the user, and the hash is only replaced if
+// the password wasn't changed since it was verified, so that the writes made
+// to the user during the login are kept. Failing to persist the new hash on a
+// read-only node is not an error; the password will be re-hashed on a later
+// login handled by a node that can write.
+func (b *backend) rehashPasswordIfNeeded(ctx context.Context, req *logical.Request, username string, user *UserEntry, password string, legacyPassword bool) error {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if !legacyPassword && !config.needsRehash(user.PasswordHash) {
+		return nil
+	}
+
+	hash, err := config.hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	lock := b.userLock(username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	current, err := b.user(ctx, req.Storage, username)
+	if err != nil {
+		return err
+	}
+	if current == nil || !bytes.Equal(current.PasswordHash, user.PasswordHash) || current.Password != user.Password {
+		b.Logger().Debug("skipping re-hash of password changed during login", "username", username)
+		return nil
+	}
+	current.PasswordHash = hash
+	current.Password = ""
+
+	if err := b.setUser(ctx, req.Storage, username, current); err != nil {
+		if errors.Is(err, logical.ErrReadOnly) {
+			b.Logger().Debug("unable to persist re-hashed password on read-only node", "username", username)
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
 func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	// Get the user
 	user, err := b.user(ctx, req.Storage, req.Auth.Metadata["username"])
@@ -140,6 +203,12 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 		// User no longer exists, do not renew
 		return nil, nil
 	}
+	if user.Disabled {
+		return nil, fmt.Errorf("user is disabled, not renewing")
+	}
+	if !user.TokensRevokedAt.IsZero() && req.Auth.IssueTime.Before(user.TokensRevokedAt) {
+		return nil, fmt.Errorf("tokens issued before the user was disabled are not renewable")
+	}
 
 	if !policyutil.EquivalentPolicies(user.TokenPolicies, req.Auth.TokenPolicies) {
 		return nil, fmt.Errorf("policies have changed, not renewing")
@@ -3,8 +3,10 @@ package userpass
 import (
 	"context"
 	"fmt"
-
-	"golang.org/x/crypto/bcrypt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -22,6 +24,17 @@ func pathUserPassword(b *backend) *framework.Path {
 			"password": {
 				Type:        framework.TypeString,
 				Description: "Password for this user.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+
+			"current_password": {
+				Type:        framework.TypeString,
+				Description: "The current password for this user. Required if the mount is configured with require_current_password.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
 			},
 		},
 
@@ -35,7 +48,26 @@ func pathUserPassword(b *backend) *framework.Path {
 }
 
 func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	username := d.Get("username").(string)
+	username := strings.ToLower(d.Get("username").(string))
+
+	// This endpoint is scoped to the password only so that policies can grant
+	// users the ability to change their own password without also allowing
+	// them to modify their policies or token settings.
+	var unsupported []string
+	for k := range d.Raw {
+		if _, ok := d.Schema[k]; !ok {
+			unsupported = append(unsupported, k)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return logical.ErrorResponse("unsupported parameters for password update: %s",
+			strings.Join(unsupported, ", ")), logical.ErrInvalidRequest
+	}
+
+	lock := b.userLock(username)
+	lock.Lock()
+	defer lock.Unlock()
 
 	userEntry, err := b.user(ctx, req.Storage, username)
 	if err != nil {
@@ -45,10 +77,31 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("username does not exist")
 	}
 
-	userErr, intErr := b.updateUserPassword(req, d, userEntry)
-	if intErr != nil {
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
 		return nil, err
 	}
+	if config.RequireCurrentPassword {
+		currentPassword := d.Get("current_password").(string)
+		if currentPassword == "" {
+			return logical.ErrorResponse("missing current_password"), logical.ErrInvalidRequest
+		}
+		// Attempts are refused without checking the password while backing
+		// off, and the error doesn't tell backing off and a wrong password
+		// apart, so that a stolen token can't be used to guess the password
+		now := time.Now()
+		if !b.passwordAttempts.allowed(username, now) || !userEntry.passwordMatches(currentPassword) {
+			b.passwordAttempts.failed(username, now)
+			b.Logger().Warn("failed to verify the current password of a password update", "username", username)
+			return logical.ErrorResponse(errCurrentPassword), logical.ErrPermissionDenied
+		}
+		b.passwordAttempts.succeeded(username)
+	}
+
+	userErr, intErr := b.updateUserPassword(ctx, req, d, userEntry)
+	if intErr != nil {
+		return nil, intErr
+	}
 	if userErr != nil {
 		return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
 	}
@@ -56,17 +109,121 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 	return nil, b.setUser(ctx, req.Storage, username, userEntry)
 }
 
-func (b *backend) updateUserPassword(req *logical.Request, d *framework.FieldData, userEntry *UserEntry) (error, error) {
+// errCurrentPassword is the error of password updates whose current
+// password couldn't be verified, whether it's wrong or the user is backing
+// off after failed attempts.
+const errCurrentPassword = "current_password could not be verified"
+
+const (
+	// currentPasswordFreeAttempts is the number of failed attempts at the
+	// current password of a user before further attempts back off
+	currentPasswordFreeAttempts = 3
+
+	// currentPasswordBackoff is the backoff after the first failed attempt
+	// past the free ones, doubled after each further one up to
+	// currentPasswordMaxBackoff
+	currentPasswordBackoff    = time.Second
+	currentPasswordMaxBackoff = 5 * time.Minute
+
+	// currentPasswordAttemptsReset is how long after their last failed
+	// attempt the failures of a user are forgotten
+	currentPasswordAttemptsReset = 15 * time.Minute
+)
+
+// passwordAttempts tracks the failed attempts at the current password of
+// users, which back off exponentially. Only existing users are tracked, and
+// their failures are forgotten after a successful attempt. The failures are
+// tracked by each node rather than in storage.
+type passwordAttempts struct {
+	l        sync.Mutex
+	failures map[string]*passwordFailures
+}
+
+type passwordFailures struct {
+	count     int
+	last      time.Time
+	notBefore time.Time
+}
+
+func newPasswordAttempts() *passwordAttempts {
+	return &passwordAttempts{
+		failures: make(map[string]*passwordFailures),
+	}
+}
+
+// allowed returns false if the user is backing off at the given time.
+func (p *passwordAttempts) allowed(username string, now time.Time) bool {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	f, ok := p.failures[username]
+	if !ok {
+		return true
+	}
+	if now.Sub(f.last) >= currentPasswordAttemptsReset {
+		delete(p.failures, username)
+		return true
+	}
+	return !now.Before(f.notBefore)
+}
+
+// failed records a failed attempt of the user at the given time.
+func (p *passwordAttempts) failed(username string, now time.Time) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	f, ok := p.failures[username]
+	if !ok || now.Sub(f.last) >= currentPasswordAttemptsReset {
+		f = &passwordFailures{}
+		p.failures[username] = f
+	}
+	f.count++
+	f.last = now
+	if f.count < currentPasswordFreeAttempts {
+		return
+	}
+
+	backoff := currentPasswordBackoff
+	for n := currentPasswordFreeAttempts; n < f.count && backoff < currentPasswordMaxBackoff; n++ {
+		backoff *= 2
+	}
+	if backoff > currentPasswordMaxBackoff {
+		backoff = currentPasswordMaxBackoff
+	}
+	f.notBefore = now.Add(backoff)
+}
+
+// succeeded forgets the failed attempts of the user.
+func (p *passwordAttempts) succeeded(username string) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	delete(p.failures, username)
+}
+
+func (b *backend) updateUserPassword(ctx context.Context, req *logical.Request, d *framework.FieldData, userEntry *UserEntry) (error, error) {
 	password := d.Get("password").(string)
 	if password == "" {
 		return fmt.Errorf("missing password"), nil
 	}
-	// Generate a hash of the password
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	config, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if userEntry.passwordRecentlyUsed(password, config.PasswordHistoryDepth) {
+		return fmt.Errorf("password was used recently"), nil
+	}
+
+	// Generate a hash of the password using the configured algorithm
+	hash, err := config.hashPassword(password)
 	if err != nil {
 		return nil, err
 	}
+	userEntry.recordPasswordHistory(config.PasswordHistoryDepth)
 	userEntry.PasswordHash = hash
+	userEntry.PasswordLastChanged = time.Now().UTC()
 	return nil, nil
 }
 
@@ -75,5 +232,13 @@ Reset user's password.
 `
 
 const pathUserPasswordHelpDesc = `
-This endpoint allows resetting the user's password.
+This endpoint allows resetting the user's password. Only the password may be
+changed using this endpoint, which allows policies to grant users the ability
+to change their own password, for example by templating the path as
+"auth/userpass/users/{{identity.entity.aliases.<mount accessor>.name}}/password".
+
+If the mount is configured with "require_current_password", the user's
+current password must also be provided as "current_password". After repeated
+failures to provide it, further attempts are refused for a backoff period that
+doubles with each failure.
 `
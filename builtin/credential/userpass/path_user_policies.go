@@ -41,6 +41,10 @@ func pathUserPolicies(b *backend) *framework.Path {
 func (b *backend) pathUserPoliciesUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	username := d.Get("username").(string)
 
+	lock := b.userLock(username)
+	lock.Lock()
+	defer lock.Unlock()
+
 	userEntry, err := b.user(ctx, req.Storage, username)
 	if err != nil {
 		return nil, err
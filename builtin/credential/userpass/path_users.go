@@ -3,6 +3,7 @@ package userpass
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,6 +16,24 @@ import (
 func pathUsersList(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "users/?",
+		Fields: map[string]*framework.FieldSchema{
+			"after": {
+				Type:        framework.TypeString,
+				Description: "Optional username after which to start listing. Usernames are listed in lexicographical order.",
+			},
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: "Optional maximum number of usernames to return. If not set, all matching usernames are returned.",
+			},
+			"filter": {
+				Type:        framework.TypeString,
+				Description: "Optional prefix that returned usernames must begin with.",
+			},
+			"detailed": {
+				Type:        framework.TypeBool,
+				Description: "If set, key_info will contain details about each returned user.",
+			},
+		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ListOperation: b.pathUserList,
@@ -46,6 +65,30 @@ func pathUsers(b *backend) *framework.Path {
 				},
 			},
 
+			"password_hash": {
+				Type:        framework.TypeString,
+				Description: "Pre-hashed password for this user, in bcrypt or argon2id (PHC string) format. Mutually exclusive with password.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+
+			"enabled": {
+				Type:        framework.TypeBool,
+				Description: "If set to false, the user is not allowed to log in and tokens previously issued to the user are not renewed. Defaults to true.",
+				Default:     true,
+			},
+
+			"revoke_tokens": {
+				Type:        framework.TypeBool,
+				Description: "If set when disabling the user, tokens previously issued to the user are revoked.",
+			},
+
+			"metadata": {
+				Type:        framework.TypeKVPairs,
+				Description: "Arbitrary key-value metadata associated with the user.",
+			},
+
 			"policies": {
 				Type:        framework.TypeCommaStringSlice,
 				Description: tokenutil.DeprecationText("token_policies"),
@@ -149,11 +192,94 @@ func (b *backend) pathUserList(ctx context.Context, req *logical.Request, d *fra
 	if err != nil {
 		return nil, err
 	}
-	return logical.ListResponse(users), nil
+
+	after := strings.ToLower(d.Get("after").(string))
+	limit := d.Get("limit").(int)
+	filter := strings.ToLower(d.Get("filter").(string))
+	detailed := d.Get("detailed").(bool)
+
+	if limit < 0 {
+		return logical.ErrorResponse("limit must be a positive integer"), logical.ErrInvalidRequest
+	}
+
+	// Preserve the unparameterized behavior of returning all users as-is
+	if after == "" && limit == 0 && filter == "" && !detailed {
+		return logical.ListResponse(users), nil
+	}
+
+	// Sort the usernames so that paging is stable across requests
+	sort.Strings(users)
+
+	var keys []string
+	for _, username := range users {
+		if after != "" && username <= after {
+			continue
+		}
+		if !strings.HasPrefix(username, filter) {
+			continue
+		}
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+		keys = append(keys, username)
+	}
+
+	if !detailed {
+		return logical.ListResponse(keys), nil
+	}
+
+	keyInfo := make(map[string]interface{}, len(keys))
+	for _, username := range keys {
+		user, err := b.user(ctx, req.Storage, username)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			continue
+		}
+
+		keyInfo[username] = user.listInfo()
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+// listInfo returns the details of the user included in detailed list responses.
+// Userpass doesn't lock users out after failed logins, so there is no lockout
+// state to include.
+func (u *UserEntry) listInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"token_policies": u.TokenPolicies,
+		"enabled":        !u.Disabled,
+	}
+	if !u.PasswordLastChanged.IsZero() {
+		info["password_last_changed"] = u.PasswordLastChanged.Format(time.RFC3339)
+	}
+
+	return info
+}
+
+// aliasMetadata returns a copy of the user's metadata to set on its entity
+// alias, or nil if the user has none.
+func (u *UserEntry) aliasMetadata() map[string]string {
+	if len(u.Metadata) == 0 {
+		return nil
+	}
+	metadata := make(map[string]string, len(u.Metadata))
+	for k, v := range u.Metadata {
+		metadata[k] = v
+	}
+	return metadata
 }
 
 func (b *backend) pathUserDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "user/"+strings.ToLower(d.Get("username").(string)))
+	username := strings.ToLower(d.Get("username").(string))
+
+	lock := b.userLock(username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := req.Storage.Delete(ctx, "user/"+username)
 	if err != nil {
 		return nil, err
 	}
@@ -187,6 +313,16 @@ func (b *backend) pathUserRead(ctx context.Context, req *logical.Request, d *fra
 		data["bound_cidrs"] = user.BoundCIDRs
 	}
 
+	if !user.PasswordLastChanged.IsZero() {
+		data["password_last_changed"] = user.PasswordLastChanged.Format(time.RFC3339)
+	}
+
+	data["enabled"] = !user.Disabled
+
+	if len(user.Metadata) > 0 {
+		data["metadata"] = user.Metadata
+	}
+
 	return &logical.Response{
 		Data: data,
 	}, nil
@@ -194,6 +330,11 @@ func (b *backend) pathUserRead(ctx context.Context, req *logical.Request, d *fra
 
 func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	username := strings.ToLower(d.Get("username").(string))
+
+	lock := b.userLock(username)
+	lock.Lock()
+	defer lock.Unlock()
+
 	userEntry, err := b.user(ctx, req.Storage, username)
 	if err != nil {
 		return nil, err
@@ -207,8 +348,14 @@ func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
-	if _, ok := d.GetOk("password"); ok {
-		userErr, intErr := b.updateUserPassword(req, d, userEntry)
+	_, hasPassword := d.GetOk("password")
+	passwordHashRaw, hasPasswordHash := d.GetOk("password_hash")
+	if hasPassword && hasPasswordHash {
+		return logical.ErrorResponse("password and password_hash are mutually exclusive"), logical.ErrInvalidRequest
+	}
+
+	if hasPassword {
+		userErr, intErr := b.updateUserPassword(ctx, req, d, userEntry)
 		if intErr != nil {
 			return nil, intErr
 		}
@@ -217,6 +364,46 @@ func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d
 		}
 	}
 
+	if hasPasswordHash {
+		passwordHash := passwordHashRaw.(string)
+		if err := validatePasswordHash(passwordHash); err != nil {
+			return logical.ErrorResponse("invalid password_hash: %s", err.Error()), logical.ErrInvalidRequest
+		}
+
+		config, err := b.config(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		// The hash is stored as-is and verified at login, at which point it
+		// is re-hashed if it doesn't match the configured algorithm. Since
+		// the password is unknown it can't be checked against the history.
+		userEntry.recordPasswordHistory(config.PasswordHistoryDepth)
+		userEntry.PasswordHash = []byte(passwordHash)
+		userEntry.Password = ""
+		userEntry.PasswordLastChanged = time.Now().UTC()
+	}
+
+	if metadataRaw, ok := d.GetOk("metadata"); ok {
+		userEntry.Metadata = metadataRaw.(map[string]string)
+	}
+
+	if enabledRaw, ok := d.GetOk("enabled"); ok {
+		userEntry.Disabled = !enabledRaw.(bool)
+	}
+	var tokenRevoker logical.LoginTokenRevoker
+	revokeTokens := d.Get("revoke_tokens").(bool)
+	if revokeTokens {
+		if !userEntry.Disabled {
+			return logical.ErrorResponse("revoke_tokens can only be set when disabling the user"), logical.ErrInvalidRequest
+		}
+		var ok bool
+		if tokenRevoker, ok = b.System().(logical.LoginTokenRevoker); !ok {
+			return logical.ErrorResponse("revoke_tokens is not supported by this mount"), logical.ErrInvalidRequest
+		}
+		userEntry.TokensRevokedAt = time.Now().UTC()
+	}
+
 	// handle upgrade cases
 	{
 		if err := tokenutil.UpgradeValue(d, "policies", "token_policies", &userEntry.Policies, &userEntry.TokenPolicies); err != nil {
@@ -236,12 +423,25 @@ func (b *backend) userCreateUpdate(ctx context.Context, req *logical.Request, d
 		}
 	}
 
-	return nil, b.setUser(ctx, req.Storage, username, userEntry)
+	if err := b.setUser(ctx, req.Storage, username, userEntry); err != nil {
+		return nil, err
+	}
+
+	// The user is stored as disabled first, so no tokens can be issued to it
+	// while its existing ones are revoked.
+	if revokeTokens {
+		if err := tokenRevoker.RevokeLoginTokens(ctx, "login/"+username); err != nil {
+			return nil, fmt.Errorf("failed to revoke tokens of user %q: %w", username, err)
+		}
+	}
+
+	return nil, nil
 }
 
 func (b *backend) pathUserWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	password := d.Get("password").(string)
-	if req.Operation == logical.CreateOperation && password == "" {
+	passwordHash := d.Get("password_hash").(string)
+	if req.Operation == logical.CreateOperation && password == "" && passwordHash == "" {
 		return logical.ErrorResponse("missing password"), logical.ErrInvalidRequest
 	}
 	return b.userCreateUpdate(ctx, req, d)
@@ -254,10 +454,32 @@ type UserEntry struct {
 	// PasswordHash, but is retained for backwards compatibility.
 	Password string
 
-	// PasswordHash is a bcrypt hash of the password. This is
-	// used instead of the actual password in Vault 0.2+.
+	// PasswordHash is a hash of the password. This is used instead
+	// of the actual password in Vault 0.2+. The hash is either a bcrypt
+	// hash or an argon2id hash in the PHC string format, both of which
+	// record the algorithm and parameters used to produce them.
 	PasswordHash []byte
 
+	// PasswordLastChanged is the time at which the password was last set
+	PasswordLastChanged time.Time
+
+	// PasswordHistory contains the hashes of the user's previous passwords,
+	// most recent first, pruned to the configured password_history_depth.
+	PasswordHistory [][]byte
+
+	// Metadata is arbitrary key-value data associated with the user. It's
+	// set as the metadata of the user's entity alias on login.
+	Metadata map[string]string
+
+	// Disabled prevents the user from logging in and renewing tokens. It is
+	// stored inverted so that existing users remain enabled.
+	Disabled bool
+
+	// TokensRevokedAt is the time at which the user was disabled with
+	// revoke_tokens set. Tokens issued before this time are revoked, and
+	// aren't renewed should a revocation have failed.
+	TokensRevokedAt time.Time
+
 	Policies []string
 
 	// Duration after which the user will be revoked unless renewed
@@ -0,0 +1,205 @@
+package userpass
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// maxBatchSize is the maximum number of users accepted in a single batch
+// request.
+const maxBatchSize = 1000
+
+// usernameRegex matches the usernames accepted by the users/<username> path.
+var usernameRegex = regexp.MustCompile(`^\w(([\w-.]+)?\w)?$`)
+
+func pathUsersImport(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "users-import",
+		Fields: map[string]*framework.FieldSchema{
+			"users": {
+				Type:        framework.TypeSlice,
+				Description: "List of users to import. Each user must have a username and a password_hash, and may have any of the token parameters accepted by users/<username>.",
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathUsersImport,
+		},
+
+		HelpSynopsis:    pathUsersImportHelpSyn,
+		HelpDescription: pathUsersImportHelpDesc,
+	}
+}
+
+func pathUsersBatch(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "users-batch",
+		Fields: map[string]*framework.FieldSchema{
+			"users": {
+				Type:        framework.TypeSlice,
+				Description: "List of users to create or update. Each user must have a username, and may have any of the parameters accepted by users/<username>.",
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathUsersBatch,
+		},
+
+		HelpSynopsis:    pathUsersBatchHelpSyn,
+		HelpDescription: pathUsersBatchHelpDesc,
+	}
+}
+
+func (b *backend) pathUsersBatch(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.handleUsersBatch(ctx, req, d, nil)
+}
+
+func (b *backend) pathUsersImport(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.handleUsersBatch(ctx, req, d, func(item map[string]interface{}) error {
+		if _, ok := item["password"]; ok {
+			return fmt.Errorf("password is not supported when importing users; use password_hash")
+		}
+		if hash, _ := item["password_hash"].(string); hash == "" {
+			return fmt.Errorf("missing password_hash")
+		}
+		return nil
+	})
+}
+
+// handleUsersBatch creates or updates each user in the "users" field. Each
+// user is processed independently so that an invalid entry does not prevent
+// the remaining users from being written. The check function, if non-nil, is
+// used to reject entries before they are written.
+func (b *backend) handleUsersBatch(ctx context.Context, req *logical.Request, d *framework.FieldData, check func(map[string]interface{}) error) (*logical.Response, error) {
+	usersRaw := d.Get("users").([]interface{})
+	if len(usersRaw) == 0 {
+		return logical.ErrorResponse("missing users"), logical.ErrInvalidRequest
+	}
+	if len(usersRaw) > maxBatchSize {
+		return logical.ErrorResponse("number of users exceeds the maximum batch size of %d", maxBatchSize), logical.ErrInvalidRequest
+	}
+
+	schema := pathUsers(b).Fields
+
+	var failed int
+	results := make([]map[string]interface{}, 0, len(usersRaw))
+	for idx, userRaw := range usersRaw {
+		result := map[string]interface{}{}
+		results = append(results, result)
+
+		item, ok := userRaw.(map[string]interface{})
+		if !ok {
+			result["error"] = fmt.Sprintf("user at index %d is not an object", idx)
+			failed++
+			continue
+		}
+
+		username, _ := item["username"].(string)
+		username = strings.ToLower(username)
+		result["username"] = username
+
+		if err := b.writeBatchUser(ctx, req, schema, username, item, check); err != nil {
+			result["error"] = err.Error()
+			failed++
+		}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"results":   results,
+			"succeeded": len(results) - failed,
+			"failed":    failed,
+		},
+	}
+	return resp, nil
+}
+
+// writeBatchUser writes a single user from a batch request using the same
+// validation and storage logic as the users/<username> path.
+func (b *backend) writeBatchUser(ctx context.Context, req *logical.Request, schema map[string]*framework.FieldSchema, username string, item map[string]interface{}, check func(map[string]interface{}) error) error {
+	if !usernameRegex.MatchString(username) {
+		return fmt.Errorf("invalid username %q", username)
+	}
+
+	for k := range item {
+		if _, ok := schema[k]; !ok {
+			return fmt.Errorf("unsupported parameter %q", k)
+		}
+	}
+
+	if check != nil {
+		if err := check(item); err != nil {
+			return err
+		}
+	}
+
+	raw := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		raw[k] = v
+	}
+	raw["username"] = username
+
+	fd := &framework.FieldData{
+		Raw:    raw,
+		Schema: schema,
+	}
+	// The errors of FieldData include the invalid value, which may be a
+	// password, and the errors of each user are returned and audited as-is.
+	for k := range raw {
+		if _, _, err := fd.GetOkErr(k); err != nil {
+			return fmt.Errorf("invalid value for parameter %q", k)
+		}
+	}
+
+	existing, err := b.user(ctx, req.Storage, username)
+	if err != nil {
+		return err
+	}
+
+	itemReq := *req
+	itemReq.Operation = logical.UpdateOperation
+	if existing == nil {
+		itemReq.Operation = logical.CreateOperation
+	}
+
+	resp, err := b.pathUserWrite(ctx, &itemReq, fd)
+	if resp != nil && resp.IsError() {
+		return resp.Error()
+	}
+	return err
+}
+
+const pathUsersImportHelpSyn = `
+Import users with pre-hashed passwords.
+`
+
+const pathUsersImportHelpDesc = `
+This endpoint creates or updates a list of users whose passwords have already
+been hashed, such as users exported from another system. Each user must
+specify a "password_hash" in bcrypt or argon2id (PHC string) format. The
+hashes are validated and stored as-is, and are verified when the user logs in.
+
+Each user is processed independently and the response contains the result for
+each user in the same order as the request.
+`
+
+const pathUsersBatchHelpSyn = `
+Create or update multiple users in a single request.
+`
+
+const pathUsersBatchHelpDesc = `
+This endpoint creates or updates a list of users. Each user accepts the same
+parameters as "users/<username>", including either a "password" or a
+"password_hash" when the user is created.
+
+Each user is processed independently, so an invalid user does not prevent the
+remaining users from being written. The response contains the result for each
+user in the same order as the request.
+`
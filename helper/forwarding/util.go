@@ -102,6 +102,13 @@ func GenerateForwardedRequest(req *http.Request) (*Request, error) {
 		}
 	}
 
+	if req.TLS != nil {
+		fq.TlsVersion = uint32(req.TLS.Version)
+		fq.TlsCipherSuite = uint32(req.TLS.CipherSuite)
+		fq.TlsServerName = req.TLS.ServerName
+		fq.TlsNegotiatedProtocol = req.TLS.NegotiatedProtocol
+	}
+
 	if req.TLS != nil && req.TLS.PeerCertificates != nil && len(req.TLS.PeerCertificates) > 0 {
 		fq.PeerCertificates = make([][]byte, len(req.TLS.PeerCertificates))
 		for i, cert := range req.TLS.PeerCertificates {
@@ -163,10 +170,19 @@ func ParseForwardedRequest(fq *Request) (*http.Request, error) {
 		ret.Header[k] = v.Values
 	}
 
-	if fq.PeerCertificates != nil && len(fq.PeerCertificates) > 0 {
+	// Requests forwarded by older versions only carry the peer certificates
+	if fq.TlsVersion != 0 || len(fq.PeerCertificates) > 0 {
 		ret.TLS = &tls.ConnectionState{
-			PeerCertificates: make([]*x509.Certificate, len(fq.PeerCertificates)),
+			Version:            uint16(fq.TlsVersion),
+			HandshakeComplete:  fq.TlsVersion != 0,
+			CipherSuite:        uint16(fq.TlsCipherSuite),
+			ServerName:         fq.TlsServerName,
+			NegotiatedProtocol: fq.TlsNegotiatedProtocol,
 		}
+	}
+
+	if fq.PeerCertificates != nil && len(fq.PeerCertificates) > 0 {
+		ret.TLS.PeerCertificates = make([]*x509.Certificate, len(fq.PeerCertificates))
 		for i, certBytes := range fq.PeerCertificates {
 			cert, err := x509.ParseCertificate(certBytes)
 			if err != nil {
@@ -3,6 +3,7 @@ package forwarding
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
 	"net/http"
 	"os"
 	"reflect"
@@ -13,6 +14,57 @@ func Test_ForwardedRequest_GenerateParse(t *testing.T) {
 	testForwardedRequestGenerateParse(t)
 }
 
+func Test_ForwardedRequest_ConnectionState(t *testing.T) {
+	for _, messageType := range []string{"json", "json_compress", "proto3"} {
+		t.Run(messageType, func(t *testing.T) {
+			t.Setenv("VAULT_MESSAGE_TYPE", messageType)
+
+			initialReq, err := http.NewRequest("GET", "https://pushit.real.good:9281/snicketysnack", http.NoBody)
+			if err != nil {
+				t.Fatal(err)
+			}
+			initialReq.RemoteAddr = "203.0.113.7:51234"
+			initialReq.TLS = &tls.ConnectionState{
+				Version:            tls.VersionTLS13,
+				HandshakeComplete:  true,
+				CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+				ServerName:         "pushit.real.good",
+				NegotiatedProtocol: "h2",
+			}
+
+			req, err := GenerateForwardedHTTPRequest(initialReq, "https://bloopety.bloop:8201")
+			if err != nil {
+				t.Fatal(err)
+			}
+			finalReq, err := ParseForwardedHTTPRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if finalReq.RemoteAddr != initialReq.RemoteAddr {
+				t.Fatalf("bad remoteaddr: expected %q, got %q", initialReq.RemoteAddr, finalReq.RemoteAddr)
+			}
+			if !reflect.DeepEqual(initialReq.TLS, finalReq.TLS) {
+				t.Fatalf("bad connection state:\ninitialReq:\n%#v\nfinalReq:\n%#v\n", *initialReq.TLS, finalReq.TLS)
+			}
+
+			// Requests that weren't received over TLS have no connection state
+			initialReq.TLS = nil
+			req, err = GenerateForwardedHTTPRequest(initialReq, "https://bloopety.bloop:8201")
+			if err != nil {
+				t.Fatal(err)
+			}
+			finalReq, err = ParseForwardedHTTPRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if finalReq.TLS != nil {
+				t.Fatalf("expected no connection state, got %#v", *finalReq.TLS)
+			}
+		})
+	}
+}
+
 func Benchmark_ForwardedRequest_GenerateParse_JSON(b *testing.B) {
 	os.Setenv("VAULT_MESSAGE_TYPE", "json")
 	var totalSize int64
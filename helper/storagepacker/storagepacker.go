@@ -356,6 +356,101 @@ func (s *StoragePacker) PutItem(ctx context.Context, item *Item) error {
 	return s.putBucket(ctx, bucket)
 }
 
+// PutMultipleItems stores the given items in their respective buckets,
+// reading and writing each bucket once regardless of the number of items
+// stored in it.
+func (s *StoragePacker) PutMultipleItems(ctx context.Context, logger hclog.Logger, items []*Item) error {
+	defer metrics.MeasureSince([]string{"storage_packer", "put_items"}, time.Now())
+	if len(items) == 0 {
+		return nil
+	}
+
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+
+	// Sort the items by the bucket they will be stored in
+	lockKeys := make([]string, 0)
+	byBucket := make(map[string][]*Item)
+	for _, item := range items {
+		if item == nil {
+			return fmt.Errorf("nil item")
+		}
+		if item.ID == "" {
+			return fmt.Errorf("missing ID in item")
+		}
+
+		bucketKey := s.BucketKey(item.ID)
+		if _, ok := byBucket[bucketKey]; !ok {
+			// Add the lock key once
+			lockKeys = append(lockKeys, bucketKey)
+		}
+		byBucket[bucketKey] = append(byBucket[bucketKey], item)
+	}
+
+	locks := locksutil.LocksForKeys(s.storageLocks, lockKeys)
+	for _, lock := range locks {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	logger.Debug("putting multiple items into storagepacker", "total_items", len(items))
+
+	// For each bucket, load from storage, upsert the items, and write it back
+	// out to storage
+	pctDone := 0
+	idx := 0
+	for bucketKey, bucketItems := range byBucket {
+		bucket := &Bucket{
+			Key: bucketKey,
+		}
+
+		storageEntry, err := s.view.Get(ctx, bucketKey)
+		if err != nil {
+			return fmt.Errorf("failed to read packed storage bucket entry: %w", err)
+		}
+		if storageEntry != nil {
+			uncompressedData, notCompressed, err := compressutil.Decompress(storageEntry.Value)
+			if err != nil {
+				return fmt.Errorf("failed to decompress packed storage entry: %w", err)
+			}
+			if notCompressed {
+				uncompressedData = storageEntry.Value
+			}
+
+			err = proto.Unmarshal(uncompressedData, bucket)
+			if err != nil {
+				return fmt.Errorf("failed to decode packed storage entry: %w", err)
+			}
+		}
+
+		for _, item := range bucketItems {
+			if err := bucket.upsert(item); err != nil {
+				return fmt.Errorf("failed to update entry in packed storage entry: %w", err)
+			}
+		}
+
+		// Fail if the context is canceled, the storage calls will fail anyways
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := s.putBucket(ctx, bucket); err != nil {
+			return err
+		}
+
+		newPctDone := idx * 100.0 / len(byBucket)
+		if int(newPctDone) > pctDone {
+			pctDone = int(newPctDone)
+			logger.Trace("bucket persistence progress", "percent", pctDone, "buckets_persisted", idx)
+		}
+
+		idx++
+	}
+
+	return nil
+}
+
 // NewStoragePacker creates a new storage packer for a given view
 func NewStoragePacker(view logical.Storage, logger log.Logger, viewPrefix string) (*StoragePacker, error) {
 	if view == nil {
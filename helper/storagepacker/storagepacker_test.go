@@ -290,3 +290,74 @@ func TestStoragePacker_DeleteMultiple_ALL(t *testing.T) {
 		}
 	}
 }
+
+func TestStoragePacker_PutMultiple(t *testing.T) {
+	storagePacker, err := NewStoragePacker(&logical.InmemStorage{}, log.New(&log.LoggerOptions{Name: "storagepackertest"}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	// Persist an item that is updated below
+	err = storagePacker.PutItem(ctx, &Item{
+		ID: "item0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := make([]*Item, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		message, err := ptypes.MarshalAny(&identity.Entity{
+			ID: fmt.Sprintf("entity%d", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items = append(items, &Item{
+			ID:      fmt.Sprintf("item%d", i),
+			Message: message,
+		})
+	}
+
+	err = storagePacker.PutMultipleItems(ctx, nil, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check that every item was stored
+	for i := 0; i < 1000; i++ {
+		fetchedItem, err := storagePacker.GetItem(fmt.Sprintf("item%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fetchedItem == nil {
+			t.Fatal("expected item not found")
+		}
+
+		var entity identity.Entity
+		if err := ptypes.UnmarshalAny(fetchedItem.Message, &entity); err != nil {
+			t.Fatal(err)
+		}
+		if entity.ID != fmt.Sprintf("entity%d", i) {
+			t.Fatalf("bad: entity ID; expected: %q\n actual: %q\n", fmt.Sprintf("entity%d", i), entity.ID)
+		}
+	}
+
+	// Check that the buckets don't hold duplicates
+	bucket, err := storagePacker.GetBucket(ctx, storagePacker.BucketKey("item0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	for _, item := range bucket.Items {
+		if item.ID == "item0" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("bad: expected a single item0 in the bucket, found %d", count)
+	}
+}
@@ -0,0 +1,74 @@
+package testhelpers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/go-testing-interface"
+)
+
+// MountAccessor returns the accessor of the mount at the given path, which is
+// an auth mount if it is prefixed by "auth/" and a secrets mount otherwise.
+// Accessors are not cached since mounts may be disabled and enabled again
+// within a test.
+func MountAccessor(t testing.T, client *api.Client, path string) string {
+	t.Helper()
+	path = strings.Trim(path, "/")
+	if authPath := strings.TrimPrefix(path, "auth/"); authPath != path {
+		return AuthMountAccessor(t, client, authPath)
+	}
+	return SecretsMountAccessor(t, client, path)
+}
+
+// AuthMountAccessor returns the accessor of the auth mount at the given path,
+// e.g. "userpass".
+func AuthMountAccessor(t testing.T, client *api.Client, path string) string {
+	t.Helper()
+	mount, err := client.Sys().GetAuthMount(path)
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		t.Fatalf("no auth mount at %q", path)
+	case err != nil:
+		t.Fatalf("error reading auth mount %q: %v", path, err)
+	}
+	return mount.Accessor
+}
+
+// SecretsMountAccessor returns the accessor of the secrets mount at the given
+// path.
+func SecretsMountAccessor(t testing.T, client *api.Client, path string) string {
+	t.Helper()
+	mount, err := client.Sys().GetMount(path)
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		t.Fatalf("no secrets mount at %q", path)
+	case err != nil:
+		t.Fatalf("error reading secrets mount %q: %v", path, err)
+	}
+	return mount.Accessor
+}
+
+// EnableUserpass enables userpass auth at "userpass", creates the given users
+// by username with their password, and returns the accessor of the mount. The
+// userpass credential backend must be registered on the cluster.
+func EnableUserpass(t testing.T, client *api.Client, users map[string]string) string {
+	t.Helper()
+	err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
+		Type: "userpass",
+	})
+	if err != nil {
+		t.Fatalf("error enabling userpass auth: %v", err)
+	}
+
+	for username, password := range users {
+		_, err := client.Logical().Write("auth/userpass/users/"+username, map[string]interface{}{
+			"password": password,
+		})
+		if err != nil {
+			t.Fatalf("error creating userpass user %q: %v", username, err)
+		}
+	}
+
+	return AuthMountAccessor(t, client, "userpass")
+}
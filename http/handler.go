@@ -485,16 +485,7 @@ func WrapForwardedForHandler(h http.Handler, l *configutil.Listener) http.Handle
 		}
 
 		// At this point we have at least one value and it's authorized
-
-		// Split comma separated ones, which are common. This brings it in line
-		// to the multiple-header case.
-		var acc []string
-		for _, header := range headers {
-			vals := strings.Split(header, ",")
-			for _, v := range vals {
-				acc = append(acc, strings.TrimSpace(v))
-			}
-		}
+		acc := parseForwardedFor(headers)
 
 		indexToUse := int64(len(acc)) - 1 - hopSkips
 		if indexToUse < 0 {
@@ -518,6 +509,20 @@ func WrapForwardedForHandler(h http.Handler, l *configutil.Listener) http.Handle
 	})
 }
 
+// parseForwardedFor returns the addresses in the given X-Forwarded-For
+// headers. Comma separated ones, which are common, are split to bring them in
+// line with the multiple-header case.
+func parseForwardedFor(headers []string) []string {
+	var acc []string
+	for _, header := range headers {
+		vals := strings.Split(header, ",")
+		for _, v := range vals {
+			acc = append(acc, strings.TrimSpace(v))
+		}
+	}
+	return acc
+}
+
 // stripPrefix is a helper to strip a prefix from the path. It will
 // return false from the second return value if it the prefix doesn't exist.
 func stripPrefix(prefix, path string) (string, bool) {
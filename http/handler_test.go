@@ -446,10 +446,11 @@ func TestSysMounts_headerAuth(t *testing.T) {
 				"type":                    "identity",
 				"external_entropy_access": false,
 				"config": map[string]interface{}{
-					"default_lease_ttl":           json.Number("0"),
-					"max_lease_ttl":               json.Number("0"),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []interface{}{"Authorization"},
+					"default_lease_ttl":            json.Number("0"),
+					"max_lease_ttl":                json.Number("0"),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
@@ -501,10 +502,11 @@ func TestSysMounts_headerAuth(t *testing.T) {
 			"type":                    "identity",
 			"external_entropy_access": false,
 			"config": map[string]interface{}{
-				"default_lease_ttl":           json.Number("0"),
-				"max_lease_ttl":               json.Number("0"),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []interface{}{"Authorization"},
+				"default_lease_ttl":            json.Number("0"),
+				"max_lease_ttl":                json.Number("0"),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -71,6 +71,7 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 				return nil, nil, http.StatusBadRequest, nil
 			}
 			if list {
+				queryVals.Del("list")
 				op = logical.ListOperation
 				if !strings.HasSuffix(path, "/") {
 					path += "/"
@@ -78,7 +79,9 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 			}
 		}
 
-		if !list {
+		// List operations only carry data when additional query parameters,
+		// such as pagination options, were provided.
+		if !list || len(queryVals) > 0 {
 			data = parseQuery(queryVals)
 		}
 
@@ -93,6 +96,10 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 		case path == "sys/monitor":
 			passHTTPReq = true
 			responseWriter = w
+		case strings.HasPrefix(path, "identity/oidc/provider/") && strings.HasSuffix(path, "/.well-known/openid-configuration"):
+			// The discovery document of a provider depends on the host
+			// that it's requested from
+			passHTTPReq = true
 		}
 
 	case "POST", "PUT":
@@ -178,7 +185,14 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 			path += "/"
 		}
 
-	case "OPTIONS", "HEAD":
+		if queryVals := r.URL.Query(); len(queryVals) > 0 {
+			data = parseQuery(queryVals)
+		}
+
+	case "OPTIONS":
+		op = logical.OptionsOperation
+
+	case "HEAD":
 	default:
 		return nil, nil, http.StatusMethodNotAllowed, nil
 	}
@@ -462,7 +476,7 @@ func respondRaw(w http.ResponseWriter, r *http.Request, resp *logical.Response)
 		return
 	}
 
-	nonEmpty := status != http.StatusNoContent
+	nonEmpty := status != http.StatusNoContent && status != http.StatusNotModified
 
 	var contentType string
 	var body []byte
@@ -524,6 +538,27 @@ WRITE_RESPONSE:
 		w.Header().Set("WWW-Authenticate", wwwAuthn)
 	}
 
+	if etag, ok := resp.Data[logical.HTTPETagHeader].(string); ok {
+		w.Header().Set("ETag", etag)
+	}
+
+	if allowOrigin, ok := resp.Data[logical.HTTPAccessControlAllowOrigin].(string); ok {
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if allowMethods, ok := resp.Data[logical.HTTPAccessControlAllowMethods].(string); ok {
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+	}
+
+	if allowHeaders, ok := resp.Data[logical.HTTPAccessControlAllowHeaders].(string); ok {
+		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+	}
+
+	if maxAge, ok := resp.Data[logical.HTTPAccessControlMaxAge].(string); ok {
+		w.Header().Set("Access-Control-Max-Age", maxAge)
+	}
+
 	w.WriteHeader(status)
 	w.Write(body)
 }
@@ -545,9 +580,10 @@ func getConnection(r *http.Request) (connection *logical.Connection) {
 	}
 
 	connection = &logical.Connection{
-		RemoteAddr: remoteAddr,
-		RemotePort: remotePort,
-		ConnState:  r.TLS,
+		RemoteAddr:   remoteAddr,
+		RemotePort:   remotePort,
+		ForwardedFor: parseForwardedFor(r.Header.Values("X-Forwarded-For")),
+		ConnState:    r.TLS,
 	}
 	return
 }
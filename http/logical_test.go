@@ -505,6 +505,34 @@ func TestLogical_ShouldParseForm(t *testing.T) {
 	}
 }
 
+func TestLogical_getConnection(t *testing.T) {
+	tests := map[string]struct {
+		headers  []string
+		expected []string
+	}{
+		"no header":        {nil, nil},
+		"single address":   {[]string{"203.0.113.7"}, []string{"203.0.113.7"}},
+		"comma separated":  {[]string{"203.0.113.7, 198.51.100.2"}, []string{"203.0.113.7", "198.51.100.2"}},
+		"multiple headers": {[]string{"203.0.113.7", "198.51.100.2,192.0.2.1"}, []string{"203.0.113.7", "198.51.100.2", "192.0.2.1"}},
+	}
+
+	for name, test := range tests {
+		r := httptest.NewRequest("POST", "/v1/identity/oidc/provider/default/token", nil)
+		r.RemoteAddr = "127.0.0.1:51234"
+		for _, header := range test.headers {
+			r.Header.Add("X-Forwarded-For", header)
+		}
+
+		conn := getConnection(r)
+		if conn.RemoteAddr != "127.0.0.1" || conn.RemotePort != 51234 {
+			t.Fatalf("%s fail: bad remote address %q and port %d", name, conn.RemoteAddr, conn.RemotePort)
+		}
+		if !reflect.DeepEqual(conn.ForwardedFor, test.expected) {
+			t.Fatalf("%s fail: expected forwarded for %q, got %q", name, test.expected, conn.ForwardedFor)
+		}
+	}
+}
+
 func TestLogical_AuditPort(t *testing.T) {
 	coreConfig := &vault.CoreConfig{
 		LogicalBackends: map[string]logical.Factory{
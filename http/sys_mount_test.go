@@ -75,10 +75,11 @@ func TestSysMounts(t *testing.T) {
 				"type":                    "identity",
 				"external_entropy_access": false,
 				"config": map[string]interface{}{
-					"default_lease_ttl":           json.Number("0"),
-					"max_lease_ttl":               json.Number("0"),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []interface{}{"Authorization"},
+					"default_lease_ttl":            json.Number("0"),
+					"max_lease_ttl":                json.Number("0"),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
@@ -130,10 +131,11 @@ func TestSysMounts(t *testing.T) {
 			"type":                    "identity",
 			"external_entropy_access": false,
 			"config": map[string]interface{}{
-				"default_lease_ttl":           json.Number("0"),
-				"max_lease_ttl":               json.Number("0"),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []interface{}{"Authorization"},
+				"default_lease_ttl":            json.Number("0"),
+				"max_lease_ttl":                json.Number("0"),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -245,10 +247,11 @@ func TestSysMount(t *testing.T) {
 				"type":                    "identity",
 				"external_entropy_access": false,
 				"config": map[string]interface{}{
-					"default_lease_ttl":           json.Number("0"),
-					"max_lease_ttl":               json.Number("0"),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []interface{}{"Authorization"},
+					"default_lease_ttl":            json.Number("0"),
+					"max_lease_ttl":                json.Number("0"),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
@@ -313,10 +316,11 @@ func TestSysMount(t *testing.T) {
 			"type":                    "identity",
 			"external_entropy_access": false,
 			"config": map[string]interface{}{
-				"default_lease_ttl":           json.Number("0"),
-				"max_lease_ttl":               json.Number("0"),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []interface{}{"Authorization"},
+				"default_lease_ttl":            json.Number("0"),
+				"max_lease_ttl":                json.Number("0"),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -463,10 +467,11 @@ func TestSysRemount(t *testing.T) {
 				"type":                    "identity",
 				"external_entropy_access": false,
 				"config": map[string]interface{}{
-					"default_lease_ttl":           json.Number("0"),
-					"max_lease_ttl":               json.Number("0"),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []interface{}{"Authorization"},
+					"default_lease_ttl":            json.Number("0"),
+					"max_lease_ttl":                json.Number("0"),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
@@ -531,10 +536,11 @@ func TestSysRemount(t *testing.T) {
 			"type":                    "identity",
 			"external_entropy_access": false,
 			"config": map[string]interface{}{
-				"default_lease_ttl":           json.Number("0"),
-				"max_lease_ttl":               json.Number("0"),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []interface{}{"Authorization"},
+				"default_lease_ttl":            json.Number("0"),
+				"max_lease_ttl":                json.Number("0"),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -633,10 +639,11 @@ func TestSysUnmount(t *testing.T) {
 				"type":                    "identity",
 				"external_entropy_access": false,
 				"config": map[string]interface{}{
-					"default_lease_ttl":           json.Number("0"),
-					"max_lease_ttl":               json.Number("0"),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []interface{}{"Authorization"},
+					"default_lease_ttl":            json.Number("0"),
+					"max_lease_ttl":                json.Number("0"),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
@@ -688,10 +695,11 @@ func TestSysUnmount(t *testing.T) {
 			"type":                    "identity",
 			"external_entropy_access": false,
 			"config": map[string]interface{}{
-				"default_lease_ttl":           json.Number("0"),
-				"max_lease_ttl":               json.Number("0"),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []interface{}{"Authorization"},
+				"default_lease_ttl":            json.Number("0"),
+				"max_lease_ttl":                json.Number("0"),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -889,10 +897,11 @@ func TestSysTuneMount(t *testing.T) {
 				"type":                    "identity",
 				"external_entropy_access": false,
 				"config": map[string]interface{}{
-					"default_lease_ttl":           json.Number("0"),
-					"max_lease_ttl":               json.Number("0"),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []interface{}{"Authorization"},
+					"default_lease_ttl":            json.Number("0"),
+					"max_lease_ttl":                json.Number("0"),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
@@ -957,10 +966,11 @@ func TestSysTuneMount(t *testing.T) {
 			"type":                    "identity",
 			"external_entropy_access": false,
 			"config": map[string]interface{}{
-				"default_lease_ttl":           json.Number("0"),
-				"max_lease_ttl":               json.Number("0"),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []interface{}{"Authorization"},
+				"default_lease_ttl":            json.Number("0"),
+				"max_lease_ttl":                json.Number("0"),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -1098,10 +1108,11 @@ func TestSysTuneMount(t *testing.T) {
 				"type":                    "identity",
 				"external_entropy_access": false,
 				"config": map[string]interface{}{
-					"default_lease_ttl":           json.Number("0"),
-					"max_lease_ttl":               json.Number("0"),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []interface{}{"Authorization"},
+					"default_lease_ttl":            json.Number("0"),
+					"max_lease_ttl":                json.Number("0"),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
@@ -1166,10 +1177,11 @@ func TestSysTuneMount(t *testing.T) {
 			"type":                    "identity",
 			"external_entropy_access": false,
 			"config": map[string]interface{}{
-				"default_lease_ttl":           json.Number("0"),
-				"max_lease_ttl":               json.Number("0"),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []interface{}{"Authorization"},
+				"default_lease_ttl":            json.Number("0"),
+				"max_lease_ttl":                json.Number("0"),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []interface{}{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []interface{}{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -2,6 +2,7 @@ package framework
 
 import (
 	"fmt"
+	"net/http"
 	"reflect"
 	"regexp"
 	"sort"
@@ -325,6 +326,7 @@ func documentPath(p *Path, specialPaths *logical.Paths, requestResponsePrefix st
 
 			op.Summary = props.Summary
 			op.Description = props.Description
+			op.OperationID = props.OperationID
 			op.Deprecated = props.Deprecated
 
 			// Add any fields not present in the path as body parameters for POST.
@@ -342,27 +344,10 @@ func documentPath(p *Path, specialPaths *logical.Paths, requestResponsePrefix st
 						continue
 					}
 
-					openapiField := convertType(field.Type)
 					if field.Required {
 						s.Required = append(s.Required, name)
 					}
-
-					p := OASSchema{
-						Type:         openapiField.baseType,
-						Description:  cleanString(field.Description),
-						Format:       openapiField.format,
-						Pattern:      openapiField.pattern,
-						Enum:         field.AllowedValues,
-						Default:      field.Default,
-						Deprecated:   field.Deprecated,
-						DisplayAttrs: field.DisplayAttrs,
-					}
-					if openapiField.baseType == "array" {
-						p.Items = &OASSchema{
-							Type: openapiField.items,
-						}
-					}
-					s.Properties[name] = &p
+					s.Properties[name] = fieldSchema(field)
 				}
 
 				// If examples were given, use the first one as the sample
@@ -371,18 +356,46 @@ func documentPath(p *Path, specialPaths *logical.Paths, requestResponsePrefix st
 					s.Example = props.Examples[0].Data
 				}
 
-				// Set the final request body. Only JSON request data is supported.
+				// Set the final request body, which is JSON unless other media
+				// types are given.
 				if len(s.Properties) > 0 || s.Example != nil {
 					requestName := constructRequestName(requestResponsePrefix, path)
 					doc.Components.Schemas[requestName] = s
+
+					mediaTypes := props.RequestMediaTypes
+					if len(mediaTypes) == 0 {
+						mediaTypes = []string{"application/json"}
+					}
 					op.RequestBody = &OASRequestBody{
-						Content: OASContent{
-							"application/json": &OASMediaTypeObject{
-								Schema: &OASSchema{Ref: fmt.Sprintf("#/components/schemas/%s", requestName)},
-							},
-						},
+						Content: make(OASContent, len(mediaTypes)),
 					}
+					for _, mediaType := range mediaTypes {
+						op.RequestBody.Content[mediaType] = &OASMediaTypeObject{
+							Schema: &OASSchema{Ref: fmt.Sprintf("#/components/schemas/%s", requestName)},
+						}
+					}
+				}
+			}
+
+			// Document the body fields of read operations reading them from
+			// the query string as query parameters.
+			if opType == logical.ReadOperation && props.QueryParameters {
+				for name, field := range bodyFields {
+					schema := fieldSchema(field)
+					schema.Description = ""
+					schema.Deprecated = false
+					op.Parameters = append(op.Parameters, OASParameter{
+						Name:        name,
+						Description: cleanString(field.Description),
+						In:          "query",
+						Schema:      schema,
+						Required:    field.Required,
+						Deprecated:  field.Deprecated,
+					})
 				}
+				sort.Slice(op.Parameters, func(i, j int) bool {
+					return strings.ToLower(op.Parameters[i].Name) < strings.ToLower(op.Parameters[j].Name)
+				})
 			}
 
 			// LIST is represented as GET with a `list` query parameter.
@@ -434,23 +447,50 @@ func documentPath(p *Path, specialPaths *logical.Paths, requestResponsePrefix st
 					if i == 0 {
 						description = resp.Description
 					}
-					if resp.Example != nil {
-						mediaType := resp.MediaType
-						if mediaType == "" {
-							mediaType = "application/json"
-						}
+					if resp.Example == nil && resp.Fields == nil {
+						continue
+					}
 
+					mediaType := resp.MediaType
+					if mediaType == "" {
+						mediaType = "application/json"
+					}
+
+					// Only one schema per media type is allowed, so first one wins
+					if _, ok := content[mediaType]; ok {
+						continue
+					}
+
+					schema := &OASSchema{}
+					if resp.Example != nil {
 						// create a version of the response that will not emit null items
-						cr := cleanResponse(resp.Example)
-
-						// Only one example per media type is allowed, so first one wins
-						if _, ok := content[mediaType]; !ok {
-							content[mediaType] = &OASMediaTypeObject{
-								Schema: &OASSchema{
-									Example: cr,
-								},
+						schema.Example = cleanResponse(resp.Example)
+					}
+
+					// Responses with fields are described by a schema component,
+					// which holds the example since siblings of a reference are
+					// ignored.
+					if resp.Fields != nil {
+						s := &OASSchema{
+							Type:       "object",
+							Properties: make(map[string]*OASSchema, len(resp.Fields)),
+							Example:    schema.Example,
+						}
+						for name, field := range resp.Fields {
+							if field.Required {
+								s.Required = append(s.Required, name)
 							}
+							s.Properties[name] = fieldSchema(field)
 						}
+						sort.Strings(s.Required)
+
+						responseName := constructResponseName(requestResponsePrefix, path, code)
+						doc.Components.Schemas[responseName] = s
+						schema = &OASSchema{Ref: fmt.Sprintf("#/components/schemas/%s", responseName)}
+					}
+
+					content[mediaType] = &OASMediaTypeObject{
+						Schema: schema,
 					}
 				}
 
@@ -481,6 +521,24 @@ func documentPath(p *Path, specialPaths *logical.Paths, requestResponsePrefix st
 //
 // For example, prefix="kv" & path=/config/lease/{name} => KvConfigLeaseRequest
 func constructRequestName(requestResponsePrefix string, path string) string {
+	return constructSchemaName(requestResponsePrefix, path, "Request")
+}
+
+// constructResponseName joins the given prefix with the path elements into a
+// CamelCaseResponse string, which includes the status code unless it is 200.
+// Operations on the same path share the name of their responses, which must
+// therefore have the same fields.
+//
+// For example, prefix="kv" & path=/config/lease/{name} & code=404 => KvConfigLease404Response
+func constructResponseName(requestResponsePrefix string, path string, code int) string {
+	suffix := "Response"
+	if code != http.StatusOK {
+		suffix = strconv.Itoa(code) + suffix
+	}
+	return constructSchemaName(requestResponsePrefix, path, suffix)
+}
+
+func constructSchemaName(requestResponsePrefix string, path string, suffix string) string {
 	var b strings.Builder
 
 	b.WriteString(strings.Title(requestResponsePrefix))
@@ -495,11 +553,33 @@ func constructRequestName(requestResponsePrefix string, path string) string {
 		}
 	}
 
-	b.WriteString("Request")
+	b.WriteString(suffix)
 
 	return b.String()
 }
 
+// fieldSchema returns the schema of the given field in a request or response
+// body.
+func fieldSchema(field *FieldSchema) *OASSchema {
+	openapiField := convertType(field.Type)
+	s := &OASSchema{
+		Type:         openapiField.baseType,
+		Description:  cleanString(field.Description),
+		Format:       openapiField.format,
+		Pattern:      openapiField.pattern,
+		Enum:         field.AllowedValues,
+		Default:      field.Default,
+		Deprecated:   field.Deprecated,
+		DisplayAttrs: field.DisplayAttrs,
+	}
+	if openapiField.baseType == "array" {
+		s.Items = &OASSchema{
+			Type: openapiField.items,
+		}
+	}
+	return s
+}
+
 func specialPathMatch(path string, specialPaths []string) bool {
 	// Test for exact or prefix match of special paths.
 	for _, sp := range specialPaths {
@@ -507,10 +587,39 @@ func specialPathMatch(path string, specialPaths []string) bool {
 			(strings.HasSuffix(sp, "*") && strings.HasPrefix(path, sp[0:len(sp)-1])) {
 			return true
 		}
+		if strings.Contains(sp, "+") && specialPathSegmentsMatch(path, sp) {
+			return true
+		}
 	}
 	return false
 }
 
+// specialPathSegmentsMatch tests whether the path matches a special path
+// containing "+" segments, which match any path segment such as a path
+// parameter.
+func specialPathSegmentsMatch(path string, sp string) bool {
+	prefix := strings.HasSuffix(sp, "*")
+	spSegments := strings.Split(strings.TrimSuffix(sp, "*"), "/")
+	pathSegments := strings.Split(path, "/")
+
+	if len(pathSegments) < len(spSegments) || (!prefix && len(pathSegments) != len(spSegments)) {
+		return false
+	}
+
+	for i, segment := range spSegments {
+		switch {
+		case segment == "+":
+		case prefix && i == len(spSegments)-1:
+			if !strings.HasPrefix(pathSegments[i], segment) {
+				return false
+			}
+		case segment != pathSegments[i]:
+			return false
+		}
+	}
+	return true
+}
+
 // expandPattern expands a regex pattern by generating permutations of any optional parameters
 // and changing named parameters into their {openapi} equivalents.
 func expandPattern(pattern string) []string {
@@ -749,10 +858,14 @@ func (d *OASDocument) CreateOperationIDs(context string) {
 				continue
 			}
 
-			// Space-split on non-words, title case everything, recombine
-			opID := nonWordRe.ReplaceAllString(strings.ToLower(path), " ")
-			opID = strings.Title(opID)
-			opID = method + strings.Replace(opID, " ", "", -1)
+			// Use the operationId given by the operation if any. Otherwise
+			// space-split on non-words, title case everything, recombine
+			opID := oasOperation.OperationID
+			if opID == "" {
+				opID = nonWordRe.ReplaceAllString(strings.ToLower(path), " ")
+				opID = strings.Title(opID)
+				opID = method + strings.Replace(opID, " ", "", -1)
+			}
 
 			// deduplicate operationIds. This is a safeguard, since generated IDs should
 			// already be unique given our current path naming conventions.
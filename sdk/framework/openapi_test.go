@@ -261,6 +261,9 @@ func TestOpenAPI_SpecialPaths(t *testing.T) {
 		{"foo/", []string{"foo/*"}, true, []string{"a", "b", "foo/"}, true},
 		{"foo", []string{"foo*"}, true, []string{"a", "fo*"}, true},
 		{"foo/bar", []string{"a", "b", "foo/*"}, true, []string{"foo/baz/*"}, false},
+		{"foo/baz/bar", []string{"foo/+/bar"}, true, []string{"foo/+/*"}, true},
+		{"foo/baz/bar", []string{"foo/+"}, false, []string{"+/baz/ba*"}, true},
+		{"foo/baz/bar", []string{"foo/+/baz"}, false, []string{"bar/+/*"}, false},
 	}
 	for i, test := range tests {
 		doc := NewOASDocument()
@@ -492,6 +495,74 @@ func TestOpenAPI_Paths(t *testing.T) {
 
 		testPath(t, p, sp, expected("responses"))
 	})
+
+	t.Run("Response fields", func(t *testing.T) {
+		errorFields := map[string]*FieldSchema{
+			"error": {
+				Type:        TypeString,
+				Description: "the error code",
+				Required:    true,
+			},
+			"error_description": {
+				Type:        TypeString,
+				Description: "the error description",
+			},
+		}
+		p := &Path{
+			Pattern: "foo/" + GenericNameRegex("name"),
+			Fields: map[string]*FieldSchema{
+				"name": {
+					Type:        TypeString,
+					Description: "the name",
+				},
+				"code": {
+					Type:        TypeString,
+					Description: "the code",
+					Required:    true,
+				},
+				"max_age": {
+					Type:        TypeInt,
+					Description: "the max age",
+				},
+			},
+			HelpSynopsis: "Synopsis",
+			Operations: map[logical.Operation]OperationHandler{
+				logical.ReadOperation: &PathOperation{
+					Summary:         "Read Summary",
+					OperationID:     "readFoo",
+					QueryParameters: true,
+					Responses: map[int][]Response{
+						200: {{
+							Description: "OK",
+							Fields: map[string]*FieldSchema{
+								"token": {
+									Type:        TypeString,
+									Description: "the token",
+									Required:    true,
+								},
+							},
+						}},
+						400: {{
+							Description: "Bad Request",
+							Fields:      errorFields,
+						}},
+					},
+				},
+				logical.UpdateOperation: &PathOperation{
+					Summary:           "Update Summary",
+					RequestMediaTypes: []string{"application/x-www-form-urlencoded", "application/json"},
+					Responses: map[int][]Response{
+						400: {{
+							Description: "Bad Request",
+							Fields:      errorFields,
+						}},
+					},
+				},
+			},
+		}
+
+		testPath(t, p, nil, expected("response_fields"))
+	})
 }
 
 func TestOpenAPI_OperationID(t *testing.T) {
@@ -135,6 +135,20 @@ type OperationProperties struct {
 	// Markdown-formatted text markup.
 	Description string
 
+	// OperationID is the operationId of the operation in OpenAPI output. If
+	// empty, one is generated from the method and the path.
+	OperationID string
+
+	// RequestMediaTypes are the media types of the request body accepted by
+	// a create or update operation in OpenAPI output. Defaults to
+	// "application/json".
+	RequestMediaTypes []string
+
+	// QueryParameters indicates that the fields of a read operation that are
+	// not part of the path are passed in the query string, in which case
+	// they are documented as query parameters in OpenAPI output.
+	QueryParameters bool
+
 	// Examples provides samples of the expected request data. The most
 	// relevant example should be first in the list, as it will be shown in
 	// documentation that supports only a single example.
@@ -223,9 +237,10 @@ type RequestExample struct {
 
 // Response describes and optional demonstrations an operation response.
 type Response struct {
-	Description string            // summary of the the response and should always be provided
-	MediaType   string            // media type of the response, defaulting to "application/json" if empty
-	Example     *logical.Response // example response data
+	Description string                  // summary of the the response and should always be provided
+	MediaType   string                  // media type of the response, defaulting to "application/json" if empty
+	Fields      map[string]*FieldSchema // fields of the response body, used to generate its schema
+	Example     *logical.Response       // example response data
 }
 
 // PathOperation is a concrete implementation of OperationHandler.
@@ -233,6 +248,9 @@ type PathOperation struct {
 	Callback                    OperationFunc
 	Summary                     string
 	Description                 string
+	OperationID                 string
+	RequestMediaTypes           []string
+	QueryParameters             bool
 	Examples                    []RequestExample
 	Responses                   map[int][]Response
 	Unpublished                 bool
@@ -249,6 +267,9 @@ func (p *PathOperation) Properties() OperationProperties {
 	return OperationProperties{
 		Summary:                     strings.TrimSpace(p.Summary),
 		Description:                 strings.TrimSpace(p.Description),
+		OperationID:                 p.OperationID,
+		RequestMediaTypes:           p.RequestMediaTypes,
+		QueryParameters:             p.QueryParameters,
 		Responses:                   p.Responses,
 		Examples:                    p.Examples,
 		Unpublished:                 p.Unpublished,
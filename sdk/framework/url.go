@@ -0,0 +1,127 @@
+package framework
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+)
+
+// URLRules are the rules that the values of a URL field must follow. The
+// zero value allows only absolute URLs without credentials, a query or a
+// fragment.
+type URLRules struct {
+	// Schemes are the allowed schemes of the URLs, such as "https".
+	Schemes []string
+
+	// AllowLoopbackHTTP allows "http" URLs whose host is a loopback address
+	// or "localhost", as used by native apps (RFC 8252 section 7.3).
+	AllowLoopbackHTTP bool
+
+	// AllowCustomSchemes allows schemes other than "http" and "https", such
+	// as the private-use schemes of native apps (RFC 8252 section 7.1).
+	AllowCustomSchemes bool
+
+	// AllowQuery allows URLs with a query.
+	AllowQuery bool
+
+	// AllowFragment allows URLs with a fragment.
+	AllowFragment bool
+
+	// MaxLength is the maximum length of the URLs. Zero means no maximum.
+	MaxLength int
+}
+
+// ValidateURL validates the value of the given field against the rules and
+// returns the parsed URL. Errors name the field and the offending value.
+// URLs with credentials are always refused, and the hosts of "http" and
+// "https" URLs must be in their ASCII form, which is punycode for
+// internationalized hosts.
+func ValidateURL(field, value string, rules URLRules) (*url.URL, error) {
+	invalid := func(reason string, args ...interface{}) error {
+		return fmt.Errorf("invalid %q value %q: %s", field, value, fmt.Sprintf(reason, args...))
+	}
+
+	if rules.MaxLength > 0 && len(value) > rules.MaxLength {
+		return nil, invalid("longer than %d characters", rules.MaxLength)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, invalid("not a valid URL")
+	}
+	if !u.IsAbs() {
+		return nil, invalid("not an absolute URL")
+	}
+	if u.User != nil {
+		return nil, invalid("must not include credentials")
+	}
+	if !rules.AllowQuery && (u.RawQuery != "" || u.ForceQuery) {
+		return nil, invalid("must not include a query")
+	}
+	if !rules.AllowFragment && (u.Fragment != "" || strings.Contains(value, "#")) {
+		return nil, invalid("must not include a fragment")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	web := scheme == "http" || scheme == "https"
+	if web {
+		if u.Opaque != "" || u.Hostname() == "" || strings.HasSuffix(u.Host, ":") {
+			return nil, invalid("must include a host")
+		}
+		if !strings.HasPrefix(u.Host, "[") && strings.Count(u.Host, ":") > 1 {
+			return nil, invalid("IPv6 hosts must be enclosed in brackets")
+		}
+		if !isASCII(u.Host) {
+			return nil, invalid("host must be in its ASCII (punycode) form")
+		}
+	}
+
+	switch {
+	case strutil.StrListContains(rules.Schemes, scheme):
+	case scheme == "http" && rules.AllowLoopbackHTTP && isLoopbackHost(u.Hostname()):
+	case !web && rules.AllowCustomSchemes:
+	default:
+		allowed := append([]string(nil), rules.Schemes...)
+		if rules.AllowLoopbackHTTP {
+			allowed = append(allowed, "http (loopback hosts only)")
+		}
+		if rules.AllowCustomSchemes {
+			allowed = append(allowed, "custom schemes")
+		}
+		return nil, invalid("scheme %q is not allowed, which must be one of: %s", scheme, strings.Join(allowed, ", "))
+	}
+
+	return u, nil
+}
+
+// ValidateURLs validates each of the values of the given field against the
+// rules, as ValidateURL does.
+func ValidateURLs(field string, values []string, rules URLRules) error {
+	for _, value := range values {
+		if _, err := ValidateURL(field, value, rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
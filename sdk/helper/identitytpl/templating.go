@@ -33,6 +33,12 @@ type PopulateStringInput struct {
 	Mode              int       // processing mode, ACLTemplate or JSONTemplating
 	Now               time.Time // optional, defaults to current time
 
+	// OIDCClientID and OIDCProvider are the client ID and the name of the
+	// provider of the OIDC provider request the string is populated for.
+	// They are empty in any other context.
+	OIDCClientID string
+	OIDCProvider string
+
 	templateHandler templateHandlerFunc
 	groupIDs        []string
 	groupNames      []string
@@ -119,21 +125,31 @@ func PopulateString(p PopulateStringInput) (bool, string, error) {
 		return false, "", fmt.Errorf("unknown mode %q", p.Mode)
 	}
 
+	// Parameters are only nested in JSON templates, such as the scope
+	// templates of OIDC providers. ACL templates are parsed as written.
+	input := p.String
+	if p.Mode == JSONTemplating {
+		input = expandNestedParameters(input, map[string]string{
+			"identity.oidc.client_id": p.OIDCClientID,
+			"identity.oidc.provider":  p.OIDCProvider,
+		})
+	}
+
 	var subst bool
-	splitStr := strings.Split(p.String, "{{")
+	splitStr := strings.Split(input, "{{")
 
 	if len(splitStr) >= 1 {
 		if strings.Contains(splitStr[0], "}}") {
 			return false, "", ErrUnbalancedTemplatingCharacter
 		}
 		if len(splitStr) == 1 {
-			return false, p.String, nil
+			return false, input, nil
 		}
 	}
 
 	var b strings.Builder
 	if !p.ValidityCheckOnly {
-		b.Grow(2 * len(p.String))
+		b.Grow(2 * len(input))
 	}
 
 	for i, str := range splitStr {
@@ -163,7 +179,196 @@ func PopulateString(p PopulateStringInput) (bool, string, error) {
 	return subst, b.String(), nil
 }
 
+// expandNestedParameters replaces the parameters nested in other directives
+// with their raw values, so that directives can select values by parameter,
+// as in {{identity.entity.metadata.{{identity.oidc.client_id}}}}. Only the
+// given parameters can be nested. Other nested directives are left as is, so
+// that they're reported as unbalanced in the order of the string.
+func expandNestedParameters(s string, params map[string]string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	var inDirective bool
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "{{") && inDirective && nestedParameterEnd(s, params) > 0:
+			end := nestedParameterEnd(s, params)
+			b.WriteString(params[strings.TrimSpace(s[2:end])])
+			s = s[end+2:]
+
+		case strings.HasPrefix(s, "{{"):
+			inDirective = true
+			b.WriteString("{{")
+			s = s[2:]
+
+		case strings.HasPrefix(s, "}}"):
+			inDirective = false
+			b.WriteString("}}")
+			s = s[2:]
+
+		default:
+			b.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+
+	return b.String()
+}
+
+// nestedParameterEnd returns the index of the closing characters of the
+// parameter directive at the start of s, or -1 if it isn't one of the given
+// parameters.
+func nestedParameterEnd(s string, params map[string]string) int {
+	end := strings.Index(s, "}}")
+	if end < 0 {
+		return -1
+	}
+	if _, ok := params[strings.TrimSpace(s[2:end])]; !ok {
+		return -1
+	}
+	return end
+}
+
+// directiveFilter is a filter applied to the value of a directive, as in
+// {{identity.entity.metadata.phone_number | default ""}}.
+type directiveFilter struct {
+	name string
+
+	// value is the JSON literal of the default filter
+	value string
+}
+
+const (
+	defaultFilter   = "default"
+	omitEmptyFilter = "omitempty"
+)
+
+// omittedValue is the value of the directives whose omitempty filter omitted
+// them, and omittedJSONValue its JSON encoding. It can't be written by hand
+// in a template, since it contains NUL characters.
+const (
+	omittedValue     = "\x00identitytpl:omitted\x00"
+	omittedJSONValue = `"\u0000identitytpl:omitted\u0000"`
+)
+
+// parseDirectiveFilter splits the directive into its parameter and its filter,
+// if any. Directives whose text after the first '|' isn't a known filter are
+// returned as is, so that metadata keys containing '|' keep working.
+func parseDirectiveFilter(input string) (string, *directiveFilter, error) {
+	idx := strings.Index(input, "|")
+	if idx < 0 {
+		return input, nil, nil
+	}
+	param := strings.TrimSpace(input[:idx])
+	expr := strings.TrimSpace(input[idx+1:])
+
+	name, arg := expr, ""
+	if i := strings.IndexAny(expr, " \t\n"); i >= 0 {
+		name, arg = expr[:i], strings.TrimSpace(expr[i:])
+	}
+
+	switch name {
+	case defaultFilter:
+		if arg == "" {
+			return "", nil, errors.New("the default filter requires a JSON value")
+		}
+		if !json.Valid([]byte(arg)) {
+			return "", nil, fmt.Errorf("the value of the default filter is not valid JSON: %s", arg)
+		}
+		return param, &directiveFilter{name: name, value: arg}, nil
+
+	case omitEmptyFilter:
+		if arg != "" {
+			return "", nil, errors.New("the omitempty filter takes no value")
+		}
+		return param, &directiveFilter{name: name}, nil
+	}
+
+	return input, nil, nil
+}
+
+// apply returns the filtered value of a directive in JSON mode. Directives
+// without a value, and those whose value is an empty string, list or object,
+// are replaced by the default value or omitted.
+func (f *directiveFilter) apply(value string, err error) (string, error) {
+	if err != nil && !errors.Is(err, ErrTemplateValueNotFound) {
+		return "", err
+	}
+	if err == nil {
+		switch value {
+		case `""`, "[]", "{}", "null":
+		default:
+			return value, nil
+		}
+	}
+
+	if f.name == defaultFilter {
+		return f.value, nil
+	}
+	return omittedJSONValue, nil
+}
+
+// RemoveOmittedValues removes the values of the directives omitted by the
+// omitempty filter from the parsed output of a JSON template. Omitted object
+// members are deleted and omitted list elements are dropped, at any depth.
+// It returns whether any value was removed.
+func RemoveOmittedValues(parsed map[string]interface{}) bool {
+	var removed bool
+	for k, v := range parsed {
+		if v == omittedValue {
+			delete(parsed, k)
+			removed = true
+			continue
+		}
+		var ok bool
+		if parsed[k], ok = removeOmittedValues(v); ok {
+			removed = true
+		}
+	}
+	return removed
+}
+
+func removeOmittedValues(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return t, RemoveOmittedValues(t)
+
+	case []interface{}:
+		var removed bool
+		kept := t[:0]
+		for _, e := range t {
+			if e == omittedValue {
+				removed = true
+				continue
+			}
+			e, ok := removeOmittedValues(e)
+			if ok {
+				removed = true
+			}
+			kept = append(kept, e)
+		}
+		return kept, removed
+	}
+
+	return v, false
+}
+
 func performTemplating(input string, p *PopulateStringInput) (string, error) {
+	input, filter, err := parseDirectiveFilter(input)
+	if err != nil {
+		return "", err
+	}
+	if filter != nil {
+		if p.Mode != JSONTemplating {
+			return "", fmt.Errorf("the %s filter is only supported in JSON templates", filter.name)
+		}
+		return filter.apply(performTemplating(input, p))
+	}
+
 	performAliasTemplating := func(trimmed string, alias *logical.Alias) (string, error) {
 		switch {
 		case trimmed == "id":
@@ -360,6 +565,12 @@ func performTemplating(input string, p *PopulateStringInput) (string, error) {
 
 	case strings.HasPrefix(input, "time."):
 		return performTimeTemplating(strings.TrimPrefix(input, "time."))
+
+	case input == "identity.oidc.client_id":
+		return p.templateHandler(p.OIDCClientID)
+
+	case input == "identity.oidc.provider":
+		return p.templateHandler(p.OIDCProvider)
 	}
 
 	return "", ErrTemplateValueNotFound
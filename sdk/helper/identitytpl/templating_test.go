@@ -1,9 +1,11 @@
 package identitytpl
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -553,3 +555,275 @@ func TestPopulate_FullObject(t *testing.T) {
 		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, out)
 	}
 }
+
+func TestPopulate_OIDCParameters(t *testing.T) {
+	entity := &logical.Entity{
+		ID: "abc-123",
+		Metadata: map[string]string{
+			"client-a": "admin",
+			"client-b": "viewer",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		mode     int
+		input    string
+		clientID string
+		provider string
+		output   string
+		err      error
+	}{
+		{
+			name:     "client and provider",
+			mode:     JSONTemplating,
+			input:    `{"client": {{identity.oidc.client_id}}, "provider": {{identity.oidc.provider}}}`,
+			clientID: "client-a",
+			provider: "default",
+			output:   `{"client": "client-a", "provider": "default"}`,
+		},
+		{
+			name:   "empty outside of provider requests",
+			mode:   JSONTemplating,
+			input:  `{"client": {{identity.oidc.client_id}}}`,
+			output: `{"client": ""}`,
+		},
+		{
+			name:     "nested metadata key",
+			mode:     JSONTemplating,
+			input:    `{"role": {{identity.entity.metadata.{{identity.oidc.client_id}}}}}`,
+			clientID: "client-b",
+			output:   `{"role": "viewer"}`,
+		},
+		{
+			name:     "nested metadata key with spaces",
+			mode:     JSONTemplating,
+			input:    `{"role": {{ identity.entity.metadata.{{ identity.oidc.client_id }} }}}`,
+			clientID: "client-a",
+			output:   `{"role": "admin"}`,
+		},
+		{
+			name:   "nested metadata key outside of provider requests",
+			mode:   JSONTemplating,
+			input:  `{"role": {{identity.entity.metadata.{{identity.oidc.client_id}}}}}`,
+			output: `{"role": ""}`,
+		},
+		{
+			name:  "nested directive other than a parameter",
+			mode:  JSONTemplating,
+			input: `{"role": {{identity.entity.metadata.{{identity.entity.id}}}}}`,
+			err:   ErrUnbalancedTemplatingCharacter,
+		},
+		{
+			name:     "acl client",
+			mode:     ACLTemplating,
+			input:    `path/{{identity.oidc.client_id}}`,
+			clientID: "client-a",
+			output:   `path/client-a`,
+		},
+		{
+			name:     "acl nested metadata key",
+			mode:     ACLTemplating,
+			input:    `path/{{identity.entity.metadata.{{identity.oidc.client_id}}}}`,
+			clientID: "client-a",
+			err:      ErrUnbalancedTemplatingCharacter,
+		},
+		{
+			name:  "acl client outside of provider requests",
+			mode:  ACLTemplating,
+			input: `path/{{identity.oidc.client_id}}`,
+			err:   ErrTemplateValueNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, out, err := PopulateString(PopulateStringInput{
+				Mode:         test.mode,
+				String:       test.input,
+				Entity:       entity,
+				OIDCClientID: test.clientID,
+				OIDCProvider: test.provider,
+			})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if out != test.output {
+				t.Fatalf("expected %q, got %q", test.output, out)
+			}
+		})
+	}
+}
+
+func TestPopulate_Filters(t *testing.T) {
+	entity := &logical.Entity{
+		ID: "abc-123",
+		Metadata: map[string]string{
+			"color":  "green",
+			"empty":  "",
+			"a|b":    "pipe",
+			"digits": "42",
+		},
+	}
+	omitted := omittedJSONValue
+
+	tests := []struct {
+		name    string
+		mode    int
+		input   string
+		output  string
+		wantErr bool
+	}{
+		{
+			name:   "default with value",
+			mode:   JSONTemplating,
+			input:  `{"color": {{identity.entity.metadata.color | default ""}}}`,
+			output: `{"color": "green"}`,
+		},
+		{
+			name:   "default without value",
+			mode:   JSONTemplating,
+			input:  `{"phone": {{identity.entity.metadata.phone_number | default "none"}}}`,
+			output: `{"phone": "none"}`,
+		},
+		{
+			name:   "default of an empty value",
+			mode:   JSONTemplating,
+			input:  `{"empty": {{ identity.entity.metadata.empty | default null }}}`,
+			output: `{"empty": null}`,
+		},
+		{
+			name:   "default of an empty object",
+			mode:   JSONTemplating,
+			input:  `{"alias": {{identity.entity.aliases.missing.metadata | default ["none"]}}}`,
+			output: `{"alias": ["none"]}`,
+		},
+		{
+			name:    "default without a value",
+			mode:    JSONTemplating,
+			input:   `{"color": {{identity.entity.metadata.color | default}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "default with invalid JSON",
+			mode:    JSONTemplating,
+			input:   `{"color": {{identity.entity.metadata.color | default none}}}`,
+			wantErr: true,
+		},
+		{
+			name:   "omitempty with value",
+			mode:   JSONTemplating,
+			input:  `{"color": {{identity.entity.metadata.color | omitempty}}}`,
+			output: `{"color": "green"}`,
+		},
+		{
+			name:   "omitempty without value",
+			mode:   JSONTemplating,
+			input:  `{"phone": {{identity.entity.metadata.phone_number | omitempty}}}`,
+			output: `{"phone": ` + omitted + `}`,
+		},
+		{
+			name:   "omitempty of an empty object",
+			mode:   JSONTemplating,
+			input:  `{"alias": {{identity.entity.aliases.missing.metadata | omitempty}}}`,
+			output: `{"alias": ` + omitted + `}`,
+		},
+		{
+			name:    "omitempty with a value",
+			mode:    JSONTemplating,
+			input:   `{"color": {{identity.entity.metadata.color | omitempty true}}}`,
+			wantErr: true,
+		},
+		{
+			name:   "filter of a directive without a value",
+			mode:   JSONTemplating,
+			input:  `{"color": {{identity.entity.unknown | omitempty}}}`,
+			output: `{"color": ` + omitted + `}`,
+		},
+		{
+			name:    "filter of an unknown selector",
+			mode:    JSONTemplating,
+			input:   `{"group": {{identity.groups.ids.unknown.name | default ""}}}`,
+			wantErr: true,
+		},
+		{
+			name:   "metadata key containing a pipe",
+			mode:   JSONTemplating,
+			input:  `{"pipe": {{identity.entity.metadata.a|b}}}`,
+			output: `{"pipe": "pipe"}`,
+		},
+		{
+			name:    "acl filters",
+			mode:    ACLTemplating,
+			input:   `path/{{identity.entity.metadata.color | default "blue"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, out, err := PopulateString(PopulateStringInput{
+				Mode:   test.mode,
+				String: test.input,
+				Entity: entity,
+				Groups: []*logical.Group{{ID: "g1-id", Name: "g1"}},
+			})
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != test.output {
+				t.Fatalf("expected %q, got %q", test.output, out)
+			}
+		})
+	}
+}
+
+func TestRemoveOmittedValues(t *testing.T) {
+	template := `{
+		"color": {{identity.entity.metadata.color | omitempty}},
+		"phone": {{identity.entity.metadata.phone_number | omitempty}},
+		"contact": {
+			"email": {{identity.entity.metadata.email | omitempty}},
+			"name": {{identity.entity.name}}
+		},
+		"list": [{{identity.entity.metadata.email | omitempty}}, {{identity.entity.metadata.color}}, [{{identity.entity.metadata.email | omitempty}}]]
+	}`
+
+	_, out, err := PopulateString(PopulateStringInput{
+		Mode:   JSONTemplating,
+		String: template,
+		Entity: &logical.Entity{
+			Name:     "Entity Name",
+			Metadata: map[string]string{"color": "green"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !RemoveOmittedValues(parsed) {
+		t.Fatal("expected omitted values to be removed")
+	}
+
+	expected := map[string]interface{}{
+		"color":   "green",
+		"contact": map[string]interface{}{"name": "Entity Name"},
+		"list":    []interface{}{"green", []interface{}{}},
+	}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, parsed)
+	}
+	if RemoveOmittedValues(parsed) {
+		t.Fatal("expected no omitted values to be left")
+	}
+}
@@ -13,6 +13,12 @@ type Connection struct {
 	// RemotePort is the network port that sent the request.
 	RemotePort int `json:"remote_port"`
 
+	// ForwardedFor is the chain of addresses in the X-Forwarded-For headers
+	// of the request, from the client to the last proxy. The chain is set by
+	// the client and the proxies, so only RemoteAddr should be relied upon:
+	// it is resolved from the chain if the listener trusts the proxies.
+	ForwardedFor []string `json:"forwarded_for,omitempty"`
+
 	// ConnState is the TLS connection state if applicable.
 	ConnState *tls.ConnectionState `sentinel:""`
 }
@@ -366,6 +366,22 @@ const (
 	HelpOperation                     = "help"
 	AliasLookaheadOperation           = "alias-lookahead"
 
+	// ResolveGroupAliasesOperation is sent by the identity store to the login
+	// path of a credential backend, with the name of an entity alias of the
+	// mount as the login name and the metadata of the alias as the
+	// "alias_metadata" field, to resolve the group aliases of the alias
+	// without a login. The group aliases are returned in the Auth of the
+	// response. Backends that can't enumerate group memberships don't handle
+	// it, and their external groups can't be synced.
+	ResolveGroupAliasesOperation = "resolve-group-aliases"
+
+	// OptionsOperation is sent for HTTP OPTIONS requests that aren't answered
+	// by the CORS configuration of the listener, such as the CORS preflight
+	// requests of browsers to endpoints that handle cross-origin requests
+	// themselves. Backends that don't handle it respond as to any other
+	// unsupported operation.
+	OptionsOperation = "options"
+
 	// The operations below are called globally, the path is less relevant.
 	RevokeOperation   Operation = "revoke"
 	RenewOperation              = "renew"
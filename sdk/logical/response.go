@@ -46,6 +46,24 @@ const (
 	// If set, HTTPWWWAuthenticateHeader will set the WWW-Authenticate response header.
 	// The value must be a string.
 	HTTPWWWAuthenticateHeader = "http_www_authenticate"
+
+	// If set, HTTPETagHeader will set the ETag response header, which clients
+	// send back in the If-None-Match request header. The value must be a
+	// quoted string.
+	HTTPETagHeader = "http_raw_etag"
+
+	// If set, HTTPAccessControlAllowOrigin will set the Access-Control-Allow-Origin
+	// response header and add Origin to the Vary response header. The value
+	// must be a string.
+	HTTPAccessControlAllowOrigin = "http_raw_access_control_allow_origin"
+
+	// If set, HTTPAccessControlAllowMethods, HTTPAccessControlAllowHeaders and
+	// HTTPAccessControlMaxAge will set the Access-Control-Allow-Methods,
+	// Access-Control-Allow-Headers and Access-Control-Max-Age response headers
+	// of CORS preflight responses. The values must be strings.
+	HTTPAccessControlAllowMethods = "http_raw_access_control_allow_methods"
+	HTTPAccessControlAllowHeaders = "http_raw_access_control_allow_headers"
+	HTTPAccessControlMaxAge       = "http_raw_access_control_max_age"
 )
 
 // Response is a struct that stores the response of a request.
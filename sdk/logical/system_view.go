@@ -90,6 +90,15 @@ type ExtendedSystemView interface {
 	ForwardGenericRequest(context.Context, *Request) (*Response, error)
 }
 
+// LoginTokenRevoker is implemented by the system views of builtin credential
+// backends. RevokeLoginTokens revokes the tokens, and the leases of their
+// children, that were issued by logging in at the given path of the mount,
+// such as "login/<username>". The last element of the path is compared
+// case-insensitively.
+type LoginTokenRevoker interface {
+	RevokeLoginTokens(ctx context.Context, loginPath string) error
+}
+
 type PasswordGenerator func() (password string, err error)
 
 type StaticSystemView struct {
@@ -0,0 +1,4 @@
+import OidcProviderDeviceController from './oidc-provider-device';
+
+// Use same params as the base oidc-provider-device route
+export default class VaultClusterOidcProviderDeviceNsController extends OidcProviderDeviceController {}
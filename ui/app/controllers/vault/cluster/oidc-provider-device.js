@@ -0,0 +1,44 @@
+import Controller from '@ember/controller';
+import { action } from '@ember/object';
+import { inject as service } from '@ember/service';
+import { tracked } from '@glimmer/tracking';
+
+export default class VaultClusterOidcProviderDeviceController extends Controller {
+  @service auth;
+
+  queryParams = ['user_code'];
+  user_code = null;
+
+  @tracked userCodeInput = '';
+  @tracked decision = null;
+  @tracked decisionError = null;
+
+  reset() {
+    this.userCodeInput = '';
+    this.decision = null;
+    this.decisionError = null;
+  }
+
+  @action
+  submitUserCode(evt) {
+    evt.preventDefault();
+    this.reset();
+    this.set('user_code', this.userCodeInput.trim());
+  }
+
+  @action
+  async decide(decision, evt) {
+    evt.preventDefault();
+    let { endpoint, namespace, userCode } = this.model;
+    try {
+      await this.auth.ajax(endpoint, 'POST', {
+        namespace,
+        body: JSON.stringify({ user_code: userCode, action: decision }),
+      });
+      this.decision = decision;
+    } catch (errorRes) {
+      let resp = await errorRes.json();
+      this.decisionError = resp.errors?.[0] || 'The request could not be completed.';
+    }
+  }
+}
@@ -0,0 +1,4 @@
+import OidcProviderLogoutController from './oidc-provider-logout';
+
+// Use same params as the base oidc-provider-logout route
+export default class VaultClusterOidcProviderLogoutNsController extends OidcProviderLogoutController {}
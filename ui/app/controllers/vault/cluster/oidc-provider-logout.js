@@ -0,0 +1,14 @@
+import Controller from '@ember/controller';
+
+export default class VaultClusterOidcProviderLogoutController extends Controller {
+  queryParams = [
+    'id_token_hint', // *
+    'client_id',
+    'post_logout_redirect_uri',
+    'state',
+  ];
+  id_token_hint = null;
+  client_id = null;
+  post_logout_redirect_uri = null;
+  state = null;
+}
@@ -11,6 +11,14 @@ Router.map(function () {
     this.route('cluster', { path: '/:cluster_name' }, function () {
       this.route('oidc-provider-ns', { path: '/*namespace/identity/oidc/provider/:provider_name/authorize' });
       this.route('oidc-provider', { path: '/identity/oidc/provider/:provider_name/authorize' });
+      this.route('oidc-provider-logout-ns', {
+        path: '/*namespace/identity/oidc/provider/:provider_name/logout',
+      });
+      this.route('oidc-provider-logout', { path: '/identity/oidc/provider/:provider_name/logout' });
+      this.route('oidc-provider-device-ns', {
+        path: '/*namespace/identity/oidc/provider/:provider_name/device',
+      });
+      this.route('oidc-provider-device', { path: '/identity/oidc/provider/:provider_name/device' });
       this.route('oidc-callback', { path: '/auth/*auth_path/oidc/callback' });
       this.route('auth');
       this.route('init');
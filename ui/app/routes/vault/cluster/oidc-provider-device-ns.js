@@ -0,0 +1,3 @@
+import VaultClusterOidcProviderDeviceRoute from './oidc-provider-device';
+
+export default class VaultClusterOidcProviderDeviceNsRoute extends VaultClusterOidcProviderDeviceRoute {}
@@ -0,0 +1,76 @@
+import Route from '@ember/routing/route';
+import { inject as service } from '@ember/service';
+
+const AUTH = 'vault.cluster.auth';
+const DEVICE = 'vault.cluster.oidc-provider-device';
+const NS_DEVICE = 'vault.cluster.oidc-provider-device-ns';
+
+export default class VaultClusterOidcProviderDeviceRoute extends Route {
+  @service auth;
+  @service router;
+
+  queryParams = {
+    user_code: { refreshModel: true },
+  };
+
+  get win() {
+    return this.window || window;
+  }
+
+  beforeModel(transition) {
+    if (this.auth.get('currentTokenName')) {
+      return;
+    }
+    let { provider_name, namespace = null } = transition.to.params;
+    let qp = { ...transition.to.queryParams, redirect_to: null };
+    let { cluster_name } = this.paramsFor('vault.cluster');
+    let url = namespace
+      ? this.router.urlFor(NS_DEVICE, cluster_name, namespace, provider_name, { queryParams: qp })
+      : this.router.urlFor(DEVICE, cluster_name, provider_name, { queryParams: qp });
+    // auth-form transitions to the url without the rootURL, as in the oidc-provider route
+    url = url.replace(/^(\/?ui)/, '');
+    let queryParams = {
+      redirect_to: url,
+      o: provider_name,
+    };
+    if (namespace) {
+      queryParams.namespace = namespace;
+    }
+    return this.transitionTo(AUTH, cluster_name, { queryParams });
+  }
+
+  /**
+   * Looks up the device authorization request of the user code, if any, so that the end-user can check
+   * the client and scopes before approving it. Without a user code, the end-user is asked to enter one.
+   */
+  async model(params) {
+    let { provider_name, namespace, user_code } = params;
+    let endpoint = `${this.win.origin}/v1/identity/oidc/provider/${provider_name}/device`;
+    let model = { endpoint, namespace, userCode: user_code };
+    if (!user_code) {
+      return model;
+    }
+    try {
+      let url = new URL(endpoint);
+      url.searchParams.append('user_code', user_code);
+      const response = await this.auth.ajax(url, 'GET', { namespace });
+      return { ...model, request: response.data };
+    } catch (errorRes) {
+      let resp = await errorRes.json();
+      return {
+        ...model,
+        error: {
+          title: 'Invalid code',
+          message: resp.errors?.[0] || 'The code is invalid or has expired.',
+        },
+      };
+    }
+  }
+
+  resetController(controller, isExiting) {
+    if (isExiting) {
+      controller.set('user_code', null);
+    }
+    controller.reset();
+  }
+}
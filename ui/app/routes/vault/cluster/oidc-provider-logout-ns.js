@@ -0,0 +1,3 @@
+import VaultClusterOidcProviderLogoutRoute from './oidc-provider-logout';
+
+export default class VaultClusterOidcProviderLogoutNsRoute extends VaultClusterOidcProviderLogoutRoute {}
@@ -0,0 +1,68 @@
+import Ember from 'ember';
+import Route from '@ember/routing/route';
+import { inject as service } from '@ember/service';
+
+export default class VaultClusterOidcProviderLogoutRoute extends Route {
+  @service auth;
+
+  get win() {
+    return this.window || window;
+  }
+
+  _buildUrl(urlString, params) {
+    try {
+      let url = new URL(urlString);
+      Object.keys(params).forEach((key) => {
+        if (params[key]) {
+          url.searchParams.append(key, params[key]);
+        }
+      });
+      return url;
+    } catch (e) {
+      console.debug('DEBUG: parsing url failed for', urlString);
+      throw new Error('Invalid URL');
+    }
+  }
+
+  /**
+   * Ends the session of the end-user with the client. The current token is sent along, so that the
+   * provider revokes it if it belongs to the end-user of the id_token_hint, and is forgotten either way.
+   * Errors are rendered rather than redirected, since the redirect URI may not be registered.
+   */
+  async model(params) {
+    let { provider_name, namespace, ...qp } = params;
+    let endpoint = this._buildUrl(`${this.win.origin}/v1/identity/oidc/provider/${provider_name}/logout`, qp);
+    try {
+      const response = await this.auth.ajax(endpoint, 'GET', { namespace });
+      if (this.auth.get('currentTokenName')) {
+        this.auth.deleteCurrentToken();
+      }
+      const { post_logout_redirect_uri, state } = response;
+      if (!post_logout_redirect_uri) {
+        return { loggedOut: true };
+      }
+      let redirectUrl = this._buildUrl(post_logout_redirect_uri, { state });
+      if (Ember.testing) {
+        return { redirectUrl };
+      }
+      this.win.location.replace(redirectUrl);
+    } catch (errorRes) {
+      let resp = await errorRes.json();
+      if (resp.error === 'invalid_redirect_uri') {
+        return {
+          error: {
+            title: 'Redirect URI mismatch',
+            message:
+              'The provided post_logout_redirect_uri is not in the list of allowed post-logout redirect URIs. Please make sure you are sending a valid redirect URI from your application.',
+          },
+        };
+      }
+      return {
+        error: {
+          title: 'Logout failed',
+          message: resp.error_description || 'The logout request is invalid.',
+        },
+      };
+    }
+  }
+}
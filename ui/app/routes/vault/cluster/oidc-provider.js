@@ -143,7 +143,11 @@ export default class VaultClusterOidcProviderRoute extends Route {
     } catch (errorRes) {
       let resp = await errorRes.json();
       let code = resp.error;
-      if (code === 'max_age_violation' || resp?.errors?.includes('permission denied')) {
+      let reAuthenticate = code === 'max_age_violation' || resp?.errors?.includes('permission denied');
+      if (reAuthenticate && 'none' === qp.prompt?.toLowerCase()) {
+        // the end-user must not be prompted to log in again
+        return this._handleError({ state: qp.state, error: 'login_required' }, decodedRedirect);
+      } else if (reAuthenticate) {
         this._redirectToAuth({ ...routeParams, qp, logout: true });
       } else if (code === 'invalid_redirect_uri') {
         return {
@@ -100,6 +100,7 @@ export default Service.extend({
     return fetch(url, {
       method: opts.method || 'GET',
       headers: opts.headers || {},
+      body: opts.body,
     }).then((response) => {
       if (response.status === 204) {
         return resolve();
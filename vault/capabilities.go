@@ -61,7 +61,7 @@ func (c *Core) Capabilities(ctx context.Context, token, path string) ([]string,
 	// Add capabilities of the inline policy if it's set
 	policies := make([]*Policy, 0)
 	if te.InlinePolicy != "" {
-		inlinePolicy, err := ParseACLPolicy(tokenNS, te.InlinePolicy)
+		inlinePolicy, err := c.policyStore.parseInlinePolicy(tokenNS, te.InlinePolicy)
 		if err != nil {
 			return nil, err
 		}
@@ -572,6 +572,10 @@ type Core struct {
 	// secureRandomReader is the reader used for CSP operations
 	secureRandomReader io.Reader
 
+	// oidcKeySource is the deterministic source of OIDC keys used by tests,
+	// if any
+	oidcKeySource *oidcKeySource
+
 	recoveryMode bool
 
 	clusterNetworkLayer cluster.NetworkLayer
@@ -747,6 +751,10 @@ type CoreConfig struct {
 
 	// DisableSSCTokens is used to disable the use of server side consistent tokens
 	DisableSSCTokens bool
+
+	// testOIDCKeySeed makes the identity store generate OIDC keys
+	// deterministically from the seed. It is only set by TestSetOIDCKeySeed.
+	testOIDCKeySeed []byte
 }
 
 // GetServiceRegistration returns the config's ServiceRegistration, or nil if it does
@@ -877,6 +885,7 @@ func CreateCore(conf *CoreConfig) (*Core, error) {
 		metricsHelper:                  conf.MetricsHelper,
 		metricSink:                     conf.MetricSink,
 		secureRandomReader:             conf.SecureRandomReader,
+		oidcKeySource:                  newOIDCKeySource(conf.testOIDCKeySeed),
 		rawConfig:                      new(atomic.Value),
 		recoveryMode:                   conf.RecoveryMode,
 		postUnsealStarted:              new(uint32),
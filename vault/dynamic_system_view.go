@@ -3,6 +3,8 @@ package vault
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/helper/identity"
@@ -59,6 +61,35 @@ func (e extendedSystemViewImpl) ForwardGenericRequest(ctx context.Context, req *
 	return nil, logical.ErrReadOnly
 }
 
+// RevokeLoginTokens revokes the tokens issued by logging in at the given path
+// of the credential mount. The leases of login tokens are stored under the
+// path as it was requested, so the last element is matched case-insensitively.
+func (e extendedSystemViewImpl) RevokeLoginTokens(ctx context.Context, loginPath string) error {
+	if e.mountEntry.Table != credentialTableType {
+		return fmt.Errorf("login tokens can only be revoked by credential backends")
+	}
+	dir, name := path.Split(strings.Trim(loginPath, "/"))
+	if name == "" {
+		return fmt.Errorf("missing login path")
+	}
+
+	ctx = namespace.ContextWithNamespace(ctx, e.mountEntry.Namespace())
+	prefix := credentialRoutePrefix + e.mountEntry.Path + dir
+	entries, err := e.core.expiration.leaseView(e.mountEntry.Namespace()).List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list login leases: %w", err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry, "/") || !strings.EqualFold(strings.TrimSuffix(entry, "/"), name) {
+			continue
+		}
+		if err := e.core.expiration.RevokePrefix(ctx, prefix+entry, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SudoPrivilege returns true if given path has sudo privileges
 // for the given client token
 func (e extendedSystemViewImpl) SudoPrivilege(ctx context.Context, path string, token string) bool {
@@ -104,7 +135,7 @@ func (e extendedSystemViewImpl) SudoPrivilege(ctx context.Context, path string,
 	// Add the inline policy if it's set
 	policies := make([]*Policy, 0)
 	if te.InlinePolicy != "" {
-		inlinePolicy, err := ParseACLPolicy(tokenNS, te.InlinePolicy)
+		inlinePolicy, err := e.core.policyStore.parseInlinePolicy(tokenNS, te.InlinePolicy)
 		if err != nil {
 			e.core.logger.Error("failed to parse the token's inline policy", "error", err)
 			return false
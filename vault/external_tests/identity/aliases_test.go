@@ -6,6 +6,7 @@ import (
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/builtin/credential/github"
 	"github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/helper/testhelpers"
 	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/vault"
@@ -34,21 +35,7 @@ func TestIdentityStore_ListAlias(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mounts, err := client.Sys().ListAuth()
-	if err != nil {
-		t.Fatal(err)
-	}
-	var githubAccessor string
-	for k, v := range mounts {
-		t.Logf("key: %v\nmount: %#v", k, *v)
-		if k == "github/" {
-			githubAccessor = v.Accessor
-			break
-		}
-	}
-	if githubAccessor == "" {
-		t.Fatal("did not find github accessor")
-	}
+	githubAccessor := testhelpers.AuthMountAccessor(t, client, "github")
 
 	resp, err := client.Logical().Write("identity/entity", nil)
 	if err != nil {
@@ -206,21 +193,7 @@ func TestIdentityStore_RenameAlias_CannotMergeEntity(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mounts, err := client.Sys().ListAuth()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	var mountAccessor string
-	for k, v := range mounts {
-		if k == "userpass/" {
-			mountAccessor = v.Accessor
-			break
-		}
-	}
-	if mountAccessor == "" {
-		t.Fatal("did not find userpass accessor")
-	}
+	mountAccessor := testhelpers.AuthMountAccessor(t, client, "userpass")
 
 	// Now create a new unrelated entity and alias
 	entityResp, err := client.Logical().Write("identity/entity", map[string]interface{}{
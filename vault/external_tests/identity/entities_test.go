@@ -376,3 +376,76 @@ func TestIdentityStore_EntityPoliciesInInitialAuth(t *testing.T) {
 		t.Fatalf("policy mismatch, got policies: %v", policies)
 	}
 }
+
+func TestIdentityStore_TestCreateIdentity(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"approle": approle.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	vault.TestWaitActive(t, core)
+	client := cluster.Cores[0].Client
+
+	err := client.Sys().EnableAuthWithOptions("approle", &api.EnableAuthOptions{
+		Type: "approle",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An existing group keeps its other members
+	other := vault.TestCreateIdentity(t, client, vault.TestIdentitySpec{
+		Name:   "other",
+		Groups: []string{"engineering"},
+	})
+
+	spec := vault.TestIdentitySpec{
+		Name:          "test-entity",
+		Metadata:      map[string]string{"team": "vault"},
+		MountPath:     "approle",
+		AliasName:     "test-role",
+		AliasMetadata: map[string]string{"env": "test"},
+		Groups:        []string{"engineering", "oncall"},
+	}
+	identity := vault.TestCreateIdentity(t, client, spec)
+	if identity.EntityID == "" || identity.AliasID == "" || len(identity.GroupIDs) != 2 {
+		t.Fatalf("unexpected identity: %#v", identity)
+	}
+	if identity.GroupIDs[0] != other.GroupIDs[0] {
+		t.Fatalf("expected existing group %q to be reused, got %q", other.GroupIDs[0], identity.GroupIDs[0])
+	}
+
+	// Creating the identity again is a no-op
+	again := vault.TestCreateIdentity(t, client, spec)
+	if again.EntityID != identity.EntityID || again.AliasID != identity.AliasID ||
+		!strutil.EquivalentSlices(again.GroupIDs, identity.GroupIDs) {
+		t.Fatalf("expected the same identity, got %#v and %#v", identity, again)
+	}
+
+	alias, err := client.Logical().Read("identity/entity-alias/id/" + identity.AliasID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alias.Data["name"] != "test-role" || alias.Data["canonical_id"] != identity.EntityID {
+		t.Fatalf("unexpected alias: %#v", alias.Data)
+	}
+	if metadata, _ := alias.Data["custom_metadata"].(map[string]interface{}); metadata["env"] != "test" {
+		t.Fatalf("unexpected alias metadata: %#v", alias.Data["custom_metadata"])
+	}
+
+	group, err := client.Logical().Read("identity/group/id/" + identity.GroupIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	members, _ := group.Data["member_entity_ids"].([]interface{})
+	if len(members) != 2 {
+		t.Fatalf("expected both entities to be members, got %#v", members)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/testhelpers"
 	ldaphelper "github.com/hashicorp/vault/helper/testhelpers/ldap"
 	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -36,21 +37,7 @@ func TestIdentityStore_ListGroupAlias(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mounts, err := client.Sys().ListAuth()
-	if err != nil {
-		t.Fatal(err)
-	}
-	var githubAccessor string
-	for k, v := range mounts {
-		t.Logf("key: %v\nmount: %#v", k, *v)
-		if k == "github/" {
-			githubAccessor = v.Accessor
-			break
-		}
-	}
-	if githubAccessor == "" {
-		t.Fatal("did not find github accessor")
-	}
+	githubAccessor := testhelpers.AuthMountAccessor(t, client, "github")
 
 	resp, err := client.Logical().Write("identity/group", map[string]interface{}{
 		"type": "external",
@@ -0,0 +1,376 @@
+package identity
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/credential/userpass"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+// The OpenID conformance suite harness is configured with the following
+// environment variables. It only runs if VAULT_OIDC_CONFORMANCE is set.
+const (
+	// conformanceEnvEnabled enables the harness.
+	conformanceEnvEnabled = "VAULT_OIDC_CONFORMANCE"
+
+	// conformanceEnvServer is the URL of a running conformance suite.
+	conformanceEnvServer = "VAULT_OIDC_CONFORMANCE_SERVER"
+
+	// conformanceEnvSuiteDir is the path to a checkout of the conformance
+	// suite, whose scripts/run-test-plan.py runner is used.
+	conformanceEnvSuiteDir = "VAULT_OIDC_CONFORMANCE_SUITE_DIR"
+
+	// conformanceEnvRunnerImage is the docker image the runner is run in. It
+	// must provide python3 and the dependencies of the runner.
+	conformanceEnvRunnerImage = "VAULT_OIDC_CONFORMANCE_RUNNER_IMAGE"
+
+	// conformanceEnvHost is the host the conformance suite reaches the
+	// cluster at. Defaults to 127.0.0.1.
+	conformanceEnvHost = "VAULT_OIDC_CONFORMANCE_HOST"
+
+	// conformanceEnvPort is the port of the listener of the first core. The
+	// other cores and the authorization endpoint use the following ports.
+	// Defaults to 28200.
+	conformanceEnvPort = "VAULT_OIDC_CONFORMANCE_PORT"
+
+	// conformanceEnvConfigDir is the directory the configuration of the
+	// suite and the exported results are written to. Defaults to a
+	// temporary directory.
+	conformanceEnvConfigDir = "VAULT_OIDC_CONFORMANCE_CONFIG_DIR"
+)
+
+const (
+	conformanceAlias       = "vault"
+	conformanceNumCores    = 2
+	conformanceExcerptSize = 40
+)
+
+// conformancePlans are the certification profiles run against the provider.
+// The basic profile uses static server metadata since the authorization
+// endpoint in the discovery document is served by the UI, which the suite
+// cannot log in to; the harness serves its own authorization endpoint
+// instead.
+var conformancePlans = []struct {
+	name string
+	plan string
+}{
+	{
+		name: "config",
+		plan: "oidcc-config-certification-test-plan",
+	},
+	{
+		name: "basic",
+		plan: "oidcc-basic-certification-test-plan[server_metadata=static][client_registration=static_client]",
+	},
+}
+
+// conformanceFailureRe matches the lines of the output of the runner
+// reporting failures.
+var conformanceFailureRe = regexp.MustCompile(`(?i)fail|error|warning|interrupted`)
+
+// TestOIDC_Conformance runs the certification profiles of the OpenID
+// conformance suite against a provider on a cluster listening on fixed
+// ports, so that the configuration of the suite is predictable.
+func TestOIDC_Conformance(t *testing.T) {
+	if os.Getenv(conformanceEnvEnabled) == "" {
+		t.Skipf("set %s to run the OpenID conformance suite", conformanceEnvEnabled)
+	}
+
+	server := requireConformanceEnv(t, conformanceEnvServer)
+	suiteDir := requireConformanceEnv(t, conformanceEnvSuiteDir)
+	image := requireConformanceEnv(t, conformanceEnvRunnerImage)
+	host := os.Getenv(conformanceEnvHost)
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	basePort := 28200
+	if raw := os.Getenv(conformanceEnvPort); raw != "" {
+		var err error
+		if basePort, err = strconv.Atoi(raw); err != nil {
+			t.Fatalf("invalid %s %q: %v", conformanceEnvPort, raw, err)
+		}
+	}
+	configDir := os.Getenv(conformanceEnvConfigDir)
+	if configDir == "" {
+		configDir = t.TempDir()
+	}
+
+	listenPorts := make([]int, conformanceNumCores)
+	for i := range listenPorts {
+		listenPorts[i] = basePort + i
+	}
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		NumCores:    conformanceNumCores,
+		HandlerFunc: vaulthttp.Handler,
+		ListenPorts: listenPorts,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	active := cluster.Cores[0].Client
+
+	redirectURI := strings.TrimSuffix(server, "/") + "/test/a/" + conformanceAlias + "/callback"
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password: testPassword,
+		EntityMetadata: map[string]string{
+			"email":        "test@hashicorp.com",
+			"phone_number": "123-456-7890",
+		},
+		ClientName:   "conformance",
+		RedirectURIs: []string{redirectURI},
+		Scopes: func(mountAccessor string) map[string]string {
+			return map[string]string{
+				"user": fmt.Sprintf(testUserScopeTemplate, mountAccessor),
+			}
+		},
+		ProviderName: "conformance",
+		Issuer:       fmt.Sprintf("https://%s:%d", host, basePort),
+	})
+
+	// The suite requires a second client
+	oidc := active.Identity().OIDC()
+	err := oidc.WriteClient("conformance2", &api.OIDCClient{
+		Key:          "test-key",
+		RedirectURIs: &[]string{redirectURI},
+		Assignments:  &[]string{"test-assignment"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client2, err := oidc.ReadClient("conformance2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = oidc.WriteProvider(fixture.ProviderName, &api.OIDCProvider{
+		AllowedClientIDs: &[]string{fixture.ClientID, client2.ClientID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve the authorization endpoint on behalf of the end user
+	resp, err := active.Logical().Write("auth/userpass/login/"+fixture.Username, map[string]interface{}{
+		"password": fixture.Password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userClient, err := active.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	userClient.SetToken(resp.Auth.ClientToken)
+
+	authPort := basePort + conformanceNumCores
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", authPort))
+	if err != nil {
+		t.Fatalf("error listening on the authorization endpoint port %d: %v", authPort, err)
+	}
+	authServer := &http.Server{
+		Handler: &conformanceAuthorizer{
+			t:            t,
+			client:       userClient,
+			provider:     fixture.ProviderName,
+			redirectURIs: []string{redirectURI},
+		},
+	}
+	go authServer.Serve(tls.NewListener(ln, cluster.Cores[0].TLSConfig))
+	defer authServer.Close()
+
+	authURL := fmt.Sprintf("https://%s:%d/authorize", host, authPort)
+	config := conformanceConfig(fixture, client2, authURL)
+	configJSON, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(configDir, "config.json")
+	if err := ioutil.WriteFile(configPath, configJSON, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("conformance suite configuration written to %s:\n%s", configPath, configJSON)
+
+	for _, tc := range conformancePlans {
+		t.Run(tc.name, func(t *testing.T) {
+			runConformancePlan(t, image, server, suiteDir, configDir, tc.name, tc.plan)
+		})
+	}
+}
+
+func requireConformanceEnv(t *testing.T, name string) string {
+	t.Helper()
+	value := os.Getenv(name)
+	if value == "" {
+		t.Fatalf("%s must be set to run the OpenID conformance suite", name)
+	}
+	return value
+}
+
+// conformanceConfig returns the configuration of the suite for the provider
+// of the fixture.
+func conformanceConfig(fixture *vault.TestOIDCProvider, client2 *api.OIDCClient, authURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"alias":       conformanceAlias,
+		"description": "Vault OIDC provider",
+		"server": map[string]interface{}{
+			"discoveryUrl":           fixture.Issuer + "/.well-known/openid-configuration",
+			"issuer":                 fixture.Issuer,
+			"authorization_endpoint": authURL,
+			"token_endpoint":         fixture.Issuer + "/token",
+			"userinfo_endpoint":      fixture.Issuer + "/userinfo",
+			"jwks_uri":               fixture.Issuer + "/.well-known/keys",
+		},
+		"client": map[string]interface{}{
+			"client_id":     fixture.ClientID,
+			"client_secret": fixture.ClientSecret,
+			"scope":         "openid user",
+		},
+		"client2": map[string]interface{}{
+			"client_id":     client2.ClientID,
+			"client_secret": client2.ClientSecret,
+			"scope":         "openid user",
+		},
+		// The authorization endpoint of the harness redirects right away,
+		// so the browser only has to wait for the callback
+		"browser": []interface{}{
+			map[string]interface{}{
+				"match": authURL + "*",
+				"tasks": []interface{}{
+					map[string]interface{}{
+						"task":     "Verify Complete",
+						"match":    "*/test/a/" + conformanceAlias + "/callback*",
+						"commands": []interface{}{[]interface{}{"wait", "id", "submission_complete", 10}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runConformancePlan runs the test plan with the runner of the suite in
+// docker, failing the test with an excerpt of its output if any module of
+// the plan fails.
+func runConformancePlan(t *testing.T, image, server, suiteDir, configDir, name, plan string) {
+	exportDir := filepath.Join("/config", "results-"+name)
+	cmd := exec.Command("docker", "run", "--rm", "--network", "host",
+		"-v", suiteDir+":/suite:ro",
+		"-v", configDir+":/config",
+		"-e", "CONFORMANCE_SERVER="+server,
+		"-e", "CONFORMANCE_DEV_MODE=1",
+		image,
+		"python3", "/suite/scripts/run-test-plan.py",
+		"--export-dir", exportDir,
+		plan, "/config/config.json")
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if err == nil {
+		t.Logf("%s profile passed, results exported to %s", name, filepath.Join(configDir, "results-"+name))
+		return
+	}
+
+	t.Errorf("%s profile failed: %v; results exported to %s\n%s",
+		name, err, filepath.Join(configDir, "results-"+name), conformanceExcerpt(output.Bytes()))
+}
+
+// conformanceExcerpt returns the lines of the output of the runner reporting
+// failures, along with its last lines, which hold the summary of the plan.
+func conformanceExcerpt(output []byte) string {
+	var lines, failures []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		lines = append(lines, line)
+		if conformanceFailureRe.MatchString(line) && len(failures) < conformanceExcerptSize {
+			failures = append(failures, line)
+		}
+	}
+
+	tail := lines
+	if len(tail) > conformanceExcerptSize {
+		tail = tail[len(tail)-conformanceExcerptSize:]
+	}
+	return fmt.Sprintf("failures:\n%s\n\nend of output:\n%s",
+		strings.Join(failures, "\n"), strings.Join(tail, "\n"))
+}
+
+// conformanceAuthorizer serves an authorization endpoint on behalf of an
+// authenticated end user: it forwards authorization requests to the provider
+// and redirects to the client with the resulting code or error.
+type conformanceAuthorizer struct {
+	t            *testing.T
+	client       *api.Client
+	provider     string
+	redirectURIs []string
+}
+
+func (a *conformanceAuthorizer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	resp, err := a.client.Logical().ReadRawWithData(
+		api.PathJoin("identity", "oidc", "provider", a.provider, "authorize"), query)
+	if resp == nil {
+		a.t.Logf("error forwarding authorization request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := jsonutil.DecodeJSONFromReader(resp.Body, &result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// Errors about the client or the redirect URI itself must not be
+	// redirected to it
+	redirectURI := query.Get("redirect_uri")
+	allowed := false
+	for _, uri := range a.redirectURIs {
+		if uri == redirectURI {
+			allowed = true
+		}
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("invalid redirect URI %q: %v", redirectURI, result), http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := u.Query()
+	for _, key := range []string{"code", "state", "error", "error_description"} {
+		if value, ok := result[key].(string); ok && value != "" {
+			params.Set(key, value)
+		}
+	}
+	u.RawQuery = params.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
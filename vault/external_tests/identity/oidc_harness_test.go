@@ -0,0 +1,103 @@
+package identity
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/credential/userpass"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+	"github.com/stretchr/testify/require"
+)
+
+// oidcTestServer is a single unsealed core served over TLS by an
+// httptest.Server. It starts much faster than a test cluster and is enough
+// for tests of the OIDC provider that only talk to the active node.
+//
+// Tests that exercise HA behavior, such as requests forwarded from a standby
+// or a failover between the authorization and token requests, still need the
+// full cluster from setupOIDCTestCluster.
+type oidcTestServer struct {
+	Core   *vault.Core
+	Client *api.Client
+
+	// URL is the address of the server, of the form https://127.0.0.1:port.
+	// It is also the API address of the core, which the issuer of providers
+	// defaults to.
+	URL string
+
+	// CACertPEM is the PEM-encoded certificate of the server, to be trusted
+	// by OIDC clients.
+	CACertPEM []byte
+}
+
+// newOIDCTestServer returns a started oidcTestServer with the userpass
+// credential backend registered and a client authenticated with the root
+// token. The server is closed when the test completes.
+func newOIDCTestServer(t *testing.T) *oidcTestServer {
+	t.Helper()
+
+	// The listener is created before the core so that its address can be
+	// used as the API address of the core
+	server := httptest.NewUnstartedServer(nil)
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	url := "https://" + addr.String()
+
+	core, _, rootToken := vault.TestCoreUnsealedWithConfig(t, &vault.CoreConfig{
+		RedirectAddr: url,
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+	})
+
+	server.Config.Handler = vaulthttp.Handler(&vault.HandlerProperties{
+		Core: core,
+		ListenerConfig: &configutil.Listener{
+			Address: addr.String(),
+		},
+	})
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caCertPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	config := api.DefaultConfig()
+	config.Address = url
+	err := config.ConfigureTLS(&api.TLSConfig{
+		CACertBytes: caCertPEM,
+	})
+	require.NoError(t, err)
+	client, err := api.NewClient(config)
+	require.NoError(t, err)
+	client.SetToken(rootToken)
+
+	return &oidcTestServer{
+		Core:      core,
+		Client:    client,
+		URL:       url,
+		CACertPEM: caCertPEM,
+	}
+}
+
+// Issuer returns the issuer of the given provider as advertised in its
+// discovery document.
+func (s *oidcTestServer) Issuer(t *testing.T, provider string) string {
+	t.Helper()
+
+	var discovery struct {
+		Issuer string `json:"issuer"`
+	}
+	err := s.Client.Logical().ReadJSONInto(
+		api.PathJoin("identity", "oidc", "provider", provider, ".well-known", "openid-configuration"), nil, &discovery)
+	require.NoError(t, err)
+	require.NotEmpty(t, discovery.Issuer)
+	return discovery.Issuer
+}
@@ -2,6 +2,7 @@ package identity
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,9 +13,13 @@ import (
 	"time"
 
 	"github.com/hashicorp/cap/oidc"
+	sockaddr "github.com/hashicorp/go-sockaddr"
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/helper/testhelpers"
 	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/internalshared/configutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/vault"
 	"github.com/stretchr/testify/require"
@@ -42,7 +47,8 @@ const (
 // TestOIDC_Auth_Code_Flow_Default_Resources tests the authorization
 // code flow using the default OIDC provider, default key, and allow_all
 // assignment. This ensures that the resources are created and usable with
-// an initial setup of Vault.
+// an initial setup of Vault. It runs on a cluster to also cover requests
+// made through a standby.
 func TestOIDC_Auth_Code_Flow_Default_Resources(t *testing.T) {
 	cluster := setupOIDCTestCluster(t, 2)
 	defer cluster.Cleanup()
@@ -50,34 +56,30 @@ func TestOIDC_Auth_Code_Flow_Default_Resources(t *testing.T) {
 	standby := cluster.Cores[1].Client
 
 	// Enable userpass auth and create a user
-	err := active.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
-		Type: "userpass",
-	})
-	require.NoError(t, err)
-	_, err = active.Logical().Write("auth/userpass/users/end-user", map[string]interface{}{
-		"password": testPassword,
-	})
-	require.NoError(t, err)
+	testhelpers.EnableUserpass(t, active, map[string]string{"end-user": testPassword})
 
 	// Create a confidential client
-	_, err = active.Logical().Write("identity/oidc/client/confidential", map[string]interface{}{
-		"redirect_uris":    []string{testRedirectURI},
-		"assignments":      []string{"allow_all"},
-		"id_token_ttl":     "1h",
-		"access_token_ttl": "30m",
+	idTokenTTL := int((1 * time.Hour).Seconds())
+	accessTokenTTL := int((30 * time.Minute).Seconds())
+	err := active.Identity().OIDC().WriteClient("confidential", &api.OIDCClient{
+		RedirectURIs:   &[]string{testRedirectURI},
+		Assignments:    &[]string{"allow_all"},
+		IDTokenTTL:     &idTokenTTL,
+		AccessTokenTTL: &accessTokenTTL,
 	})
 	require.NoError(t, err)
 
 	// Read the client ID and secret in order to configure the OIDC client
-	resp, err := active.Logical().Read("identity/oidc/client/confidential")
+	oidcClient, err := active.Identity().OIDC().ReadClient("confidential")
 	require.NoError(t, err)
-	clientID := resp.Data["client_id"].(string)
-	clientSecret := resp.Data["client_secret"].(string)
+	require.NotNil(t, oidcClient)
+	clientID := oidcClient.ClientID
+	clientSecret := oidcClient.ClientSecret
 
 	// We aren't going to open up a browser to facilitate the login and redirect
 	// from this test, so we'll log in via userpass and set the client's token as
 	// the token that results from the authentication.
-	resp, err = active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+	resp, err := active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
 		"password": testPassword,
 	})
 	require.NoError(t, err)
@@ -97,9 +99,8 @@ func TestOIDC_Auth_Code_Flow_Default_Resources(t *testing.T) {
 	var discovery struct {
 		Issuer string `json:"issuer"`
 	}
-	decodeRawRequest(t, active, http.MethodGet,
-		"/v1/identity/oidc/provider/default/.well-known/openid-configuration",
-		nil, &discovery)
+	require.NoError(t, active.Logical().ReadJSONInto(
+		"identity/oidc/provider/default/.well-known/openid-configuration", nil, &discovery))
 
 	// Create the client-side OIDC provider config
 	pc, err := oidc.NewConfig(discovery.Issuer, clientID,
@@ -185,6 +186,24 @@ func TestOIDC_Auth_Code_Flow_Default_Resources(t *testing.T) {
 				"namespace": "root"
 			}`, discovery.Issuer, clientID, entityID),
 		},
+		{
+			name: "standby: authorization code flow with max_age and prompt parameters",
+			args: args{
+				useStandby: true,
+				options: []oidc.Option{
+					oidc.WithScopes("openid"),
+					oidc.WithMaxAge(60),
+					oidc.WithPrompts(oidc.None),
+				},
+			},
+			expected: fmt.Sprintf(`{
+				"iss": "%s",
+				"aud": "%s",
+				"sub": "%s",
+				"namespace": "root",
+				"auth_time": %d
+			}`, discovery.Issuer, clientID, entityID, expectedAuthTime),
+		},
 	}
 
 	for _, tt := range tests {
@@ -205,15 +224,15 @@ func TestOIDC_Auth_Code_Flow_Default_Resources(t *testing.T) {
 			parsedAuthURL, err := url.Parse(authURL)
 			require.NoError(t, err)
 
-			// This replace only occurs because we're not using the browser in this test
-			authURLPath := strings.Replace(parsedAuthURL.Path, "/ui/vault/", "/v1/", 1)
+			// This trim only occurs because we're not using the browser in this test
+			authURLPath := strings.TrimPrefix(parsedAuthURL.Path, "/ui/vault/")
 
 			// Kick off the authorization code flow
 			var authResp struct {
 				Code  string `json:"code"`
 				State string `json:"state"`
 			}
-			decodeRawRequest(t, client, http.MethodGet, authURLPath, parsedAuthURL.Query(), &authResp)
+			require.NoError(t, client.Logical().ReadJSONInto(authURLPath, parsedAuthURL.Query(), &authResp))
 
 			// The returned state must match the OIDC client state
 			require.Equal(t, oidcRequest.State(), authResp.State)
@@ -267,120 +286,43 @@ func TestOIDC_Auth_Code_Flow_Default_Resources(t *testing.T) {
 // requirements of the OIDC spec. This test uses a confidential client which has
 // a client secret and authenticates to the token endpoint.
 func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
-	cluster := setupOIDCTestCluster(t, 2)
-	defer cluster.Cleanup()
-	active := cluster.Cores[0].Client
-	standby := cluster.Cores[1].Client
-
-	// Create an entity with some metadata
-	resp, err := active.Logical().Write("identity/entity", map[string]interface{}{
-		"name": "test-entity",
-		"metadata": map[string]string{
+	server := newOIDCTestServer(t)
+	active := server.Client
+
+	// Provision the OIDC provider with a confidential client
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password: testPassword,
+		Policies: map[string]string{
+			// Allows updating the provider
+			"test-policy": `
+				path "identity/oidc/provider/test-provider" {
+					capabilities = ["update"]
+				}
+			`,
+		},
+		EntityMetadata: map[string]string{
 			"email":        "test@hashicorp.com",
 			"phone_number": "123-456-7890",
 		},
+		ClientName:   "confidential",
+		ClientType:   "confidential",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes: func(mountAccessor string) map[string]string {
+			return map[string]string{
+				"groups": testGroupScopeTemplate,
+				"user":   fmt.Sprintf(testUserScopeTemplate, mountAccessor),
+			}
+		},
 	})
-	require.NoError(t, err)
-	entityID := resp.Data["id"].(string)
-
-	// Create a group
-	resp, err = active.Logical().Write("identity/group", map[string]interface{}{
-		"name":              "engineering",
-		"member_entity_ids": []string{entityID},
-	})
-	require.NoError(t, err)
-	groupID := resp.Data["id"].(string)
-
-	// Create a policy that allows updating the provider
-	err = active.Sys().PutPolicy("test-policy", `
-		path "identity/oidc/provider/test-provider" {
-			capabilities = ["update"]
-		}
-	`)
-	require.NoError(t, err)
-
-	// Enable userpass auth and create a user
-	err = active.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
-		Type: "userpass",
-	})
-	require.NoError(t, err)
-	_, err = active.Logical().Write("auth/userpass/users/end-user", map[string]interface{}{
-		"password":       testPassword,
-		"token_policies": "test-policy",
-	})
-	require.NoError(t, err)
-
-	// Get the userpass mount accessor
-	mounts, err := active.Sys().ListAuth()
-	require.NoError(t, err)
-	var mountAccessor string
-	for k, v := range mounts {
-		if k == "userpass/" {
-			mountAccessor = v.Accessor
-			break
-		}
-	}
-	require.NotEmpty(t, mountAccessor)
-
-	// Create an entity alias
-	_, err = active.Logical().Write("identity/entity-alias", map[string]interface{}{
-		"name":           "end-user",
-		"canonical_id":   entityID,
-		"mount_accessor": mountAccessor,
-	})
-	require.NoError(t, err)
-
-	// Create some custom scopes
-	_, err = active.Logical().Write("identity/oidc/scope/groups", map[string]interface{}{
-		"template": testGroupScopeTemplate,
-	})
-	require.NoError(t, err)
-	_, err = active.Logical().Write("identity/oidc/scope/user", map[string]interface{}{
-		"template": fmt.Sprintf(testUserScopeTemplate, mountAccessor),
-	})
-	require.NoError(t, err)
-
-	// Create a key
-	_, err = active.Logical().Write("identity/oidc/key/test-key", map[string]interface{}{
-		"allowed_client_ids": []string{"*"},
-		"algorithm":          "RS256",
-	})
-	require.NoError(t, err)
-
-	// Create an assignment
-	_, err = active.Logical().Write("identity/oidc/assignment/test-assignment", map[string]interface{}{
-		"entity_ids": []string{entityID},
-		"group_ids":  []string{groupID},
-	})
-	require.NoError(t, err)
-
-	// Create a confidential client
-	_, err = active.Logical().Write("identity/oidc/client/confidential", map[string]interface{}{
-		"key":              "test-key",
-		"redirect_uris":    []string{testRedirectURI},
-		"assignments":      []string{"test-assignment"},
-		"id_token_ttl":     "1h",
-		"access_token_ttl": "30m",
-	})
-	require.NoError(t, err)
-
-	// Read the client ID and secret in order to configure the OIDC client
-	resp, err = active.Logical().Read("identity/oidc/client/confidential")
-	require.NoError(t, err)
-	clientID := resp.Data["client_id"].(string)
-	clientSecret := resp.Data["client_secret"].(string)
-
-	// Create the OIDC provider
-	_, err = active.Logical().Write("identity/oidc/provider/test-provider", map[string]interface{}{
-		"allowed_client_ids": []string{clientID},
-		"scopes_supported":   []string{"user", "groups"},
-	})
-	require.NoError(t, err)
+	issuer := fixture.Issuer
+	entityID := fixture.EntityID
+	clientID := fixture.ClientID
+	clientSecret := fixture.ClientSecret
 
 	// We aren't going to open up a browser to facilitate the login and redirect
 	// from this test, so we'll log in via userpass and set the client's token as
 	// the token that results from the authentication.
-	resp, err = active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+	resp, err := active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
 		"password": testPassword,
 	})
 	require.NoError(t, err)
@@ -395,18 +337,10 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 	expectedAuthTime, err := strconv.Atoi(string(resp.Data["creation_time"].(json.Number)))
 	require.NoError(t, err)
 
-	// Read the issuer from the OIDC provider's discovery document
-	var discovery struct {
-		Issuer string `json:"issuer"`
-	}
-	decodeRawRequest(t, active, http.MethodGet,
-		"/v1/identity/oidc/provider/test-provider/.well-known/openid-configuration",
-		nil, &discovery)
-
 	// Create the client-side OIDC provider config
-	pc, err := oidc.NewConfig(discovery.Issuer, clientID,
+	pc, err := oidc.NewConfig(issuer, clientID,
 		oidc.ClientSecret(clientSecret), []oidc.Alg{oidc.RS256},
-		[]string{testRedirectURI}, oidc.WithProviderCA(string(cluster.CACertPEM)))
+		[]string{testRedirectURI}, oidc.WithProviderCA(string(server.CACertPEM)))
 	require.NoError(t, err)
 
 	// Create the client-side OIDC provider
@@ -419,8 +353,7 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 	require.NoError(t, err)
 
 	type args struct {
-		useStandby bool
-		options    []oidc.Option
+		options []oidc.Option
 	}
 	tests := []struct {
 		name     string
@@ -444,7 +377,7 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 						"email": "test@hashicorp.com",
 						"phone_number": "123-456-7890"
 					}
-				}`, discovery.Issuer, clientID, entityID),
+				}`, issuer, clientID, entityID),
 		},
 		{
 			name: "active: authorization code flow with additional scopes",
@@ -464,7 +397,7 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 					"phone_number": "123-456-7890"
 				},
 				"groups": ["engineering"]
-			}`, discovery.Issuer, clientID, entityID),
+			}`, issuer, clientID, entityID),
 		},
 		{
 			name: "active: authorization code flow with max_age parameter",
@@ -480,7 +413,7 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 				"sub": "%s",
 				"namespace": "root",
 				"auth_time": %d
-			}`, discovery.Issuer, clientID, entityID, expectedAuthTime),
+			}`, issuer, clientID, entityID, expectedAuthTime),
 		},
 		{
 			name: "active: authorization code flow with Proof Key for Code Exchange (PKCE)",
@@ -495,42 +428,18 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 				"aud": "%s",
 				"sub": "%s",
 				"namespace": "root"
-			}`, discovery.Issuer, clientID, entityID),
-		},
-		{
-			name: "standby: authorization code flow with additional scopes",
-			args: args{
-				useStandby: true,
-				options: []oidc.Option{
-					oidc.WithScopes("openid user groups"),
-				},
-			},
-			expected: fmt.Sprintf(`{
-				"iss": "%s",
-				"aud": "%s",
-				"sub": "%s",
-				"namespace": "root",
-				"username": "end-user",
-				"contact": {
-					"email": "test@hashicorp.com",
-					"phone_number": "123-456-7890"
-				},
-				"groups": ["engineering"]
-			}`, discovery.Issuer, clientID, entityID),
+			}`, issuer, clientID, entityID),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			client := active
-			if tt.args.useStandby {
-				client = standby
-			}
 			client.SetToken(clientToken)
 
 			// Update allowed client IDs before the authentication flow
-			_, err = client.Logical().Write("identity/oidc/provider/test-provider", map[string]interface{}{
-				"allowed_client_ids": []string{clientID},
+			err = client.Identity().OIDC().WriteProvider("test-provider", &api.OIDCProvider{
+				AllowedClientIDs: &[]string{clientID},
 			})
 			require.NoError(t, err)
 
@@ -544,15 +453,15 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 			parsedAuthURL, err := url.Parse(authURL)
 			require.NoError(t, err)
 
-			// This replace only occurs because we're not using the browser in this test
-			authURLPath := strings.Replace(parsedAuthURL.Path, "/ui/vault/", "/v1/", 1)
+			// This trim only occurs because we're not using the browser in this test
+			authURLPath := strings.TrimPrefix(parsedAuthURL.Path, "/ui/vault/")
 
 			// Kick off the authorization code flow
 			var authResp struct {
 				Code  string `json:"code"`
 				State string `json:"state"`
 			}
-			decodeRawRequest(t, client, http.MethodGet, authURLPath, parsedAuthURL.Query(), &authResp)
+			require.NoError(t, client.Logical().ReadJSONInto(authURLPath, parsedAuthURL.Query(), &authResp))
 
 			// The returned state must match the OIDC client state
 			require.Equal(t, oidcRequest.State(), authResp.State)
@@ -617,120 +526,43 @@ func TestOIDC_Auth_Code_Flow_Confidential_CAP_Client(t *testing.T) {
 // the OIDC spec. This test uses a public client which does not have a client secret
 // and always uses proof key for code exchange (PKCE).
 func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
-	cluster := setupOIDCTestCluster(t, 2)
-	defer cluster.Cleanup()
-	active := cluster.Cores[0].Client
-	standby := cluster.Cores[1].Client
-
-	// Create an entity with some metadata
-	resp, err := active.Logical().Write("identity/entity", map[string]interface{}{
-		"name": "test-entity",
-		"metadata": map[string]string{
+	server := newOIDCTestServer(t)
+	active := server.Client
+
+	// Provision the OIDC provider with a public client
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password: testPassword,
+		Policies: map[string]string{
+			// Allows updating the provider
+			"test-policy": `
+				path "identity/oidc/provider/test-provider" {
+					capabilities = ["update"]
+				}
+			`,
+		},
+		EntityMetadata: map[string]string{
 			"email":        "test@hashicorp.com",
 			"phone_number": "123-456-7890",
 		},
+		ClientName:   "public",
+		ClientType:   "public",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes: func(mountAccessor string) map[string]string {
+			return map[string]string{
+				"groups": testGroupScopeTemplate,
+				"user":   fmt.Sprintf(testUserScopeTemplate, mountAccessor),
+			}
+		},
 	})
-	require.NoError(t, err)
-	entityID := resp.Data["id"].(string)
-
-	// Create a group
-	resp, err = active.Logical().Write("identity/group", map[string]interface{}{
-		"name":              "engineering",
-		"member_entity_ids": []string{entityID},
-	})
-	require.NoError(t, err)
-	groupID := resp.Data["id"].(string)
-
-	// Create a policy that allows updating the provider
-	err = active.Sys().PutPolicy("test-policy", `
-		path "identity/oidc/provider/test-provider" {
-			capabilities = ["update"]
-		}
-	`)
-	require.NoError(t, err)
-
-	// Enable userpass auth and create a user
-	err = active.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
-		Type: "userpass",
-	})
-	require.NoError(t, err)
-	_, err = active.Logical().Write("auth/userpass/users/end-user", map[string]interface{}{
-		"password":       testPassword,
-		"token_policies": "test-policy",
-	})
-	require.NoError(t, err)
-
-	// Get the userpass mount accessor
-	mounts, err := active.Sys().ListAuth()
-	require.NoError(t, err)
-	var mountAccessor string
-	for k, v := range mounts {
-		if k == "userpass/" {
-			mountAccessor = v.Accessor
-			break
-		}
-	}
-	require.NotEmpty(t, mountAccessor)
-
-	// Create an entity alias
-	_, err = active.Logical().Write("identity/entity-alias", map[string]interface{}{
-		"name":           "end-user",
-		"canonical_id":   entityID,
-		"mount_accessor": mountAccessor,
-	})
-	require.NoError(t, err)
-
-	// Create some custom scopes
-	_, err = active.Logical().Write("identity/oidc/scope/groups", map[string]interface{}{
-		"template": testGroupScopeTemplate,
-	})
-	require.NoError(t, err)
-	_, err = active.Logical().Write("identity/oidc/scope/user", map[string]interface{}{
-		"template": fmt.Sprintf(testUserScopeTemplate, mountAccessor),
-	})
-	require.NoError(t, err)
-
-	// Create a key
-	_, err = active.Logical().Write("identity/oidc/key/test-key", map[string]interface{}{
-		"allowed_client_ids": []string{"*"},
-		"algorithm":          "RS256",
-	})
-	require.NoError(t, err)
-
-	// Create an assignment
-	_, err = active.Logical().Write("identity/oidc/assignment/test-assignment", map[string]interface{}{
-		"entity_ids": []string{entityID},
-		"group_ids":  []string{groupID},
-	})
-	require.NoError(t, err)
-
-	// Create a public client
-	_, err = active.Logical().Write("identity/oidc/client/public", map[string]interface{}{
-		"key":              "test-key",
-		"redirect_uris":    []string{testRedirectURI},
-		"assignments":      []string{"test-assignment"},
-		"id_token_ttl":     "1h",
-		"access_token_ttl": "30m",
-		"client_type":      "public",
-	})
-	require.NoError(t, err)
-
-	// Read the client ID in order to configure the OIDC client
-	resp, err = active.Logical().Read("identity/oidc/client/public")
-	require.NoError(t, err)
-	clientID := resp.Data["client_id"].(string)
-
-	// Create the OIDC provider
-	_, err = active.Logical().Write("identity/oidc/provider/test-provider", map[string]interface{}{
-		"allowed_client_ids": []string{clientID},
-		"scopes_supported":   []string{"user", "groups"},
-	})
-	require.NoError(t, err)
+	issuer := fixture.Issuer
+	entityID := fixture.EntityID
+	clientID := fixture.ClientID
+	require.Empty(t, fixture.ClientSecret)
 
 	// We aren't going to open up a browser to facilitate the login and redirect
 	// from this test, so we'll log in via userpass and set the client's token as
 	// the token that results from the authentication.
-	resp, err = active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+	resp, err := active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
 		"password": testPassword,
 	})
 	require.NoError(t, err)
@@ -745,18 +577,10 @@ func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
 	expectedAuthTime, err := strconv.Atoi(string(resp.Data["creation_time"].(json.Number)))
 	require.NoError(t, err)
 
-	// Read the issuer from the OIDC provider's discovery document
-	var discovery struct {
-		Issuer string `json:"issuer"`
-	}
-	decodeRawRequest(t, active, http.MethodGet,
-		"/v1/identity/oidc/provider/test-provider/.well-known/openid-configuration",
-		nil, &discovery)
-
 	// Create the client-side OIDC provider config with client secret intentionally empty
 	clientSecret := oidc.ClientSecret("")
-	pc, err := oidc.NewConfig(discovery.Issuer, clientID, clientSecret, []oidc.Alg{oidc.RS256},
-		[]string{testRedirectURI}, oidc.WithProviderCA(string(cluster.CACertPEM)))
+	pc, err := oidc.NewConfig(issuer, clientID, clientSecret, []oidc.Alg{oidc.RS256},
+		[]string{testRedirectURI}, oidc.WithProviderCA(string(server.CACertPEM)))
 	require.NoError(t, err)
 
 	// Create the client-side OIDC provider
@@ -765,8 +589,7 @@ func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
 	defer p.Done()
 
 	type args struct {
-		useStandby bool
-		options    []oidc.Option
+		options []oidc.Option
 	}
 	tests := []struct {
 		name     string
@@ -790,7 +613,7 @@ func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
 						"email": "test@hashicorp.com",
 						"phone_number": "123-456-7890"
 					}
-				}`, discovery.Issuer, clientID, entityID),
+				}`, issuer, clientID, entityID),
 		},
 		{
 			name: "active: authorization code flow with additional scopes",
@@ -810,7 +633,7 @@ func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
 					"phone_number": "123-456-7890"
 				},
 				"groups": ["engineering"]
-			}`, discovery.Issuer, clientID, entityID),
+			}`, issuer, clientID, entityID),
 		},
 		{
 			name: "active: authorization code flow with max_age parameter",
@@ -826,42 +649,18 @@ func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
 				"sub": "%s",
 				"namespace": "root",
 				"auth_time": %d
-			}`, discovery.Issuer, clientID, entityID, expectedAuthTime),
-		},
-		{
-			name: "standby: authorization code flow with additional scopes",
-			args: args{
-				useStandby: true,
-				options: []oidc.Option{
-					oidc.WithScopes("openid user groups"),
-				},
-			},
-			expected: fmt.Sprintf(`{
-				"iss": "%s",
-				"aud": "%s",
-				"sub": "%s",
-				"namespace": "root",
-				"username": "end-user",
-				"contact": {
-					"email": "test@hashicorp.com",
-					"phone_number": "123-456-7890"
-				},
-				"groups": ["engineering"]
-			}`, discovery.Issuer, clientID, entityID),
+			}`, issuer, clientID, entityID, expectedAuthTime),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			client := active
-			if tt.args.useStandby {
-				client = standby
-			}
 			client.SetToken(clientToken)
 
 			// Update allowed client IDs before the authentication flow
-			_, err = client.Logical().Write("identity/oidc/provider/test-provider", map[string]interface{}{
-				"allowed_client_ids": []string{clientID},
+			err = client.Identity().OIDC().WriteProvider("test-provider", &api.OIDCProvider{
+				AllowedClientIDs: &[]string{clientID},
 			})
 			require.NoError(t, err)
 
@@ -880,15 +679,15 @@ func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
 			parsedAuthURL, err := url.Parse(authURL)
 			require.NoError(t, err)
 
-			// This replace only occurs because we're not using the browser in this test
-			authURLPath := strings.Replace(parsedAuthURL.Path, "/ui/vault/", "/v1/", 1)
+			// This trim only occurs because we're not using the browser in this test
+			authURLPath := strings.TrimPrefix(parsedAuthURL.Path, "/ui/vault/")
 
 			// Kick off the authorization code flow
 			var authResp struct {
 				Code  string `json:"code"`
 				State string `json:"state"`
 			}
-			decodeRawRequest(t, client, http.MethodGet, authURLPath, parsedAuthURL.Query(), &authResp)
+			require.NoError(t, client.Logical().ReadJSONInto(authURLPath, parsedAuthURL.Query(), &authResp))
 
 			// The returned state must match the OIDC client state
 			require.Equal(t, oidcRequest.State(), authResp.State)
@@ -947,6 +746,670 @@ func TestOIDC_Auth_Code_Flow_Public_CAP_Client(t *testing.T) {
 	}
 }
 
+// TestOIDC_Auth_Code_Flow_Failover tests the authorization code flow across a
+// failover of the active node. Tokens obtained from a code exchange completed
+// before the failover remain valid, while authorization codes that were not
+// exchanged are lost since they're only kept in memory by the active node.
+func TestOIDC_Auth_Code_Flow_Failover(t *testing.T) {
+	cluster := setupOIDCTestCluster(t, 2)
+	defer cluster.Cleanup()
+	active := cluster.Cores[0].Client
+
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password:     testPassword,
+		RedirectURIs: []string{testRedirectURI},
+	})
+
+	resp, err := active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+		"password": testPassword,
+	})
+	require.NoError(t, err)
+	client, err := active.Clone()
+	require.NoError(t, err)
+	client.SetToken(resp.Auth.ClientToken)
+
+	pc, err := oidc.NewConfig(fixture.Issuer, fixture.ClientID,
+		oidc.ClientSecret(fixture.ClientSecret), []oidc.Alg{oidc.RS256},
+		[]string{testRedirectURI}, oidc.WithProviderCA(string(cluster.CACertPEM)))
+	require.NoError(t, err)
+	p, err := oidc.NewProvider(pc)
+	require.NoError(t, err)
+	defer p.Done()
+
+	authorize := func() (oidc.Request, string) {
+		t.Helper()
+		oidcRequest, err := oidc.NewRequest(10*time.Minute, testRedirectURI, oidc.WithScopes("openid"))
+		require.NoError(t, err)
+		authURL, err := p.AuthURL(context.Background(), oidcRequest)
+		require.NoError(t, err)
+		parsedAuthURL, err := url.Parse(authURL)
+		require.NoError(t, err)
+
+		var authResp struct {
+			Code  string `json:"code"`
+			State string `json:"state"`
+		}
+		require.NoError(t, client.Logical().ReadJSONInto(
+			strings.TrimPrefix(parsedAuthURL.Path, "/ui/vault/"), parsedAuthURL.Query(), &authResp))
+		require.Equal(t, oidcRequest.State(), authResp.State)
+		return oidcRequest, authResp.Code
+	}
+
+	// Complete one exchange and leave another code unexchanged
+	exchangedRequest, code := authorize()
+	token, err := p.Exchange(context.Background(), exchangedRequest, exchangedRequest.State(), code)
+	require.NoError(t, err)
+	pendingRequest, pendingCode := authorize()
+
+	newActive := cluster.StepDownActive(t)
+	require.Equal(t, 1, newActive)
+
+	// The tokens from the exchange are still valid
+	claims, err := p.VerifyIDToken(context.Background(), token.IDToken(), exchangedRequest)
+	require.NoError(t, err)
+	require.Equal(t, fixture.EntityID, claims["sub"])
+	userInfo := make(map[string]interface{})
+	require.NoError(t, p.UserInfo(context.Background(), token.StaticTokenSource(), fixture.EntityID, &userInfo))
+
+	// The unexchanged code was lost along with the memory of the former
+	// active node
+	_, err = p.Exchange(context.Background(), pendingRequest, pendingRequest.State(), pendingCode)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid_grant")
+}
+
+// TestOIDC_Assignment_Revoked_Standby tests that removing an entity from the
+// group of an assignment on the active node denies the next token request
+// made through a standby, even though the assignment was just evaluated for
+// the entity.
+func TestOIDC_Assignment_Revoked_Standby(t *testing.T) {
+	cluster := setupOIDCTestCluster(t, 2)
+	defer cluster.Cleanup()
+	active := cluster.Cores[0].Client
+	standby := cluster.Cores[1].Client
+
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password:     testPassword,
+		RedirectURIs: []string{testRedirectURI},
+	})
+
+	// Only authorize the entity through its group
+	_, err := active.Logical().Write("identity/oidc/assignment/test-assignment", map[string]interface{}{
+		"entity_ids": []string{},
+		"group_ids":  []string{fixture.GroupID},
+	})
+	require.NoError(t, err)
+
+	resp, err := standby.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+		"password": testPassword,
+	})
+	require.NoError(t, err)
+	user, err := standby.Clone()
+	require.NoError(t, err)
+	user.SetToken(resp.Auth.ClientToken)
+	anonymous, err := standby.Clone()
+	require.NoError(t, err)
+	anonymous.ClearToken()
+
+	providerPath := "identity/oidc/provider/" + fixture.ProviderName
+	authorize := func() string {
+		t.Helper()
+		var authResp struct {
+			Code string `json:"code"`
+		}
+		require.NoError(t, user.Logical().ReadJSONInto(providerPath+"/authorize", url.Values{
+			"client_id":     {fixture.ClientID},
+			"scope":         {"openid"},
+			"redirect_uri":  {testRedirectURI},
+			"response_type": {"code"},
+			"state":         {"test-state"},
+		}, &authResp))
+		require.NotEmpty(t, authResp.Code)
+		return authResp.Code
+	}
+	exchange := func(code string) (*api.Response, error) {
+		t.Helper()
+		req := anonymous.NewRequest(http.MethodPost, "/v1/"+providerPath+"/token")
+		req.Headers = make(http.Header)
+		req.Headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString(
+			[]byte(fixture.ClientID+":"+fixture.ClientSecret)))
+		require.NoError(t, req.SetJSONBody(map[string]interface{}{
+			"grant_type":   "authorization_code",
+			"code":         code,
+			"redirect_uri": testRedirectURI,
+		}))
+		return anonymous.RawRequest(req)
+	}
+
+	tokenResp, err := exchange(authorize())
+	require.NoError(t, err)
+	tokenResp.Body.Close()
+
+	// Remove the entity from the group right after authorizing a code for it
+	code := authorize()
+	_, err = active.Logical().Write("identity/group/id/"+fixture.GroupID, map[string]interface{}{
+		"member_entity_ids": []string{},
+	})
+	require.NoError(t, err)
+
+	tokenResp, err = exchange(code)
+	require.Error(t, err)
+	require.NotNil(t, tokenResp)
+	defer tokenResp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, tokenResp.StatusCode)
+	var errResp struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	require.NoError(t, json.NewDecoder(tokenResp.Body).Decode(&errResp))
+	require.Equal(t, "invalid_request", errResp.Error)
+	require.Contains(t, errResp.Description, "not authorized by client assignment")
+}
+
+// TestOIDC_Patch tests that the OIDC configuration objects can be patched
+// with JSON merge patches, which preserve the fields that aren't patched.
+func TestOIDC_Patch(t *testing.T) {
+	server := newOIDCTestServer(t)
+	client := server.Client
+
+	_, err := client.Logical().Write("identity/oidc/assignment/test-assignment", map[string]interface{}{
+		"entity_ids": []string{"entity-1"},
+		"group_ids":  []string{"group-1"},
+	})
+	require.NoError(t, err)
+	resp, err := client.Logical().Patch("identity/oidc/assignment/test-assignment", map[string]interface{}{
+		"group_ids": []string{"group-2"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"entity-1"}, resp.Data["entity_ids"])
+	require.Equal(t, []interface{}{"group-2"}, resp.Data["group_ids"])
+
+	_, err = client.Logical().Write("identity/oidc/scope/test-scope", map[string]interface{}{
+		"template":    testGroupScopeTemplate,
+		"description": "groups",
+	})
+	require.NoError(t, err)
+	resp, err = client.Logical().Patch("identity/oidc/scope/test-scope", map[string]interface{}{
+		"description": "group names",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "group names", resp.Data["description"])
+	require.Equal(t, testGroupScopeTemplate, resp.Data["template"])
+
+	_, err = client.Logical().Write("identity/oidc/client/test-client", map[string]interface{}{
+		"redirect_uris": []string{testRedirectURI},
+		"assignments":   []string{"test-assignment"},
+		"id_token_ttl":  "1h",
+	})
+	require.NoError(t, err)
+	created, err := client.Logical().Read("identity/oidc/client/test-client")
+	require.NoError(t, err)
+	clientID := created.Data["client_id"].(string)
+
+	resp, err = client.Logical().Patch("identity/oidc/client/test-client", map[string]interface{}{
+		"redirect_uris": []string{"https://127.0.0.1:8252/callback"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"https://127.0.0.1:8252/callback"}, resp.Data["redirect_uris"])
+	require.Equal(t, []interface{}{"test-assignment"}, resp.Data["assignments"])
+	require.Equal(t, json.Number("3600"), resp.Data["id_token_ttl"])
+	require.Equal(t, clientID, resp.Data["client_id"])
+	require.Equal(t, created.Data["client_secret"], resp.Data["client_secret"])
+
+	// Fields set to null are reset to their default value
+	resp, err = client.Logical().Patch("identity/oidc/client/test-client", map[string]interface{}{
+		"id_token_ttl": nil,
+	})
+	require.NoError(t, err)
+	require.Equal(t, json.Number("86400"), resp.Data["id_token_ttl"])
+	require.Equal(t, []interface{}{"https://127.0.0.1:8252/callback"}, resp.Data["redirect_uris"])
+
+	// Immutable fields can only be patched with their current value
+	for field, value := range map[string]interface{}{
+		"client_id":   "patched",
+		"key":         "patched",
+		"client_type": "public",
+	} {
+		_, err = client.Logical().Patch("identity/oidc/client/test-client", map[string]interface{}{
+			field: value,
+		})
+		require.Error(t, err, field)
+		require.Contains(t, err.Error(), "immutable", field)
+	}
+	resp, err = client.Logical().Patch("identity/oidc/client/test-client", map[string]interface{}{
+		"client_id": clientID,
+		"key":       "default",
+	})
+	require.NoError(t, err)
+	require.Equal(t, clientID, resp.Data["client_id"])
+
+	_, err = client.Logical().Write("identity/oidc/provider/test-provider", map[string]interface{}{
+		"allowed_client_ids": []string{clientID},
+		"scopes_supported":   []string{"test-scope"},
+	})
+	require.NoError(t, err)
+	resp, err = client.Logical().Patch("identity/oidc/provider/test-provider", map[string]interface{}{
+		"scopes_supported": nil,
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Data["scopes_supported"])
+	require.Equal(t, []interface{}{clientID}, resp.Data["allowed_client_ids"])
+
+	// Objects must exist to be patched
+	_, err = client.Logical().Patch("identity/oidc/client/missing", map[string]interface{}{
+		"redirect_uris": []string{testRedirectURI},
+	})
+	var respErr *api.ResponseError
+	require.ErrorAs(t, err, &respErr)
+	require.Equal(t, http.StatusNotFound, respErr.StatusCode)
+}
+
+// TestOIDC_Provider_Audit_RemoteAddress tests that audit entries of requests
+// to the provider endpoints that are forwarded by a standby have the client
+// address resolved from the X-Forwarded-For header of a trusted proxy.
+func TestOIDC_Provider_Audit_RemoteAddress(t *testing.T) {
+	proxyAddr, err := sockaddr.NewIPAddr("127.0.0.1")
+	require.NoError(t, err)
+
+	coreConfig := &vault.CoreConfig{}
+	vault.AddTestInmemAudit(coreConfig)
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		NumCores: 2,
+		HandlerFunc: func(props *vault.HandlerProperties) http.Handler {
+			return vaulthttp.WrapForwardedForHandler(vaulthttp.Handler(props), &configutil.Listener{
+				XForwardedForAuthorizedAddrs: []*sockaddr.SockAddrMarshaler{
+					{SockAddr: proxyAddr},
+				},
+			})
+		},
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	active := cluster.Cores[0].Client
+	standby := cluster.Cores[1].Client
+
+	err = active.Sys().EnableAuditWithOptions("inmem", &api.EnableAuditOptions{
+		Type: vault.TestInmemAuditType,
+	})
+	require.NoError(t, err)
+
+	// Make a token request without client credentials through the standby
+	req := standby.NewRequest(http.MethodPost, "/v1/identity/oidc/provider/default/token")
+	req.Headers = make(http.Header)
+	req.Headers.Set("X-Forwarded-For", "203.0.113.7")
+	require.NoError(t, req.SetJSONBody(map[string]interface{}{
+		"grant_type": "authorization_code",
+		"code":       "not-a-valid-code",
+	}))
+	resp, err := standby.RawRequest(req)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	for _, entryType := range []string{"request", "response"} {
+		cluster.RequireAuditContains(t, func(entry *audit.AuditResponseEntry) bool {
+			return entry.Type == entryType &&
+				entry.Request.Path == "identity/oidc/provider/default/token" &&
+				entry.Request.RemoteAddr == "203.0.113.7"
+		})
+	}
+}
+
+// TestOIDC_UserInfo_BearerErrors tests that the userinfo endpoint rejects an
+// expired access token and an access token of another provider with the
+// error responses of RFC 6750, for both its GET and POST forms.
+func TestOIDC_UserInfo_BearerErrors(t *testing.T) {
+	server := newOIDCTestServer(t)
+	active := server.Client
+
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password:       testPassword,
+		RedirectURIs:   []string{testRedirectURI},
+		AccessTokenTTL: 3 * time.Second,
+	})
+
+	// Create another provider allowing the client
+	err := active.Identity().OIDC().WriteProvider("other-provider", &api.OIDCProvider{
+		AllowedClientIDs: &[]string{fixture.ClientID},
+	})
+	require.NoError(t, err)
+	otherIssuer := server.Issuer(t, "other-provider")
+
+	resp, err := active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+		"password": testPassword,
+	})
+	require.NoError(t, err)
+	user, err := active.Clone()
+	require.NoError(t, err)
+	user.SetToken(resp.Auth.ClientToken)
+	anonymous, err := active.Clone()
+	require.NoError(t, err)
+	anonymous.ClearToken()
+
+	pc, err := oidc.NewConfig(fixture.Issuer, fixture.ClientID,
+		oidc.ClientSecret(fixture.ClientSecret), []oidc.Alg{oidc.RS256},
+		[]string{testRedirectURI}, oidc.WithProviderCA(string(server.CACertPEM)))
+	require.NoError(t, err)
+	p, err := oidc.NewProvider(pc)
+	require.NoError(t, err)
+	defer p.Done()
+
+	// Get an access token from the authorization code flow
+	oidcRequest, err := oidc.NewRequest(10*time.Minute, testRedirectURI, oidc.WithScopes("openid"))
+	require.NoError(t, err)
+	authURL, err := p.AuthURL(context.Background(), oidcRequest)
+	require.NoError(t, err)
+	parsedAuthURL, err := url.Parse(authURL)
+	require.NoError(t, err)
+	var authResp struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	require.NoError(t, user.Logical().ReadJSONInto(
+		strings.TrimPrefix(parsedAuthURL.Path, "/ui/vault/"), parsedAuthURL.Query(), &authResp))
+	token, err := p.Exchange(context.Background(), oidcRequest, authResp.State, authResp.Code)
+	require.NoError(t, err)
+	accessToken, err := token.StaticTokenSource().Token()
+	require.NoError(t, err)
+
+	requireBearerError := func(provider, expectedHeader string) {
+		t.Helper()
+
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			req := anonymous.NewRequest(method, "/v1/identity/oidc/provider/"+provider+"/userinfo")
+			req.Headers = make(http.Header)
+			req.Headers.Set("Authorization", "Bearer "+accessToken.AccessToken)
+			resp, err := anonymous.RawRequest(req)
+			require.Error(t, err, method)
+			require.NotNil(t, resp, method)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode, method)
+			require.Equal(t, expectedHeader, resp.Header.Get("WWW-Authenticate"), method)
+
+			var errResp struct {
+				Error string `json:"error"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp), method)
+			require.Equal(t, "invalid_token", errResp.Error, method)
+		}
+	}
+
+	// The access token of the provider isn't valid for another provider
+	requireBearerError("other-provider", fmt.Sprintf("Bearer realm=%q, error=%q, error_description=%q",
+		otherIssuer, "invalid_token", "access token was not issued by the provider"))
+
+	// The access token is valid until it expires
+	userInfo := make(map[string]interface{})
+	require.NoError(t, p.UserInfo(context.Background(), token.StaticTokenSource(), fixture.EntityID, &userInfo))
+
+	time.Sleep(4 * time.Second)
+	requireBearerError(fixture.ProviderName, fmt.Sprintf("Bearer realm=%q, error=%q, error_description=%q",
+		fixture.Issuer, "invalid_token", "access token is expired or invalid"))
+	require.Error(t, p.UserInfo(context.Background(), token.StaticTokenSource(), fixture.EntityID, &userInfo))
+}
+
+// TestOIDC_Provider_JWKS_Rotation tests that the keys of a provider are
+// published with their use and algorithm across repeated rotations, that
+// rotated keys stop being published once their verification TTL has passed,
+// and that a relying party still validates fresh tokens.
+func TestOIDC_Provider_JWKS_Rotation(t *testing.T) {
+	server := newOIDCTestServer(t)
+	active := server.Client
+
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password:     testPassword,
+		RedirectURIs: []string{testRedirectURI},
+	})
+
+	readKeys := func() map[string]string {
+		t.Helper()
+
+		var jwks struct {
+			Keys []struct {
+				KeyID     string `json:"kid"`
+				Use       string `json:"use"`
+				Algorithm string `json:"alg"`
+			} `json:"keys"`
+		}
+		require.NoError(t, active.Logical().ReadJSONInto(
+			api.PathJoin("identity", "oidc", "provider", fixture.ProviderName, ".well-known", "keys"), nil, &jwks))
+		keys := make(map[string]string)
+		for _, key := range jwks.Keys {
+			require.Equal(t, "sig", key.Use)
+			keys[key.KeyID] = key.Algorithm
+		}
+		return keys
+	}
+
+	for n := 0; n < 3; n++ {
+		_, err := active.Logical().Write("identity/oidc/key/test-key/rotate", map[string]interface{}{
+			"verification_ttl": "1s",
+		})
+		require.NoError(t, err)
+	}
+	before := readKeys()
+	require.Len(t, before, 5)
+
+	// Once the verification TTL has passed, documents rendered afterwards
+	// only publish the signing and next signing keys, with the same key IDs
+	time.Sleep(2 * time.Second)
+	err := active.Identity().OIDC().WriteProvider(fixture.ProviderName, &api.OIDCProvider{
+		AllowedClientIDs: &[]string{fixture.ClientID},
+	})
+	require.NoError(t, err)
+	after := readKeys()
+	require.Len(t, after, 2)
+	for keyID, algorithm := range after {
+		require.Equal(t, "RS256", algorithm)
+		require.Contains(t, before, keyID)
+	}
+
+	// The relying party validates tokens signed by the current key
+	resp, err := active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+		"password": testPassword,
+	})
+	require.NoError(t, err)
+	user, err := active.Clone()
+	require.NoError(t, err)
+	user.SetToken(resp.Auth.ClientToken)
+
+	pc, err := oidc.NewConfig(fixture.Issuer, fixture.ClientID,
+		oidc.ClientSecret(fixture.ClientSecret), []oidc.Alg{oidc.RS256},
+		[]string{testRedirectURI}, oidc.WithProviderCA(string(server.CACertPEM)))
+	require.NoError(t, err)
+	p, err := oidc.NewProvider(pc)
+	require.NoError(t, err)
+	defer p.Done()
+
+	oidcRequest, err := oidc.NewRequest(10*time.Minute, testRedirectURI, oidc.WithScopes("openid"))
+	require.NoError(t, err)
+	authURL, err := p.AuthURL(context.Background(), oidcRequest)
+	require.NoError(t, err)
+	parsedAuthURL, err := url.Parse(authURL)
+	require.NoError(t, err)
+	var authResp struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	require.NoError(t, user.Logical().ReadJSONInto(
+		strings.TrimPrefix(parsedAuthURL.Path, "/ui/vault/"), parsedAuthURL.Query(), &authResp))
+	token, err := p.Exchange(context.Background(), oidcRequest, authResp.State, authResp.Code)
+	require.NoError(t, err)
+
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(string(token.IDToken()), ".")[0])
+	require.NoError(t, err)
+	var idTokenHeader struct {
+		KeyID string `json:"kid"`
+	}
+	require.NoError(t, json.Unmarshal(header, &idTokenHeader))
+	require.Contains(t, after, idTokenHeader.KeyID)
+}
+
+// TestOIDC_Auth_Code_Flow_CAP_Client_Algorithms tests the authorization code
+// flow with the CAP OIDC client for every signing algorithm of named keys. The
+// provider must publish keys of the right type and curve, advertise the
+// algorithm of the client's key, and keep the previous public key of a
+// rotation or an algorithm change for the verification TTL.
+func TestOIDC_Auth_Code_Flow_CAP_Client_Algorithms(t *testing.T) {
+	tests := []struct {
+		alg   string
+		kty   string
+		curve string
+	}{
+		{alg: "RS256", kty: "RSA"},
+		{alg: "RS384", kty: "RSA"},
+		{alg: "RS512", kty: "RSA"},
+		{alg: "ES256", kty: "EC", curve: "P-256"},
+		{alg: "ES384", kty: "EC", curve: "P-384"},
+		{alg: "ES512", kty: "EC", curve: "P-521"},
+		{alg: "EdDSA", kty: "OKP", curve: "Ed25519"},
+	}
+
+	type jwk struct {
+		KeyID     string `json:"kid"`
+		KeyType   string `json:"kty"`
+		Curve     string `json:"crv"`
+		Algorithm string `json:"alg"`
+		Use       string `json:"use"`
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.alg, func(t *testing.T) {
+			server := newOIDCTestServer(t)
+			active := server.Client
+
+			fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+				Password:     testPassword,
+				KeyAlgorithm: tt.alg,
+				RedirectURIs: []string{testRedirectURI},
+			})
+			providerPath := api.PathJoin("identity", "oidc", "provider", fixture.ProviderName)
+
+			readKeys := func() map[string]jwk {
+				t.Helper()
+
+				var jwks struct {
+					Keys []jwk `json:"keys"`
+				}
+				require.NoError(t, active.Logical().ReadJSONInto(api.PathJoin(providerPath, ".well-known", "keys"), nil, &jwks))
+				keys := make(map[string]jwk)
+				for _, key := range jwks.Keys {
+					keys[key.KeyID] = key
+				}
+				return keys
+			}
+
+			// The signing and next signing keys are published with the type
+			// and curve of the algorithm
+			keys := readKeys()
+			require.Len(t, keys, 2)
+			for _, key := range keys {
+				require.Equal(t, tt.kty, key.KeyType)
+				require.Equal(t, tt.curve, key.Curve)
+				require.Equal(t, tt.alg, key.Algorithm)
+				require.Equal(t, "sig", key.Use)
+			}
+
+			// Only the algorithm of the client's key is advertised
+			var discovery struct {
+				IDTokenAlgs []string `json:"id_token_signing_alg_values_supported"`
+			}
+			require.NoError(t, active.Logical().ReadJSONInto(api.PathJoin(providerPath, ".well-known", "openid-configuration"), nil, &discovery))
+			require.Equal(t, []string{tt.alg}, discovery.IDTokenAlgs)
+
+			resp, err := active.Logical().Write("auth/userpass/login/end-user", map[string]interface{}{
+				"password": testPassword,
+			})
+			require.NoError(t, err)
+			user, err := active.Clone()
+			require.NoError(t, err)
+			user.SetToken(resp.Auth.ClientToken)
+
+			// exchange runs the authorization code flow, in which the relying
+			// party verifies the ID token with the published keys, and
+			// returns the key ID of the ID token
+			exchange := func() string {
+				t.Helper()
+
+				pc, err := oidc.NewConfig(fixture.Issuer, fixture.ClientID,
+					oidc.ClientSecret(fixture.ClientSecret), []oidc.Alg{oidc.Alg(tt.alg)},
+					[]string{testRedirectURI}, oidc.WithProviderCA(string(server.CACertPEM)))
+				require.NoError(t, err)
+				p, err := oidc.NewProvider(pc)
+				require.NoError(t, err)
+				defer p.Done()
+
+				oidcRequest, err := oidc.NewRequest(10*time.Minute, testRedirectURI, oidc.WithScopes("openid"))
+				require.NoError(t, err)
+				authURL, err := p.AuthURL(context.Background(), oidcRequest)
+				require.NoError(t, err)
+				parsedAuthURL, err := url.Parse(authURL)
+				require.NoError(t, err)
+				var authResp struct {
+					Code  string `json:"code"`
+					State string `json:"state"`
+				}
+				require.NoError(t, user.Logical().ReadJSONInto(
+					strings.TrimPrefix(parsedAuthURL.Path, "/ui/vault/"), parsedAuthURL.Query(), &authResp))
+				token, err := p.Exchange(context.Background(), oidcRequest, authResp.State, authResp.Code)
+				require.NoError(t, err)
+
+				claims := make(map[string]interface{})
+				require.NoError(t, token.IDToken().Claims(&claims))
+				require.Equal(t, fixture.EntityID, claims["sub"])
+				require.NoError(t, p.UserInfo(context.Background(), token.StaticTokenSource(), fixture.EntityID, &claims))
+
+				header, err := base64.RawURLEncoding.DecodeString(strings.Split(string(token.IDToken()), ".")[0])
+				require.NoError(t, err)
+				var idTokenHeader struct {
+					KeyID     string `json:"kid"`
+					Algorithm string `json:"alg"`
+				}
+				require.NoError(t, json.Unmarshal(header, &idTokenHeader))
+				require.Equal(t, tt.alg, idTokenHeader.Algorithm)
+				return idTokenHeader.KeyID
+			}
+			signedBy := exchange()
+			require.Contains(t, keys, signedBy)
+
+			// The previous signing key stays published after a rotation
+			_, err = active.Logical().Write("identity/oidc/key/test-key/rotate", nil)
+			require.NoError(t, err)
+			keys = readKeys()
+			require.Len(t, keys, 3)
+			require.Contains(t, keys, signedBy)
+			require.NotEqual(t, signedBy, exchange())
+
+			// Changing the algorithm rotates the key immediately. The previous
+			// signing key stays published, while the previous next signing
+			// key, which never signed anything, is dropped.
+			otherAlg := "ES256"
+			if tt.alg == otherAlg {
+				otherAlg = "RS256"
+			}
+			signedBy = exchange()
+			_, err = active.Logical().Write("identity/oidc/key/test-key", map[string]interface{}{
+				"algorithm": otherAlg,
+			})
+			require.NoError(t, err)
+			keys = readKeys()
+			require.Contains(t, keys, signedBy)
+			require.Equal(t, tt.alg, keys[signedBy].Algorithm)
+			algs := make(map[string]int)
+			for _, key := range keys {
+				algs[key.Algorithm]++
+			}
+			require.Equal(t, 2, algs[otherAlg])
+		})
+	}
+}
+
+// setupOIDCTestCluster returns a started cluster with the given number of
+// cores. Tests that don't need a standby or a failover should use the faster
+// newOIDCTestServer instead.
 func setupOIDCTestCluster(t *testing.T, numCores int) *vault.TestCluster {
 	t.Helper()
 
@@ -966,20 +1429,50 @@ func setupOIDCTestCluster(t *testing.T, numCores int) *vault.TestCluster {
 	return cluster
 }
 
-func decodeRawRequest(t *testing.T, client *api.Client, method, path string, params url.Values, v interface{}) {
-	t.Helper()
-
-	// Create the request and add query params if provided
-	req := client.NewRequest(method, path)
-	req.Params = params
+// TestOIDC_Provider_IssuerRoundTrip tests that the issuer of a provider is
+// normalized on write, that the discovery document advertises the normalized
+// form accepted by relying parties, and that an issuer differing from the
+// api_addr of the cluster is stored with a warning.
+func TestOIDC_Provider_IssuerRoundTrip(t *testing.T) {
+	server := newOIDCTestServer(t)
+	active := server.Client
+
+	// The trailing slash of the issuer is removed
+	fixture := vault.TestOIDCProviderSetup(t, active, &vault.TestOIDCProviderOptions{
+		Password:     testPassword,
+		RedirectURIs: []string{testRedirectURI},
+		Issuer:       server.URL + "/",
+	})
+	require.Equal(t, server.URL+"/v1/identity/oidc/provider/"+fixture.ProviderName, fixture.Issuer)
 
-	// Send the raw request
-	r, err := client.RawRequest(req)
+	// The relying party requires the issuer of the discovery document to be
+	// the one it was configured with
+	pc, err := oidc.NewConfig(fixture.Issuer, fixture.ClientID,
+		oidc.ClientSecret(fixture.ClientSecret), []oidc.Alg{oidc.RS256},
+		[]string{testRedirectURI}, oidc.WithProviderCA(string(server.CACertPEM)))
 	require.NoError(t, err)
-	require.NotNil(t, r)
-	require.Equal(t, http.StatusOK, r.StatusCode)
-	defer r.Body.Close()
+	p, err := oidc.NewProvider(pc)
+	require.NoError(t, err)
+	p.Done()
+
+	providerPath := "identity/oidc/provider/" + fixture.ProviderName
+	resp, err := active.Logical().Write(providerPath, map[string]interface{}{
+		"issuer": server.URL + "?query=1",
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid "issuer" value "`+server.URL+`?query=1": must not include a query`)
 
-	// Decode the body into v
-	require.NoError(t, json.NewDecoder(r.Body).Decode(v))
+	// The issuer isn't rejected if it differs from the api_addr
+	otherIssuer := strings.Replace(server.URL, "127.0.0.1", "LOCALHOST", 1)
+	resp, err = active.Logical().Write(providerPath, map[string]interface{}{
+		"issuer": otherIssuer,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, strings.ToLower(otherIssuer), resp.Data["issuer"])
+	warnings := resp.Data["issuer_warnings"].([]interface{})
+	require.Len(t, warnings, 1)
+	require.Equal(t, "issuer_api_addr_mismatch", warnings[0].(map[string]interface{})["code"])
+	require.Equal(t, strings.ToLower(otherIssuer)+"/v1/identity/oidc/provider/"+fixture.ProviderName,
+		server.Issuer(t, fixture.ProviderName))
 }
@@ -103,6 +103,77 @@ func TestTokenStore_TokenInvalidEntityID(t *testing.T) {
 	}
 }
 
+func TestTokenStore_UserpassRevokeTokens(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": credUserpass.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	vault.TestWaitActive(t, core)
+	client := cluster.Cores[0].Client
+
+	err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
+		Type: "userpass",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, username := range []string{"testuser", "otheruser"} {
+		_, err = client.Logical().Write("auth/userpass/users/"+username, map[string]interface{}{
+			"password": "testpassword",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	login := func(username string) string {
+		t.Helper()
+		secret, err := client.Logical().Write("auth/userpass/login/"+username, map[string]interface{}{
+			"password": "testpassword",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret.Auth.ClientToken
+	}
+	lookup := func(token string) error {
+		_, err := client.Logical().Write("auth/token/lookup", map[string]interface{}{
+			"token": token,
+		})
+		return err
+	}
+
+	// Logins are case-insensitive, so the tokens of both must be revoked
+	tokens := []string{login("testuser"), login("TestUser")}
+	otherToken := login("otheruser")
+
+	_, err = client.Logical().Write("auth/userpass/users/testuser", map[string]interface{}{
+		"enabled":       false,
+		"revoke_tokens": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range tokens {
+		if err := lookup(token); err == nil {
+			t.Fatal("expected the token of the disabled user to be revoked")
+		}
+	}
+	if err := lookup(otherToken); err != nil {
+		t.Fatalf("expected the token of another user to remain valid: %v", err)
+	}
+}
+
 func TestTokenStore_IdentityPolicies(t *testing.T) {
 	coreConfig := &vault.CoreConfig{
 		CredentialBackends: map[string]logical.Factory{
@@ -44,6 +44,14 @@ func (i *IdentityStore) resetDB(ctx context.Context) error {
 		return err
 	}
 
+	// Values computed from the previous database must not be served
+	if i.oidcClaimsCache != nil {
+		i.purgeOIDCEntityCaches()
+	}
+	if i.groupClosureCache != nil {
+		i.groupClosureCache.purge()
+	}
+
 	return nil
 }
 
@@ -61,11 +69,25 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 		tokenStorer:   core,
 		entityCreator: core,
 		mfaBackend:    core.loginMFABackend,
+		oidcKeySource: core.oidcKeySource,
+
+		groupClosureCache: newGroupClosureCache(),
+		oidcClientUsage:   newOIDCClientUsage(),
+	}
+
+	var err error
+	iStore.oidcClaimsCache, err = newOIDCEntityCache(oidcEntityCacheSize, oidcClaimsCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	iStore.oidcAssignmentCache, err = newOIDCEntityCache(oidcEntityCacheSize, oidcAssignmentCacheTTL)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create a memdb instance, which by default, operates on lower cased
 	// identity names
-	err := iStore.resetDB(ctx)
+	err = iStore.resetDB(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +124,10 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 				"oidc/.well-known/*",
 				"oidc/provider/+/.well-known/*",
 				"oidc/provider/+/token",
+				"oidc/provider/+/userinfo",
+				"oidc/provider/+/introspect",
+				"oidc/provider/+/logout",
+				"oidc/provider/+/device_authorization",
 			},
 			LocalStorage: []string{
 				localAliasesBucketsPrefix,
@@ -109,6 +135,8 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 		},
 		PeriodicFunc: func(ctx context.Context, req *logical.Request) error {
 			iStore.oidcPeriodicFunc(ctx)
+			iStore.persistOIDCClientUsage(ctx)
+			iStore.groupSyncPeriodicFunc(ctx)
 
 			return nil
 		},
@@ -117,6 +145,12 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 	iStore.oidcCache = newOIDCCache(cache.NoExpiration, cache.NoExpiration)
 	iStore.oidcAuthCodeCache = newOIDCCache(5*time.Minute, 5*time.Minute)
 
+	// Device codes outlive their expiry in the cache, so that clients still
+	// polling are told that they expired
+	iStore.oidcDeviceCodeCache = newOIDCCache(deviceCodeTTL+5*time.Minute, 5*time.Minute)
+	iStore.oidcClientAssertionCache = newOIDCCache(5*time.Minute, 5*time.Minute)
+	iStore.oidcClientJWKSCache = newOIDCCache(clientJWKSCacheTTL, 5*time.Minute)
+
 	err = iStore.Setup(ctx, config)
 	if err != nil {
 		return nil, err
@@ -130,11 +164,20 @@ func (i *IdentityStore) paths() []*framework.Path {
 		entityPaths(i),
 		aliasPaths(i),
 		groupAliasPaths(i),
+		// The sync paths come first, as group names may hold slashes
+		groupSyncPaths(i),
+		groupImportPaths(i),
 		groupPaths(i),
 		lookupPaths(i),
 		upgradePaths(i),
 		oidcPaths(i),
 		oidcProviderPaths(i),
+		oidcProviderRevokePaths(i),
+		oidcProviderTidyPaths(i),
+		oidcProviderIntrospectPaths(i),
+		oidcProviderLogoutPaths(i),
+		oidcClientSecretPaths(i),
+		oidcProviderDevicePaths(i),
 		mfaPaths(i),
 	)
 }
@@ -508,6 +551,14 @@ func (i *IdentityStore) initialize(ctx context.Context, req *logical.Initializat
 		return nil
 	}
 
+	if err := i.recoverOIDCKeys(ctx, req.Storage); err != nil {
+		return err
+	}
+
+	if err := i.migrateOIDCObjects(ctx, req.Storage); err != nil {
+		return err
+	}
+
 	if err := i.storeOIDCDefaultResources(ctx, req.Storage); err != nil {
 		return err
 	}
@@ -769,14 +820,64 @@ func (i *IdentityStore) Invalidate(ctx context.Context, key string) {
 		if err := i.oidcCache.Flush(ns); err != nil {
 			i.logger.Error("error flushing oidc cache", "error", err)
 		}
+
+		// Serve the new keys before any token can be signed with them
+		if err := i.precomputeOIDCPublicKeys(ctx, i.view); err != nil {
+			i.logger.Error("error precomputing OIDC public keys", "error", err)
+		}
 	case strings.HasPrefix(key, clientPath):
 		name := strings.TrimPrefix(key, clientPath)
 
-		// Invalidate the cached client in memdb
-		if err := i.memDBDeleteClientByName(ctx, name); err != nil {
+		// Reload the client in memdb, which must hold every client since
+		// clients are only resolved by ID from memdb. Clients are only
+		// written to these paths by versions that don't pack them.
+		client, err := i.storageClientByName(ctx, i.view, name)
+		if err != nil {
+			i.logger.Error("error reading client during invalidation", "error", err, "key", key)
+			return
+		}
+		if err := i.memDBReplaceClientByName(ctx, name, client); err != nil {
 			i.logger.Error("error invalidating client", "error", err, "key", key)
 			return
 		}
+
+		// Clients determine the keys of providers
+		if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+			i.logger.Error("error flushing oidc cache", "error", err)
+		}
+	case strings.HasPrefix(key, clientBucketsPrefix):
+		// Reload the clients of the bucket in memdb
+		entries, err := oidcClientStore.bucket(ctx, i.view, key)
+		if err != nil {
+			i.logger.Error("error reading client bucket during invalidation", "error", err, "key", key)
+			return
+		}
+		clients := make([]*client, 0, len(entries))
+		for _, entry := range entries {
+			var client client
+			if err := entry.DecodeJSON(&client); err != nil {
+				i.logger.Error("error decoding client during invalidation", "error", err, "key", key)
+				return
+			}
+			client.BucketKey = entry.BucketKey
+			clients = append(clients, &client)
+		}
+		if err := i.memDBReloadClientBucket(ctx, key, clients); err != nil {
+			i.logger.Error("error invalidating client bucket", "error", err, "key", key)
+			return
+		}
+
+		// Clients determine the keys of providers
+		if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+			i.logger.Error("error flushing oidc cache", "error", err)
+		}
+	case strings.HasPrefix(key, assignmentPath), strings.HasPrefix(key, assignmentBucketsPrefix):
+		i.oidcAssignmentCache.purge()
+	case strings.HasPrefix(key, providerPath), strings.HasPrefix(key, scopePath), strings.HasPrefix(key, scopeBucketsPrefix):
+		// Wipe the rendered discovery and keys documents of providers
+		if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+			i.logger.Error("error flushing oidc cache", "error", err)
+		}
 	case strings.HasPrefix(key, localAliasesBucketsPrefix):
 		//
 		// This invalidation only happens on perf standbys
@@ -50,6 +50,10 @@ This field is deprecated, use canonical_id.`,
 					Type:        framework.TypeString,
 					Description: "Mount accessor to which this alias belongs to; unused for a modify",
 				},
+				"mount_path": {
+					Type:        framework.TypeString,
+					Description: "Path of the auth method to which this alias belongs, e.g. auth/userpass/ or userpass/, as an alternative to mount_accessor; unused for a modify",
+				},
 				"name": {
 					Type:        framework.TypeString,
 					Description: "Name of the alias; unused for a modify",
@@ -87,6 +91,10 @@ This field is deprecated, use canonical_id.`,
 					Type:        framework.TypeString,
 					Description: "(Unused)",
 				},
+				"mount_path": {
+					Type:        framework.TypeString,
+					Description: "(Unused)",
+				},
 				"name": {
 					Type:        framework.TypeString,
 					Description: "(Unused)",
@@ -130,8 +138,11 @@ func (i *IdentityStore) handleAliasCreateUpdate() framework.OperationFunc {
 		// Get alias name, if any
 		name := d.Get("name").(string)
 
-		// Get mount accessor, if any
-		mountAccessor := d.Get("mount_accessor").(string)
+		// Get mount accessor, if any, possibly from the mount path
+		mountAccessor, errResp, err := i.aliasMountAccessor(ctx, d)
+		if errResp != nil || err != nil {
+			return errResp, err
+		}
 
 		// Get ID, if any
 		id := d.Get("id").(string)
@@ -202,7 +213,7 @@ func (i *IdentityStore) handleAliasCreateUpdate() framework.OperationFunc {
 
 		// If they didn't provide an ID, we must have both accessor and name provided
 		if mountAccessor == "" || name == "" {
-			return logical.ErrorResponse("'id' or 'mount_accessor' and 'name' must be provided"), nil
+			return logical.ErrorResponse("'id' or 'mount_accessor' or 'mount_path' and 'name' must be provided"), nil
 		}
 
 		// Look up the alias by factors; if it's found it's an update
@@ -308,12 +319,7 @@ func (i *IdentityStore) handleAliasCreate(ctx context.Context, canonicalID, name
 	}
 
 	// Return ID of both alias and entity
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"id":           alias.ID,
-			"canonical_id": entity.ID,
-		},
-	}, nil
+	return i.aliasWriteResponse(alias, entity.ID), nil
 }
 
 func (i *IdentityStore) handleAliasUpdate(ctx context.Context, canonicalID, name, mountAccessor string, alias *identity.Alias, customMetadata map[string]string) (*logical.Response, error) {
@@ -443,12 +449,7 @@ func (i *IdentityStore) handleAliasUpdate(ctx context.Context, canonicalID, name
 			return nil, err
 		}
 
-		return &logical.Response{
-			Data: map[string]interface{}{
-				"id":           alias.ID,
-				"canonical_id": newEntity.ID,
-			},
-		}, nil
+		return i.aliasWriteResponse(alias, newEntity.ID), nil
 	}
 
 	// Index entity and its aliases in MemDB and persist entity along with
@@ -460,12 +461,68 @@ func (i *IdentityStore) handleAliasUpdate(ctx context.Context, canonicalID, name
 	}
 
 	// Return ID of both alias and entity
-	return &logical.Response{
+	return i.aliasWriteResponse(alias, newEntity.ID), nil
+}
+
+// aliasWriteResponse returns the response to the write of an entity or group
+// alias: the IDs of the alias and of the entity or group it belongs to, and
+// the accessor and path of its mount.
+func (i *IdentityStore) aliasWriteResponse(alias *identity.Alias, canonicalID string) *logical.Response {
+	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"id":           alias.ID,
-			"canonical_id": newEntity.ID,
+			"id":             alias.ID,
+			"canonical_id":   canonicalID,
+			"mount_accessor": alias.MountAccessor,
 		},
-	}, nil
+	}
+	if mountValidationResp := i.router.ValidateMountByAccessor(alias.MountAccessor); mountValidationResp != nil {
+		resp.Data["mount_path"] = mountValidationResp.MountPath
+	}
+
+	return resp
+}
+
+// aliasMountAccessor returns the accessor of the mount of an alias write,
+// given either by its mount_accessor or by its mount_path. The mount path is
+// that of an auth method in the namespace of the request, with or without
+// the auth/ prefix. An error response is returned if both are given or if no
+// auth method is enabled at the mount path. The accessor is empty if neither
+// is given.
+func (i *IdentityStore) aliasMountAccessor(ctx context.Context, d *framework.FieldData) (string, *logical.Response, error) {
+	mountAccessor := d.Get("mount_accessor").(string)
+	mountPath := d.Get("mount_path").(string)
+	if mountPath == "" {
+		return mountAccessor, nil, nil
+	}
+	if mountAccessor != "" {
+		return "", logical.ErrorResponse("only one of 'mount_accessor' and 'mount_path' can be provided"), nil
+	}
+
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	path := strings.Trim(mountPath, "/") + "/"
+	credentialPath := path
+	if !strings.HasPrefix(credentialPath, credentialRoutePrefix) {
+		credentialPath = credentialRoutePrefix + credentialPath
+	}
+	mountEntry := i.router.MatchingMountEntry(ctx, credentialPath)
+	if mountEntry != nil && mountEntry.Table == credentialTableType && mountEntry.NamespaceID == ns.ID &&
+		credentialRoutePrefix+mountEntry.Path == credentialPath {
+		return mountEntry.Accessor, nil, nil
+	}
+
+	// Aliases only belong to auth methods, so point out paths of secrets
+	// engines rather than reporting them as missing
+	mountEntry = i.router.MatchingMountEntry(ctx, path)
+	if mountEntry != nil && mountEntry.Table != credentialTableType && mountEntry.NamespaceID == ns.ID &&
+		mountEntry.Path == path {
+		return "", logical.ErrorResponse("mount path %q is that of a secrets engine, not of an auth method", mountPath), nil
+	}
+
+	return "", logical.ErrorResponse("no auth method is enabled at mount path %q", mountPath), nil
 }
 
 func validateCustomMetadata(customMetadata map[string]string) error {
@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -354,6 +355,72 @@ func TestIdentityStore_AliasRegister(t *testing.T) {
 	}
 }
 
+// TestIdentityStore_AliasRegister_MountPath tests that aliases can be
+// registered with the path of their auth method instead of its accessor.
+func TestIdentityStore_AliasRegister_MountPath(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	is, githubAccessor, _ := testIdentityStoreWithGithubAuth(ctx, t)
+
+	register := func(data map[string]interface{}) (*logical.Response, error) {
+		return is.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "entity-alias",
+			Data:      data,
+		})
+	}
+
+	for n, mountPath := range []string{"auth/github/", "auth/github", "github/", "github"} {
+		resp, err := register(map[string]interface{}{
+			"name":       fmt.Sprintf("testaliasname-%d", n),
+			"mount_path": mountPath,
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		if resp.Data["mount_accessor"] != githubAccessor {
+			t.Fatalf("bad: mount accessor of %q; expected: %q, actual: %q", mountPath, githubAccessor, resp.Data["mount_accessor"])
+		}
+		if resp.Data["mount_path"] != "auth/github/" {
+			t.Fatalf("bad: mount path of %q; expected: %q, actual: %q", mountPath, "auth/github/", resp.Data["mount_path"])
+		}
+
+		alias, err := is.MemDBAliasByID(resp.Data["id"].(string), false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alias == nil || alias.MountAccessor != githubAccessor {
+			t.Fatalf("bad: alias of %q: %#v", mountPath, alias)
+		}
+	}
+
+	for mountPath, expected := range map[string]string{
+		"auth/missing/": "no auth method is enabled",
+		"auth/github/x": "no auth method is enabled",
+		"cubbyhole/":    "is that of a secrets engine",
+	} {
+		resp, err := register(map[string]interface{}{
+			"name":       "testaliasname",
+			"mount_path": mountPath,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error for %q; err:%v resp:%#v", mountPath, err, resp)
+		}
+		if !strings.Contains(resp.Error().Error(), expected) {
+			t.Fatalf("bad: error of %q; expected: %q, actual: %q", mountPath, expected, resp.Error())
+		}
+	}
+
+	// The mount can't be given both ways
+	resp, err := register(map[string]interface{}{
+		"name":           "testaliasname",
+		"mount_path":     "auth/github/",
+		"mount_accessor": githubAccessor,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error; err:%v resp:%#v", err, resp)
+	}
+}
+
 func TestIdentityStore_AliasUpdate(t *testing.T) {
 	ctx := namespace.RootContext(nil)
 	is, githubAccessor, _ := testIdentityStoreWithGithubAuth(ctx, t)
@@ -29,6 +29,10 @@ func groupAliasPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "Mount accessor to which this alias belongs to.",
 				},
+				"mount_path": {
+					Type:        framework.TypeString,
+					Description: "Path of the auth method to which this alias belongs, e.g. auth/userpass/ or userpass/, as an alternative to mount_accessor.",
+				},
 				"canonical_id": {
 					Type:        framework.TypeString,
 					Description: "ID of the group to which this is an alias.",
@@ -56,6 +60,10 @@ func groupAliasPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "Mount accessor to which this alias belongs to.",
 				},
+				"mount_path": {
+					Type:        framework.TypeString,
+					Description: "Path of the auth method to which this alias belongs, e.g. auth/userpass/ or userpass/, as an alternative to mount_accessor.",
+				},
 				"canonical_id": {
 					Type:        framework.TypeString,
 					Description: "ID of the group to which this is an alias.",
@@ -151,9 +159,12 @@ func (i *IdentityStore) handleGroupAliasUpdateCommon(ctx context.Context, req *l
 		return logical.ErrorResponse("missing alias name"), nil
 	}
 
-	mountAccessor := d.Get("mount_accessor").(string)
+	mountAccessor, errResp, err := i.aliasMountAccessor(ctx, d)
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
 	if mountAccessor == "" {
-		return logical.ErrorResponse("missing mount_accessor"), nil
+		return logical.ErrorResponse("missing mount_accessor or mount_path"), nil
 	}
 
 	canonicalID := d.Get("canonical_id").(string)
@@ -256,12 +267,7 @@ func (i *IdentityStore) handleGroupAliasUpdateCommon(ctx context.Context, req *l
 		return nil, err
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"id":           groupAlias.ID,
-			"canonical_id": newGroup.ID,
-		},
-	}, nil
+	return i.aliasWriteResponse(groupAlias, newGroup.ID), nil
 }
 
 // pathGroupAliasIDRead returns the properties of an alias for a given
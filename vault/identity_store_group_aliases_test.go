@@ -337,6 +337,40 @@ func TestIdentityStore_GroupAliases_CRUD(t *testing.T) {
 	}
 }
 
+// TestIdentityStore_GroupAliases_MountPath tests that group aliases can be
+// registered with the path of their auth method instead of its accessor.
+func TestIdentityStore_GroupAliases_MountPath(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	i, accessor, _ := testIdentityStoreWithGithubAuth(ctx, t)
+
+	resp, err := i.HandleRequest(ctx, &logical.Request{
+		Path:      "group-alias",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":       "testgroupaliasname",
+			"mount_path": "github",
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v\nresp: %#v", err, resp)
+	}
+	if resp.Data["mount_accessor"] != accessor || resp.Data["mount_path"] != "auth/github/" {
+		t.Fatalf("bad: mount of group alias: %#v", resp.Data)
+	}
+
+	resp, err = i.HandleRequest(ctx, &logical.Request{
+		Path:      "group-alias",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":       "testgroupaliasname",
+			"mount_path": "cubbyhole/",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error; err: %v\nresp: %#v", err, resp)
+	}
+}
+
 func TestIdentityStore_GroupAliases_MemDBIndexes(t *testing.T) {
 	var err error
 	ctx := namespace.RootContext(nil)
@@ -0,0 +1,205 @@
+package vault
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+)
+
+// groupClosureCache memoizes the closure of groups: the IDs of a group and of
+// every group it is a member of, directly or through nested groups. Walking
+// the hierarchy of groups is needed to resolve the inherited groups and
+// policies of entities, which is costly for deep hierarchies.
+//
+// Closures only depend on the hierarchy of groups, so every closure is
+// removed when a group is created or deleted, or when its parent groups
+// change. Other changes to groups, such as their policies, don't invalidate
+// the cache, so closures hold IDs rather than groups.
+type groupClosureCache struct {
+	l sync.RWMutex
+
+	// closures maps group IDs to their closure
+	closures map[string][]string
+
+	// generation is incremented by every purge. Closures computed from a
+	// hierarchy read before a purge are not cached. Purges run once the
+	// transaction that changed the hierarchy is committed, so the generation
+	// must be read before the transaction that the hierarchy is read in is
+	// opened.
+	generation uint64
+}
+
+func newGroupClosureCache() *groupClosureCache {
+	return &groupClosureCache{
+		closures: make(map[string][]string),
+	}
+}
+
+// currentGeneration returns the generation to pass to set for closures
+// computed from the hierarchy read in a transaction opened after the call.
+func (c *groupClosureCache) currentGeneration() uint64 {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	return c.generation
+}
+
+// get returns the cached closure of the group. The returned slice must not
+// be modified.
+func (c *groupClosureCache) get(groupID string) ([]string, bool) {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	closure, ok := c.closures[groupID]
+	return closure, ok
+}
+
+// set caches the closure of the group, unless the cache was purged since the
+// given generation.
+func (c *groupClosureCache) set(generation uint64, groupID string, closure []string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	c.closures[groupID] = closure
+}
+
+// purge removes every cached closure.
+func (c *groupClosureCache) purge() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.generation++
+	c.closures = make(map[string][]string)
+}
+
+// invalidateGroupClosuresInTxn purges the closures of groups once the
+// transaction is committed if the hierarchy of groups changes from the
+// previous version of the group to the new one. A nil previous group is a
+// created group, and a nil new group a deleted one.
+func (i *IdentityStore) invalidateGroupClosuresInTxn(txn *memdb.Txn, previous, group *identity.Group) {
+	// The group may have been modified in place, in which case its previous
+	// parent groups are unknown
+	if previous != nil && group != nil && previous != group &&
+		strutil.EquivalentSlices(previous.ParentGroupIDs, group.ParentGroupIDs) {
+		return
+	}
+
+	txn.Defer(i.groupClosureCache.purge)
+}
+
+// groupClosureInTxn returns the IDs of the group and of every group it is a
+// member of, directly or through nested groups, starting with the group
+// itself. Cycles in the hierarchy are walked once. The closure is cached
+// under the generation of the cache read before the transaction was opened.
+// The returned slice must not be modified.
+func (i *IdentityStore) groupClosureInTxn(txn *memdb.Txn, generation uint64, group *identity.Group) ([]string, error) {
+	if group == nil {
+		return nil, fmt.Errorf("nil group")
+	}
+
+	if closure, ok := i.groupClosureCache.get(group.ID); ok {
+		return closure, nil
+	}
+
+	visited := make(map[string]bool)
+	var closure []string
+
+	var walk func(group *identity.Group) error
+	walk = func(group *identity.Group) error {
+		visited[group.ID] = true
+		closure = append(closure, group.ID)
+
+		for _, parentGroupID := range group.ParentGroupIDs {
+			if visited[parentGroupID] {
+				continue
+			}
+
+			// The closure of a parent group already holds every group
+			// above it, so it doesn't need to be walked again
+			if parentClosure, ok := i.groupClosureCache.get(parentGroupID); ok {
+				for _, id := range parentClosure {
+					if !visited[id] {
+						visited[id] = true
+						closure = append(closure, id)
+					}
+				}
+				continue
+			}
+
+			parentGroup, err := i.MemDBGroupByIDInTxn(txn, parentGroupID, false)
+			if err != nil {
+				return err
+			}
+			if parentGroup == nil {
+				continue
+			}
+			if err := walk(parentGroup); err != nil {
+				return fmt.Errorf("failed to collect group at parent group ID %q", parentGroup.ID)
+			}
+		}
+
+		return nil
+	}
+	if err := walk(group); err != nil {
+		return nil, err
+	}
+
+	i.groupClosureCache.set(generation, group.ID, closure)
+
+	return closure, nil
+}
+
+// groupClosure is groupClosureInTxn in a new read transaction.
+func (i *IdentityStore) groupClosure(group *identity.Group) ([]string, error) {
+	generation := i.groupClosureCache.currentGeneration()
+	txn := i.db.Txn(false)
+	defer txn.Abort()
+
+	return i.groupClosureInTxn(txn, generation, group)
+}
+
+// groupClosuresInTxn returns the groups in the closures of the given groups,
+// without duplicates, starting with the given groups. The closures are cached
+// as by groupClosureInTxn.
+func (i *IdentityStore) groupClosuresInTxn(txn *memdb.Txn, generation uint64, groups []*identity.Group) ([]*identity.Group, error) {
+	seen := make(map[string]bool)
+	var closureGroups []*identity.Group
+	for _, group := range groups {
+		if seen[group.ID] {
+			continue
+		}
+		seen[group.ID] = true
+		closureGroups = append(closureGroups, group)
+	}
+
+	for _, group := range groups {
+		closure, err := i.groupClosureInTxn(txn, generation, group)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range closure {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			closureGroup, err := i.MemDBGroupByIDInTxn(txn, id, false)
+			if err != nil {
+				return nil, err
+			}
+			if closureGroup == nil {
+				continue
+			}
+			closureGroups = append(closureGroups, closureGroup)
+		}
+	}
+
+	return closureGroups, nil
+}
@@ -0,0 +1,281 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/benchhelpers"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIdentityStore_GroupClosure tests that the inherited groups and policies
+// of an entity follow changes to the hierarchy of groups and to the groups in
+// it, and that cycles are still rejected.
+func TestIdentityStore_GroupClosure(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	is := c.identityStore
+
+	resp, err := is.HandleRequest(ctx, &logical.Request{
+		Path:      "entity",
+		Operation: logical.UpdateOperation,
+	})
+	expectSuccess(t, resp, err)
+	entityID := resp.Data["id"].(string)
+
+	// Create the hierarchy group-0 <- group-1 <- group-2 <- group-3, where
+	// the entity is a member of group-0
+	writeGroup := func(name string, data map[string]interface{}) string {
+		t.Helper()
+
+		data["name"] = name
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Path:      "group",
+			Operation: logical.UpdateOperation,
+			Data:      data,
+		})
+		expectSuccess(t, resp, err)
+		if resp == nil {
+			group, err := is.MemDBGroupByName(ctx, name, false)
+			require.NoError(t, err)
+			return group.ID
+		}
+		return resp.Data["id"].(string)
+	}
+	groupIDs := []string{writeGroup("group-0", map[string]interface{}{
+		"member_entity_ids": []string{entityID},
+		"policies":          []string{"policy-0"},
+	})}
+	for n := 1; n < 4; n++ {
+		groupIDs = append(groupIDs, writeGroup(fmt.Sprintf("group-%d", n), map[string]interface{}{
+			"member_group_ids": []string{groupIDs[n-1]},
+			"policies":         []string{fmt.Sprintf("policy-%d", n)},
+		}))
+	}
+
+	assertGroups := func(expectedPolicies ...string) {
+		t.Helper()
+
+		groupPolicies, err := is.groupPoliciesByEntityID(entityID)
+		require.NoError(t, err)
+		var policies []string
+		for _, nsPolicies := range groupPolicies {
+			policies = append(policies, nsPolicies...)
+		}
+		sort.Strings(policies)
+		require.Equal(t, expectedPolicies, policies)
+
+		groups, inheritedGroups, err := is.groupsByEntityID(entityID)
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		require.Equal(t, groupIDs[0], groups[0].ID)
+		require.Len(t, inheritedGroups, len(expectedPolicies)-1)
+	}
+	assertGroups("policy-0", "policy-1", "policy-2", "policy-3")
+
+	group, err := is.MemDBGroupByID(groupIDs[0], false)
+	require.NoError(t, err)
+	closure, err := is.groupClosure(group)
+	require.NoError(t, err)
+	require.Equal(t, groupIDs, closure)
+
+	// Changing the policies of a group doesn't change the hierarchy
+	writeGroup("group-3", map[string]interface{}{
+		"member_group_ids": []string{groupIDs[2]},
+		"policies":         []string{"policy-3-updated"},
+	})
+	assertGroups("policy-0", "policy-1", "policy-2", "policy-3-updated")
+
+	// Adding group-3 as a member of group-0 creates a cycle
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/id/" + groupIDs[0],
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"member_group_ids": []string{groupIDs[3]},
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cyclic relationship detected")
+
+	// Removing group-1 from group-2 removes the groups above it
+	writeGroup("group-2", map[string]interface{}{
+		"member_group_ids": []string{},
+		"policies":         []string{"policy-2"},
+	})
+	assertGroups("policy-0", "policy-1")
+
+	// group-3 can now be added as a member of group-0
+	writeGroup("group-3", map[string]interface{}{
+		"member_group_ids": []string{groupIDs[1]},
+		"policies":         []string{"policy-3"},
+	})
+	assertGroups("policy-0", "policy-1", "policy-3")
+
+	// Deleting a group removes it from the closures
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/id/" + groupIDs[3],
+		Operation: logical.DeleteOperation,
+	})
+	expectSuccess(t, resp, err)
+	assertGroups("policy-0", "policy-1")
+}
+
+// TestIdentityStore_GroupClosure_StaleSnapshot tests that the closures read
+// from a snapshot taken before a change to the hierarchy are not cached once
+// the change is committed.
+func TestIdentityStore_GroupClosure_StaleSnapshot(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	is := c.identityStore
+
+	resp, err := is.HandleRequest(ctx, &logical.Request{
+		Path:      "entity",
+		Operation: logical.UpdateOperation,
+	})
+	expectSuccess(t, resp, err)
+	entityID := resp.Data["id"].(string)
+
+	// The entity is a member of the child group, which is a member of the
+	// parent group
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":              "child",
+			"member_entity_ids": []string{entityID},
+			"policies":          []string{"child-policy"},
+		},
+	})
+	expectSuccess(t, resp, err)
+	childID := resp.Data["id"].(string)
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":             "parent",
+			"member_group_ids": []string{childID},
+			"policies":         []string{"parent-policy"},
+		},
+	})
+	expectSuccess(t, resp, err)
+
+	// A reader takes a snapshot of the hierarchy, which is changed by
+	// removing the child group from the parent group before the reader
+	// walks it
+	generation := is.groupClosureCache.currentGeneration()
+	txn := is.db.Txn(false)
+	defer txn.Abort()
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/name/parent",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"member_group_ids": []string{},
+		},
+	})
+	expectSuccess(t, resp, err)
+
+	child, err := is.MemDBGroupByIDInTxn(txn, childID, false)
+	require.NoError(t, err)
+	closureGroups, err := is.groupClosuresInTxn(txn, generation, []*identity.Group{child})
+	require.NoError(t, err)
+	require.Len(t, closureGroups, 2)
+
+	// The closure of the snapshot wasn't cached, so the entity no longer
+	// inherits the policies of the parent group
+	groupPolicies, err := is.groupPoliciesByEntityID(entityID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"child-policy"}, groupPolicies[namespace.RootNamespaceID])
+	_, inheritedGroups, err := is.groupsByEntityID(entityID)
+	require.NoError(t, err)
+	require.Empty(t, inheritedGroups)
+}
+
+// TestIdentityStore_GroupClosure_Cycle tests that the closure of groups in a
+// cycle, which can't be created through the API but may be stored, holds
+// every group of the cycle once.
+func TestIdentityStore_GroupClosure_Cycle(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	is := c.identityStore
+
+	txn := is.db.Txn(true)
+	for n := 0; n < 3; n++ {
+		require.NoError(t, is.MemDBUpsertGroupInTxn(txn, &identity.Group{
+			ID:             fmt.Sprintf("group-%d", n),
+			Name:           fmt.Sprintf("group-%d", n),
+			NamespaceID:    namespace.RootNamespaceID,
+			ParentGroupIDs: []string{fmt.Sprintf("group-%d", (n+1)%3)},
+		}))
+	}
+	txn.Commit()
+
+	for n := 0; n < 3; n++ {
+		group, err := is.MemDBGroupByID(fmt.Sprintf("group-%d", n), false)
+		require.NoError(t, err)
+		closure, err := is.groupClosure(group)
+		require.NoError(t, err)
+		require.Len(t, closure, 3)
+		require.Equal(t, group.ID, closure[0])
+	}
+}
+
+// BenchmarkIdentityStore_GroupsByEntityID compares resolving the groups of an
+// entity in a hierarchy of 6 levels of 700 groups with memoized closures to
+// walking the hierarchy for every entity.
+func BenchmarkIdentityStore_GroupsByEntityID(b *testing.B) {
+	const (
+		levels         = 6
+		groupsPerLevel = 700
+		entityGroups   = 20
+	)
+
+	c, _, _ := TestCoreUnsealed(benchhelpers.TBtoT(b))
+	is := c.identityStore
+
+	// Every group is a member of two groups of the level above it, and the
+	// entity is a member of groups of the lowest level
+	txn := is.db.Txn(true)
+	for level := 0; level < levels; level++ {
+		for n := 0; n < groupsPerLevel; n++ {
+			group := &identity.Group{
+				ID:          fmt.Sprintf("group-%d-%d", level, n),
+				Name:        fmt.Sprintf("group-%d-%d", level, n),
+				NamespaceID: namespace.RootNamespaceID,
+				Policies:    []string{fmt.Sprintf("policy-%d-%d", level, n)},
+			}
+			if level > 0 {
+				group.ParentGroupIDs = []string{
+					fmt.Sprintf("group-%d-%d", level-1, n),
+					fmt.Sprintf("group-%d-%d", level-1, (n*7+1)%groupsPerLevel),
+				}
+			}
+			if level == levels-1 && n < entityGroups {
+				group.MemberEntityIDs = []string{"entity"}
+			}
+			if err := is.MemDBUpsertGroupInTxn(txn, group); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	txn.Commit()
+
+	for _, memoized := range []bool{false, true} {
+		b.Run(fmt.Sprintf("memoized=%t", memoized), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if !memoized {
+					is.groupClosureCache.purge()
+				}
+				if _, _, err := is.groupsByEntityID("entity"); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := is.groupPoliciesByEntityID("entity"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	groupImportModeAdd     = "add"
+	groupImportModeRemove  = "remove"
+	groupImportModeReplace = "replace"
+
+	// groupImportBatchSize is the number of members resolved to entities in
+	// each memdb transaction of an import.
+	groupImportBatchSize = 1000
+)
+
+// groupImportResult holds the changes made to the member entities of a group
+// by an import, and the members that failed to import.
+type groupImportResult struct {
+	Added     int
+	Removed   int
+	Unchanged int
+	Errors    []map[string]interface{}
+}
+
+func groupImportPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "group/id/" + framework.GenericNameRegex("id") + "/members/import$",
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the group.",
+				},
+				"members": {
+					Type:        framework.TypeSlice,
+					Description: "Array of the IDs or names of the member entities to import, or a newline-delimited string of them.",
+				},
+				"mode": {
+					Type:          framework.TypeString,
+					Description:   "How the members are imported. 'add' adds them to the group, 'remove' removes them from the group, and 'replace' replaces the member entities of the group with them.",
+					Default:       groupImportModeAdd,
+					AllowedValues: []interface{}{groupImportModeAdd, groupImportModeRemove, groupImportModeReplace},
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathGroupIDMembersImport(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupHelp["group-members-import"][0]),
+			HelpDescription: strings.TrimSpace(groupHelp["group-members-import"][1]),
+		},
+	}
+}
+
+func (i *IdentityStore) pathGroupIDMembersImport() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		groupID := d.Get("id").(string)
+		if groupID == "" {
+			return logical.ErrorResponse("empty group ID"), nil
+		}
+
+		mode := d.Get("mode").(string)
+		switch mode {
+		case groupImportModeAdd, groupImportModeRemove, groupImportModeReplace:
+		default:
+			return logical.ErrorResponse("invalid mode %q", mode), nil
+		}
+
+		// Each item may hold several newline-delimited members
+		var members []string
+		for _, item := range d.Get("members").([]interface{}) {
+			itemStr, ok := item.(string)
+			if !ok {
+				return logical.ErrorResponse("members must be strings"), nil
+			}
+			for _, member := range strings.Split(itemStr, "\n") {
+				if member = strings.TrimSpace(member); member != "" {
+					members = append(members, member)
+				}
+			}
+		}
+		if len(members) == 0 {
+			return logical.ErrorResponse("no members to import"), nil
+		}
+
+		group, err := i.MemDBGroupByID(groupID, false)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return logical.ErrorResponse("invalid group ID"), nil
+		}
+		ns, err := namespace.FromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ns.ID != group.NamespaceID {
+			return logical.ErrorResponse("request namespace is not the same as the group namespace"), logical.ErrPermissionDenied
+		}
+		if group.Type == groupTypeExternal {
+			return logical.ErrorResponse("member entities can't be set manually for external groups"), nil
+		}
+
+		result, err := i.importGroupMembers(ctx, groupID, mode, members)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"added":     result.Added,
+				"removed":   result.Removed,
+				"unchanged": result.Unchanged,
+				"failed":    len(result.Errors),
+				"errors":    result.Errors,
+			},
+		}, nil
+	}
+}
+
+// importGroupMembers adds, removes or replaces the member entities of the
+// group with the entities of the given IDs or names. Members that don't
+// resolve to an entity of the namespace of the group are reported in the
+// errors of the result rather than failing the import. The member entities
+// are updated in a single memdb transaction, so readers never observe a
+// partially imported group.
+func (i *IdentityStore) importGroupMembers(ctx context.Context, groupID, mode string, members []string) (*groupImportResult, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	// The group may have changed since the request was validated
+	group, err := i.MemDBGroupByID(groupID, true)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("group %q was deleted during the import", groupID)
+	}
+
+	result := &groupImportResult{
+		Errors: []map[string]interface{}{},
+	}
+	resolved := make(map[string]bool)
+	var resolvedIDs []string
+	for start := 0; start < len(members); start += groupImportBatchSize {
+		end := start + groupImportBatchSize
+		if end > len(members) {
+			end = len(members)
+		}
+		if err := i.resolveGroupImportBatch(ctx, ns, members[start:end], resolved, &resolvedIDs, result); err != nil {
+			return nil, err
+		}
+	}
+
+	current := make(map[string]bool, len(group.MemberEntityIDs))
+	for _, entityID := range group.MemberEntityIDs {
+		current[entityID] = true
+	}
+
+	var memberEntityIDs []string
+	switch mode {
+	case groupImportModeAdd:
+		memberEntityIDs = group.MemberEntityIDs
+		for _, entityID := range resolvedIDs {
+			if current[entityID] {
+				result.Unchanged++
+				continue
+			}
+			memberEntityIDs = append(memberEntityIDs, entityID)
+			result.Added++
+		}
+	case groupImportModeRemove:
+		for _, entityID := range group.MemberEntityIDs {
+			if resolved[entityID] {
+				result.Removed++
+				continue
+			}
+			memberEntityIDs = append(memberEntityIDs, entityID)
+		}
+		result.Unchanged = len(resolvedIDs) - result.Removed
+	case groupImportModeReplace:
+		for _, entityID := range group.MemberEntityIDs {
+			if !resolved[entityID] {
+				result.Removed++
+				continue
+			}
+			memberEntityIDs = append(memberEntityIDs, entityID)
+			result.Unchanged++
+		}
+		for _, entityID := range resolvedIDs {
+			if !current[entityID] {
+				memberEntityIDs = append(memberEntityIDs, entityID)
+				result.Added++
+			}
+		}
+	}
+
+	if result.Added == 0 && result.Removed == 0 {
+		return result, nil
+	}
+
+	i.logger.Debug("importing member entity IDs of group", "group_id", group.ID, "mode", mode, "added", result.Added, "removed", result.Removed)
+
+	txn := i.db.Txn(true)
+	defer txn.Abort()
+
+	group.MemberEntityIDs = memberEntityIDs
+	group.LastUpdateTime = ptypes.TimestampNow()
+	if err := i.UpsertGroupInTxn(ctx, txn, group, true); err != nil {
+		return nil, err
+	}
+
+	txn.Commit()
+
+	return result, nil
+}
+
+// resolveGroupImportBatch resolves a batch of members of an import to the IDs
+// of entities of the namespace, which are appended to resolvedIDs in order
+// without duplicates. Members that don't resolve are added to the errors of
+// the result.
+func (i *IdentityStore) resolveGroupImportBatch(ctx context.Context, ns *namespace.Namespace, members []string, resolved map[string]bool, resolvedIDs *[]string, result *groupImportResult) error {
+	txn := i.db.Txn(false)
+	defer txn.Abort()
+
+	for _, member := range members {
+		entity, err := i.MemDBEntityByIDInTxn(txn, member, false)
+		if err != nil {
+			return err
+		}
+		if entity == nil || entity.NamespaceID != ns.ID {
+			entity, err = i.MemDBEntityByNameInTxn(ctx, txn, member, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		var entityErr error
+		switch {
+		case entity == nil:
+			entityErr = fmt.Errorf("no entity with the ID or name %q", member)
+		case entity.NamespaceID != ns.ID:
+			entityErr = fmt.Errorf("entity %q does not belong to the namespace of the group", member)
+		}
+		if entityErr != nil {
+			result.Errors = append(result.Errors, map[string]interface{}{
+				"member": member,
+				"error":  entityErr.Error(),
+			})
+			continue
+		}
+
+		if !resolved[entity.ID] {
+			resolved[entity.ID] = true
+			*resolvedIDs = append(*resolvedIDs, entity.ID)
+		}
+	}
+
+	return nil
+}
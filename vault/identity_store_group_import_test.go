@@ -0,0 +1,135 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIdentityStore_GroupMembersImport tests that the member entities of
+// groups can be added, removed and replaced in bulk by entity ID or name,
+// and that members that don't resolve are reported without failing the
+// import.
+func TestIdentityStore_GroupMembersImport(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	is := c.identityStore
+
+	entityIDs := make(map[string]string)
+	for n := 0; n < 5; n++ {
+		name := fmt.Sprintf("entity-%d", n)
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Path:      "entity",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"name": name,
+			},
+		})
+		expectSuccess(t, resp, err)
+		entityIDs[name] = resp.Data["id"].(string)
+	}
+
+	resp, err := is.HandleRequest(ctx, &logical.Request{
+		Path:      "group",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":              "imported",
+			"member_entity_ids": []string{entityIDs["entity-0"]},
+		},
+	})
+	expectSuccess(t, resp, err)
+	groupID := resp.Data["id"].(string)
+
+	importMembers := func(mode string, members interface{}) map[string]interface{} {
+		t.Helper()
+
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Path:      "group/id/" + groupID + "/members/import",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"mode":    mode,
+				"members": members,
+			},
+		})
+		expectSuccess(t, resp, err)
+		return resp.Data
+	}
+	memberEntityIDs := func() []string {
+		t.Helper()
+
+		group, err := is.MemDBGroupByID(groupID, false)
+		require.NoError(t, err)
+		return group.MemberEntityIDs
+	}
+
+	// Members are added by ID or name, and unknown members are reported
+	data := importMembers("add", []string{
+		entityIDs["entity-0"],
+		entityIDs["entity-1"],
+		"entity-2",
+		"entity-2",
+		"missing",
+	})
+	require.Equal(t, 2, data["added"])
+	require.Equal(t, 0, data["removed"])
+	require.Equal(t, 1, data["unchanged"])
+	require.Equal(t, 1, data["failed"])
+	errs := data["errors"].([]map[string]interface{})
+	require.Equal(t, "missing", errs[0]["member"])
+	require.Contains(t, errs[0]["error"], `no entity with the ID or name "missing"`)
+	require.Equal(t, []string{entityIDs["entity-0"], entityIDs["entity-1"], entityIDs["entity-2"]}, memberEntityIDs())
+
+	// Members may be given as a newline-delimited string
+	data = importMembers("remove", "entity-0\n\nentity-3\n")
+	require.Equal(t, 0, data["added"])
+	require.Equal(t, 1, data["removed"])
+	require.Equal(t, 1, data["unchanged"])
+	require.Equal(t, 0, data["failed"])
+	require.Equal(t, []string{entityIDs["entity-1"], entityIDs["entity-2"]}, memberEntityIDs())
+
+	data = importMembers("replace", strings.Join([]string{"entity-2", "entity-3", "entity-4"}, "\n"))
+	require.Equal(t, 2, data["added"])
+	require.Equal(t, 1, data["removed"])
+	require.Equal(t, 1, data["unchanged"])
+	require.Equal(t, []string{entityIDs["entity-2"], entityIDs["entity-3"], entityIDs["entity-4"]}, memberEntityIDs())
+
+	// The group's entities are updated along with its members
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "entity/id/" + entityIDs["entity-3"],
+		Operation: logical.ReadOperation,
+	})
+	expectSuccess(t, resp, err)
+	require.Contains(t, resp.Data["direct_group_ids"], groupID)
+
+	// Imports need members and can't target external groups
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/id/" + groupID + "/members/import",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"mode": "replace",
+		},
+	})
+	expectError(t, resp, err)
+
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name": "external",
+			"type": groupTypeExternal,
+		},
+	})
+	expectSuccess(t, resp, err)
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/id/" + resp.Data["id"].(string) + "/members/import",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"members": []string{"entity-1"},
+		},
+	})
+	expectError(t, resp, err)
+}
@@ -0,0 +1,402 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// groupSyncPath is the storage prefix of the sync configurations of
+// external groups, keyed by group ID.
+const groupSyncPath = "group-sync/"
+
+// errGroupSyncNotSupported is returned when the auth method of the alias of
+// an external group can't enumerate group memberships.
+var errGroupSyncNotSupported = errors.New("auth method does not support syncing group memberships")
+
+// errGroupSyncResolveFailed is returned when the auth method of the alias of
+// an external group fails to resolve the groups of one of its aliases, such
+// as when it isn't configured to do so.
+var errGroupSyncResolveFailed = errors.New("auth method failed to resolve group memberships")
+
+// groupSyncConfig is the sync configuration of an external group. Groups
+// with a non-zero interval are synced by the periodic func of the identity
+// store.
+type groupSyncConfig struct {
+	Interval     time.Duration `json:"interval"`
+	LastSyncTime time.Time     `json:"last_sync_time"`
+}
+
+// groupSyncResult holds the changes made to the member entities of an
+// external group by a sync.
+type groupSyncResult struct {
+	Added   []string
+	Removed []string
+}
+
+func groupSyncPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "group/id/" + framework.GenericNameRegex("id") + "/sync$",
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the external group.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathGroupIDSync(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupHelp["group-sync"][0]),
+			HelpDescription: strings.TrimSpace(groupHelp["group-sync"][1]),
+		},
+		{
+			Pattern: "group/name/(?P<name>.+)/sync$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the external group.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathGroupNameSync(),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupHelp["group-sync"][0]),
+			HelpDescription: strings.TrimSpace(groupHelp["group-sync"][1]),
+		},
+	}
+}
+
+func (i *IdentityStore) pathGroupIDSync() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		groupID := d.Get("id").(string)
+		if groupID == "" {
+			return logical.ErrorResponse("empty group ID"), nil
+		}
+
+		group, err := i.MemDBGroupByID(groupID, false)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return logical.ErrorResponse("invalid group ID"), nil
+		}
+
+		return i.handleGroupSyncCommon(ctx, group)
+	}
+}
+
+func (i *IdentityStore) pathGroupNameSync() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		groupName := d.Get("name").(string)
+		if groupName == "" {
+			return logical.ErrorResponse("empty group name"), nil
+		}
+
+		group, err := i.MemDBGroupByName(ctx, groupName, false)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return logical.ErrorResponse("invalid group name"), nil
+		}
+
+		return i.handleGroupSyncCommon(ctx, group)
+	}
+}
+
+func (i *IdentityStore) handleGroupSyncCommon(ctx context.Context, group *identity.Group) (*logical.Response, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ns.ID != group.NamespaceID {
+		return logical.ErrorResponse("request namespace is not the same as the group namespace"), logical.ErrPermissionDenied
+	}
+
+	switch {
+	case group.Type != groupTypeExternal:
+		return logical.ErrorResponse("only external groups can be synced"), nil
+	case group.Alias == nil:
+		return logical.ErrorResponse("external group has no alias to sync its members from"), nil
+	}
+
+	result, err := i.syncExternalGroupMembers(ctx, group.ID)
+	switch {
+	case errors.Is(err, errGroupSyncNotSupported), errors.Is(err, errGroupSyncResolveFailed):
+		return logical.ErrorResponse(err.Error()), nil
+	case err != nil:
+		return nil, err
+	}
+
+	if err := i.touchGroupSyncConfig(ctx, group.ID); err != nil {
+		return nil, err
+	}
+
+	group, err = i.MemDBGroupByID(group.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	var memberEntityIDs []string
+	if group != nil {
+		memberEntityIDs = group.MemberEntityIDs
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"added":             len(result.Added),
+			"removed":           len(result.Removed),
+			"member_entity_ids": memberEntityIDs,
+		},
+	}, nil
+}
+
+// syncExternalGroupMembers re-resolves the member entities of an external
+// group from the auth method of its alias. The group aliases of every entity
+// alias of the mount are resolved through the auth method, and the entities
+// of the aliases that resolve to the alias of the group become its members.
+// Other groups are left untouched, even if the resolved group aliases show
+// that an entity's memberships of them are stale.
+func (i *IdentityStore) syncExternalGroupMembers(ctx context.Context, groupID string) (*groupSyncResult, error) {
+	group, err := i.MemDBGroupByID(groupID, false)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("group %q not found", groupID)
+	}
+	if group.Type != groupTypeExternal || group.Alias == nil {
+		return nil, fmt.Errorf("group %q is not an external group with an alias", groupID)
+	}
+	groupAlias := group.Alias
+
+	mountEntry := i.router.MatchingMountByAccessor(groupAlias.MountAccessor)
+	if mountEntry == nil {
+		return nil, fmt.Errorf("no auth method found for mount accessor %q", groupAlias.MountAccessor)
+	}
+	ctx = namespace.ContextWithNamespace(ctx, mountEntry.Namespace())
+
+	aliases, err := i.entityAliasesByMountAccessor(groupAlias.MountAccessor)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]bool)
+	for _, alias := range aliases {
+		resp, err := i.router.Route(ctx, &logical.Request{
+			Operation: logical.ResolveGroupAliasesOperation,
+			Path:      credentialRoutePrefix + mountEntry.Path + "login/" + alias.Name,
+			Data: map[string]interface{}{
+				"alias_metadata": alias.Metadata,
+			},
+		})
+		switch {
+		case errors.Is(err, logical.ErrUnsupportedOperation), errors.Is(err, logical.ErrUnsupportedPath):
+			return nil, fmt.Errorf("%w: auth method of type %q at %q", errGroupSyncNotSupported, mountEntry.Type, credentialRoutePrefix+mountEntry.Path)
+		case err != nil:
+			return nil, fmt.Errorf("failed to resolve the groups of alias %q: %w", alias.Name, err)
+		case resp == nil:
+			continue
+		case resp.IsError():
+			return nil, fmt.Errorf("%w of alias %q: %v", errGroupSyncResolveFailed, alias.Name, resp.Error())
+		case resp.Auth == nil:
+			continue
+		}
+
+		for _, resolved := range resp.Auth.GroupAliases {
+			aliasByFactors, err := i.MemDBAliasByFactors(groupAlias.MountAccessor, resolved.Name, false, true)
+			if err != nil {
+				return nil, err
+			}
+			if aliasByFactors != nil && aliasByFactors.ID == groupAlias.ID {
+				members[alias.CanonicalID] = true
+				break
+			}
+		}
+	}
+
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	txn := i.db.Txn(true)
+	defer txn.Abort()
+
+	// The group may have changed while the groups of the aliases were being
+	// resolved
+	group, err = i.MemDBGroupByIDInTxn(txn, groupID, true)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("group %q was deleted during the sync", groupID)
+	}
+
+	result := &groupSyncResult{}
+	var memberEntityIDs []string
+	for _, entityID := range group.MemberEntityIDs {
+		if !members[entityID] {
+			result.Removed = append(result.Removed, entityID)
+			continue
+		}
+		memberEntityIDs = append(memberEntityIDs, entityID)
+		delete(members, entityID)
+	}
+	for entityID := range members {
+		result.Added = append(result.Added, entityID)
+	}
+	sort.Strings(result.Added)
+	memberEntityIDs = append(memberEntityIDs, result.Added...)
+
+	if len(result.Added) == 0 && len(result.Removed) == 0 {
+		return result, nil
+	}
+
+	i.logger.Debug("syncing member entity IDs of external group", "group_id", group.ID, "added", len(result.Added), "removed", len(result.Removed))
+
+	group.MemberEntityIDs = memberEntityIDs
+	if err := i.UpsertGroupInTxn(ctx, txn, group, true); err != nil {
+		return nil, err
+	}
+
+	txn.Commit()
+
+	return result, nil
+}
+
+// entityAliasesByMountAccessor returns the entity aliases of the given mount.
+func (i *IdentityStore) entityAliasesByMountAccessor(mountAccessor string) ([]*identity.Alias, error) {
+	txn := i.db.Txn(false)
+	defer txn.Abort()
+
+	iter, err := txn.Get(entityAliasesTable, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []*identity.Alias
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		alias := raw.(*identity.Alias)
+		if alias.MountAccessor == mountAccessor {
+			aliases = append(aliases, alias)
+		}
+	}
+
+	return aliases, nil
+}
+
+// groupSyncConfig returns the sync configuration of the group, or nil if it
+// has none.
+func (i *IdentityStore) groupSyncConfig(ctx context.Context, groupID string) (*groupSyncConfig, error) {
+	entry, err := i.view.Get(ctx, groupSyncPath+groupID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config groupSyncConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (i *IdentityStore) putGroupSyncConfig(ctx context.Context, groupID string, config *groupSyncConfig) error {
+	entry, err := logical.StorageEntryJSON(groupSyncPath+groupID, config)
+	if err != nil {
+		return err
+	}
+	return i.view.Put(ctx, entry)
+}
+
+// setGroupSyncInterval sets the interval at which the group is synced. A zero
+// interval disables periodic syncs.
+func (i *IdentityStore) setGroupSyncInterval(ctx context.Context, groupID string, interval time.Duration) error {
+	config, err := i.groupSyncConfig(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case interval == 0 && config == nil:
+		return nil
+	case interval == 0:
+		return i.view.Delete(ctx, groupSyncPath+groupID)
+	case config == nil:
+		config = &groupSyncConfig{}
+	}
+	config.Interval = interval
+
+	return i.putGroupSyncConfig(ctx, groupID, config)
+}
+
+// touchGroupSyncConfig records the time of a sync of a group that is synced
+// periodically, so that the next periodic sync is scheduled from it.
+func (i *IdentityStore) touchGroupSyncConfig(ctx context.Context, groupID string) error {
+	config, err := i.groupSyncConfig(ctx, groupID)
+	if err != nil || config == nil {
+		return err
+	}
+
+	config.LastSyncTime = time.Now()
+	return i.putGroupSyncConfig(ctx, groupID, config)
+}
+
+// groupSyncPeriodicFunc syncs the external groups whose sync interval has
+// elapsed since their last sync.
+func (i *IdentityStore) groupSyncPeriodicFunc(ctx context.Context) {
+	// Syncs write to storage, so only run this on the primary cluster. The
+	// periodic func does not run on perf standbys or DR secondaries.
+	if i.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		return
+	}
+
+	groupIDs, err := i.view.List(ctx, groupSyncPath)
+	if err != nil {
+		i.logger.Error("failed to list group sync configurations", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, groupID := range groupIDs {
+		config, err := i.groupSyncConfig(ctx, groupID)
+		if err != nil {
+			i.logger.Error("failed to read group sync configuration", "group_id", groupID, "error", err)
+			continue
+		}
+		if config == nil || now.Before(config.LastSyncTime.Add(config.Interval)) {
+			continue
+		}
+
+		group, err := i.MemDBGroupByID(groupID, false)
+		if err != nil {
+			i.logger.Error("failed to read group", "group_id", groupID, "error", err)
+			continue
+		}
+		if group == nil || group.Type != groupTypeExternal || group.Alias == nil {
+			continue
+		}
+
+		if _, err := i.syncExternalGroupMembers(ctx, groupID); err != nil {
+			i.logger.Error("failed to sync external group", "group_id", groupID, "error", err)
+		}
+
+		// Failed syncs are retried at the next interval rather than at every
+		// run of the periodic func
+		if err := i.touchGroupSyncConfig(ctx, groupID); err != nil {
+			i.logger.Error("failed to update group sync configuration", "group_id", groupID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package vault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// testGroupResolverFactory returns the factory of a credential backend that
+// resolves the group aliases of entity aliases from the given map.
+func testGroupResolverFactory(groups map[string][]string) logical.Factory {
+	return func(ctx context.Context, config *logical.BackendConfig) (logical.Backend, error) {
+		b := &framework.Backend{
+			BackendType: logical.TypeCredential,
+			Paths: []*framework.Path{
+				{
+					Pattern: "login/(?P<username>.+)",
+					Fields: map[string]*framework.FieldSchema{
+						"username": {
+							Type: framework.TypeString,
+						},
+					},
+					Callbacks: map[logical.Operation]framework.OperationFunc{
+						logical.ResolveGroupAliasesOperation: func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+							auth := &logical.Auth{}
+							for _, name := range groups[d.Get("username").(string)] {
+								auth.GroupAliases = append(auth.GroupAliases, &logical.Alias{
+									Name: name,
+								})
+							}
+							return &logical.Response{
+								Auth: auth,
+							}, nil
+						},
+					},
+				},
+			},
+		}
+		if err := b.Setup(ctx, config); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+}
+
+// TestIdentityStore_GroupSync tests that the members of external groups are
+// synced on demand and periodically from auth methods that can resolve group
+// memberships, and that other auth methods refuse to sync.
+func TestIdentityStore_GroupSync(t *testing.T) {
+	groups := map[string][]string{
+		"alice": {"admins"},
+	}
+	err := AddTestCredentialBackend("group-resolver", testGroupResolverFactory(groups))
+	require.NoError(t, err)
+
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	is := c.identityStore
+
+	resolverMount := &MountEntry{
+		Table: credentialTableType,
+		Path:  "resolver/",
+		Type:  "group-resolver",
+	}
+	require.NoError(t, c.enableCredential(ctx, resolverMount))
+	noopMount := &MountEntry{
+		Table: credentialTableType,
+		Path:  "noop/",
+		Type:  "noop",
+	}
+	require.NoError(t, c.enableCredential(ctx, noopMount))
+
+	entityIDs := make(map[string]string)
+	for _, name := range []string{"alice", "bob"} {
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Path:      "entity-alias",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"name":           name,
+				"mount_accessor": resolverMount.Accessor,
+			},
+		})
+		expectSuccess(t, resp, err)
+		entityIDs[name] = resp.Data["canonical_id"].(string)
+	}
+
+	externalGroup := func(name, mountAccessor string, data map[string]interface{}) string {
+		t.Helper()
+
+		data["name"] = name
+		data["type"] = groupTypeExternal
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Path:      "group",
+			Operation: logical.UpdateOperation,
+			Data:      data,
+		})
+		expectSuccess(t, resp, err)
+		groupID := resp.Data["id"].(string)
+
+		resp, err = is.HandleRequest(ctx, &logical.Request{
+			Path:      "group-alias",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"name":           "admins",
+				"mount_accessor": mountAccessor,
+				"canonical_id":   groupID,
+			},
+		})
+		expectSuccess(t, resp, err)
+		return groupID
+	}
+	groupID := externalGroup("admins", resolverMount.Accessor, map[string]interface{}{
+		"sync_interval": "1h",
+	})
+
+	sync := func(path string) *logical.Response {
+		t.Helper()
+
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Path:      path,
+			Operation: logical.UpdateOperation,
+		})
+		expectSuccess(t, resp, err)
+		return resp
+	}
+
+	resp := sync("group/id/" + groupID + "/sync")
+	require.Equal(t, 1, resp.Data["added"])
+	require.Equal(t, 0, resp.Data["removed"])
+	require.Equal(t, []string{entityIDs["alice"]}, resp.Data["member_entity_ids"])
+
+	groups["alice"] = nil
+	groups["bob"] = []string{"admins", "others"}
+	resp = sync("group/name/admins/sync")
+	require.Equal(t, 1, resp.Data["added"])
+	require.Equal(t, 1, resp.Data["removed"])
+	require.Equal(t, []string{entityIDs["bob"]}, resp.Data["member_entity_ids"])
+
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/id/" + groupID,
+		Operation: logical.ReadOperation,
+	})
+	expectSuccess(t, resp, err)
+	require.Equal(t, int64(3600), resp.Data["sync_interval"])
+	require.NotEmpty(t, resp.Data["last_sync_time"])
+
+	// The periodic func only syncs the group once its interval has elapsed
+	groups["alice"] = []string{"admins"}
+	is.groupSyncPeriodicFunc(ctx)
+	group, err := is.MemDBGroupByID(groupID, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{entityIDs["bob"]}, group.MemberEntityIDs)
+
+	config, err := is.groupSyncConfig(ctx, groupID)
+	require.NoError(t, err)
+	config.LastSyncTime = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, is.putGroupSyncConfig(ctx, groupID, config))
+	is.groupSyncPeriodicFunc(ctx)
+	group, err = is.MemDBGroupByID(groupID, false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{entityIDs["alice"], entityIDs["bob"]}, group.MemberEntityIDs)
+
+	// Auth methods that can't resolve group memberships can't be synced
+	noopGroupID := externalGroup("noop-admins", noopMount.Accessor, map[string]interface{}{})
+	_, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "entity-alias",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":           "alice",
+			"mount_accessor": noopMount.Accessor,
+			"canonical_id":   entityIDs["alice"],
+		},
+	})
+	require.NoError(t, err)
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/id/" + noopGroupID + "/sync",
+		Operation: logical.UpdateOperation,
+	})
+	expectError(t, resp, err)
+	require.Contains(t, resp.Error().Error(), "does not support syncing group memberships")
+
+	// Internal groups can't be synced nor have a sync interval
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name": "internal",
+		},
+	})
+	expectSuccess(t, resp, err)
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/name/internal/sync",
+		Operation: logical.UpdateOperation,
+	})
+	expectError(t, resp, err)
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/name/internal",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"sync_interval": "1h",
+		},
+	})
+	expectError(t, resp, err)
+
+	// Deleting the group deletes its sync configuration
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Path:      "group/id/" + groupID,
+		Operation: logical.DeleteOperation,
+	})
+	expectSuccess(t, resp, err)
+	config, err = is.groupSyncConfig(ctx, groupID)
+	require.NoError(t, err)
+	require.Nil(t, config)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
@@ -52,6 +53,10 @@ vault <command> <path> metadata=key1=value1 metadata=key2=value2
 			Type:        framework.TypeCommaStringSlice,
 			Description: "Entity IDs to be assigned as group members.",
 		},
+		"sync_interval": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Interval at which the members of an external group are synced from the auth method of its alias. Defaults to 0, which disables periodic syncs.",
+		},
 	}
 }
 
@@ -253,11 +258,28 @@ func (i *IdentityStore) handleGroupUpdateCommon(ctx context.Context, req *logica
 		memberGroupIDs = memberGroupIDsRaw.([]string)
 	}
 
+	syncIntervalRaw, syncIntervalOk := d.GetOk("sync_interval")
+	if syncIntervalOk {
+		if group.Type != groupTypeExternal {
+			return logical.ErrorResponse("sync interval can only be set for external groups"), nil
+		}
+		if syncIntervalRaw.(int) < 0 {
+			return logical.ErrorResponse("sync interval must not be negative"), nil
+		}
+	}
+
 	err = i.sanitizeAndUpsertGroup(ctx, group, nil, memberGroupIDs)
 	if err != nil {
 		return nil, err
 	}
 
+	if syncIntervalOk {
+		err = i.setGroupSyncInterval(ctx, group.ID, time.Duration(syncIntervalRaw.(int))*time.Second)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if !newGroup {
 		return nil, nil
 	}
@@ -354,6 +376,20 @@ func (i *IdentityStore) handleGroupReadCommon(ctx context.Context, group *identi
 
 	respData["alias"] = aliasMap
 
+	if group.Type == groupTypeExternal {
+		syncConfig, err := i.groupSyncConfig(ctx, group.ID)
+		if err != nil {
+			return nil, err
+		}
+		respData["sync_interval"] = 0
+		if syncConfig != nil {
+			respData["sync_interval"] = int64(syncConfig.Interval.Seconds())
+			if !syncConfig.LastSyncTime.IsZero() {
+				respData["last_sync_time"] = syncConfig.LastSyncTime.Format(time.RFC3339)
+			}
+		}
+	}
+
 	var memberGroupIDs []string
 	memberGroups, err := i.MemDBGroupsByParentGroupID(group.ID, false)
 	if err != nil {
@@ -450,6 +486,12 @@ func (i *IdentityStore) handleGroupDeleteCommon(ctx context.Context, key string,
 	// Committing the transaction *after* successfully deleting group
 	txn.Commit()
 
+	if group.Type == groupTypeExternal {
+		if err := i.view.Delete(ctx, groupSyncPath+group.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	return nil, nil
 }
 
@@ -546,4 +588,19 @@ var groupHelp = map[string][2]string{
 		"List all the group IDs.",
 		"",
 	},
+	"group-sync": {
+		"Sync the member entities of an external group.",
+		`Re-resolves the members of an external group from the auth method of its
+alias, and reports the number of member entities that were added and removed.
+Only auth methods that can enumerate group memberships, such as LDAP, support
+syncing.`,
+	},
+	"group-members-import": {
+		"Import the member entities of a group.",
+		`Adds, removes or replaces the member entities of an internal group with
+the entities of the given IDs or names, and reports the number of members that
+were added, removed, unchanged or failed to import along with the errors of
+the failed ones. The members of the group are updated at once, so readers never
+observe a partially imported group.`,
+	},
 }
@@ -10,11 +10,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
 	mathrand "math/rand"
+	"net/http"
 	"net/url"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -71,16 +74,17 @@ type role struct {
 // include top-level keys, but those keys may not overwrite any of the
 // required OIDC fields.
 type idToken struct {
-	Issuer          string `json:"iss"`       // api_addr or custom Issuer
-	Namespace       string `json:"namespace"` // Namespace of issuer
-	Subject         string `json:"sub"`       // Entity ID
-	Audience        string `json:"aud"`       // Role or client ID will be used here.
-	Expiry          int64  `json:"exp"`       // Expiration, as determined by the role or client.
-	IssuedAt        int64  `json:"iat"`       // Time of token creation
-	Nonce           string `json:"nonce"`     // Nonce given in OIDC authentication requests
-	AuthTime        int64  `json:"auth_time"` // AuthTime given in OIDC authentication requests
-	AccessTokenHash string `json:"at_hash"`   // Access token hash value
-	CodeHash        string `json:"c_hash"`    // Authorization code hash value
+	Issuer          string   `json:"iss"`       // api_addr or custom Issuer
+	Namespace       string   `json:"namespace"` // Namespace of issuer
+	Subject         string   `json:"sub"`       // Entity ID
+	Audience        string   `json:"aud"`       // Role or client ID will be used here.
+	Expiry          int64    `json:"exp"`       // Expiration, as determined by the role or client.
+	IssuedAt        int64    `json:"iat"`       // Time of token creation
+	Nonce           string   `json:"nonce"`     // Nonce given in OIDC authentication requests
+	AuthTime        int64    `json:"auth_time"` // AuthTime given in OIDC authentication requests
+	AccessTokenHash string   `json:"at_hash"`   // Access token hash value
+	CodeHash        string   `json:"c_hash"`    // Authorization code hash value
+	AuthMethods     []string `json:"amr"`       // Authentication methods used by the end-user
 }
 
 // discovery contains a subset of the required elements of OIDC discovery needed
@@ -95,9 +99,19 @@ type discovery struct {
 	IDTokenAlgs   []string `json:"id_token_signing_alg_values_supported"`
 }
 
-// oidcCache is a thin wrapper around go-cache to partition by namespace
+// oidcCache is a thin wrapper around go-cache to partition by namespace.
+//
+// Items are spread over shards by their namespaced key, each of which is a
+// go-cache with its own lock, so that concurrent requests don't contend on a
+// single lock.
 type oidcCache struct {
-	c *cache.Cache
+	// flushes counts the calls to Flush. It is read with SetDefaultIfNotFlushed
+	// so that values read from storage before a flush aren't cached after it,
+	// without holding a lock across the storage reads. It is the first field
+	// to be 64-bit aligned for atomic operations.
+	flushes uint64
+
+	shards []*cache.Cache
 }
 
 var errNilNamespace = errors.New("nil namespace in oidc cache request")
@@ -115,7 +129,7 @@ var (
 	reservedClaims = []string{
 		"iat", "aud", "exp", "iss",
 		"sub", "namespace", "nonce",
-		"auth_time", "at_hash", "c_hash",
+		"auth_time", "at_hash", "c_hash", "amr",
 	}
 	supportedAlgs = []string{
 		string(jose.RS256),
@@ -170,7 +184,7 @@ func oidcPaths(i *IdentityStore) []*framework.Path {
 
 				"algorithm": {
 					Type:        framework.TypeString,
-					Description: "Signing algorithm to use. This will default to RS256.",
+					Description: "Signing algorithm to use. Allowed values are: RS256, RS384, RS512, ES256, ES384, ES512, EdDSA. Changing the algorithm rotates the key immediately. This will default to RS256.",
 					Default:     "RS256",
 				},
 
@@ -180,10 +194,10 @@ func oidcPaths(i *IdentityStore) []*framework.Path {
 				},
 			},
 			Callbacks: map[logical.Operation]framework.OperationFunc{
-				logical.CreateOperation: i.pathOIDCCreateUpdateKey,
-				logical.UpdateOperation: i.pathOIDCCreateUpdateKey,
+				logical.CreateOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCCreateUpdateKey),
+				logical.UpdateOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCCreateUpdateKey),
 				logical.ReadOperation:   i.pathOIDCReadKey,
-				logical.DeleteOperation: i.pathOIDCDeleteKey,
+				logical.DeleteOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCDeleteKey),
 			},
 			ExistenceCheck:  i.pathOIDCKeyExistenceCheck,
 			HelpSynopsis:    "CRUD operations for OIDC keys.",
@@ -202,11 +216,34 @@ func oidcPaths(i *IdentityStore) []*framework.Path {
 				},
 			},
 			Callbacks: map[logical.Operation]framework.OperationFunc{
-				logical.UpdateOperation: i.pathOIDCRotateKey,
+				logical.UpdateOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCRotateKey),
 			},
 			HelpSynopsis:    "Rotate a named OIDC key.",
 			HelpDescription: "Manually rotate a named OIDC key. Rotating a named key will cause a new underlying signing key to be generated. The public portion of the underlying rotated signing key will continue to live for the verification_ttl duration.",
 		},
+		{
+			Pattern: "oidc/key/" + framework.GenericNameRegex("name") + "/migrate/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the key",
+				},
+				"target_key": {
+					Type:        framework.TypeString,
+					Description: "Name of the key to move the clients referencing the key to.",
+					Required:    true,
+				},
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "If true, the clients that would be moved are returned without being modified.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCMigrateKey),
+			},
+			HelpSynopsis:    "Move the clients referencing a named OIDC key to another key.",
+			HelpDescription: "Move every OIDC client referencing a named key to another key using the same algorithm. The public portions of the named key remain available for verification to the providers of the moved clients for the verification_ttl of the named key, during which the named key can't be deleted.",
+		},
 		{
 			Pattern: "oidc/key/?$",
 			Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -272,10 +309,10 @@ func oidcPaths(i *IdentityStore) []*framework.Path {
 				},
 			},
 			Callbacks: map[logical.Operation]framework.OperationFunc{
-				logical.UpdateOperation: i.pathOIDCCreateUpdateRole,
-				logical.CreateOperation: i.pathOIDCCreateUpdateRole,
+				logical.UpdateOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCCreateUpdateRole),
+				logical.CreateOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCCreateUpdateRole),
 				logical.ReadOperation:   i.pathOIDCReadRole,
-				logical.DeleteOperation: i.pathOIDCDeleteRole,
+				logical.DeleteOperation: i.oidcPrecomputeKeysCallback(i.pathOIDCDeleteRole),
 			},
 			ExistenceCheck:  i.pathOIDCRoleExistenceCheck,
 			HelpSynopsis:    "CRUD operations on OIDC Roles",
@@ -350,6 +387,10 @@ func (i *IdentityStore) pathOIDCUpdateConfig(ctx context.Context, req *logical.R
 	issuer := issuerRaw.(string)
 
 	if issuer != "" {
+		if _, err := framework.ValidateURL("issuer", issuer, issuerURLRules); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
 		// verify that issuer is the correct format:
 		//   - http or https
 		//   - host name
@@ -539,6 +580,20 @@ func (i *IdentityStore) pathOIDCCreateUpdateKey(ctx context.Context, req *logica
 		return logical.ErrorResponse("unknown signing algorithm %q", key.Algorithm), nil
 	}
 
+	// ensure any clients referencing this key that sign their user info
+	// responses keep the algorithm they registered
+	if req.Operation == logical.UpdateOperation && key.Algorithm != prevAlgorithm {
+		clients, err := i.clientsReferencingTargetKeyName(ctx, req, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, client := range clients {
+			if client.UserInfoSignedResponseAlg != "" && client.UserInfoSignedResponseAlg != key.Algorithm {
+				return logical.ErrorResponse("unable to update key %q because it is currently referenced by one or more clients with a userinfo_signed_response_alg of %q", name, client.UserInfoSignedResponseAlg), nil
+			}
+		}
+	}
+
 	now := time.Now()
 
 	// Update next rotation time if it is unset or now earlier than previously set.
@@ -549,21 +604,19 @@ func (i *IdentityStore) pathOIDCCreateUpdateKey(ctx context.Context, req *logica
 
 	// generate current and next keys if creating a new key or changing algorithms
 	if key.Algorithm != prevAlgorithm {
-		err = key.generateAndSetKey(ctx, i.Logger(), req.Storage)
+		key.expireKeysOfAlgorithmChange(now)
+
+		err = key.generateAndSetKey(ctx, i.Logger(), req.Storage, i.oidcKeySource)
 		if err != nil {
 			return nil, err
 		}
 
-		err = key.generateAndSetNextKey(ctx, i.Logger(), req.Storage)
+		err = key.generateAndSetNextKey(ctx, i.Logger(), req.Storage, i.oidcKeySource)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if err := i.oidcCache.Flush(ns); err != nil {
-		return nil, err
-	}
-
 	// store named key
 	entry, err := logical.StorageEntryJSON(namedKeyConfigPath+name, key)
 	if err != nil {
@@ -574,6 +627,11 @@ func (i *IdentityStore) pathOIDCCreateUpdateKey(ctx context.Context, req *logica
 		return nil, err
 	}
 
+	// Flush after the write so that the previous key can't be cached again
+	if err := i.oidcCache.Flush(ns); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
@@ -596,19 +654,27 @@ func (i *IdentityStore) pathOIDCReadKey(ctx context.Context, req *logical.Reques
 	if err := entry.DecodeJSON(&storedNamedKey); err != nil {
 		return nil, err
 	}
+
+	clientNames, err := i.memDBClientNamesByKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
 	return &logical.Response{
 		Data: map[string]interface{}{
 			"rotation_period":    int64(storedNamedKey.RotationPeriod.Seconds()),
 			"verification_ttl":   int64(storedNamedKey.VerificationTTL.Seconds()),
 			"algorithm":          storedNamedKey.Algorithm,
 			"allowed_client_ids": storedNamedKey.AllowedClientIDs,
+			"referenced_by":      referencedBy("client", clientNames),
 		},
 	}, nil
 }
 
-// keyIDsByName will return a slice of key IDs for the given key name
-func (i *IdentityStore) keyIDsByName(ctx context.Context, s logical.Storage, name string) ([]string, error) {
-	var keyIDs []string
+// keyIDsByName returns the IDs of the keys of the named key that can still
+// verify signatures at the given time
+func (i *IdentityStore) keyIDsByName(ctx context.Context, s logical.Storage, name string, now time.Time) (jwksKeyIDs, error) {
+	keyIDs := make(jwksKeyIDs)
 	entry, err := s.Get(ctx, namedKeyConfigPath+name)
 	if err != nil {
 		return keyIDs, err
@@ -622,11 +688,56 @@ func (i *IdentityStore) keyIDsByName(ctx context.Context, s logical.Storage, nam
 		return keyIDs, err
 	}
 
+	keyIDs.add(&key, now)
+	return keyIDs, nil
+}
+
+// jwksKeyIDs maps the IDs of the public keys published in a JWKS to the
+// signing algorithm of their named key.
+type jwksKeyIDs map[string]string
+
+// add adds the keys of the key ring of the named key whose verification
+// window hasn't ended at the given time. The current and next signing keys
+// have no expiration.
+func (ids jwksKeyIDs) add(key *namedKey, now time.Time) {
 	for _, k := range key.KeyRing {
-		keyIDs = append(keyIDs, k.KeyID)
+		if !k.ExpireAt.IsZero() && !k.ExpireAt.After(now) {
+			continue
+		}
+		ids[k.KeyID] = key.Algorithm
 	}
+}
 
-	return keyIDs, nil
+// load returns the JWKS of the public keys, in the order of their IDs so that
+// the same keys always render the same document. Every key is published for
+// signature verification with the algorithm of its named key, unless it
+// carries its own, since strict validators refuse keys without "use" and
+// "alg".
+func (ids jwksKeyIDs) load(ctx context.Context, s logical.Storage) (*jose.JSONWebKeySet, error) {
+	keyIDs := make([]string, 0, len(ids))
+	for keyID := range ids {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	jwks := &jose.JSONWebKeySet{
+		Keys: make([]jose.JSONWebKey, 0, len(keyIDs)),
+	}
+	for _, keyID := range keyIDs {
+		key, err := loadOIDCPublicKey(ctx, s, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if key.Use == "" {
+			key.Use = "sig"
+		}
+		if key.Algorithm == "" {
+			key.Algorithm = ids[keyID]
+		}
+		jwks.Keys = append(jwks.Keys, *key)
+	}
+
+	return jwks, nil
 }
 
 // rolesReferencingTargetKeyName returns a map of role names to roles
@@ -713,8 +824,45 @@ func (i *IdentityStore) pathOIDCDeleteKey(ctx context.Context, req *logical.Requ
 	}
 
 	if len(clientNames) > 0 {
-		errorMessage := fmt.Sprintf("unable to delete key %q because it is currently referenced by these clients: %s",
-			targetKeyName, strings.Join(clientNames, ", "))
+		errorMessage := fmt.Sprintf("unable to delete key %q because it is currently referenced by these clients: %s; "+
+			"use identity/oidc/key/%s/migrate to move them to another key",
+			targetKeyName, strings.Join(clientNames, ", "), targetKeyName)
+		i.oidcLock.Unlock()
+		return logical.ErrorResponse(errorMessage), logical.ErrInvalidRequest
+	}
+
+	providerNames, err := i.providerNamesReferencingTargetKeyName(ctx, req, targetKeyName)
+	if err != nil {
+		i.oidcLock.Unlock()
+		return nil, err
+	}
+
+	if len(providerNames) > 0 {
+		errorMessage := fmt.Sprintf("unable to delete key %q because it currently signs the metadata of these providers: %s",
+			targetKeyName, strings.Join(providerNames, ", "))
+		i.oidcLock.Unlock()
+		return logical.ErrorResponse(errorMessage), logical.ErrInvalidRequest
+	}
+
+	// Tokens signed for clients migrated from the key must remain verifiable
+	// until they expire
+	migratedClients, err := i.clientsMigratedFromTargetKeyName(ctx, req, targetKeyName)
+	if err != nil {
+		i.oidcLock.Unlock()
+		return nil, err
+	}
+
+	if len(migratedClients) > 0 {
+		var expireAt time.Time
+		var migratedNames []string
+		for _, client := range migratedClients {
+			migratedNames = append(migratedNames, client.Name)
+			if client.PreviousKeyExpireAt.After(expireAt) {
+				expireAt = client.PreviousKeyExpireAt
+			}
+		}
+		errorMessage := fmt.Sprintf("unable to delete key %q because it verifies tokens of these clients migrated from it until %s: %s",
+			targetKeyName, expireAt.UTC().Format(time.RFC3339), strings.Join(migratedNames, ", "))
 		i.oidcLock.Unlock()
 		return logical.ErrorResponse(errorMessage), logical.ErrInvalidRequest
 	}
@@ -740,6 +888,131 @@ func (i *IdentityStore) pathOIDCDeleteKey(ctx context.Context, req *logical.Requ
 	return nil, nil
 }
 
+// pathOIDCMigrateKey is used to move the clients referencing the named key
+// to another key
+func (i *IdentityStore) pathOIDCMigrateKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	targetKeyName := d.Get("target_key").(string)
+	dryRun := d.Get("dry_run").(bool)
+
+	if targetKeyName == "" {
+		return logical.ErrorResponse("the target_key parameter is required"), logical.ErrInvalidRequest
+	}
+	if targetKeyName == name {
+		return logical.ErrorResponse("the target_key parameter must be different from the key being migrated"), logical.ErrInvalidRequest
+	}
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	readKey := func(name string) (*namedKey, error) {
+		entry, err := req.Storage.Get(ctx, namedKeyConfigPath+name)
+		if err != nil || entry == nil {
+			return nil, err
+		}
+
+		var key namedKey
+		if err := entry.DecodeJSON(&key); err != nil {
+			return nil, err
+		}
+		return &key, nil
+	}
+
+	sourceKey, err := readKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if sourceKey == nil {
+		return logical.ErrorResponse("no named key found at %q", name), logical.ErrInvalidRequest
+	}
+	targetKey, err := readKey(targetKeyName)
+	if err != nil {
+		return nil, err
+	}
+	if targetKey == nil {
+		return logical.ErrorResponse("no named key found at %q", targetKeyName), logical.ErrInvalidRequest
+	}
+
+	if sourceKey.Algorithm != targetKey.Algorithm {
+		return logical.ErrorResponse("unable to migrate clients from key %q using algorithm %q to key %q using algorithm %q",
+			name, sourceKey.Algorithm, targetKeyName, targetKey.Algorithm), logical.ErrInvalidRequest
+	}
+
+	clients, err := i.clientsReferencingTargetKeyName(ctx, req, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientNames []string
+	for clientName := range clients {
+		clientNames = append(clientNames, clientName)
+	}
+	sort.Strings(clientNames)
+
+	// Every client must be usable with the target key, and none may still
+	// need a third key to verify its tokens
+	var problems []string
+	for _, clientName := range clientNames {
+		client := clients[clientName]
+		if !strutil.StrListContains(targetKey.AllowedClientIDs, "*") &&
+			!strutil.StrListContains(targetKey.AllowedClientIDs, client.ClientID) {
+			problems = append(problems, fmt.Sprintf("client %q is not allowed by the allowed_client_ids of key %q", clientName, targetKeyName))
+		}
+		if client.IDTokenTTL > targetKey.VerificationTTL {
+			problems = append(problems, fmt.Sprintf("the id_token_ttl of client %q is greater than the verification_ttl of key %q", clientName, targetKeyName))
+		}
+		if client.previousKeyVerifies() && client.PreviousKey != targetKeyName {
+			problems = append(problems, fmt.Sprintf("client %q was migrated from key %q, which verifies its tokens until %s",
+				clientName, client.PreviousKey, client.PreviousKeyExpireAt.UTC().Format(time.RFC3339)))
+		}
+	}
+	if len(problems) > 0 {
+		return logical.ErrorResponse("unable to migrate clients from key %q to key %q: %s",
+			name, targetKeyName, strings.Join(problems, "; ")), logical.ErrInvalidRequest
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"clients": clientNames,
+			"dry_run": dryRun,
+		},
+	}
+	if clientNames == nil {
+		resp.Data["clients"] = []string{}
+	}
+	if dryRun || len(clientNames) == 0 {
+		return resp, nil
+	}
+
+	expireAt := time.Now().Add(sourceKey.VerificationTTL)
+	migrated := make([]*client, 0, len(clientNames))
+	originals := make([]*client, 0, len(clientNames))
+	for _, clientName := range clientNames {
+		original := clients[clientName]
+		original.Name = clientName
+		client := original
+		client.Key = targetKeyName
+		client.PreviousKey = name
+		client.PreviousKeyExpireAt = expireAt
+		migrated = append(migrated, &client)
+		originals = append(originals, &original)
+	}
+
+	if err := i.putClients(ctx, req.Storage, migrated); err != nil {
+		// Restore the clients that may have been written
+		if rollbackErr := i.putClients(ctx, req.Storage, originals); rollbackErr != nil {
+			i.Logger().Error("failed to restore clients after failed key migration", "key", name, "error", rollbackErr)
+		}
+		return nil, err
+	}
+
+	if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 // handleOIDCListKey is used to list named keys
 func (i *IdentityStore) pathOIDCListKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	i.oidcLock.RLock()
@@ -786,7 +1059,7 @@ func (i *IdentityStore) pathOIDCRotateKey(ctx context.Context, req *logical.Requ
 		verificationTTLOverride = time.Duration(ttlRaw.(int)) * time.Second
 	}
 
-	if err := storedNamedKey.rotate(ctx, i.Logger(), req.Storage, verificationTTLOverride); err != nil {
+	if err := storedNamedKey.rotate(ctx, i.Logger(), req.Storage, i.oidcKeySource, verificationTTLOverride); err != nil {
 		return nil, err
 	}
 
@@ -932,6 +1205,7 @@ func (i *IdentityStore) getNamedKey(ctx context.Context, s logical.Storage, name
 	}
 
 	// Fall back to reading the key from storage
+	flushes := i.oidcCache.flushCount()
 	entry, err := s.Get(ctx, namedKeyConfigPath+name)
 	if err != nil {
 		return nil, err
@@ -945,7 +1219,7 @@ func (i *IdentityStore) getNamedKey(ctx context.Context, s logical.Storage, name
 	}
 
 	// Cache the key
-	if err := i.oidcCache.SetDefault(ns, "namedKeys/"+name, &key); err != nil {
+	if err := i.oidcCache.SetDefaultIfNotFlushed(flushes, ns, "namedKeys/"+name, &key); err != nil {
 		i.logger.Warn("failed to cache key", "error", err)
 	}
 
@@ -975,6 +1249,9 @@ func (tok *idToken) generatePayload(logger hclog.Logger, templates ...string) ([
 	if len(tok.CodeHash) > 0 {
 		output["c_hash"] = tok.CodeHash
 	}
+	if len(tok.AuthMethods) > 0 {
+		output["amr"] = tok.AuthMethods
+	}
 
 	// Merge each of the populated JSON templates into output
 	err := mergeJSONTemplates(logger, output, templates...)
@@ -1000,23 +1277,30 @@ func mergeJSONTemplates(logger hclog.Logger, output map[string]interface{}, temp
 		if err := json.Unmarshal([]byte(template), &parsed); err != nil {
 			logger.Warn("error parsing OIDC template", "template", template, "err", err)
 		}
+		identitytpl.RemoveOmittedValues(parsed)
 
-		for k, v := range parsed {
-			if !strutil.StrListContains(reservedClaims, k) {
-				output[k] = v
-			} else {
-				logger.Warn("invalid top level OIDC template key", "template", template, "key", k)
-			}
-		}
+		mergeClaims(logger, output, template, parsed)
 	}
 
 	return nil
 }
 
+// mergeClaims merges the top-level claims parsed from the given JSON template
+// into the given output map, except for reserved claims.
+func mergeClaims(logger hclog.Logger, output map[string]interface{}, template string, parsed map[string]interface{}) {
+	for k, v := range parsed {
+		if !strutil.StrListContains(reservedClaims, k) {
+			output[k] = v
+		} else {
+			logger.Warn("invalid top level OIDC template key", "template", template, "key", k)
+		}
+	}
+}
+
 // generateAndSetKey will generate new signing and public key pairs and set
 // them as the SigningKey.
-func (k *namedKey) generateAndSetKey(ctx context.Context, logger hclog.Logger, s logical.Storage) error {
-	signingKey, err := generateKeys(k.Algorithm)
+func (k *namedKey) generateAndSetKey(ctx context.Context, logger hclog.Logger, s logical.Storage, keys *oidcKeySource) error {
+	signingKey, err := keys.generateKeys(k.Algorithm)
 	if err != nil {
 		return err
 	}
@@ -1031,10 +1315,26 @@ func (k *namedKey) generateAndSetKey(ctx context.Context, logger hclog.Logger, s
 	return nil
 }
 
+// expireKeysOfAlgorithmChange expires the keys of the previous algorithm of
+// the key before new ones are generated. The previous signing key stays
+// published for the verification_ttl, as on rotation, so that the tokens it
+// signed can still be verified. The previous next signing key never signed
+// anything and is expired immediately.
+func (k *namedKey) expireKeysOfAlgorithmChange(now time.Time) {
+	for _, key := range k.KeyRing {
+		switch {
+		case k.SigningKey != nil && key.KeyID == k.SigningKey.KeyID:
+			key.ExpireAt = now.Add(k.VerificationTTL)
+		case k.NextSigningKey != nil && key.KeyID == k.NextSigningKey.KeyID:
+			key.ExpireAt = now
+		}
+	}
+}
+
 // generateAndSetNextKey will generate new signing and public key pairs and set
 // them as the NextSigningKey.
-func (k *namedKey) generateAndSetNextKey(ctx context.Context, logger hclog.Logger, s logical.Storage) error {
-	signingKey, err := generateKeys(k.Algorithm)
+func (k *namedKey) generateAndSetNextKey(ctx context.Context, logger hclog.Logger, s logical.Storage, keys *oidcKeySource) error {
+	signingKey, err := keys.generateKeys(k.Algorithm)
 	if err != nil {
 		return err
 	}
@@ -1352,36 +1652,11 @@ func (i *IdentityStore) getKeysCacheControlHeader() (string, error) {
 // pathOIDCReadPublicKeys is used to retrieve all public keys so that clients can
 // verify the validity of a signed OIDC token.
 func (i *IdentityStore) pathOIDCReadPublicKeys(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	var data []byte
-
-	ns, err := namespace.FromContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	v, ok, err := i.oidcCache.Get(ns, "jwksResponse")
+	data, err := i.cachedPublicJWKS(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
-	if ok {
-		data = v.([]byte)
-	} else {
-		jwks, err := i.generatePublicJWKS(ctx, req.Storage)
-		if err != nil {
-			return nil, err
-		}
-
-		data, err = json.Marshal(jwks)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := i.oidcCache.SetDefault(ns, "jwksResponse", data); err != nil {
-			return nil, err
-		}
-	}
-
 	resp := &logical.Response{
 		Data: map[string]interface{}{
 			logical.HTTPStatusCode:  200,
@@ -1409,6 +1684,68 @@ func (i *IdentityStore) pathOIDCReadPublicKeys(ctx context.Context, req *logical
 	return resp, nil
 }
 
+// cachedPublicJWKS returns the serialized JWKS of identity tokens, which is
+// cached until the next flush of the namespace.
+func (i *IdentityStore) cachedPublicJWKS(ctx context.Context, s logical.Storage) ([]byte, error) {
+	return i.cachedOIDCDocument(ctx, "jwksResponse", func() ([]byte, error) {
+		jwks, err := i.generatePublicJWKS(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(jwks)
+	})
+}
+
+// precomputeOIDCPublicKeys renders and caches the JWKS of identity tokens and
+// of every provider in the namespace of the context. It is called whenever
+// keys or roles are written, rotated or expired, and when they are
+// invalidated on other nodes, so that the keys endpoints serve the new keys
+// from the cache before any token is signed with them, rather than rendering
+// them on the first request.
+//
+// It must not be called with the OIDC lock held, since generating the JWKS
+// expires public keys.
+func (i *IdentityStore) precomputeOIDCPublicKeys(ctx context.Context, s logical.Storage) error {
+	if _, err := i.cachedPublicJWKS(ctx, s); err != nil {
+		return err
+	}
+
+	providerNames, err := s.List(ctx, providerPath)
+	if err != nil {
+		return err
+	}
+	for _, name := range providerNames {
+		name := name
+		_, err := i.cachedOIDCDocument(ctx, "providerKeys/"+name, func() ([]byte, error) {
+			return i.renderProviderPublicKeys(ctx, s, name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// oidcPrecomputeKeysCallback wraps an operation that changes the keys or
+// roles of the namespace and precomputes its JWKS once the operation
+// succeeded and released the OIDC lock.
+func (i *IdentityStore) oidcPrecomputeKeysCallback(op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		resp, err := op(ctx, req, d)
+		if err != nil || resp.IsError() {
+			return resp, err
+		}
+
+		if err := i.precomputeOIDCPublicKeys(ctx, req.Storage); err != nil {
+			return nil, err
+		}
+
+		return resp, nil
+	}
+}
+
 func (i *IdentityStore) pathOIDCIntrospect(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	var claims jwt.Claims
 
@@ -1440,6 +1777,9 @@ func (i *IdentityStore) pathOIDCIntrospect(ctx context.Context, req *logical.Req
 	}
 
 	rawIDToken := d.Get("token").(string)
+	if rawIDToken == "" {
+		return oidcProviderError(ErrTokenInvalidRequest, "token parameter is required", http.StatusBadRequest, nil)
+	}
 	clientID := d.Get("client_id").(string)
 
 	// validate basic JWT structure
@@ -1501,7 +1841,7 @@ func (i *IdentityStore) pathOIDCIntrospect(ctx context.Context, req *logical.Req
 
 // namedKey.rotate(overrides) performs a key rotation on a namedKey.
 // verification_ttl can be overridden with an overrideVerificationTTL value >= 0
-func (k *namedKey) rotate(ctx context.Context, logger hclog.Logger, s logical.Storage, overrideVerificationTTL time.Duration) error {
+func (k *namedKey) rotate(ctx context.Context, logger hclog.Logger, s logical.Storage, keys *oidcKeySource, overrideVerificationTTL time.Duration) error {
 	verificationTTL := k.VerificationTTL
 	if overrideVerificationTTL >= 0 {
 		verificationTTL = overrideVerificationTTL
@@ -1526,7 +1866,7 @@ func (k *namedKey) rotate(ctx context.Context, logger hclog.Logger, s logical.St
 		logger.Debug("nil next signing key detected on rotation")
 		// keys will not have a NextSigningKey if they were generated before
 		// vault 1.9
-		err := k.generateAndSetNextKey(ctx, logger, s)
+		err := k.generateAndSetNextKey(ctx, logger, s, keys)
 		if err != nil {
 			return err
 		}
@@ -1537,7 +1877,7 @@ func (k *namedKey) rotate(ctx context.Context, logger hclog.Logger, s logical.St
 	k.NextRotation = now.Add(k.RotationPeriod)
 
 	// now that we have rotated, generate a new NextSigningKey
-	err := k.generateAndSetNextKey(ctx, logger, s)
+	err := k.generateAndSetNextKey(ctx, logger, s, keys)
 	if err != nil {
 		return err
 	}
@@ -1555,8 +1895,13 @@ func (k *namedKey) rotate(ctx context.Context, logger hclog.Logger, s logical.St
 	return nil
 }
 
-// generateKeys returns a signingKey and publicKey pair
-func generateKeys(algorithm string) (*jose.JSONWebKey, error) {
+// generateKeys returns a signingKey and publicKey pair. Keys are generated
+// from the deterministic source if it is set, see oidcKeySource.
+func (keys *oidcKeySource) generateKeys(algorithm string) (*jose.JSONWebKey, error) {
+	if keys != nil {
+		return keys.generateDeterministicKeys(algorithm)
+	}
+
 	var key interface{}
 	var err error
 
@@ -1669,7 +2014,8 @@ func (i *IdentityStore) generatePublicJWKS(ctx context.Context, s logical.Storag
 	}
 
 	// collect and deduplicate the key IDs for all roles
-	keyIDs := make(map[string]struct{})
+	now := time.Now()
+	keyIDs := make(jwksKeyIDs)
 	for _, roleName := range roleNames {
 		role, err := i.getOIDCRole(ctx, s, roleName)
 		if err != nil {
@@ -1679,27 +2025,19 @@ func (i *IdentityStore) generatePublicJWKS(ctx context.Context, s logical.Storag
 			continue
 		}
 
-		roleKeyIDs, err := i.keyIDsByName(ctx, s, role.Key)
+		roleKeyIDs, err := i.keyIDsByName(ctx, s, role.Key, now)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, keyID := range roleKeyIDs {
-			keyIDs[keyID] = struct{}{}
+		for keyID, algorithm := range roleKeyIDs {
+			keyIDs[keyID] = algorithm
 		}
 	}
 
-	jwks := &jose.JSONWebKeySet{
-		Keys: make([]jose.JSONWebKey, 0, len(keyIDs)),
-	}
-
-	// load the JSON web key for each key ID
-	for keyID := range keyIDs {
-		key, err := loadOIDCPublicKey(ctx, s, keyID)
-		if err != nil {
-			return nil, err
-		}
-		jwks.Keys = append(jwks.Keys, *key)
+	jwks, err := keyIDs.load(ctx, s)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := i.oidcCache.SetDefault(ns, "jwks", jwks); err != nil {
@@ -1818,6 +2156,103 @@ func (i *IdentityStore) expireOIDCPublicKeys(ctx context.Context, s logical.Stor
 	return nextExpiration, nil
 }
 
+// recoverOIDCKeys repairs the state that named keys may be left in if writing
+// them was interrupted, e.g. by a crash or a storage error. It is run when the
+// identity store is initialized.
+//
+// Storage views don't support transactions, so a named key is always written
+// after the public keys of its key ring. An interrupted write can therefore
+// only leave behind public keys that no named key references, which are
+// deleted. Public keys that are referenced but missing nonetheless are written
+// again from the signing keys of the named key if possible, and otherwise
+// removed from its key ring so that the JWKS can still be generated.
+func (i *IdentityStore) recoverOIDCKeys(ctx context.Context, s logical.Storage) error {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	publicKeyIDs, err := listOIDCPublicKeys(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	// referenced tracks whether each stored public key is in a key ring
+	referenced := make(map[string]bool, len(publicKeyIDs))
+	for _, keyID := range publicKeyIDs {
+		referenced[keyID] = false
+	}
+
+	keyNames, err := s.List(ctx, namedKeyConfigPath)
+	if err != nil {
+		return err
+	}
+
+	for _, keyName := range keyNames {
+		entry, err := s.Get(ctx, namedKeyConfigPath+keyName)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var key namedKey
+		if err := entry.DecodeJSON(&key); err != nil {
+			return err
+		}
+
+		signingKeys := make(map[string]*jose.JSONWebKey, 2)
+		for _, signingKey := range []*jose.JSONWebKey{key.SigningKey, key.NextSigningKey} {
+			if signingKey != nil {
+				signingKeys[signingKey.KeyID] = signingKey
+			}
+		}
+
+		var keyringUpdated bool
+		keyRing := make([]*expireableKey, 0, len(key.KeyRing))
+		for _, k := range key.KeyRing {
+			if _, ok := referenced[k.KeyID]; !ok {
+				signingKey, ok := signingKeys[k.KeyID]
+				if !ok {
+					i.Logger().Warn("removing missing OIDC public key from key ring", "key", keyName, "key_id", k.KeyID)
+					keyringUpdated = true
+					continue
+				}
+
+				if err := saveOIDCPublicKey(ctx, s, signingKey.Public()); err != nil {
+					return err
+				}
+				i.Logger().Warn("restored missing OIDC public key", "key", keyName, "key_id", k.KeyID)
+			}
+
+			referenced[k.KeyID] = true
+			keyRing = append(keyRing, k)
+		}
+
+		if keyringUpdated {
+			key.KeyRing = keyRing
+			entry, err := logical.StorageEntryJSON(entry.Key, key)
+			if err != nil {
+				return err
+			}
+			if err := s.Put(ctx, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyID := range publicKeyIDs {
+		if referenced[keyID] {
+			continue
+		}
+		if err := s.Delete(ctx, publicKeysConfigPath+keyID); err != nil {
+			return err
+		}
+		i.Logger().Debug("deleted unreferenced OIDC public key", "key_id", keyID)
+	}
+
+	return nil
+}
+
 // oidcKeyRotation will rotate any keys that are due to be rotated.
 //
 // It will return the time of the soonest rotation and the minimum
@@ -1870,7 +2305,7 @@ func (i *IdentityStore) oidcKeyRotation(ctx context.Context, s logical.Storage)
 		// Key that is due to be rotated.
 		if now.After(key.NextRotation) {
 			i.Logger().Debug("rotating OIDC key", "key", key.name)
-			if err := key.rotate(ctx, i.Logger(), s, -1); err != nil {
+			if err := key.rotate(ctx, i.Logger(), s, i.oidcKeySource, -1); err != nil {
 				return now, jwksClientCacheDuration, err
 			}
 
@@ -1939,6 +2374,34 @@ func (i *IdentityStore) oidcPeriodicFunc(ctx context.Context) {
 				i.Logger().Error("error flushing oidc cache", "err", err)
 			}
 
+			if err := i.precomputeOIDCPublicKeys(namespace.ContextWithNamespace(ctx, ns), s); err != nil {
+				i.Logger().Warn("error precomputing OIDC public keys", "err", err)
+			}
+
+			nextDeletion, err := i.deleteExpiredOIDCClients(namespace.ContextWithNamespace(ctx, ns), s)
+			if err != nil {
+				i.Logger().Warn("error deleting expired OIDC clients", "err", err)
+			}
+			if !nextDeletion.IsZero() && nextDeletion.Before(nextRun) {
+				nextRun = nextDeletion
+			}
+
+			nextSecretExpiration, err := i.deleteExpiredOIDCClientSecrets(namespace.ContextWithNamespace(ctx, ns), s)
+			if err != nil {
+				i.Logger().Warn("error deleting expired OIDC client secrets", "err", err)
+			}
+			if !nextSecretExpiration.IsZero() && nextSecretExpiration.Before(nextRun) {
+				nextRun = nextSecretExpiration
+			}
+
+			_, err = i.tidyOIDCProviderArtifacts(namespace.ContextWithNamespace(ctx, ns), newOIDCArtifactScan(s, oidcArtifactScanJitter), false)
+			switch {
+			case errors.Is(err, errOIDCTidyInProgress):
+				i.Logger().Debug("skipping periodic tidy of OIDC provider artifacts", "err", err)
+			case err != nil:
+				i.Logger().Warn("error tidying OIDC provider artifacts", "err", err)
+			}
+
 			// re-run at the soonest expiration or rotation time
 			if nextRotation.Before(nextRun) {
 				nextRun = nextRotation
@@ -1975,21 +2438,55 @@ func (i *IdentityStore) oidcPeriodicFunc(ctx context.Context) {
 	}
 }
 
+// advanceOIDCPeriodicRun brings the next run of oidcPeriodicFunc in to the
+// given time if it's scheduled later.
+func (i *IdentityStore) advanceOIDCPeriodicRun(t time.Time) {
+	v, ok, err := i.oidcCache.Get(noNamespace, "nextRun")
+	if err != nil || !ok {
+		// without a scheduled run, the next invocation runs the actions
+		return
+	}
+	if t.Before(v.(time.Time)) {
+		if err := i.oidcCache.SetDefault(noNamespace, "nextRun", t); err != nil {
+			i.Logger().Error("error setting oidc cache", "err", err)
+		}
+	}
+}
+
+// oidcCacheShards is the number of shards of an oidcCache
+const oidcCacheShards = 32
+
 func newOIDCCache(defaultExpiration, cleanupInterval time.Duration) *oidcCache {
-	return &oidcCache{
-		c: cache.New(defaultExpiration, cleanupInterval),
+	return newShardedOIDCCache(defaultExpiration, cleanupInterval, oidcCacheShards)
+}
+
+func newShardedOIDCCache(defaultExpiration, cleanupInterval time.Duration, shards int) *oidcCache {
+	c := &oidcCache{
+		shards: make([]*cache.Cache, shards),
+	}
+	for i := range c.shards {
+		c.shards[i] = cache.New(defaultExpiration, cleanupInterval)
 	}
+	return c
 }
 
 func (c *oidcCache) nskey(ns *namespace.Namespace, key string) string {
-	return fmt.Sprintf("v0:%s:%s", ns.ID, key)
+	return "v0:" + ns.ID + ":" + key
+}
+
+// shard returns the shard of the given namespaced key
+func (c *oidcCache) shard(nskey string) *cache.Cache {
+	h := fnv.New32a()
+	h.Write([]byte(nskey))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
 }
 
 func (c *oidcCache) Get(ns *namespace.Namespace, key string) (interface{}, bool, error) {
 	if ns == nil {
 		return nil, false, errNilNamespace
 	}
-	v, found := c.c.Get(c.nskey(ns, key))
+	nskey := c.nskey(ns, key)
+	v, found := c.shard(nskey).Get(nskey)
 	return v, found, nil
 }
 
@@ -1997,7 +2494,47 @@ func (c *oidcCache) SetDefault(ns *namespace.Namespace, key string, obj interfac
 	if ns == nil {
 		return errNilNamespace
 	}
-	c.c.SetDefault(c.nskey(ns, key), obj)
+	nskey := c.nskey(ns, key)
+	c.shard(nskey).SetDefault(nskey, obj)
+
+	return nil
+}
+
+// Add sets the item with the given expiration unless an unexpired item
+// already has the key, and returns whether it was set.
+func (c *oidcCache) Add(ns *namespace.Namespace, key string, obj interface{}, d time.Duration) (bool, error) {
+	if ns == nil {
+		return false, errNilNamespace
+	}
+	nskey := c.nskey(ns, key)
+	return c.shard(nskey).Add(nskey, obj, d) == nil, nil
+}
+
+// flushCount returns the number of calls to Flush, to be passed to
+// SetDefaultIfNotFlushed.
+func (c *oidcCache) flushCount() uint64 {
+	return atomic.LoadUint64(&c.flushes)
+}
+
+// SetDefaultIfNotFlushed sets the item unless the cache was flushed since
+// the given flush count was read. Values read from storage after the flush
+// count can therefore be cached without holding a lock across the reads.
+func (c *oidcCache) SetDefaultIfNotFlushed(flushes uint64, ns *namespace.Namespace, key string, obj interface{}) error {
+	if ns == nil {
+		return errNilNamespace
+	}
+	if c.flushCount() != flushes {
+		return nil
+	}
+
+	nskey := c.nskey(ns, key)
+	shard := c.shard(nskey)
+	shard.SetDefault(nskey, obj)
+
+	// A flush that started before the item was set may have missed it
+	if c.flushCount() != flushes {
+		shard.Delete(nskey)
+	}
 
 	return nil
 }
@@ -2006,7 +2543,8 @@ func (c *oidcCache) Delete(ns *namespace.Namespace, key string) error {
 	if ns == nil {
 		return errNilNamespace
 	}
-	c.c.Delete(c.nskey(ns, key))
+	nskey := c.nskey(ns, key)
+	c.shard(nskey).Delete(nskey)
 
 	return nil
 }
@@ -2016,16 +2554,31 @@ func (c *oidcCache) Flush(ns *namespace.Namespace) error {
 		return errNilNamespace
 	}
 
+	atomic.AddUint64(&c.flushes, 1)
+
 	// Remove all items from the provided namespace as well as the shared, "no namespace" section.
-	for itemKey := range c.c.Items() {
-		if isTargetNamespacedKey(itemKey, []string{noNamespace.ID, ns.ID}) {
-			c.c.Delete(itemKey)
+	for _, shard := range c.shards {
+		for itemKey := range shard.Items() {
+			if isTargetNamespacedKey(itemKey, []string{noNamespace.ID, ns.ID}) {
+				shard.Delete(itemKey)
+			}
 		}
 	}
 
 	return nil
 }
 
+// items returns the unexpired items of every shard by namespaced key
+func (c *oidcCache) items() map[string]cache.Item {
+	items := make(map[string]cache.Item)
+	for _, shard := range c.shards {
+		for k, v := range shard.Items() {
+			items[k] = v
+		}
+	}
+	return items
+}
+
 // isTargetNamespacedKey returns true for a properly constructed namespaced key (<version>:<nsID>:<key>)
 // where <nsID> matches any targeted nsID
 func isTargetNamespacedKey(nskey string, nsTargets []string) bool {
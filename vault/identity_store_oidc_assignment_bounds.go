@@ -0,0 +1,167 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// assignmentTimeBounds bound when an assignment, or one of its entities or
+// groups, authorizes entities. Zero times are unbounded.
+type assignmentTimeBounds struct {
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// contains returns true if the bounds authorize entities at the given time.
+func (b assignmentTimeBounds) contains(now time.Time) bool {
+	return (b.NotBefore.IsZero() || !now.Before(b.NotBefore)) &&
+		(b.NotAfter.IsZero() || now.Before(b.NotAfter))
+}
+
+// expired returns true if the bounds no longer authorize entities at the
+// given time, and never will again.
+func (b assignmentTimeBounds) expired(now time.Time) bool {
+	return !b.NotAfter.IsZero() && !now.Before(b.NotAfter)
+}
+
+// nextChange returns the earliest bound after the given time, or the zero
+// time if the bounds never change whether they contain later times.
+func (b assignmentTimeBounds) nextChange(now time.Time) time.Time {
+	var next time.Time
+	for _, bound := range []time.Time{b.NotBefore, b.NotAfter} {
+		if bound.After(now) && (next.IsZero() || bound.Before(next)) {
+			next = bound
+		}
+	}
+	return next
+}
+
+func (b assignmentTimeBounds) isZero() bool {
+	return b.NotBefore.IsZero() && b.NotAfter.IsZero()
+}
+
+func (b assignmentTimeBounds) validate(name string) error {
+	if !b.NotBefore.IsZero() && !b.NotAfter.IsZero() && !b.NotBefore.Before(b.NotAfter) {
+		return fmt.Errorf("not_before of %s must be before its not_after", name)
+	}
+	return nil
+}
+
+func (b assignmentTimeBounds) responseData() map[string]interface{} {
+	return map[string]interface{}{
+		"not_before": formatAssignmentTime(b.NotBefore),
+		"not_after":  formatAssignmentTime(b.NotAfter),
+	}
+}
+
+// formatAssignmentTime formats a bound of an assignment for responses, or
+// returns an empty string if it is unbounded.
+func formatAssignmentTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseAssignmentTimeBounds parses the bounds of the entities or groups of an
+// assignment, given as a map of their IDs to objects with optional
+// not_before and not_after timestamps. IDs are lowercased like those of the
+// assignment, and must be among them.
+func parseAssignmentTimeBounds(field string, raw map[string]interface{}, ids []string) (map[string]assignmentTimeBounds, error) {
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+
+	bounds := make(map[string]assignmentTimeBounds, len(raw))
+	for rawID, rawBounds := range raw {
+		id := strings.ToLower(strings.TrimSpace(rawID))
+		if !known[id] {
+			return nil, fmt.Errorf("%s has bounds for %q, which is not an ID of the assignment", field, rawID)
+		}
+
+		values, ok := rawBounds.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s of %q must be an object with not_before and not_after timestamps", field, rawID)
+		}
+
+		var b assignmentTimeBounds
+		for key, value := range values {
+			str, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s of %q must have string timestamps", field, rawID)
+			}
+
+			var err error
+			switch key {
+			case "not_before":
+				b.NotBefore, err = parseOIDCTime(key, str)
+			case "not_after":
+				b.NotAfter, err = parseOIDCTime(key, str)
+			default:
+				err = fmt.Errorf("%s of %q has unknown key %q", field, rawID, key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := b.validate(fmt.Sprintf("%q", rawID)); err != nil {
+			return nil, err
+		}
+
+		if !b.isZero() {
+			bounds[id] = b
+		}
+	}
+
+	return bounds, nil
+}
+
+// pruneTimeBounds removes the bounds of IDs that are no longer in the
+// assignment.
+func pruneTimeBounds(bounds map[string]assignmentTimeBounds, ids []string) {
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	for id := range bounds {
+		if !known[id] {
+			delete(bounds, id)
+		}
+	}
+}
+
+func timeBoundsResponseData(bounds map[string]assignmentTimeBounds) map[string]interface{} {
+	data := make(map[string]interface{}, len(bounds))
+	for id, b := range bounds {
+		data[id] = b.responseData()
+	}
+	return data
+}
+
+// expiredTimeBounds returns the sorted IDs whose bounds have expired at the
+// given time.
+func expiredTimeBounds(bounds map[string]assignmentTimeBounds, now time.Time) []string {
+	var expired []string
+	for id, b := range bounds {
+		if b.expired(now) {
+			expired = append(expired, id)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// earliestTime returns the earliest of the given times, ignoring zero times.
+func earliestTime(a, b time.Time) time.Time {
+	switch {
+	case a.IsZero():
+		return b
+	case b.IsZero() || a.Before(b):
+		return a
+	default:
+		return b
+	}
+}
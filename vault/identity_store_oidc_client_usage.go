@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+)
+
+// clientUsageInterval is the coarseness of the last token issuance time of
+// OIDC clients. Issuance is recorded at most once per interval per client,
+// so that busy clients don't write to storage on every token request.
+const clientUsageInterval = time.Hour
+
+// oidcClientUsage holds the token issuance times of OIDC clients that are
+// yet to be persisted, keyed by client ID. The times are recorded by the
+// token endpoint and persisted by the periodic func of the identity store,
+// so a crash loses at most the times recorded since its last run.
+type oidcClientUsage struct {
+	l       sync.Mutex
+	pending map[string]time.Time
+}
+
+func newOIDCClientUsage() *oidcClientUsage {
+	return &oidcClientUsage{
+		pending: make(map[string]time.Time),
+	}
+}
+
+// recordClientTokenIssued records that tokens were issued to the client, if
+// its last token issuance time is older than the usage interval. Usage is
+// only recorded on the active node, which persists it.
+func (i *IdentityStore) recordClientTokenIssued(client *client, issuedAt time.Time) {
+	if i.localNode.HAState() == consts.PerfStandby ||
+		i.localNode.ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		return
+	}
+	if issuedAt.Sub(client.LastTokenIssuedAt) < clientUsageInterval {
+		return
+	}
+
+	i.oidcClientUsage.l.Lock()
+	defer i.oidcClientUsage.l.Unlock()
+	if _, ok := i.oidcClientUsage.pending[client.ClientID]; !ok {
+		i.oidcClientUsage.pending[client.ClientID] = issuedAt
+	}
+}
+
+// persistOIDCClientUsage writes the recorded token issuance times to the
+// clients they were recorded for. Clients deleted since are skipped.
+func (i *IdentityStore) persistOIDCClientUsage(ctx context.Context) {
+	i.oidcClientUsage.l.Lock()
+	pending := i.oidcClientUsage.pending
+	i.oidcClientUsage.pending = make(map[string]time.Time)
+	i.oidcClientUsage.l.Unlock()
+
+	for clientID, issuedAt := range pending {
+		if err := i.persistClientTokenIssued(ctx, clientID, issuedAt); err != nil {
+			i.Logger().Error("failed to persist the last token issuance time of client", "client_id", clientID, "error", err)
+		}
+	}
+}
+
+func (i *IdentityStore) persistClientTokenIssued(ctx context.Context, clientID string, issuedAt time.Time) error {
+	memClient, err := i.clientByID(clientID)
+	if err != nil || memClient == nil {
+		return err
+	}
+
+	ns, err := i.namespacer.NamespaceByID(ctx, memClient.NamespaceID)
+	if err != nil || ns == nil {
+		return err
+	}
+	ctx = namespace.ContextWithNamespace(ctx, ns)
+	s := i.router.MatchingStorageByAPIPath(ctx, ns.Path+"identity/oidc")
+	if s == nil {
+		return nil
+	}
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	// The client is read from storage under the lock, so that concurrent
+	// writes of the client aren't lost
+	entry, err := oidcClientStore.get(ctx, s, memClient.Name)
+	if err != nil || entry == nil {
+		return err
+	}
+	var client client
+	if err := entry.DecodeJSON(&client); err != nil {
+		return err
+	}
+	if client.ClientID != clientID || !issuedAt.After(client.LastTokenIssuedAt) {
+		return nil
+	}
+	client.LastTokenIssuedAt = issuedAt
+
+	entry, err = oidcClientStore.put(ctx, s, client.Name, client)
+	if err != nil {
+		return err
+	}
+	client.BucketKey = entry.BucketKey
+
+	return i.memDBReplaceClientByName(ctx, client.Name, &client)
+}
@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// oidcEntityCacheSize is the number of entities whose populated scope
+	// templates or assignment results are kept cached
+	oidcEntityCacheSize = 4096
+
+	// oidcClaimsCacheTTL is how long a populated scope template is served
+	// from the cache. Templates are removed from the cache as soon as their
+	// entity, its aliases or any group changes, so the TTL only bounds the
+	// staleness that a missed invalidation could cause.
+	oidcClaimsCacheTTL = 5 * time.Minute
+
+	// oidcAssignmentCacheTTL is how long the result of evaluating the
+	// assignments of a client for an entity is served from the cache. Like
+	// oidcClaimsCacheTTL, it only bounds the staleness that a missed
+	// invalidation could cause.
+	oidcAssignmentCacheTTL = time.Minute
+)
+
+// populatedScopeTemplate is a scope template populated for an entity
+type populatedScopeTemplate struct {
+	// populated is the JSON of the populated template, or empty if the
+	// template couldn't be populated to a JSON object
+	populated string
+
+	// claims are the top-level claims of the populated template
+	claims []string
+
+	// parsed holds the top-level claims of the populated template by name,
+	// so that they aren't parsed again when merged into a response. It is
+	// shared by the responses the template is merged into, and must not be
+	// modified.
+	parsed map[string]interface{}
+
+	// warning explains why the template populated no claims, if it failed
+	warning string
+}
+
+type oidcEntityCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// oidcEntityCache caches values computed for entities from their identity,
+// such as the scope templates populated for them or whether they are
+// authorized by the assignments of a client. Computing these values requires
+// the groups of the entity, which is costly for entities in large or nested
+// groups.
+//
+// Values are cached per entity and by key. The values of an entity are
+// invalidated when the entity or one of its aliases changes, and every value
+// is invalidated when a group changes since group membership can be
+// inherited. Values that depend on anything else must have it in their key,
+// or be purged when it changes.
+type oidcEntityCache struct {
+	l   sync.Mutex
+	ttl time.Duration
+
+	// entities maps entity IDs to their cached entries by key
+	entities *lru.Cache
+
+	// generation is incremented by every invalidation. Values computed from
+	// an identity read before an invalidation are not cached.
+	generation uint64
+}
+
+func newOIDCEntityCache(size int, ttl time.Duration) (*oidcEntityCache, error) {
+	entities, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcEntityCache{
+		ttl:      ttl,
+		entities: entities,
+	}, nil
+}
+
+// oidcClaimsCacheKey returns the key of a template populated for an entity
+// in the given namespace.
+func oidcClaimsCacheKey(namespaceID, scope, template string) string {
+	sum := sha256.Sum256([]byte(template))
+	return namespaceID + ":" + scope + ":" + hex.EncodeToString(sum[:])
+}
+
+// oidcAssignmentCacheKey returns the key of the result of evaluating the
+// given assignments of a client for an entity in the given namespace. The
+// assignments are part of the key so that updating a client doesn't need to
+// invalidate the cache.
+func oidcAssignmentCacheKey(namespaceID string, assignments []string) string {
+	return namespaceID + ":" + strings.Join(assignments, ",")
+}
+
+// currentGeneration returns the generation to pass to set for values computed
+// from the identity read after the call.
+func (c *oidcEntityCache) currentGeneration() uint64 {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	return c.generation
+}
+
+// get returns the value cached for the entity under the given key, if it
+// hasn't expired.
+func (c *oidcEntityCache) get(entityID, key string) (interface{}, bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	v, ok := c.entities.Get(entityID)
+	if !ok {
+		return nil, false
+	}
+	entry, ok := v.(map[string]*oidcEntityCacheEntry)[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+// set caches the value for the entity under the given key, unless the cache
+// was invalidated since the given generation.
+func (c *oidcEntityCache) set(generation uint64, entityID, key string, value interface{}) {
+	c.setUntil(generation, entityID, key, value, time.Time{})
+}
+
+// setUntil caches the value like set, but expires it by the given time if it
+// is earlier than the TTL of the cache. A zero time is ignored.
+func (c *oidcEntityCache) setUntil(generation uint64, entityID, key string, value interface{}, until time.Time) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	var entries map[string]*oidcEntityCacheEntry
+	if v, ok := c.entities.Get(entityID); ok {
+		entries = v.(map[string]*oidcEntityCacheEntry)
+	} else {
+		entries = make(map[string]*oidcEntityCacheEntry)
+		c.entities.Add(entityID, entries)
+	}
+	expiresAt := time.Now().Add(c.ttl)
+	if !until.IsZero() && until.Before(expiresAt) {
+		expiresAt = until
+	}
+	entries[key] = &oidcEntityCacheEntry{
+		value:     value,
+		expiresAt: expiresAt,
+	}
+}
+
+// invalidateEntity removes the values cached for the entity.
+func (c *oidcEntityCache) invalidateEntity(entityID string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.generation++
+	c.entities.Remove(entityID)
+}
+
+// purge removes every cached value.
+func (c *oidcEntityCache) purge() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.generation++
+	c.entities.Purge()
+}
+
+// purgeOIDCEntityCaches removes every value cached for entities.
+func (i *IdentityStore) purgeOIDCEntityCaches() {
+	i.oidcClaimsCache.purge()
+	i.oidcAssignmentCache.purge()
+}
+
+// invalidateOIDCEntityInTxn invalidates the values cached for the entity once
+// the transaction is committed, or the values of every entity if the entity
+// ID is empty. Invalidating on commit ensures that values computed from the
+// identity before the transaction aren't cached after it.
+func (i *IdentityStore) invalidateOIDCEntityInTxn(txn *memdb.Txn, entityID string) {
+	txn.Defer(func() {
+		if entityID == "" {
+			i.purgeOIDCEntityCaches()
+			return
+		}
+		i.oidcClaimsCache.invalidateEntity(entityID)
+		i.oidcAssignmentCache.invalidateEntity(entityID)
+	})
+}
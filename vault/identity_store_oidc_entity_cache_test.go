@@ -0,0 +1,230 @@
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCEntityCache(t *testing.T) {
+	c, err := newOIDCEntityCache(2, time.Minute)
+	require.NoError(t, err)
+
+	// Values are cached per entity and key
+	generation := c.currentGeneration()
+	c.set(generation, "entity-1", "key-1", "1-1")
+	c.set(generation, "entity-1", "key-2", "1-2")
+	c.set(generation, "entity-2", "key-1", "2-1")
+	for _, tc := range []struct{ entityID, key, value string }{
+		{"entity-1", "key-1", "1-1"},
+		{"entity-1", "key-2", "1-2"},
+		{"entity-2", "key-1", "2-1"},
+	} {
+		got, ok := c.get(tc.entityID, tc.key)
+		require.True(t, ok)
+		require.Equal(t, tc.value, got)
+	}
+	_, ok := c.get("entity-2", "key-2")
+	require.False(t, ok)
+
+	// The least recently used entity is evicted
+	c.set(generation, "entity-3", "key-1", "3-1")
+	_, ok = c.get("entity-1", "key-1")
+	require.False(t, ok)
+	_, ok = c.get("entity-3", "key-1")
+	require.True(t, ok)
+
+	// Values computed before an invalidation are not cached
+	c.invalidateEntity("entity-3")
+	_, ok = c.get("entity-3", "key-1")
+	require.False(t, ok)
+	c.set(generation, "entity-3", "key-1", "3-1")
+	_, ok = c.get("entity-3", "key-1")
+	require.False(t, ok)
+
+	generation = c.currentGeneration()
+	c.set(generation, "entity-3", "key-1", "3-1")
+	c.purge()
+	_, ok = c.get("entity-3", "key-1")
+	require.False(t, ok)
+
+	// Expired values are not served
+	c, err = newOIDCEntityCache(2, -time.Second)
+	require.NoError(t, err)
+	c.set(c.currentGeneration(), "entity-1", "key-1", "1-1")
+	_, ok = c.get("entity-1", "key-1")
+	require.False(t, ok)
+}
+
+// TestOIDC_PopulateScopeTemplates_Invalidate tests that the claims of an
+// entity are populated again after a change of the entity, its groups or the
+// scope templates.
+func TestOIDC_PopulateScopeTemplates_Invalidate(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	s := c.identityStore.view
+
+	resp, err := c.identityStore.HandleRequest(ctx, testEntityReq(s))
+	expectSuccess(t, resp, err)
+	entityID := resp.Data["id"].(string)
+	resp, err = c.identityStore.HandleRequest(ctx, testGroupReq(s, "child", []string{entityID}, nil))
+	expectSuccess(t, resp, err)
+	childID := resp.Data["id"].(string)
+	resp, err = c.identityStore.HandleRequest(ctx, testGroupReq(s, "parent", nil, []string{childID}))
+	expectSuccess(t, resp, err)
+
+	groupsTemplate := `{"groups": {{identity.entity.groups.names}}}`
+	resp, err = c.identityStore.HandleRequest(ctx, testScopeReq(s, "groups", groupsTemplate))
+	expectSuccess(t, resp, err)
+	resp, err = c.identityStore.HandleRequest(ctx, testScopeReq(s, "contact",
+		`{"email": {{identity.entity.metadata.email}}}`))
+	expectSuccess(t, resp, err)
+
+	populate := func() map[string]interface{} {
+		t.Helper()
+		entity, err := c.identityStore.MemDBEntityByID(entityID, true)
+		require.NoError(t, err)
+		templates, _, err := c.identityStore.populateScopeTemplates(ctx, s, namespace.RootNamespace, entity, scopeTemplateParams{}, "groups", "contact")
+		require.NoError(t, err)
+		claims := make(map[string]interface{})
+		require.NoError(t, mergeJSONTemplates(c.identityStore.Logger(), claims, templates...))
+		return claims
+	}
+
+	claims := populate()
+	require.ElementsMatch(t, []interface{}{"child", "parent"}, claims["groups"])
+	require.Equal(t, "test@hashicorp.com", claims["email"])
+	_, ok := c.identityStore.oidcClaimsCache.get(entityID, oidcClaimsCacheKey(namespace.RootNamespaceID, "groups", groupsTemplate))
+	require.True(t, ok)
+
+	// Removing the entity from the child group also removes it from the
+	// parent group
+	resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+		Storage:   s,
+		Path:      "group/id/" + childID,
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"member_entity_ids": []string{},
+		},
+	})
+	expectSuccess(t, resp, err)
+	claims = populate()
+	require.Empty(t, claims["groups"])
+
+	resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+		Storage:   s,
+		Path:      "entity/id/" + entityID,
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"metadata": map[string]string{
+				"email": "updated@hashicorp.com",
+			},
+		},
+	})
+	expectSuccess(t, resp, err)
+	claims = populate()
+	require.Equal(t, "updated@hashicorp.com", claims["email"])
+
+	// Updated templates are populated without an invalidation
+	resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+		Storage:   s,
+		Path:      "oidc/scope/contact",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"template": `{"mail": {{identity.entity.metadata.email}}}`,
+		},
+	})
+	expectSuccess(t, resp, err)
+	claims = populate()
+	require.Equal(t, "updated@hashicorp.com", claims["mail"])
+	require.NotContains(t, claims, "email")
+}
+
+// TestOIDC_EntityHasAssignment_Invalidate tests that assignments are evaluated
+// again after a change of the entity, its groups or the assignments.
+func TestOIDC_EntityHasAssignment_Invalidate(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	s := c.identityStore.view
+
+	resp, err := c.identityStore.HandleRequest(ctx, testEntityReq(s))
+	expectSuccess(t, resp, err)
+	entityID := resp.Data["id"].(string)
+	resp, err = c.identityStore.HandleRequest(ctx, testGroupReq(s, "child", []string{entityID}, nil))
+	expectSuccess(t, resp, err)
+	childID := resp.Data["id"].(string)
+	resp, err = c.identityStore.HandleRequest(ctx, testGroupReq(s, "parent", nil, []string{childID}))
+	expectSuccess(t, resp, err)
+	parentID := resp.Data["id"].(string)
+
+	writeAssignment := func(groupIDs []string) {
+		t.Helper()
+		resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+			Storage:   s,
+			Path:      "oidc/assignment/test-assignment",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"group_ids": groupIDs,
+			},
+		})
+		expectSuccess(t, resp, err)
+	}
+	setMembers := func(entityIDs []string) {
+		t.Helper()
+		resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+			Storage:   s,
+			Path:      "group/id/" + childID,
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"member_entity_ids": entityIDs,
+			},
+		})
+		expectSuccess(t, resp, err)
+	}
+	hasAssignment := func() bool {
+		t.Helper()
+		entity, err := c.identityStore.MemDBEntityByID(entityID, true)
+		require.NoError(t, err)
+		ok, err := c.identityStore.entityHasAssignment(ctx, s, entity, []string{"test-assignment"})
+		require.NoError(t, err)
+		return ok
+	}
+
+	// The entity inherits the membership of the parent group
+	writeAssignment([]string{parentID})
+	require.True(t, hasAssignment())
+	_, ok := c.identityStore.oidcAssignmentCache.get(entityID,
+		oidcAssignmentCacheKey(namespace.RootNamespaceID, []string{"test-assignment"}))
+	require.True(t, ok)
+
+	setMembers([]string{})
+	require.False(t, hasAssignment())
+	setMembers([]string{entityID})
+	require.True(t, hasAssignment())
+
+	writeAssignment([]string{})
+	require.False(t, hasAssignment())
+
+	// Invalidations of assignments written by another node purge the cache
+	entry, err := oidcAssignmentStore.put(ctx, s, "test-assignment", &assignment{
+		GroupIDs: []string{childID},
+	})
+	require.NoError(t, err)
+	c.identityStore.Invalidate(ctx, entry.BucketKey)
+	require.True(t, hasAssignment())
+
+	// Disabled entities are never authorized
+	resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+		Storage:   s,
+		Path:      "entity/id/" + entityID,
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"disabled": true,
+		},
+	})
+	expectSuccess(t, resp, err)
+	require.False(t, hasAssignment())
+}
@@ -0,0 +1,163 @@
+package vault
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/hashicorp/go-uuid"
+	"golang.org/x/crypto/ed25519"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// oidcKeySource generates OIDC keys deterministically from a seed so that
+// tests can produce reproducible key IDs, JWKS documents and token
+// signatures. It can only be enabled through TestSetOIDCKeySeed; a nil
+// source generates random keys.
+//
+// The keys are derived from a stream of SHA-256 hashes of the seed and a
+// counter rather than by passing a reader to the key generation functions
+// of the standard library, which deliberately don't behave deterministically.
+// Signatures made with ECDSA keys remain randomized.
+type oidcKeySource struct {
+	l       sync.Mutex
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func newOIDCKeySource(seed []byte) *oidcKeySource {
+	if seed == nil {
+		return nil
+	}
+	return &oidcKeySource{
+		seed: append([]byte(nil), seed...),
+	}
+}
+
+// fill fills p with the next bytes of the stream. The lock must be held.
+func (keys *oidcKeySource) fill(p []byte) {
+	for len(p) > 0 {
+		if len(keys.buf) == 0 {
+			var counter [8]byte
+			binary.BigEndian.PutUint64(counter[:], keys.counter)
+			keys.counter++
+
+			h := sha256.New()
+			h.Write(keys.seed)
+			h.Write(counter[:])
+			keys.buf = h.Sum(nil)
+		}
+		n := copy(p, keys.buf)
+		keys.buf = keys.buf[n:]
+		p = p[n:]
+	}
+}
+
+func (keys *oidcKeySource) generateDeterministicKeys(algorithm string) (*jose.JSONWebKey, error) {
+	keys.l.Lock()
+	defer keys.l.Unlock()
+
+	var key interface{}
+	switch algorithm {
+	case "RS256", "RS384", "RS512":
+		key = keys.rsaKey(2048)
+	case "ES256":
+		key = keys.ecdsaKey(elliptic.P256())
+	case "ES384":
+		key = keys.ecdsaKey(elliptic.P384())
+	case "ES512":
+		key = keys.ecdsaKey(elliptic.P521())
+	case "EdDSA":
+		seed := make([]byte, ed25519.SeedSize)
+		keys.fill(seed)
+		key = ed25519.NewKeyFromSeed(seed)
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", algorithm)
+	}
+
+	idBytes := make([]byte, 16)
+	keys.fill(idBytes)
+	id, err := uuid.FormatUUID(idBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jose.JSONWebKey{
+		Key:       key,
+		KeyID:     id,
+		Algorithm: algorithm,
+		Use:       "sig",
+	}, nil
+}
+
+// rsaKey returns an RSA key of the given size, which must be a multiple of
+// 16 bits.
+func (keys *oidcKeySource) rsaKey(bits int) *rsa.PrivateKey {
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+	for {
+		p := keys.prime(bits / 2)
+		q := keys.prime(bits / 2)
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: new(big.Int).Mul(p, q),
+				E: int(e.Int64()),
+			},
+			D:      d,
+			Primes: []*big.Int{p, q},
+		}
+		key.Precompute()
+		return key
+	}
+}
+
+// prime returns a prime of the given size, which must be a multiple of 8
+// bits. Its two top bits are set so that the product of two such primes has
+// twice the size.
+func (keys *oidcKeySource) prime(bits int) *big.Int {
+	b := make([]byte, bits/8)
+	for {
+		keys.fill(b)
+		b[0] |= 0xc0
+		b[len(b)-1] |= 1
+
+		p := new(big.Int).SetBytes(b)
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
+
+func (keys *oidcKeySource) ecdsaKey(curve elliptic.Curve) *ecdsa.PrivateKey {
+	params := curve.Params()
+
+	// Reduce 64 more bits than the order to make the bias negligible
+	b := make([]byte, (params.N.BitLen()+64+7)/8)
+	keys.fill(b)
+	n := new(big.Int).Sub(params.N, big.NewInt(1))
+	d := new(big.Int).Mod(new(big.Int).SetBytes(b), n)
+	d.Add(d, big.NewInt(1))
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve},
+		D:         d,
+	}
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(d.Bytes())
+	return key
+}
@@ -7,20 +7,27 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/go-memdb"
 	"github.com/hashicorp/go-secure-stdlib/base62"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/cidrutil"
 	"github.com/hashicorp/vault/sdk/helper/identitytpl"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault/quotas"
 	"gopkg.in/square/go-jose.v2"
 )
 
@@ -30,6 +37,7 @@ const (
 	scopesDelimiter          = " "
 	accessTokenScopesMeta    = "scopes"
 	accessTokenClientIDMeta  = "client_id"
+	accessTokenProviderMeta  = "provider"
 	clientIDLength           = 32
 	clientSecretLength       = 64
 	clientSecretPrefix       = "hvo_secret_"
@@ -39,7 +47,36 @@ const (
 	defaultKeyName           = "default"
 	allowAllAssignmentName   = "allow_all"
 
-	// Storage path constants
+	// Values of the prompt parameter of the authorization endpoint. See
+	// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+	promptNone          = "none"
+	promptLogin         = "login"
+	promptConsent       = "consent"
+	promptSelectAccount = "select_account"
+
+	// oidcScopeTemplateWorkers is the maximum number of scope templates
+	// populated concurrently for a token or user info request
+	oidcScopeTemplateWorkers = 8
+
+	// oidcResolveLimit is the maximum number of entity and of group IDs of
+	// an assignment whose names are resolved on reads
+	oidcResolveLimit = 100
+
+	// oidcReferencedByLimit is the maximum number of names of referencing
+	// objects returned on reads of scopes and keys
+	oidcReferencedByLimit = 100
+
+	// defaultOIDCMaintenanceMessage is the message of providers in
+	// maintenance that don't set their own
+	defaultOIDCMaintenanceMessage = "the provider is temporarily unavailable for maintenance"
+
+	// oidcIssuerLookupTimeout bounds the resolution of the host of issuers
+	// on provider writes
+	oidcIssuerLookupTimeout = 2 * time.Second
+
+	// Storage path constants. Assignments, scopes and clients are packed
+	// into buckets by oidcObjectStore, and only read from these paths until
+	// they are migrated.
 	oidcProviderPrefix = "oidc_provider/"
 	assignmentPath     = oidcProviderPrefix + "assignment/"
 	scopePath          = oidcProviderPrefix + "scope/"
@@ -55,21 +92,39 @@ const (
 	ErrAuthServerError             = "server_error"
 	ErrAuthRequestNotSupported     = "request_not_supported"
 	ErrAuthRequestURINotSupported  = "request_uri_not_supported"
+	ErrAuthTemporarilyUnavailable  = "temporarily_unavailable"
+	ErrAuthLoginRequired           = "login_required"
 
 	// Error constants used in the Token Endpoint. See details at
 	// https://openid.net/specs/openid-connect-core-1_0.html#TokenErrorResponse
 	ErrTokenInvalidRequest       = "invalid_request"
 	ErrTokenInvalidClient        = "invalid_client"
 	ErrTokenInvalidGrant         = "invalid_grant"
+	ErrTokenUnauthorizedClient   = "unauthorized_client"
 	ErrTokenUnsupportedGrantType = "unsupported_grant_type"
+	ErrTokenInvalidScope         = "invalid_scope"
 	ErrTokenServerError          = "server_error"
 
+	// ErrTokenTemporarilyUnavailable is returned with the 503 status code
+	// while a provider is in maintenance. The token endpoint has no such
+	// error in RFC 6749, so it mirrors the one of the authorization endpoint.
+	ErrTokenTemporarilyUnavailable = "temporarily_unavailable"
+
+	// Error constants used in the Token Endpoint for the device
+	// authorization grant. See details at
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+	ErrTokenAuthorizationPending = "authorization_pending"
+	ErrTokenSlowDown             = "slow_down"
+	ErrTokenAccessDenied         = "access_denied"
+	ErrTokenExpiredToken         = "expired_token"
+
 	// Error constants used in the UserInfo Endpoint. See details at
 	// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoError
-	ErrUserInfoServerError    = "server_error"
-	ErrUserInfoInvalidRequest = "invalid_request"
-	ErrUserInfoInvalidToken   = "invalid_token"
-	ErrUserInfoAccessDenied   = "access_denied"
+	// and https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+	ErrUserInfoServerError       = "server_error"
+	ErrUserInfoInvalidRequest    = "invalid_request"
+	ErrUserInfoInvalidToken      = "invalid_token"
+	ErrUserInfoInsufficientScope = "insufficient_scope"
 
 	// The following errors are used by the UI for specific behavior of
 	// the OIDC specification. Any changes to their values must come with
@@ -82,6 +137,14 @@ const (
 type assignment struct {
 	GroupIDs  []string `json:"group_ids"`
 	EntityIDs []string `json:"entity_ids"`
+
+	// The assignment-wide time bounds, and those of its entities and groups
+	// by ID. An entity is authorized through an entity or group only while
+	// both the assignment's bounds and the entry's bounds contain the
+	// current time.
+	assignmentTimeBounds
+	EntityTimeBounds map[string]assignmentTimeBounds `json:"entity_time_bounds,omitempty"`
+	GroupTimeBounds  map[string]assignmentTimeBounds `json:"group_time_bounds,omitempty"`
 }
 
 type scope struct {
@@ -101,12 +164,134 @@ type client struct {
 	IDTokenTTL     time.Duration `json:"id_token_ttl"`
 	AccessTokenTTL time.Duration `json:"access_token_ttl"`
 	Type           clientType    `json:"type"`
+	AllowedOrigins []string      `json:"allowed_origins"`
+
+	// PostLogoutRedirectURIs are the URIs that the end_session_endpoint of
+	// the providers redirects to after the client's end-users log out
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+
+	// DisallowLoopbackRedirects requires loopback redirect URIs to match a
+	// registered URI exactly, including its port. It's inverted so that
+	// clients stored before it was added keep port-agnostic matching.
+	DisallowLoopbackRedirects bool `json:"disallow_loopback_redirects"`
+
+	// RequireAuthTime adds the auth_time claim to the ID tokens of the
+	// authorization code flow even if the max_age parameter isn't requested
+	RequireAuthTime bool `json:"require_auth_time"`
+
+	// UserInfoSignedResponseAlg is the algorithm of the client's key if the
+	// userinfo endpoint returns a signed JWT to the client rather than JSON
+	UserInfoSignedResponseAlg string `json:"userinfo_signed_response_alg"`
+
+	// RefreshTokenTTL is the time-to-live of the refresh tokens issued to
+	// the client for the offline_access scope. The client isn't issued
+	// refresh tokens if zero. RefreshTokenMaxTTL bounds how long the tokens
+	// of a login can be refreshed, without bound if zero.
+	RefreshTokenTTL    time.Duration `json:"refresh_token_ttl"`
+	RefreshTokenMaxTTL time.Duration `json:"refresh_token_max_ttl"`
+
+	// PasswordGrantMount is the path of the auth mount that the client's
+	// resource owner password credentials grants log in with. The grant is
+	// disabled for the client if it's empty.
+	PasswordGrantMount string `json:"password_grant_mount"`
+
+	// TokenEndpointAllowedCIDRs are the CIDR blocks that the client's token
+	// requests must come from. Requests from any address are allowed if empty.
+	TokenEndpointAllowedCIDRs []string `json:"token_endpoint_allowed_cidrs"`
+
+	// TokenEndpointCIDRsSkipRefresh exempts the refresh token grant from
+	// TokenEndpointAllowedCIDRs. It's inverted so that clients stored before
+	// it was added keep applying the CIDR blocks to every grant.
+	TokenEndpointCIDRsSkipRefresh bool `json:"token_endpoint_cidrs_skip_refresh"`
+
+	// GrantTypes are the grant types that the client may use at the token
+	// endpoint. The default grant types are allowed if empty.
+	GrantTypes []string `json:"grant_types"`
+
+	// EntityID is the entity that the client_credentials grant issues
+	// tokens for. The tokens are issued for the client itself if empty.
+	EntityID string `json:"entity_id"`
+
+	// SubjectType is the type of the sub claim of the client's tokens,
+	// either public or pairwise. The client is public if empty.
+	SubjectType string `json:"subject_type"`
+
+	// TokenEndpointAuthMethod is the method that the client authenticates
+	// with. The method of the client type is used if empty.
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method"`
+
+	// JWKS is the JSON Web Key Set of the public keys that verify the
+	// assertions of private_key_jwt clients, and JWKSURI is the URL it's
+	// fetched from instead. ClientAssertionClockSkew is the clock skew
+	// tolerated when validating the time claims of the assertions.
+	JWKS                     string        `json:"jwks"`
+	JWKSURI                  string        `json:"jwks_uri"`
+	ClientAssertionClockSkew time.Duration `json:"client_assertion_clock_skew"`
+
+	// ExpiresAt is the time from which the client is refused by the
+	// authorization and token endpoints. The client never expires if zero.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// ExpiryRetentionPeriod is how long the client is kept once expired
+	// before it's deleted. Expired clients are kept until deleted if zero.
+	ExpiryRetentionPeriod time.Duration `json:"expiry_retention_period"`
+
+	// RevokeTokensOnExpiry refuses the access tokens issued to the client
+	// once it expires, rather than letting them run out their TTL.
+	RevokeTokensOnExpiry bool `json:"revoke_tokens_on_expiry"`
+
+	// Times of the client's creation, last update and last token issuance.
+	// LastTokenIssuedAt is only updated once per clientUsageInterval.
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	LastTokenIssuedAt time.Time `json:"last_token_issued_at"`
 
 	// Generated values that are used in OIDC endpoints
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
+
+	// PreviousClientSecret is the client secret that ClientSecret was
+	// rotated from at ClientSecretRotatedAt. It's accepted along with the
+	// client secret until PreviousClientSecretExpireAt.
+	ClientSecretRotatedAt        time.Time `json:"client_secret_rotated_at,omitempty"`
+	PreviousClientSecret         string    `json:"previous_client_secret,omitempty"`
+	PreviousClientSecretExpireAt time.Time `json:"previous_client_secret_expire_at,omitempty"`
+
+	// PreviousKey is the key the client was migrated from. Its public keys
+	// stay published to the client's providers until PreviousKeyExpireAt so
+	// that tokens signed before the migration can still be verified.
+	PreviousKey         string    `json:"previous_key,omitempty"`
+	PreviousKeyExpireAt time.Time `json:"previous_key_expire_at,omitempty"`
+
+	// BucketKey is the storage key of the bucket the client is stored in.
+	// Used for indexing in memdb.
+	BucketKey string `json:"-"`
+}
+
+// expired returns true if the client has expired at the given time.
+func (c *client) expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// deleteAt returns the time at which the client is deleted once expired, or
+// the zero time if it's never deleted.
+func (c *client) deleteAt() time.Time {
+	if c.ExpiresAt.IsZero() || c.ExpiryRetentionPeriod <= 0 {
+		return time.Time{}
+	}
+	return c.ExpiresAt.Add(c.ExpiryRetentionPeriod)
+}
+
+// previousKeyVerifies returns true if tokens signed by the key the client was
+// migrated from can still be verified.
+func (c *client) previousKeyVerifies() bool {
+	return c.PreviousKey != "" && time.Now().Before(c.PreviousKeyExpireAt)
 }
 
+// oidcIssuerLookupHost resolves the host of issuers on provider writes. It
+// is replaced in tests that shouldn't depend on DNS.
+var oidcIssuerLookupHost = net.DefaultResolver.LookupHost
+
 type clientType int
 
 const (
@@ -129,25 +314,104 @@ type provider struct {
 	Issuer           string   `json:"issuer"`
 	AllowedClientIDs []string `json:"allowed_client_ids"`
 	ScopesSupported  []string `json:"scopes_supported"`
+	DefaultScopes    []string `json:"default_scopes"`
+
+	// AllowedRedirectHosts are the glob patterns of the hosts that the
+	// redirect URIs of the clients of the provider may use. Any host is
+	// allowed if empty.
+	AllowedRedirectHosts []string `json:"allowed_redirect_hosts"`
+
+	// MetadataSigningKey is the name of the key that signs the metadata of
+	// the discovery document. The signed_metadata is omitted if empty.
+	MetadataSigningKey string `json:"metadata_signing_key"`
+
+	// Maintenance stops new logins through the provider while set. The
+	// discovery document, keys and userinfo endpoint keep working so that
+	// relying parties with issued tokens aren't affected.
+	Maintenance bool `json:"maintenance"`
+
+	// MaintenanceMessage is returned by the authorization and token
+	// endpoints while the provider is in maintenance. A default message is
+	// returned if empty.
+	MaintenanceMessage string `json:"maintenance_message"`
+
+	// The display name of the provider and the URLs of its documentation,
+	// policy and terms of service, which are published in the discovery
+	// document when set
+	DisplayName          string `json:"display_name"`
+	ServiceDocumentation string `json:"service_documentation"`
+	OPPolicyURI          string `json:"op_policy_uri"`
+	OPTOSURI             string `json:"op_tos_uri"`
+
+	// AdditionalIssuers are the normalized issuers that the provider is
+	// also reachable at, such as the old issuer while relying parties
+	// migrate to a new one
+	AdditionalIssuers []string `json:"additional_issuers"`
+
+	// AllowCrossClientIntrospection allows clients to introspect the access
+	// tokens issued to other clients of the provider
+	AllowCrossClientIntrospection bool `json:"allow_cross_client_introspection"`
 
 	// effectiveIssuer is a calculated field and will be either Issuer (if
 	// that's set) or the Vault instance's api_addr.
 	effectiveIssuer string
+
+	// effectiveAdditionalIssuers are the calculated issuers of
+	// AdditionalIssuers, keyed by their lowercase host
+	effectiveAdditionalIssuers map[string]string
+
+	// name is the name of the provider, which is set when it's read
+	name string
+}
+
+// maintenanceMessage returns the message of the requests that the provider
+// refuses while in maintenance.
+func (p *provider) maintenanceMessage() string {
+	if p.MaintenanceMessage == "" {
+		return defaultOIDCMaintenanceMessage
+	}
+	return p.MaintenanceMessage
+}
+
+// issuerForHost returns the issuer that the provider advertises to requests
+// for the given host: the additional issuer with that host, if any, or the
+// primary issuer.
+func (p *provider) issuerForHost(host string) string {
+	if issuer, ok := p.effectiveAdditionalIssuers[strings.ToLower(host)]; ok {
+		return issuer
+	}
+	return p.effectiveIssuer
 }
 
 type providerDiscovery struct {
-	Issuer                string   `json:"issuer"`
-	Keys                  string   `json:"jwks_uri"`
-	AuthorizationEndpoint string   `json:"authorization_endpoint"`
-	TokenEndpoint         string   `json:"token_endpoint"`
-	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
-	RequestURIParameter   bool     `json:"request_uri_parameter_supported"`
-	IDTokenAlgs           []string `json:"id_token_signing_alg_values_supported"`
-	ResponseTypes         []string `json:"response_types_supported"`
-	Scopes                []string `json:"scopes_supported"`
-	Subjects              []string `json:"subject_types_supported"`
-	GrantTypes            []string `json:"grant_types_supported"`
-	AuthMethods           []string `json:"token_endpoint_auth_methods_supported"`
+	Issuer                      string   `json:"issuer"`
+	Keys                        string   `json:"jwks_uri"`
+	AuthorizationEndpoint       string   `json:"authorization_endpoint"`
+	TokenEndpoint               string   `json:"token_endpoint"`
+	UserinfoEndpoint            string   `json:"userinfo_endpoint"`
+	RequestURIParameter         bool     `json:"request_uri_parameter_supported"`
+	IDTokenAlgs                 []string `json:"id_token_signing_alg_values_supported"`
+	UserInfoAlgs                []string `json:"userinfo_signing_alg_values_supported"`
+	ResponseTypes               []string `json:"response_types_supported"`
+	Scopes                      []string `json:"scopes_supported"`
+	Claims                      []string `json:"claims_supported"`
+	Subjects                    []string `json:"subject_types_supported"`
+	GrantTypes                  []string `json:"grant_types_supported"`
+	AuthMethods                 []string `json:"token_endpoint_auth_methods_supported"`
+	AuthSigningAlgs             []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	CodeChallengeMethods        []string `json:"code_challenge_methods_supported"`
+	IntrospectionEndpoint       string   `json:"introspection_endpoint"`
+	IntrospectionMethods        []string `json:"introspection_endpoint_auth_methods_supported"`
+	EndSessionEndpoint          string   `json:"end_session_endpoint"`
+	DeviceAuthorizationEndpoint string   `json:"device_authorization_endpoint"`
+
+	// Optional metadata is omitted if unset, since some relying parties
+	// refuse empty URLs
+	DisplayName          string `json:"display_name,omitempty"`
+	ServiceDocumentation string `json:"service_documentation,omitempty"`
+	OPPolicyURI          string `json:"op_policy_uri,omitempty"`
+	OPTOSURI             string `json:"op_tos_uri,omitempty"`
+	SignedMetadata       string `json:"signed_metadata,omitempty"`
 }
 
 type authCodeCacheEntry struct {
@@ -160,9 +424,89 @@ type authCodeCacheEntry struct {
 	authTime            time.Time
 	codeChallenge       string
 	codeChallengeMethod string
+	offlineAccess       bool
+}
+
+// oidcProviderErrorFields are the fields of the OAuth 2.0 error response
+// returned by the provider endpoints. See details at
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+var oidcProviderErrorFields = map[string]*framework.FieldSchema{
+	"error": {
+		Type:        framework.TypeString,
+		Description: "The error code.",
+		Required:    true,
+	},
+	"error_description": {
+		Type:        framework.TypeString,
+		Description: "A human-readable description of the error.",
+	},
+}
+
+// oidcProviderResponses returns the documented responses of a provider
+// endpoint, which responds with the given fields on success and with an
+// OAuth 2.0 error response having the given additional fields for each of
+// the given error status codes.
+func oidcProviderResponses(fields, errorFields map[string]*framework.FieldSchema, errorCodes ...int) map[int][]framework.Response {
+	responses := map[int][]framework.Response{
+		http.StatusOK: {{
+			Description: "OK",
+			Fields:      fields,
+		}},
+	}
+
+	if len(errorCodes) == 0 {
+		return responses
+	}
+
+	allErrorFields := make(map[string]*framework.FieldSchema, len(oidcProviderErrorFields)+len(errorFields))
+	for name, field := range oidcProviderErrorFields {
+		allErrorFields[name] = field
+	}
+	for name, field := range errorFields {
+		allErrorFields[name] = field
+	}
+	for _, code := range errorCodes {
+		responses[code] = []framework.Response{{
+			Description: http.StatusText(code),
+			Fields:      allErrorFields,
+		}}
+	}
+	return responses
 }
 
 func oidcProviderPaths(i *IdentityStore) []*framework.Path {
+	authorizeResponses := oidcProviderResponses(
+		map[string]*framework.FieldSchema{
+			"code": {
+				Type:        framework.TypeString,
+				Description: "The authorization code to exchange at the token endpoint.",
+				Required:    true,
+			},
+			"state": {
+				Type:        framework.TypeString,
+				Description: "The state parameter of the request.",
+				Required:    true,
+			},
+		},
+		map[string]*framework.FieldSchema{
+			"state": {
+				Type:        framework.TypeString,
+				Description: "The state parameter of the request.",
+			},
+		},
+		http.StatusBadRequest, http.StatusInternalServerError)
+
+	userInfoResponses := oidcProviderResponses(
+		map[string]*framework.FieldSchema{
+			"sub": {
+				Type:        framework.TypeString,
+				Description: "The subject of the access token, which is the ID of the entity, or an identifier of the entity that is unique to the client for pairwise clients. The other claims are those of the scopes granted to the client.",
+				Required:    true,
+			},
+		},
+		nil,
+		http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError)
+
 	return []*framework.Path{
 		{
 			Pattern: "oidc/assignment/" + framework.GenericNameRegex("name"),
@@ -177,7 +521,27 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 				},
 				"group_ids": {
 					Type:        framework.TypeCommaStringSlice,
-					Description: "Comma separated string or array of identity group IDs",
+					Description: "Comma separated string or array of identity group IDs. Members of the groups' member groups, at any depth, are members of the groups.",
+				},
+				"not_before": {
+					Type:        framework.TypeString,
+					Description: "RFC3339 timestamp before which the assignment authorizes no entities. Empty for no bound.",
+				},
+				"not_after": {
+					Type:        framework.TypeString,
+					Description: "RFC3339 timestamp from which the assignment authorizes no entities. Empty for no bound.",
+				},
+				"entity_time_bounds": {
+					Type:        framework.TypeMap,
+					Description: "Map of entity IDs of the assignment to objects with optional not_before and not_after RFC3339 timestamps, which bound when the entity is authorized.",
+				},
+				"group_time_bounds": {
+					Type:        framework.TypeMap,
+					Description: "Map of group IDs of the assignment to objects with optional not_before and not_after RFC3339 timestamps, which bound when the group's members are authorized.",
+				},
+				"resolve": {
+					Type:        framework.TypeBool,
+					Description: "If set on reads, the response includes the current names of the entities and groups of the assignment.",
 				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -193,6 +557,9 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 				logical.DeleteOperation: &framework.PathOperation{
 					Callback: i.pathOIDCDeleteAssignment,
 				},
+				logical.PatchOperation: &framework.PathOperation{
+					Callback: i.oidcPatchCallback(i.pathOIDCCreateUpdateAssignment, i.pathOIDCReadAssignment),
+				},
 			},
 			ExistenceCheck:  i.pathOIDCAssignmentExistenceCheck,
 			HelpSynopsis:    "CRUD operations for OIDC assignments.",
@@ -237,11 +604,43 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 				logical.DeleteOperation: &framework.PathOperation{
 					Callback: i.pathOIDCDeleteScope,
 				},
+				logical.PatchOperation: &framework.PathOperation{
+					Callback: i.oidcPatchCallback(i.pathOIDCCreateUpdateScope, i.pathOIDCReadScope),
+				},
 			},
 			ExistenceCheck:  i.pathOIDCScopeExistenceCheck,
 			HelpSynopsis:    "CRUD operations for OIDC scopes.",
 			HelpDescription: "Create, Read, Update, and Delete OIDC scopes.",
 		},
+		{
+			Pattern: "oidc/scope/" + framework.GenericNameRegex("name") + "/render$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the scope",
+				},
+				"entity_id": {
+					Type:        framework.TypeString,
+					Description: "ID of the entity to render the template for",
+					Required:    true,
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "Client ID of the client to render the template for, which templates reference as {{identity.oidc.client_id}}. Optional.",
+				},
+				"provider": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider to render the template for, which templates reference as {{identity.oidc.provider}}. Optional.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: i.pathOIDCRenderScope,
+				},
+			},
+			HelpSynopsis:    "Render the template of an OIDC scope.",
+			HelpDescription: "Render the template of an OIDC scope for an entity, as the token and userinfo endpoints of providers do for the given client and provider.",
+		},
 		{
 			Pattern: "oidc/scope/?$",
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -267,6 +666,15 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeCommaStringSlice,
 					Description: "Comma separated string or array of assignment resources.",
 				},
+				"post_logout_redirect_uris": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the URIs that end-users of the client may be redirected to after logging out. One of these values must exactly match the post_logout_redirect_uri parameter value used in each logout request.",
+				},
+				"allow_loopback_redirects": {
+					Type:        framework.TypeBool,
+					Description: "If true, the port of loopback redirect URIs, such as http://127.0.0.1/callback, is ignored when matching them against the redirect URIs of the client, for native applications that listen on an ephemeral port. If false, loopback redirect URIs must match exactly.",
+					Default:     true,
+				},
 				"key": {
 					Type:        framework.TypeString,
 					Description: "A reference to a named key resource. Cannot be modified after creation. Defaults to the 'default' key.",
@@ -277,16 +685,92 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 					Description: "The time-to-live for ID tokens obtained by the client.",
 					Default:     "24h",
 				},
+				"require_auth_time": {
+					Type:        framework.TypeBool,
+					Description: "If true, the ID tokens issued to the client by the authorization code flow always contain the auth_time claim, rather than only when the max_age parameter is requested.",
+				},
+				"userinfo_signed_response_alg": {
+					Type:        framework.TypeString,
+					Description: "The algorithm of the client's key, if the UserInfo endpoint responds to the client with a JWT signed by the key. The response is plain JSON if unset.",
+				},
 				"access_token_ttl": {
 					Type:        framework.TypeDurationSecond,
 					Description: "The time-to-live for access tokens obtained by the client.",
 					Default:     "24h",
 				},
+				"refresh_token_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "The time-to-live for refresh tokens obtained by the client with the 'offline_access' scope. Refresh tokens are rotated on every use. The client isn't issued refresh tokens if zero.",
+				},
+				"refresh_token_max_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "The maximum time that the tokens of a login can be refreshed for. Unlimited if zero.",
+				},
 				"client_type": {
 					Type:        framework.TypeString,
 					Description: "The client type based on its ability to maintain confidentiality of credentials. The following client types are supported: 'confidential', 'public'. Defaults to 'confidential'.",
 					Default:     "confidential",
 				},
+				"allowed_origins": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the origins allowed to make cross-origin requests to the token and userinfo endpoints for the client. Wildcards are allowed but discouraged.",
+				},
+				"password_grant_mount": {
+					Type:        framework.TypeString,
+					Description: "The path of the auth mount used to log in resource owners with the password grant type, such as 'userpass/'. The password grant type is disabled for the client if not set.",
+				},
+				"token_endpoint_allowed_cidrs": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the CIDR blocks that the client's token requests must come from. If empty, token requests from any address are allowed.",
+				},
+				"token_endpoint_cidrs_apply_to_refresh": {
+					Type:        framework.TypeBool,
+					Description: "If true, the refresh token requests of the client must also come from its token_endpoint_allowed_cidrs. If false, refresh token requests from any address are allowed.",
+					Default:     true,
+				},
+				"grant_types": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of the grant types that the client may use. The supported grant types are 'authorization_code', 'refresh_token', 'password', 'urn:ietf:params:oauth:grant-type:device_code' and 'client_credentials'. If empty, all grant types but 'client_credentials' are allowed.",
+				},
+				"entity_id": {
+					Type:        framework.TypeString,
+					Description: "The ID of the entity that tokens of the client_credentials grant are issued for. If empty, the tokens are issued for the client itself.",
+				},
+				"subject_type": {
+					Type:          framework.TypeString,
+					Description:   "The type of the subject identifier of the client's tokens. The following subject types are supported: 'public', 'pairwise'. Pairwise clients get a subject identifier of the entity that's unique to the client. Changing it changes the subject of the client's end-users. Defaults to 'public'.",
+					AllowedValues: []interface{}{subjectTypePublic, subjectTypePairwise},
+				},
+				"token_endpoint_auth_method": {
+					Type:          framework.TypeString,
+					Description:   "The method that the client authenticates to the token endpoint with. Confidential clients use 'client_secret_basic' or 'private_key_jwt', and public clients use 'none'. Clients using 'private_key_jwt' can't authenticate with their client secret. Defaults to 'client_secret_basic' for confidential clients and 'none' for public clients.",
+					AllowedValues: []interface{}{tokenEndpointAuthMethodSecretBasic, tokenEndpointAuthMethodPrivateKeyJWT, tokenEndpointAuthMethodNone},
+				},
+				"jwks": {
+					Type:        framework.TypeString,
+					Description: "The JSON Web Key Set of the public keys that verify the client's assertions with the 'private_key_jwt' method. Cannot be set with jwks_uri.",
+				},
+				"jwks_uri": {
+					Type:        framework.TypeString,
+					Description: "The URL of the JSON Web Key Set of the public keys that verify the client's assertions with the 'private_key_jwt' method. Cannot be set with jwks.",
+				},
+				"client_assertion_clock_skew": {
+					Type:        framework.TypeDurationSecond,
+					Description: "The clock skew tolerated when validating the time claims of the client's assertions with the 'private_key_jwt' method.",
+					Default:     "60s",
+				},
+				"expires_at": {
+					Type:        framework.TypeString,
+					Description: "RFC3339 timestamp from which the client is refused by the authorization and token endpoints. If empty, the client never expires.",
+				},
+				"expiry_retention_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the client is kept once expired before it's deleted. If zero, expired clients are kept until deleted.",
+				},
+				"revoke_tokens_on_expiry": {
+					Type:        framework.TypeBool,
+					Description: "If true, the access tokens issued to the client are refused once it expires. Otherwise, they stay valid until their TTL runs out.",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.UpdateOperation: &framework.PathOperation{
@@ -301,6 +785,9 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 				logical.DeleteOperation: &framework.PathOperation{
 					Callback: i.pathOIDCDeleteClient,
 				},
+				logical.PatchOperation: &framework.PathOperation{
+					Callback: i.oidcPatchCallback(i.pathOIDCCreateUpdateClient, i.pathOIDCReadClient, "client_id", "client_secret", "key", "client_type"),
+				},
 			},
 			ExistenceCheck:  i.pathOIDCClientExistenceCheck,
 			HelpSynopsis:    "CRUD operations for OIDC clients.",
@@ -308,6 +795,28 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 		},
 		{
 			Pattern: "oidc/client/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"after": {
+					Type:        framework.TypeString,
+					Description: "Optional client name after which to start listing. Clients are listed in lexicographical order.",
+				},
+				"limit": {
+					Type:        framework.TypeInt,
+					Description: "Optional maximum number of clients to return. If not set, all matching clients are returned.",
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "Optional name of the key that returned clients must use.",
+				},
+				"assignment": {
+					Type:        framework.TypeString,
+					Description: "Optional name of an assignment that returned clients must reference.",
+				},
+				"detailed": {
+					Type:        framework.TypeBool,
+					Description: "If set, key_info will contain details about each returned client. Client secrets are never returned.",
+				},
+			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ListOperation: &framework.PathOperation{
 					Callback: i.pathOIDCListClient,
@@ -327,6 +836,10 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "Specifies what will be used for the iss claim of ID tokens.",
 				},
+				"additional_issuers": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Issuers that the provider is also reachable at, such as while migrating to a new issuer. The discovery document served from the host of one of them advertises that issuer. Tokens are always issued with the primary issuer.",
+				},
 				"allowed_client_ids": {
 					Type:        framework.TypeCommaStringSlice,
 					Description: "The client IDs that are permitted to use the provider",
@@ -335,6 +848,46 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeCommaStringSlice,
 					Description: "The scopes supported for requesting on the provider",
 				},
+				"default_scopes": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "The scopes granted in addition to the requested scopes on every authorization request. Each must be one of the scopes supported by the provider.",
+				},
+				"allowed_redirect_hosts": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "The glob patterns of the hosts that the redirect URIs of clients allowed to use the provider may use. If empty, any host is allowed.",
+				},
+				"metadata_signing_key": {
+					Type:        framework.TypeString,
+					Description: "The name of the key used to sign the metadata of the provider's OpenID configuration, which is included as signed_metadata. Signed metadata is not included if not set.",
+				},
+				"maintenance": {
+					Type:        framework.TypeBool,
+					Description: "If true, the authorization and token endpoints refuse new logins with the temporarily_unavailable error. The discovery document, keys and userinfo endpoint keep working.",
+				},
+				"maintenance_message": {
+					Type:        framework.TypeString,
+					Description: "The message returned by the authorization and token endpoints while the provider is in maintenance. If empty, a default message is returned.",
+				},
+				"display_name": {
+					Type:        framework.TypeString,
+					Description: "The name of the provider shown to end-users, which is published as display_name in the discovery document.",
+				},
+				"service_documentation": {
+					Type:        framework.TypeString,
+					Description: "The https URL of the documentation of the provider for developers, which is published in the discovery document.",
+				},
+				"op_policy_uri": {
+					Type:        framework.TypeString,
+					Description: "The https URL of the policy of the provider about the use of end-users' data, which is published in the discovery document.",
+				},
+				"op_tos_uri": {
+					Type:        framework.TypeString,
+					Description: "The https URL of the terms of service of the provider, which is published in the discovery document.",
+				},
+				"allow_cross_client_introspection": {
+					Type:        framework.TypeBool,
+					Description: "If true, clients of the provider may introspect the access tokens issued to other clients of the provider. By default, clients may only introspect their own access tokens.",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.UpdateOperation: &framework.PathOperation{
@@ -349,6 +902,9 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 				logical.DeleteOperation: &framework.PathOperation{
 					Callback: i.pathOIDCDeleteProvider,
 				},
+				logical.PatchOperation: &framework.PathOperation{
+					Callback: i.oidcPatchCallback(i.pathOIDCCreateUpdateProvider, i.pathOIDCReadProvider),
+				},
 			},
 			ExistenceCheck:  i.pathOIDCProviderExistenceCheck,
 			HelpSynopsis:    "CRUD operations for OIDC providers.",
@@ -374,7 +930,95 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
-					Callback: i.pathOIDCProviderDiscovery,
+					Callback:    i.withOIDCProviderTelemetry("discovery", i.pathOIDCProviderDiscovery, nil),
+					Summary:     "Read the OpenID Provider configuration of the provider.",
+					OperationID: "readOIDCProviderOpenIDConfiguration",
+					Responses: oidcProviderResponses(map[string]*framework.FieldSchema{
+						"issuer": {
+							Type:        framework.TypeString,
+							Description: "The issuer of the provider.",
+							Required:    true,
+						},
+						"jwks_uri": {
+							Type:        framework.TypeString,
+							Description: "The URL of the JSON Web Key Set of the provider.",
+							Required:    true,
+						},
+						"authorization_endpoint": {
+							Type:        framework.TypeString,
+							Description: "The URL of the authorization endpoint.",
+							Required:    true,
+						},
+						"token_endpoint": {
+							Type:        framework.TypeString,
+							Description: "The URL of the token endpoint.",
+							Required:    true,
+						},
+						"userinfo_endpoint": {
+							Type:        framework.TypeString,
+							Description: "The URL of the UserInfo endpoint.",
+							Required:    true,
+						},
+						"request_uri_parameter_supported": {
+							Type:        framework.TypeBool,
+							Description: "Whether the request_uri parameter is supported.",
+						},
+						"id_token_signing_alg_values_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The algorithms used to sign ID tokens.",
+							Required:    true,
+						},
+						"userinfo_signing_alg_values_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The algorithms used to sign the UserInfo responses of the clients that set userinfo_signed_response_alg.",
+						},
+						"response_types_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The supported response types.",
+							Required:    true,
+						},
+						"scopes_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The supported scopes.",
+						},
+						"claims_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The claims set by the provider and the top-level claims of the templates of the supported scopes.",
+						},
+						"subject_types_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The supported subject identifier types.",
+							Required:    true,
+						},
+						"grant_types_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The supported grant types.",
+						},
+						"token_endpoint_auth_methods_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The client authentication methods supported by the token endpoint.",
+						},
+						"token_endpoint_auth_signing_alg_values_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The signing algorithms supported for the client assertions of the 'private_key_jwt' authentication method.",
+						},
+						"introspection_endpoint": {
+							Type:        framework.TypeString,
+							Description: "The URL of the token introspection endpoint.",
+						},
+						"introspection_endpoint_auth_methods_supported": {
+							Type:        framework.TypeStringSlice,
+							Description: "The client authentication methods supported by the token introspection endpoint.",
+						},
+						"end_session_endpoint": {
+							Type:        framework.TypeString,
+							Description: "The URL of the UI page that ends the session of an end-user with a client.",
+						},
+						"device_authorization_endpoint": {
+							Type:        framework.TypeString,
+							Description: "The URL of the device authorization endpoint.",
+						},
+					}, nil),
 				},
 			},
 			HelpSynopsis:    "Query OIDC configurations",
@@ -390,7 +1034,16 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
-					Callback: i.pathOIDCReadProviderPublicKeys,
+					Callback:    i.withOIDCProviderTelemetry("keys", i.pathOIDCReadProviderPublicKeys, nil),
+					Summary:     "Read the JSON Web Key Set of the provider.",
+					OperationID: "readOIDCProviderKeys",
+					Responses: oidcProviderResponses(map[string]*framework.FieldSchema{
+						"keys": {
+							Type:        framework.TypeSlice,
+							Description: "The public JSON Web Keys used to sign the tokens issued to the clients of the provider.",
+							Required:    true,
+						},
+					}, nil),
 				},
 			},
 			HelpSynopsis:    "Retrieve public keys",
@@ -436,6 +1089,10 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeInt,
 					Description: "The allowable elapsed time in seconds since the last time the end-user was actively authenticated.",
 				},
+				"prompt": {
+					Type:        framework.TypeString,
+					Description: "A space-delimited list of the values 'none', 'login', 'consent' and 'select_account' that specify whether the end-user is prompted for re-authentication and consent. 'none' must not be combined with other values.",
+				},
 				"code_challenge": {
 					Type:        framework.TypeString,
 					Description: "The code challenge derived from the code verifier.",
@@ -444,16 +1101,28 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "The method that was used to derive the code challenge. The following methods are supported: 'S256', 'plain'. Defaults to 'plain'.",
 					Default:     codeChallengeMethodPlain,
+					AllowedValues: []interface{}{
+						codeChallengeMethodPlain,
+						codeChallengeMethodS256,
+					},
 				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
-					Callback:                    i.pathOIDCAuthorize,
+					Callback:                    i.withOIDCProviderTelemetry("authorize", i.pathOIDCAuthorize, authorizeRequestClientID),
+					Summary:                     "Authorize a client with the request parameters in the query string.",
+					OperationID:                 "readOIDCProviderAuthorize",
+					QueryParameters:             true,
+					Responses:                   authorizeResponses,
 					ForwardPerformanceStandby:   true,
 					ForwardPerformanceSecondary: false,
 				},
 				logical.UpdateOperation: &framework.PathOperation{
-					Callback:                    i.pathOIDCAuthorize,
+					Callback:                    i.withOIDCProviderTelemetry("authorize", i.pathOIDCAuthorize, authorizeRequestClientID),
+					Summary:                     "Authorize a client with the request parameters in the request body.",
+					OperationID:                 "oidcProviderAuthorize",
+					RequestMediaTypes:           []string{"application/x-www-form-urlencoded", "application/json"},
+					Responses:                   authorizeResponses,
 					ForwardPerformanceStandby:   true,
 					ForwardPerformanceSecondary: false,
 				},
@@ -470,26 +1139,47 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 				},
 				"code": {
 					Type:        framework.TypeString,
-					Description: "The authorization code received from the provider's authorization endpoint.",
-					Required:    true,
+					Description: "The authorization code received from the provider's authorization endpoint. Required for the 'authorization_code' grant type.",
 				},
 				"grant_type": {
-					Type:        framework.TypeString,
-					Description: "The authorization grant type. The following grant types are supported: 'authorization_code'.",
-					Required:    true,
+					Type:          framework.TypeString,
+					Description:   "The authorization grant type. The following grant types are supported: 'authorization_code', 'password', 'refresh_token', 'urn:ietf:params:oauth:grant-type:device_code', 'client_credentials'. The client must allow the grant type with its grant_types.",
+					Required:      true,
+					AllowedValues: []interface{}{"authorization_code", "password", "refresh_token", deviceCodeGrantType, clientCredentialsGrantType},
 				},
 				"redirect_uri": {
 					Type:        framework.TypeString,
-					Description: "The callback location where the authentication response was sent.",
-					Required:    true,
+					Description: "The callback location where the authentication response was sent. Required for the 'authorization_code' grant type.",
 				},
 				"code_verifier": {
 					Type:        framework.TypeString,
 					Description: "The code verifier associated with the authorization code.",
 				},
+				"username": {
+					Type:        framework.TypeString,
+					Description: "The username of the resource owner. Required for the 'password' grant type.",
+				},
+				"password": {
+					Type:        framework.TypeString,
+					Description: "The password of the resource owner. Required for the 'password' grant type.",
+				},
+				"scope": {
+					Type:        framework.TypeString,
+					Description: "A space-delimited, case-sensitive list of scopes to be requested with the 'password' grant type, which requires the 'openid' scope, or the 'client_credentials' grant type, which only issues an ID token with the 'openid' scope, or to narrow the scopes of the 'refresh_token' grant type.",
+				},
+				"refresh_token": {
+					Type:        framework.TypeString,
+					Description: "The refresh token issued to the client. Required for the 'refresh_token' grant type.",
+				},
+				"device_code": {
+					Type:        framework.TypeString,
+					Description: "The device code received from the provider's device authorization endpoint. Required for the 'urn:ietf:params:oauth:grant-type:device_code' grant type.",
+				},
 				// For confidential clients, the client_id and client_secret are provided to
 				// the token endpoint via the 'client_secret_basic' authentication method, which
-				// uses the HTTP Basic authentication scheme. See the OIDC spec for details at:
+				// uses the HTTP Basic authentication scheme, or a client assertion signed by
+				// the client is provided via the 'private_key_jwt' authentication method. See
+				// the OIDC spec for details at:
 				// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
 
 				// For public clients, the client_id is required and a client_secret does
@@ -500,13 +1190,59 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "The ID of the requesting client.",
 				},
+				"client_assertion_type": {
+					Type:        framework.TypeString,
+					Description: "The type of the client assertion, which must be 'urn:ietf:params:oauth:client-assertion-type:jwt-bearer'. Required for the 'private_key_jwt' authentication method.",
+				},
+				"client_assertion": {
+					Type:        framework.TypeString,
+					Description: "A JWT signed by one of the registered keys of the client. Required for the 'private_key_jwt' authentication method.",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.UpdateOperation: &framework.PathOperation{
-					Callback:                    i.pathOIDCToken,
+					Callback:          i.withOIDCProviderTelemetry("token", i.withOIDCClientCORS(i.pathOIDCToken, tokenRequestClientID), tokenRequestClientID),
+					Summary:           "Exchange an authorization code for an ID token and an access token.",
+					Description:       "Confidential clients authenticate with their client ID and secret using the HTTP Basic authentication scheme, or with a client assertion if their token_endpoint_auth_method is 'private_key_jwt'. Public clients pass their client ID in the request body.",
+					OperationID:       "oidcProviderToken",
+					RequestMediaTypes: []string{"application/x-www-form-urlencoded", "application/json"},
+					Responses: oidcProviderResponses(map[string]*framework.FieldSchema{
+						"access_token": {
+							Type:        framework.TypeString,
+							Description: "The access token, to be used at the UserInfo endpoint.",
+							Required:    true,
+						},
+						"id_token": {
+							Type:        framework.TypeString,
+							Description: "The signed ID token.",
+							Required:    true,
+						},
+						"token_type": {
+							Type:        framework.TypeString,
+							Description: "The type of the access token, which is always 'Bearer'.",
+							Required:    true,
+						},
+						"expires_in": {
+							Type:        framework.TypeInt64,
+							Description: "The lifetime of the access token in seconds.",
+							Required:    true,
+						},
+						"scope": {
+							Type:        framework.TypeString,
+							Description: "The space-delimited scopes granted to the client, including the default scopes of the provider.",
+						},
+						"refresh_token": {
+							Type:        framework.TypeString,
+							Description: "The refresh token, issued for the 'offline_access' scope to clients with a refresh_token_ttl.",
+						},
+					}, nil, http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError),
 					ForwardPerformanceStandby:   true,
 					ForwardPerformanceSecondary: false,
 				},
+				logical.OptionsOperation: &framework.PathOperation{
+					Callback: i.pathOIDCPreflight("POST"),
+					Summary:  "Answer CORS preflight requests of the allowed origins of the clients of the provider.",
+				},
 			},
 			HelpSynopsis:    "Provides the OIDC Token Endpoint.",
 			HelpDescription: "The OIDC Token Endpoint allows a client to exchange its Authorization Grant for an Access Token and ID Token.",
@@ -521,10 +1257,20 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
-					Callback: i.pathOIDCUserInfo,
+					Callback:    i.withOIDCProviderTelemetry("userinfo", i.withOIDCClientCORS(i.pathOIDCUserInfo, i.userInfoRequestClientID), i.userInfoRequestClientID),
+					Summary:     "Read the claims about the end-user authorized by the bearer access token.",
+					OperationID: "readOIDCProviderUserInfo",
+					Responses:   userInfoResponses,
 				},
 				logical.UpdateOperation: &framework.PathOperation{
-					Callback: i.pathOIDCUserInfo,
+					Callback:    i.withOIDCProviderTelemetry("userinfo", i.withOIDCClientCORS(i.pathOIDCUserInfo, i.userInfoRequestClientID), i.userInfoRequestClientID),
+					Summary:     "Read the claims about the end-user authorized by the bearer access token.",
+					OperationID: "oidcProviderUserInfo",
+					Responses:   userInfoResponses,
+				},
+				logical.OptionsOperation: &framework.PathOperation{
+					Callback: i.pathOIDCPreflight("GET,POST"),
+					Summary:  "Answer CORS preflight requests of the allowed origins of the clients of the provider.",
 				},
 			},
 			HelpSynopsis:    "Provides the OIDC UserInfo Endpoint.",
@@ -536,26 +1282,20 @@ func oidcProviderPaths(i *IdentityStore) []*framework.Path {
 // clientsReferencingTargetAssignmentName returns a map of client names to
 // clients referencing targetAssignmentName.
 func (i *IdentityStore) clientsReferencingTargetAssignmentName(ctx context.Context, req *logical.Request, targetAssignmentName string) (map[string]client, error) {
-	clientNames, err := req.Storage.List(ctx, clientPath)
+	entries, err := oidcClientStore.entries(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
-	var tempClient client
 	clients := make(map[string]client)
-	for _, clientName := range clientNames {
-		entry, err := req.Storage.Get(ctx, clientPath+clientName)
-		if err != nil {
+	for _, entry := range entries {
+		var tempClient client
+		if err := entry.DecodeJSON(&tempClient); err != nil {
 			return nil, err
 		}
-		if entry != nil {
-			if err := entry.DecodeJSON(&tempClient); err != nil {
-				return nil, err
-			}
-			for _, a := range tempClient.Assignments {
-				if a == targetAssignmentName {
-					clients[clientName] = tempClient
-				}
+		for _, a := range tempClient.Assignments {
+			if a == targetAssignmentName {
+				clients[entry.Name] = tempClient
 			}
 		}
 	}
@@ -582,27 +1322,46 @@ func (i *IdentityStore) clientNamesReferencingTargetAssignmentName(ctx context.C
 // clientsReferencingTargetKeyName returns a map of client names to
 // clients referencing targetKeyName.
 func (i *IdentityStore) clientsReferencingTargetKeyName(ctx context.Context, req *logical.Request, targetKeyName string) (map[string]client, error) {
-	clientNames, err := req.Storage.List(ctx, clientPath)
+	entries, err := oidcClientStore.entries(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
-	var tempClient client
 	clients := make(map[string]client)
-	for _, clientName := range clientNames {
-		entry, err := req.Storage.Get(ctx, clientPath+clientName)
-		if err != nil {
+	for _, entry := range entries {
+		var tempClient client
+		if err := entry.DecodeJSON(&tempClient); err != nil {
 			return nil, err
 		}
-		if entry != nil {
-			if err := entry.DecodeJSON(&tempClient); err != nil {
-				return nil, err
-			}
-			if tempClient.Key == targetKeyName {
-				clients[clientName] = tempClient
-			}
+		if tempClient.Key == targetKeyName {
+			clients[entry.Name] = tempClient
+		}
+	}
+
+	return clients, nil
+}
+
+// clientsMigratedFromTargetKeyName returns the clients migrated from
+// targetKeyName whose tokens may still be verified by it, sorted by name.
+func (i *IdentityStore) clientsMigratedFromTargetKeyName(ctx context.Context, req *logical.Request, targetKeyName string) ([]client, error) {
+	entries, err := oidcClientStore.entries(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []client
+	for _, entry := range entries {
+		var tempClient client
+		if err := entry.DecodeJSON(&tempClient); err != nil {
+			return nil, err
+		}
+		if tempClient.PreviousKey == targetKeyName && tempClient.previousKeyVerifies() {
+			clients = append(clients, tempClient)
 		}
 	}
+	sort.Slice(clients, func(a, b int) bool {
+		return clients[a].Name < clients[b].Name
+	})
 
 	return clients, nil
 }
@@ -623,93 +1382,285 @@ func (i *IdentityStore) clientNamesReferencingTargetKeyName(ctx context.Context,
 	return names, nil
 }
 
-// providersReferencingTargetScopeName returns a list of provider names referencing targetScopeName.
+// providerNamesReferencingTargetKeyName returns the sorted names of the
+// providers that sign their metadata with targetKeyName.
 // Not threadsafe. To be called with lock already held.
-func (i *IdentityStore) providersReferencingTargetScopeName(ctx context.Context, req *logical.Request, targetScopeName string) ([]string, error) {
-	providerNames, err := req.Storage.List(ctx, providerPath)
+func (i *IdentityStore) providerNamesReferencingTargetKeyName(ctx context.Context, req *logical.Request, targetKeyName string) ([]string, error) {
+	names, err := req.Storage.List(ctx, providerPath)
 	if err != nil {
 		return nil, err
 	}
 
-	var tempProvider provider
-	var providers []string
-	for _, providerName := range providerNames {
-		entry, err := req.Storage.Get(ctx, providerPath+providerName)
+	var providerNames []string
+	for _, name := range names {
+		provider, err := i.getOIDCProvider(ctx, req.Storage, name)
 		if err != nil {
 			return nil, err
 		}
-		if entry != nil {
-			if err := entry.DecodeJSON(&tempProvider); err != nil {
-				return nil, err
-			}
-			for _, a := range tempProvider.ScopesSupported {
-				if a == targetScopeName {
-					providers = append(providers, providerName)
-				}
-			}
+		if provider != nil && provider.MetadataSigningKey == targetKeyName {
+			providerNames = append(providerNames, name)
 		}
 	}
+	sort.Strings(providerNames)
+	return providerNames, nil
+}
 
-	return providers, nil
+// providersReferencingTargetScopeName returns a list of provider names referencing targetScopeName.
+// Not threadsafe. To be called with lock already held.
+func (i *IdentityStore) providersReferencingTargetScopeName(ctx context.Context, req *logical.Request, targetScopeName string) ([]string, error) {
+	providersByScope, err := i.providerNamesByScope(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return providersByScope[targetScopeName], nil
 }
 
-// pathOIDCCreateUpdateAssignment is used to create a new assignment or update an existing one
-func (i *IdentityStore) pathOIDCCreateUpdateAssignment(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	name := d.Get("name").(string)
+// providerNamesByScope returns the sorted names of the providers of the
+// namespace that support each scope. The index is cached with the documents
+// of providers, so it is rebuilt from storage after providers are written or
+// invalidated rather than on every call. The returned map must not be
+// modified.
+func (i *IdentityStore) providerNamesByScope(ctx context.Context, s logical.Storage) (map[string][]string, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	if name == allowAllAssignmentName {
-		return logical.ErrorResponse("modification of assignment %q not allowed",
-			allowAllAssignmentName), nil
+	v, ok, err := i.oidcCache.Get(ns, "providers_by_scope")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return v.(map[string][]string), nil
 	}
 
-	i.oidcLock.Lock()
-	defer i.oidcLock.Unlock()
+	flushes := i.oidcCache.flushCount()
+	providerNames, err := s.List(ctx, providerPath)
+	if err != nil {
+		return nil, err
+	}
 
-	var assignment assignment
-	if req.Operation == logical.UpdateOperation {
-		entry, err := req.Storage.Get(ctx, assignmentPath+name)
+	// Providers are listed in sorted order, so the names of each scope are
+	// sorted too
+	sort.Strings(providerNames)
+	providersByScope := make(map[string][]string)
+	for _, providerName := range providerNames {
+		entry, err := s.Get(ctx, providerPath+providerName)
 		if err != nil {
 			return nil, err
 		}
-		if entry != nil {
-			if err := entry.DecodeJSON(&assignment); err != nil {
-				return nil, err
-			}
+		if entry == nil {
+			continue
 		}
-	}
 
-	if entitiesRaw, ok := d.GetOk("entity_ids"); ok {
-		assignment.EntityIDs = entitiesRaw.([]string)
-	} else if req.Operation == logical.CreateOperation {
-		assignment.EntityIDs = d.Get("entity_ids").([]string)
+		var p provider
+		if err := entry.DecodeJSON(&p); err != nil {
+			return nil, err
+		}
+		for _, scopeName := range p.ScopesSupported {
+			providersByScope[scopeName] = append(providersByScope[scopeName], providerName)
+		}
 	}
 
-	if groupsRaw, ok := d.GetOk("group_ids"); ok {
-		assignment.GroupIDs = groupsRaw.([]string)
-	} else if req.Operation == logical.CreateOperation {
-		assignment.GroupIDs = d.Get("group_ids").([]string)
+	if err := i.oidcCache.SetDefaultIfNotFlushed(flushes, ns, "providers_by_scope", providersByScope); err != nil {
+		return nil, err
 	}
 
-	// remove duplicates and lowercase entities and groups
-	assignment.EntityIDs = strutil.RemoveDuplicates(assignment.EntityIDs, true)
-	assignment.GroupIDs = strutil.RemoveDuplicates(assignment.GroupIDs, true)
+	return providersByScope, nil
+}
 
-	// store assignment
-	entry, err := logical.StorageEntryJSON(assignmentPath+name, assignment)
-	if err != nil {
-		return nil, err
+// referencedBy returns the referenced_by section of the read response of an
+// object referenced by the named objects of the given kind, e.g. the clients
+// of a key. At most oidcReferencedByLimit names are returned along with the
+// total count.
+func referencedBy(kind string, names []string) map[string]interface{} {
+	count := len(names)
+	if count > oidcReferencedByLimit {
+		names = names[:oidcReferencedByLimit]
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
-		return nil, err
+	return map[string]interface{}{
+		kind + "s":      append(make([]string, 0, len(names)), names...),
+		kind + "_count": count,
 	}
-
-	return nil, nil
 }
 
-// pathOIDCListAssignment is used to list assignments
-func (i *IdentityStore) pathOIDCListAssignment(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	assignments, err := req.Storage.List(ctx, assignmentPath)
+// oidcPatchCallback returns the callback of the patch operation of an OIDC
+// configuration object, which applies the JSON merge patch of the request to
+// the object with its create/update callback and returns the resulting object
+// with its read callback. Fields that are not in the patch are preserved, and
+// fields set to null are reset to their default value. The given immutable
+// fields can only be patched with their current value.
+func (i *IdentityStore) oidcPatchCallback(update, read framework.OperationFunc, immutable ...string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		current, err := read(ctx, req, d)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, logical.CodedError(http.StatusNotFound, fmt.Sprintf("no %s to patch at %q", strings.Split(req.Path, "/")[1], req.Path))
+		}
+
+		raw := make(map[string]interface{}, len(d.Raw))
+		for k, v := range d.Raw {
+			raw[k] = v
+		}
+
+		for _, field := range immutable {
+			v, ok := raw[field]
+			if !ok {
+				continue
+			}
+			if v != current.Data[field] {
+				return logical.ErrorResponse("%s is immutable and cannot be patched", field), nil
+			}
+			delete(raw, field)
+		}
+
+		for k, v := range raw {
+			schema, ok := d.Schema[k]
+			if v == nil && ok {
+				raw[k] = schema.DefaultOrZero()
+			}
+		}
+
+		resp, err := update(ctx, req, &framework.FieldData{
+			Raw:    raw,
+			Schema: d.Schema,
+		})
+		if err != nil || resp.IsError() {
+			return resp, err
+		}
+
+		patched, err := read(ctx, req, d)
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil && patched != nil {
+			patched.Warnings = append(patched.Warnings, resp.Warnings...)
+		}
+		return patched, nil
+	}
+}
+
+// pathOIDCCreateUpdateAssignment is used to create a new assignment or update an existing one
+func (i *IdentityStore) pathOIDCCreateUpdateAssignment(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	if name == allowAllAssignmentName {
+		return logical.ErrorResponse("modification of assignment %q not allowed",
+			allowAllAssignmentName), nil
+	}
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	var assignment assignment
+	if req.Operation == logical.UpdateOperation || req.Operation == logical.PatchOperation {
+		entry, err := oidcAssignmentStore.get(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			if err := entry.DecodeJSON(&assignment); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if entitiesRaw, ok := d.GetOk("entity_ids"); ok {
+		assignment.EntityIDs = entitiesRaw.([]string)
+	} else if req.Operation == logical.CreateOperation {
+		assignment.EntityIDs = d.Get("entity_ids").([]string)
+	}
+
+	if groupsRaw, ok := d.GetOk("group_ids"); ok {
+		assignment.GroupIDs = groupsRaw.([]string)
+	} else if req.Operation == logical.CreateOperation {
+		assignment.GroupIDs = d.Get("group_ids").([]string)
+	}
+
+	// remove duplicates and lowercase entities and groups
+	assignment.EntityIDs = strutil.RemoveDuplicates(assignment.EntityIDs, true)
+	assignment.GroupIDs = strutil.RemoveDuplicates(assignment.GroupIDs, true)
+
+	for _, field := range []string{"not_before", "not_after"} {
+		raw, ok := d.GetOk(field)
+		if !ok {
+			continue
+		}
+		t, err := parseOIDCTime(field, raw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if field == "not_before" {
+			assignment.NotBefore = t
+		} else {
+			assignment.NotAfter = t
+		}
+	}
+	if err := assignment.assignmentTimeBounds.validate("the assignment"); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// The bounds of entities and groups that were removed from the
+	// assignment are dropped along with them
+	pruneTimeBounds(assignment.EntityTimeBounds, assignment.EntityIDs)
+	pruneTimeBounds(assignment.GroupTimeBounds, assignment.GroupIDs)
+	if raw, ok := d.GetOk("entity_time_bounds"); ok {
+		bounds, err := parseAssignmentTimeBounds("entity_time_bounds", raw.(map[string]interface{}), assignment.EntityIDs)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		assignment.EntityTimeBounds = bounds
+	}
+	if raw, ok := d.GetOk("group_time_bounds"); ok {
+		bounds, err := parseAssignmentTimeBounds("group_time_bounds", raw.(map[string]interface{}), assignment.GroupIDs)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		assignment.GroupTimeBounds = bounds
+	}
+
+	// store assignment
+	if _, err := oidcAssignmentStore.put(ctx, req.Storage, name, assignment); err != nil {
+		return nil, err
+	}
+
+	// Assignments are evaluated by name, so every result may have changed
+	i.oidcAssignmentCache.purge()
+
+	// Bounds in the past are accepted, but are likely to be a mistake
+	var resp *logical.Response
+	now := time.Now()
+	if assignment.expired(now) {
+		resp = &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("not_after %s is in the past, so the assignment authorizes no entities",
+			formatAssignmentTime(assignment.NotAfter)))
+	}
+	for _, expired := range []struct {
+		field string
+		ids   []string
+	}{
+		{"entity_time_bounds", expiredTimeBounds(assignment.EntityTimeBounds, now)},
+		{"group_time_bounds", expiredTimeBounds(assignment.GroupTimeBounds, now)},
+	} {
+		if len(expired.ids) == 0 {
+			continue
+		}
+		if resp == nil {
+			resp = &logical.Response{}
+		}
+		resp.AddWarning(fmt.Sprintf("the not_after of %s in %s is in the past", strings.Join(expired.ids, ", "), expired.field))
+	}
+
+	return resp, nil
+}
+
+// pathOIDCListAssignment is used to list assignments
+func (i *IdentityStore) pathOIDCListAssignment(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	assignments, err := oidcAssignmentStore.list(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
@@ -728,16 +1679,90 @@ func (i *IdentityStore) pathOIDCReadAssignment(ctx context.Context, req *logical
 		return nil, nil
 	}
 
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"group_ids":  assignment.GroupIDs,
-			"entity_ids": assignment.EntityIDs,
+			"group_ids":          assignment.GroupIDs,
+			"entity_ids":         assignment.EntityIDs,
+			"not_before":         formatAssignmentTime(assignment.NotBefore),
+			"not_after":          formatAssignmentTime(assignment.NotAfter),
+			"entity_time_bounds": timeBoundsResponseData(assignment.EntityTimeBounds),
+			"group_time_bounds":  timeBoundsResponseData(assignment.GroupTimeBounds),
 		},
-	}, nil
+	}
+
+	if d.Get("resolve").(bool) {
+		entities, groups, truncated, err := i.resolveIdentityIDs(assignment.EntityIDs, assignment.GroupIDs, oidcResolveLimit)
+		if err != nil {
+			return nil, err
+		}
+		resp.Data["entities"] = entities
+		resp.Data["groups"] = groups
+		resp.Data["entity_count"] = len(assignment.EntityIDs)
+		resp.Data["group_count"] = len(assignment.GroupIDs)
+		resp.Data["truncated"] = truncated
+	}
+
+	return resp, nil
+}
+
+// resolveIdentityIDs returns the current names of the given entities and
+// groups, in the order of the IDs. IDs that no longer resolve to an entity or
+// group are returned with resolved set to false and an empty name. At most
+// limit entities and limit groups are resolved, and truncated is set if IDs
+// were left out.
+func (i *IdentityStore) resolveIdentityIDs(entityIDs, groupIDs []string, limit int) ([]map[string]interface{}, []map[string]interface{}, bool, error) {
+	txn := i.db.Txn(false)
+	defer txn.Abort()
+
+	truncated := false
+	if len(entityIDs) > limit {
+		entityIDs = entityIDs[:limit]
+		truncated = true
+	}
+	if len(groupIDs) > limit {
+		groupIDs = groupIDs[:limit]
+		truncated = true
+	}
+
+	entities := make([]map[string]interface{}, 0, len(entityIDs))
+	for _, id := range entityIDs {
+		entity, err := i.MemDBEntityByIDInTxn(txn, id, false)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		resolved := map[string]interface{}{
+			"id":       id,
+			"name":     "",
+			"resolved": entity != nil,
+		}
+		if entity != nil {
+			resolved["name"] = entity.Name
+		}
+		entities = append(entities, resolved)
+	}
+
+	groups := make([]map[string]interface{}, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		group, err := i.MemDBGroupByIDInTxn(txn, id, false)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		resolved := map[string]interface{}{
+			"id":       id,
+			"name":     "",
+			"resolved": group != nil,
+		}
+		if group != nil {
+			resolved["name"] = group.Name
+		}
+		groups = append(groups, resolved)
+	}
+
+	return entities, groups, truncated, nil
 }
 
 func (i *IdentityStore) getOIDCAssignment(ctx context.Context, s logical.Storage, name string) (*assignment, error) {
-	entry, err := s.Get(ctx, assignmentPath+name)
+	entry, err := oidcAssignmentStore.get(ctx, s, name)
 	if err != nil {
 		return nil, err
 	}
@@ -776,17 +1801,20 @@ func (i *IdentityStore) pathOIDCDeleteAssignment(ctx context.Context, req *logic
 		return logical.ErrorResponse(errorMessage), logical.ErrInvalidRequest
 	}
 
-	err = req.Storage.Delete(ctx, assignmentPath+name)
+	err = oidcAssignmentStore.delete(ctx, req.Storage, name)
 	if err != nil {
 		return nil, err
 	}
+
+	i.oidcAssignmentCache.purge()
+
 	return nil, nil
 }
 
 func (i *IdentityStore) pathOIDCAssignmentExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
 	name := d.Get("name").(string)
 
-	entry, err := req.Storage.Get(ctx, assignmentPath+name)
+	entry, err := oidcAssignmentStore.get(ctx, req.Storage, name)
 	if err != nil {
 		return false, err
 	}
@@ -805,8 +1833,8 @@ func (i *IdentityStore) pathOIDCCreateUpdateScope(ctx context.Context, req *logi
 	defer i.oidcLock.Unlock()
 
 	var scope scope
-	if req.Operation == logical.UpdateOperation {
-		entry, err := req.Storage.Get(ctx, scopePath+name)
+	if req.Operation == logical.UpdateOperation || req.Operation == logical.PatchOperation {
+		entry, err := oidcScopeStore.get(ctx, req.Storage, name)
 		if err != nil {
 			return nil, err
 		}
@@ -834,9 +1862,9 @@ func (i *IdentityStore) pathOIDCCreateUpdateScope(ctx context.Context, req *logi
 		scope.Template = string(decoded)
 	}
 
-	// Validate that template can be parsed and results in valid JSON
+	// Validate that template can be parsed and results in a valid JSON object
 	if scope.Template != "" {
-		_, populatedTemplate, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+		_, _, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
 			Mode:   identitytpl.JSONTemplating,
 			String: scope.Template,
 			Entity: new(logical.Entity),
@@ -846,8 +1874,8 @@ func (i *IdentityStore) pathOIDCCreateUpdateScope(ctx context.Context, req *logi
 			return logical.ErrorResponse("error parsing template: %s", err.Error()), nil
 		}
 
-		var tmp map[string]interface{}
-		if err := json.Unmarshal([]byte(populatedTemplate), &tmp); err != nil {
+		tmp, err := validateScopeTemplateJSON(scope.Template)
+		if err != nil {
 			return logical.ErrorResponse("error parsing template JSON: %s", err.Error()), nil
 		}
 
@@ -859,12 +1887,11 @@ func (i *IdentityStore) pathOIDCCreateUpdateScope(ctx context.Context, req *logi
 		}
 	}
 	// store scope
-	entry, err := logical.StorageEntryJSON(scopePath+name, scope)
-	if err != nil {
+	if _, err := oidcScopeStore.put(ctx, req.Storage, name, scope); err != nil {
 		return nil, err
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	if err := i.flushOIDCProviderDocuments(ctx); err != nil {
 		return nil, err
 	}
 
@@ -873,7 +1900,7 @@ func (i *IdentityStore) pathOIDCCreateUpdateScope(ctx context.Context, req *logi
 
 // pathOIDCListScope is used to list scopes
 func (i *IdentityStore) pathOIDCListScope(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	scopes, err := req.Storage.List(ctx, scopePath)
+	scopes, err := oidcScopeStore.list(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
@@ -892,16 +1919,97 @@ func (i *IdentityStore) pathOIDCReadScope(ctx context.Context, req *logical.Requ
 		return nil, nil
 	}
 
+	providersByScope, err := i.providerNamesByScope(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"template":    scope.Template,
-			"description": scope.Description,
+			"template":      scope.Template,
+			"description":   scope.Description,
+			"referenced_by": referencedBy("provider", providersByScope[name]),
 		},
 	}, nil
 }
 
+// pathOIDCRenderScope renders the template of a scope for an entity, as the
+// token and userinfo endpoints do for the given client and provider.
+func (i *IdentityStore) pathOIDCRenderScope(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := d.Get("name").(string)
+	scope, err := i.getOIDCScope(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if scope == nil {
+		return logical.ErrorResponse("scope %q not found", name), nil
+	}
+
+	entityID := d.Get("entity_id").(string)
+	if entityID == "" {
+		return logical.ErrorResponse("missing entity_id"), nil
+	}
+	entity, err := i.MemDBEntityByID(entityID, false)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.NamespaceID != ns.ID {
+		return logical.ErrorResponse("entity %q not found", entityID), nil
+	}
+
+	params := scopeTemplateParams{
+		clientID: d.Get("client_id").(string),
+		provider: d.Get("provider").(string),
+	}
+	if params.clientID != "" {
+		client, err := i.clientByID(params.clientID)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil || client.NamespaceID != ns.ID {
+			return logical.ErrorResponse("client %q not found", params.clientID), nil
+		}
+	}
+	if params.provider != "" {
+		provider, err := i.getOIDCProvider(ctx, req.Storage, params.provider)
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil {
+			return logical.ErrorResponse("provider %q not found", params.provider), nil
+		}
+	}
+
+	entity, groups, err := i.scopeTemplateIdentity(entity)
+	if err != nil {
+		return nil, err
+	}
+	populated := i.populateScopeTemplate(ns, params, identity.ToSDKEntity(entity), identity.ToSDKGroups(groups), name, scope.Template)
+
+	claims := populated.parsed
+	if claims == nil {
+		claims = make(map[string]interface{})
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"template": populated.populated,
+			"claims":   claims,
+		},
+	}
+	if populated.warning != "" {
+		resp.AddWarning(populated.warning)
+	}
+	return resp, nil
+}
+
 func (i *IdentityStore) getOIDCScope(ctx context.Context, s logical.Storage, name string) (*scope, error) {
-	entry, err := s.Get(ctx, scopePath+name)
+	entry, err := oidcScopeStore.get(ctx, s, name)
 	if err != nil {
 		return nil, err
 	}
@@ -934,18 +2042,22 @@ func (i *IdentityStore) pathOIDCDeleteScope(ctx context.Context, req *logical.Re
 			name, strings.Join(providerNames, ", "))
 		return logical.ErrorResponse(errorMessage), logical.ErrInvalidRequest
 	}
-	err = req.Storage.Delete(ctx, scopePath+name)
+	err = oidcScopeStore.delete(ctx, req.Storage, name)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
 func (i *IdentityStore) pathOIDCScopeExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
 	name := d.Get("name").(string)
 
-	entry, err := req.Storage.Get(ctx, scopePath+name)
+	entry, err := oidcScopeStore.get(ctx, req.Storage, name)
 	if err != nil {
 		return false, err
 	}
@@ -969,8 +2081,9 @@ func (i *IdentityStore) pathOIDCCreateUpdateClient(ctx context.Context, req *log
 		Name:        name,
 		NamespaceID: ns.ID,
 	}
-	if req.Operation == logical.UpdateOperation {
-		entry, err := req.Storage.Get(ctx, clientPath+name)
+	exists := false
+	if req.Operation == logical.UpdateOperation || req.Operation == logical.PatchOperation {
+		entry, err := oidcClientStore.get(ctx, req.Storage, name)
 		if err != nil {
 			return nil, err
 		}
@@ -978,28 +2091,117 @@ func (i *IdentityStore) pathOIDCCreateUpdateClient(ctx context.Context, req *log
 			if err := entry.DecodeJSON(&client); err != nil {
 				return nil, err
 			}
+			exists = true
 		}
 	}
 
 	if redirectURIsRaw, ok := d.GetOk("redirect_uris"); ok {
 		client.RedirectURIs = redirectURIsRaw.([]string)
+		if err := framework.ValidateURLs("redirect_uris", client.RedirectURIs, redirectURIRules); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
 	} else if req.Operation == logical.CreateOperation {
 		client.RedirectURIs = d.Get("redirect_uris").([]string)
 	}
 
+	if postLogoutRedirectURIsRaw, ok := d.GetOk("post_logout_redirect_uris"); ok {
+		client.PostLogoutRedirectURIs = postLogoutRedirectURIsRaw.([]string)
+		if err := framework.ValidateURLs("post_logout_redirect_uris", client.PostLogoutRedirectURIs, redirectURIRules); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	} else if req.Operation == logical.CreateOperation {
+		client.PostLogoutRedirectURIs = d.Get("post_logout_redirect_uris").([]string)
+	}
+
+	if allowLoopbackRaw, ok := d.GetOk("allow_loopback_redirects"); ok {
+		client.DisallowLoopbackRedirects = !allowLoopbackRaw.(bool)
+	} else if req.Operation == logical.CreateOperation {
+		client.DisallowLoopbackRedirects = !d.Get("allow_loopback_redirects").(bool)
+	}
+
 	if assignmentsRaw, ok := d.GetOk("assignments"); ok {
 		client.Assignments = assignmentsRaw.([]string)
 	} else if req.Operation == logical.CreateOperation {
 		client.Assignments = d.Get("assignments").([]string)
 	}
 
+	if allowedOriginsRaw, ok := d.GetOk("allowed_origins"); ok {
+		client.AllowedOrigins = allowedOriginsRaw.([]string)
+	} else if req.Operation == logical.CreateOperation {
+		client.AllowedOrigins = d.Get("allowed_origins").([]string)
+	}
+
 	// remove duplicate assignments and redirect URIs
 	client.Assignments = strutil.RemoveDuplicates(client.Assignments, false)
 	client.RedirectURIs = strutil.RemoveDuplicates(client.RedirectURIs, false)
+	client.PostLogoutRedirectURIs = strutil.RemoveDuplicates(client.PostLogoutRedirectURIs, false)
+
+	// browsers send origins in a normalized form, so allowed origins are
+	// stored in it too
+	allowedOrigins := make([]string, 0, len(client.AllowedOrigins))
+	for _, origin := range client.AllowedOrigins {
+		normalized, err := normalizeOIDCOrigin(origin)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		allowedOrigins = append(allowedOrigins, normalized)
+	}
+	client.AllowedOrigins = strutil.RemoveDuplicates(allowedOrigins, false)
+
+	if mountRaw, ok := d.GetOk("password_grant_mount"); ok {
+		client.PasswordGrantMount = mountRaw.(string)
+	}
+
+	if cidrsRaw, ok := d.GetOk("token_endpoint_allowed_cidrs"); ok {
+		client.TokenEndpointAllowedCIDRs = cidrsRaw.([]string)
+	} else if req.Operation == logical.CreateOperation {
+		client.TokenEndpointAllowedCIDRs = d.Get("token_endpoint_allowed_cidrs").([]string)
+	}
+	client.TokenEndpointAllowedCIDRs = strutil.RemoveDuplicates(client.TokenEndpointAllowedCIDRs, false)
+	if len(client.TokenEndpointAllowedCIDRs) > 0 {
+		if valid, err := cidrutil.ValidateCIDRListSlice(client.TokenEndpointAllowedCIDRs); !valid {
+			return logical.ErrorResponse("invalid token_endpoint_allowed_cidrs: %s", err), nil
+		}
+	}
+
+	if applyToRefreshRaw, ok := d.GetOk("token_endpoint_cidrs_apply_to_refresh"); ok {
+		client.TokenEndpointCIDRsSkipRefresh = !applyToRefreshRaw.(bool)
+	} else if req.Operation == logical.CreateOperation {
+		client.TokenEndpointCIDRsSkipRefresh = !d.Get("token_endpoint_cidrs_apply_to_refresh").(bool)
+	}
+
+	if expiresAtRaw, ok := d.GetOk("expires_at"); ok {
+		expiresAt, err := parseOIDCTime("expires_at", expiresAtRaw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		client.ExpiresAt = expiresAt
+	}
+
+	if retentionRaw, ok := d.GetOk("expiry_retention_period"); ok {
+		client.ExpiryRetentionPeriod = time.Duration(retentionRaw.(int)) * time.Second
+		if client.ExpiryRetentionPeriod < 0 {
+			return logical.ErrorResponse("expiry_retention_period must not be negative"), nil
+		}
+	}
+
+	if revokeRaw, ok := d.GetOk("revoke_tokens_on_expiry"); ok {
+		client.RevokeTokensOnExpiry = revokeRaw.(bool)
+	}
+
+	// enforce that the password grant mount is an auth mount of the namespace
+	if client.PasswordGrantMount != "" {
+		client.PasswordGrantMount = strings.Trim(client.PasswordGrantMount, "/") + "/"
+		mountEntry := i.router.MatchingMountEntry(ctx, credentialRoutePrefix+client.PasswordGrantMount)
+		if mountEntry == nil || mountEntry.Table != credentialTableType ||
+			mountEntry.Path != client.PasswordGrantMount {
+			return logical.ErrorResponse("auth mount %q does not exist", client.PasswordGrantMount), nil
+		}
+	}
 
 	// enforce assignment existence
 	for _, assignment := range client.Assignments {
-		entry, err := req.Storage.Get(ctx, assignmentPath+assignment)
+		entry, err := oidcAssignmentStore.get(ctx, req.Storage, assignment)
 		if err != nil {
 			return nil, err
 		}
@@ -1010,7 +2212,7 @@ func (i *IdentityStore) pathOIDCCreateUpdateClient(ctx context.Context, req *log
 
 	if keyRaw, ok := d.GetOk("key"); ok {
 		key := keyRaw.(string)
-		if req.Operation == logical.UpdateOperation && client.Key != key {
+		if req.Operation != logical.CreateOperation && client.Key != key {
 			return logical.ErrorResponse("key modification is not allowed"), nil
 		}
 		client.Key = key
@@ -1037,15 +2239,48 @@ func (i *IdentityStore) pathOIDCCreateUpdateClient(ctx context.Context, req *log
 		return logical.ErrorResponse("a client's id_token_ttl cannot be greater than the verification_ttl of the key it references"), nil
 	}
 
+	if requireAuthTimeRaw, ok := d.GetOk("require_auth_time"); ok {
+		client.RequireAuthTime = requireAuthTimeRaw.(bool)
+	} else if req.Operation == logical.CreateOperation {
+		client.RequireAuthTime = d.Get("require_auth_time").(bool)
+	}
+
+	if userInfoAlgRaw, ok := d.GetOk("userinfo_signed_response_alg"); ok {
+		client.UserInfoSignedResponseAlg = userInfoAlgRaw.(string)
+	} else if req.Operation == logical.CreateOperation {
+		client.UserInfoSignedResponseAlg = d.Get("userinfo_signed_response_alg").(string)
+	}
+
+	// The user info responses are signed with the client's key, so the
+	// algorithm can't differ from the key's
+	if client.UserInfoSignedResponseAlg != "" && client.UserInfoSignedResponseAlg != key.Algorithm {
+		return logical.ErrorResponse("userinfo_signed_response_alg must be the algorithm %q of the key %q of the client", key.Algorithm, client.Key), nil
+	}
+
 	if accessTokenTTLRaw, ok := d.GetOk("access_token_ttl"); ok {
 		client.AccessTokenTTL = time.Duration(accessTokenTTLRaw.(int)) * time.Second
 	} else if req.Operation == logical.CreateOperation {
 		client.AccessTokenTTL = time.Duration(d.Get("access_token_ttl").(int)) * time.Second
 	}
 
+	if refreshTokenTTLRaw, ok := d.GetOk("refresh_token_ttl"); ok {
+		client.RefreshTokenTTL = time.Duration(refreshTokenTTLRaw.(int)) * time.Second
+	}
+
+	if refreshTokenMaxTTLRaw, ok := d.GetOk("refresh_token_max_ttl"); ok {
+		client.RefreshTokenMaxTTL = time.Duration(refreshTokenMaxTTLRaw.(int)) * time.Second
+	}
+
+	if client.RefreshTokenTTL < 0 || client.RefreshTokenMaxTTL < 0 {
+		return logical.ErrorResponse("refresh_token_ttl and refresh_token_max_ttl must not be negative"), nil
+	}
+	if client.RefreshTokenMaxTTL > 0 && client.RefreshTokenTTL > client.RefreshTokenMaxTTL {
+		return logical.ErrorResponse("a client's refresh_token_ttl cannot be greater than its refresh_token_max_ttl"), nil
+	}
+
 	if clientTypeRaw, ok := d.GetOk("client_type"); ok {
 		clientType := clientTypeRaw.(string)
-		if req.Operation == logical.UpdateOperation && client.Type.String() != clientType {
+		if req.Operation != logical.CreateOperation && client.Type.String() != clientType {
 			return logical.ErrorResponse("client_type modification is not allowed"), nil
 		}
 
@@ -1059,49 +2294,232 @@ func (i *IdentityStore) pathOIDCCreateUpdateClient(ctx context.Context, req *log
 		}
 	}
 
-	if client.ClientID == "" {
-		// generate client_id
-		clientID, err := base62.Random(clientIDLength)
-		if err != nil {
-			return nil, err
+	if grantTypesRaw, ok := d.GetOk("grant_types"); ok {
+		client.GrantTypes = strutil.RemoveDuplicatesStable(grantTypesRaw.([]string), false)
+		if len(client.GrantTypes) == 0 {
+			client.GrantTypes = nil
 		}
-		client.ClientID = clientID
+	}
+	if err := client.validateGrantTypes(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	// client secrets are only generated for confidential clients
-	if client.Type == confidential && client.ClientSecret == "" {
-		// generate client_secret
-		clientSecret, err := base62.Random(clientSecretLength)
-		if err != nil {
-			return nil, err
+	if subjectTypeRaw, ok := d.GetOk("subject_type"); ok {
+		client.SubjectType = subjectTypeRaw.(string)
+		if !strutil.StrListContains(supportedSubjectTypes, client.SubjectType) {
+			return logical.ErrorResponse("invalid subject_type %q", client.SubjectType), nil
 		}
-		client.ClientSecret = clientSecretPrefix + clientSecret
 	}
 
-	// invalidate the cached client in memdb
-	if err := i.memDBDeleteClientByName(ctx, name); err != nil {
-		return nil, err
+	if authMethodRaw, ok := d.GetOk("token_endpoint_auth_method"); ok {
+		client.TokenEndpointAuthMethod = authMethodRaw.(string)
 	}
-
-	// store client
-	entry, err := logical.StorageEntryJSON(clientPath+name, client)
-	if err != nil {
-		return nil, err
+	if jwksRaw, ok := d.GetOk("jwks"); ok {
+		client.JWKS = strings.TrimSpace(jwksRaw.(string))
 	}
-	if err := req.Storage.Put(ctx, entry); err != nil {
-		return nil, err
+	if jwksURIRaw, ok := d.GetOk("jwks_uri"); ok {
+		client.JWKSURI = strings.TrimSpace(jwksURIRaw.(string))
+	}
+	if clockSkewRaw, ok := d.GetOk("client_assertion_clock_skew"); ok {
+		client.ClientAssertionClockSkew = time.Duration(clockSkewRaw.(int)) * time.Second
+	} else if req.Operation == logical.CreateOperation {
+		client.ClientAssertionClockSkew = time.Duration(d.Get("client_assertion_clock_skew").(int)) * time.Second
+	}
+	if err := client.validateTokenEndpointAuthMethod(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	return nil, nil
+	// enforce that the entity of the client_credentials grant is an entity
+	// of the namespace
+	if entityIDRaw, ok := d.GetOk("entity_id"); ok {
+		client.EntityID = strings.TrimSpace(entityIDRaw.(string))
+	}
+	if client.EntityID != "" {
+		entity, err := i.MemDBEntityByID(client.EntityID, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil || entity.NamespaceID != ns.ID {
+			return logical.ErrorResponse("entity %q does not exist", client.EntityID), nil
+		}
+	}
+
+	if client.ClientID == "" {
+		// generate client_id
+		clientID, err := base62.Random(clientIDLength)
+		if err != nil {
+			return nil, err
+		}
+		client.ClientID = clientID
+	}
+
+	// the redirect URIs must be allowed by the providers that allow the
+	// client, which is only known once it has a client ID
+	providers, err := i.providersAllowingClient(ctx, req.Storage, client.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	var disallowed []string
+	for _, provider := range providers {
+		if uris := disallowedRedirectURIs(client.registeredRedirectURIs(), provider.AllowedRedirectHosts); len(uris) > 0 {
+			disallowed = append(disallowed, fmt.Sprintf("%s by provider %q", strings.Join(uris, ", "), provider.name))
+		}
+	}
+	if len(disallowed) > 0 {
+		return logical.ErrorResponse("redirect URIs outside the allowed redirect hosts: %s", strings.Join(disallowed, "; ")), nil
+	}
+
+	// client secrets are only generated for confidential clients
+	if client.Type == confidential && client.ClientSecret == "" {
+		// generate client_secret
+		clientSecret, err := generateClientSecret()
+		if err != nil {
+			return nil, err
+		}
+		client.ClientSecret = clientSecret
+	}
+
+	now := time.Now()
+	if !exists {
+		client.CreatedAt = now
+	}
+	client.UpdatedAt = now
+
+	// store client
+	entry, err := oidcClientStore.put(ctx, req.Storage, name, client)
+	if err != nil {
+		return nil, err
+	}
+	client.BucketKey = entry.BucketKey
+
+	// update the client in memdb
+	if err := i.memDBReplaceClientByName(ctx, name, &client); err != nil {
+		return nil, err
+	}
+
+	if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+		return nil, err
+	}
+
+	// bring the deletion of the client in if it's due before the next run
+	// of the periodic func
+	if deleteAt := client.deleteAt(); !deleteAt.IsZero() {
+		i.advanceOIDCPeriodicRun(deleteAt)
+	}
+
+	var resp logical.Response
+	for _, origin := range client.AllowedOrigins {
+		if strings.Contains(origin, "*") {
+			resp.AddWarning(fmt.Sprintf("The allowed origin %q is a wildcard, which allows any matching "+
+				"origin to make cross-origin requests for the client. Listing the exact origins of the "+
+				"client is recommended.", origin))
+		}
+	}
+	if len(resp.Warnings) == 0 {
+		return nil, nil
+	}
+
+	return &resp, nil
 }
 
 // pathOIDCListClient is used to list clients
 func (i *IdentityStore) pathOIDCListClient(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	clients, err := req.Storage.List(ctx, clientPath)
+	after := d.Get("after").(string)
+	limit := d.Get("limit").(int)
+	keyName := d.Get("key").(string)
+	assignmentName := d.Get("assignment").(string)
+	detailed := d.Get("detailed").(bool)
+
+	if limit < 0 {
+		return logical.ErrorResponse("limit must be a positive integer"), logical.ErrInvalidRequest
+	}
+
+	// Clients are sorted by name, so paging is stable across requests
+	clients, err := i.memDBClients(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return logical.ListResponse(clients), nil
+
+	names := make([]string, 0, len(clients))
+	var keyInfo map[string]interface{}
+	if detailed {
+		keyInfo = make(map[string]interface{})
+	}
+	for _, client := range clients {
+		if after != "" && client.Name <= after {
+			continue
+		}
+		if keyName != "" && client.Key != keyName {
+			continue
+		}
+		if assignmentName != "" && !strutil.StrListContains(client.Assignments, assignmentName) {
+			continue
+		}
+		if limit > 0 && len(names) >= limit {
+			break
+		}
+
+		names = append(names, client.Name)
+		if detailed {
+			keyInfo[client.Name] = client.listInfo()
+		}
+	}
+
+	if !detailed {
+		return logical.ListResponse(names), nil
+	}
+	return logical.ListResponseWithInfo(names, keyInfo), nil
+}
+
+// listInfo returns the details of the client included in detailed list
+// responses, which never include the client secret.
+func (c *client) listInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"redirect_uris":                         c.RedirectURIs,
+		"post_logout_redirect_uris":             c.PostLogoutRedirectURIs,
+		"allow_loopback_redirects":              !c.DisallowLoopbackRedirects,
+		"assignments":                           c.Assignments,
+		"key":                                   c.Key,
+		"id_token_ttl":                          int64(c.IDTokenTTL.Seconds()),
+		"require_auth_time":                     c.RequireAuthTime,
+		"userinfo_signed_response_alg":          c.UserInfoSignedResponseAlg,
+		"access_token_ttl":                      int64(c.AccessTokenTTL.Seconds()),
+		"refresh_token_ttl":                     int64(c.RefreshTokenTTL.Seconds()),
+		"refresh_token_max_ttl":                 int64(c.RefreshTokenMaxTTL.Seconds()),
+		"client_id":                             c.ClientID,
+		"client_type":                           c.Type.String(),
+		"allowed_origins":                       c.AllowedOrigins,
+		"password_grant_mount":                  c.PasswordGrantMount,
+		"token_endpoint_allowed_cidrs":          c.TokenEndpointAllowedCIDRs,
+		"token_endpoint_cidrs_apply_to_refresh": !c.TokenEndpointCIDRsSkipRefresh,
+		"grant_types":                           c.allowedGrantTypes(),
+		"entity_id":                             c.EntityID,
+		"subject_type":                          c.subjectType(),
+		"token_endpoint_auth_method":            c.tokenEndpointAuthMethod(),
+		"jwks":                                  c.JWKS,
+		"jwks_uri":                              c.JWKSURI,
+		"client_assertion_clock_skew":           int64(c.ClientAssertionClockSkew.Seconds()),
+		"expires_at":                            formatClientTime(c.ExpiresAt),
+		"expired":                               c.expired(time.Now()),
+		"expiry_retention_period":               int64(c.ExpiryRetentionPeriod.Seconds()),
+		"revoke_tokens_on_expiry":               c.RevokeTokensOnExpiry,
+		"created_at":                            formatClientTime(c.CreatedAt),
+		"updated_at":                            formatClientTime(c.UpdatedAt),
+		"last_token_issued_at":                  formatClientTime(c.LastTokenIssuedAt),
+		"client_secret_rotated_at":              formatClientTime(c.ClientSecretRotatedAt),
+		"previous_client_secret_active":         c.previousClientSecretValid(time.Now()),
+		"previous_client_secret_expires_at":     formatClientTime(c.previousClientSecretExpireAt(time.Now())),
+	}
+}
+
+// formatClientTime formats a time of a client for responses. Times that
+// weren't recorded, such as the creation time of clients created before it
+// was recorded, are empty.
+func formatClientTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
 }
 
 // pathOIDCReadClient is used to read an existing client
@@ -1118,13 +2536,40 @@ func (i *IdentityStore) pathOIDCReadClient(ctx context.Context, req *logical.Req
 
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"redirect_uris":    client.RedirectURIs,
-			"assignments":      client.Assignments,
-			"key":              client.Key,
-			"id_token_ttl":     int64(client.IDTokenTTL.Seconds()),
-			"access_token_ttl": int64(client.AccessTokenTTL.Seconds()),
-			"client_id":        client.ClientID,
-			"client_type":      client.Type.String(),
+			"redirect_uris":                         client.RedirectURIs,
+			"post_logout_redirect_uris":             client.PostLogoutRedirectURIs,
+			"allow_loopback_redirects":              !client.DisallowLoopbackRedirects,
+			"assignments":                           client.Assignments,
+			"key":                                   client.Key,
+			"id_token_ttl":                          int64(client.IDTokenTTL.Seconds()),
+			"require_auth_time":                     client.RequireAuthTime,
+			"userinfo_signed_response_alg":          client.UserInfoSignedResponseAlg,
+			"access_token_ttl":                      int64(client.AccessTokenTTL.Seconds()),
+			"refresh_token_ttl":                     int64(client.RefreshTokenTTL.Seconds()),
+			"refresh_token_max_ttl":                 int64(client.RefreshTokenMaxTTL.Seconds()),
+			"client_id":                             client.ClientID,
+			"client_type":                           client.Type.String(),
+			"allowed_origins":                       client.AllowedOrigins,
+			"password_grant_mount":                  client.PasswordGrantMount,
+			"token_endpoint_allowed_cidrs":          client.TokenEndpointAllowedCIDRs,
+			"token_endpoint_cidrs_apply_to_refresh": !client.TokenEndpointCIDRsSkipRefresh,
+			"grant_types":                           client.allowedGrantTypes(),
+			"entity_id":                             client.EntityID,
+			"subject_type":                          client.subjectType(),
+			"token_endpoint_auth_method":            client.tokenEndpointAuthMethod(),
+			"jwks":                                  client.JWKS,
+			"jwks_uri":                              client.JWKSURI,
+			"client_assertion_clock_skew":           int64(client.ClientAssertionClockSkew.Seconds()),
+			"expires_at":                            formatClientTime(client.ExpiresAt),
+			"expired":                               client.expired(time.Now()),
+			"expiry_retention_period":               int64(client.ExpiryRetentionPeriod.Seconds()),
+			"revoke_tokens_on_expiry":               client.RevokeTokensOnExpiry,
+			"created_at":                            formatClientTime(client.CreatedAt),
+			"updated_at":                            formatClientTime(client.UpdatedAt),
+			"last_token_issued_at":                  formatClientTime(client.LastTokenIssuedAt),
+			"client_secret_rotated_at":              formatClientTime(client.ClientSecretRotatedAt),
+			"previous_client_secret_active":         client.previousClientSecretValid(time.Now()),
+			"previous_client_secret_expires_at":     formatClientTime(client.previousClientSecretExpireAt(time.Now())),
 		},
 	}
 
@@ -1142,23 +2587,73 @@ func (i *IdentityStore) pathOIDCDeleteClient(ctx context.Context, req *logical.R
 	i.oidcLock.Lock()
 	defer i.oidcLock.Unlock()
 
+	// Delete the client from storage
+	if err := oidcClientStore.delete(ctx, req.Storage, name); err != nil {
+		return nil, err
+	}
+
 	// Delete the client from memdb
 	if err := i.memDBDeleteClientByName(ctx, name); err != nil {
 		return nil, err
 	}
 
-	// Delete the client from storage
-	if err := req.Storage.Delete(ctx, clientPath+name); err != nil {
+	if err := i.flushOIDCProviderDocuments(ctx); err != nil {
 		return nil, err
 	}
 
 	return nil, nil
 }
 
+// deleteExpiredOIDCClients deletes the clients of the namespace of the context
+// whose retention period has elapsed since they expired. It returns the
+// soonest time at which another client is due for deletion, or the zero time
+// if none is.
+func (i *IdentityStore) deleteExpiredOIDCClients(ctx context.Context, s logical.Storage) (time.Time, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	clients, err := i.memDBClients(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var nextDeletion time.Time
+	var deleted bool
+	now := time.Now()
+	for _, client := range clients {
+		deleteAt := client.deleteAt()
+		if deleteAt.IsZero() {
+			continue
+		}
+		if now.Before(deleteAt) {
+			nextDeletion = earliestTime(nextDeletion, deleteAt)
+			continue
+		}
+
+		if err := oidcClientStore.delete(ctx, s, client.Name); err != nil {
+			return nextDeletion, err
+		}
+		if err := i.memDBDeleteClientByName(ctx, client.Name); err != nil {
+			return nextDeletion, err
+		}
+		deleted = true
+		i.Logger().Info("deleted expired OIDC client", "name", client.Name, "client_id", client.ClientID,
+			"expired_at", client.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+
+	if deleted {
+		if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+			return nextDeletion, err
+		}
+	}
+
+	return nextDeletion, nil
+}
+
 func (i *IdentityStore) pathOIDCClientExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
 	name := d.Get("name").(string)
 
-	entry, err := req.Storage.Get(ctx, clientPath+name)
+	entry, err := oidcClientStore.get(ctx, req.Storage, name)
 	if err != nil {
 		return false, err
 	}
@@ -1171,11 +2666,24 @@ func (i *IdentityStore) pathOIDCCreateUpdateProvider(ctx context.Context, req *l
 	var resp logical.Response
 	name := d.Get("name").(string)
 
+	// The issuer is checked before the lock is taken since resolving its
+	// host may take a while
+	var issuer string
+	var issuerWarnings []map[string]interface{}
+	if issuerRaw, ok := d.GetOk("issuer"); ok && issuerRaw.(string) != "" {
+		var err error
+		issuer, err = normalizeOIDCIssuer("issuer", issuerRaw.(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		issuerWarnings = i.oidcIssuerWarnings(ctx, issuer)
+	}
+
 	i.oidcLock.Lock()
 	defer i.oidcLock.Unlock()
 
 	var provider provider
-	if req.Operation == logical.UpdateOperation {
+	if req.Operation == logical.UpdateOperation || req.Operation == logical.PatchOperation {
 		entry, err := req.Storage.Get(ctx, providerPath+name)
 		if err != nil {
 			return nil, err
@@ -1187,12 +2695,25 @@ func (i *IdentityStore) pathOIDCCreateUpdateProvider(ctx context.Context, req *l
 		}
 	}
 
-	if issuerRaw, ok := d.GetOk("issuer"); ok {
-		provider.Issuer = issuerRaw.(string)
+	if _, ok := d.GetOk("issuer"); ok {
+		provider.Issuer = issuer
 	} else if req.Operation == logical.CreateOperation {
 		provider.Issuer = d.Get("issuer").(string)
 	}
 
+	if _, ok := d.GetOk("additional_issuers"); ok || req.Operation == logical.CreateOperation {
+		additionalIssuersRaw := d.Get("additional_issuers").([]string)
+		additionalIssuers := make([]string, 0, len(additionalIssuersRaw))
+		for _, additionalIssuer := range additionalIssuersRaw {
+			normalized, err := normalizeOIDCIssuer("additional_issuers", additionalIssuer)
+			if err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+			additionalIssuers = append(additionalIssuers, normalized)
+		}
+		provider.AdditionalIssuers = strutil.RemoveDuplicates(additionalIssuers, false)
+	}
+
 	if allowedClientIDsRaw, ok := d.GetOk("allowed_client_ids"); ok {
 		provider.AllowedClientIDs = allowedClientIDsRaw.([]string)
 	} else if req.Operation == logical.CreateOperation {
@@ -1205,38 +2726,137 @@ func (i *IdentityStore) pathOIDCCreateUpdateProvider(ctx context.Context, req *l
 		provider.ScopesSupported = d.Get("scopes_supported").([]string)
 	}
 
-	// remove duplicate allowed client IDs and scopes
+	if defaultScopesRaw, ok := d.GetOk("default_scopes"); ok {
+		provider.DefaultScopes = defaultScopesRaw.([]string)
+	} else if req.Operation == logical.CreateOperation {
+		provider.DefaultScopes = d.Get("default_scopes").([]string)
+	}
+
+	if allowedRedirectHostsRaw, ok := d.GetOk("allowed_redirect_hosts"); ok {
+		provider.AllowedRedirectHosts = allowedRedirectHostsRaw.([]string)
+	} else if req.Operation == logical.CreateOperation {
+		provider.AllowedRedirectHosts = d.Get("allowed_redirect_hosts").([]string)
+	}
+
+	if metadataSigningKeyRaw, ok := d.GetOk("metadata_signing_key"); ok {
+		provider.MetadataSigningKey = metadataSigningKeyRaw.(string)
+	}
+
+	if maintenanceRaw, ok := d.GetOk("maintenance"); ok {
+		provider.Maintenance = maintenanceRaw.(bool)
+	}
+
+	if maintenanceMessageRaw, ok := d.GetOk("maintenance_message"); ok {
+		provider.MaintenanceMessage = maintenanceMessageRaw.(string)
+	}
+
+	if allowCrossClientRaw, ok := d.GetOk("allow_cross_client_introspection"); ok {
+		provider.AllowCrossClientIntrospection = allowCrossClientRaw.(bool)
+	}
+
+	if displayNameRaw, ok := d.GetOk("display_name"); ok {
+		provider.DisplayName = strings.TrimSpace(displayNameRaw.(string))
+	}
+
+	for field, uri := range map[string]*string{
+		"service_documentation": &provider.ServiceDocumentation,
+		"op_policy_uri":         &provider.OPPolicyURI,
+		"op_tos_uri":            &provider.OPTOSURI,
+	} {
+		raw, ok := d.GetOk(field)
+		if !ok {
+			continue
+		}
+		*uri = raw.(string)
+		if *uri == "" {
+			continue
+		}
+		if _, err := framework.ValidateURL(field, *uri, providerDocumentURLRules); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	// enforce key existence for signed metadata
+	if provider.MetadataSigningKey != "" {
+		key, err := i.getNamedKey(ctx, req.Storage, provider.MetadataSigningKey)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return logical.ErrorResponse("key %q does not exist", provider.MetadataSigningKey), nil
+		}
+	}
+
+	// remove duplicate allowed client IDs, scopes and redirect hosts
 	provider.AllowedClientIDs = strutil.RemoveDuplicates(provider.AllowedClientIDs, false)
 	provider.ScopesSupported = strutil.RemoveDuplicates(provider.ScopesSupported, false)
-
-	if provider.Issuer != "" {
-		// verify that issuer is the correct format:
-		//   - http or https
-		//   - host name
-		//   - optional port
-		//   - nothing more
-		valid := false
-		if u, err := url.Parse(provider.Issuer); err == nil {
-			u2 := url.URL{
-				Scheme: u.Scheme,
-				Host:   u.Host,
-			}
-			valid = (*u == u2) &&
-				(u.Scheme == "http" || u.Scheme == "https") &&
-				u.Host != ""
+	provider.DefaultScopes = strutil.RemoveDuplicates(provider.DefaultScopes, false)
+	provider.AllowedRedirectHosts = strutil.RemoveDuplicates(provider.AllowedRedirectHosts, true)
+
+	// the discovery document picks an additional issuer by the host of the
+	// request, so each must have its own host, distinct from the primary
+	// issuer's
+	primaryIssuer := provider.Issuer
+	if primaryIssuer == "" && i.redirectAddr != "" {
+		primaryIssuer, _ = normalizeOIDCIssuer("issuer", i.redirectAddr)
+	}
+	additionalHosts := make(map[string]string, len(provider.AdditionalIssuers))
+	for _, additionalIssuer := range provider.AdditionalIssuers {
+		host := oidcIssuerHost(additionalIssuer)
+		if primaryIssuer != "" && host == oidcIssuerHost(primaryIssuer) {
+			return logical.ErrorResponse("additional issuer %q has the host of the issuer of the provider", additionalIssuer), nil
+		}
+		if other, ok := additionalHosts[host]; ok {
+			return logical.ErrorResponse("additional issuers %q and %q have the same host", other, additionalIssuer), nil
 		}
+		additionalHosts[host] = additionalIssuer
+	}
 
-		if !valid {
-			return logical.ErrorResponse(
-				"invalid issuer, which must include only a scheme, host, " +
-					"and optional port (e.g. https://example.com:8200)"), nil
+	// default scopes are granted on requests that only name the openid
+	// scope, so they must be scopes that clients could request
+	for _, scopeName := range provider.DefaultScopes {
+		if !strutil.StrListContains(provider.ScopesSupported, scopeName) {
+			return logical.ErrorResponse("default scope %q is not one of the scopes supported by the provider", scopeName), nil
 		}
+	}
 
+	if provider.Issuer != "" {
 		resp.AddWarning(`If "issuer" is set explicitly, all tokens must be ` +
 			`validated against that address, including those issued by secondary ` +
 			`clusters. Setting issuer to "" will restore the default behavior of ` +
 			`using the cluster's api_addr as the issuer.`)
 
+		// relying parties with https redirect URIs expect an https issuer
+		if strings.HasPrefix(provider.Issuer, "http://") {
+			clientNames, err := i.clientNamesWithHTTPSRedirectURIs(ctx, req.Storage, provider.AllowedClientIDs)
+			if err != nil {
+				return nil, err
+			}
+			if len(clientNames) > 0 {
+				return logical.ErrorResponse("invalid issuer, which must use https because these clients "+
+					"have https redirect URIs: %s", strings.Join(clientNames, ", ")), nil
+			}
+		}
+	}
+
+	// Existing clients keep their redirect URIs when the allowed redirect
+	// hosts change, so report the ones the provider will now refuse
+	if len(provider.AllowedRedirectHosts) > 0 {
+		clients, err := i.clientsAllowedByIDs(ctx, req.Storage, provider.AllowedClientIDs)
+		if err != nil {
+			return nil, err
+		}
+		var nonCompliant []string
+		for _, client := range clients {
+			if uris := disallowedRedirectURIs(client.registeredRedirectURIs(), provider.AllowedRedirectHosts); len(uris) > 0 {
+				nonCompliant = append(nonCompliant, fmt.Sprintf("%q (%s)", client.Name, strings.Join(uris, ", ")))
+			}
+		}
+		if len(nonCompliant) > 0 {
+			sort.Strings(nonCompliant)
+			resp.AddWarning(fmt.Sprintf("These clients have redirect URIs outside the allowed redirect "+
+				"hosts, which will be refused by the provider: %s", strings.Join(nonCompliant, "; ")))
+		}
 	}
 
 	scopeTemplateKeyNames := make(map[string]string)
@@ -1251,22 +2871,12 @@ func (i *IdentityStore) pathOIDCCreateUpdateProvider(ctx context.Context, req *l
 		}
 
 		// ensure no two templates have the same top-level keys
-		_, populatedTemplate, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
-			Mode:   identitytpl.JSONTemplating,
-			String: scope.Template,
-			Entity: new(logical.Entity),
-			Groups: make([]*logical.Group, 0),
-		})
+		keyNames, err := scopeClaimKeys(scopeName, scope)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing template for scope %q: %s", scopeName, err.Error())
-		}
-
-		jsonTemplate := make(map[string]interface{})
-		if err = json.Unmarshal([]byte(populatedTemplate), &jsonTemplate); err != nil {
 			return nil, err
 		}
 
-		for keyName := range jsonTemplate {
+		for _, keyName := range keyNames {
 			val, ok := scopeTemplateKeyNames[keyName]
 			if ok && val != scopeName {
 				resp.AddWarning(fmt.Sprintf("Found scope templates with conflicting top-level keys: "+
@@ -1288,6 +2898,20 @@ func (i *IdentityStore) pathOIDCCreateUpdateProvider(ctx context.Context, req *l
 		return nil, err
 	}
 
+	if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(issuerWarnings) > 0 {
+		for _, warning := range issuerWarnings {
+			resp.AddWarning(warning["message"].(string))
+		}
+		resp.Data = map[string]interface{}{
+			"issuer":          provider.Issuer,
+			"issuer_warnings": issuerWarnings,
+		}
+	}
+
 	if len(resp.Warnings) == 0 {
 		return nil, nil
 	}
@@ -1295,6 +2919,167 @@ func (i *IdentityStore) pathOIDCCreateUpdateProvider(ctx context.Context, req *l
 	return &resp, nil
 }
 
+// normalizeOIDCIssuer validates the issuer of a provider and returns its
+// normalized form: a lowercase scheme and host, an optional port and no
+// trailing slash. Relying parties compare the iss claim of tokens to the
+// issuer of the discovery document exactly, so the stored issuer must be the
+// form advertised there.
+func normalizeOIDCIssuer(field, issuer string) (string, error) {
+	u, err := framework.ValidateURL(field, issuer, issuerURLRules)
+	if err != nil {
+		return "", err
+	}
+	if strings.Trim(u.Path, "/") != "" {
+		return "", fmt.Errorf("invalid %s, which must include only a scheme, host, "+
+			"and optional port (e.g. https://example.com:8200)", field)
+	}
+
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host), nil
+}
+
+// oidcIssuerHost returns the host, and port if any, of a normalized issuer.
+func oidcIssuerHost(issuer string) string {
+	if i := strings.Index(issuer, "://"); i >= 0 {
+		return issuer[i+len("://"):]
+	}
+	return issuer
+}
+
+// oidcIssuerWarnings returns warnings about a normalized issuer that may be
+// legitimate, such as in air-gapped setups, and so don't fail the write:
+// a host that doesn't resolve from this node, and an issuer that differs from
+// the api_addr of the cluster. Each warning has a code and a message.
+func (i *IdentityStore) oidcIssuerWarnings(ctx context.Context, issuer string) []map[string]interface{} {
+	var warnings []map[string]interface{}
+	addWarning := func(code, message string) {
+		warnings = append(warnings, map[string]interface{}{
+			"code":    code,
+			"message": message,
+		})
+	}
+
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil
+	}
+
+	if host := u.Hostname(); net.ParseIP(host) == nil {
+		lookupCtx, cancel := context.WithTimeout(ctx, oidcIssuerLookupTimeout)
+		defer cancel()
+		if _, err := oidcIssuerLookupHost(lookupCtx, host); err != nil {
+			addWarning("issuer_unresolvable", fmt.Sprintf("The host of issuer %q could not be resolved "+
+				"from this node: %s. Relying parties must be able to reach the issuer to discover "+
+				"the provider.", issuer, err))
+		}
+	}
+
+	if i.redirectAddr != "" {
+		apiAddr, err := normalizeOIDCIssuer("issuer", i.redirectAddr)
+		if err == nil && apiAddr != issuer {
+			addWarning("issuer_api_addr_mismatch", fmt.Sprintf("The issuer %q differs from the "+
+				"api_addr of the cluster %q. Relying parties must reach the provider through the issuer.",
+				issuer, apiAddr))
+		}
+	}
+
+	return warnings
+}
+
+// clientNamesWithHTTPSRedirectURIs returns the sorted names of the clients
+// allowed by the given client IDs that have https redirect URIs.
+func (i *IdentityStore) clientNamesWithHTTPSRedirectURIs(ctx context.Context, s logical.Storage, allowedClientIDs []string) ([]string, error) {
+	clients, err := i.clientsAllowedByIDs(ctx, s, allowedClientIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, client := range clients {
+		for _, redirectURI := range client.RedirectURIs {
+			if strings.HasPrefix(strings.ToLower(redirectURI), "https://") {
+				names = append(names, client.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// signingAlgsOfClients returns the signing algorithms of the keys of the
+// clients, in the order of supportedAlgs. Every supported algorithm is
+// returned if there are no clients, since the keys of future clients aren't
+// known yet.
+func (i *IdentityStore) signingAlgsOfClients(ctx context.Context, s logical.Storage, clients []*client) ([]string, error) {
+	if len(clients) == 0 {
+		return supportedAlgs, nil
+	}
+
+	configured := make(map[string]bool)
+	for _, client := range clients {
+		key, err := i.getNamedKey(ctx, s, client.Key)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			configured[key.Algorithm] = true
+		}
+	}
+
+	algs := make([]string, 0, len(configured))
+	for _, alg := range supportedAlgs {
+		if configured[alg] {
+			algs = append(algs, alg)
+		}
+	}
+	return algs, nil
+}
+
+// clientsAllowedByIDs returns the clients allowed by the allowed client IDs
+// of a provider.
+func (i *IdentityStore) clientsAllowedByIDs(ctx context.Context, s logical.Storage, allowedClientIDs []string) ([]*client, error) {
+	if strutil.StrListContains(allowedClientIDs, "*") {
+		return i.listClients(ctx, s)
+	}
+
+	var clients []*client
+	for _, clientID := range allowedClientIDs {
+		client, err := i.clientByID(clientID)
+		if err != nil {
+			return nil, err
+		}
+		if client != nil {
+			clients = append(clients, client)
+		}
+	}
+	return clients, nil
+}
+
+// providersAllowingClient returns the providers of the namespace that allow
+// the client ID, sorted by name.
+func (i *IdentityStore) providersAllowingClient(ctx context.Context, s logical.Storage, clientID string) ([]*provider, error) {
+	names, err := s.List(ctx, providerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []*provider
+	for _, name := range names {
+		provider, err := i.getOIDCProvider(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil {
+			continue
+		}
+		if strutil.StrListContains(provider.AllowedClientIDs, "*") ||
+			strutil.StrListContains(provider.AllowedClientIDs, clientID) {
+			providers = append(providers, provider)
+		}
+	}
+	return providers, nil
+}
+
 // pathOIDCListProvider is used to list named providers
 func (i *IdentityStore) pathOIDCListProvider(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	providers, err := req.Storage.List(ctx, providerPath)
@@ -1318,9 +3103,20 @@ func (i *IdentityStore) pathOIDCReadProvider(ctx context.Context, req *logical.R
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"issuer":             provider.effectiveIssuer,
-			"allowed_client_ids": provider.AllowedClientIDs,
-			"scopes_supported":   provider.ScopesSupported,
+			"issuer":                           provider.effectiveIssuer,
+			"additional_issuers":               provider.AdditionalIssuers,
+			"allowed_client_ids":               provider.AllowedClientIDs,
+			"scopes_supported":                 provider.ScopesSupported,
+			"default_scopes":                   provider.DefaultScopes,
+			"allowed_redirect_hosts":           provider.AllowedRedirectHosts,
+			"metadata_signing_key":             provider.MetadataSigningKey,
+			"maintenance":                      provider.Maintenance,
+			"maintenance_message":              provider.MaintenanceMessage,
+			"display_name":                     provider.DisplayName,
+			"service_documentation":            provider.ServiceDocumentation,
+			"op_policy_uri":                    provider.OPPolicyURI,
+			"op_tos_uri":                       provider.OPTOSURI,
+			"allow_cross_client_introspection": provider.AllowCrossClientIntrospection,
 		},
 	}, nil
 }
@@ -1350,6 +3146,13 @@ func (i *IdentityStore) getOIDCProvider(ctx context.Context, s logical.Storage,
 	}
 
 	provider.effectiveIssuer += "/v1/" + ns.Path + "identity/oidc/provider/" + name
+	provider.name = name
+
+	provider.effectiveAdditionalIssuers = make(map[string]string, len(provider.AdditionalIssuers))
+	for _, additionalIssuer := range provider.AdditionalIssuers {
+		provider.effectiveAdditionalIssuers[oidcIssuerHost(additionalIssuer)] = additionalIssuer +
+			"/v1/" + ns.Path + "identity/oidc/provider/" + name
+	}
 
 	return &provider, nil
 }
@@ -1363,11 +3166,27 @@ func (i *IdentityStore) pathOIDCDeleteProvider(ctx context.Context, req *logical
 			defaultProviderName), nil
 	}
 
-	return nil, req.Storage.Delete(ctx, providerPath+name)
-}
-
-func (i *IdentityStore) pathOIDCProviderExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
-	name := d.Get("name").(string)
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, providerPath+name); err != nil {
+		return nil, err
+	}
+
+	// A provider created with the same name gets new pairwise subjects
+	if err := req.Storage.Delete(ctx, pairwiseSaltPath+name); err != nil {
+		return nil, err
+	}
+
+	if err := i.flushOIDCProviderDocuments(ctx); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCProviderExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	name := d.Get("name").(string)
 
 	entry, err := req.Storage.Get(ctx, providerPath+name)
 	if err != nil {
@@ -1380,51 +3199,207 @@ func (i *IdentityStore) pathOIDCProviderExistenceCheck(ctx context.Context, req
 func (i *IdentityStore) pathOIDCProviderDiscovery(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
 
-	p, err := i.getOIDCProvider(ctx, req.Storage, name)
+	// The document served from the host of an additional issuer advertises
+	// that issuer, so it's cached by host. Other hosts get the document of
+	// the primary issuer.
+	hosts, err := i.cachedOIDCDocument(ctx, "providerIssuerHosts/"+name, func() ([]byte, error) {
+		return i.renderProviderIssuerHosts(ctx, req.Storage, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	host := strings.ToLower(requestHost(req))
+	if host == "" || !strutil.StrListContains(strings.Split(string(hosts), "\n"), host) {
+		host = ""
+	}
+
+	data, err := i.cachedOIDCDocument(ctx, "providerDiscovery/"+name+"/"+host, func() ([]byte, error) {
+		return i.renderProviderDiscovery(ctx, req.Storage, name, host)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	return oidcDocumentResponse(req, data, "max-age=3600"), nil
+}
+
+// renderProviderIssuerHosts returns the newline-separated hosts of the
+// additional issuers of the named provider, or nil if the provider doesn't
+// exist.
+func (i *IdentityStore) renderProviderIssuerHosts(ctx context.Context, s logical.Storage, name string) ([]byte, error) {
+	p, err := i.getOIDCProvider(ctx, s, name)
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0, len(p.effectiveAdditionalIssuers))
+	for host := range p.effectiveAdditionalIssuers {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return []byte(strings.Join(hosts, "\n")), nil
+}
+
+// renderProviderDiscovery returns the JSON discovery document of the named
+// provider as served from the given host, or nil if the provider doesn't
+// exist.
+func (i *IdentityStore) renderProviderDiscovery(ctx context.Context, s logical.Storage, name, host string) ([]byte, error) {
+	p, err := i.getOIDCProvider(ctx, s, name)
 	if err != nil {
 		return nil, err
 	}
 	if p == nil {
 		return nil, nil
 	}
+	issuer := p.issuerForHost(host)
 
 	// the "openid" scope is reserved and is included for every provider
 	scopes := append(p.ScopesSupported, openIDScope)
 
+	// the grant types other than authorization_code are only advertised if
+	// a client of the provider has enabled them
+	clients, err := i.clientsAllowedByIDs(ctx, s, p.AllowedClientIDs)
+	if err != nil {
+		return nil, err
+	}
+	var device, password, refresh, clientCredentials bool
+	for _, client := range clients {
+		device = device || client.allowsGrantType(deviceCodeGrantType)
+		password = password || (client.allowsGrantType("password") && client.PasswordGrantMount != "")
+		refresh = refresh || (client.allowsGrantType("refresh_token") && client.RefreshTokenTTL > 0)
+		clientCredentials = clientCredentials || client.allowsGrantType(clientCredentialsGrantType)
+	}
+	grantTypes := []string{"authorization_code"}
+	if device {
+		grantTypes = append(grantTypes, deviceCodeGrantType)
+	}
+	if password {
+		grantTypes = append(grantTypes, "password")
+	}
+	if refresh {
+		grantTypes = append(grantTypes, "refresh_token")
+	}
+	if clientCredentials {
+		grantTypes = append(grantTypes, clientCredentialsGrantType)
+	}
+
+	idTokenAlgs, err := i.signingAlgsOfClients(ctx, s, clients)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := i.claimsSupported(ctx, s, p)
+	if err != nil {
+		return nil, err
+	}
+
 	disc := providerDiscovery{
-		Issuer:                p.effectiveIssuer,
-		Keys:                  p.effectiveIssuer + "/.well-known/keys",
-		AuthorizationEndpoint: strings.Replace(p.effectiveIssuer, "/v1/", "/ui/vault/", 1) + "/authorize",
-		TokenEndpoint:         p.effectiveIssuer + "/token",
-		UserinfoEndpoint:      p.effectiveIssuer + "/userinfo",
-		IDTokenAlgs:           supportedAlgs,
-		Scopes:                scopes,
-		RequestURIParameter:   false,
-		ResponseTypes:         []string{"code"},
-		Subjects:              []string{"public"},
-		GrantTypes:            []string{"authorization_code"},
+		Issuer:                      issuer,
+		Keys:                        issuer + "/.well-known/keys",
+		AuthorizationEndpoint:       strings.Replace(issuer, "/v1/", "/ui/vault/", 1) + "/authorize",
+		TokenEndpoint:               issuer + "/token",
+		DeviceAuthorizationEndpoint: issuer + "/device_authorization",
+		UserinfoEndpoint:            issuer + "/userinfo",
+		IDTokenAlgs:                 idTokenAlgs,
+		UserInfoAlgs:                idTokenAlgs,
+		Scopes:                      scopes,
+		Claims:                      claims,
+		RequestURIParameter:         false,
+		ResponseTypes:               []string{"code"},
+		Subjects:                    supportedSubjectTypes,
+		GrantTypes:                  grantTypes,
 		AuthMethods: []string{
 			// PKCE is required for auth method "none"
-			"none",
-			"client_secret_basic",
+			tokenEndpointAuthMethodNone,
+			tokenEndpointAuthMethodSecretBasic,
+			tokenEndpointAuthMethodPrivateKeyJWT,
+		},
+		AuthSigningAlgs: supportedAlgs,
+		CodeChallengeMethods: []string{
+			codeChallengeMethodS256,
+			codeChallengeMethodPlain,
 		},
+		IntrospectionEndpoint: issuer + "/introspect",
+		IntrospectionMethods:  []string{tokenEndpointAuthMethodSecretBasic, tokenEndpointAuthMethodPrivateKeyJWT},
+		EndSessionEndpoint:    strings.Replace(issuer, "/v1/", "/ui/vault/", 1) + "/logout",
+		DisplayName:           p.DisplayName,
+		ServiceDocumentation:  p.ServiceDocumentation,
+		OPPolicyURI:           p.OPPolicyURI,
+		OPTOSURI:              p.OPTOSURI,
+	}
+
+	if p.MetadataSigningKey != "" {
+		disc.SignedMetadata, err = i.signProviderMetadata(ctx, s, p, disc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(disc)
+}
+
+// claimsSupported returns the claims that the provider may issue, in sorted
+// order. These are the claims it sets itself, such as auth_time, and the
+// top-level claims of the templates of its supported scopes.
+func (i *IdentityStore) claimsSupported(ctx context.Context, s logical.Storage, p *provider) ([]string, error) {
+	claims := append([]string{}, reservedClaims...)
+	for _, scopeName := range p.ScopesSupported {
+		scope, err := i.getOIDCScope(ctx, s, scopeName)
+		if err != nil {
+			return nil, err
+		}
+		if scope == nil {
+			continue
+		}
+
+		keys, err := scopeClaimKeys(scopeName, scope)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, keys...)
 	}
 
-	data, err := json.Marshal(disc)
+	return strutil.RemoveDuplicates(claims, false), nil
+}
+
+// signProviderMetadata returns the signed_metadata of the discovery document
+// of the provider, which is a JWT signed with the provider's metadata signing
+// key. Its claims are the metadata of the document along with the iss and sub
+// claims of the issuer, as described by
+// https://datatracker.ietf.org/doc/html/rfc8414#section-2.1
+func (i *IdentityStore) signProviderMetadata(ctx context.Context, s logical.Storage, p *provider, disc providerDiscovery) (string, error) {
+	key, err := i.getNamedKey(ctx, s, p.MetadataSigningKey)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	if key == nil {
+		return "", fmt.Errorf("metadata signing key %q not found", p.MetadataSigningKey)
 	}
 
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			logical.HTTPStatusCode:         200,
-			logical.HTTPRawBody:            data,
-			logical.HTTPContentType:        "application/json",
-			logical.HTTPCacheControlHeader: "max-age=3600",
-		},
+	// The claims are decoded from the document itself, so that they always
+	// match its plain metadata
+	disc.SignedMetadata = ""
+	metadata, err := json.Marshal(disc)
+	if err != nil {
+		return "", err
 	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(metadata, &claims); err != nil {
+		return "", err
+	}
+	claims["iss"] = disc.Issuer
+	claims["sub"] = disc.Issuer
+	claims["iat"] = time.Now().Unix()
 
-	return resp, nil
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	return key.signPayload(payload)
 }
 
 // pathOIDCReadProviderPublicKeys is used to retrieve all public keys for a
@@ -1432,9 +3407,33 @@ func (i *IdentityStore) pathOIDCProviderDiscovery(ctx context.Context, req *logi
 func (i *IdentityStore) pathOIDCReadProviderPublicKeys(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	providerName := d.Get("name").(string)
 
+	data, err := i.cachedOIDCDocument(ctx, "providerKeys/"+providerName, func() ([]byte, error) {
+		return i.renderProviderPublicKeys(ctx, req.Storage, providerName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	// Relying parties may cache the keys for as long as the keys of
+	// identity tokens, which is bounded by the rotation and verification
+	// periods of the keys so that they refetch them before a new key signs
+	cacheControl, err := i.getKeysCacheControlHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcDocumentResponse(req, data, cacheControl), nil
+}
+
+// renderProviderPublicKeys returns the JSON web key set of the named
+// provider, or nil if the provider doesn't exist.
+func (i *IdentityStore) renderProviderPublicKeys(ctx context.Context, s logical.Storage, providerName string) ([]byte, error) {
 	var provider provider
 
-	providerEntry, err := req.Storage.Get(ctx, providerPath+providerName)
+	providerEntry, err := s.Get(ctx, providerPath+providerName)
 	if err != nil {
 		return nil, err
 	}
@@ -1445,43 +3444,107 @@ func (i *IdentityStore) pathOIDCReadProviderPublicKeys(ctx context.Context, req
 		return nil, err
 	}
 
-	keyIDs, err := i.keyIDsReferencedByTargetClientIDs(ctx, req.Storage, provider.AllowedClientIDs)
+	now := time.Now()
+	keyIDs, err := i.keyIDsReferencedByTargetClientIDs(ctx, s, provider.AllowedClientIDs, now)
 	if err != nil {
 		return nil, err
 	}
 
-	jwks := &jose.JSONWebKeySet{
-		Keys: make([]jose.JSONWebKey, 0, len(keyIDs)),
-	}
-
-	for _, keyID := range keyIDs {
-		key, err := loadOIDCPublicKey(ctx, req.Storage, keyID)
+	// The signed metadata of the provider must be verifiable with its keys
+	if provider.MetadataSigningKey != "" {
+		key, err := i.getNamedKey(ctx, s, provider.MetadataSigningKey)
 		if err != nil {
 			return nil, err
 		}
-		jwks.Keys = append(jwks.Keys, *key)
+		if key != nil {
+			keyIDs.add(key, now)
+		}
 	}
 
-	data, err := json.Marshal(jwks)
+	jwks, err := keyIDs.load(ctx, s)
 	if err != nil {
 		return nil, err
 	}
 
+	return json.Marshal(jwks)
+}
+
+// flushOIDCProviderDocuments removes the discovery and keys documents of the
+// providers in the namespace of the context from the cache.
+func (i *IdentityStore) flushOIDCProviderDocuments(ctx context.Context) error {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return i.oidcCache.Flush(ns)
+}
+
+// oidcDocumentResponse returns the response serving a rendered document of a
+// provider with its ETag, and the given Cache-Control header if any. The
+// document is left out of a 304 Not Modified response if the request's
+// If-None-Match header matches the ETag, so that relying parties only
+// download it again once it changes.
+func oidcDocumentResponse(req *logical.Request, data []byte, cacheControl string) *logical.Response {
+	etag := documentETag(data)
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			logical.HTTPStatusCode:  200,
+			logical.HTTPStatusCode:  http.StatusOK,
 			logical.HTTPRawBody:     data,
 			logical.HTTPContentType: "application/json",
+			logical.HTTPETagHeader:  etag,
 		},
 	}
+	if cacheControl != "" {
+		resp.Data[logical.HTTPCacheControlHeader] = cacheControl
+	}
+	if etagMatches(req, etag) {
+		resp.Data[logical.HTTPStatusCode] = http.StatusNotModified
+		delete(resp.Data, logical.HTTPRawBody)
+	}
 
-	return resp, nil
+	return resp
+}
+
+// cachedOIDCDocument returns the document cached in the namespace of the
+// context under the given key, rendering and caching it on a miss. A nil
+// document, e.g. of a provider that doesn't exist, is not cached.
+//
+// The cache of the namespace is flushed whenever a provider, client, scope,
+// role or key is written or invalidated. A document rendered from storage
+// before a concurrent flush is not cached.
+func (i *IdentityStore) cachedOIDCDocument(ctx context.Context, key string, render func() ([]byte, error)) ([]byte, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok, err := i.oidcCache.Get(ns, key)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return v.([]byte), nil
+	}
+
+	flushes := i.oidcCache.flushCount()
+	data, err := render()
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	if err := i.oidcCache.SetDefaultIfNotFlushed(flushes, ns, key, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
 }
 
-// keyIDsReferencedByTargetClientIDs returns a slice of key IDs that are
-// referenced by the clients' targetIDs.
+// keyIDsReferencedByTargetClientIDs returns the IDs of the keys that are
+// referenced by the clients' targetIDs and can still verify signatures at the
+// given time.
 // If targetIDs contains "*" then the IDs for all public keys are returned.
-func (i *IdentityStore) keyIDsReferencedByTargetClientIDs(ctx context.Context, s logical.Storage, targetIDs []string) ([]string, error) {
+func (i *IdentityStore) keyIDsReferencedByTargetClientIDs(ctx context.Context, s logical.Storage, targetIDs []string, now time.Time) (jwksKeyIDs, error) {
 	keyNames := make(map[string]bool)
 
 	// Get all key names referenced by clients if wildcard "*" in target client IDs
@@ -1493,25 +3556,31 @@ func (i *IdentityStore) keyIDsReferencedByTargetClientIDs(ctx context.Context, s
 
 		for _, client := range clients {
 			keyNames[client.Key] = true
+			if client.previousKeyVerifies() {
+				keyNames[client.PreviousKey] = true
+			}
 		}
 	}
 
 	// Otherwise, get the key names referenced by each target client ID
 	if len(keyNames) == 0 {
 		for _, clientID := range targetIDs {
-			client, err := i.clientByID(ctx, s, clientID)
+			client, err := i.clientByID(clientID)
 			if err != nil {
 				return nil, err
 			}
 
 			if client != nil {
 				keyNames[client.Key] = true
+				if client.previousKeyVerifies() {
+					keyNames[client.PreviousKey] = true
+				}
 			}
 		}
 	}
 
 	// Collect the key IDs
-	var keyIDs []string
+	keyIDs := make(jwksKeyIDs)
 	for name := range keyNames {
 		entry, err := s.Get(ctx, namedKeyConfigPath+name)
 		if err != nil {
@@ -1526,9 +3595,7 @@ func (i *IdentityStore) keyIDsReferencedByTargetClientIDs(ctx context.Context, s
 		if err := entry.DecodeJSON(&key); err != nil {
 			return nil, err
 		}
-		for _, expirableKey := range key.KeyRing {
-			keyIDs = append(keyIDs, expirableKey.KeyID)
-		}
+		keyIDs.add(&key, now)
 	}
 	return keyIDs, nil
 }
@@ -1556,6 +3623,16 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 		return authResponse("", state, ErrAuthInvalidRequest, "provider not found")
 	}
 
+	// Errors about the client ID and redirect URI are rendered rather than
+	// redirected to the client, so they carry the maintenance message while
+	// the provider is in maintenance
+	renderedError := func(errorCode, errorDescription string) (*logical.Response, error) {
+		if provider.Maintenance {
+			errorDescription = provider.maintenanceMessage()
+		}
+		return authResponse("", state, errorCode, errorDescription)
+	}
+
 	// Validate that a scope parameter is present and contains the openid scope value
 	requestedScopes := strutil.ParseDedupAndSortStrings(d.Get("scope").(string), scopesDelimiter)
 	if len(requestedScopes) == 0 || !strutil.StrListContains(requestedScopes, openIDScope) {
@@ -1583,19 +3660,22 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 	// Validate the client ID
 	clientID := d.Get("client_id").(string)
 	if clientID == "" {
-		return authResponse("", state, ErrAuthInvalidClientID, "client_id parameter is required")
+		return renderedError(ErrAuthInvalidClientID, "client_id parameter is required")
 	}
-	client, err := i.clientByID(ctx, req.Storage, clientID)
+	client, err := i.clientByID(clientID)
 	if err != nil {
 		return authResponse("", state, ErrAuthServerError, err.Error())
 	}
 	if client == nil {
-		return authResponse("", state, ErrAuthInvalidClientID, "client with client_id not found")
+		return renderedError(ErrAuthInvalidClientID, "client with client_id not found")
 	}
 	if !strutil.StrListContains(provider.AllowedClientIDs, "*") &&
 		!strutil.StrListContains(provider.AllowedClientIDs, clientID) {
 		return authResponse("", state, ErrAuthUnauthorizedClient, "client is not authorized to use the provider")
 	}
+	if !client.allowsGrantType("authorization_code") {
+		return authResponse("", state, ErrAuthUnauthorizedClient, "client is not authorized to use the authorization_code grant type")
+	}
 
 	// Validate the redirect URI
 	redirectURI := d.Get("redirect_uri").(string)
@@ -1603,8 +3683,25 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 		return authResponse("", state, ErrAuthInvalidRequest, "redirect_uri parameter is required")
 	}
 
-	if !validRedirect(redirectURI, client.RedirectURIs) {
-		return authResponse("", state, ErrAuthInvalidRedirectURI, "redirect_uri is not allowed for the client")
+	if !validRedirect(redirectURI, client.RedirectURIs, !client.DisallowLoopbackRedirects) {
+		return renderedError(ErrAuthInvalidRedirectURI, "redirect_uri is not allowed for the client")
+	}
+
+	// Client redirect URIs are checked against the allowed redirect hosts
+	// when written, but the provider may have changed since
+	if len(disallowedRedirectURIs([]string{redirectURI}, provider.AllowedRedirectHosts)) > 0 {
+		return renderedError(ErrAuthInvalidRedirectURI, "redirect_uri is not allowed by the provider")
+	}
+
+	// New logins are refused while the provider is in maintenance. The
+	// redirect URI is valid, so the error is redirected to the client.
+	if provider.Maintenance {
+		return authResponse("", state, ErrAuthTemporarilyUnavailable, provider.maintenanceMessage())
+	}
+
+	// Expired clients are refused until their expiry is extended
+	if client.expired(time.Now()) {
+		return authResponse("", state, ErrAuthAccessDenied, "client has expired")
 	}
 
 	// We don't support the request or request_uri parameters. If they're provided,
@@ -1618,6 +3715,15 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 		return authResponse("", state, ErrAuthRequestURINotSupported, "request_uri parameter is not supported")
 	}
 
+	// Validate the optional prompt parameter. The UI prompts the end-user
+	// for consent, and for an account by logging in, before the request
+	// reaches the provider, so only 'none' and 'login' are enforced here.
+	prompt, err := parsePrompt(d.Get("prompt").(string))
+	if err != nil {
+		return authResponse("", state, ErrAuthInvalidRequest, err.Error())
+	}
+	silent := strutil.StrListContains(prompt, promptNone)
+
 	// Validate that there is an identity entity associated with the request
 	if req.EntityID == "" {
 		return authResponse("", state, ErrAuthAccessDenied, "identity entity must be associated with the request")
@@ -1639,6 +3745,13 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 		return authResponse("", state, ErrAuthAccessDenied, "identity entity not authorized by client assignment")
 	}
 
+	// The default scopes of the provider are granted along with the
+	// requested scopes
+	scopes, err = i.grantedScopes(ctx, req.Storage, provider, scopes)
+	if err != nil {
+		return authResponse("", state, ErrAuthServerError, err.Error())
+	}
+
 	// A nonce is optional for the authorization code flow. If not
 	// provided, the nonce claim will be omitted from the ID token.
 	nonce := d.Get("nonce").(string)
@@ -1651,6 +3764,10 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 		redirectURI: redirectURI,
 		nonce:       nonce,
 		scopes:      scopes,
+
+		// A refresh token is requested with the offline_access scope,
+		// which needs no scope template
+		offlineAccess: strutil.StrListContains(requestedScopes, offlineAccessScope),
 	}
 
 	// Validate the Proof Key for Code Exchange (PKCE) code challenge and code challenge
@@ -1688,26 +3805,35 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 	// of the user should occur. Re-authentication will be requested if the last time
 	// the token actively authenticated exceeds the given max_age requirement. Returning
 	// ErrAuthMaxAgeReAuthenticate will enforce the user to re-authenticate via the user agent.
-	if maxAgeRaw, ok := d.GetOk("max_age"); ok {
-		maxAge := maxAgeRaw.(int)
-		if maxAge < 1 {
-			return authResponse("", state, ErrAuthInvalidRequest, "max_age must be greater than zero")
-		}
-
-		// Look up the token associated with the request
-		te, err := i.tokenStorer.LookupToken(ctx, req.ClientToken)
+	maxAgeRaw, okMaxAge := d.GetOk("max_age")
+	if okMaxAge && maxAgeRaw.(int) < 1 {
+		return authResponse("", state, ErrAuthInvalidRequest, "max_age must be greater than zero")
+	}
+	reAuthenticate := strutil.StrListContains(prompt, promptLogin)
+	if okMaxAge || reAuthenticate || client.RequireAuthTime {
+		// Look up the token associated with the request. Its creation time
+		// is the time the end-user last actively authenticated.
+		te, err := i.requestTokenEntry(ctx, req)
 		if err != nil {
 			return authResponse("", state, ErrAuthServerError, err.Error())
 		}
 		if te == nil {
 			return authResponse("", state, ErrAuthAccessDenied, "token associated with request not found")
 		}
-
-		// Check if the token creation time violates the max age requirement
-		now := time.Now().UTC()
 		lastAuthTime := time.Unix(te.CreationTime, 0).UTC()
-		secondsSince := int(now.Sub(lastAuthTime).Seconds())
-		if secondsSince > maxAge {
+
+		// The existing token is never reused for prompt=login. The UI logs
+		// the end-user out and repeats the request without prompt instead.
+		if reAuthenticate {
+			return authResponse("", state, ErrAuthMaxAgeReAuthenticate, "active re-authentication is required by prompt=login")
+		}
+
+		// Check if the token creation time violates the max age requirement.
+		// The end-user can't be re-authenticated for prompt=none.
+		if okMaxAge && int(time.Now().UTC().Sub(lastAuthTime).Seconds()) > maxAgeRaw.(int) {
+			if silent {
+				return authResponse("", state, ErrAuthLoginRequired, "active re-authentication is required by max_age")
+			}
 			return authResponse("", state, ErrAuthMaxAgeReAuthenticate, "active re-authentication is required by max_age")
 		}
 
@@ -1734,38 +3860,24 @@ func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Requ
 //   - https://openid.net/specs/openid-connect-core-1_0.html#AuthResponse
 //   - https://openid.net/specs/openid-connect-core-1_0.html#AuthError
 func authResponse(code, state, errorCode, errorDescription string) (*logical.Response, error) {
-	statusCode := http.StatusOK
-	response := map[string]interface{}{
-		"code":  code,
-		"state": state,
-	}
-
-	// Set the error response and status code if error code isn't empty
 	if errorCode != "" {
-		statusCode = http.StatusBadRequest
-		if errorCode == ErrAuthServerError {
+		statusCode := http.StatusBadRequest
+		switch errorCode {
+		case ErrAuthServerError:
 			statusCode = http.StatusInternalServerError
+		case ErrAuthTemporarilyUnavailable:
+			statusCode = http.StatusServiceUnavailable
 		}
 
-		response = map[string]interface{}{
-			"error":             errorCode,
-			"error_description": errorDescription,
-			"state":             state,
-		}
-	}
-
-	body, err := json.Marshal(response)
-	if err != nil {
-		return nil, err
+		return oidcProviderError(errorCode, errorDescription, statusCode, map[string]interface{}{
+			"state": state,
+		})
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			logical.HTTPStatusCode:  statusCode,
-			logical.HTTPRawBody:     body,
-			logical.HTTPContentType: "application/json",
-		},
-	}, nil
+	return oidcProviderResponse(http.StatusOK, map[string]interface{}{
+		"code":  code,
+		"state": state,
+	})
 }
 
 func (i *IdentityStore) pathOIDCToken(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -1785,30 +3897,43 @@ func (i *IdentityStore) pathOIDCToken(ctx context.Context, req *logical.Request,
 		return tokenResponse(nil, ErrTokenInvalidRequest, "provider not found")
 	}
 
-	// Get the client ID
-	clientID, clientSecret, okBasicAuth := basicAuth(req)
-	if !okBasicAuth {
-		clientID = d.Get("client_id").(string)
-		if clientID == "" {
-			return tokenResponse(nil, ErrTokenInvalidRequest, "client_id parameter is required")
-		}
-	}
-	client, err := i.clientByID(ctx, req.Storage, clientID)
+	// Authenticate the client with its authentication method. Details at
+	// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+	client, errCode, errDescription, err := i.authenticateClient(ctx, req, d, ns, provider)
 	if err != nil {
 		return tokenResponse(nil, ErrTokenServerError, err.Error())
 	}
-	if client == nil {
-		i.Logger().Debug("client failed to authenticate with client not found", "client_id", clientID)
-		return tokenResponse(nil, ErrTokenInvalidClient, "client failed to authenticate")
+	if errCode != "" {
+		return tokenResponse(nil, errCode, errDescription)
+	}
+	clientID := client.ClientID
+
+	// Expired clients are refused until their expiry is extended
+	if client.expired(time.Now()) {
+		i.Logger().Debug("refused token request of expired client", "client_id", clientID)
+		return tokenResponse(nil, ErrTokenInvalidClient, "client has expired")
 	}
 
-	// Authenticate the client using the client_secret_basic authentication method if it's a
-	// confidential client. The authentication method uses the HTTP Basic authentication scheme.
-	// Details at https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
-	if client.Type == confidential &&
-		subtle.ConstantTimeCompare([]byte(client.ClientSecret), []byte(clientSecret)) == 0 {
-		i.Logger().Debug("client failed to authenticate with invalid client secret", "client_id", clientID)
-		return tokenResponse(nil, ErrTokenInvalidClient, "client failed to authenticate")
+	// Validate that the request comes from the allowed CIDR blocks of the
+	// client, unless it's a refresh the client exempts from them. The remote
+	// address is the one resolved by the listener, which honors its
+	// X-Forwarded-For configuration. Refused addresses are audited.
+	if len(client.TokenEndpointAllowedCIDRs) > 0 &&
+		!(client.TokenEndpointCIDRsSkipRefresh && d.Get("grant_type").(string) == "refresh_token") {
+		var remoteAddr string
+		if req.Connection != nil {
+			remoteAddr = req.Connection.RemoteAddr
+		}
+		if ok, err := cidrutil.IPBelongsToCIDRBlocksSlice(remoteAddr, client.TokenEndpointAllowedCIDRs); !ok {
+			i.Logger().Warn("refused token request from outside the allowed CIDR blocks of the client",
+				"client_id", clientID, "remote_address", remoteAddr, "error", err)
+			resp, err := tokenResponse(nil, ErrTokenInvalidClient, "client is not allowed to make token requests from this address")
+			if err != nil {
+				return nil, err
+			}
+			resp.Data[oidcProviderAuditRefusedRemoteAddress] = remoteAddr
+			return resp, nil
+		}
 	}
 
 	// Validate that the client is authorized to use the provider
@@ -1817,6 +3942,11 @@ func (i *IdentityStore) pathOIDCToken(ctx context.Context, req *logical.Request,
 		return tokenResponse(nil, ErrTokenInvalidClient, "client is not authorized to use the provider")
 	}
 
+	// New logins are refused while the provider is in maintenance
+	if provider.Maintenance {
+		return tokenResponse(nil, ErrTokenTemporarilyUnavailable, provider.maintenanceMessage())
+	}
+
 	// Get the key that the client uses to sign ID tokens
 	key, err := i.getNamedKey(ctx, req.Storage, client.Key)
 	if err != nil {
@@ -1837,7 +3967,23 @@ func (i *IdentityStore) pathOIDCToken(ctx context.Context, req *logical.Request,
 	if grantType == "" {
 		return tokenResponse(nil, ErrTokenInvalidRequest, "grant_type parameter is required")
 	}
-	if grantType != "authorization_code" {
+	if !strutil.StrListContains(supportedClientGrantTypes, grantType) {
+		return tokenResponse(nil, ErrTokenUnsupportedGrantType, "unsupported grant_type value")
+	}
+	if !client.allowsGrantType(grantType) {
+		return tokenResponse(nil, ErrTokenUnauthorizedClient, "client is not authorized to use the grant_type")
+	}
+	switch grantType {
+	case "authorization_code":
+	case "password":
+		return i.oidcPasswordGrant(ctx, req, d, ns, provider, client, key)
+	case "refresh_token":
+		return i.oidcRefreshTokenGrant(ctx, req, d, ns, provider, client, key)
+	case deviceCodeGrantType:
+		return i.oidcDeviceCodeGrant(ctx, req, d, ns, provider, client, key)
+	case clientCredentialsGrantType:
+		return i.oidcClientCredentialsGrant(ctx, req, d, ns, provider, client, key)
+	default:
 		return tokenResponse(nil, ErrTokenUnsupportedGrantType, "unsupported grant_type value")
 	}
 
@@ -1921,6 +4067,52 @@ func (i *IdentityStore) pathOIDCToken(ctx context.Context, req *logical.Request,
 		}
 	}
 
+	return i.issueOIDCTokens(ctx, req, ns, provider, client, key, entity, tokenGrant{
+		scopes:        authCodeEntry.scopes,
+		nonce:         authCodeEntry.nonce,
+		authTime:      authCodeEntry.authTime,
+		code:          code,
+		offlineAccess: authCodeEntry.offlineAccess,
+	})
+}
+
+// tokenGrant contains the parameters of an authorization grant that are
+// carried into the tokens issued for it.
+type tokenGrant struct {
+	scopes      []string
+	nonce       string
+	authTime    time.Time
+	code        string
+	authMethods []string
+
+	// offlineAccess requests a refresh token, which is issued if the
+	// client has a refresh_token_ttl
+	offlineAccess bool
+
+	// loginAt is the time of the login whose tokens are refreshed, or the
+	// zero time if the grant isn't a refresh
+	loginAt time.Time
+
+	// clientCredentials marks tokens issued to the client itself, which
+	// have no end-user, and omitIDToken omits their ID token
+	clientCredentials bool
+	omitIDToken       bool
+}
+
+// issueOIDCTokens issues an access token and an ID token for the entity to
+// the client, and returns them in the OIDC Token Response. The tokens are
+// issued for the client itself if the entity is nil.
+func (i *IdentityStore) issueOIDCTokens(ctx context.Context, req *logical.Request, ns *namespace.Namespace, provider *provider, client *client, key *namedKey, entity *identity.Entity, grant tokenGrant) (*logical.Response, error) {
+	subject, entityID := client.ClientID, ""
+	if entity != nil {
+		var err error
+		entityID = entity.ID
+		subject, err = i.subjectForClient(ctx, req.Storage, provider.name, client, entity.ID)
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
+	}
+
 	// The access token is a Vault batch token with a policy that only
 	// provides access to the issuing provider's userinfo endpoint.
 	accessTokenIssuedAt := time.Now()
@@ -1931,222 +4123,518 @@ func (i *IdentityStore) pathOIDCToken(ctx context.Context, req *logical.Request,
 		Path:               req.Path,
 		TTL:                client.AccessTokenTTL,
 		CreationTime:       accessTokenIssuedAt.Unix(),
-		EntityID:           entity.ID,
+		EntityID:           entityID,
 		NoIdentityPolicies: true,
 		Meta: map[string]string{
 			"oidc_token_type": "access token",
 		},
 		InternalMeta: map[string]string{
 			accessTokenClientIDMeta: client.ClientID,
-			accessTokenScopesMeta:   strings.Join(authCodeEntry.scopes, scopesDelimiter),
+			accessTokenScopesMeta:   strings.Join(grant.scopes, scopesDelimiter),
+			accessTokenProviderMeta: provider.name,
 		},
 		InlinePolicy: fmt.Sprintf(`
 			path "identity/oidc/provider/%s/userinfo" {
 				capabilities = ["read", "update"]
 			}
-		`, name),
+		`, provider.name),
 	}
-	err = i.tokenStorer.CreateToken(ctx, accessToken)
+	if grant.clientCredentials {
+		accessToken.InternalMeta[accessTokenGrantTypeMeta] = clientCredentialsGrantType
+	}
+	trackingID, issued, err := trackIssuedAccessToken(accessToken, client, accessTokenIssuedAt, accessTokenExpiry)
 	if err != nil {
 		return tokenResponse(nil, ErrTokenServerError, err.Error())
 	}
-
-	// Compute the access token hash claim (at_hash)
-	atHash, err := computeHashClaim(key.Algorithm, accessToken.ID)
+	err = i.tokenStorer.CreateToken(ctx, accessToken)
 	if err != nil {
 		return tokenResponse(nil, ErrTokenServerError, err.Error())
 	}
 
-	// Compute the authorization code hash claim (c_hash)
-	cHash, err := computeHashClaim(key.Algorithm, code)
-	if err != nil {
+	// The access token is only valid once its client and entity are
+	// recorded, so that it can be revoked with them
+	if err := putIssuedAccessToken(ctx, req.Storage, provider.name, trackingID, issued); err != nil {
 		return tokenResponse(nil, ErrTokenServerError, err.Error())
 	}
 
-	// Set the ID token claims
+	// The ID token is omitted for grants without an end-user that didn't
+	// request the openid scope
 	idTokenIssuedAt := time.Now()
-	idTokenExpiry := idTokenIssuedAt.Add(client.IDTokenTTL)
-	idToken := idToken{
-		Namespace:       ns.ID,
-		Issuer:          provider.effectiveIssuer,
-		Subject:         authCodeEntry.entityID,
-		Audience:        authCodeEntry.clientID,
-		Nonce:           authCodeEntry.nonce,
-		Expiry:          idTokenExpiry.Unix(),
-		IssuedAt:        idTokenIssuedAt.Unix(),
-		AccessTokenHash: atHash,
-		CodeHash:        cHash,
-	}
-
-	// Add the auth_time claim if it's not the zero time instant
-	if !authCodeEntry.authTime.IsZero() {
-		idToken.AuthTime = authCodeEntry.authTime.Unix()
-	}
-
-	// Populate each of the requested scope templates
-	templates, conflict, err := i.populateScopeTemplates(ctx, req.Storage, ns, entity, authCodeEntry.scopes...)
-	if !conflict && err != nil {
-		return tokenResponse(nil, ErrTokenServerError, err.Error())
-	}
-	if conflict && err != nil {
-		return tokenResponse(nil, ErrTokenInvalidRequest, err.Error())
-	}
+	var signedIDToken string
+	if !grant.omitIDToken {
+		// Compute the access token hash claim (at_hash)
+		atHash, err := computeHashClaim(key.Algorithm, accessToken.ID)
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
 
-	// Generate the ID token payload
-	payload, err := idToken.generatePayload(i.Logger(), templates...)
-	if err != nil {
-		return tokenResponse(nil, ErrTokenServerError, err.Error())
-	}
+		// Compute the authorization code hash claim (c_hash) if the tokens
+		// are issued for an authorization code
+		var cHash string
+		if grant.code != "" {
+			cHash, err = computeHashClaim(key.Algorithm, grant.code)
+			if err != nil {
+				return tokenResponse(nil, ErrTokenServerError, err.Error())
+			}
+		}
 
-	// Sign the ID token using the client's key
-	signedIDToken, err := key.signPayload(payload)
-	if err != nil {
-		return tokenResponse(nil, ErrTokenServerError, err.Error())
-	}
+		// Set the ID token claims
+		idTokenExpiry := idTokenIssuedAt.Add(client.IDTokenTTL)
+		idToken := idToken{
+			Namespace:       ns.ID,
+			Issuer:          provider.effectiveIssuer,
+			Subject:         subject,
+			Audience:        client.ClientID,
+			Nonce:           grant.nonce,
+			Expiry:          idTokenExpiry.Unix(),
+			IssuedAt:        idTokenIssuedAt.Unix(),
+			AccessTokenHash: atHash,
+			CodeHash:        cHash,
+			AuthMethods:     grant.authMethods,
+		}
 
-	return tokenResponse(map[string]interface{}{
-		"token_type":   "Bearer",
-		"access_token": accessToken.ID,
-		"id_token":     signedIDToken,
-		"expires_in":   int64(accessTokenExpiry.Sub(accessTokenIssuedAt).Seconds()),
-	}, "", "")
-}
+		// Add the auth_time claim if it's not the zero time instant
+		if !grant.authTime.IsZero() {
+			idToken.AuthTime = grant.authTime.Unix()
+		}
 
-// tokenResponse returns the OIDC Token Response. An error response is
-// returned if the given error code is non-empty. For details, see spec at
-//   - https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
-//   - https://openid.net/specs/openid-connect-core-1_0.html#TokenErrorResponse
-func tokenResponse(response map[string]interface{}, errorCode, errorDescription string) (*logical.Response, error) {
-	statusCode := http.StatusOK
+		// Populate each of the requested scope templates
+		templates, conflict, err := i.populateScopeTemplates(ctx, req.Storage, ns, entity, scopeTemplateParams{
+			clientID: client.ClientID,
+			provider: provider.name,
+		}, grant.scopes...)
+		if !conflict && err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
+		if conflict && err != nil {
+			return tokenResponse(nil, ErrTokenInvalidRequest, err.Error())
+		}
 
-	// Set the error response and status code if error code isn't empty
-	if errorCode != "" {
-		switch errorCode {
-		case ErrTokenInvalidClient:
-			statusCode = http.StatusUnauthorized
-		case ErrTokenServerError:
-			statusCode = http.StatusInternalServerError
-		default:
-			statusCode = http.StatusBadRequest
+		// Generate the ID token payload
+		payload, err := idToken.generatePayload(i.Logger(), templates...)
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
 		}
 
-		response = map[string]interface{}{
-			"error":             errorCode,
-			"error_description": errorDescription,
+		// Sign the ID token using the client's key
+		signedIDToken, err = key.signPayload(payload)
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
 		}
 	}
 
-	body, err := json.Marshal(response)
-	if err != nil {
-		return nil, err
+	// Issue a refresh token if requested
+	var issuedRefreshToken string
+	if grant.offlineAccess {
+		issuedRefreshToken, err = issueRefreshToken(ctx, req.Storage, provider, client, entityID, grant, idTokenIssuedAt)
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
 	}
 
-	data := map[string]interface{}{
-		logical.HTTPStatusCode:  statusCode,
-		logical.HTTPRawBody:     body,
-		logical.HTTPContentType: "application/json",
+	i.recordClientTokenIssued(client, idTokenIssuedAt)
 
-		// Token responses must include the following HTTP response headers
-		// https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
-		logical.HTTPCacheControlHeader: "no-store",
-		logical.HTTPPragmaHeader:       "no-cache",
+	grantedScopes := append([]string{openIDScope}, grant.scopes...)
+	if grant.omitIDToken {
+		grantedScopes = append([]string{}, grant.scopes...)
 	}
-
+	if issuedRefreshToken != "" && !strutil.StrListContains(grantedScopes, offlineAccessScope) {
+		grantedScopes = append(grantedScopes, offlineAccessScope)
+	}
+	response := map[string]interface{}{
+		"token_type":   "Bearer",
+		"access_token": accessToken.ID,
+		"expires_in":   int64(accessTokenExpiry.Sub(accessTokenIssuedAt).Seconds()),
+		"scope":        strings.Join(grantedScopes, scopesDelimiter),
+	}
+	if signedIDToken != "" {
+		response["id_token"] = signedIDToken
+	}
+	if issuedRefreshToken != "" {
+		response["refresh_token"] = issuedRefreshToken
+	}
+	return tokenResponse(response, "", "")
+}
+
+// oidcPasswordGrant handles the resource owner password credentials grant,
+// which logs the resource owner in with the client's password grant mount
+// and issues tokens for its entity as the authorization code flow would.
+// See https://datatracker.ietf.org/doc/html/rfc6749#section-4.3
+func (i *IdentityStore) oidcPasswordGrant(ctx context.Context, req *logical.Request, d *framework.FieldData, ns *namespace.Namespace, provider *provider, client *client, key *namedKey) (*logical.Response, error) {
+	if client.PasswordGrantMount == "" {
+		return tokenResponse(nil, ErrTokenUnauthorizedClient, "client is not authorized to use the password grant type")
+	}
+
+	username := d.Get("username").(string)
+	if username == "" {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "username parameter is required")
+	}
+	password := d.Get("password").(string)
+	if password == "" {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "password parameter is required")
+	}
+
+	// Validate that the scope parameter contains the openid scope value and
+	// ignore scope values that are not supported by the provider
+	requestedScopes := strutil.ParseDedupAndSortStrings(d.Get("scope").(string), scopesDelimiter)
+	if !strutil.StrListContains(requestedScopes, openIDScope) {
+		return tokenResponse(nil, ErrTokenInvalidRequest,
+			fmt.Sprintf("scope parameter must contain the %q value", openIDScope))
+	}
+	scopes := make([]string, 0)
+	for _, scope := range requestedScopes {
+		if strutil.StrListContains(provider.ScopesSupported, scope) && scope != openIDScope {
+			scopes = append(scopes, scope)
+		}
+	}
+	scopes, err := i.grantedScopes(ctx, req.Storage, provider, scopes)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+
+	loginPath := credentialRoutePrefix + client.PasswordGrantMount + "login/" + username
+	mountEntry := i.router.MatchingMountEntry(ctx, loginPath)
+	if mountEntry == nil || mountEntry.Table != credentialTableType {
+		return tokenResponse(nil, ErrTokenServerError,
+			fmt.Sprintf("password grant mount %q of the client not found", client.PasswordGrantMount))
+	}
+
+	allowed, err := i.applyPasswordGrantQuota(ctx, req, ns, loginPath)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if !allowed {
+		resp, err := tokenResponse(nil, ErrTokenTemporarilyUnavailable,
+			fmt.Sprintf("request path %q: %s", loginPath, quotas.ErrRateLimitQuotaExceeded))
+		if err != nil {
+			return nil, err
+		}
+		resp.Data[logical.HTTPStatusCode] = http.StatusTooManyRequests
+		return resp, nil
+	}
+
+	// Log in with the auth method of the mount, which checks the credentials
+	// along with its own restrictions on the login. No Vault token is created
+	// for the login.
+	loginResp, err := i.passwordGrantLogin(ctx, req, mountEntry, loginPath, password)
+	switch {
+	case err != nil:
+		i.Logger().Error("failed to audit the login of the password grant", "path", loginPath, "error", err)
+		return tokenResponse(nil, ErrTokenServerError, "failed to audit the login of the resource owner")
+	case loginResp == nil || loginResp.IsError() || loginResp.Auth == nil || loginResp.Auth.Alias == nil:
+		if loginResp != nil && loginResp.IsError() {
+			i.Logger().Debug("resource owner failed to log in", "client_id", client.ClientID, "mount", client.PasswordGrantMount, "error", loginResp.Error())
+		}
+		return tokenResponse(nil, ErrTokenInvalidGrant, "resource owner credentials are invalid")
+	}
+
+	// Fetch the entity of the alias, or create one if it doesn't exist, as
+	// logins to the mount do
+	alias := loginResp.Auth.Alias
+	alias.MountType = mountEntry.Type
+	alias.MountAccessor = mountEntry.Accessor
+	alias.Local = mountEntry.Local
+	entity, _, err := i.CreateOrFetchEntity(ctx, alias)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if entity == nil {
+		return tokenResponse(nil, ErrTokenServerError, "failed to create an entity for the resource owner")
+	}
+	if entity.Disabled {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity of the resource owner is disabled")
+	}
+	if _, err := i.refreshExternalGroupMembershipsByEntityID(ctx, entity.ID, loginResp.Auth.GroupAliases, mountEntry.Accessor); err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+
+	// The password grant has no way of completing login MFA, so resource
+	// owners that are required to use it must use the authorization code flow
+	mfaConfigs, err := i.mfaBackend.Core.buildMFAEnforcementConfigList(ctx, entity, loginPath)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if len(mfaConfigs) > 0 {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "login MFA is required for the resource owner, which the password grant type does not support")
+	}
+
+	// Validate that the entity is a member of the client's assignments
+	isMember, err := i.entityHasAssignment(ctx, req.Storage, entity, client.Assignments)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if !isMember {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "identity entity not authorized by client assignment")
+	}
+
+	return i.issueOIDCTokens(ctx, req, ns, provider, client, key, entity, tokenGrant{
+		scopes:        scopes,
+		authTime:      time.Now(),
+		authMethods:   []string{"pwd"},
+		offlineAccess: strutil.StrListContains(requestedScopes, offlineAccessScope),
+	})
+}
+
+// applyPasswordGrantQuota applies the rate limit quotas of the password
+// grant mount to the login of a password grant, as they are applied to
+// logins to the mount through the HTTP API. It returns false if the login is
+// rejected.
+func (i *IdentityStore) applyPasswordGrantQuota(ctx context.Context, req *logical.Request, ns *namespace.Namespace, loginPath string) (bool, error) {
+	// Quotas are kept by client address, which requests that don't come
+	// through the HTTP API don't have
+	if req.Connection == nil || req.Connection.RemoteAddr == "" {
+		return true, nil
+	}
+
+	core := i.mfaBackend.Core
+	quotaResp, err := core.ApplyRateLimitQuota(ctx, &quotas.Request{
+		Path:          loginPath,
+		MountPath:     strings.TrimPrefix(i.router.MatchingMount(ctx, loginPath), ns.Path),
+		NamespacePath: ns.Path,
+		ClientAddress: req.Connection.RemoteAddr,
+	})
+	if err != nil {
+		return false, err
+	}
+	if !quotaResp.Allowed && i.Logger().IsTrace() {
+		i.Logger().Trace("password grant rejected due to rate limit quota violation", "request_path", loginPath)
+	}
+	return quotaResp.Allowed, nil
+}
+
+// passwordGrantLogin routes the login of a password grant to its mount and
+// returns its response, which is an error response if the login failed. The
+// login is audited with the ID of the token request, as core audits logins,
+// and an error is only returned if it couldn't be.
+func (i *IdentityStore) passwordGrantLogin(ctx context.Context, req *logical.Request, mountEntry *MountEntry, loginPath, password string) (*logical.Response, error) {
+	loginReq := &logical.Request{
+		ID:              req.ID,
+		Operation:       logical.UpdateOperation,
+		Path:            loginPath,
+		Connection:      req.Connection,
+		MountType:       mountEntry.Type,
+		MountAccessor:   mountEntry.Accessor,
+		Unauthenticated: true,
+		Data: map[string]interface{}{
+			"password": password,
+		},
+	}
+
+	var nonHMACReqDataKeys, nonHMACRespDataKeys []string
+	if rawVals, ok := mountEntry.synthesizedConfigCache.Load("audit_non_hmac_request_keys"); ok {
+		nonHMACReqDataKeys = rawVals.([]string)
+	}
+	if rawVals, ok := mountEntry.synthesizedConfigCache.Load("audit_non_hmac_response_keys"); ok {
+		nonHMACRespDataKeys = rawVals.([]string)
+	}
+
+	auditor := i.mfaBackend.Core.AuditLogger()
+	if err := auditor.AuditRequest(ctx, &logical.LogInput{
+		Request:            loginReq,
+		NonHMACReqDataKeys: nonHMACReqDataKeys,
+	}); err != nil {
+		return nil, err
+	}
+
+	loginResp, loginErr := i.router.Route(ctx, loginReq)
+
+	logInput := &logical.LogInput{
+		Request:             loginReq,
+		Response:            loginResp,
+		OuterErr:            loginErr,
+		NonHMACReqDataKeys:  nonHMACReqDataKeys,
+		NonHMACRespDataKeys: nonHMACRespDataKeys,
+	}
+	if loginResp != nil {
+		logInput.Auth = loginResp.Auth
+	}
+	if err := auditor.AuditResponse(ctx, logInput); err != nil {
+		return nil, err
+	}
+
+	if loginErr != nil {
+		return logical.ErrorResponse(loginErr.Error()), nil
+	}
+	return loginResp, nil
+}
+
+// tokenResponse returns the OIDC Token Response. An error response is
+// returned if the given error code is non-empty. For details, see spec at
+//   - https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
+//   - https://openid.net/specs/openid-connect-core-1_0.html#TokenErrorResponse
+func tokenResponse(response map[string]interface{}, errorCode, errorDescription string) (*logical.Response, error) {
+	var resp *logical.Response
+	var err error
+	if errorCode != "" {
+		statusCode := http.StatusBadRequest
+		switch errorCode {
+		case ErrTokenInvalidClient:
+			statusCode = http.StatusUnauthorized
+		case ErrTokenServerError:
+			statusCode = http.StatusInternalServerError
+		case ErrTokenTemporarilyUnavailable:
+			statusCode = http.StatusServiceUnavailable
+		}
+		resp, err = oidcProviderError(errorCode, errorDescription, statusCode, nil)
+	} else {
+		resp, err = oidcProviderResponse(http.StatusOK, response)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Token responses must include the following HTTP response headers
+	// https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
+	resp.Data[logical.HTTPCacheControlHeader] = "no-store"
+	resp.Data[logical.HTTPPragmaHeader] = "no-cache"
+
 	// Set the WWW-Authenticate response header when returning the
-	// invalid_client error code per the OAuth 2.0 spec at
-	// https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+	// invalid_client error code, which always has the 401 status code
+	// since clients may authenticate with the HTTP Basic authentication
+	// scheme. See https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
 	if errorCode == ErrTokenInvalidClient {
-		data[logical.HTTPWWWAuthenticateHeader] = "Basic"
+		resp.Data[logical.HTTPWWWAuthenticateHeader] = "Basic"
 	}
 
-	return &logical.Response{
-		Data: data,
-	}, nil
+	return resp, nil
+}
+
+// accessTokenIssuedByProvider returns true if the access token was issued by
+// the named provider of the given namespace.
+func accessTokenIssuedByProvider(te *logical.TokenEntry, ns *namespace.Namespace, name string) bool {
+	if te.NamespaceID != ns.ID || te.Path != "oidc/provider/"+name+"/token" {
+		return false
+	}
+	if provider, ok := te.InternalMeta[accessTokenProviderMeta]; ok && provider != name {
+		return false
+	}
+	return true
 }
 
 func (i *IdentityStore) pathOIDCUserInfo(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	// Get the namespace
 	ns, err := namespace.FromContext(ctx)
 	if err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+		return userInfoError("", ErrUserInfoServerError, err.Error())
 	}
 
 	// Get the OIDC provider
 	name := d.Get("name").(string)
 	provider, err := i.getOIDCProvider(ctx, req.Storage, name)
 	if err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+		return userInfoError("", ErrUserInfoServerError, err.Error())
 	}
 	if provider == nil {
-		return userInfoResponse(nil, ErrUserInfoInvalidRequest, "provider not found")
+		return userInfoError("", ErrUserInfoInvalidRequest, "provider not found")
 	}
 
-	// Validate that the access token was sent as a Bearer token
-	if req.ClientTokenSource != logical.ClientTokenFromAuthzHeader {
-		return userInfoResponse(nil, ErrUserInfoInvalidToken, "access token must be sent as a Bearer token")
+	// Errors about the access token are returned with the issuer of the
+	// provider as the realm of the WWW-Authenticate header
+	realm := provider.effectiveIssuer
+
+	// Validate that the access token was sent as a Bearer token. The endpoint
+	// is unauthenticated so that requests without a valid access token get
+	// the error responses of RFC 6750 rather than those of Vault.
+	if req.ClientToken == "" || req.ClientTokenSource != logical.ClientTokenFromAuthzHeader {
+		return userInfoError(realm, "", "access token must be sent as a Bearer token")
 	}
 
 	// Look up the access token
-	te, err := i.tokenStorer.LookupToken(ctx, req.ClientToken)
+	te, err := i.requestTokenEntry(ctx, req)
 	if err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
 	}
 	if te == nil {
-		return userInfoResponse(nil, ErrUserInfoInvalidToken, "access token is expired")
+		return userInfoError(realm, ErrUserInfoInvalidToken, "access token is expired or invalid")
 	}
 	if te.Type != logical.TokenTypeBatch {
-		return userInfoResponse(nil, ErrUserInfoInvalidToken, "access token is malformed or invalid")
+		return userInfoError(realm, ErrUserInfoInvalidToken, "access token is malformed or invalid")
+	}
+
+	// Validate that the access token was issued by the provider, so that
+	// the token of a provider can't be used to get the claims of the scopes
+	// of another provider. Access tokens issued before the provider was
+	// recorded with them are bound by their path only.
+	if !accessTokenIssuedByProvider(te, ns, name) {
+		return userInfoError(realm, ErrUserInfoInvalidToken, "access token was not issued by the provider")
+	}
+
+	// Access tokens revoked by the provider are refused
+	revoked, err := accessTokenRevoked(ctx, req.Storage, name, te)
+	if err != nil {
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
+	}
+	if revoked {
+		return userInfoError(realm, ErrUserInfoInvalidToken, "access token has been revoked")
 	}
 
 	// Get the client ID that originated the request from the token metadata
 	clientID, ok := te.InternalMeta[accessTokenClientIDMeta]
 	if !ok {
-		return userInfoResponse(nil, ErrUserInfoServerError, "expected client ID in token metadata")
+		return userInfoError(realm, ErrUserInfoInvalidToken, "access token is malformed or invalid")
 	}
-	client, err := i.clientByID(ctx, req.Storage, clientID)
+	client, err := i.clientByID(clientID)
 	if err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
 	}
 	if client == nil {
-		return userInfoResponse(nil, ErrUserInfoAccessDenied, "client not found")
+		return userInfoError(realm, ErrUserInfoInvalidToken, "client of the access token not found")
 	}
 
-	// Validate that there is an identity entity associated with the request
-	if req.EntityID == "" {
-		return userInfoResponse(nil, ErrUserInfoAccessDenied, "identity entity must be associated with the request")
+	// Access tokens outlive the expiry of their client unless the client
+	// revokes them on expiry
+	if client.RevokeTokensOnExpiry && client.expired(time.Now()) {
+		return userInfoError(realm, ErrUserInfoInvalidToken, "client of the access token has expired")
 	}
-	entity, err := i.MemDBEntityByID(req.EntityID, false)
+
+	// Validate that the client is authorized to use the provider
+	if !strutil.StrListContains(provider.AllowedClientIDs, "*") &&
+		!strutil.StrListContains(provider.AllowedClientIDs, clientID) {
+		return userInfoError(realm, ErrUserInfoInvalidToken, "client is not authorized to use the provider")
+	}
+
+	// Access tokens of the client_credentials grant have no end-user to
+	// return the claims of
+	if te.InternalMeta[accessTokenGrantTypeMeta] == clientCredentialsGrantType {
+		return userInfoError(realm, ErrUserInfoInsufficientScope, "access tokens of the client_credentials grant have no end-user")
+	}
+
+	// Validate that there is an enabled identity entity associated with the
+	// access token
+	if te.EntityID == "" {
+		return userInfoError(realm, ErrUserInfoInvalidToken, "identity entity must be associated with the access token")
+	}
+	entity, err := i.MemDBEntityByID(te.EntityID, false)
 	if err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
 	}
-	if entity == nil {
-		return userInfoResponse(nil, ErrUserInfoAccessDenied, "identity entity associated with the request not found")
+	if entity == nil || entity.Disabled {
+		return userInfoError(realm, ErrUserInfoInvalidToken, "identity entity associated with the access token not found or disabled")
 	}
 
 	// Validate that the entity is a member of the client's assignments
 	isMember, err := i.entityHasAssignment(ctx, req.Storage, entity, client.Assignments)
 	if err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
 	}
 	if !isMember {
-		return userInfoResponse(nil, ErrUserInfoAccessDenied, "identity entity not authorized by client assignment")
+		return userInfoError(realm, ErrUserInfoInsufficientScope, "identity entity not authorized by client assignment")
 	}
 
-	// Validate that the client is authorized to use the provider
-	if !strutil.StrListContains(provider.AllowedClientIDs, "*") &&
-		!strutil.StrListContains(provider.AllowedClientIDs, clientID) {
-		return userInfoResponse(nil, ErrUserInfoAccessDenied, "client is not authorized to use the provider")
+	// The subject claim must always be in the response, and must be the
+	// subject of the ID tokens of the client
+	subject, err := i.subjectForClient(ctx, req.Storage, name, client, entity.ID)
+	if err != nil {
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
 	}
-
 	claims := map[string]interface{}{
-		// The subject claim must always be in the response
-		"sub": entity.ID,
+		"sub": subject,
 	}
 
 	// Get the scopes for the access token
 	tokenScopes, ok := te.InternalMeta[accessTokenScopesMeta]
 	if !ok || len(tokenScopes) == 0 {
-		return userInfoResponse(claims, "", "")
+		return i.clientUserInfoResponse(ctx, req.Storage, realm, provider, client, claims)
 	}
 	parsedScopes := strutil.ParseStringSlice(tokenScopes, scopesDelimiter)
 
@@ -2159,155 +4647,650 @@ func (i *IdentityStore) pathOIDCUserInfo(ctx context.Context, req *logical.Reque
 	}
 
 	// Populate each of the token's scope templates
-	templates, conflict, err := i.populateScopeTemplates(ctx, req.Storage, ns, entity, scopes...)
+	populated, conflict, err := i.populateScopeClaims(ctx, req.Storage, ns, entity, scopeTemplateParams{
+		clientID: clientID,
+		provider: name,
+	}, oidcScopeTemplateWorkers, scopes...)
 	if !conflict && err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
 	}
 	if conflict && err != nil {
-		return userInfoResponse(nil, ErrUserInfoInvalidRequest, err.Error())
+		return userInfoError(realm, ErrUserInfoInvalidRequest, err.Error())
 	}
 
-	// Merge all of the populated JSON scope templates into claims
-	if err := mergeJSONTemplates(i.Logger(), claims, templates...); err != nil {
-		return userInfoResponse(nil, ErrUserInfoServerError, err.Error())
+	// Merge the claims of the populated scope templates, which are parsed
+	// once when they are populated
+	for _, p := range populated {
+		mergeClaims(i.Logger(), claims, p.populated, p.parsed)
 	}
 
-	return userInfoResponse(claims, "", "")
+	return i.clientUserInfoResponse(ctx, req.Storage, realm, provider, client, claims)
 }
 
-// userInfoResponse returns the OIDC UserInfo Response. An error response is
-// returned if the given error code is non-empty. For details, see spec at
-//   - https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+// requestTokenEntry returns the entry of the client token of the request.
+// The entry looked up when the request was routed is used if it is set.
+func (i *IdentityStore) requestTokenEntry(ctx context.Context, req *logical.Request) (*logical.TokenEntry, error) {
+	if te := req.TokenEntry(); te != nil {
+		return te, nil
+	}
+
+	return i.tokenStorer.LookupToken(ctx, req.ClientToken)
+}
+
+// userInfoResponse returns the OIDC UserInfo Response with the given claims.
+// See https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+func userInfoResponse(claims map[string]interface{}) (*logical.Response, error) {
+	return oidcProviderResponse(http.StatusOK, claims)
+}
+
+// clientUserInfoResponse returns the OIDC UserInfo Response of the client
+// with the given claims. It's a JWT signed with the client's key, like its
+// ID tokens, if the client sets userinfo_signed_response_alg, and plain JSON
+// otherwise.
+func (i *IdentityStore) clientUserInfoResponse(ctx context.Context, s logical.Storage, realm string, p *provider, c *client, claims map[string]interface{}) (*logical.Response, error) {
+	if c.UserInfoSignedResponseAlg == "" {
+		return userInfoResponse(claims)
+	}
+
+	key, err := i.getNamedKey(ctx, s, c.Key)
+	if err != nil {
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
+	}
+	if key == nil {
+		return userInfoError(realm, ErrUserInfoServerError, fmt.Sprintf("client key %q not found", c.Key))
+	}
+	if key.Algorithm != c.UserInfoSignedResponseAlg {
+		return userInfoError(realm, ErrUserInfoServerError, fmt.Sprintf("client key %q has the algorithm %q rather than the userinfo_signed_response_alg %q", c.Key, key.Algorithm, c.UserInfoSignedResponseAlg))
+	}
+
+	// Signed responses must contain the iss and aud claims. Both are
+	// reserved, so scope templates can't set them.
+	claims["iss"] = p.effectiveIssuer
+	claims["aud"] = c.ClientID
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
+	}
+	signed, err := key.signPayload(payload)
+	if err != nil {
+		return userInfoError(realm, ErrUserInfoServerError, err.Error())
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawBody:     []byte(signed),
+			logical.HTTPContentType: "application/jwt",
+		},
+	}, nil
+}
+
+// userInfoError returns the OIDC UserInfo Error Response with the given error
+// code, which are the error responses of RFC 6750. The WWW-Authenticate header
+// has the given realm, if any, and the error code and description, unless the
+// error code is empty for requests without an access token. For details, see
 //   - https://openid.net/specs/openid-connect-core-1_0.html#UserInfoError
-func userInfoResponse(response map[string]interface{}, errorCode, errorDescription string) (*logical.Response, error) {
-	statusCode := http.StatusOK
+//   - https://datatracker.ietf.org/doc/html/rfc6750#section-3
+func userInfoError(realm, errorCode, errorDescription string) (*logical.Response, error) {
+	statusCode := http.StatusBadRequest
+	bodyErrorCode := errorCode
+	switch errorCode {
+	case "":
+		// Requests without authentication information must not be told of
+		// an error code in the header, but the body of the error response
+		// always has one
+		statusCode = http.StatusUnauthorized
+		bodyErrorCode = ErrUserInfoInvalidToken
+	case ErrUserInfoInvalidToken:
+		statusCode = http.StatusUnauthorized
+	case ErrUserInfoInsufficientScope:
+		statusCode = http.StatusForbidden
+	case ErrUserInfoServerError:
+		statusCode = http.StatusInternalServerError
+	}
+	resp, err := oidcProviderError(bodyErrorCode, errorDescription, statusCode, nil)
+	if err != nil {
+		return nil, err
+	}
+	if errorCode == ErrUserInfoServerError {
+		return resp, nil
+	}
+
+	var params []string
+	if realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", realm))
+	}
+	if errorCode != "" {
+		params = append(params, fmt.Sprintf("error=%q", errorCode),
+			fmt.Sprintf("error_description=%q", errorDescription))
+	}
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+	resp.Data[logical.HTTPWWWAuthenticateHeader] = challenge
+
+	return resp, nil
+}
+
+// oidcProviderError returns the OAuth 2.0 error response of a provider
+// endpoint with the given error code, description and status code. The body
+// can have additional members, such as the state of authorization requests.
+// See https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+func oidcProviderError(errorCode, errorDescription string, statusCode int, members map[string]interface{}) (*logical.Response, error) {
+	body := map[string]interface{}{
+		"error":             errorCode,
+		"error_description": errorDescription,
+	}
+	for k, v := range members {
+		body[k] = v
+	}
+
+	return oidcProviderResponse(statusCode, body)
+}
+
+// oidcProviderResponse returns a response of a provider endpoint with the
+// given status code and JSON body, which isn't wrapped in the response
+// format of Vault.
+func oidcProviderResponse(statusCode int, body map[string]interface{}) (*logical.Response, error) {
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  statusCode,
+			logical.HTTPRawBody:     rawBody,
+			logical.HTTPContentType: "application/json",
+		},
+	}, nil
+}
+
+// withOIDCClientCORS wraps the handler of a provider endpoint that browsers
+// may call cross-origin. The Origin of a request is echoed in the
+// Access-Control-Allow-Origin header of the response only if it's an allowed
+// origin of the client that requestClientID resolves from the request.
+func (i *IdentityStore) withOIDCClientCORS(handler framework.OperationFunc, requestClientID func(context.Context, *logical.Request, *framework.FieldData) string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		resp, err := handler(ctx, req, d)
+		origin := requestOrigin(req)
+		if err != nil || resp == nil || resp.Data == nil || origin == "" {
+			return resp, err
+		}
+
+		clientID := requestClientID(ctx, req, d)
+		if clientID == "" {
+			return resp, nil
+		}
+		client, err := i.clientByID(clientID)
+		if err != nil {
+			return nil, err
+		}
+		if client != nil && originAllowed(origin, client.AllowedOrigins) {
+			resp.Data[logical.HTTPAccessControlAllowOrigin] = origin
+		}
+
+		return resp, nil
+	}
+}
+
+// tokenRequestClientID returns the client ID of a token request, from its
+// basic authorization header, its client assertion or its client_id parameter.
+func tokenRequestClientID(_ context.Context, req *logical.Request, d *framework.FieldData) string {
+	if clientID, _, ok, err := basicAuth(req); ok && err == nil {
+		return clientID
+	}
+	if assertion := d.Get("client_assertion").(string); assertion != "" {
+		clientID, _ := clientAssertionSubject(assertion)
+		return clientID
+	}
+	return d.Get("client_id").(string)
+}
+
+// userInfoRequestClientID returns the ID of the client that the access token
+// of a userinfo request was issued to.
+func (i *IdentityStore) userInfoRequestClientID(ctx context.Context, req *logical.Request, _ *framework.FieldData) string {
+	if req.ClientToken == "" || req.ClientTokenSource != logical.ClientTokenFromAuthzHeader {
+		return ""
+	}
+	te, err := i.requestTokenEntry(ctx, req)
+	if err != nil || te == nil {
+		return ""
+	}
+	return te.InternalMeta[accessTokenClientIDMeta]
+}
+
+// pathOIDCPreflight returns the handler of CORS preflight requests to a
+// provider endpoint that allows the given methods. Preflight requests carry
+// neither the client ID nor the access token of the actual request, so any
+// allowed origin of a client allowed to use the provider passes them. The
+// actual request is then only allowed for the origins of its own client.
+func (i *IdentityStore) pathOIDCPreflight(methods string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPStatusCode: http.StatusNoContent,
+			},
+		}
+
+		origin := requestOrigin(req)
+		if origin == "" {
+			return resp, nil
+		}
+
+		provider, err := i.getOIDCProvider(ctx, req.Storage, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil {
+			return resp, nil
+		}
+
+		clients, err := i.clientsAllowedByIDs(ctx, req.Storage, provider.AllowedClientIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, client := range clients {
+			if originAllowed(origin, client.AllowedOrigins) {
+				resp.Data[logical.HTTPAccessControlAllowOrigin] = origin
+				resp.Data[logical.HTTPAccessControlAllowMethods] = methods
+				resp.Data[logical.HTTPAccessControlAllowHeaders] = "Authorization,Content-Type"
+				resp.Data[logical.HTTPAccessControlMaxAge] = "300"
+				break
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// scopeTemplate is the template of a scope
+type scopeTemplate struct {
+	scope    string
+	template string
+}
+
+// scopeClaimKeys returns the top-level claim keys of the template of the
+// scope, which don't depend on the entity it is populated with.
+func scopeClaimKeys(name string, scope *scope) ([]string, error) {
+	_, populatedTemplate, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+		Mode:   identitytpl.JSONTemplating,
+		String: scope.Template,
+		Entity: new(logical.Entity),
+		Groups: make([]*logical.Group, 0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template for scope %q: %s", name, err.Error())
+	}
+
+	jsonTemplate := make(map[string]interface{})
+	if err = json.Unmarshal([]byte(populatedTemplate), &jsonTemplate); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(jsonTemplate))
+	for key := range jsonTemplate {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// grantedScopes returns the supported scopes of an authorization request
+// along with the default scopes of the provider, without duplicates and in
+// sorted order. A default scope is not granted if the provider no longer
+// supports it, or if its template has a top-level claim of the template of
+// another granted scope, so that explicitly requested scopes are populated
+// as they would be without default scopes.
+func (i *IdentityStore) grantedScopes(ctx context.Context, s logical.Storage, p *provider, requested []string) ([]string, error) {
+	if len(p.DefaultScopes) == 0 {
+		return requested, nil
+	}
+
+	scopeKeys := func(scopeName string) ([]string, error) {
+		scope, err := i.getOIDCScope(ctx, s, scopeName)
+		if err != nil || scope == nil {
+			return nil, err
+		}
+		return scopeClaimKeys(scopeName, scope)
+	}
+
+	claimKeys := make(map[string]bool)
+	for _, scopeName := range requested {
+		keys, err := scopeKeys(scopeName)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			claimKeys[key] = true
+		}
+	}
+
+	scopes := append([]string(nil), requested...)
+DEFAULTS:
+	for _, scopeName := range p.DefaultScopes {
+		if strutil.StrListContains(scopes, scopeName) ||
+			!strutil.StrListContains(p.ScopesSupported, scopeName) {
+			continue
+		}
+
+		keys, err := scopeKeys(scopeName)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if claimKeys[key] {
+				continue DEFAULTS
+			}
+		}
+		for _, key := range keys {
+			claimKeys[key] = true
+		}
+		scopes = append(scopes, scopeName)
+	}
+
+	return strutil.RemoveDuplicates(scopes, false), nil
+}
+
+// getScopeTemplates returns the templates of the given scopes in the same
+// order, skipping the openid scope and scopes that don't exist.
+func (i *IdentityStore) getScopeTemplates(ctx context.Context, s logical.Storage, scopes ...string) ([]scopeTemplate, error) {
+	templates := make([]scopeTemplate, 0, len(scopes))
+	seen := make(map[string]struct{}, len(scopes))
+	for _, name := range scopes {
+		if name == openIDScope {
+			// No template for the openid scope
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		// Get the scope template
+		scope, err := i.getOIDCScope(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if scope == nil {
+			// Scope values used that are not understood by an implementation should be ignored.
+			// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+			continue
+		}
+		templates = append(templates, scopeTemplate{
+			scope:    name,
+			template: scope.Template,
+		})
+	}
+
+	return templates, nil
+}
+
+// scopeTemplateParams are the client and provider of the provider request
+// that scope templates are populated for, which templates reference as
+// {{identity.oidc.client_id}} and {{identity.oidc.provider}}.
+type scopeTemplateParams struct {
+	clientID string
+	provider string
+}
+
+// claimsCacheKey returns the key of the template of the scope populated with
+// the params. Templates that don't reference the params are shared by every
+// client and provider.
+func (params scopeTemplateParams) claimsCacheKey(namespaceID, scope, template string) string {
+	key := oidcClaimsCacheKey(namespaceID, scope, template)
+	if strings.Contains(template, "identity.oidc.") {
+		key += ":" + params.clientID + ":" + params.provider
+	}
+	return key
+}
+
+// populateScopeTemplates populates the templates for each of the passed scopes.
+// Returns a slice of the populated JSON template strings and a bool to indicate
+// if a conflict in scope template claims occurred.
+//
+// Populated templates are served from the claims cache, which is invalidated
+// as soon as the entity, its aliases or a group changes. Claims are therefore
+// as fresh as the identity of this node, and the cache TTL only bounds the
+// staleness that a missed invalidation could cause.
+func (i *IdentityStore) populateScopeTemplates(ctx context.Context, s logical.Storage, ns *namespace.Namespace, entity *identity.Entity, params scopeTemplateParams, scopes ...string) ([]string, bool, error) {
+	return i.populateScopeTemplatesWithWorkers(ctx, s, ns, entity, params, oidcScopeTemplateWorkers, scopes...)
+}
+
+// populateScopeTemplatesWithWorkers is populateScopeTemplates with at most
+// the given number of templates populated concurrently.
+func (i *IdentityStore) populateScopeTemplatesWithWorkers(ctx context.Context, s logical.Storage, ns *namespace.Namespace, entity *identity.Entity, params scopeTemplateParams, workers int, scopes ...string) ([]string, bool, error) {
+	populated, conflict, err := i.populateScopeClaims(ctx, s, ns, entity, params, workers, scopes...)
+	if err != nil {
+		return nil, conflict, err
+	}
+
+	templates := make([]string, 0, len(populated))
+	for _, p := range populated {
+		templates = append(templates, p.populated)
+	}
+
+	return templates, false, nil
+}
+
+// populateScopeClaims populates the templates of the given scopes with at
+// most the given number of templates populated concurrently, and returns the
+// populated templates that aren't empty. The returned templates are shared
+// with the claims cache and must not be modified.
+//
+// The identity of the entity is read once and shared by every template. The
+// populated templates are returned in the order of the scopes, regardless of
+// the order in which they were populated. A top-level claim defined by more
+// than one scope is a conflict, and the returned error lists every
+// conflicting claim in the order of the scopes that define it first.
+func (i *IdentityStore) populateScopeClaims(ctx context.Context, s logical.Storage, ns *namespace.Namespace, entity *identity.Entity, params scopeTemplateParams, workers int, scopes ...string) ([]*populatedScopeTemplate, bool, error) {
+	// Gather the templates for each scope
+	templates, err := i.getScopeTemplates(ctx, s, scopes...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Templates that aren't cached are populated with the entity and groups
+	// read after the generation of the cache, so that they aren't cached if
+	// the entity or a group changes while they are populated
+	generation := i.oidcClaimsCache.currentGeneration()
+
+	nsLabels := []metrics.Label{metricsutil.NamespaceLabel(ns)}
+	results := make([]*populatedScopeTemplate, len(templates))
+	var misses []int
+	for idx, t := range templates {
+		// Claims of tokens without an entity aren't cached
+		if entity == nil {
+			misses = append(misses, idx)
+			continue
+		}
+		cached, ok := i.oidcClaimsCache.get(entity.ID, params.claimsCacheKey(ns.ID, t.scope, t.template))
+		if ok {
+			results[idx] = cached.(*populatedScopeTemplate)
+			i.metrics.IncrCounterWithLabels([]string{"identity", "oidc", "claims_cache", "hit"}, 1, nsLabels)
+			continue
+		}
+		i.metrics.IncrCounterWithLabels([]string{"identity", "oidc", "claims_cache", "miss"}, 1, nsLabels)
+		misses = append(misses, idx)
+	}
 
-	// Set the error response and status code if error code isn't empty
-	if errorCode != "" {
-		switch errorCode {
-		case ErrUserInfoInvalidRequest:
-			statusCode = http.StatusBadRequest
-		case ErrUserInfoInvalidToken:
-			statusCode = http.StatusUnauthorized
-		case ErrUserInfoAccessDenied:
-			statusCode = http.StatusForbidden
-		case ErrUserInfoServerError:
-			statusCode = http.StatusInternalServerError
+	if len(misses) > 0 {
+		var sdkEntity *logical.Entity
+		var sdkGroups []*logical.Group
+		if entity != nil {
+			current, groups, err := i.scopeTemplateIdentity(entity)
+			if err != nil {
+				return nil, false, err
+			}
+			sdkEntity = identity.ToSDKEntity(current)
+			sdkGroups = identity.ToSDKGroups(groups)
 		}
 
-		response = map[string]interface{}{
-			"error":             errorCode,
-			"error_description": errorDescription,
+		populate := func(idx int) {
+			results[idx] = i.populateScopeTemplate(ns, params, sdkEntity, sdkGroups, templates[idx].scope, templates[idx].template)
 		}
-	}
 
-	body, err := json.Marshal(response)
-	if err != nil {
-		return nil, err
-	}
+		if workers <= 1 || len(misses) == 1 {
+			for _, idx := range misses {
+				populate(idx)
+			}
+		} else {
+			if workers > len(misses) {
+				workers = len(misses)
+			}
 
-	data := map[string]interface{}{
-		logical.HTTPStatusCode:  statusCode,
-		logical.HTTPRawBody:     body,
-		logical.HTTPContentType: "application/json",
-	}
+			work := make(chan int)
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for idx := range work {
+						populate(idx)
+					}
+				}()
+			}
+			for _, idx := range misses {
+				work <- idx
+			}
+			close(work)
+			wg.Wait()
+		}
 
-	// Set the WWW-Authenticate response header when returning error codes
-	// defined in https://datatracker.ietf.org/doc/html/rfc6750#section-3
-	if errorCode == ErrUserInfoInvalidRequest || errorCode == ErrUserInfoInvalidToken {
-		data[logical.HTTPWWWAuthenticateHeader] = fmt.Sprintf("Bearer error=%q,error_description=%q",
-			errorCode, errorDescription)
+		if entity != nil {
+			for _, idx := range misses {
+				key := params.claimsCacheKey(ns.ID, templates[idx].scope, templates[idx].template)
+				i.oidcClaimsCache.set(generation, entity.ID, key, results[idx])
+			}
+		}
 	}
 
-	return &logical.Response{
-		Data: data,
-	}, nil
-}
-
-// getScopeTemplates returns a mapping from scope names to
-// their templates for each of the given scopes.
-func (i *IdentityStore) getScopeTemplates(ctx context.Context, s logical.Storage, scopes ...string) (map[string]string, error) {
-	templates := make(map[string]string)
-	for _, name := range scopes {
-		if name == openIDScope {
-			// No template for the openid scope
-			continue
+	// Merge the populated templates in the order of the scopes, and check
+	// top-level claim keys for conflicts with other scopes
+	claimsToScopes := make(map[string][]string)
+	var claims []string
+	populatedTemplates := make([]*populatedScopeTemplate, 0, len(results))
+	for idx, populated := range results {
+		for _, claimKey := range populated.claims {
+			if _, ok := claimsToScopes[claimKey]; !ok {
+				claims = append(claims, claimKey)
+			}
+			claimsToScopes[claimKey] = append(claimsToScopes[claimKey], templates[idx].scope)
 		}
 
-		// Get the scope template
-		scope, err := i.getOIDCScope(ctx, s, name)
-		if err != nil {
-			return nil, err
+		if populated.populated != "" {
+			populatedTemplates = append(populatedTemplates, populated)
 		}
-		if scope == nil {
-			// Scope values used that are not understood by an implementation should be ignored.
-			// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+	}
+
+	var conflicts []string
+	for _, claimKey := range claims {
+		claimScopes := claimsToScopes[claimKey]
+		if len(claimScopes) < 2 {
 			continue
 		}
-		templates[name] = scope.Template
+		quoted := make([]string, 0, len(claimScopes))
+		for _, scope := range claimScopes {
+			quoted = append(quoted, strconv.Quote(scope))
+		}
+		conflicts = append(conflicts, fmt.Sprintf("claim %q in scopes %s", claimKey, strings.Join(quoted, ", ")))
+	}
+	if len(conflicts) > 0 {
+		return nil, true, fmt.Errorf("found scopes with conflicting top-level claim: %s", strings.Join(conflicts, "; "))
 	}
 
-	return templates, nil
+	return populatedTemplates, false, nil
 }
 
-// populateScopeTemplates populates the templates for each of the passed scopes.
-// Returns a slice of the populated JSON template strings and a bool to indicate
-// if a conflict in scope template claims occurred.
-func (i *IdentityStore) populateScopeTemplates(ctx context.Context, s logical.Storage, ns *namespace.Namespace, entity *identity.Entity, scopes ...string) ([]string, bool, error) {
-	// Gather the templates for each scope
-	templates, err := i.getScopeTemplates(ctx, s, scopes...)
+// scopeTemplateIdentity returns the current version of the entity in memdb
+// and its direct and inherited groups. The given entity is returned if it
+// was deleted from memdb.
+func (i *IdentityStore) scopeTemplateIdentity(entity *identity.Entity) (*identity.Entity, []*identity.Group, error) {
+	current, err := i.MemDBEntityByID(entity.ID, false)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
+	}
+	if current != nil {
+		entity = current
 	}
 
-	// Get the groups for the entity
 	groups, inheritedGroups, err := i.groupsByEntityID(entity.ID)
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
-	groups = append(groups, inheritedGroups...)
-
-	claimsToScopes := make(map[string]string)
-	populatedTemplates := make([]string, 0)
-	for scope, template := range templates {
-		// Parse and integrate the populated template. Structural errors with the template
-		// should be caught during configuration. Errors found during runtime will be logged.
-		_, populatedTemplate, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
-			Mode:        identitytpl.JSONTemplating,
-			String:      template,
-			Entity:      identity.ToSDKEntity(entity),
-			Groups:      identity.ToSDKGroups(groups),
-			NamespaceID: ns.ID,
-		})
-		if err != nil {
-			i.Logger().Warn("error populating OIDC token template", "scope", scope,
-				"template", template, "error", err)
-		}
 
-		if populatedTemplate != "" {
-			claimsMap := make(map[string]interface{})
-			if err := json.Unmarshal([]byte(populatedTemplate), &claimsMap); err != nil {
-				i.Logger().Warn("error parsing OIDC template", "template", template, "err", err)
-			}
+	return entity, append(groups, inheritedGroups...), nil
+}
 
-			// Check top-level claim keys for conflicts with other scopes
-			for claimKey := range claimsMap {
-				if conflictScope, ok := claimsToScopes[claimKey]; ok {
-					return nil, true, fmt.Errorf("found scopes with conflicting top-level claim: claim %q in scopes %q, %q",
-						claimKey, scope, conflictScope)
-				}
-				claimsToScopes[claimKey] = scope
-			}
+// populateScopeTemplate populates the template of a scope for the entity.
+// Structural errors with the template should be caught during configuration,
+// so errors found at runtime are logged and result in an empty template.
+//
+// It is called concurrently for the scopes of a request, and must not modify
+// the entity or groups.
+func (i *IdentityStore) populateScopeTemplate(ns *namespace.Namespace, params scopeTemplateParams, entity *logical.Entity, groups []*logical.Group, scope, template string) *populatedScopeTemplate {
+	result := &populatedScopeTemplate{}
+
+	_, populatedTemplate, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+		Mode:         identitytpl.JSONTemplating,
+		String:       template,
+		Entity:       entity,
+		Groups:       groups,
+		NamespaceID:  ns.ID,
+		OIDCClientID: params.clientID,
+		OIDCProvider: params.provider,
+	})
+	if err != nil {
+		i.Logger().Warn("error populating OIDC token template", "scope", scope,
+			"template", template, "error", err)
+		result.warning = fmt.Sprintf("error populating template of scope %q: %s", scope, err)
+	}
+	if populatedTemplate == "" {
+		return result
+	}
 
-			populatedTemplates = append(populatedTemplates, populatedTemplate)
+	// Omit the claims of the scope rather than the whole response if the
+	// template didn't populate to a JSON object
+	var claimsMap map[string]interface{}
+	if err := json.Unmarshal([]byte(populatedTemplate), &claimsMap); err != nil || claimsMap == nil {
+		if err == nil {
+			err = errors.New("template must be a JSON object")
 		}
+		i.Logger().Warn("error parsing populated OIDC token template, omitting its claims", "scope", scope,
+			"template", template, "error", err)
+		result.warning = fmt.Sprintf("template of scope %q populated invalid JSON, its claims are omitted: %s", scope, err)
+		return result
 	}
 
-	return populatedTemplates, false, nil
+	// Remove the claims omitted by the omitempty filter, so that the ID token
+	// and the userinfo endpoint, which use the populated and parsed template
+	// respectively, return the same claims
+	if identitytpl.RemoveOmittedValues(claimsMap) {
+		encoded, err := json.Marshal(claimsMap)
+		if err != nil {
+			i.Logger().Warn("error encoding populated OIDC token template, omitting its claims", "scope", scope,
+				"template", template, "error", err)
+			result.warning = fmt.Sprintf("error encoding template of scope %q, its claims are omitted: %s", scope, err)
+			return result
+		}
+		populatedTemplate = string(encoded)
+	}
+	result.populated = populatedTemplate
+	for claimKey := range claimsMap {
+		result.claims = append(result.claims, claimKey)
+	}
+	sort.Strings(result.claims)
+	result.parsed = claimsMap
+
+	return result
 }
 
 // entityHasAssignment returns true if the entity is enabled and a member of any
 // of the assignments' groups or entities. Otherwise, returns false or an error.
+//
+// Results are served from the assignment cache, which is invalidated as soon
+// as the entity, a group or an assignment changes.
 func (i *IdentityStore) entityHasAssignment(ctx context.Context, s logical.Storage, entity *identity.Entity, assignments []string) (bool, error) {
 	if entity.GetDisabled() {
 		return false, nil
@@ -2317,39 +5300,90 @@ func (i *IdentityStore) entityHasAssignment(ctx context.Context, s logical.Stora
 		return true, nil
 	}
 
-	// Get the group IDs that the entity is a member of
-	groups, inheritedGroups, err := i.groupsByEntityID(entity.GetID())
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	nsLabels := []metrics.Label{metricsutil.NamespaceLabel(ns)}
+	key := oidcAssignmentCacheKey(ns.ID, assignments)
+	if cached, ok := i.oidcAssignmentCache.get(entity.GetID(), key); ok {
+		i.metrics.IncrCounterWithLabels([]string{"identity", "oidc", "assignment_cache", "hit"}, 1, nsLabels)
+		return cached.(bool), nil
+	}
+	i.metrics.IncrCounterWithLabels([]string{"identity", "oidc", "assignment_cache", "miss"}, 1, nsLabels)
+
+	// The groups and assignments are read after the generation of the cache,
+	// so that the result isn't cached if they change during the evaluation
+	generation := i.oidcAssignmentCache.currentGeneration()
+	hasAssignment, nextChange, err := i.evaluateAssignments(ctx, s, entity, assignments, time.Now())
 	if err != nil {
 		return false, err
 	}
+	i.oidcAssignmentCache.setUntil(generation, entity.GetID(), key, hasAssignment, nextChange)
+
+	return hasAssignment, nil
+}
+
+// evaluateAssignments returns true if the entity is a member of any of the
+// assignments' groups or entities whose time bounds contain the given time.
+// It also returns the earliest time after which a time bound may change the
+// result, or the zero time if none may.
+//
+// Membership of a group is transitive: an entity that is a member of a group,
+// including an external group it was added to by a login, is a member of
+// every group that has the group as a member group, at any depth.
+func (i *IdentityStore) evaluateAssignments(ctx context.Context, s logical.Storage, entity *identity.Entity, assignments []string, now time.Time) (bool, time.Time, error) {
+	// Get the group IDs that the entity is a member of, directly or through
+	// nested groups. The closures of groups are memoized and walk cycles
+	// once, so deep hierarchies are resolved once until they change.
+	groups, inheritedGroups, err := i.groupsByEntityID(entity.GetID())
+	if err != nil {
+		return false, time.Time{}, err
+	}
 	entityGroupIDs := make(map[string]bool)
 	for _, group := range append(groups, inheritedGroups...) {
 		entityGroupIDs[group.GetID()] = true
 	}
 
+	var nextChange time.Time
 	for _, a := range assignments {
 		assignment, err := i.getOIDCAssignment(ctx, s, a)
 		if err != nil {
-			return false, err
+			return false, time.Time{}, err
 		}
 		if assignment == nil {
-			return false, fmt.Errorf("client assignment %q not found", a)
+			return false, time.Time{}, fmt.Errorf("client assignment %q not found", a)
+		}
+
+		nextChange = earliestTime(nextChange, assignment.nextChange(now))
+		if !assignment.contains(now) {
+			continue
 		}
 
 		// Check if the entity is a member of any groups in the assignment
 		for _, id := range assignment.GroupIDs {
-			if entityGroupIDs[id] {
-				return true, nil
+			if !entityGroupIDs[id] {
+				continue
+			}
+			bounds := assignment.GroupTimeBounds[id]
+			nextChange = earliestTime(nextChange, bounds.nextChange(now))
+			if bounds.contains(now) {
+				return true, nextChange, nil
 			}
 		}
 
 		// Check if the entity is a member of the assignment's entities
 		if strutil.StrListContains(assignment.EntityIDs, entity.GetID()) {
-			return true, nil
+			bounds := assignment.EntityTimeBounds[entity.GetID()]
+			nextChange = earliestTime(nextChange, bounds.nextChange(now))
+			if bounds.contains(now) {
+				return true, nextChange, nil
+			}
 		}
 	}
 
-	return false, nil
+	return false, nextChange, nil
 }
 
 func defaultOIDCProvider() provider {
@@ -2395,8 +5429,35 @@ func (i *IdentityStore) storeOIDCDefaultResources(ctx context.Context, view logi
 	}
 
 	// Store the default key
-	storageKey = namedKeyConfigPath + defaultKeyName
-	entry, err = view.Get(ctx, storageKey)
+	if err := i.storeOIDCDefaultKey(ctx, view); err != nil {
+		return err
+	}
+
+	// Store the allow all assignment
+	assignmentEntry, err := oidcAssignmentStore.get(ctx, view, allowAllAssignmentName)
+	if err != nil {
+		return err
+	}
+	if assignmentEntry == nil {
+		if _, err := oidcAssignmentStore.put(ctx, view, allowAllAssignmentName, allowAllAssignment()); err != nil {
+			return err
+		}
+		i.Logger().Debug("wrote OIDC allow_all assignment")
+	}
+
+	return nil
+}
+
+// storeOIDCDefaultKey stores the default key if it doesn't exist. The lock is
+// held while its public keys and then the key itself are written, so that the
+// expiration of public keys can't delete the former before the latter is
+// stored.
+func (i *IdentityStore) storeOIDCDefaultKey(ctx context.Context, view logical.Storage) error {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	storageKey := namedKeyConfigPath + defaultKeyName
+	entry, err := view.Get(ctx, storageKey)
 	if err != nil {
 		return err
 	}
@@ -2404,11 +5465,11 @@ func (i *IdentityStore) storeOIDCDefaultResources(ctx context.Context, view logi
 		defaultKey := defaultOIDCKey()
 
 		// Generate initial key material for current and next keys
-		err = defaultKey.generateAndSetKey(ctx, i.Logger(), view)
+		err = defaultKey.generateAndSetKey(ctx, i.Logger(), view, i.oidcKeySource)
 		if err != nil {
 			return err
 		}
-		err = defaultKey.generateAndSetNextKey(ctx, i.Logger(), view)
+		err = defaultKey.generateAndSetNextKey(ctx, i.Logger(), view, i.oidcKeySource)
 		if err != nil {
 			return err
 		}
@@ -2424,49 +5485,25 @@ func (i *IdentityStore) storeOIDCDefaultResources(ctx context.Context, view logi
 		i.Logger().Debug("wrote OIDC default key")
 	}
 
-	// Store the allow all assignment
-	storageKey = assignmentPath + allowAllAssignmentName
-	entry, err = view.Get(ctx, storageKey)
-	if err != nil {
-		return err
-	}
-	if entry == nil {
-		entry, err := logical.StorageEntryJSON(storageKey, allowAllAssignment())
-		if err != nil {
-			return err
-		}
-		if err := view.Put(ctx, entry); err != nil {
-			return err
-		}
-		i.Logger().Debug("wrote OIDC allow_all assignment")
-	}
-
 	return nil
 }
 
 func (i *IdentityStore) loadOIDCClients(ctx context.Context) error {
 	i.logger.Debug("identity loading OIDC clients")
 
-	clients, err := i.view.List(ctx, clientPath)
+	entries, err := oidcClientStore.entries(ctx, i.view)
 	if err != nil {
 		return err
 	}
 
 	txn := i.db.Txn(true)
 	defer txn.Abort()
-	for _, name := range clients {
-		entry, err := i.view.Get(ctx, clientPath+name)
-		if err != nil {
-			return err
-		}
-		if entry == nil {
-			continue
-		}
-
+	for _, entry := range entries {
 		var client client
 		if err := entry.DecodeJSON(&client); err != nil {
 			return err
 		}
+		client.BucketKey = entry.BucketKey
 
 		if err := i.memDBUpsertClientInTxn(txn, &client); err != nil {
 			return err
@@ -2477,36 +5514,12 @@ func (i *IdentityStore) loadOIDCClients(ctx context.Context) error {
 	return nil
 }
 
-// clientByID returns the client with the given ID.
-func (i *IdentityStore) clientByID(ctx context.Context, s logical.Storage, id string) (*client, error) {
-	// Read the client from memdb
-	client, err := i.memDBClientByID(id)
-	if err != nil {
-		return nil, err
-	}
-	if client != nil {
-		return client, nil
-	}
-
-	// Fall back to reading the client from storage
-	client, err = i.storageClientByID(ctx, s, id)
-	if err != nil {
-		return nil, err
-	}
-	if client == nil {
-		return nil, nil
-	}
-
-	// Upsert the client in memdb
-	txn := i.db.Txn(true)
-	defer txn.Abort()
-	if err := i.memDBUpsertClientInTxn(txn, client); err != nil {
-		i.logger.Debug("failed to upsert client in memdb", "error", err)
-		return client, nil
-	}
-	txn.Commit()
-
-	return client, nil
+// clientByID returns the client with the given ID. Clients are indexed by ID
+// in memdb, which is loaded on startup and kept up to date by client writes
+// and invalidations, so that resolving client IDs in the authorization and
+// token requests doesn't need to read every client from storage.
+func (i *IdentityStore) clientByID(id string) (*client, error) {
+	return i.memDBClientByID(id)
 }
 
 // clientByName returns the client with the given name.
@@ -2620,6 +5633,87 @@ func (i *IdentityStore) memDBClientByNameInTxn(ctx context.Context, txn *memdb.T
 	return client, nil
 }
 
+// memDBClients returns the clients of the namespace in the context from
+// memdb, sorted by name.
+func (i *IdentityStore) memDBClients(ctx context.Context) ([]*client, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := i.db.Txn(false)
+
+	// The name index sorts the clients of the namespace by name
+	iter, err := txn.Get(oidcClientsTable, "name_prefix", ns.ID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch clients from memdb: %w", err)
+	}
+
+	var clients []*client
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		client, ok := raw.(*client)
+		if !ok {
+			return nil, errors.New("unexpected client type")
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// memDBClientNamesByKey returns the sorted names of the clients of the
+// namespace in the context that use the named key.
+func (i *IdentityStore) memDBClientNamesByKey(ctx context.Context, keyName string) ([]string, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := i.db.Txn(false)
+
+	iter, err := txn.Get(oidcClientsTable, "key", ns.ID, keyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch clients from memdb: %w", err)
+	}
+
+	var names []string
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		client, ok := raw.(*client)
+		if !ok {
+			return nil, errors.New("unexpected client type")
+		}
+		names = append(names, client.Name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// memDBReloadClientBucket replaces the clients of the bucket with the given
+// storage key in memdb by the given clients, which were read from the bucket.
+func (i *IdentityStore) memDBReloadClientBucket(ctx context.Context, bucketKey string, clients []*client) error {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	txn := i.db.Txn(true)
+	defer txn.Abort()
+
+	if _, err := txn.DeleteAll(oidcClientsTable, "bucket_key", ns.ID, bucketKey); err != nil {
+		return fmt.Errorf("failed to delete clients from memdb: %w", err)
+	}
+	for _, client := range clients {
+		if err := i.memDBUpsertClientInTxn(txn, client); err != nil {
+			return err
+		}
+	}
+
+	txn.Commit()
+
+	return nil
+}
+
 // memDBDeleteClientByName deletes the client with the given name from memdb.
 func (i *IdentityStore) memDBDeleteClientByName(ctx context.Context, name string) error {
 	if name == "" {
@@ -2663,6 +5757,52 @@ func (i *IdentityStore) memDBDeleteClientByNameInTxn(ctx context.Context, txn *m
 	return nil
 }
 
+// putClients stores the given clients and replaces them in memdb. Clients
+// stored in the same bucket are written together.
+func (i *IdentityStore) putClients(ctx context.Context, s logical.Storage, clients []*client) error {
+	names := make([]string, 0, len(clients))
+	values := make([]interface{}, 0, len(clients))
+	for _, client := range clients {
+		names = append(names, client.Name)
+		values = append(values, client)
+	}
+
+	entries, err := oidcClientStore.putAll(ctx, s, i.logger, names, values)
+	if err != nil {
+		return err
+	}
+
+	for n, client := range clients {
+		client.BucketKey = entries[n].BucketKey
+		if err := i.memDBReplaceClientByName(ctx, client.Name, client); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// memDBReplaceClientByName replaces the client with the given name in memdb
+// by the given client, which may have a different ID, or deletes it if the
+// given client is nil.
+func (i *IdentityStore) memDBReplaceClientByName(ctx context.Context, name string, client *client) error {
+	txn := i.db.Txn(true)
+	defer txn.Abort()
+
+	if err := i.memDBDeleteClientByNameInTxn(ctx, txn, name); err != nil {
+		return err
+	}
+	if client != nil {
+		if err := i.memDBUpsertClientInTxn(txn, client); err != nil {
+			return err
+		}
+	}
+
+	txn.Commit()
+
+	return nil
+}
+
 // memDBUpsertClientInTxn creates or updates the given client in memdb using the given txn.
 func (i *IdentityStore) memDBUpsertClientInTxn(txn *memdb.Txn, client *client) error {
 	if client == nil {
@@ -2694,7 +5834,7 @@ func (i *IdentityStore) memDBUpsertClientInTxn(txn *memdb.Txn, client *client) e
 
 // storageClientByName returns the client with name from the given logical storage.
 func (i *IdentityStore) storageClientByName(ctx context.Context, s logical.Storage, name string) (*client, error) {
-	entry, err := s.Get(ctx, clientPath+name)
+	entry, err := oidcClientStore.get(ctx, s, name)
 	if err != nil {
 		return nil, err
 	}
@@ -2706,54 +5846,24 @@ func (i *IdentityStore) storageClientByName(ctx context.Context, s logical.Stora
 	if err := entry.DecodeJSON(&client); err != nil {
 		return nil, err
 	}
+	client.BucketKey = entry.BucketKey
 
 	return &client, nil
 }
 
-// storageClientByID returns the client with ID from the given logical storage.
-func (i *IdentityStore) storageClientByID(ctx context.Context, s logical.Storage, id string) (*client, error) {
-	clients, err := s.List(ctx, clientPath)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, name := range clients {
-		client, err := i.storageClientByName(ctx, s, name)
-		if err != nil {
-			return nil, err
-		}
-		if client == nil {
-			continue
-		}
-
-		if client.ClientID == id {
-			return client, nil
-		}
-	}
-
-	return nil, nil
-}
-
 func (i *IdentityStore) listClients(ctx context.Context, s logical.Storage) ([]*client, error) {
-	clientNames, err := s.List(ctx, clientPath)
+	entries, err := oidcClientStore.entries(ctx, s)
 	if err != nil {
 		return nil, err
 	}
 
 	var clients []*client
-	for _, name := range clientNames {
-		entry, err := s.Get(ctx, clientPath+name)
-		if err != nil {
-			return nil, err
-		}
-		if entry == nil {
-			continue
-		}
-
+	for _, entry := range entries {
 		var client client
 		if err := entry.DecodeJSON(&client); err != nil {
 			return nil, err
 		}
+		client.BucketKey = entry.BucketKey
 		clients = append(clients, &client)
 	}
 
@@ -0,0 +1,406 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// The methods that clients authenticate to the token endpoint with. See
+	// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+	tokenEndpointAuthMethodNone          = "none"
+	tokenEndpointAuthMethodSecretBasic   = "client_secret_basic"
+	tokenEndpointAuthMethodPrivateKeyJWT = "private_key_jwt"
+
+	// clientAssertionTypeJWTBearer is the client_assertion_type of clients
+	// that authenticate with the private_key_jwt method.
+	// See https://datatracker.ietf.org/doc/html/rfc7523#section-2.2
+	clientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// clientJWKSCacheTTL is how long the JWKS fetched from the jwks_uri of a
+	// client is cached. A JWKS without the key ID of an assertion is fetched
+	// again regardless, so that clients can rotate their keys.
+	clientJWKSCacheTTL = 5 * time.Minute
+
+	// clientJWKSRefetchInterval is the shortest time between two fetches of
+	// the JWKS of a client, so that assertions with unknown key IDs can't
+	// make the provider fetch it on every request
+	clientJWKSRefetchInterval = 30 * time.Second
+
+	// clientJWKSFetchTimeout bounds the requests to the jwks_uri of clients
+	clientJWKSFetchTimeout = 10 * time.Second
+
+	// clientJWKSMaxSize is the maximum size of the JWKS of a jwks_uri
+	clientJWKSMaxSize = 1 << 20
+)
+
+// tokenEndpointAuthMethods are the methods that clients of each type may
+// authenticate with
+var tokenEndpointAuthMethods = map[clientType][]string{
+	confidential: {tokenEndpointAuthMethodSecretBasic, tokenEndpointAuthMethodPrivateKeyJWT},
+	public:       {tokenEndpointAuthMethodNone},
+}
+
+// tokenEndpointAuthMethod returns the method that the client authenticates
+// with. Confidential clients use their client secret unless they set their
+// token_endpoint_auth_method, and public clients don't authenticate.
+func (c *client) tokenEndpointAuthMethod() string {
+	if c.TokenEndpointAuthMethod != "" {
+		return c.TokenEndpointAuthMethod
+	}
+	if c.Type == public {
+		return tokenEndpointAuthMethodNone
+	}
+	return tokenEndpointAuthMethodSecretBasic
+}
+
+// validateTokenEndpointAuthMethod returns an error if the client can't
+// authenticate with its token_endpoint_auth_method, or its keys are invalid.
+func (c *client) validateTokenEndpointAuthMethod() error {
+	method := c.tokenEndpointAuthMethod()
+	if !strutil.StrListContains(tokenEndpointAuthMethods[c.Type], method) {
+		return fmt.Errorf("token_endpoint_auth_method %q is not allowed for %s clients", method, c.Type)
+	}
+
+	if c.JWKS != "" && c.JWKSURI != "" {
+		return errors.New("only one of jwks and jwks_uri may be set")
+	}
+	if c.JWKS != "" {
+		if _, err := parseClientJWKS([]byte(c.JWKS)); err != nil {
+			return fmt.Errorf("invalid jwks: %w", err)
+		}
+	}
+	if c.JWKSURI != "" {
+		u, err := url.Parse(c.JWKSURI)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid jwks_uri %q: must be an absolute http or https URL", c.JWKSURI)
+		}
+	}
+	if method == tokenEndpointAuthMethodPrivateKeyJWT && c.JWKS == "" && c.JWKSURI == "" {
+		return fmt.Errorf("one of jwks or jwks_uri is required for the %s method", tokenEndpointAuthMethodPrivateKeyJWT)
+	}
+	if c.ClientAssertionClockSkew < 0 {
+		return errors.New("client_assertion_clock_skew must not be negative")
+	}
+
+	return nil
+}
+
+// parseClientJWKS parses the JWKS of a client, which must only have public
+// signing keys.
+func parseClientJWKS(raw []byte) (*jose.JSONWebKeySet, error) {
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &jwks); err != nil {
+		return nil, err
+	}
+	if len(jwks.Keys) == 0 {
+		return nil, errors.New("the key set has no keys")
+	}
+	for _, key := range jwks.Keys {
+		if !key.Valid() || !key.IsPublic() {
+			return nil, fmt.Errorf("key %q is not a valid public key", key.KeyID)
+		}
+		if key.Use != "" && key.Use != "sig" {
+			return nil, fmt.Errorf("key %q is not a signing key", key.KeyID)
+		}
+	}
+	return &jwks, nil
+}
+
+// authenticateClient authenticates the client of a request to the token,
+// device authorization or introspection endpoint of the provider. Clients
+// authenticate with the method of their token_endpoint_auth_method, and
+// public clients pass their client_id. It returns the client, or the error
+// code and description of the response if the client fails to authenticate.
+func (i *IdentityStore) authenticateClient(ctx context.Context, req *logical.Request, d *framework.FieldData, ns *namespace.Namespace, provider *provider) (*client, string, string, error) {
+	clientID, clientSecret, okBasicAuth, err := basicAuth(req)
+	if err != nil {
+		i.Logger().Debug("client failed to authenticate with malformed credentials", "error", err)
+		return nil, ErrTokenInvalidClient, "client failed to authenticate", nil
+	}
+	assertionType := d.Get("client_assertion_type").(string)
+	assertion := d.Get("client_assertion").(string)
+	okAssertion := assertionType != "" || assertion != ""
+
+	// Clients must not use more than one authentication method
+	// https://datatracker.ietf.org/doc/html/rfc6749#section-2.3
+	_, okClientID := req.Data["client_id"]
+	_, okClientSecret := req.Data["client_secret"]
+	if (okBasicAuth && (okClientID || okClientSecret || okAssertion)) || (okAssertion && okClientSecret) {
+		return nil, ErrTokenInvalidRequest, "client must not use more than one authentication method", nil
+	}
+
+	var method string
+	switch {
+	case okAssertion:
+		method = tokenEndpointAuthMethodPrivateKeyJWT
+		if assertionType != clientAssertionTypeJWTBearer {
+			return nil, ErrTokenInvalidRequest, fmt.Sprintf("client_assertion_type must be %q", clientAssertionTypeJWTBearer), nil
+		}
+		if assertion == "" {
+			return nil, ErrTokenInvalidRequest, "client_assertion parameter is required", nil
+		}
+
+		// The subject of the assertion finds the client, whose keys then
+		// verify it
+		clientID, err = clientAssertionSubject(assertion)
+		if err != nil {
+			i.Logger().Debug("client failed to authenticate with malformed assertion", "error", err)
+			return nil, ErrTokenInvalidClient, "client failed to authenticate", nil
+		}
+		if bodyClientID := d.Get("client_id").(string); bodyClientID != "" && bodyClientID != clientID {
+			return nil, ErrTokenInvalidClient, "client_id is not the subject of the client_assertion", nil
+		}
+	case okBasicAuth:
+		method = tokenEndpointAuthMethodSecretBasic
+	default:
+		method = tokenEndpointAuthMethodNone
+		clientID = d.Get("client_id").(string)
+		if clientID == "" {
+			return nil, ErrTokenInvalidRequest, "client_id parameter is required", nil
+		}
+	}
+
+	client, err := i.clientByID(clientID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if client == nil {
+		i.Logger().Debug("client failed to authenticate with client not found", "client_id", clientID)
+		return nil, ErrTokenInvalidClient, "client failed to authenticate", nil
+	}
+
+	// Public clients may pass their client ID in the Authorization header
+	if method == tokenEndpointAuthMethodSecretBasic && client.Type == public {
+		method = tokenEndpointAuthMethodNone
+	}
+
+	// Clients may only use their own authentication method, so that
+	// private_key_jwt clients can't authenticate with their client secret
+	if method != client.tokenEndpointAuthMethod() {
+		i.Logger().Debug("client failed to authenticate with another authentication method",
+			"client_id", clientID, "method", method)
+		return nil, ErrTokenInvalidClient, fmt.Sprintf("client must authenticate with the %s method", client.tokenEndpointAuthMethod()), nil
+	}
+
+	switch method {
+	case tokenEndpointAuthMethodSecretBasic:
+		if !client.clientSecretMatches(clientSecret, time.Now()) {
+			i.Logger().Debug("client failed to authenticate with invalid client secret", "client_id", clientID)
+			return nil, ErrTokenInvalidClient, "client failed to authenticate", nil
+		}
+	case tokenEndpointAuthMethodPrivateKeyJWT:
+		if err := i.verifyClientAssertion(ctx, req, ns, provider, client, assertion, time.Now()); err != nil {
+			i.Logger().Debug("client failed to authenticate with invalid assertion", "client_id", clientID, "error", err)
+			return nil, ErrTokenInvalidClient, "client failed to authenticate", nil
+		}
+	}
+
+	return client, "", "", nil
+}
+
+// clientAssertionSubject returns the unverified subject of a client
+// assertion, which is the client ID of the client that signed it.
+func clientAssertionSubject(assertion string) (string, error) {
+	parsedJWT, err := jwt.ParseSigned(assertion)
+	if err != nil {
+		return "", err
+	}
+	var claims jwt.Claims
+	if err := parsedJWT.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return "", errors.New("the assertion has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// clientAssertionAudiences returns the audiences that client assertions to
+// the provider may have: the token endpoint URL of its issuer, which is
+// also accepted by the device authorization and introspection endpoints,
+// or its issuer.
+func clientAssertionAudiences(req *logical.Request, provider *provider) []string {
+	issuers := strutil.RemoveDuplicates([]string{provider.effectiveIssuer, provider.issuerForHost(requestHost(req))}, false)
+	audiences := make([]string, 0, 2*len(issuers))
+	for _, issuer := range issuers {
+		audiences = append(audiences, issuer+"/token", issuer)
+	}
+	return audiences
+}
+
+// verifyClientAssertion returns an error unless the assertion was signed by
+// one of the registered keys of the client for the provider, is unexpired,
+// and wasn't used before. The time claims are validated with the clock skew
+// tolerance of the client, and the ID of the assertion is remembered until
+// it expires.
+// See https://datatracker.ietf.org/doc/html/rfc7523#section-3
+func (i *IdentityStore) verifyClientAssertion(ctx context.Context, req *logical.Request, ns *namespace.Namespace, provider *provider, client *client, assertion string, now time.Time) error {
+	parsedJWT, err := jwt.ParseSigned(assertion)
+	if err != nil {
+		return err
+	}
+	if len(parsedJWT.Headers) != 1 {
+		return errors.New("the assertion must have exactly one signature")
+	}
+	header := parsedJWT.Headers[0]
+	if !strutil.StrListContains(supportedAlgs, header.Algorithm) {
+		return fmt.Errorf("unsupported signing algorithm %q", header.Algorithm)
+	}
+
+	keys, err := i.clientAssertionKeys(ctx, ns, client, header.KeyID)
+	if err != nil {
+		return err
+	}
+	var claims *jwt.Claims
+	for _, key := range keys {
+		var c jwt.Claims
+		if err := parsedJWT.Claims(key, &c); err == nil {
+			claims = &c
+			break
+		}
+	}
+	if claims == nil {
+		return errors.New("no registered key of the client verifies the assertion")
+	}
+
+	if claims.Expiry == nil {
+		return errors.New("the assertion has no expiration time")
+	}
+	if claims.ID == "" {
+		return errors.New("the assertion has no ID")
+	}
+	skew := client.ClientAssertionClockSkew
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:  client.ClientID,
+		Subject: client.ClientID,
+		Time:    now,
+	}, skew); err != nil {
+		return err
+	}
+	audiences := clientAssertionAudiences(req, provider)
+	validAudience := false
+	for _, audience := range claims.Audience {
+		if strutil.StrListContains(audiences, audience) {
+			validAudience = true
+			break
+		}
+	}
+	if !validAudience {
+		return fmt.Errorf("the audience of the assertion must be one of: %v", audiences)
+	}
+
+	// Assertions are single use, so the ID is remembered for as long as the
+	// assertion would be accepted
+	added, err := i.oidcClientAssertionCache.Add(ns, client.ClientID+":"+claims.ID, struct{}{},
+		claims.Expiry.Time().Add(skew).Sub(now))
+	if err != nil {
+		return err
+	}
+	if !added {
+		return fmt.Errorf("the assertion %q was already used", claims.ID)
+	}
+
+	return nil
+}
+
+// clientAssertionKeys returns the registered keys of the client that may
+// verify an assertion with the given key ID, or all of its keys if the
+// assertion has none.
+func (i *IdentityStore) clientAssertionKeys(ctx context.Context, ns *namespace.Namespace, client *client, keyID string) ([]jose.JSONWebKey, error) {
+	keysOf := func(jwks *jose.JSONWebKeySet) []jose.JSONWebKey {
+		if keyID == "" {
+			return jwks.Keys
+		}
+		return jwks.Key(keyID)
+	}
+
+	if client.JWKS != "" {
+		jwks, err := parseClientJWKS([]byte(client.JWKS))
+		if err != nil {
+			return nil, err
+		}
+		return keysOf(jwks), nil
+	}
+	if client.JWKSURI == "" {
+		return nil, errors.New("the client has no registered keys")
+	}
+
+	cacheKey := "jwks_uri:" + client.JWKSURI
+	var cached *jose.JSONWebKeySet
+	if v, ok, err := i.oidcClientJWKSCache.Get(ns, cacheKey); err != nil {
+		return nil, err
+	} else if ok {
+		cached = v.(*jose.JSONWebKeySet)
+		if keys := keysOf(cached); len(keys) > 0 {
+			return keys, nil
+		}
+	}
+
+	// The JWKS of a client is fetched at most once per refetch interval,
+	// whether or not the fetch succeeds. The cached JWKS is used until then.
+	fetch, err := i.oidcClientJWKSCache.Add(ns, "jwks_uri_fetch:"+client.ClientID+":"+client.JWKSURI, struct{}{}, clientJWKSRefetchInterval)
+	if err != nil {
+		return nil, err
+	}
+	if !fetch {
+		if cached != nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("the JWKS of the client was fetched less than %s ago", clientJWKSRefetchInterval)
+	}
+
+	jwks, err := fetchClientJWKS(ctx, client.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.oidcClientJWKSCache.SetDefault(ns, cacheKey, jwks); err != nil {
+		return nil, err
+	}
+	return keysOf(jwks), nil
+}
+
+// fetchClientJWKS fetches the JWKS of a client from its jwks_uri
+func fetchClientJWKS(ctx context.Context, jwksURI string) (*jose.JSONWebKeySet, error) {
+	ctx, cancel := context.WithTimeout(ctx, clientJWKSFetchTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := cleanhttp.DefaultClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the JWKS of the client: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the JWKS of the client: unexpected status code %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, clientJWKSMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the JWKS of the client: %w", err)
+	}
+	jwks, err := parseClientJWKS(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS of the client: %w", err)
+	}
+	return jwks, nil
+}
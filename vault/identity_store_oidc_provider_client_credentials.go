@@ -0,0 +1,139 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// clientCredentialsGrantType is the grant type that clients use to get
+	// tokens for themselves rather than for an end-user. See details at
+	// https://datatracker.ietf.org/doc/html/rfc6749#section-4.4
+	clientCredentialsGrantType = "client_credentials"
+
+	// accessTokenGrantTypeMeta is the internal metadata of access tokens
+	// issued by the client_credentials grant, which have no end-user
+	accessTokenGrantTypeMeta = "grant_type"
+)
+
+// defaultClientGrantTypes are the grant types of clients that don't set their
+// grant_types. The client_credentials grant type must be enabled explicitly.
+var defaultClientGrantTypes = []string{
+	"authorization_code",
+	"refresh_token",
+	"password",
+	deviceCodeGrantType,
+}
+
+// supportedClientGrantTypes are the grant types that clients may enable.
+var supportedClientGrantTypes = append(append([]string{}, defaultClientGrantTypes...), clientCredentialsGrantType)
+
+// allowedGrantTypes returns the grant types that the client may use. The
+// password and refresh_token grant types also need the client's
+// password_grant_mount and refresh_token_ttl.
+func (c *client) allowedGrantTypes() []string {
+	if len(c.GrantTypes) == 0 {
+		return defaultClientGrantTypes
+	}
+	return c.GrantTypes
+}
+
+func (c *client) allowsGrantType(grantType string) bool {
+	return strutil.StrListContains(c.allowedGrantTypes(), grantType)
+}
+
+// validateGrantTypes returns an error if the client enables an unknown grant
+// type, or the client_credentials grant type without being able to
+// authenticate.
+func (c *client) validateGrantTypes() error {
+	for _, grantType := range c.GrantTypes {
+		if !strutil.StrListContains(supportedClientGrantTypes, grantType) {
+			return fmt.Errorf("invalid grant type %q, must be one of: %s", grantType, strings.Join(supportedClientGrantTypes, ", "))
+		}
+	}
+	if c.allowsGrantType(clientCredentialsGrantType) && c.Type != confidential {
+		return fmt.Errorf("the %s grant type is only allowed for confidential clients", clientCredentialsGrantType)
+	}
+	return nil
+}
+
+// oidcClientCredentialsGrant handles the client credentials grant, which
+// issues tokens to the client itself. The subject of the tokens is the
+// client ID, or the entity that the client is bound to by its entity_id. An
+// ID token is only issued if the openid scope is requested.
+// See https://datatracker.ietf.org/doc/html/rfc6749#section-4.4
+func (i *IdentityStore) oidcClientCredentialsGrant(ctx context.Context, req *logical.Request, d *framework.FieldData, ns *namespace.Namespace, provider *provider, client *client, key *namedKey) (*logical.Response, error) {
+	// Only confidential clients authenticate, which the grant relies on
+	if client.Type != confidential {
+		return tokenResponse(nil, ErrTokenUnauthorizedClient, "public clients are not authorized to use the client_credentials grant type")
+	}
+
+	var entity *identity.Entity
+	if client.EntityID != "" {
+		var err error
+		entity, err = i.MemDBEntityByID(client.EntityID, true)
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
+		if entity == nil {
+			return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity of the client not found")
+		}
+		if entity.Disabled {
+			return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity of the client is disabled")
+		}
+	}
+
+	// Ignore scope values that are not supported by the provider. Refresh
+	// tokens aren't issued for the grant, so offline_access is ignored too.
+	requestedScopes := strutil.ParseDedupAndSortStrings(d.Get("scope").(string), scopesDelimiter)
+	scopes := make([]string, 0)
+	for _, scope := range requestedScopes {
+		if strutil.StrListContains(provider.ScopesSupported, scope) && scope != openIDScope {
+			scopes = append(scopes, scope)
+		}
+	}
+	scopes, err := i.grantedScopes(ctx, req.Storage, provider, scopes)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+
+	// Without an entity, only the scopes whose templates don't reference
+	// the entity or its groups are granted
+	if entity == nil {
+		scopes, err = i.entityIndependentScopes(ctx, req.Storage, scopes)
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
+	}
+
+	return i.issueOIDCTokens(ctx, req, ns, provider, client, key, entity, tokenGrant{
+		scopes:            scopes,
+		clientCredentials: true,
+		omitIDToken:       !strutil.StrListContains(requestedScopes, openIDScope),
+	})
+}
+
+// entityIndependentScopes returns the given scopes whose templates don't
+// reference the entity or its groups.
+func (i *IdentityStore) entityIndependentScopes(ctx context.Context, s logical.Storage, scopes []string) ([]string, error) {
+	templates, err := i.getScopeTemplates(ctx, s, scopes...)
+	if err != nil {
+		return nil, err
+	}
+
+	independent := make([]string, 0, len(templates))
+	for _, t := range templates {
+		if strings.Contains(t.template, "identity.entity.") || strings.Contains(t.template, "identity.groups.") {
+			continue
+		}
+		independent = append(independent, t.scope)
+	}
+	return independent, nil
+}
@@ -0,0 +1,183 @@
+package vault
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/base62"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func oidcClientSecretPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/client/" + framework.GenericNameRegex("name") + "/rotate-secret",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the client.",
+				},
+				"secret_rotation_grace": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long the previous client secret is still accepted after the rotation. If zero, the previous client secret is refused immediately.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: i.pathOIDCRotateClientSecret,
+					Summary:  "Rotate the client secret of a confidential client.",
+				},
+			},
+			HelpSynopsis:    "Rotate the client secret of an OIDC client.",
+			HelpDescription: "Generate a new client secret for a confidential OIDC client that authenticates with the 'client_secret_basic' method, keeping its client ID. The previous client secret is accepted along with the new one for the secret_rotation_grace duration, after which it's deleted.",
+		},
+	}
+}
+
+// generateClientSecret returns a new random client secret.
+func generateClientSecret() (string, error) {
+	clientSecret, err := base62.Random(clientSecretLength)
+	if err != nil {
+		return "", err
+	}
+	return clientSecretPrefix + clientSecret, nil
+}
+
+// previousClientSecretValid returns true if the client secret that the
+// client's secret was rotated from is still accepted at the given time.
+func (c *client) previousClientSecretValid(now time.Time) bool {
+	return c.PreviousClientSecret != "" && now.Before(c.PreviousClientSecretExpireAt)
+}
+
+// previousClientSecretExpireAt returns the time at which the previous client
+// secret expires, or the zero time if it's no longer accepted at the given
+// time.
+func (c *client) previousClientSecretExpireAt(now time.Time) time.Time {
+	if !c.previousClientSecretValid(now) {
+		return time.Time{}
+	}
+	return c.PreviousClientSecretExpireAt
+}
+
+// clientSecretMatches returns true if the given secret is the client secret
+// of the client, or its previous client secret during the grace period of
+// the rotation.
+func (c *client) clientSecretMatches(secret string, now time.Time) bool {
+	if subtle.ConstantTimeCompare([]byte(c.ClientSecret), []byte(secret)) == 1 {
+		return true
+	}
+	return c.previousClientSecretValid(now) &&
+		subtle.ConstantTimeCompare([]byte(c.PreviousClientSecret), []byte(secret)) == 1
+}
+
+// pathOIDCRotateClientSecret generates a new client secret for a client that
+// authenticates with its client secret. The client ID is kept, so that the
+// relying parties only need to update their secret.
+func (i *IdentityStore) pathOIDCRotateClientSecret(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	grace := time.Duration(d.Get("secret_rotation_grace").(int)) * time.Second
+	if grace < 0 {
+		return logical.ErrorResponse("secret_rotation_grace must not be negative"), logical.ErrInvalidRequest
+	}
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	c, err := i.storageClientByName(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return logical.ErrorResponse("no client found at %q", name), logical.ErrInvalidRequest
+	}
+	c.Name = name
+
+	if c.Type == public {
+		return logical.ErrorResponse("client %q is a public client, which doesn't have a client secret", name), logical.ErrInvalidRequest
+	}
+	if method := c.tokenEndpointAuthMethod(); method != tokenEndpointAuthMethodSecretBasic {
+		return logical.ErrorResponse("client %q authenticates with the %q method rather than with its client secret", name, method), logical.ErrInvalidRequest
+	}
+
+	clientSecret, err := generateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	c.PreviousClientSecret = ""
+	c.PreviousClientSecretExpireAt = time.Time{}
+	if grace > 0 {
+		c.PreviousClientSecret = c.ClientSecret
+		c.PreviousClientSecretExpireAt = now.Add(grace)
+	}
+	c.ClientSecret = clientSecret
+	c.ClientSecretRotatedAt = now
+	c.UpdatedAt = now
+
+	if err := i.putClients(ctx, req.Storage, []*client{c}); err != nil {
+		return nil, err
+	}
+
+	// bring the deletion of the previous secret in if it's due before the
+	// next run of the periodic func
+	if grace > 0 {
+		i.advanceOIDCPeriodicRun(c.PreviousClientSecretExpireAt)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"client_id":                         c.ClientID,
+			"client_secret":                     c.ClientSecret,
+			"client_secret_rotated_at":          formatClientTime(c.ClientSecretRotatedAt),
+			"previous_client_secret_expires_at": formatClientTime(c.PreviousClientSecretExpireAt),
+		},
+	}, nil
+}
+
+// deleteExpiredOIDCClientSecrets deletes the previous client secrets of the
+// clients of the namespace of the context whose grace period has elapsed. It
+// returns the soonest time at which another previous client secret expires,
+// or the zero time if none does.
+func (i *IdentityStore) deleteExpiredOIDCClientSecrets(ctx context.Context, s logical.Storage) (time.Time, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	clients, err := i.memDBClients(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var nextExpiration time.Time
+	var expired []*client
+	now := time.Now()
+	for _, c := range clients {
+		if c.PreviousClientSecret == "" {
+			continue
+		}
+		if c.previousClientSecretValid(now) {
+			nextExpiration = earliestTime(nextExpiration, c.PreviousClientSecretExpireAt)
+			continue
+		}
+
+		// memdb clients must not be modified
+		updated := *c
+		updated.PreviousClientSecret = ""
+		updated.PreviousClientSecretExpireAt = time.Time{}
+		expired = append(expired, &updated)
+	}
+	if len(expired) == 0 {
+		return nextExpiration, nil
+	}
+
+	if err := i.putClients(ctx, s, expired); err != nil {
+		return nextExpiration, err
+	}
+	for _, c := range expired {
+		i.Logger().Debug("deleted the previous client secret of OIDC client", "name", c.Name, "client_id", c.ClientID)
+	}
+
+	return nextExpiration, nil
+}
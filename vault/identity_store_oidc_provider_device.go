@@ -0,0 +1,573 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/base62"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// deviceCodeGrantType is the grant type of the device authorization
+	// grant at the token endpoint. See details at
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-3.4
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// deviceCodeTTL is how long device codes can be approved and exchanged
+	// for tokens, and deviceCodeInterval is the minimum time in seconds that
+	// clients must wait between polling requests
+	deviceCodeTTL      = 10 * time.Minute
+	deviceCodeInterval = 5
+
+	// userCodeCharset is the charset of user codes, which has no vowels so
+	// that codes don't spell words, and userCodeLength is their length
+	// without the separator. See details at
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-6.1
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+)
+
+// deviceAuthorization is a pending device authorization request, which is
+// cached by the hash of its device code until it is exchanged or expires.
+// It is only changed while holding the oidcDeviceCodeLock.
+type deviceAuthorization struct {
+	provider      string
+	clientID      string
+	scopes        []string
+	offlineAccess bool
+	userCode      string
+	expireAt      time.Time
+
+	// interval is the current polling interval in seconds, which is raised
+	// each time the client polls too fast, and lastPolledAt is the time of
+	// the last polling request
+	interval     int
+	lastPolledAt time.Time
+
+	// The decision of the end-user. The entity is the one that approved the
+	// request, and authTime the time it authenticated.
+	approved bool
+	denied   bool
+	entityID string
+	authTime time.Time
+}
+
+func deviceCodeCacheKey(deviceCode string) string {
+	hash := sha256.Sum256([]byte(deviceCode))
+	return "device_code/" + hex.EncodeToString(hash[:])
+}
+
+func userCodeCacheKey(provider, userCode string) string {
+	return "user_code/" + provider + "/" + normalizeUserCode(userCode)
+}
+
+// normalizeUserCode returns the user code without separators and in upper
+// case, since user codes are case-insensitive.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
+
+// generateUserCode returns a random user code formatted as XXXX-XXXX.
+func generateUserCode() (string, error) {
+	charsetLen := big.NewInt(int64(len(userCodeCharset)))
+	code := make([]byte, 0, userCodeLength+1)
+	for n := 0; n < userCodeLength; n++ {
+		if n == userCodeLength/2 {
+			code = append(code, '-')
+		}
+		idx, err := rand.Int(rand.Reader, charsetLen)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, userCodeCharset[idx.Int64()])
+	}
+	return string(code), nil
+}
+
+func oidcProviderDevicePaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/device_authorization",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "The ID of the requesting client. Confidential clients authenticate with the HTTP Basic authentication scheme or a client assertion instead.",
+				},
+				"client_assertion_type": {
+					Type:        framework.TypeString,
+					Description: "The type of the client assertion, which must be 'urn:ietf:params:oauth:client-assertion-type:jwt-bearer'. Required for the 'private_key_jwt' authentication method.",
+				},
+				"client_assertion": {
+					Type:        framework.TypeString,
+					Description: "A JWT signed by one of the registered keys of the client. Required for the 'private_key_jwt' authentication method.",
+				},
+				"scope": {
+					Type:        framework.TypeString,
+					Description: "A space-delimited, case-sensitive list of scopes to be requested. The 'openid' scope is required.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:          i.pathOIDCDeviceAuthorization,
+					Summary:           "Start a device authorization request.",
+					OperationID:       "oidcProviderDeviceAuthorization",
+					RequestMediaTypes: []string{"application/x-www-form-urlencoded", "application/json"},
+					Responses: oidcProviderResponses(map[string]*framework.FieldSchema{
+						"device_code": {
+							Type:        framework.TypeString,
+							Description: "The device verification code, to be exchanged at the token endpoint.",
+							Required:    true,
+						},
+						"user_code": {
+							Type:        framework.TypeString,
+							Description: "The code that the end-user enters at the verification URI.",
+							Required:    true,
+						},
+						"verification_uri": {
+							Type:        framework.TypeString,
+							Description: "The URI of the Vault UI where the end-user approves the request.",
+							Required:    true,
+						},
+						"verification_uri_complete": {
+							Type:        framework.TypeString,
+							Description: "The verification URI with the user code.",
+						},
+						"expires_in": {
+							Type:        framework.TypeInt,
+							Description: "The lifetime in seconds of the device and user codes.",
+							Required:    true,
+						},
+						"interval": {
+							Type:        framework.TypeInt,
+							Description: "The minimum time in seconds that the client must wait between polling requests to the token endpoint.",
+							Required:    true,
+						},
+					}, nil, http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusServiceUnavailable),
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: false,
+				},
+			},
+			HelpSynopsis:    "Provides the OAuth 2.0 Device Authorization Endpoint.",
+			HelpDescription: "Starts the device authorization grant of RFC 8628 for clients without a browser. The end-user approves the request in the Vault UI with the returned user code, while the client polls the token endpoint with the device code.",
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/device",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"user_code": {
+					Type:        framework.TypeString,
+					Description: "The user code of the device authorization request. User codes are case-insensitive.",
+					Required:    true,
+				},
+				"action": {
+					Type:          framework.TypeString,
+					Description:   "Whether to approve or deny the device authorization request.",
+					Default:       "approve",
+					AllowedValues: []interface{}{"approve", "deny"},
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback:                    i.pathOIDCDeviceRead,
+					Summary:                     "Read the client and scopes of a device authorization request.",
+					QueryParameters:             true,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: false,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:                    i.pathOIDCDeviceVerify,
+					Summary:                     "Approve or deny a device authorization request.",
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: false,
+				},
+			},
+			HelpSynopsis:    "Approve or deny device authorization requests of the provider.",
+			HelpDescription: "The end-user enters the user code of a device authorization request to approve or deny it. The identity entity of the request must be authorized by the assignments of the client.",
+		},
+	}
+}
+
+// pathOIDCDeviceAuthorization starts a device authorization request for the
+// client. Clients authenticate as they do at the token endpoint.
+// See https://datatracker.ietf.org/doc/html/rfc8628#section-3.1
+func (i *IdentityStore) pathOIDCDeviceAuthorization(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the namespace
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+
+	// Get the OIDC provider
+	name := d.Get("name").(string)
+	provider, err := i.getOIDCProvider(ctx, req.Storage, name)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if provider == nil {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "provider not found")
+	}
+
+	// Authenticate the client with its authentication method, or find it by
+	// its client_id if it's a public client
+	client, errCode, errDescription, err := i.authenticateClient(ctx, req, d, ns, provider)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if errCode != "" {
+		return tokenResponse(nil, errCode, errDescription)
+	}
+	clientID := client.ClientID
+	if client.expired(time.Now()) {
+		return tokenResponse(nil, ErrTokenInvalidClient, "client has expired")
+	}
+
+	// Validate that the client is authorized to use the provider
+	if !strutil.StrListContains(provider.AllowedClientIDs, "*") &&
+		!strutil.StrListContains(provider.AllowedClientIDs, clientID) {
+		return tokenResponse(nil, ErrTokenInvalidClient, "client is not authorized to use the provider")
+	}
+	if !client.allowsGrantType(deviceCodeGrantType) {
+		return tokenResponse(nil, ErrTokenUnauthorizedClient, "client is not authorized to use the device_code grant type")
+	}
+
+	// New logins are refused while the provider is in maintenance
+	if provider.Maintenance {
+		return tokenResponse(nil, ErrTokenTemporarilyUnavailable, provider.maintenanceMessage())
+	}
+
+	// Validate that the scope parameter contains the openid scope value and
+	// ignore scope values that are not supported by the provider
+	requestedScopes := strutil.ParseDedupAndSortStrings(d.Get("scope").(string), scopesDelimiter)
+	if !strutil.StrListContains(requestedScopes, openIDScope) {
+		return tokenResponse(nil, ErrTokenInvalidRequest,
+			fmt.Sprintf("scope parameter must contain the %q value", openIDScope))
+	}
+	scopes := make([]string, 0)
+	for _, scope := range requestedScopes {
+		if strutil.StrListContains(provider.ScopesSupported, scope) && scope != openIDScope {
+			scopes = append(scopes, scope)
+		}
+	}
+	scopes, err = i.grantedScopes(ctx, req.Storage, provider, scopes)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+
+	deviceCode, err := base62.Random(32)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	now := time.Now()
+	authorization := &deviceAuthorization{
+		provider:      name,
+		clientID:      clientID,
+		scopes:        scopes,
+		offlineAccess: strutil.StrListContains(requestedScopes, offlineAccessScope),
+		expireAt:      now.Add(deviceCodeTTL),
+		interval:      deviceCodeInterval,
+	}
+
+	// User codes are short, so they are generated again until one isn't in
+	// use by another pending request of the provider
+	i.oidcDeviceCodeLock.Lock()
+	defer i.oidcDeviceCodeLock.Unlock()
+	for attempts := 0; authorization.userCode == ""; attempts++ {
+		if attempts == 5 {
+			return tokenResponse(nil, ErrTokenServerError, "failed to generate a unique user code")
+		}
+		userCode, err := generateUserCode()
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
+		_, found, err := i.oidcDeviceCodeCache.Get(ns, userCodeCacheKey(name, userCode))
+		if err != nil {
+			return tokenResponse(nil, ErrTokenServerError, err.Error())
+		}
+		if !found {
+			authorization.userCode = userCode
+		}
+	}
+
+	deviceCodeKey := deviceCodeCacheKey(deviceCode)
+	if err := i.oidcDeviceCodeCache.SetDefault(ns, deviceCodeKey, authorization); err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if err := i.oidcDeviceCodeCache.SetDefault(ns, userCodeCacheKey(name, authorization.userCode), deviceCodeKey); err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+
+	// The end-user approves the request in the UI, like authorization
+	// requests
+	issuer := provider.issuerForHost(requestHost(req))
+	verificationURI := strings.Replace(issuer, "/v1/", "/ui/vault/", 1) + "/device"
+	return tokenResponse(map[string]interface{}{
+		"device_code":               deviceCode,
+		"user_code":                 authorization.userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + authorization.userCode,
+		"expires_in":                int64(deviceCodeTTL.Seconds()),
+		"interval":                  authorization.interval,
+	}, "", "")
+}
+
+// pendingDeviceAuthorization returns the pending device authorization request
+// of the provider with the given user code, or nil if there is none. The
+// caller must hold the oidcDeviceCodeLock.
+func (i *IdentityStore) pendingDeviceAuthorization(ns *namespace.Namespace, provider, userCode string) (*deviceAuthorization, error) {
+	deviceCodeKey, found, err := i.oidcDeviceCodeCache.Get(ns, userCodeCacheKey(provider, userCode))
+	if err != nil || !found {
+		return nil, err
+	}
+	authorizationRaw, found, err := i.oidcDeviceCodeCache.Get(ns, deviceCodeKey.(string))
+	if err != nil || !found {
+		return nil, err
+	}
+
+	authorization := authorizationRaw.(*deviceAuthorization)
+	if authorization.approved || authorization.denied || !time.Now().Before(authorization.expireAt) {
+		return nil, nil
+	}
+	return authorization, nil
+}
+
+// pathOIDCDeviceRead returns the client and scopes of a pending device
+// authorization request, so that the end-user can check them before
+// approving it.
+func (i *IdentityStore) pathOIDCDeviceRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userCode := d.Get("user_code").(string)
+	if userCode == "" {
+		return logical.ErrorResponse("user_code is required"), nil
+	}
+
+	i.oidcDeviceCodeLock.Lock()
+	authorization, err := i.pendingDeviceAuthorization(ns, d.Get("name").(string), userCode)
+	i.oidcDeviceCodeLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if authorization == nil {
+		return logical.ErrorResponse("user code is invalid or expired"), nil
+	}
+
+	client, err := i.clientByID(authorization.clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return logical.ErrorResponse("client of the device authorization request not found"), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"client_id":   client.ClientID,
+			"client_name": client.Name,
+			"scopes":      append([]string{openIDScope}, authorization.scopes...),
+			"expire_time": authorization.expireAt.UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// pathOIDCDeviceVerify approves or denies a pending device authorization
+// request for the identity entity of the request. The entity must be
+// authorized by the assignments of the client to approve it.
+// See https://datatracker.ietf.org/doc/html/rfc8628#section-3.3
+func (i *IdentityStore) pathOIDCDeviceVerify(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := d.Get("name").(string)
+	userCode := d.Get("user_code").(string)
+	if userCode == "" {
+		return logical.ErrorResponse("user_code is required"), nil
+	}
+
+	i.oidcDeviceCodeLock.Lock()
+	defer i.oidcDeviceCodeLock.Unlock()
+	authorization, err := i.pendingDeviceAuthorization(ns, name, userCode)
+	if err != nil {
+		return nil, err
+	}
+	if authorization == nil {
+		return logical.ErrorResponse("user code is invalid or expired"), nil
+	}
+
+	switch d.Get("action").(string) {
+	case "deny":
+		authorization.denied = true
+		return nil, nil
+	case "approve":
+	default:
+		return logical.ErrorResponse("action must be 'approve' or 'deny'"), nil
+	}
+
+	// Validate that there is an identity entity associated with the request
+	if req.EntityID == "" {
+		return logical.ErrorResponse("identity entity must be associated with the request"), nil
+	}
+	entity, err := i.MemDBEntityByID(req.EntityID, false)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.Disabled {
+		return logical.ErrorResponse("identity entity associated with the request not found or disabled"), nil
+	}
+
+	// Validate that the entity is a member of the client's assignments
+	client, err := i.clientByID(authorization.clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return logical.ErrorResponse("client of the device authorization request not found"), nil
+	}
+	isMember, err := i.entityHasAssignment(ctx, req.Storage, entity, client.Assignments)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return logical.ErrorResponse("identity entity not authorized by client assignment"), nil
+	}
+
+	// The end-user authenticated when the token of the request was created
+	authTime := time.Now()
+	te, err := i.requestTokenEntry(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if te != nil && te.CreationTime > 0 {
+		authTime = time.Unix(te.CreationTime, 0)
+	}
+
+	authorization.approved = true
+	authorization.entityID = entity.ID
+	authorization.authTime = authTime
+	i.Logger().Debug("approved device authorization request", "provider", name,
+		"client_id", client.ClientID, "entity_id", entity.ID)
+
+	return nil, nil
+}
+
+// pollDeviceAuthorization returns the approved device authorization request
+// of the device code and deletes it, or the error code of the polling
+// response if the request isn't approved. Denied and expired requests are
+// deleted as well, so that each device code is decided once.
+func (i *IdentityStore) pollDeviceAuthorization(ns *namespace.Namespace, provider, clientID, deviceCode string) (*deviceAuthorization, string, string, error) {
+	i.oidcDeviceCodeLock.Lock()
+	defer i.oidcDeviceCodeLock.Unlock()
+
+	deviceCodeKey := deviceCodeCacheKey(deviceCode)
+	authorizationRaw, found, err := i.oidcDeviceCodeCache.Get(ns, deviceCodeKey)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if !found {
+		return nil, ErrTokenInvalidGrant, "device code is invalid or was already used", nil
+	}
+	authorization := authorizationRaw.(*deviceAuthorization)
+	if authorization.clientID != clientID || authorization.provider != provider {
+		return nil, ErrTokenInvalidGrant, "device code was not issued to the client by the provider", nil
+	}
+
+	var errCode, errDescription string
+	now := time.Now()
+	switch {
+	case !now.Before(authorization.expireAt):
+		errCode, errDescription = ErrTokenExpiredToken, "device code has expired"
+	case authorization.denied:
+		errCode, errDescription = ErrTokenAccessDenied, "end-user denied the authorization request"
+	case !authorization.approved && now.Sub(authorization.lastPolledAt) < time.Duration(authorization.interval)*time.Second:
+		// Clients that poll too fast must wait 5 more seconds between
+		// requests from then on
+		authorization.interval += deviceCodeInterval
+		authorization.lastPolledAt = now
+		return nil, ErrTokenSlowDown, fmt.Sprintf("polling interval is now %d seconds", authorization.interval), nil
+	case !authorization.approved:
+		authorization.lastPolledAt = now
+		return nil, ErrTokenAuthorizationPending, "end-user has not yet approved the authorization request", nil
+	}
+
+	if err := i.oidcDeviceCodeCache.Delete(ns, deviceCodeKey); err != nil {
+		return nil, "", "", err
+	}
+	if err := i.oidcDeviceCodeCache.Delete(ns, userCodeCacheKey(provider, authorization.userCode)); err != nil {
+		return nil, "", "", err
+	}
+	if errCode != "" {
+		return nil, errCode, errDescription, nil
+	}
+	return authorization, "", "", nil
+}
+
+// oidcDeviceCodeGrant handles the device authorization grant, which clients
+// poll until the end-user approves or denies the request, or it expires.
+// See https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+func (i *IdentityStore) oidcDeviceCodeGrant(ctx context.Context, req *logical.Request, d *framework.FieldData, ns *namespace.Namespace, provider *provider, client *client, key *namedKey) (*logical.Response, error) {
+	deviceCode := d.Get("device_code").(string)
+	if deviceCode == "" {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "device_code parameter is required")
+	}
+
+	authorization, errCode, errDescription, err := i.pollDeviceAuthorization(ns, provider.name, client.ClientID, deviceCode)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if errCode != "" {
+		return tokenResponse(nil, errCode, errDescription)
+	}
+
+	// The entity that approved the request was authorized by the client's
+	// assignments then, and must still be
+	entity, err := i.MemDBEntityByID(authorization.entityID, true)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if entity == nil {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity that approved the request not found")
+	}
+	if entity.Disabled {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity that approved the request is disabled")
+	}
+	isMember, err := i.entityHasAssignment(ctx, req.Storage, entity, client.Assignments)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if !isMember {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity not authorized by client assignment")
+	}
+
+	return i.issueOIDCTokens(ctx, req, ns, provider, client, key, entity, tokenGrant{
+		scopes:        authorization.scopes,
+		authTime:      authorization.authTime,
+		offlineAccess: authorization.offlineAccess,
+	})
+}
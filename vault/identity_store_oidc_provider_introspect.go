@@ -0,0 +1,257 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func oidcProviderIntrospectPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/introspect",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"token": {
+					Type:        framework.TypeString,
+					Description: "The access token to introspect.",
+				},
+				"token_type_hint": {
+					Type:        framework.TypeString,
+					Description: "A hint about the type of the token. Only access tokens can be introspected, so the hint is ignored.",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "The ID of the requesting client, which must be the subject of the client assertion if set. Clients otherwise authenticate with the HTTP Basic authentication scheme.",
+				},
+				"client_assertion_type": {
+					Type:        framework.TypeString,
+					Description: "The type of the client assertion, which must be 'urn:ietf:params:oauth:client-assertion-type:jwt-bearer'. Required for the 'private_key_jwt' authentication method.",
+				},
+				"client_assertion": {
+					Type:        framework.TypeString,
+					Description: "A JWT signed by one of the registered keys of the client. Required for the 'private_key_jwt' authentication method.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:    i.pathOIDCProviderIntrospect,
+					Summary:     "Introspect an access token issued by the provider.",
+					OperationID: "introspectOIDCProviderToken",
+					Responses: oidcProviderResponses(map[string]*framework.FieldSchema{
+						"active": {
+							Type:        framework.TypeBool,
+							Description: "Whether the token is an active access token issued by the provider.",
+							Required:    true,
+						},
+						"sub": {
+							Type:        framework.TypeString,
+							Description: "The subject identifier of the entity that the token was issued for, which is unique to the client for pairwise clients, or the client ID for tokens of the client_credentials grant without an entity.",
+						},
+						"aud": {
+							Type:        framework.TypeString,
+							Description: "The client ID of the client that the token was issued to.",
+						},
+						"client_id": {
+							Type:        framework.TypeString,
+							Description: "The client ID of the client that the token was issued to.",
+						},
+						"exp": {
+							Type:        framework.TypeInt64,
+							Description: "The time the token expires at, in seconds since the Unix epoch.",
+						},
+						"iat": {
+							Type:        framework.TypeInt64,
+							Description: "The time the token was issued at, in seconds since the Unix epoch.",
+						},
+						"iss": {
+							Type:        framework.TypeString,
+							Description: "The issuer of the provider.",
+						},
+						"scope": {
+							Type:        framework.TypeString,
+							Description: "The space-delimited scopes granted to the token.",
+						},
+						"token_type": {
+							Type:        framework.TypeString,
+							Description: "The type of the token, which is always Bearer.",
+						},
+					}, nil, http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError),
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: false,
+				},
+			},
+			HelpSynopsis:    "Introspect access tokens issued by the provider.",
+			HelpDescription: "Returns whether an access token is active, and its claims if it is, as described by RFC 7662. Clients authenticate with their token_endpoint_auth_method, and may only introspect their own access tokens unless the provider allows cross-client introspection.",
+		},
+	}
+}
+
+// pathOIDCProviderIntrospect handles token introspection requests of
+// confidential clients. Tokens that aren't active access tokens of the
+// provider, or that the client isn't allowed to introspect, are reported as
+// inactive rather than with an error.
+// See https://datatracker.ietf.org/doc/html/rfc7662
+func (i *IdentityStore) pathOIDCProviderIntrospect(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	// Get the namespace
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+
+	// Get the OIDC provider
+	name := d.Get("name").(string)
+	provider, err := i.getOIDCProvider(ctx, req.Storage, name)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if provider == nil {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "provider not found")
+	}
+
+	// Authenticate the client with its authentication method. Public
+	// clients can't authenticate, so they can't introspect.
+	client, errCode, errDescription, err := i.authenticateClient(ctx, req, d, ns, provider)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if errCode != "" {
+		return tokenResponse(nil, errCode, errDescription)
+	}
+	if client.Type != confidential {
+		i.Logger().Debug("public client refused for token introspection", "client_id", client.ClientID)
+		return tokenResponse(nil, ErrTokenInvalidClient, "client failed to authenticate")
+	}
+	clientID := client.ClientID
+	if client.expired(time.Now()) {
+		return tokenResponse(nil, ErrTokenInvalidClient, "client has expired")
+	}
+
+	// Validate that the client is authorized to use the provider
+	if !strutil.StrListContains(provider.AllowedClientIDs, "*") &&
+		!strutil.StrListContains(provider.AllowedClientIDs, clientID) {
+		return tokenResponse(nil, ErrTokenInvalidClient, "client is not authorized to use the provider")
+	}
+
+	token := d.Get("token").(string)
+	if token == "" {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "token parameter is required")
+	}
+
+	claims, err := i.introspectAccessToken(ctx, req.Storage, ns, provider, client, token)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if claims == nil {
+		return tokenResponse(map[string]interface{}{"active": false}, "", "")
+	}
+
+	return tokenResponse(claims, "", "")
+}
+
+// introspectAccessToken returns the introspection response of the access
+// token for the client, or nil if the token isn't active or the client isn't
+// allowed to introspect it. The token is active under the same conditions
+// as those of the userinfo endpoint.
+func (i *IdentityStore) introspectAccessToken(ctx context.Context, s logical.Storage, ns *namespace.Namespace, provider *provider, caller *client, token string) (map[string]interface{}, error) {
+	// Malformed and expired tokens fail the lookup or aren't found, and are
+	// inactive rather than an error
+	te, err := i.tokenStorer.LookupToken(ctx, token)
+	if err != nil {
+		i.Logger().Debug("failed to look up introspected token", "error", err)
+		return nil, nil
+	}
+	if te == nil || te.Type != logical.TokenTypeBatch {
+		return nil, nil
+	}
+
+	// Tokens of other providers, including those of the same mount, are
+	// inactive to the clients of this one
+	if !accessTokenIssuedByProvider(te, ns, provider.name) {
+		return nil, nil
+	}
+	revoked, err := accessTokenRevoked(ctx, s, provider.name, te)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, nil
+	}
+
+	clientID, ok := te.InternalMeta[accessTokenClientIDMeta]
+	if !ok {
+		return nil, nil
+	}
+	if clientID != caller.ClientID && !provider.AllowCrossClientIntrospection {
+		return nil, nil
+	}
+	client, err := i.clientByID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || (client.RevokeTokensOnExpiry && client.expired(time.Now())) {
+		return nil, nil
+	}
+	if !strutil.StrListContains(provider.AllowedClientIDs, "*") &&
+		!strutil.StrListContains(provider.AllowedClientIDs, clientID) {
+		return nil, nil
+	}
+
+	// Tokens of the client_credentials grant are issued for the client, or
+	// for the entity it's bound to without its assignments being checked
+	clientCredentials := te.InternalMeta[accessTokenGrantTypeMeta] == clientCredentialsGrantType
+	subject := clientID
+	if te.EntityID != "" {
+		entity, err := i.MemDBEntityByID(te.EntityID, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil || entity.Disabled {
+			return nil, nil
+		}
+		if !clientCredentials {
+			isMember, err := i.entityHasAssignment(ctx, s, entity, client.Assignments)
+			if err != nil {
+				return nil, err
+			}
+			if !isMember {
+				return nil, nil
+			}
+		}
+		subject, err = i.subjectForClient(ctx, s, provider.name, client, entity.ID)
+		if err != nil {
+			return nil, err
+		}
+	} else if !clientCredentials {
+		return nil, nil
+	}
+
+	var scopes []string
+	if !clientCredentials {
+		scopes = append(scopes, openIDScope)
+	}
+	if tokenScopes := te.InternalMeta[accessTokenScopesMeta]; tokenScopes != "" {
+		scopes = append(scopes, strutil.ParseStringSlice(tokenScopes, scopesDelimiter)...)
+	}
+
+	return map[string]interface{}{
+		"active":     true,
+		"sub":        subject,
+		"aud":        clientID,
+		"client_id":  clientID,
+		"exp":        te.CreationTime + int64(te.TTL.Seconds()),
+		"iat":        te.CreationTime,
+		"iss":        provider.effectiveIssuer,
+		"scope":      strings.Join(strutil.RemoveDuplicatesStable(scopes, false), scopesDelimiter),
+		"token_type": "Bearer",
+	}, nil
+}
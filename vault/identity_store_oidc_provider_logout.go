@@ -0,0 +1,206 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func oidcProviderLogoutPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/logout",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"id_token_hint": {
+					Type:        framework.TypeString,
+					Description: "An ID token issued by the provider to the client, which identifies the end-user and client of the session. Expired ID tokens are accepted.",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "The ID of the client. If set, it must be the audience of the id_token_hint.",
+				},
+				"post_logout_redirect_uri": {
+					Type:        framework.TypeString,
+					Description: "The URI to redirect the end-user to after logging out, which must be one of the post_logout_redirect_uris of the client.",
+				},
+				"state": {
+					Type:        framework.TypeString,
+					Description: "The value used by the client to maintain state between the logout request and the callback to the post_logout_redirect_uri.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback:                    i.pathOIDCLogout,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: false,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:                    i.pathOIDCLogout,
+					ForwardPerformanceStandby:   true,
+					ForwardPerformanceSecondary: false,
+				},
+			},
+			HelpSynopsis:    "Ends the session of an end-user with a client of the provider.",
+			HelpDescription: "The logout endpoint of the OIDC provider, as described by the OpenID Connect RP-Initiated Logout specification. The Vault token of the request is revoked if it belongs to the end-user of the id_token_hint.",
+		},
+	}
+}
+
+// registeredRedirectURIs returns the redirect URIs and post-logout redirect
+// URIs of the client, which must all be allowed by its providers.
+func (c *client) registeredRedirectURIs() []string {
+	uris := make([]string, 0, len(c.RedirectURIs)+len(c.PostLogoutRedirectURIs))
+	uris = append(uris, c.RedirectURIs...)
+	return append(uris, c.PostLogoutRedirectURIs...)
+}
+
+// pathOIDCLogout ends the session of the end-user of the id_token_hint by
+// revoking the Vault token of the request, which is the token that the UI
+// used to authorize the client. Errors are always rendered rather than
+// redirected, so that the endpoint can't be used as an open redirect.
+// See https://openid.net/specs/openid-connect-rpinitiated-1_0.html
+func (i *IdentityStore) pathOIDCLogout(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	state := d.Get("state").(string)
+
+	// Get the OIDC provider
+	name := d.Get("name").(string)
+	provider, err := i.getOIDCProvider(ctx, req.Storage, name)
+	if err != nil {
+		return logoutResponse("", state, ErrAuthServerError, err.Error())
+	}
+	if provider == nil {
+		return logoutResponse("", state, ErrAuthInvalidRequest, "provider not found")
+	}
+
+	// Validate that the ID token hint was issued by the provider to one of
+	// its clients
+	idTokenHint := d.Get("id_token_hint").(string)
+	if idTokenHint == "" {
+		return logoutResponse("", state, ErrAuthInvalidRequest, "id_token_hint parameter is required")
+	}
+	claims, client, err := i.verifyIDTokenHint(ctx, req.Storage, provider, idTokenHint)
+	if err != nil {
+		return logoutResponse("", state, ErrAuthServerError, err.Error())
+	}
+	if claims == nil {
+		return logoutResponse("", state, ErrAuthInvalidRequest, "id_token_hint is invalid or was not issued by the provider")
+	}
+	if clientID := d.Get("client_id").(string); clientID != "" && clientID != client.ClientID {
+		return logoutResponse("", state, ErrAuthInvalidClientID, "client_id is not the audience of the id_token_hint")
+	}
+
+	// Validate that the post-logout redirect URI is registered by the client
+	// and allowed by the provider
+	redirectURI := d.Get("post_logout_redirect_uri").(string)
+	if redirectURI != "" {
+		if !validRedirect(redirectURI, client.PostLogoutRedirectURIs, !client.DisallowLoopbackRedirects) ||
+			len(disallowedRedirectURIs([]string{redirectURI}, provider.AllowedRedirectHosts)) > 0 {
+			return logoutResponse("", state, ErrAuthInvalidRedirectURI, "post_logout_redirect_uri is not registered for the client")
+		}
+	}
+
+	// Revoke the Vault token of the session, so that the end-user must log
+	// in again, even with prompt=none. Tokens of other entities are left
+	// alone, since the request may come from another user of the browser.
+	if req.ClientToken != "" {
+		te, err := i.requestTokenEntry(ctx, req)
+		if err != nil {
+			return logoutResponse("", state, ErrAuthServerError, err.Error())
+		}
+		if te != nil && te.EntityID != "" && te.Type != logical.TokenTypeBatch {
+			// The subject of the ID tokens of pairwise clients isn't the
+			// entity ID
+			subject, err := i.subjectForClient(ctx, req.Storage, name, client, te.EntityID)
+			if err != nil {
+				return logoutResponse("", state, ErrAuthServerError, err.Error())
+			}
+			if subject == claims.Subject {
+				if err := i.tokenStorer.RevokeToken(ctx, te); err != nil {
+					return logoutResponse("", state, ErrAuthServerError, err.Error())
+				}
+				i.Logger().Debug("revoked the token of an OIDC session on logout", "provider", name,
+					"client_id", client.ClientID, "entity_id", te.EntityID)
+			}
+		}
+	}
+
+	return logoutResponse(redirectURI, state, "", "")
+}
+
+// verifyIDTokenHint returns the claims of the given ID token and the client it
+// was issued to, or nil if it wasn't issued by the provider to one of its
+// clients. Expired ID tokens are accepted, since sessions usually outlive
+// their ID tokens.
+func (i *IdentityStore) verifyIDTokenHint(ctx context.Context, s logical.Storage, provider *provider, idTokenHint string) (*jwt.Claims, *client, error) {
+	parsedJWT, err := jwt.ParseSigned(idTokenHint)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	// The audience of the unverified claims finds the client, whose keys
+	// then verify the signature
+	var unverified jwt.Claims
+	if err := parsedJWT.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return nil, nil, nil
+	}
+	if unverified.Issuer != provider.effectiveIssuer || len(unverified.Audience) != 1 {
+		return nil, nil, nil
+	}
+	client, err := i.clientByID(unverified.Audience[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		return nil, nil, nil
+	}
+	if !strutil.StrListContains(provider.AllowedClientIDs, "*") &&
+		!strutil.StrListContains(provider.AllowedClientIDs, client.ClientID) {
+		return nil, nil, nil
+	}
+
+	keyIDs, err := i.keyIDsReferencedByTargetClientIDs(ctx, s, []string{client.ClientID}, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	jwks, err := keyIDs.load(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, key := range jwks.Keys {
+		var claims jwt.Claims
+		if err := parsedJWT.Claims(key, &claims); err == nil {
+			return &claims, client, nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+// logoutResponse returns the response of the logout endpoint, which the UI
+// redirects to the post-logout redirect URI, if any, with the state.
+func logoutResponse(redirectURI, state, errorCode, errorDescription string) (*logical.Response, error) {
+	if errorCode != "" {
+		statusCode := http.StatusBadRequest
+		if errorCode == ErrAuthServerError {
+			statusCode = http.StatusInternalServerError
+		}
+
+		return oidcProviderError(errorCode, errorDescription, statusCode, map[string]interface{}{
+			"state": state,
+		})
+	}
+
+	return oidcProviderResponse(http.StatusOK, map[string]interface{}{
+		"post_logout_redirect_uri": redirectURI,
+		"state":                    state,
+	})
+}
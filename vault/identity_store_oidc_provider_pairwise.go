@@ -0,0 +1,69 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// pairwiseSaltPath is the storage prefix of the salts of the pairwise
+	// subject identifiers of providers, stored as pairwiseSaltPath/<provider>
+	pairwiseSaltPath = oidcProviderPrefix + "pairwise_salt/"
+
+	// subjectTypePublic clients get the entity ID as the subject of their
+	// tokens, and subjectTypePairwise clients get an identifier of the
+	// entity that's unique to the client.
+	// See https://openid.net/specs/openid-connect-core-1_0.html#SubjectIDTypes
+	subjectTypePublic   = "public"
+	subjectTypePairwise = "pairwise"
+)
+
+var supportedSubjectTypes = []string{subjectTypePublic, subjectTypePairwise}
+
+// subjectType returns the subject type of the client. Clients created before
+// subject types were introduced are public.
+func (c *client) subjectType() string {
+	if c.SubjectType == "" {
+		return subjectTypePublic
+	}
+	return c.SubjectType
+}
+
+// sectorIdentifier returns the identifier of the client's sector, which
+// pairwise subject identifiers are computed for. The client ID is used, so
+// that subjects stay stable when the redirect URIs of the client change.
+func (c *client) sectorIdentifier() string {
+	return c.ClientID
+}
+
+// pairwiseSalt returns the salt of the pairwise subject identifiers of the
+// provider, which is generated on first use.
+func (i *IdentityStore) pairwiseSalt(ctx context.Context, s logical.Storage, provider string) (*salt.Salt, error) {
+	i.oidcPairwiseSaltLock.Lock()
+	defer i.oidcPairwiseSaltLock.Unlock()
+
+	return salt.NewSalt(ctx, s, &salt.Config{
+		Location: pairwiseSaltPath + provider,
+		HashFunc: salt.SHA256Hash,
+	})
+}
+
+// subjectForClient returns the sub claim of the entity for the client of the
+// provider, which is the entity ID for public clients. For pairwise clients,
+// it's an HMAC of the sector identifier of the client and the entity ID, so
+// the entity gets the same subject across logins to the client, but different
+// subjects across clients.
+func (i *IdentityStore) subjectForClient(ctx context.Context, s logical.Storage, provider string, client *client, entityID string) (string, error) {
+	if client.subjectType() != subjectTypePairwise {
+		return entityID, nil
+	}
+
+	pairwiseSalt, err := i.pairwiseSalt(ctx, s, provider)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the pairwise salt of provider %q: %w", provider, err)
+	}
+	return pairwiseSalt.GetHMAC(client.sectorIdentifier() + "/" + entityID), nil
+}
@@ -0,0 +1,264 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/base62"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// offlineAccessScope is the scope value that requests a refresh token
+	// along with the ID and access tokens. See details at
+	// https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess
+	offlineAccessScope = "offline_access"
+
+	// refreshTokenPath is the storage prefix of the refresh tokens issued by
+	// providers, which are stored by provider and the hash of the token as
+	// refreshTokenPath/<provider>/<token hash>
+	refreshTokenPath = oidcProviderPrefix + "refresh_token/"
+)
+
+// refreshToken is a refresh token issued by a provider. Only the hash of the
+// token is stored. Refresh tokens are rotated on every use, and the tokens of
+// a login can't be refreshed past the refresh_token_max_ttl of the client.
+type refreshToken struct {
+	ClientID    string    `json:"client_id"`
+	EntityID    string    `json:"entity_id"`
+	Scopes      []string  `json:"scopes"`
+	AuthTime    time.Time `json:"auth_time"`
+	AuthMethods []string  `json:"auth_methods"`
+	IssuedAt    time.Time `json:"issued_at"`
+	ExpireAt    time.Time `json:"expire_at"`
+
+	// LoginAt is the time the first refresh token of the login was issued,
+	// which the refresh_token_max_ttl of the client is counted from
+	LoginAt time.Time `json:"login_at"`
+}
+
+func refreshTokenKey(provider, token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return refreshTokenPath + provider + "/" + hex.EncodeToString(hash[:])
+}
+
+// refreshTokenExpiry returns the expiry of a refresh token issued to the
+// client at the given time for a login at loginAt, or the zero time if the
+// login can no longer be refreshed.
+func (c *client) refreshTokenExpiry(loginAt, now time.Time) time.Time {
+	expireAt := now.Add(c.RefreshTokenTTL)
+	if c.RefreshTokenMaxTTL > 0 && loginAt.Add(c.RefreshTokenMaxTTL).Before(expireAt) {
+		expireAt = loginAt.Add(c.RefreshTokenMaxTTL)
+	}
+	if !expireAt.After(now) {
+		return time.Time{}
+	}
+	return expireAt
+}
+
+// issueRefreshToken issues a refresh token for the grant to the client, or
+// returns an empty token if the client doesn't issue refresh tokens or the
+// login can no longer be refreshed.
+func issueRefreshToken(ctx context.Context, s logical.Storage, provider *provider, client *client, entityID string, grant tokenGrant, now time.Time) (string, error) {
+	if client.RefreshTokenTTL <= 0 || !client.allowsGrantType("refresh_token") {
+		return "", nil
+	}
+
+	loginAt := grant.loginAt
+	if loginAt.IsZero() {
+		loginAt = now
+	}
+	expireAt := client.refreshTokenExpiry(loginAt, now)
+	if expireAt.IsZero() {
+		return "", nil
+	}
+
+	token, err := base62.Random(64)
+	if err != nil {
+		return "", err
+	}
+	entry, err := logical.StorageEntryJSON(refreshTokenKey(provider.name, token), &refreshToken{
+		ClientID:    client.ClientID,
+		EntityID:    entityID,
+		Scopes:      grant.scopes,
+		AuthTime:    grant.authTime,
+		AuthMethods: grant.authMethods,
+		IssuedAt:    now,
+		ExpireAt:    expireAt,
+		LoginAt:     loginAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// takeRefreshToken returns the refresh token issued by the provider to the
+// client and deletes it, so that each refresh token is used once. Nil is
+// returned if the token doesn't exist, has expired or wasn't issued to the
+// client, in which case it isn't deleted.
+func (i *IdentityStore) takeRefreshToken(ctx context.Context, s logical.Storage, provider, clientID, token string) (*refreshToken, error) {
+	i.oidcRefreshTokenLock.Lock()
+	defer i.oidcRefreshTokenLock.Unlock()
+
+	key := refreshTokenKey(provider, token)
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var rt refreshToken
+	if err := entry.DecodeJSON(&rt); err != nil {
+		return nil, err
+	}
+	if rt.ClientID != clientID || !time.Now().Before(rt.ExpireAt) {
+		return nil, nil
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// oidcRefreshTokenGrant handles the refresh token grant, which rotates the
+// refresh token and issues new tokens for its login. The entity must still
+// be enabled and authorized by the client's assignments.
+// See https://openid.net/specs/openid-connect-core-1_0.html#RefreshTokens
+func (i *IdentityStore) oidcRefreshTokenGrant(ctx context.Context, req *logical.Request, d *framework.FieldData, ns *namespace.Namespace, provider *provider, client *client, key *namedKey) (*logical.Response, error) {
+	token := d.Get("refresh_token").(string)
+	if token == "" {
+		return tokenResponse(nil, ErrTokenInvalidRequest, "refresh_token parameter is required")
+	}
+
+	// The requested scopes may narrow the scopes of the login, but never
+	// widen them
+	var requestedScopes []string
+	if scopeRaw, ok := d.GetOk("scope"); ok {
+		requestedScopes = strutil.ParseDedupAndSortStrings(scopeRaw.(string), scopesDelimiter)
+	}
+
+	rt, err := i.takeRefreshToken(ctx, req.Storage, provider.name, client.ClientID, token)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if rt == nil {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "refresh token is invalid or expired")
+	}
+
+	scopes := rt.Scopes
+	if len(requestedScopes) > 0 {
+		scopes = make([]string, 0, len(rt.Scopes))
+		for _, scope := range requestedScopes {
+			switch {
+			case scope == openIDScope || scope == offlineAccessScope:
+			case strutil.StrListContains(rt.Scopes, scope):
+				scopes = append(scopes, scope)
+			default:
+				return tokenResponse(nil, ErrTokenInvalidScope, fmt.Sprintf("scope %q was not granted to the refresh token", scope))
+			}
+		}
+	}
+
+	entity, err := i.MemDBEntityByID(rt.EntityID, true)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if entity == nil {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity of the refresh token not found")
+	}
+	if entity.Disabled {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity of the refresh token is disabled")
+	}
+
+	// Membership is evaluated again, so that entities removed from the
+	// client's assignments can no longer refresh their tokens
+	isMember, err := i.entityHasAssignment(ctx, req.Storage, entity, client.Assignments)
+	if err != nil {
+		return tokenResponse(nil, ErrTokenServerError, err.Error())
+	}
+	if !isMember {
+		return tokenResponse(nil, ErrTokenInvalidGrant, "identity entity not authorized by client assignment")
+	}
+
+	return i.issueOIDCTokens(ctx, req, ns, provider, client, key, entity, tokenGrant{
+		scopes:        scopes,
+		authTime:      rt.AuthTime,
+		authMethods:   rt.AuthMethods,
+		offlineAccess: true,
+		loginAt:       rt.LoginAt,
+	})
+}
+
+// deleteRefreshTokens deletes the unexpired refresh tokens issued by the
+// provider that match, which revokes them, and returns their number. None
+// are matched if match is nil. Expired tokens are always deleted.
+func deleteRefreshTokens(ctx context.Context, scan *oidcArtifactScan, provider string, match func(*refreshToken) bool) (int, error) {
+	revoked := 0
+	now := time.Now()
+	err := scan.reap(ctx, refreshTokenPath+provider+"/", false, &oidcTidyCounts{}, func(entry *logical.StorageEntry) (bool, error) {
+		var rt refreshToken
+		if err := entry.DecodeJSON(&rt); err != nil {
+			return false, err
+		}
+		if !now.Before(rt.ExpireAt) {
+			return true, nil
+		}
+		if match == nil || !match(&rt) {
+			return false, nil
+		}
+		revoked++
+		return true, nil
+	})
+	return revoked, err
+}
+
+// tidyRefreshTokens deletes expired refresh tokens, and those of providers
+// that no longer exist, unless this is a dry run.
+func tidyRefreshTokens(ctx context.Context, scan *oidcArtifactScan, dryRun bool) (*oidcTidyCounts, error) {
+	counts := &oidcTidyCounts{}
+	providers, err := scan.storage.List(ctx, refreshTokenPath)
+	if err != nil {
+		return counts, err
+	}
+
+	now := time.Now()
+	for _, provider := range providers {
+		provider = strings.TrimSuffix(provider, "/")
+		entry, err := scan.storage.Get(ctx, providerPath+provider)
+		if err != nil {
+			return counts, err
+		}
+		deleted := entry == nil
+
+		err = scan.reap(ctx, refreshTokenPath+provider+"/", dryRun, counts, func(entry *logical.StorageEntry) (bool, error) {
+			if deleted {
+				return true, nil
+			}
+			var rt refreshToken
+			if err := entry.DecodeJSON(&rt); err != nil {
+				return false, err
+			}
+			return !now.Before(rt.ExpireAt), nil
+		})
+		if err != nil {
+			return counts, fmt.Errorf("failed to tidy the refresh tokens of provider %q: %w", provider, err)
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,262 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// issuedAccessTokenPath is the storage prefix of the access tokens
+	// issued by providers, which are stored by provider and entity as
+	// issuedAccessTokenPath/<provider>/<owner>/<tracking ID>, where the
+	// owner is the entity ID, or client-<client ID> for tokens without one
+	issuedAccessTokenPath = oidcProviderPrefix + "issued_access_token/"
+
+	// accessTokenTrackingIDMeta is the internal metadata of access tokens
+	// that holds the tracking ID of their issuedAccessToken entry
+	accessTokenTrackingIDMeta = "tracking_id"
+)
+
+// issuedAccessToken links an access token issued by a provider to its client
+// and entity, so that the access tokens of a client or entity can be revoked
+// in bulk. Access tokens are batch tokens, which can't be revoked themselves,
+// so the userinfo endpoint refuses access tokens whose entry was deleted.
+type issuedAccessToken struct {
+	ClientID string    `json:"client_id"`
+	EntityID string    `json:"entity_id"`
+	IssuedAt time.Time `json:"issued_at"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+func issuedAccessTokenKey(provider, owner, trackingID string) string {
+	return issuedAccessTokenPath + provider + "/" + owner + "/" + trackingID
+}
+
+// issuedAccessTokenOwner returns the storage segment that the access tokens
+// of the entity are stored under, or those of the client if the tokens have
+// no entity, as with the client_credentials grant.
+func issuedAccessTokenOwner(entityID, clientID string) string {
+	if entityID == "" {
+		return "client-" + clientID
+	}
+	return entityID
+}
+
+func oidcProviderRevokePaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/revoke-by",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"entity_id": {
+					Type:        framework.TypeString,
+					Description: "The ID of the entity whose access and refresh tokens are revoked.",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "The client ID of the client whose access and refresh tokens are revoked.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: i.pathOIDCProviderRevokeBy,
+				},
+			},
+			HelpSynopsis:    "Revoke the access and refresh tokens issued by a provider to an entity or client.",
+			HelpDescription: "Revoke the outstanding access and refresh tokens issued by the provider that match the given entity ID, client ID, or both. ID tokens are signed and can't be revoked.",
+		},
+	}
+}
+
+// trackIssuedAccessToken sets the tracking ID of an access token about to be
+// issued and returns its entry, which is stored by putIssuedAccessToken once
+// the token is created.
+func trackIssuedAccessToken(te *logical.TokenEntry, client *client, issuedAt, expireAt time.Time) (string, *issuedAccessToken, error) {
+	trackingID, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", nil, err
+	}
+	te.InternalMeta[accessTokenTrackingIDMeta] = trackingID
+
+	return trackingID, &issuedAccessToken{
+		ClientID: client.ClientID,
+		EntityID: te.EntityID,
+		IssuedAt: issuedAt,
+		ExpireAt: expireAt,
+	}, nil
+}
+
+func putIssuedAccessToken(ctx context.Context, s logical.Storage, provider, trackingID string, token *issuedAccessToken) error {
+	entry, err := logical.StorageEntryJSON(issuedAccessTokenKey(provider, issuedAccessTokenOwner(token.EntityID, token.ClientID), trackingID), token)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// accessTokenRevoked returns true if the access token was revoked by its
+// provider. Access tokens issued before they were tracked can't be revoked.
+func accessTokenRevoked(ctx context.Context, s logical.Storage, provider string, te *logical.TokenEntry) (bool, error) {
+	trackingID, ok := te.InternalMeta[accessTokenTrackingIDMeta]
+	if !ok {
+		return false, nil
+	}
+
+	owner := issuedAccessTokenOwner(te.EntityID, te.InternalMeta[accessTokenClientIDMeta])
+	entry, err := s.Get(ctx, issuedAccessTokenKey(provider, owner, trackingID))
+	if err != nil {
+		return false, err
+	}
+	return entry == nil, nil
+}
+
+// pathOIDCProviderRevokeBy revokes the access and refresh tokens issued by a
+// provider that match the given entity ID, client ID, or both.
+func (i *IdentityStore) pathOIDCProviderRevokeBy(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	entityID := strings.TrimSpace(d.Get("entity_id").(string))
+	clientID := strings.TrimSpace(d.Get("client_id").(string))
+	if entityID == "" && clientID == "" {
+		return logical.ErrorResponse("entity_id or client_id is required"), nil
+	}
+
+	provider, err := i.getOIDCProvider(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return logical.ErrorResponse("provider %q does not exist", name), nil
+	}
+
+	// The tokens are looked up in batches, as the tidy does, since a client
+	// or entity can have many of them
+	scan := newOIDCArtifactScan(req.Storage, 0)
+	revoked, err := deleteIssuedAccessTokens(ctx, scan, name, entityID, func(token *issuedAccessToken) bool {
+		return clientID == "" || token.ClientID == clientID
+	})
+	if err != nil {
+		return nil, err
+	}
+	revokedRefresh, err := deleteRefreshTokens(ctx, scan, name, func(token *refreshToken) bool {
+		return (entityID == "" || token.EntityID == entityID) &&
+			(clientID == "" || token.ClientID == clientID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	i.Logger().Info("revoked OIDC access tokens", "provider", name, "entity_id", entityID,
+		"client_id", clientID, "revoked", revoked, "revoked_refresh_tokens", revokedRefresh)
+
+	// The criteria and counts are audited in plain text
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			oidcProviderAuditRevokedAccessTokens:  revoked,
+			oidcProviderAuditRevokedRefreshTokens: revokedRefresh,
+		},
+	}
+	if entityID != "" {
+		resp.Data[oidcProviderAuditEntityID] = entityID
+	}
+	if clientID != "" {
+		resp.Data[oidcProviderAuditClientID] = clientID
+	}
+	resp.AddWarning("ID tokens are signed and can't be revoked. ID tokens issued to the matching " +
+		"entities and clients stay valid until they expire.")
+	return resp, nil
+}
+
+// deleteIssuedAccessTokens deletes the entries of the unexpired access tokens
+// issued by the provider to the entity that match, which revokes them, and
+// returns their number. Tokens of any entity or client are matched if the
+// entity ID is empty, and none are if match is nil. The entries of expired
+// tokens are always deleted.
+func deleteIssuedAccessTokens(ctx context.Context, scan *oidcArtifactScan, provider, entityID string, match func(*issuedAccessToken) bool) (int, error) {
+	prefix := issuedAccessTokenPath + provider + "/"
+
+	entityIDs := []string{entityID}
+	if entityID == "" {
+		var err error
+		entityIDs, err = scan.storage.List(ctx, prefix)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	revoked := 0
+	now := time.Now()
+	for _, entityID := range entityIDs {
+		err := scan.reap(ctx, prefix+strings.TrimSuffix(entityID, "/")+"/", false, &oidcTidyCounts{}, func(entry *logical.StorageEntry) (bool, error) {
+			var token issuedAccessToken
+			if err := entry.DecodeJSON(&token); err != nil {
+				return false, err
+			}
+			if !now.Before(token.ExpireAt) {
+				return true, nil
+			}
+			if match == nil || !match(&token) {
+				return false, nil
+			}
+			revoked++
+			return true, nil
+		})
+		if err != nil {
+			return revoked, err
+		}
+	}
+
+	return revoked, nil
+}
+
+// tidyIssuedAccessTokens deletes the entries of expired access tokens, and
+// those of providers that no longer exist, unless this is a dry run.
+func tidyIssuedAccessTokens(ctx context.Context, scan *oidcArtifactScan, dryRun bool) (*oidcTidyCounts, error) {
+	counts := &oidcTidyCounts{}
+	providers, err := scan.storage.List(ctx, issuedAccessTokenPath)
+	if err != nil {
+		return counts, err
+	}
+
+	now := time.Now()
+	for _, provider := range providers {
+		provider = strings.TrimSuffix(provider, "/")
+		entry, err := scan.storage.Get(ctx, providerPath+provider)
+		if err != nil {
+			return counts, err
+		}
+		// Every token of a deleted provider is refused, so all of its
+		// entries are deleted
+		deleted := entry == nil
+
+		prefix := issuedAccessTokenPath + provider + "/"
+		owners, err := scan.storage.List(ctx, prefix)
+		if err != nil {
+			return counts, err
+		}
+		for _, owner := range owners {
+			err := scan.reap(ctx, prefix+strings.TrimSuffix(owner, "/")+"/", dryRun, counts, func(entry *logical.StorageEntry) (bool, error) {
+				if deleted {
+					return true, nil
+				}
+				var token issuedAccessToken
+				if err := entry.DecodeJSON(&token); err != nil {
+					return false, err
+				}
+				return !now.Before(token.ExpireAt), nil
+			})
+			if err != nil {
+				return counts, fmt.Errorf("failed to tidy the access tokens of provider %q: %w", provider, err)
+			}
+		}
+	}
+
+	return counts, nil
+}
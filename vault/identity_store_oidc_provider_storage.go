@@ -0,0 +1,389 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/storagepacker"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// oidcBucketsPrefix is the prefix under which the objects of the OIDC
+	// provider are packed into buckets
+	oidcBucketsPrefix = oidcProviderPrefix + "buckets/"
+
+	clientBucketsPrefix     = oidcBucketsPrefix + "client/"
+	scopeBucketsPrefix      = oidcBucketsPrefix + "scope/"
+	assignmentBucketsPrefix = oidcBucketsPrefix + "assignment/"
+)
+
+var (
+	oidcClientStore     = &oidcObjectStore{legacyPrefix: clientPath, bucketsPrefix: clientBucketsPrefix}
+	oidcScopeStore      = &oidcObjectStore{legacyPrefix: scopePath, bucketsPrefix: scopeBucketsPrefix}
+	oidcAssignmentStore = &oidcObjectStore{legacyPrefix: assignmentPath, bucketsPrefix: assignmentBucketsPrefix}
+)
+
+// oidcObjectStore stores the objects of one type of the OIDC provider, such
+// as clients, packed into the buckets of a storage packer by name. Reading
+// or listing thousands of objects then takes at most one storage request per
+// bucket rather than one per object.
+//
+// Objects used to be stored in individual entries under the legacy prefix.
+// These are moved into buckets by migrate, and are read until then.
+// Writing or deleting an object removes its legacy entry.
+//
+// Writes must be serialized by the caller, since a packer is created for
+// every call and only serializes the writes made through it.
+type oidcObjectStore struct {
+	legacyPrefix  string
+	bucketsPrefix string
+}
+
+// oidcObjectEntry is an object read from an oidcObjectStore
+type oidcObjectEntry struct {
+	Name string
+
+	// BucketKey is the storage key of the bucket the object is stored in, or
+	// will be moved into if it is stored in a legacy entry
+	BucketKey string
+
+	// Value is the JSON encoding of the object
+	Value []byte
+}
+
+// DecodeJSON decodes the value of the entry into out.
+func (e *oidcObjectEntry) DecodeJSON(out interface{}) error {
+	return jsonutil.DecodeJSON(e.Value, out)
+}
+
+func (o *oidcObjectStore) packer(s logical.Storage) (*storagepacker.StoragePacker, error) {
+	return storagepacker.NewStoragePacker(s, nil, o.bucketsPrefix)
+}
+
+// bucketKey returns the storage key of the bucket the named object is stored
+// in.
+func (o *oidcObjectStore) bucketKey(s logical.Storage, name string) (string, error) {
+	packer, err := o.packer(s)
+	if err != nil {
+		return "", err
+	}
+
+	return packer.BucketKey(name), nil
+}
+
+// get returns the named object, or nil if it doesn't exist.
+func (o *oidcObjectStore) get(ctx context.Context, s logical.Storage, name string) (*oidcObjectEntry, error) {
+	packer, err := o.packer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketKey := packer.BucketKey(name)
+	bucket, err := packer.GetBucket(ctx, bucketKey)
+	if err != nil {
+		return nil, err
+	}
+	if bucket != nil {
+		for _, item := range bucket.Items {
+			if item.ID == name {
+				return o.decodeItem(bucketKey, item)
+			}
+		}
+	}
+
+	// Fall back to the legacy entry of the object
+	entry, err := s.Get(ctx, o.legacyPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	return &oidcObjectEntry{
+		Name:      name,
+		BucketKey: bucketKey,
+		Value:     entry.Value,
+	}, nil
+}
+
+// put stores the JSON encoding of the given object under the given name and
+// returns the stored entry.
+func (o *oidcObjectStore) put(ctx context.Context, s logical.Storage, name string, v interface{}) (*oidcObjectEntry, error) {
+	packer, err := o.packer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := jsonutil.EncodeJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	item, err := o.encodeItem(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := packer.PutItem(ctx, item); err != nil {
+		return nil, err
+	}
+	if err := s.Delete(ctx, o.legacyPrefix+name); err != nil {
+		return nil, err
+	}
+
+	return &oidcObjectEntry{
+		Name:      name,
+		BucketKey: packer.BucketKey(name),
+		Value:     value,
+	}, nil
+}
+
+// putAll stores the JSON encodings of the given objects under the given
+// names, writing each bucket once, and returns the stored entries in the
+// same order. Buckets are written one after the other, so an error may leave
+// some of the objects stored.
+func (o *oidcObjectStore) putAll(ctx context.Context, s logical.Storage, logger log.Logger, names []string, values []interface{}) ([]*oidcObjectEntry, error) {
+	packer, err := o.packer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*oidcObjectEntry, 0, len(names))
+	items := make([]*storagepacker.Item, 0, len(names))
+	for n, name := range names {
+		value, err := jsonutil.EncodeJSON(values[n])
+		if err != nil {
+			return nil, err
+		}
+		item, err := o.encodeItem(name, value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		entries = append(entries, &oidcObjectEntry{
+			Name:      name,
+			BucketKey: packer.BucketKey(name),
+			Value:     value,
+		})
+	}
+
+	if err := packer.PutMultipleItems(ctx, logger, items); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := s.Delete(ctx, o.legacyPrefix+name); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// delete removes the named object.
+func (o *oidcObjectStore) delete(ctx context.Context, s logical.Storage, name string) error {
+	packer, err := o.packer(s)
+	if err != nil {
+		return err
+	}
+
+	if err := packer.DeleteItem(ctx, name); err != nil {
+		return err
+	}
+
+	return s.Delete(ctx, o.legacyPrefix+name)
+}
+
+// bucket returns the objects stored in the bucket with the given storage key.
+func (o *oidcObjectStore) bucket(ctx context.Context, s logical.Storage, key string) ([]*oidcObjectEntry, error) {
+	packer, err := o.packer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := packer.GetBucket(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if bucket == nil {
+		return nil, nil
+	}
+
+	entries := make([]*oidcObjectEntry, 0, len(bucket.Items))
+	for _, item := range bucket.Items {
+		entry, err := o.decodeItem(key, item)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// entries returns every object, sorted by name.
+func (o *oidcObjectStore) entries(ctx context.Context, s logical.Storage) ([]*oidcObjectEntry, error) {
+	packer, err := o.packer(s)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketKeys, err := s.List(ctx, o.bucketsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*oidcObjectEntry
+	names := make(map[string]struct{})
+	for _, key := range bucketKeys {
+		bucketEntries, err := o.bucket(ctx, s, o.bucketsPrefix+key)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range bucketEntries {
+			names[entry.Name] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+
+	// Add the objects that are still stored in legacy entries
+	legacyNames, err := s.List(ctx, o.legacyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range legacyNames {
+		if _, ok := names[name]; ok {
+			continue
+		}
+
+		entry, err := s.Get(ctx, o.legacyPrefix+name)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		entries = append(entries, &oidcObjectEntry{
+			Name:      name,
+			BucketKey: packer.BucketKey(name),
+			Value:     entry.Value,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
+// list returns the sorted names of every object.
+func (o *oidcObjectStore) list(ctx context.Context, s logical.Storage) ([]string, error) {
+	entries, err := o.entries(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+
+	return names, nil
+}
+
+// migrate moves the objects stored in legacy entries into buckets. The
+// legacy entries are only deleted once every object has been packed, so
+// that an interrupted migration is resumed on the next one.
+func (o *oidcObjectStore) migrate(ctx context.Context, s logical.Storage, logger log.Logger) error {
+	legacyNames, err := s.List(ctx, o.legacyPrefix)
+	if err != nil {
+		return err
+	}
+	if len(legacyNames) == 0 {
+		return nil
+	}
+
+	packer, err := o.packer(s)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	var items []*storagepacker.Item
+	for _, name := range legacyNames {
+		entry, err := s.Get(ctx, o.legacyPrefix+name)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		item, err := o.encodeItem(name, entry.Value)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+		items = append(items, item)
+	}
+
+	if err := packer.PutMultipleItems(ctx, logger, items); err != nil {
+		return fmt.Errorf("failed to pack OIDC objects: %w", err)
+	}
+
+	for _, name := range names {
+		if err := s.Delete(ctx, o.legacyPrefix+name); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("migrated OIDC objects into buckets", "prefix", o.legacyPrefix, "count", len(names))
+
+	return nil
+}
+
+func (o *oidcObjectStore) encodeItem(name string, value []byte) (*storagepacker.Item, error) {
+	message, err := ptypes.MarshalAny(&wrappers.BytesValue{Value: value})
+	if err != nil {
+		return nil, err
+	}
+
+	return &storagepacker.Item{
+		ID:      name,
+		Message: message,
+	}, nil
+}
+
+func (o *oidcObjectStore) decodeItem(bucketKey string, item *storagepacker.Item) (*oidcObjectEntry, error) {
+	var value wrappers.BytesValue
+	if err := ptypes.UnmarshalAny(item.Message, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode OIDC object %q: %w", item.ID, err)
+	}
+
+	return &oidcObjectEntry{
+		Name:      item.ID,
+		BucketKey: bucketKey,
+		Value:     value.Value,
+	}, nil
+}
+
+// migrateOIDCObjects moves the clients, scopes and assignments of the OIDC
+// provider that are stored in legacy entries into buckets.
+func (i *IdentityStore) migrateOIDCObjects(ctx context.Context, s logical.Storage) error {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	for _, store := range []*oidcObjectStore{oidcClientStore, oidcScopeStore, oidcAssignmentStore} {
+		if err := store.migrate(ctx, s, i.logger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/benchhelpers"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLegacyOIDCObject writes an object in its individual storage entry, as
+// versions that don't pack objects into buckets do.
+func writeLegacyOIDCObject(t testing.TB, s logical.Storage, path, name string, v interface{}) {
+	t.Helper()
+
+	entry, err := logical.StorageEntryJSON(path+name, v)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+}
+
+// TestOIDC_ObjectStore_Migrate tests that clients, scopes and assignments
+// stored in individual entries are served before and after they are packed
+// into buckets.
+func TestOIDC_ObjectStore_Migrate(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	storage := c.identityStore.view
+
+	writeLegacyOIDCObject(t, storage, assignmentPath, "test-assignment", &assignment{
+		EntityIDs: []string{"test-entity"},
+	})
+	writeLegacyOIDCObject(t, storage, scopePath, "test-scope", &scope{
+		Template: `{"name": {{identity.entity.name}}}`,
+	})
+	for n := 0; n < 10; n++ {
+		writeLegacyOIDCObject(t, storage, clientPath, fmt.Sprintf("test-client-%d", n), &client{
+			Name:        fmt.Sprintf("test-client-%d", n),
+			NamespaceID: namespace.RootNamespaceID,
+			Assignments: []string{"test-assignment"},
+			Key:         defaultKeyName,
+			ClientID:    fmt.Sprintf("test-client-id-%d", n),
+		})
+	}
+	require.NoError(t, c.identityStore.loadOIDCClients(ctx))
+
+	assertObjects := func() {
+		t.Helper()
+
+		resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/assignment/test-assignment",
+			Operation: logical.ReadOperation,
+			Storage:   storage,
+		})
+		expectSuccess(t, resp, err)
+		require.Equal(t, []string{"test-entity"}, resp.Data["entity_ids"])
+
+		resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/assignment",
+			Operation: logical.ListOperation,
+			Storage:   storage,
+		})
+		expectSuccess(t, resp, err)
+		require.Equal(t, []string{allowAllAssignmentName, "test-assignment"}, resp.Data["keys"])
+
+		resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/scope",
+			Operation: logical.ListOperation,
+			Storage:   storage,
+		})
+		expectSuccess(t, resp, err)
+		require.Equal(t, []string{"test-scope"}, resp.Data["keys"])
+
+		resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/client",
+			Operation: logical.ListOperation,
+			Storage:   storage,
+		})
+		expectSuccess(t, resp, err)
+		require.Len(t, resp.Data["keys"], 10)
+
+		// The assignment can't be deleted while clients reference it
+		resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/assignment/test-assignment",
+			Operation: logical.DeleteOperation,
+			Storage:   storage,
+		})
+		expectError(t, resp, err)
+
+		for n := 0; n < 10; n++ {
+			client, err := c.identityStore.clientByID(fmt.Sprintf("test-client-id-%d", n))
+			require.NoError(t, err)
+			require.NotNil(t, client)
+			require.Equal(t, fmt.Sprintf("test-client-%d", n), client.Name)
+		}
+	}
+
+	// Objects are read from their legacy entries until they are migrated
+	assertObjects()
+
+	require.NoError(t, c.identityStore.migrateOIDCObjects(ctx, storage))
+	for _, path := range []string{clientPath, scopePath, assignmentPath} {
+		keys, err := storage.List(ctx, path)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	}
+
+	// Clients are loaded from their buckets on startup
+	require.NoError(t, c.identityStore.resetDB(ctx))
+	require.NoError(t, c.identityStore.loadOIDCClients(ctx))
+	assertObjects()
+
+	// Migrating again is a no-op
+	require.NoError(t, c.identityStore.migrateOIDCObjects(ctx, storage))
+	assertObjects()
+}
+
+// TestOIDC_ClientBucket_Invalidate tests that the invalidation of a bucket
+// only reloads the clients stored in it.
+func TestOIDC_ClientBucket_Invalidate(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	storage := c.identityStore.view
+
+	// Find two clients stored in different buckets
+	var names []string
+	var bucketKeys []string
+	for n := 0; len(names) < 2; n++ {
+		name := fmt.Sprintf("test-client-%d", n)
+		bucketKey, err := oidcClientStore.bucketKey(storage, name)
+		require.NoError(t, err)
+		if len(bucketKeys) == 1 && bucketKeys[0] == bucketKey {
+			continue
+		}
+		names = append(names, name)
+		bucketKeys = append(bucketKeys, bucketKey)
+	}
+
+	// Write the clients as the active node would
+	for n, name := range names {
+		_, err := oidcClientStore.put(ctx, storage, name, &client{
+			Name:        name,
+			NamespaceID: namespace.RootNamespaceID,
+			ClientID:    fmt.Sprintf("test-client-id-%d", n),
+		})
+		require.NoError(t, err)
+	}
+
+	// Only the client of the invalidated bucket is loaded
+	c.identityStore.Invalidate(ctx, bucketKeys[0])
+	client, err := c.identityStore.clientByID("test-client-id-0")
+	require.NoError(t, err)
+	require.NotNil(t, client)
+	require.Equal(t, bucketKeys[0], client.BucketKey)
+	client, err = c.identityStore.clientByID("test-client-id-1")
+	require.NoError(t, err)
+	require.Nil(t, client)
+
+	c.identityStore.Invalidate(ctx, bucketKeys[1])
+	client, err = c.identityStore.clientByID("test-client-id-1")
+	require.NoError(t, err)
+	require.NotNil(t, client)
+}
+
+// benchmarkOIDCClients writes the given number of clients in individual
+// entries, packs them into buckets if packed is true, and loads them.
+func benchmarkOIDCClients(b *testing.B, numClients int, packed bool) (*Core, context.Context) {
+	c, _, _ := TestCoreUnsealed(benchhelpers.TBtoT(b))
+	ctx := namespace.RootContext(nil)
+	storage := c.identityStore.view
+
+	for n := 0; n < numClients; n++ {
+		name := fmt.Sprintf("client-%d", n)
+		writeLegacyOIDCObject(b, storage, clientPath, name, &client{
+			Name:        name,
+			NamespaceID: namespace.RootNamespaceID,
+			Assignments: []string{allowAllAssignmentName},
+			Key:         defaultKeyName,
+			ClientID:    fmt.Sprintf("client-id-%d", n),
+		})
+	}
+	if packed {
+		if err := c.identityStore.migrateOIDCObjects(ctx, storage); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := c.identityStore.loadOIDCClients(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	return c, ctx
+}
+
+// BenchmarkOIDC_LoadClients compares loading 50k clients packed into buckets
+// to loading them from individual entries, as done on startup.
+func BenchmarkOIDC_LoadClients(b *testing.B) {
+	const numClients = 50000
+
+	for _, packed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("packed=%t", packed), func(b *testing.B) {
+			c, ctx := benchmarkOIDCClients(b, numClients, packed)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := c.identityStore.loadOIDCClients(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkOIDC_ListClients compares listing 50k clients from memdb, as the
+// LIST endpoint does, to listing them from their buckets or from individual
+// entries.
+func BenchmarkOIDC_ListClients(b *testing.B) {
+	const numClients = 50000
+
+	for _, packed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("packed=%t", packed), func(b *testing.B) {
+			c, ctx := benchmarkOIDCClients(b, numClients, packed)
+			storage := c.identityStore.view
+
+			b.Run("memdb", func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+						Path:      "oidc/client",
+						Operation: logical.ListOperation,
+						Storage:   storage,
+					})
+					if err != nil || resp.IsError() {
+						b.Fatalf("error listing clients: %v %v", resp, err)
+					}
+				}
+			})
+
+			b.Run("storage", func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					if _, err := oidcClientStore.list(ctx, storage); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/helper/metricsutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// The results of provider requests that don't have an error code
+	oidcProviderResultSuccess     = "success"
+	oidcProviderResultNotModified = "not_modified"
+	oidcProviderResultNotFound    = "not_found"
+
+	// oidcProviderUnknownLabel replaces the provider names and client IDs of
+	// metric labels that don't exist. The endpoints of a provider can be
+	// called with any of them, which would otherwise create a metric series
+	// for each.
+	oidcProviderUnknownLabel = "unknown"
+
+	// The response fields that carry the details of provider requests to the
+	// audit log. They are left out of the HTTP response of the endpoints
+	// with raw responses.
+	oidcProviderAuditClientID  = "oidc_client_id"
+	oidcProviderAuditGrantType = "oidc_grant_type"
+	oidcProviderAuditError     = "oidc_error"
+
+	// oidcProviderAuditRefusedRemoteAddress is the remote address of token
+	// requests refused for coming from outside the CIDR blocks of the client
+	oidcProviderAuditRefusedRemoteAddress = "oidc_refused_remote_address"
+
+	// The response fields of the revocations of provider tokens
+	oidcProviderAuditEntityID             = "oidc_entity_id"
+	oidcProviderAuditRevokedAccessTokens  = "revoked_access_tokens"
+	oidcProviderAuditRevokedRefreshTokens = "revoked_refresh_tokens"
+)
+
+// oidcProviderAuditResponseKeys are the audited response fields of the
+// provider endpoints that aren't HMAC'd, which include the criteria and
+// counts of token revocations. The codes, tokens and secrets of the
+// responses are only part of their raw body, which still is.
+var oidcProviderAuditResponseKeys = []string{
+	oidcProviderAuditClientID,
+	oidcProviderAuditGrantType,
+	oidcProviderAuditError,
+	oidcProviderAuditRefusedRemoteAddress,
+	oidcProviderAuditEntityID,
+	oidcProviderAuditRevokedAccessTokens,
+	oidcProviderAuditRevokedRefreshTokens,
+}
+
+// withOIDCProviderTelemetry wraps the handler of a provider endpoint to count
+// its requests and measure their latency, labeled by namespace, provider and
+// result, and to add the details of the request to the audited response. The
+// client ID that requestClientID resolves from the request is added to both,
+// unless requestClientID is nil for endpoints that clients don't
+// authenticate to. So is the grant type of endpoints that take one.
+func (i *IdentityStore) withOIDCProviderTelemetry(endpoint string, handler framework.OperationFunc, requestClientID func(context.Context, *logical.Request, *framework.FieldData) string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req, d)
+		result := oidcProviderResult(resp, err)
+
+		labels := []metrics.Label{
+			oidcProviderNamespaceLabel(ctx),
+			{"provider", i.oidcProviderLabel(ctx, req, d.Get("name").(string), result)},
+		}
+
+		var clientID string
+		if requestClientID != nil {
+			clientID = requestClientID(ctx, req, d)
+			labels = append(labels, metrics.Label{"client_id", i.oidcClientLabel(clientID)})
+		}
+
+		var grantType string
+		if raw, ok := d.GetOk("grant_type"); ok {
+			grantType = raw.(string)
+			grantTypeLabel := grantType
+			if !strutil.StrListContains(supportedClientGrantTypes, grantType) {
+				grantTypeLabel = oidcProviderUnknownLabel
+			}
+			labels = append(labels, metrics.Label{"grant_type", grantTypeLabel})
+		}
+
+		labels = append(labels, metrics.Label{"result", result})
+		i.metrics.IncrCounterWithLabels([]string{"identity", "oidc", "provider", endpoint, "request"}, 1, labels)
+		i.metrics.MeasureSinceWithLabels([]string{"identity", "oidc", "provider", endpoint, "duration"}, start, labels)
+
+		if err != nil || resp == nil || resp.Data == nil {
+			return resp, err
+		}
+		if clientID != "" {
+			resp.Data[oidcProviderAuditClientID] = clientID
+		}
+		if grantType != "" {
+			resp.Data[oidcProviderAuditGrantType] = grantType
+		}
+		if isOIDCProviderErrorCode(result) {
+			resp.Data[oidcProviderAuditError] = result
+		}
+
+		return resp, nil
+	}
+}
+
+// authorizeRequestClientID returns the client ID of an authorization
+// request.
+func authorizeRequestClientID(_ context.Context, _ *logical.Request, d *framework.FieldData) string {
+	return d.Get("client_id").(string)
+}
+
+// oidcProviderResult returns the result of a request to a provider endpoint,
+// which is the error code of error responses.
+func oidcProviderResult(resp *logical.Response, err error) string {
+	if err != nil {
+		return ErrTokenServerError
+	}
+	if resp == nil || resp.Data == nil {
+		return oidcProviderResultNotFound
+	}
+
+	status, ok := resp.Data[logical.HTTPStatusCode].(int)
+	switch {
+	case !ok && resp.IsError():
+		return ErrTokenInvalidRequest
+	case status == http.StatusNotModified:
+		return oidcProviderResultNotModified
+	case status < http.StatusBadRequest:
+		return oidcProviderResultSuccess
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	rawBody, _ := resp.Data[logical.HTTPRawBody].([]byte)
+	if err := json.Unmarshal(rawBody, &body); err != nil || body.Error == "" {
+		return ErrTokenServerError
+	}
+	return body.Error
+}
+
+// isOIDCProviderErrorCode returns true if the result of a provider request
+// is the error code of an error response.
+func isOIDCProviderErrorCode(result string) bool {
+	switch result {
+	case oidcProviderResultSuccess, oidcProviderResultNotModified, oidcProviderResultNotFound:
+		return false
+	}
+	return true
+}
+
+// oidcProviderNamespaceLabel returns the namespace label of the metrics of a
+// provider request.
+func oidcProviderNamespaceLabel(ctx context.Context) metrics.Label {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return metrics.Label{"namespace", "unknown"}
+	}
+	return metricsutil.NamespaceLabel(ns)
+}
+
+// oidcProviderLabel returns the provider label of the metrics of a request
+// with the given result. Successful requests were served by an existing
+// provider, so it's only looked up for the others.
+func (i *IdentityStore) oidcProviderLabel(ctx context.Context, req *logical.Request, name, result string) string {
+	switch result {
+	case oidcProviderResultSuccess, oidcProviderResultNotModified:
+		return name
+	case oidcProviderResultNotFound:
+		return oidcProviderUnknownLabel
+	}
+
+	entry, err := req.Storage.Get(ctx, providerPath+name)
+	if err != nil || entry == nil {
+		return oidcProviderUnknownLabel
+	}
+	return name
+}
+
+// oidcClientLabel returns the client_id label of the metrics of a request
+// made by the client with the given ID.
+func (i *IdentityStore) oidcClientLabel(clientID string) string {
+	if clientID == "" {
+		return oidcProviderUnknownLabel
+	}
+	client, err := i.clientByID(clientID)
+	if err != nil || client == nil {
+		return oidcProviderUnknownLabel
+	}
+	return clientID
+}
@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// The types of the stored provider artifacts that are tidied, which are
+	// the labels of their metrics
+	oidcArtifactAccessToken  = "access_token"
+	oidcArtifactRefreshToken = "refresh_token"
+
+	// oidcArtifactScanBatchSize is the number of stored provider artifacts
+	// that a scan reads before it pauses, so that scanning many artifacts
+	// doesn't hold up the other requests to storage
+	oidcArtifactScanBatchSize = 500
+
+	// oidcArtifactScanJitter is the longest pause of the periodic tidy
+	// between two batches. The pause is random, so that the scans of several
+	// namespaces don't hit storage in step.
+	oidcArtifactScanJitter = 200 * time.Millisecond
+)
+
+var errOIDCTidyInProgress = errors.New("a tidy of the OIDC provider artifacts is already in progress")
+
+// oidcTidyCounts are the numbers of stored artifacts of a type that a tidy
+// kept, and deleted or would have deleted in a dry run.
+type oidcTidyCounts struct {
+	live   int
+	reaped int
+}
+
+func (c *oidcTidyCounts) responseData() map[string]interface{} {
+	return map[string]interface{}{
+		"live":   c.live,
+		"reaped": c.reaped,
+	}
+}
+
+// oidcArtifactScan reads the stored artifacts of providers in batches, with
+// a random pause of up to jitter between them. Scans made for a request don't
+// pause, so that they finish within the request.
+type oidcArtifactScan struct {
+	storage   logical.Storage
+	batchSize int
+	jitter    time.Duration
+	read      int
+}
+
+func newOIDCArtifactScan(s logical.Storage, jitter time.Duration) *oidcArtifactScan {
+	return &oidcArtifactScan{
+		storage:   s,
+		batchSize: oidcArtifactScanBatchSize,
+		jitter:    jitter,
+	}
+}
+
+// get reads the entry at the key, once the pause that follows a full batch
+// is over. The context being done ends the pause.
+func (sc *oidcArtifactScan) get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	if sc.read > 0 && sc.read%sc.batchSize == 0 && sc.jitter > 0 {
+		timer := time.NewTimer(time.Duration(mathrand.Int63n(int64(sc.jitter))))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	sc.read++
+	return sc.storage.Get(ctx, key)
+}
+
+// reap reads the entries stored under the prefix and deletes those that reap
+// returns true for, unless this is a dry run. The entries that are kept and
+// reaped are added to the counts.
+func (sc *oidcArtifactScan) reap(ctx context.Context, prefix string, dryRun bool, counts *oidcTidyCounts, reap func(*logical.StorageEntry) (bool, error)) error {
+	keys, err := sc.storage.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		entry, err := sc.get(ctx, prefix+key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		ok, err := reap(entry)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			counts.live++
+			continue
+		}
+		if !dryRun {
+			if err := sc.storage.Delete(ctx, prefix+key); err != nil {
+				return err
+			}
+		}
+		counts.reaped++
+	}
+
+	return nil
+}
+
+func oidcProviderTidyPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/tidy$",
+			Fields: map[string]*framework.FieldSchema{
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "If true, the artifacts that would be deleted are counted without being deleted.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: i.pathOIDCTidy,
+				},
+			},
+			HelpSynopsis:    "Delete the expired access and refresh token entries of the OIDC providers.",
+			HelpDescription: "Delete the stored entries of the access and refresh tokens issued by the OIDC providers of the namespace that expired, or whose provider no longer exists. The entries are also tidied periodically by the active node. Authorization and device codes are kept in memory and expire on their own. The numbers of live and deleted entries are returned by type.",
+		},
+	}
+}
+
+// pathOIDCTidy tidies the stored artifacts of the providers of the namespace
+// and returns their numbers by type.
+func (i *IdentityStore) pathOIDCTidy(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	dryRun := d.Get("dry_run").(bool)
+
+	counts, err := i.tidyOIDCProviderArtifacts(ctx, newOIDCArtifactScan(req.Storage, 0), dryRun)
+	if errors.Is(err, errOIDCTidyInProgress) {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"dry_run":        dryRun,
+			"access_tokens":  counts[oidcArtifactAccessToken].responseData(),
+			"refresh_tokens": counts[oidcArtifactRefreshToken].responseData(),
+		},
+	}, nil
+}
+
+// tidyOIDCProviderArtifacts deletes the stored access and refresh token
+// entries of the providers of the namespace that expired, or whose provider
+// no longer exists, and returns their numbers by type. Nothing is deleted in
+// a dry run. A single tidy runs at a time in each namespace. The number of
+// live entries of each type is set as a gauge, and the number of deleted
+// entries counted, labeled by namespace and type.
+func (i *IdentityStore) tidyOIDCProviderArtifacts(ctx context.Context, scan *oidcArtifactScan, dryRun bool) (map[string]*oidcTidyCounts, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, running := i.oidcTidyLocks.LoadOrStore(ns.ID, struct{}{}); running {
+		return nil, errOIDCTidyInProgress
+	}
+	defer i.oidcTidyLocks.Delete(ns.ID)
+
+	accessTokens, err := tidyIssuedAccessTokens(ctx, scan, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to tidy the issued access tokens: %w", err)
+	}
+	refreshTokens, err := tidyRefreshTokens(ctx, scan, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to tidy the refresh tokens: %w", err)
+	}
+
+	counts := map[string]*oidcTidyCounts{
+		oidcArtifactAccessToken:  accessTokens,
+		oidcArtifactRefreshToken: refreshTokens,
+	}
+	nsLabel := oidcProviderNamespaceLabel(ctx)
+	for artifact, c := range counts {
+		labels := []metrics.Label{nsLabel, {"type", artifact}}
+		i.metrics.SetGaugeWithLabels([]string{"identity", "oidc", "artifacts", "live"}, float32(c.live), labels)
+		if !dryRun {
+			i.metrics.IncrCounterWithLabels([]string{"identity", "oidc", "artifacts", "reaped"}, float32(c.reaped), labels)
+		}
+	}
+
+	return counts, nil
+}
@@ -4,26 +4,85 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"hash"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/identitytpl"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/ryanuber/go-glob"
 	"gopkg.in/square/go-jose.v2"
 )
 
-// validRedirect checks whether uri is in allowed using special handling for loopback uris.
+// oidcURLMaxLength is the maximum length of the URLs of clients and
+// providers.
+const oidcURLMaxLength = 2048
+
+var (
+	// redirectURIRules are the rules of the redirect URIs of clients. Plain
+	// http is only allowed for the loopback redirects of native apps, which
+	// may also use private-use schemes (RFC 8252). Redirect URIs must not
+	// have a fragment (RFC 6749 section 3.1.2).
+	redirectURIRules = framework.URLRules{
+		Schemes:            []string{"https"},
+		AllowLoopbackHTTP:  true,
+		AllowCustomSchemes: true,
+		AllowQuery:         true,
+		MaxLength:          oidcURLMaxLength,
+	}
+
+	// issuerURLRules are the rules of the issuers of providers and tokens.
+	issuerURLRules = framework.URLRules{
+		Schemes:   []string{"http", "https"},
+		MaxLength: oidcURLMaxLength,
+	}
+
+	// providerDocumentURLRules are the rules of the URLs of the documents
+	// that providers publish in their discovery document, such as their
+	// terms of service. End-users are sent to them, so they must use https.
+	providerDocumentURLRules = framework.URLRules{
+		Schemes:       []string{"https"},
+		AllowQuery:    true,
+		AllowFragment: true,
+		MaxLength:     oidcURLMaxLength,
+	}
+)
+
+// parseOIDCTime parses the value of a timestamp field, such as the bounds of
+// an assignment or the expiry of a client. Only RFC3339 timestamps are
+// accepted, so that timestamps always carry their timezone. The empty string
+// is parsed to the zero time, which clears the field.
+func parseOIDCTime(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, which must be an RFC3339 timestamp such as 2021-06-01T09:00:00Z", field, value)
+	}
+	return t.UTC(), nil
+}
+
+// validRedirect checks whether uri is in allowed using special handling for loopback uris
+// if allowLoopback is true. The port of loopback uris is ignored, while their scheme, host
+// and path must still match exactly.
 // Ref: https://tools.ietf.org/html/rfc8252#section-7.3
-func validRedirect(uri string, allowed []string) bool {
+func validRedirect(uri string, allowed []string, allowLoopback bool) bool {
 	inputURI, err := url.Parse(uri)
 	if err != nil {
 		return false
 	}
 
 	// if uri isn't a loopback, just string search the allowed list
-	if !strutil.StrListContains([]string{"localhost", "127.0.0.1", "::1"}, inputURI.Hostname()) {
+	if !allowLoopback || !strutil.StrListContains([]string{"localhost", "127.0.0.1", "::1"}, inputURI.Hostname()) {
 		return strutil.StrListContains(allowed, uri)
 	}
 
@@ -33,7 +92,7 @@ func validRedirect(uri string, allowed []string) bool {
 	for _, a := range allowed {
 		allowedURI, err := url.Parse(a)
 		if err != nil {
-			return false
+			continue
 		}
 		allowedURI.Host = allowedURI.Hostname()
 
@@ -45,6 +104,132 @@ func validRedirect(uri string, allowed []string) bool {
 	return false
 }
 
+// parsePrompt returns the values of the space-delimited prompt parameter of
+// the authorization endpoint, which are case-insensitive like in the UI.
+// See https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func parsePrompt(prompt string) ([]string, error) {
+	values := strutil.RemoveDuplicatesStable(strings.Fields(strings.ToLower(prompt)), false)
+	for _, value := range values {
+		switch value {
+		case promptNone, promptLogin, promptConsent, promptSelectAccount:
+		default:
+			return nil, fmt.Errorf("unsupported prompt value %q", value)
+		}
+	}
+	if len(values) > 1 && strutil.StrListContains(values, promptNone) {
+		return nil, errors.New("prompt value \"none\" must not be combined with other values")
+	}
+	return values, nil
+}
+
+// disallowedRedirectURIs returns the redirect URIs whose host doesn't match
+// any of the allowed host glob patterns. Hosts are compared without their
+// port and case. Every URI is allowed if there are no patterns.
+func disallowedRedirectURIs(uris []string, allowedHosts []string) []string {
+	if len(allowedHosts) == 0 {
+		return nil
+	}
+
+	var disallowed []string
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil || !redirectHostAllowed(strings.ToLower(u.Hostname()), allowedHosts) {
+			disallowed = append(disallowed, uri)
+		}
+	}
+	return disallowed
+}
+
+func redirectHostAllowed(host string, allowedHosts []string) bool {
+	if host == "" {
+		return false
+	}
+	for _, pattern := range allowedHosts {
+		if glob.Glob(strings.ToLower(pattern), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeOIDCOrigin validates an allowed origin of a client and returns it
+// in the form browsers send in the Origin header: a lowercase scheme and host
+// with an optional port. The "*" origin allows any origin, and wildcards in
+// the host allow the matching hosts.
+func normalizeOIDCOrigin(origin string) (string, error) {
+	if origin == "*" {
+		return origin, nil
+	}
+
+	invalid := fmt.Errorf("invalid allowed origin %q, which must be %q or a scheme, host and optional port", origin, "*")
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+
+	// Wildcards aren't valid in hosts, so the rest of the origin is
+	// validated without them
+	u, err := url.Parse(strings.ReplaceAll(origin, "*", "x"))
+	if err != nil {
+		return "", invalid
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", invalid
+	}
+	if u.Hostname() == "" || u.User != nil || u.Opaque != "" || u.Path != "" ||
+		u.RawQuery != "" || u.ForceQuery || u.Fragment != "" || strings.HasSuffix(u.Host, ":") {
+		return "", invalid
+	}
+
+	return origin, nil
+}
+
+// originAllowed returns true if the origin matches any of the allowed
+// origins, which may contain wildcards.
+func originAllowed(origin string, allowedOrigins []string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || glob.Glob(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestOrigin returns the Origin header of the request, which browsers
+// send with cross-origin requests.
+func requestOrigin(req *logical.Request) string {
+	return http.Header(req.Headers).Get("Origin")
+}
+
+// documentETag returns the strong entity tag of a rendered document, which
+// changes along with its content.
+func documentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches returns true if the If-None-Match header of the request
+// matches the given entity tag, with the weak comparison of RFC 7232.
+// See https://datatracker.ietf.org/doc/html/rfc7232#section-3.2
+func etagMatches(req *logical.Request, etag string) bool {
+	for _, value := range http.Header(req.Headers).Values("If-None-Match") {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestHost returns the host that the request was sent to, which is only
+// known if the HTTP request was passed along with it.
+func requestHost(req *logical.Request) string {
+	if req.HTTPRequest == nil {
+		return ""
+	}
+	return req.HTTPRequest.Host
+}
+
 // computeHashClaim computes the hash value to be used for the at_hash
 // and c_hash claims. For details on how this value is computed and the
 // class of attacks it's used to prevent, see the spec at
@@ -98,10 +283,191 @@ func authCodeUsedPKCE(entry *authCodeCacheEntry) bool {
 	return entry.codeChallenge != "" && entry.codeChallengeMethod != ""
 }
 
-// basicAuth returns the username/password provided in the logical.Request's
+// basicAuth returns the client ID and secret provided in the logical.Request's
 // authorization header and a bool indicating if the request used basic
-// authentication.
-func basicAuth(req *logical.Request) (string, string, bool) {
+// authentication. The client ID and secret are form-urlencoded before being
+// base64-encoded in the header, so they are decoded after the base64 decoding.
+// See https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1. An error is
+// returned if the request used basic authentication with a malformed header.
+func basicAuth(req *logical.Request) (string, string, bool, error) {
 	headerReq := &http.Request{Header: req.Headers}
-	return headerReq.BasicAuth()
+
+	const prefix = "Basic "
+	authorization := headerReq.Header.Get("Authorization")
+	if len(authorization) < len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return "", "", false, nil
+	}
+
+	username, password, ok := headerReq.BasicAuth()
+	if !ok {
+		return "", "", true, errors.New("malformed basic authorization header")
+	}
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return "", "", true, fmt.Errorf("malformed client ID in basic authorization header: %w", err)
+	}
+	clientSecret, err := url.QueryUnescape(password)
+	if err != nil {
+		return "", "", true, fmt.Errorf("malformed client secret in basic authorization header: %w", err)
+	}
+
+	return clientID, clientSecret, true, nil
+}
+
+// scopeTemplateSegment is a literal or a directive of a scope template,
+// starting at the given byte offset of the template.
+type scopeTemplateSegment struct {
+	offset    int
+	text      string
+	directive bool
+}
+
+// splitScopeTemplate splits a scope template into its literals and
+// directives. Directives may contain nested directives, which are part of
+// the outermost directive. It returns nil if a directive isn't closed.
+func splitScopeTemplate(template string) []scopeTemplateSegment {
+	var segments []scopeTemplateSegment
+	start := 0
+	for pos := 0; pos < len(template); {
+		if !strings.HasPrefix(template[pos:], "{{") {
+			pos++
+			continue
+		}
+
+		depth := 0
+		end := pos
+		for end < len(template) {
+			switch {
+			case strings.HasPrefix(template[end:], "{{"):
+				depth++
+				end += 2
+			case strings.HasPrefix(template[end:], "}}"):
+				depth--
+				end += 2
+			default:
+				end++
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		if depth != 0 {
+			return nil
+		}
+
+		if pos > start {
+			segments = append(segments, scopeTemplateSegment{offset: start, text: template[start:pos]})
+		}
+		segments = append(segments, scopeTemplateSegment{offset: pos, text: template[pos:end], directive: true})
+		start, pos = end, end
+	}
+	if start < len(template) {
+		segments = append(segments, scopeTemplateSegment{offset: start, text: template[start:]})
+	}
+
+	return segments
+}
+
+// templatePosition returns the line and column of the byte offset of the
+// template, both starting at 1.
+func templatePosition(template string, offset int) (int, int) {
+	if offset > len(template) {
+		offset = len(template)
+	}
+	line := strings.Count(template[:offset], "\n") + 1
+	column := offset - strings.LastIndex(template[:offset], "\n")
+	return line, column
+}
+
+// validateScopeTemplateJSON populates the scope template for an empty entity
+// and returns the top-level claims of the result. The populated template must
+// be a JSON object. Syntax errors are reported at their line and column in
+// the template, or at the directive whose value caused them.
+func validateScopeTemplateJSON(template string) (map[string]interface{}, error) {
+	segments := splitScopeTemplate(template)
+	if segments == nil {
+		return nil, identitytpl.ErrUnbalancedTemplatingCharacter
+	}
+
+	// Populate the directives one at a time to map offsets of the populated
+	// template back to the template
+	var populated strings.Builder
+	populatedOffsets := make([]int, len(segments))
+	for idx, segment := range segments {
+		populatedOffsets[idx] = populated.Len()
+		if !segment.directive {
+			populated.WriteString(segment.text)
+			continue
+		}
+
+		_, value, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+			Mode:   identitytpl.JSONTemplating,
+			String: segment.text,
+			Entity: new(logical.Entity),
+			Groups: make([]*logical.Group, 0),
+		})
+		if err != nil {
+			return nil, err
+		}
+		populated.WriteString(value)
+	}
+
+	var claims map[string]interface{}
+	err := json.Unmarshal([]byte(populated.String()), &claims)
+	if err == nil {
+		if claims == nil {
+			return nil, errors.New("template must be a JSON object")
+		}
+		return claims, nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset is the number of bytes read before the error
+		offset := int(syntaxErr.Offset) - 1
+		if offset < 0 {
+			offset = 0
+		}
+		idx := sort.Search(len(populatedOffsets), func(n int) bool {
+			return populatedOffsets[n] > offset
+		}) - 1
+		segment := segments[idx]
+		if segment.directive {
+			line, column := templatePosition(template, segment.offset)
+			return nil, fmt.Errorf("%w in the value of %s at line %d, column %d", err, segment.text, line, column)
+		}
+
+		templateOffset := segment.offset + offset - populatedOffsets[idx]
+		line, column := templatePosition(template, templateOffset)
+		return nil, fmt.Errorf("%w at line %d, column %d near %q", err, line, column, templateSnippet(template, templateOffset))
+	case errors.As(err, &typeErr):
+		return nil, errors.New("template must be a JSON object")
+	default:
+		return nil, err
+	}
+}
+
+// templateSnippet returns the part of the line of the template around the
+// byte offset, to help locating errors.
+func templateSnippet(template string, offset int) string {
+	const snippetLength = 10
+	if offset > len(template) {
+		offset = len(template)
+	}
+
+	start := strings.LastIndex(template[:offset], "\n") + 1
+	if offset-start > snippetLength {
+		start = offset - snippetLength
+	}
+	end := len(template)
+	if newline := strings.IndexByte(template[offset:], '\n'); newline >= 0 {
+		end = offset + newline
+	}
+	if end-offset > snippetLength {
+		end = offset + snippetLength
+	}
+
+	return template[start:end]
 }
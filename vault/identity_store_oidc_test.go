@@ -3,8 +3,12 @@ package vault
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -409,6 +413,7 @@ func TestOIDC_Path_OIDCKeyKey(t *testing.T) {
 		"verification_ttl":   int64(86400),
 		"algorithm":          "RS256",
 		"allowed_client_ids": []string{},
+		"referenced_by":      map[string]interface{}{"clients": []string{}, "client_count": 0},
 	}
 	if diff := deep.Equal(expected, resp.Data); diff != nil {
 		t.Fatal(diff)
@@ -439,6 +444,7 @@ func TestOIDC_Path_OIDCKeyKey(t *testing.T) {
 		"verification_ttl":   int64(3600),
 		"algorithm":          "RS256",
 		"allowed_client_ids": []string{"allowed-test-role"},
+		"referenced_by":      map[string]interface{}{"clients": []string{}, "client_count": 0},
 	}
 	if diff := deep.Equal(expected, resp.Data); diff != nil {
 		t.Fatal(diff)
@@ -680,6 +686,58 @@ func assertRespPublicKeyCount(t *testing.T, resp *logical.Response, keyCount int
 	}
 }
 
+// TestOIDC_DeterministicKeys tests that cores created with the same OIDC key
+// seed publish byte-identical JWKS documents
+func TestOIDC_DeterministicKeys(t *testing.T) {
+	jwks := func(seed []byte) []byte {
+		t.Helper()
+
+		conf := &CoreConfig{}
+		TestSetOIDCKeySeed(t, conf, seed)
+		c, _, _ := TestCoreUnsealedWithConfig(t, conf)
+		ctx := namespace.RootContext(nil)
+		storage := &logical.InmemStorage{}
+
+		for _, algorithm := range []string{"RS256", "ES256", "EdDSA"} {
+			resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+				Path:      "oidc/key/test-key-" + algorithm,
+				Operation: logical.CreateOperation,
+				Data: map[string]interface{}{
+					"algorithm": algorithm,
+				},
+				Storage: storage,
+			})
+			expectSuccess(t, resp, err)
+			resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+				Path:      "oidc/role/test-role-" + algorithm,
+				Operation: logical.CreateOperation,
+				Data: map[string]interface{}{
+					"key": "test-key-" + algorithm,
+				},
+				Storage: storage,
+			})
+			expectSuccess(t, resp, err)
+		}
+
+		resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/.well-known/keys",
+			Operation: logical.ReadOperation,
+			Storage:   storage,
+		})
+		expectSuccess(t, resp, err)
+		assertRespPublicKeyCount(t, resp, 6)
+		return resp.Data["http_raw_body"].([]byte)
+	}
+
+	first := jwks([]byte("test-seed"))
+	if second := jwks([]byte("test-seed")); string(first) != string(second) {
+		t.Fatalf("expected identical JWKS for the same seed, got:\n%s\n%s", first, second)
+	}
+	if other := jwks([]byte("other-seed")); string(first) == string(other) {
+		t.Fatal("expected different JWKS for different seeds")
+	}
+}
+
 // TestOIDC_PublicKeys tests that public keys are updated by
 // key creation, rotation, and deletion
 func TestOIDC_PublicKeys(t *testing.T) {
@@ -946,6 +1004,140 @@ func TestOIDC_SignIDToken(t *testing.T) {
 	}
 }
 
+// TestOIDC_PublicKeys_NoSkew tests that the JWKS is precomputed when keys are
+// rotated, on the node that rotates them and on other nodes once the rotation
+// is invalidated, and that the JWKS served before a rotation verifies the
+// tokens signed after it.
+func TestOIDC_PublicKeys_NoSkew(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	storage := c.identityStore.view
+
+	// Create and load an entity, an entity is required to generate an ID token
+	txn := c.identityStore.db.Txn(true)
+	defer txn.Abort()
+	err := c.identityStore.upsertEntityInTxn(ctx, txn, &identity.Entity{
+		Name:      "test-entity-name",
+		ID:        "test-entity-id",
+		BucketKey: "test-entity-bucket-key",
+	}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn.Commit()
+
+	resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/test-key",
+		Operation: logical.CreateOperation,
+		Data: map[string]interface{}{
+			"allowed_client_ids": "*",
+		},
+		Storage: storage,
+	})
+	expectSuccess(t, resp, err)
+	resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/role/test-role",
+		Operation: logical.CreateOperation,
+		Data: map[string]interface{}{
+			"key": "test-key",
+		},
+		Storage: storage,
+	})
+	expectSuccess(t, resp, err)
+
+	// cachedJWKS returns the JWKS cached without requesting it
+	cachedJWKS := func() *jose.JSONWebKeySet {
+		t.Helper()
+		for _, key := range []string{"jwksResponse", "providerKeys/" + defaultProviderName} {
+			_, ok, err := c.identityStore.oidcCache.Get(namespace.RootNamespace, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Fatalf("expected %q to be precomputed", key)
+			}
+		}
+		v, _, _ := c.identityStore.oidcCache.Get(namespace.RootNamespace, "jwksResponse")
+		jwks := &jose.JSONWebKeySet{}
+		if err := json.Unmarshal(v.([]byte), jwks); err != nil {
+			t.Fatal(err)
+		}
+		return jwks
+	}
+
+	signToken := func() *jwt.JSONWebToken {
+		t.Helper()
+		resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/token/test-role",
+			Operation: logical.ReadOperation,
+			Storage:   storage,
+			EntityID:  "test-entity-id",
+		})
+		expectSuccess(t, resp, err)
+		token, err := jwt.ParseSigned(resp.Data["token"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	verifies := func(jwks *jose.JSONWebKeySet, token *jwt.JSONWebToken) bool {
+		for _, key := range jwks.Keys {
+			if err := token.Claims(key, &jwt.Claims{}); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Rotate the key on this node
+	for n := 0; n < 3; n++ {
+		before := cachedJWKS()
+		resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      "oidc/key/test-key/rotate",
+			Operation: logical.UpdateOperation,
+			Storage:   storage,
+		})
+		expectSuccess(t, resp, err)
+
+		token := signToken()
+		if !verifies(before, token) {
+			t.Fatal("token signed after the rotation can't be verified with the JWKS served before it")
+		}
+		if !verifies(cachedJWKS(), token) {
+			t.Fatal("token can't be verified with the precomputed JWKS")
+		}
+	}
+
+	// Rotate the key as another node would
+	for n := 0; n < 3; n++ {
+		before := cachedJWKS()
+
+		entry, err := storage.Get(ctx, namedKeyConfigPath+"test-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var key namedKey
+		if err := entry.DecodeJSON(&key); err != nil {
+			t.Fatal(err)
+		}
+		key.name = "test-key"
+		if err := key.rotate(ctx, hclog.NewNullLogger(), storage, c.identityStore.oidcKeySource, -1); err != nil {
+			t.Fatal(err)
+		}
+		c.identityStore.Invalidate(ctx, namedKeyConfigPath+"test-key")
+
+		after := cachedJWKS()
+		token := signToken()
+		if !verifies(before, token) {
+			t.Fatal("token signed after the rotation can't be verified with the JWKS served before it")
+		}
+		if !verifies(after, token) {
+			t.Fatal("token can't be verified with the JWKS precomputed on invalidation")
+		}
+	}
+}
+
 // TestOIDC_SignIDToken_NilSigningKey tests that an error is returned when
 // attempting to sign an ID token with a nil signing key
 func TestOIDC_SignIDToken_NilSigningKey(t *testing.T) {
@@ -980,7 +1172,7 @@ func TestOIDC_SignIDToken_NilSigningKey(t *testing.T) {
 		NextRotation:     time.Now(),
 	}
 	s := c.router.MatchingStorageByAPIPath(ctx, "identity/oidc")
-	if err := namedKey.generateAndSetNextKey(ctx, hclog.NewNullLogger(), s); err != nil {
+	if err := namedKey.generateAndSetNextKey(ctx, hclog.NewNullLogger(), s, nil); err != nil {
 		t.Fatalf("failed to set next signing key")
 	}
 	// Store namedKey
@@ -1101,12 +1293,12 @@ func TestOIDC_PeriodicFunc(t *testing.T) {
 			storage := c.router.MatchingStorageByAPIPath(ctx, "identity/oidc")
 
 			if testSet.setSigningKey {
-				if err := testSet.namedKey.generateAndSetKey(ctx, hclog.NewNullLogger(), storage); err != nil {
+				if err := testSet.namedKey.generateAndSetKey(ctx, hclog.NewNullLogger(), storage, nil); err != nil {
 					t.Fatalf("failed to set signing key")
 				}
 			}
 			if testSet.setNextSigningKey {
-				if err := testSet.namedKey.generateAndSetNextKey(ctx, hclog.NewNullLogger(), storage); err != nil {
+				if err := testSet.namedKey.generateAndSetNextKey(ctx, hclog.NewNullLogger(), storage, nil); err != nil {
 					t.Fatalf("failed to set next signing key")
 				}
 			}
@@ -1599,7 +1791,7 @@ func TestOIDC_Flush(t *testing.T) {
 	if err := c.Flush(ns[1]); err != nil {
 		t.Fatal(err)
 	}
-	items := c.c.Items()
+	items := c.items()
 	verify(items, []*namespace.Namespace{ns[2]}, []*namespace.Namespace{ns[0], ns[1]})
 
 	// flushing nilNamespace should flush nilNamespace but not ns1 or ns2
@@ -1607,7 +1799,7 @@ func TestOIDC_Flush(t *testing.T) {
 	if err := c.Flush(ns[0]); err != nil {
 		t.Fatal(err)
 	}
-	items = c.c.Items()
+	items = c.items()
 	verify(items, []*namespace.Namespace{ns[1], ns[2]}, []*namespace.Namespace{ns[0]})
 }
 
@@ -1627,6 +1819,103 @@ func TestOIDC_CacheNamespaceNilCheck(t *testing.T) {
 	}
 }
 
+// TestOIDC_CacheConcurrent tests the cache under concurrent use and must be
+// clean under the race detector.
+func TestOIDC_CacheConcurrent(t *testing.T) {
+	c := newOIDCCache(gocache.NoExpiration, gocache.NoExpiration)
+	ns := []*namespace.Namespace{noNamespace, {ID: "ns1"}, {ID: "ns2"}}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 500; n++ {
+				targetNs := ns[n%len(ns)]
+				key := fmt.Sprintf("key-%d-%d", g, n)
+				if err := c.SetDefault(targetNs, key, n); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, _, err := c.Get(targetNs, key); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := c.SetDefaultIfNotFlushed(c.flushCount(), targetNs, "shared", n); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := c.Delete(targetNs, key); err != nil {
+					t.Error(err)
+					return
+				}
+				if n%50 == 0 {
+					if err := c.Flush(targetNs); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Values read before a flush are not cached after it
+	flushes := c.flushCount()
+	if err := c.Flush(ns[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetDefaultIfNotFlushed(flushes, ns[1], "stale", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := c.Get(ns[1], "stale"); found {
+		t.Fatal("expected a value read before a flush not to be cached")
+	}
+	if err := c.SetDefaultIfNotFlushed(c.flushCount(), ns[1], "fresh", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := c.Get(ns[1], "fresh"); !found {
+		t.Fatal("expected a value read after the last flush to be cached")
+	}
+}
+
+// BenchmarkOIDC_CacheParallel reproduces the use of the caches by concurrent
+// authorization code exchanges, which get a named key and get and delete an
+// authorization code, with a single lock and with sharded locks.
+func BenchmarkOIDC_CacheParallel(b *testing.B) {
+	for _, shards := range []int{1, oidcCacheShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			keys := newShardedOIDCCache(gocache.NoExpiration, gocache.NoExpiration, shards)
+			codes := newShardedOIDCCache(5*time.Minute, 5*time.Minute, shards)
+			ns := namespace.RootNamespace
+			if err := keys.SetDefault(ns, "namedKeys/test-key", &namedKey{}); err != nil {
+				b.Fatal(err)
+			}
+
+			var next uint64
+			b.SetParallelism(64)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					code := strconv.FormatUint(atomic.AddUint64(&next, 1), 10)
+					if err := codes.SetDefault(ns, code, &authCodeCacheEntry{}); err != nil {
+						b.Fatal(err)
+					}
+					if _, _, err := keys.Get(ns, "namedKeys/test-key"); err != nil {
+						b.Fatal(err)
+					}
+					if _, _, err := codes.Get(ns, code); err != nil {
+						b.Fatal(err)
+					}
+					if err := codes.Delete(ns, code); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
 func TestOIDC_GetKeysCacheControlHeader(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
 
@@ -1685,6 +1974,251 @@ func TestOIDC_GetKeysCacheControlHeader(t *testing.T) {
 	}
 }
 
+// TestOIDC_RecoverKeys_InterruptedWrites tests that no unusable key state
+// survives the recovery pass when any storage write of an operation on named
+// keys fails, as it would if Vault crashed at that point.
+func TestOIDC_RecoverKeys_InterruptedWrites(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	keyRequest := func(s logical.Storage, op logical.Operation, path string, data map[string]interface{}) error {
+		resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+			Path:      path,
+			Operation: op,
+			Data:      data,
+			Storage:   s,
+		})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return err
+	}
+	createKey := func(s logical.Storage) error {
+		return keyRequest(s, logical.CreateOperation, "oidc/key/test-key", nil)
+	}
+
+	tests := []struct {
+		name      string
+		setup     func(s logical.Storage) error
+		operation func(s logical.Storage) error
+	}{
+		{
+			name:      "create key",
+			operation: createKey,
+		},
+		{
+			name:  "change key algorithm",
+			setup: createKey,
+			operation: func(s logical.Storage) error {
+				return keyRequest(s, logical.UpdateOperation, "oidc/key/test-key", map[string]interface{}{
+					"algorithm": "ES256",
+				})
+			},
+		},
+		{
+			name:  "rotate key",
+			setup: createKey,
+			operation: func(s logical.Storage) error {
+				return keyRequest(s, logical.UpdateOperation, "oidc/key/test-key/rotate", map[string]interface{}{
+					"verification_ttl": 0,
+				})
+			},
+		},
+		{
+			name:  "delete key",
+			setup: createKey,
+			operation: func(s logical.Storage) error {
+				return keyRequest(s, logical.DeleteOperation, "oidc/key/test-key", nil)
+			},
+		},
+		{
+			name: "store default resources",
+			operation: func(s logical.Storage) error {
+				return c.identityStore.storeOIDCDefaultResources(ctx, s)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Fail each write of the operation in turn until it completes
+			for failAt := 1; ; failAt++ {
+				storage := &logical.InmemStorage{}
+				if tt.setup != nil {
+					if err := tt.setup(storage); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				s := &failingStorage{Storage: storage, failAt: failAt}
+				err := tt.operation(s)
+				completed := s.writes < failAt
+				if completed && err != nil {
+					t.Fatal(err)
+				}
+
+				if err := c.identityStore.recoverOIDCKeys(ctx, storage); err != nil {
+					t.Fatalf("write %d: %v", failAt, err)
+				}
+				expectUsableOIDCKeys(t, ctx, storage)
+
+				if completed {
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestOIDC_RecoverKeys_MissingPublicKeys tests that the recovery pass writes
+// missing public keys of signing keys again, and removes other missing public
+// keys from key rings.
+func TestOIDC_RecoverKeys_MissingPublicKeys(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	storage := &logical.InmemStorage{}
+
+	resp, err := c.identityStore.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/test-key",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+	})
+	expectSuccess(t, resp, err)
+	resp, err = c.identityStore.HandleRequest(ctx, &logical.Request{
+		Path:      "oidc/key/test-key/rotate",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+	})
+	expectSuccess(t, resp, err)
+
+	key, err := c.identityStore.getNamedKey(ctx, storage, "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.KeyRing) != 3 {
+		t.Fatalf("expected 3 keys in the key ring, got %d", len(key.KeyRing))
+	}
+
+	// Delete the public keys of the next signing key and of the previous
+	// signing key, which can't be recovered
+	var previousKeyID string
+	for _, k := range key.KeyRing {
+		if k.KeyID != key.SigningKey.KeyID && k.KeyID != key.NextSigningKey.KeyID {
+			previousKeyID = k.KeyID
+		}
+	}
+	for _, keyID := range []string{key.NextSigningKey.KeyID, previousKeyID} {
+		if err := storage.Delete(ctx, publicKeysConfigPath+keyID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.identityStore.recoverOIDCKeys(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+	expectUsableOIDCKeys(t, ctx, storage)
+
+	publicKey, err := loadOIDCPublicKey(ctx, storage, key.NextSigningKey.KeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(publicKey.Key, key.NextSigningKey.Public().Key); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// Read the key from storage since the recovery doesn't flush the cache
+	entry, err := storage.Get(ctx, namedKeyConfigPath+"test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key = &namedKey{}
+	if err := entry.DecodeJSON(key); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range key.KeyRing {
+		if k.KeyID == previousKeyID {
+			t.Fatalf("expected key %q to be removed from the key ring", previousKeyID)
+		}
+	}
+	if len(key.KeyRing) != 2 {
+		t.Fatalf("expected 2 keys in the key ring, got %d", len(key.KeyRing))
+	}
+}
+
+// failingStorage is a storage that fails its nth write and all the following
+// ones, like a crash at that point of a multi-entry operation would.
+type failingStorage struct {
+	logical.Storage
+	failAt int
+	writes int
+}
+
+var errInjectedStorageFailure = errors.New("injected storage failure")
+
+func (s *failingStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	s.writes++
+	if s.writes >= s.failAt {
+		return errInjectedStorageFailure
+	}
+	return s.Storage.Put(ctx, entry)
+}
+
+func (s *failingStorage) Delete(ctx context.Context, key string) error {
+	s.writes++
+	if s.writes >= s.failAt {
+		return errInjectedStorageFailure
+	}
+	return s.Storage.Delete(ctx, key)
+}
+
+// expectUsableOIDCKeys fails the test unless every named key in storage has
+// signing keys, and the public keys in storage are exactly those in the key
+// rings of the named keys.
+func expectUsableOIDCKeys(t *testing.T, ctx context.Context, s logical.Storage) {
+	t.Helper()
+
+	referenced := make(map[string]bool)
+	keyNames, err := s.List(ctx, namedKeyConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, keyName := range keyNames {
+		entry, err := s.Get(ctx, namedKeyConfigPath+keyName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var key namedKey
+		if err := entry.DecodeJSON(&key); err != nil {
+			t.Fatal(err)
+		}
+		if key.SigningKey == nil || key.NextSigningKey == nil {
+			t.Fatalf("key %q is missing signing keys", keyName)
+		}
+
+		for _, k := range key.KeyRing {
+			if _, err := loadOIDCPublicKey(ctx, s, k.KeyID); err != nil {
+				t.Fatalf("key %q: %v", keyName, err)
+			}
+			referenced[k.KeyID] = true
+		}
+		for _, keyID := range []string{key.SigningKey.KeyID, key.NextSigningKey.KeyID} {
+			if !referenced[keyID] {
+				t.Fatalf("key %q: signing key %q is not in the key ring", keyName, keyID)
+			}
+		}
+	}
+
+	publicKeyIDs, err := listOIDCPublicKeys(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, keyID := range publicKeyIDs {
+		if !referenced[keyID] {
+			t.Fatalf("public key %q is not referenced by any key", keyID)
+		}
+	}
+}
+
 // some helpers
 func expectSuccess(t *testing.T, resp *logical.Response, err error) {
 	t.Helper()
@@ -254,6 +254,33 @@ func oidcClientsTableSchema(_ bool) *memdb.TableSchema {
 					Field: "NamespaceID",
 				},
 			},
+			"bucket_key": {
+				Name: "bucket_key",
+				Indexer: &memdb.CompoundIndex{
+					Indexes: []memdb.Indexer{
+						&memdb.StringFieldIndex{
+							Field: "NamespaceID",
+						},
+						&memdb.StringFieldIndex{
+							Field: "BucketKey",
+						},
+					},
+				},
+			},
+			"key": {
+				Name:         "key",
+				AllowMissing: true,
+				Indexer: &memdb.CompoundIndex{
+					Indexes: []memdb.Indexer{
+						&memdb.StringFieldIndex{
+							Field: "NamespaceID",
+						},
+						&memdb.StringFieldIndex{
+							Field: "Key",
+						},
+					},
+				},
+			},
 		},
 	}
 }
@@ -57,9 +57,22 @@ type IdentityStore struct {
 	lock     sync.RWMutex
 	oidcLock sync.RWMutex
 
+	// oidcRefreshTokenLock serializes the rotation of refresh tokens, so
+	// that each is used once
+	oidcRefreshTokenLock sync.Mutex
+
+	// oidcTidyLocks holds the IDs of the namespaces whose stored provider
+	// artifacts are being tidied, so that the periodic and requested tidies
+	// of a namespace don't run at once
+	oidcTidyLocks sync.Map
+
 	// groupLock is used to protect modifications to group entries
 	groupLock sync.RWMutex
 
+	// groupClosureCache stores the groups that groups are members of,
+	// directly or through nested groups.
+	groupClosureCache *groupClosureCache
+
 	// oidcCache stores common response data as well as when the periodic func needs
 	// to run. This is conservatively managed, and most writes to the OIDC endpoints
 	// will invalidate the cache.
@@ -69,6 +82,35 @@ type IdentityStore struct {
 	// for an ID token during an authorization code flow.
 	oidcAuthCodeCache *oidcCache
 
+	// oidcDeviceCodeCache stores the pending requests of the OIDC device
+	// authorization grant by device code, and the device codes by user
+	// code. oidcDeviceCodeLock serializes their changes.
+	oidcDeviceCodeCache *oidcCache
+	oidcDeviceCodeLock  sync.Mutex
+
+	// oidcClientAssertionCache stores the IDs of the private_key_jwt
+	// assertions of OIDC clients until they expire, so that each assertion
+	// is used once. oidcClientJWKSCache stores the JWKS fetched from the
+	// jwks_uri of clients.
+	oidcClientAssertionCache *oidcCache
+	oidcClientJWKSCache      *oidcCache
+
+	// oidcPairwiseSaltLock serializes the creation of the salts of the
+	// pairwise subject identifiers of OIDC providers.
+	oidcPairwiseSaltLock sync.Mutex
+
+	// oidcClaimsCache stores the scope templates populated for entities,
+	// which are the claims of ID tokens and userinfo responses.
+	oidcClaimsCache *oidcEntityCache
+
+	// oidcAssignmentCache stores whether entities are authorized by the
+	// assignments of clients.
+	oidcAssignmentCache *oidcEntityCache
+
+	// oidcClientUsage stores the token issuance times of clients that are
+	// yet to be persisted.
+	oidcClientUsage *oidcClientUsage
+
 	// logger is the server logger copied over from core
 	logger log.Logger
 
@@ -100,6 +142,9 @@ type IdentityStore struct {
 	tokenStorer   TokenStorer
 	entityCreator EntityCreator
 	mfaBackend    *LoginMFABackend
+
+	// oidcKeySource generates OIDC keys deterministically in tests
+	oidcKeySource *oidcKeySource
 }
 
 type groupDiff struct {
@@ -141,6 +186,7 @@ var _ GroupUpdater = &Core{}
 type TokenStorer interface {
 	LookupToken(context.Context, string) (*logical.TokenEntry, error)
 	CreateToken(context.Context, *logical.TokenEntry) error
+	RevokeToken(context.Context, *logical.TokenEntry) error
 }
 
 var _ TokenStorer = &Core{}
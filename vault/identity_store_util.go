@@ -879,6 +879,12 @@ func (i *IdentityStore) MemDBUpsertAliasInTxn(txn *memdb.Txn, alias *identity.Al
 		return fmt.Errorf("failed to update alias into memdb: %w", err)
 	}
 
+	if groupAlias {
+		i.invalidateOIDCEntityInTxn(txn, "")
+	} else {
+		i.invalidateOIDCEntityInTxn(txn, alias.CanonicalID)
+	}
+
 	return nil
 }
 
@@ -1008,6 +1014,12 @@ func (i *IdentityStore) MemDBDeleteAliasByIDInTxn(txn *memdb.Txn, aliasID string
 		return fmt.Errorf("failed to delete alias from memdb: %w", err)
 	}
 
+	if groupAlias {
+		i.invalidateOIDCEntityInTxn(txn, "")
+	} else {
+		i.invalidateOIDCEntityInTxn(txn, alias.CanonicalID)
+	}
+
 	return nil
 }
 
@@ -1058,6 +1070,8 @@ func (i *IdentityStore) MemDBUpsertEntityInTxn(txn *memdb.Txn, entity *identity.
 		return fmt.Errorf("failed to update entity into memdb: %w", err)
 	}
 
+	i.invalidateOIDCEntityInTxn(txn, entity.ID)
+
 	return nil
 }
 
@@ -1293,6 +1307,8 @@ func (i *IdentityStore) MemDBDeleteEntityByIDInTxn(txn *memdb.Txn, entityID stri
 		return fmt.Errorf("failed to delete entity from memdb: %w", err)
 	}
 
+	i.invalidateOIDCEntityInTxn(txn, entity.ID)
+
 	return nil
 }
 
@@ -1567,13 +1583,10 @@ func (i *IdentityStore) sanitizeAndUpsertGroup(ctx context.Context, group *ident
 		// If group is nil, that means that a group doesn't already exist and its
 		// okay to add any group as its member group.
 		if groupByID != nil {
-			// If adding the memberGroupID to groupID creates a cycle, then groupID must
-			// be a hop in that loop. Start a DFS traversal from memberGroupID and see if
-			// it reaches back to groupID. If it does, then it's a loop.
-
-			// Created a visited set
-			visited := make(map[string]bool)
-			cycleDetected, err := i.detectCycleDFS(visited, groupByID.ID, memberGroupID)
+			// If adding the memberGroupID to groupID creates a cycle, then
+			// memberGroupID must already be a group that groupID is a member
+			// of, directly or through nested groups.
+			cycleDetected, err := i.detectCycle(groupByID, memberGroupID)
 			if err != nil {
 				return fmt.Errorf("failed to perform cyclic relationship detection for member group ID %q", memberGroupID)
 			}
@@ -1847,7 +1860,9 @@ func (i *IdentityStore) MemDBUpsertGroupInTxn(txn *memdb.Txn, group *identity.Gr
 		return fmt.Errorf("failed to lookup group from memdb using group id: %w", err)
 	}
 
+	var previousGroup *identity.Group
 	if groupRaw != nil {
+		previousGroup = groupRaw.(*identity.Group)
 		err = txn.Delete(groupsTable, groupRaw)
 		if err != nil {
 			return fmt.Errorf("failed to delete group from memdb: %w", err)
@@ -1858,6 +1873,12 @@ func (i *IdentityStore) MemDBUpsertGroupInTxn(txn *memdb.Txn, group *identity.Gr
 		return fmt.Errorf("failed to update group into memdb: %w", err)
 	}
 
+	i.invalidateGroupClosuresInTxn(txn, previousGroup, group)
+
+	// Group membership is inherited, so a group change may change the
+	// groups of any entity
+	i.invalidateOIDCEntityInTxn(txn, "")
+
 	return nil
 }
 
@@ -1884,6 +1905,10 @@ func (i *IdentityStore) MemDBDeleteGroupByIDInTxn(txn *memdb.Txn, groupID string
 		return fmt.Errorf("failed to delete group from memdb: %w", err)
 	}
 
+	i.invalidateGroupClosuresInTxn(txn, group, nil)
+
+	i.invalidateOIDCEntityInTxn(txn, "")
+
 	return nil
 }
 
@@ -2002,18 +2027,23 @@ func (i *IdentityStore) groupPoliciesByEntityID(entityID string) (map[string][]s
 		return nil, fmt.Errorf("empty entity ID")
 	}
 
-	groups, err := i.MemDBGroupsByMemberEntityID(entityID, false, false)
+	generation := i.groupClosureCache.currentGeneration()
+	txn := i.db.Txn(false)
+	defer txn.Abort()
+
+	groups, err := i.MemDBGroupsByMemberEntityIDInTxn(txn, entityID, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	closureGroups, err := i.groupClosuresInTxn(txn, generation, groups)
 	if err != nil {
 		return nil, err
 	}
 
-	visited := make(map[string]bool)
 	policies := make(map[string][]string)
-	for _, group := range groups {
-		err := i.collectPoliciesReverseDFS(group, visited, policies)
-		if err != nil {
-			return nil, err
-		}
+	for _, group := range closureGroups {
+		policies[group.NamespaceID] = append(policies[group.NamespaceID], group.Policies...)
 	}
 
 	return policies, nil
@@ -2024,30 +2054,18 @@ func (i *IdentityStore) groupsByEntityID(entityID string) ([]*identity.Group, []
 		return nil, nil, fmt.Errorf("empty entity ID")
 	}
 
-	groups, err := i.MemDBGroupsByMemberEntityID(entityID, true, false)
+	generation := i.groupClosureCache.currentGeneration()
+	txn := i.db.Txn(false)
+	defer txn.Abort()
+
+	groups, err := i.MemDBGroupsByMemberEntityIDInTxn(txn, entityID, true, false)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	visited := make(map[string]bool)
-	var tGroups []*identity.Group
-	for _, group := range groups {
-		gGroups, err := i.collectGroupsReverseDFS(group, visited, nil)
-		if err != nil {
-			return nil, nil, err
-		}
-		tGroups = append(tGroups, gGroups...)
-	}
-
-	// Remove duplicates
-	groupMap := make(map[string]*identity.Group)
-	for _, group := range tGroups {
-		groupMap[group.ID] = group
-	}
-
-	tGroups = make([]*identity.Group, 0, len(groupMap))
-	for _, group := range groupMap {
-		tGroups = append(tGroups, group)
+	tGroups, err := i.groupClosuresInTxn(txn, generation, groups)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	diff := diffGroups(groups, tGroups)
@@ -2061,107 +2079,16 @@ func (i *IdentityStore) groupsByEntityID(entityID string) ([]*identity.Group, []
 	return diff.Unmodified, diff.New, nil
 }
 
-func (i *IdentityStore) collectGroupsReverseDFS(group *identity.Group, visited map[string]bool, groups []*identity.Group) ([]*identity.Group, error) {
-	if group == nil {
-		return nil, fmt.Errorf("nil group")
-	}
-
-	// If traversal for a groupID is performed before, skip it
-	if visited[group.ID] {
-		return groups, nil
-	}
-	visited[group.ID] = true
-
-	groups = append(groups, group)
-
-	// Traverse all the parent groups
-	for _, parentGroupID := range group.ParentGroupIDs {
-		parentGroup, err := i.MemDBGroupByID(parentGroupID, false)
-		if err != nil {
-			return nil, err
-		}
-		if parentGroup == nil {
-			continue
-		}
-		groups, err = i.collectGroupsReverseDFS(parentGroup, visited, groups)
-		if err != nil {
-			return nil, fmt.Errorf("failed to collect group at parent group ID %q", parentGroup.ID)
-		}
-	}
-
-	return groups, nil
-}
-
-func (i *IdentityStore) collectPoliciesReverseDFS(group *identity.Group, visited map[string]bool, policies map[string][]string) error {
-	if group == nil {
-		return fmt.Errorf("nil group")
-	}
-
-	// If traversal for a groupID is performed before, skip it
-	if visited[group.ID] {
-		return nil
-	}
-	visited[group.ID] = true
-
-	policies[group.NamespaceID] = append(policies[group.NamespaceID], group.Policies...)
-
-	// Traverse all the parent groups
-	for _, parentGroupID := range group.ParentGroupIDs {
-		parentGroup, err := i.MemDBGroupByID(parentGroupID, false)
-		if err != nil {
-			return err
-		}
-		if parentGroup == nil {
-			continue
-		}
-		err = i.collectPoliciesReverseDFS(parentGroup, visited, policies)
-		if err != nil {
-			return fmt.Errorf("failed to collect policies at parent group ID %q", parentGroup.ID)
-		}
-	}
-
-	return nil
-}
-
-func (i *IdentityStore) detectCycleDFS(visited map[string]bool, startingGroupID, groupID string) (bool, error) {
-	// If the traversal reaches the startingGroupID, a loop is detected
-	if startingGroupID == groupID {
-		return true, nil
-	}
-
-	// If traversal for a groupID is performed before, skip it
-	if visited[groupID] {
-		return false, nil
-	}
-	visited[groupID] = true
-
-	group, err := i.MemDBGroupByID(groupID, true)
-	if err != nil {
-		return false, err
-	}
-	if group == nil {
-		return false, nil
-	}
-
-	// Fetch all groups in which groupID is present as a ParentGroupID. In
-	// other words, find all the subgroups of groupID.
-	memberGroups, err := i.MemDBGroupsByParentGroupID(groupID, false)
+// detectCycle returns whether adding the member group to the group would
+// create a cycle, which is the case if the member group is the group or one
+// of the groups it is a member of.
+func (i *IdentityStore) detectCycle(group *identity.Group, memberGroupID string) (bool, error) {
+	closure, err := i.groupClosure(group)
 	if err != nil {
 		return false, err
 	}
 
-	// DFS traverse the member groups
-	for _, memberGroup := range memberGroups {
-		cycleDetected, err := i.detectCycleDFS(visited, startingGroupID, memberGroup.ID)
-		if err != nil {
-			return false, fmt.Errorf("failed to perform cycle detection at member group ID %q", memberGroup.ID)
-		}
-		if cycleDetected {
-			return true, fmt.Errorf("cycle detected at member group ID %q", memberGroup.ID)
-		}
-	}
-
-	return false, nil
+	return strutil.StrListContains(closure, memberGroupID), nil
 }
 
 func (i *IdentityStore) memberGroupIDsByID(groupID string) ([]string, error) {
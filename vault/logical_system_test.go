@@ -209,10 +209,11 @@ func TestSystemBackend_mounts(t *testing.T) {
 			"accessor":                resp.Data["identity/"].(map[string]interface{})["accessor"],
 			"uuid":                    resp.Data["identity/"].(map[string]interface{})["uuid"],
 			"config": map[string]interface{}{
-				"default_lease_ttl":           resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["default_lease_ttl"].(int64),
-				"max_lease_ttl":               resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []string{"Authorization"},
+				"default_lease_ttl":            resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["default_lease_ttl"].(int64),
+				"max_lease_ttl":                resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []string{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []string{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -322,10 +323,11 @@ func TestSystemBackend_mount(t *testing.T) {
 			"accessor":                resp.Data["identity/"].(map[string]interface{})["accessor"],
 			"uuid":                    resp.Data["identity/"].(map[string]interface{})["uuid"],
 			"config": map[string]interface{}{
-				"default_lease_ttl":           resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["default_lease_ttl"].(int64),
-				"max_lease_ttl":               resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
-				"force_no_cache":              false,
-				"passthrough_request_headers": []string{"Authorization"},
+				"default_lease_ttl":            resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["default_lease_ttl"].(int64),
+				"max_lease_ttl":                resp.Data["identity/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
+				"force_no_cache":               false,
+				"passthrough_request_headers":  []string{"Authorization", "Origin", "If-None-Match"},
+				"audit_non_hmac_response_keys": []string{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 			},
 			"local":     false,
 			"seal_wrap": false,
@@ -3260,10 +3262,11 @@ func TestSystemBackend_InternalUIMounts(t *testing.T) {
 				"accessor":                resp.Data["secret"].(map[string]interface{})["identity/"].(map[string]interface{})["accessor"],
 				"uuid":                    resp.Data["secret"].(map[string]interface{})["identity/"].(map[string]interface{})["uuid"],
 				"config": map[string]interface{}{
-					"default_lease_ttl":           resp.Data["secret"].(map[string]interface{})["identity/"].(map[string]interface{})["config"].(map[string]interface{})["default_lease_ttl"].(int64),
-					"max_lease_ttl":               resp.Data["secret"].(map[string]interface{})["identity/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
-					"force_no_cache":              false,
-					"passthrough_request_headers": []string{"Authorization"},
+					"default_lease_ttl":            resp.Data["secret"].(map[string]interface{})["identity/"].(map[string]interface{})["config"].(map[string]interface{})["default_lease_ttl"].(int64),
+					"max_lease_ttl":                resp.Data["secret"].(map[string]interface{})["identity/"].(map[string]interface{})["config"].(map[string]interface{})["max_lease_ttl"].(int64),
+					"force_no_cache":               false,
+					"passthrough_request_headers":  []string{"Authorization", "Origin", "If-None-Match"},
+					"audit_non_hmac_response_keys": []string{"oidc_client_id", "oidc_grant_type", "oidc_error"},
 				},
 				"local":     false,
 				"seal_wrap": false,
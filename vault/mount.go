@@ -1577,7 +1577,8 @@ func (c *Core) requiredMountTable() *MountTable {
 		Accessor:         identityAccessor,
 		BackendAwareUUID: identityBackendUUID,
 		Config: MountConfig{
-			PassthroughRequestHeaders: []string{"Authorization"},
+			PassthroughRequestHeaders: []string{"Authorization", "Origin", "If-None-Match"},
+			AuditNonHMACResponseKeys:  oidcProviderAuditResponseKeys,
 		},
 	}
 
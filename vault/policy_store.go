@@ -155,6 +155,11 @@ path "sys/control-group/request" {
 path "identity/oidc/provider/+/authorize" {
 	capabilities = ["read", "update"]
 }
+
+# Allow a token to approve device authorization requests of OIDC providers.
+path "identity/oidc/provider/+/device" {
+	capabilities = ["read", "update"]
+}
 `
 )
 
@@ -183,6 +188,10 @@ type PolicyStore struct {
 	tokenPoliciesLRU *lru.TwoQueueCache
 	egpLRU           *lru.TwoQueueCache
 
+	// inlinePoliciesLRU stores the parsed inline policies of tokens by their
+	// namespace and rules
+	inlinePoliciesLRU *lru.TwoQueueCache
+
 	// This is used to ensure that writes to the store (acl/rgp) or to the egp
 	// path tree don't happen concurrently. We are okay reading stale data so
 	// long as there aren't concurrent writes.
@@ -224,6 +233,8 @@ func NewPolicyStore(ctx context.Context, core *Core, baseView *BarrierView, syst
 		ps.tokenPoliciesLRU = cache
 		cache, _ = lru.New2Q(policyCacheSize)
 		ps.egpLRU = cache
+		cache, _ = lru.New2Q(policyCacheSize)
+		ps.inlinePoliciesLRU = cache
 	}
 
 	aclView := ps.getACLView(namespace.RootNamespace)
@@ -859,3 +870,26 @@ func (ps *PolicyStore) sanitizeName(name string) string {
 func (ps *PolicyStore) cacheKey(ns *namespace.Namespace, name string) string {
 	return path.Join(ns.ID, name)
 }
+
+// parseInlinePolicy parses the inline policy of a token in the given
+// namespace. Tokens such as the access tokens of OIDC providers share the
+// same inline policy and are used at high rates, so parsed policies are
+// cached by their namespace and rules.
+func (ps *PolicyStore) parseInlinePolicy(ns *namespace.Namespace, rules string) (*Policy, error) {
+	if ps.inlinePoliciesLRU == nil {
+		return ParseACLPolicy(ns, rules)
+	}
+
+	index := ns.ID + ":" + rules
+	if raw, ok := ps.inlinePoliciesLRU.Get(index); ok {
+		return raw.(*Policy), nil
+	}
+
+	policy, err := ParseACLPolicy(ns, rules)
+	if err != nil {
+		return nil, err
+	}
+	ps.inlinePoliciesLRU.Add(index, policy)
+
+	return policy, nil
+}
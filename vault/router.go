@@ -681,7 +681,13 @@ func (r *Router) routeCommon(ctx context.Context, req *logical.Request, existenc
 
 	originalPolicyOverride := req.PolicyOverride
 	reqTokenEntry := req.TokenEntry()
-	req.SetTokenEntry(nil)
+
+	// The identity backend is given the client token unhashed, and may use
+	// the entry of the token looked up for the request rather than look it
+	// up again
+	if !strings.HasPrefix(originalPath, "identity/") {
+		req.SetTokenEntry(nil)
+	}
 
 	// Reset the request before returning
 	defer func() {
@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -203,6 +204,7 @@ func TestCoreWithSealAndUINoCleanup(t testing.T, opts *CoreConfig) *Core {
 	conf.EnableResponseHeaderHostname = opts.EnableResponseHeaderHostname
 	conf.DisableSSCTokens = opts.DisableSSCTokens
 	conf.PluginDirectory = opts.PluginDirectory
+	conf.testOIDCKeySeed = opts.testOIDCKeySeed
 
 	if opts.Logger != nil {
 		conf.Logger = opts.Logger
@@ -793,23 +795,80 @@ func TestWaitActiveForwardingReady(t testing.T, core *Core) {
 	t.Fatal("timed out waiting for request forwarding handler to be registered")
 }
 
+// TestWaitActiveWithError waits up to 30 seconds for the core to become
+// active.
 func TestWaitActiveWithError(core *Core) error {
-	start := time.Now()
-	var standby bool
-	var err error
-	for time.Now().Sub(start) < 30*time.Second {
-		standby, err = core.Standby()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return TestWaitActiveWithContext(ctx, core)
+}
+
+// TestWaitActiveWithContext waits for the core to become active. If the
+// context is done first, the returned error includes the current HA state
+// of the core.
+func TestWaitActiveWithContext(ctx context.Context, core *Core) error {
+	return testWaitHAState(ctx, core, false)
+}
+
+// TestWaitStandby waits up to 30 seconds for the core to become an unsealed
+// standby, failing the test otherwise.
+func TestWaitStandby(t testing.T, core *Core) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := TestWaitStandbyWithContext(ctx, core); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWaitStandbyWithContext waits for the core to become an unsealed
+// standby. If the context is done first, the returned error includes the
+// current HA state of the core.
+func TestWaitStandbyWithContext(ctx context.Context, core *Core) error {
+	return testWaitHAState(ctx, core, true)
+}
+
+func testWaitHAState(ctx context.Context, core *Core, standby bool) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		isStandby, err := core.Standby()
 		if err != nil {
 			return err
 		}
-		if !standby {
-			break
+		switch {
+		case !standby && !isStandby:
+			return nil
+		case standby && isStandby && !core.Sealed():
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			expected := "active"
+			if standby {
+				expected = "standby"
+			}
+			return fmt.Errorf("timed out waiting for core to become %s, current state: %s: %w", expected, testHAState(core), ctx.Err())
+		case <-ticker.C:
 		}
 	}
-	if standby {
-		return errors.New("should not be in standby mode")
+}
+
+// testHAState describes the HA state of the core for test failures.
+func testHAState(core *Core) string {
+	standby, perfStandby := core.StandbyStates()
+	state := fmt.Sprintf("sealed=%t standby=%t perf_standby=%t", core.Sealed(), standby, perfStandby)
+
+	_, leaderAddr, _, err := core.Leader()
+	switch {
+	case err != nil:
+		state += fmt.Sprintf(" leader_error=%q", err)
+	default:
+		state += fmt.Sprintf(" leader=%q", leaderAddr)
 	}
-	return nil
+	return state
 }
 
 type TestCluster struct {
@@ -1110,6 +1169,19 @@ type TestClusterOptions struct {
 	// do not clash with any other explicitly assigned ports in other tests.
 	BaseClusterListenPort int
 
+	// ListenPorts explicitly assigns the port of the listener of each core,
+	// by core index, e.g. so that OIDC redirect URIs or issuers can be
+	// registered ahead of time. A zero entry lets the OS choose the port of
+	// the corresponding core. ListenPorts takes precedence over the port of
+	// BaseListenAddress, whose host is still used if specified. It may not
+	// have more entries than there are cores.
+	ListenPorts []int
+
+	// SkipIfListenPortInUse skips the test rather than failing it when an
+	// explicitly assigned listener port is still in use once retries are
+	// exhausted.
+	SkipIfListenPortInUse bool
+
 	NumCores       int
 	SealFunc       func() Seal
 	UnwrapSealFunc func() Seal
@@ -1216,6 +1288,35 @@ func (tl *TestLogger) StopLogging() {
 	tl.Logger.(log.InterceptLogger).DeregisterSink(tl.sink)
 }
 
+// testListenPortRetries is the number of times binding an explicitly
+// assigned listener port is retried when it is in use, e.g. because a
+// previous test or a stopped core just released it.
+const testListenPortRetries = 10
+
+// testListenTCP binds the listener of the core with the given index. Binding
+// an explicitly assigned port that is in use is retried, after which the
+// test is skipped if opts.SkipIfListenPortInUse is set, and fails otherwise.
+func testListenTCP(t testing.T, addr *net.TCPAddr, idx int, opts *TestClusterOptions) *net.TCPListener {
+	t.Helper()
+
+	for attempt := 0; ; attempt++ {
+		ln, err := net.ListenTCP("tcp", addr)
+		switch {
+		case err == nil:
+			return ln
+		case addr.Port == 0 || !errors.Is(err, syscall.EADDRINUSE):
+			t.Fatalf("error listening on %s for core %d: %v", addr, idx, err)
+		case attempt < testListenPortRetries:
+			time.Sleep(time.Duration(attempt+1) * 50 * time.Millisecond)
+		case opts != nil && opts.SkipIfListenPortInUse:
+			t.Skipf("listener port %d for core %d is in use: %v", addr.Port, idx, err)
+		default:
+			t.Fatalf("listener port %d for core %d is still in use after %d retries; "+
+				"assign another port or set SkipIfListenPortInUse: %v", addr.Port, idx, testListenPortRetries, err)
+		}
+	}
+}
+
 // NewTestCluster creates a new test cluster based on the provided core config
 // and test cluster options.
 //
@@ -1257,6 +1358,10 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 		}
 	}
 
+	if opts != nil && len(opts.ListenPorts) > numCores {
+		t.Fatalf("%d listen ports given for %d cores", len(opts.ListenPorts), numCores)
+	}
+
 	var testCluster TestCluster
 	testCluster.base = base
 
@@ -1422,11 +1527,11 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 		if baseAddr.Port != 0 {
 			addr.Port = baseAddr.Port + i
 		}
-
-		ln, err := net.ListenTCP("tcp", addr)
-		if err != nil {
-			t.Fatal(err)
+		if opts != nil && i < len(opts.ListenPorts) {
+			addr.Port = opts.ListenPorts[i]
 		}
+
+		ln := testListenTCP(t, addr, i, opts)
 		addresses = append(addresses, addr)
 
 		certFile := filepath.Join(testCluster.TempDir, fmt.Sprintf("node%d_port_%d_cert.pem", i+1, ln.Addr().(*net.TCPAddr).Port))
@@ -1520,6 +1625,7 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 		coreConfig.DisableSentinelTrace = base.DisableSentinelTrace
 		coreConfig.ClusterName = base.ClusterName
 		coreConfig.DisableAutopilot = base.DisableAutopilot
+		coreConfig.testOIDCKeySeed = base.testOIDCKeySeed
 
 		if base.BuiltinRegistry != nil {
 			coreConfig.BuiltinRegistry = base.BuiltinRegistry
@@ -1764,10 +1870,7 @@ func (cluster *TestCluster) StartCore(t testing.T, idx int, opts *TestClusterOpt
 	tcc.Logger().Info("restarting core", "core", idx)
 
 	// Set up listeners
-	ln, err := net.ListenTCP("tcp", tcc.Address)
-	if err != nil {
-		t.Fatal(err)
-	}
+	ln := testListenTCP(t, tcc.Address, idx, opts)
 	tcc.Listeners = []*TestListener{
 		{
 			Listener: tls.NewListener(ln, tcc.TLSConfig),
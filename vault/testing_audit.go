@@ -0,0 +1,246 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/go-testing-interface"
+)
+
+// TestInmemAuditType is the type of the in-memory audit device registered by
+// AddTestInmemAudit.
+const TestInmemAuditType = "test-inmem"
+
+// TestInmemAuditLog holds the entries logged by the in-memory audit devices
+// created from a single CoreConfig. It is safe for concurrent use, so the
+// devices of all the cores of a cluster can share it.
+type TestInmemAuditLog struct {
+	l       sync.Mutex
+	records [][]byte
+}
+
+// AddTestInmemAudit registers the in-memory audit device in the audit
+// backends of the given config under TestInmemAuditType, and returns the log
+// shared by all the devices created from it. Like the file device, the
+// device writes JSON entries with secret fields HMAC'd using the salt of the
+// device, and supports the hmac_accessor and log_raw options. Test messages
+// logged when enabling the device are not recorded.
+func AddTestInmemAudit(conf *CoreConfig) *TestInmemAuditLog {
+	log := &TestInmemAuditLog{}
+	if conf.AuditBackends == nil {
+		conf.AuditBackends = make(map[string]audit.Factory)
+	}
+	conf.AuditBackends[TestInmemAuditType] = func(_ context.Context, config *audit.BackendConfig) (audit.Backend, error) {
+		return newTestInmemAudit(config, log)
+	}
+	return log
+}
+
+// Entries returns a snapshot of the logged entries, in order. Request entries
+// are decoded with an empty response.
+func (l *TestInmemAuditLog) Entries(t testing.T) []*audit.AuditResponseEntry {
+	t.Helper()
+
+	l.l.Lock()
+	records := make([][]byte, len(l.records))
+	copy(records, l.records)
+	l.l.Unlock()
+
+	entries := make([]*audit.AuditResponseEntry, 0, len(records))
+	for _, record := range records {
+		entry := new(audit.AuditResponseEntry)
+		if err := json.Unmarshal(record, entry); err != nil {
+			t.Fatalf("error decoding audit entry %q: %v", record, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Reset discards the logged entries.
+func (l *TestInmemAuditLog) Reset() {
+	l.l.Lock()
+	defer l.l.Unlock()
+	l.records = nil
+}
+
+func (l *TestInmemAuditLog) append(record []byte) {
+	l.l.Lock()
+	defer l.l.Unlock()
+	l.records = append(l.records, record)
+}
+
+// AuditEntries returns a snapshot of the entries logged by the in-memory
+// audit devices enabled on the cores of the cluster, see AddTestInmemAudit.
+func (c *TestCluster) AuditEntries(t testing.T) []*audit.AuditResponseEntry {
+	t.Helper()
+
+	var entries []*audit.AuditResponseEntry
+	seen := make(map[*TestInmemAuditLog]struct{})
+	for _, core := range c.Cores {
+		for _, log := range core.testInmemAuditLogs() {
+			if _, ok := seen[log]; ok {
+				continue
+			}
+			seen[log] = struct{}{}
+			entries = append(entries, log.Entries(t)...)
+		}
+	}
+	if len(seen) == 0 {
+		t.Fatal("no in-memory audit device is enabled on the cluster")
+	}
+	return entries
+}
+
+// RequireAuditContains fails the test unless one of the entries logged by the
+// in-memory audit devices of the cluster matches.
+func (c *TestCluster) RequireAuditContains(t testing.T, matcher func(*audit.AuditResponseEntry) bool) {
+	t.Helper()
+
+	entries := c.AuditEntries(t)
+	for _, entry := range entries {
+		if matcher(entry) {
+			return
+		}
+	}
+	t.Fatalf("none of the %d audit entries matches", len(entries))
+}
+
+// testInmemAuditLogs returns the logs of the in-memory audit devices enabled
+// on the core.
+func (c *Core) testInmemAuditLogs() []*TestInmemAuditLog {
+	c.auditLock.RLock()
+	broker := c.auditBroker
+	c.auditLock.RUnlock()
+	if broker == nil {
+		return nil
+	}
+
+	broker.RLock()
+	defer broker.RUnlock()
+
+	var logs []*TestInmemAuditLog
+	for _, be := range broker.backends {
+		if b, ok := be.backend.(*testInmemAudit); ok {
+			logs = append(logs, b.log)
+		}
+	}
+	return logs
+}
+
+// testInmemAudit is the in-memory audit device.
+type testInmemAudit struct {
+	log *TestInmemAuditLog
+
+	saltConfig   *salt.Config
+	saltView     logical.Storage
+	salt         *salt.Salt
+	saltMutex    sync.RWMutex
+	formatter    audit.AuditFormatter
+	formatConfig audit.FormatterConfig
+}
+
+func newTestInmemAudit(config *audit.BackendConfig, log *TestInmemAuditLog) (*testInmemAudit, error) {
+	if config.SaltConfig == nil {
+		return nil, fmt.Errorf("nil salt config")
+	}
+	if config.SaltView == nil {
+		return nil, fmt.Errorf("nil salt view")
+	}
+
+	b := &testInmemAudit{
+		log:        log,
+		saltConfig: config.SaltConfig,
+		saltView:   config.SaltView,
+		formatConfig: audit.FormatterConfig{
+			HMACAccessor: true,
+		},
+	}
+	if raw, ok := config.Config["hmac_accessor"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		b.formatConfig.HMACAccessor = value
+	}
+	if raw, ok := config.Config["log_raw"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		b.formatConfig.Raw = value
+	}
+	b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{
+		SaltFunc: b.Salt,
+	}
+	return b, nil
+}
+
+func (b *testInmemAudit) LogRequest(ctx context.Context, in *logical.LogInput) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(ctx, &buf, b.formatConfig, in); err != nil {
+		return err
+	}
+	b.log.append(buf.Bytes())
+	return nil
+}
+
+func (b *testInmemAudit) LogResponse(ctx context.Context, in *logical.LogInput) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(ctx, &buf, b.formatConfig, in); err != nil {
+		return err
+	}
+	b.log.append(buf.Bytes())
+	return nil
+}
+
+func (b *testInmemAudit) LogTestMessage(ctx context.Context, in *logical.LogInput, config map[string]string) error {
+	tempFormatter := audit.NewTemporaryFormatter(config["format"], config["prefix"])
+	return tempFormatter.FormatResponse(ctx, ioutil.Discard, b.formatConfig, in)
+}
+
+func (b *testInmemAudit) GetHash(ctx context.Context, data string) (string, error) {
+	salt, err := b.Salt(ctx)
+	if err != nil {
+		return "", err
+	}
+	return audit.HashString(salt, data), nil
+}
+
+func (b *testInmemAudit) Reload(_ context.Context) error {
+	return nil
+}
+
+func (b *testInmemAudit) Invalidate(_ context.Context) {
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	b.salt = nil
+}
+
+func (b *testInmemAudit) Salt(ctx context.Context) (*salt.Salt, error) {
+	b.saltMutex.RLock()
+	if b.salt != nil {
+		defer b.saltMutex.RUnlock()
+		return b.salt, nil
+	}
+	b.saltMutex.RUnlock()
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	if b.salt != nil {
+		return b.salt, nil
+	}
+	salt, err := salt.NewSalt(ctx, b.saltView, b.saltConfig)
+	if err != nil {
+		return nil, err
+	}
+	b.salt = salt
+	return salt, nil
+}
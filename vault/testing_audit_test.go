@@ -0,0 +1,77 @@
+package vault
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestInmemAudit(t *testing.T) {
+	conf := &CoreConfig{}
+	log := AddTestInmemAudit(conf)
+	c, _, root := TestCoreUnsealedWithConfig(t, conf)
+
+	me := &MountEntry{
+		Table: auditTableType,
+		Path:  "inmem/",
+		Type:  TestInmemAuditType,
+	}
+	if err := c.enableAudit(namespace.RootContext(nil), me, true); err != nil {
+		t.Fatal(err)
+	}
+	if entries := log.Entries(t); len(entries) != 0 {
+		t.Fatalf("expected test message not to be recorded, got %#v", entries)
+	}
+
+	// Requests may be logged concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+			req.ClientToken = root
+			if _, err := c.HandleRequest(namespace.RootContext(nil), req); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := log.Entries(t)
+	if len(entries) != 10 {
+		t.Fatalf("expected 10 entries, got %d", len(entries))
+	}
+
+	var responses int
+	for _, entry := range entries {
+		if entry.Type != "response" {
+			continue
+		}
+		responses++
+		if entry.Request.Path != "auth/token/create" {
+			t.Fatalf("unexpected path %q", entry.Request.Path)
+		}
+		if entry.Response.Auth == nil || !strings.HasPrefix(entry.Response.Auth.ClientToken, "hmac-sha256:") {
+			t.Fatalf("expected HMAC'd client token, got %#v", entry.Response.Auth)
+		}
+		hash, err := c.auditBroker.GetHash(namespace.RootContext(nil), "inmem/", root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Request.ClientToken != hash {
+			t.Fatalf("expected client token %q, got %q", hash, entry.Request.ClientToken)
+		}
+	}
+	if responses != 5 {
+		t.Fatalf("expected 5 response entries, got %d", responses)
+	}
+
+	log.Reset()
+	if entries := log.Entries(t); len(entries) != 0 {
+		t.Fatalf("expected no entries after reset, got %d", len(entries))
+	}
+}
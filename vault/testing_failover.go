@@ -0,0 +1,98 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-testing-interface"
+)
+
+// testFailoverTimeout bounds the time the failover helpers wait for cores to
+// seal or for a new active core to be elected.
+const testFailoverTimeout = 60 * time.Second
+
+// StepDownActive forces the active core of the cluster to step down, waits
+// for another core to become active and returns its index.
+func (c *TestCluster) StepDownActive(t testing.T) int {
+	t.Helper()
+
+	idx := c.WaitForNewActive(t)
+	if err := c.Cores[idx].Client.Sys().StepDown(); err != nil {
+		t.Fatalf("error stepping down active core %d: %v", idx, err)
+	}
+	return c.WaitForNewActive(t, idx)
+}
+
+// SealCore seals the core with the given index and waits for it to be
+// sealed. If it was active, another core may then become active, see
+// WaitForNewActive. It can be unsealed again with UnsealCore.
+func (c *TestCluster) SealCore(t testing.T, idx int) {
+	t.Helper()
+
+	if idx < 0 || idx >= len(c.Cores) {
+		t.Fatalf("invalid core index %d", idx)
+	}
+	core := c.Cores[idx]
+	core.Seal(t)
+
+	deadline := time.Now().Add(testFailoverTimeout)
+	for !core.Sealed() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for core %d to seal, current state: %s", idx, testHAState(core.Core))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// WaitForNewActive waits for a core of the cluster other than the excluded
+// ones to become active, and returns its index. Excluded cores must also
+// have given up being active, so that the returned core is the only active
+// one.
+func (c *TestCluster) WaitForNewActive(t testing.T, exclude ...int) int {
+	t.Helper()
+
+	excluded := make(map[int]struct{}, len(exclude))
+	for _, idx := range exclude {
+		excluded[idx] = struct{}{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testFailoverTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		active := -1
+		stale := false
+		for i, core := range c.Cores {
+			if core.Sealed() {
+				continue
+			}
+			standby, err := core.Standby()
+			if err != nil || standby {
+				continue
+			}
+			if _, ok := excluded[i]; ok {
+				stale = true
+				continue
+			}
+			active = i
+		}
+		if active != -1 && !stale {
+			return active
+		}
+
+		select {
+		case <-ctx.Done():
+			states := make([]string, len(c.Cores))
+			for i, core := range c.Cores {
+				states[i] = fmt.Sprintf("core %d: %s", i, testHAState(core.Core))
+			}
+			t.Fatalf("timed out waiting for a new active core excluding %v; %s", exclude, strings.Join(states, "; "))
+		case <-ticker.C:
+		}
+	}
+}
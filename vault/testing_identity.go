@@ -0,0 +1,181 @@
+package vault
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/go-testing-interface"
+)
+
+// TestIdentitySpec describes the identity created by TestCreateIdentity.
+type TestIdentitySpec struct {
+	// Name and Metadata are those of the entity. Name is required.
+	Name     string
+	Metadata map[string]string
+
+	// MountPath is the path of the auth mount the alias of the entity is
+	// created on. No alias is created if it is empty.
+	MountPath string
+
+	// AliasName and AliasMetadata are the name and custom metadata of the
+	// alias. AliasName defaults to Name.
+	AliasName     string
+	AliasMetadata map[string]string
+
+	// Groups are the names of the internal groups the entity is a member of.
+	// Missing groups are created.
+	Groups []string
+}
+
+// TestIdentity is the identity created by TestCreateIdentity.
+type TestIdentity struct {
+	EntityID string
+	AliasID  string
+
+	// GroupIDs are the IDs of the groups of the spec, in order.
+	GroupIDs []string
+}
+
+// TestCreateIdentity creates the entity described by the spec using the
+// given client, along with its alias on the auth mount of the spec and its
+// group memberships. It is idempotent: entities, aliases and groups that
+// already exist are updated in place, and other members of the groups are
+// kept.
+func TestCreateIdentity(t testing.T, client *api.Client, spec TestIdentitySpec) *TestIdentity {
+	t.Helper()
+
+	if spec.Name == "" {
+		t.Fatal("identity spec has no name")
+	}
+	if spec.AliasName == "" {
+		spec.AliasName = spec.Name
+	}
+
+	entityPath := api.PathJoin("identity", "entity", "name", spec.Name)
+	entity := map[string]interface{}{}
+	if spec.Metadata != nil {
+		entity["metadata"] = spec.Metadata
+	}
+	if _, err := client.Logical().Write(entityPath, entity); err != nil {
+		t.Fatalf("error writing entity %q: %v", spec.Name, err)
+	}
+
+	ret := &TestIdentity{}
+	var entityData struct {
+		ID      string `mapstructure:"id"`
+		Aliases []struct {
+			ID        string `mapstructure:"id"`
+			Name      string `mapstructure:"name"`
+			MountPath string `mapstructure:"mount_path"`
+		} `mapstructure:"aliases"`
+	}
+	readEntity := func() {
+		t.Helper()
+		entityData.Aliases = nil
+		secret, err := client.Logical().Read(entityPath)
+		if err != nil {
+			t.Fatalf("error reading entity %q: %v", spec.Name, err)
+		}
+		if secret == nil {
+			t.Fatalf("entity %q not found after writing it", spec.Name)
+		}
+		if err := secret.DecodeData(&entityData); err != nil {
+			t.Fatalf("error decoding entity %q: %v", spec.Name, err)
+		}
+	}
+	readEntity()
+	ret.EntityID = entityData.ID
+
+	if spec.MountPath != "" {
+		// Writing an alias that already exists without changes has no
+		// response, in which case its ID is read from the entity
+		alias := map[string]interface{}{
+			"name":         spec.AliasName,
+			"mount_path":   spec.MountPath,
+			"canonical_id": ret.EntityID,
+		}
+		if spec.AliasMetadata != nil {
+			alias["custom_metadata"] = spec.AliasMetadata
+		}
+		secret, err := client.Logical().Write("identity/entity-alias", alias)
+		if err != nil {
+			t.Fatalf("error writing alias %q of entity %q on mount %q: %v", spec.AliasName, spec.Name, spec.MountPath, err)
+		}
+
+		if secret != nil {
+			ret.AliasID, _ = secret.Data["id"].(string)
+		}
+		if ret.AliasID == "" {
+			mountPath := strings.Trim(spec.MountPath, "/") + "/"
+			if !strings.HasPrefix(mountPath, "auth/") {
+				mountPath = "auth/" + mountPath
+			}
+			readEntity()
+			for _, alias := range entityData.Aliases {
+				if alias.MountPath == mountPath && alias.Name == spec.AliasName {
+					ret.AliasID = alias.ID
+				}
+			}
+		}
+		if ret.AliasID == "" {
+			t.Fatalf("alias %q of entity %q not found after writing it", spec.AliasName, spec.Name)
+		}
+	}
+
+	for _, name := range spec.Groups {
+		ret.GroupIDs = append(ret.GroupIDs, testAddGroupMember(t, client, name, ret.EntityID))
+	}
+
+	return ret
+}
+
+// testAddGroupMember adds the entity to the members of the internal group
+// with the given name, creating the group if needed, and returns the ID of
+// the group.
+func testAddGroupMember(t testing.T, client *api.Client, name, entityID string) string {
+	t.Helper()
+
+	groupPath := api.PathJoin("identity", "group", "name", name)
+	var group struct {
+		ID              string   `mapstructure:"id"`
+		Type            string   `mapstructure:"type"`
+		MemberEntityIDs []string `mapstructure:"member_entity_ids"`
+	}
+	readGroup := func() bool {
+		t.Helper()
+		secret, err := client.Logical().Read(groupPath)
+		if err != nil {
+			t.Fatalf("error reading group %q: %v", name, err)
+		}
+		if secret == nil {
+			return false
+		}
+		group.MemberEntityIDs = nil
+		if err := secret.DecodeData(&group); err != nil {
+			t.Fatalf("error decoding group %q: %v", name, err)
+		}
+		return true
+	}
+
+	if readGroup() {
+		if group.Type != "internal" {
+			t.Fatalf("group %q is of type %q; only internal groups can have member entities", name, group.Type)
+		}
+		for _, id := range group.MemberEntityIDs {
+			if id == entityID {
+				return group.ID
+			}
+		}
+	}
+
+	_, err := client.Logical().Write(groupPath, map[string]interface{}{
+		"member_entity_ids": append(group.MemberEntityIDs, entityID),
+	})
+	if err != nil {
+		t.Fatalf("error adding entity %q to group %q: %v", entityID, name, err)
+	}
+	if !readGroup() {
+		t.Fatalf("group %q not found after writing it", name)
+	}
+	return group.ID
+}
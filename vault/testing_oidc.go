@@ -0,0 +1,246 @@
+package vault
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/go-testing-interface"
+)
+
+// TestOIDCProviderOptions customizes the fixture created by
+// TestOIDCProviderSetup. Zero values are replaced by the defaults below.
+type TestOIDCProviderOptions struct {
+	// UserpassPath is the path userpass auth is enabled at. The cluster must
+	// have the userpass credential backend registered. Defaults to
+	// "userpass".
+	UserpassPath string
+
+	// Username and Password are those of the userpass user. They default to
+	// "end-user" and "testpassword".
+	Username string
+	Password string
+
+	// Policies are created and attached to the tokens of the user, by name.
+	Policies map[string]string
+
+	// EntityName and EntityMetadata are those of the entity of the user.
+	// EntityName defaults to "test-entity".
+	EntityName     string
+	EntityMetadata map[string]string
+
+	// GroupName is the name of the group the entity is a member of.
+	// Defaults to "engineering".
+	GroupName string
+
+	// KeyName and KeyAlgorithm are those of the signing key of the client.
+	// They default to "test-key" and "RS256".
+	KeyName      string
+	KeyAlgorithm string
+
+	// AssignmentName is the name of the assignment of the entity and group
+	// to the client. Defaults to "test-assignment".
+	AssignmentName string
+
+	// ClientName and ClientType are those of the OIDC client. They default
+	// to "confidential" and the client type of the same name.
+	ClientName string
+	ClientType string
+
+	// RedirectURIs are the redirect URIs of the client. Defaults to
+	// https://127.0.0.1:8251/callback.
+	RedirectURIs []string
+
+	// IDTokenTTL and AccessTokenTTL are the TTLs of the tokens issued to the
+	// client. They default to an hour and 30 minutes.
+	IDTokenTTL     time.Duration
+	AccessTokenTTL time.Duration
+
+	// Scopes returns the templates of the scopes supported by the provider
+	// by name, given the accessor of the userpass mount for templates
+	// referencing entity aliases.
+	Scopes func(mountAccessor string) map[string]string
+
+	// ProviderName is the name of the OIDC provider. Defaults to
+	// "test-provider".
+	ProviderName string
+
+	// Issuer is the issuer of the provider, of the form scheme://host:port.
+	// Defaults to the API address of the core serving the requests.
+	Issuer string
+}
+
+// TestOIDCProvider is the fixture created by TestOIDCProviderSetup.
+type TestOIDCProvider struct {
+	// Issuer is read from the discovery document of the provider.
+	Issuer       string
+	ProviderName string
+
+	ClientName   string
+	ClientID     string
+	ClientSecret string
+
+	EntityID      string
+	GroupID       string
+	MountAccessor string
+
+	Username string
+	Password string
+}
+
+// TestOIDCProviderSetup provisions an OIDC provider using the given client,
+// which must have a root token: a userpass user whose entity is a member of
+// a group, created with TestCreateIdentity, a key, an assignment of the entity and group, scopes, a client
+// and the provider allowing it.
+func TestOIDCProviderSetup(t testing.T, client *api.Client, opts *TestOIDCProviderOptions) *TestOIDCProvider {
+	t.Helper()
+
+	var o TestOIDCProviderOptions
+	if opts != nil {
+		o = *opts
+	}
+	setDefault := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	setDefault(&o.UserpassPath, "userpass")
+	setDefault(&o.Username, "end-user")
+	setDefault(&o.Password, "testpassword")
+	setDefault(&o.EntityName, "test-entity")
+	setDefault(&o.GroupName, "engineering")
+	setDefault(&o.KeyName, "test-key")
+	setDefault(&o.KeyAlgorithm, "RS256")
+	setDefault(&o.AssignmentName, "test-assignment")
+	setDefault(&o.ClientName, "confidential")
+	setDefault(&o.ClientType, "confidential")
+	setDefault(&o.ProviderName, "test-provider")
+	if len(o.RedirectURIs) == 0 {
+		o.RedirectURIs = []string{"https://127.0.0.1:8251/callback"}
+	}
+	if o.IDTokenTTL == 0 {
+		o.IDTokenTTL = time.Hour
+	}
+	if o.AccessTokenTTL == 0 {
+		o.AccessTokenTTL = 30 * time.Minute
+	}
+
+	fatalIf := func(err error, format string, args ...interface{}) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("error %s: %v", fmt.Sprintf(format, args...), err)
+		}
+	}
+
+	fixture := &TestOIDCProvider{
+		ProviderName: o.ProviderName,
+		ClientName:   o.ClientName,
+		Username:     o.Username,
+		Password:     o.Password,
+	}
+
+	// Create the user and its identity
+	policies := make([]string, 0, len(o.Policies))
+	for name, policy := range o.Policies {
+		fatalIf(client.Sys().PutPolicy(name, policy), "creating policy %q", name)
+		policies = append(policies, name)
+	}
+
+	err := client.Sys().EnableAuthWithOptions(o.UserpassPath, &api.EnableAuthOptions{
+		Type: "userpass",
+	})
+	fatalIf(err, "enabling userpass auth at %q", o.UserpassPath)
+	_, err = client.Logical().Write(api.PathJoin("auth", o.UserpassPath, "users", o.Username), map[string]interface{}{
+		"password":       o.Password,
+		"token_policies": policies,
+	})
+	fatalIf(err, "creating user %q", o.Username)
+
+	identity := TestCreateIdentity(t, client, TestIdentitySpec{
+		Name:      o.EntityName,
+		Metadata:  o.EntityMetadata,
+		MountPath: o.UserpassPath,
+		AliasName: o.Username,
+		Groups:    []string{o.GroupName},
+	})
+	fixture.EntityID = identity.EntityID
+	fixture.GroupID = identity.GroupIDs[0]
+
+	mount, err := client.Sys().GetAuthMount(o.UserpassPath)
+	fatalIf(err, "reading userpass mount %q", o.UserpassPath)
+	fixture.MountAccessor = mount.Accessor
+
+	// Create the provider resources
+	oidc := client.Identity().OIDC()
+
+	var scopes []string
+	if o.Scopes != nil {
+		for name, template := range o.Scopes(fixture.MountAccessor) {
+			err = oidc.WriteScope(name, &api.OIDCScope{Template: template})
+			fatalIf(err, "creating scope %q", name)
+			scopes = append(scopes, name)
+		}
+	}
+
+	err = oidc.WriteKey(o.KeyName, &api.OIDCKey{
+		AllowedClientIDs: &[]string{"*"},
+		Algorithm:        o.KeyAlgorithm,
+	})
+	fatalIf(err, "creating key %q", o.KeyName)
+
+	err = oidc.WriteAssignment(o.AssignmentName, &api.OIDCAssignment{
+		EntityIDs: &[]string{fixture.EntityID},
+		GroupIDs:  &[]string{fixture.GroupID},
+	})
+	fatalIf(err, "creating assignment %q", o.AssignmentName)
+
+	idTokenTTL := int(o.IDTokenTTL.Seconds())
+	accessTokenTTL := int(o.AccessTokenTTL.Seconds())
+	err = oidc.WriteClient(o.ClientName, &api.OIDCClient{
+		Key:            o.KeyName,
+		RedirectURIs:   &o.RedirectURIs,
+		Assignments:    &[]string{o.AssignmentName},
+		IDTokenTTL:     &idTokenTTL,
+		AccessTokenTTL: &accessTokenTTL,
+		ClientType:     o.ClientType,
+	})
+	fatalIf(err, "creating client %q", o.ClientName)
+
+	oidcClient, err := oidc.ReadClient(o.ClientName)
+	fatalIf(err, "reading client %q", o.ClientName)
+	if oidcClient == nil {
+		t.Fatalf("client %q not found after creating it", o.ClientName)
+	}
+	fixture.ClientID = oidcClient.ClientID
+	fixture.ClientSecret = oidcClient.ClientSecret
+
+	err = oidc.WriteProvider(o.ProviderName, &api.OIDCProvider{
+		Issuer:           &o.Issuer,
+		AllowedClientIDs: &[]string{fixture.ClientID},
+		ScopesSupported:  &scopes,
+	})
+	fatalIf(err, "creating provider %q", o.ProviderName)
+
+	var discovery struct {
+		Issuer string `json:"issuer"`
+	}
+	err = client.Logical().ReadJSONInto(
+		api.PathJoin("identity", "oidc", "provider", o.ProviderName, ".well-known", "openid-configuration"), nil, &discovery)
+	fatalIf(err, "reading discovery document of provider %q", o.ProviderName)
+	fixture.Issuer = discovery.Issuer
+
+	return fixture
+}
+
+// TestSetOIDCKeySeed makes the cores created from the given config generate
+// the OIDC keys of the identity store deterministically from the seed, so
+// that key IDs, JWKS documents and signatures are reproducible. It must be
+// set before the cores are created since the default key is generated when
+// they are first unsealed.
+func TestSetOIDCKeySeed(t testing.T, conf *CoreConfig, seed []byte) {
+	t.Helper()
+	if len(seed) == 0 {
+		t.Fatal("OIDC key seed must not be empty")
+	}
+	conf.testOIDCKeySeed = append([]byte(nil), seed...)
+}
@@ -0,0 +1,133 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// haltingT records the failure or skip of a test helper, which it stops by
+// panicking rather than by exiting the goroutine of the test.
+type haltingT struct {
+	*testing.T
+	fatal   string
+	skipped string
+}
+
+type haltedT struct{}
+
+func (t *haltingT) Fatalf(format string, args ...interface{}) {
+	t.fatal = fmt.Sprintf(format, args...)
+	panic(haltedT{})
+}
+
+func (t *haltingT) Skipf(format string, args ...interface{}) {
+	t.skipped = fmt.Sprintf(format, args...)
+	panic(haltedT{})
+}
+
+func (t *haltingT) run(f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(haltedT); !ok {
+				panic(r)
+			}
+		}
+	}()
+	f()
+}
+
+func TestTestingWaitHAState(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	// The state of the core is checked before the context, so a core that
+	// is already active is waited for even with a done context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := TestWaitActiveWithContext(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	err := TestWaitStandbyWithContext(ctx, c)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "timed out waiting for core to become standby") ||
+		!strings.Contains(err.Error(), "sealed=false standby=false") {
+		t.Fatalf("expected error with the state of the core, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = TestWaitStandbyWithContext(ctx, c)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestTestingListenTCP(t *testing.T) {
+	occupied, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+	addr := occupied.Addr().(*net.TCPAddr)
+
+	// A port that stays in use fails the test, or skips it if requested
+	ht := &haltingT{T: t}
+	ht.run(func() {
+		testListenTCP(ht, addr, 0, &TestClusterOptions{})
+	})
+	if !strings.Contains(ht.fatal, fmt.Sprintf("still in use after %d retries", testListenPortRetries)) || ht.skipped != "" {
+		t.Fatalf("expected failure, got fatal %q, skipped %q", ht.fatal, ht.skipped)
+	}
+
+	ht = &haltingT{T: t}
+	ht.run(func() {
+		testListenTCP(ht, addr, 0, &TestClusterOptions{SkipIfListenPortInUse: true})
+	})
+	if ht.skipped == "" || ht.fatal != "" {
+		t.Fatalf("expected skip, got fatal %q, skipped %q", ht.fatal, ht.skipped)
+	}
+
+	// A port released while retrying is bound
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		occupied.Close()
+	}()
+	ln := testListenTCP(t, addr, 0, nil)
+	defer ln.Close()
+	if port := ln.Addr().(*net.TCPAddr).Port; port != addr.Port {
+		t.Fatalf("expected port %d, got %d", addr.Port, port)
+	}
+}
+
+func TestTestingClusterListenPorts(t *testing.T) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	cluster := NewTestCluster(t, nil, &TestClusterOptions{
+		NumCores:    2,
+		ListenPorts: []int{port},
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	if got := cluster.Cores[0].Listeners[0].Address.Port; got != port {
+		t.Fatalf("expected core 0 to listen on port %d, got %d", port, got)
+	}
+	if got := cluster.Cores[1].Listeners[0].Address.Port; got == 0 || got == port {
+		t.Fatalf("expected core 1 to listen on another port, got %d", got)
+	}
+
+	TestWaitActive(t, cluster.Cores[0].Core)
+	TestWaitStandby(t, cluster.Cores[1].Core)
+}
@@ -512,6 +512,19 @@ func (c *Core) CreateToken(ctx context.Context, entry *logical.TokenEntry) error
 	return c.tokenStore.create(ctx, entry)
 }
 
+// RevokeToken revokes the token of the given entry and its children in the
+// core's token store. Batch tokens can't be revoked.
+func (c *Core) RevokeToken(ctx context.Context, entry *logical.TokenEntry) error {
+	if c.tokenStore == nil || c.tokenStore.expiration == nil {
+		return errors.New("unable to revoke token with nil token store")
+	}
+	if entry.Type == logical.TokenTypeBatch {
+		return errors.New("batch tokens cannot be revoked")
+	}
+
+	return c.tokenStore.revokeEntry(ctx, entry)
+}
+
 // TokenStore is used to manage client tokens. Tokens are used for
 // clients to authenticate, and each token is mapped to an applicable
 // set of policy which is used for authorization.
@@ -3075,26 +3088,31 @@ func (ts *TokenStore) revokeCommon(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse("batch tokens cannot be revoked"), nil
 	}
 
+	if err := ts.revokeEntry(ctx, te); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// revokeEntry revokes the token of the given entry and its children through
+// the revocation lease of the token.
+func (ts *TokenStore) revokeEntry(ctx context.Context, te *logical.TokenEntry) error {
 	tokenNS, err := NamespaceByID(ctx, te.NamespaceID, ts.core)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if tokenNS == nil {
-		return nil, namespace.ErrNoNamespace
+		return namespace.ErrNoNamespace
 	}
 
 	revokeCtx := namespace.ContextWithNamespace(ts.quitContext, tokenNS)
 	leaseID, err := ts.expiration.CreateOrFetchRevocationLeaseByToken(revokeCtx, te)
 	if err != nil {
-		return nil, err
-	}
-
-	err = ts.expiration.Revoke(revokeCtx, leaseID)
-	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return nil, nil
+	return ts.expiration.Revoke(revokeCtx, leaseID)
 }
 
 // handleRevokeOrphan handles the auth/token/revoke-orphan/id path for revocation of tokens